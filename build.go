@@ -36,6 +36,7 @@ var (
 	goarch    string
 	goos      string
 	noupgrade bool
+	zstd      bool
 	version   string
 	goVersion float64
 	race      bool
@@ -268,19 +269,11 @@ func runCommand(cmd string, target target) {
 		setup()
 
 	case "install":
-		var tags []string
-		if noupgrade {
-			tags = []string{"noupgrade"}
-		}
-		install(target, tags)
+		install(target, buildTags())
 		metalint(fastLinters, lintDirs)
 
 	case "build":
-		var tags []string
-		if noupgrade {
-			tags = []string{"noupgrade"}
-		}
-		build(target, tags)
+		build(target, buildTags())
 		metalint(fastLinters, lintDirs)
 
 	case "test":
@@ -350,6 +343,7 @@ func parseFlags() {
 	flag.StringVar(&goarch, "goarch", runtime.GOARCH, "GOARCH")
 	flag.StringVar(&goos, "goos", runtime.GOOS, "GOOS")
 	flag.BoolVar(&noupgrade, "no-upgrade", noupgrade, "Disable upgrade functionality")
+	flag.BoolVar(&zstd, "zstd", zstd, "Enable zstd compression (requires Go 1.20)")
 	flag.StringVar(&version, "version", getVersion(), "Set compiled in version string")
 	flag.BoolVar(&race, "race", race, "Use race detector")
 	flag.Parse()
@@ -426,6 +420,18 @@ func bench(pkgs ...string) {
 	runPrint("go", append([]string{"test", "-run", "NONE", "-bench", "."}, pkgs...)...)
 }
 
+// buildTags returns the build tags selected by the command line flags.
+func buildTags() []string {
+	var tags []string
+	if noupgrade {
+		tags = append(tags, "noupgrade")
+	}
+	if zstd {
+		tags = append(tags, "zstd")
+	}
+	return tags
+}
+
 func install(target target, tags []string) {
 	lazyRebuildAssets()
 
@@ -474,13 +480,11 @@ func buildTar(target target) {
 	name := archiveName(target)
 	filename := name + ".tar.gz"
 
-	var tags []string
 	if noupgrade {
-		tags = []string{"noupgrade"}
 		name += "-noupgrade"
 	}
 
-	build(target, tags)
+	build(target, buildTags())
 
 	if goos == "darwin" {
 		macosCodesign(target.binaryName)
@@ -502,13 +506,11 @@ func buildZip(target target) {
 	name := archiveName(target)
 	filename := name + ".zip"
 
-	var tags []string
 	if noupgrade {
-		tags = []string{"noupgrade"}
 		name += "-noupgrade"
 	}
 
-	build(target, tags)
+	build(target, buildTags())
 
 	for i := range target.archiveFiles {
 		target.archiveFiles[i].src = strings.Replace(target.archiveFiles[i].src, "{{binary}}", target.binaryName, 1)
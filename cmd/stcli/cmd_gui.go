@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/AudriusButkevicius/cli"
+	"github.com/syncthing/syncthing/lib/config"
 )
 
 func init() {
@@ -53,8 +54,8 @@ func guiDump(c *cli.Context) {
 		fmt.Fprintln(writer, "Authentication User:\t", cfg.User, "\t(username)")
 		fmt.Fprintln(writer, "Authentication Password:\t", cfg.Password, "\t(password)")
 	}
-	if cfg.APIKey != "" {
-		fmt.Fprintln(writer, "API Key:\t", cfg.APIKey, "\t(apikey)")
+	if len(cfg.APIKeyHashes) > 0 {
+		fmt.Fprintln(writer, "API Keys:\t", len(cfg.APIKeyHashes), "stored hashed", "\t(apikey)")
 	}
 	writer.Flush()
 }
@@ -78,9 +79,7 @@ func guiGet(c *cli.Context) {
 			fmt.Println(cfg.Password)
 		}
 	case "apikey":
-		if cfg.APIKey != "" {
-			fmt.Println(cfg.APIKey)
-		}
+		die("API keys are stored hashed and can't be shown; set a new one instead")
 	default:
 		die("Invalid setting: " + arg + "\nAvailable settings: enabled, tls, address, user, password, apikey")
 	}
@@ -103,7 +102,7 @@ func guiSet(c *cli.Context) {
 	case "password":
 		cfg.GUI.Password = val
 	case "apikey":
-		cfg.GUI.APIKey = val
+		cfg.GUI.APIKeyHashes = []string{config.HashAPIKey(val)}
 	default:
 		die("Invalid setting: " + arg + "\nAvailable settings: enabled, tls, address, user, password, apikey")
 	}
@@ -119,7 +118,7 @@ func guiUnset(c *cli.Context) {
 	case "password":
 		cfg.GUI.Password = ""
 	case "apikey":
-		cfg.GUI.APIKey = ""
+		cfg.GUI.APIKeyHashes = nil
 	default:
 		die("Invalid setting: " + arg + "\nAvailable settings: user, password, apikey")
 	}
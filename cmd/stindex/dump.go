@@ -10,6 +10,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/syncthing/syncthing/lib/db"
@@ -76,6 +77,22 @@ func dump(ldb *db.Instance) {
 				fmt.Printf("[deviceidx] K:%d V:%s\n", key, dev)
 			}
 
+		case db.KeyTypeFolderSelection:
+			folder := binary.BigEndian.Uint32(key[1:])
+			fmt.Printf("[selection] F:%d U:%q\n", folder, strings.Split(string(it.Value()), "\x00"))
+
+		case db.KeyTypeMigration:
+			fmt.Printf("[migration] N:%q resume:%x\n", key[1:], it.Value())
+
+		case db.KeyTypeBlockShared:
+			hash := key[1 : 1+32]
+			folder := binary.BigEndian.Uint32(key[1+32:])
+			name := nulString(key[1+32+4:])
+			fmt.Printf("[sharedblock] H:%x F:%d N:%q I:%d\n", hash, folder, name, binary.BigEndian.Uint32(it.Value()))
+
+		case db.KeyTypeBackup:
+			fmt.Printf("[backup] ID:%q chunks:%d\n", key[1:], len(it.Value())/32)
+
 		default:
 			fmt.Printf("[???]\n  %x\n  %x\n", it.Key(), it.Value())
 		}
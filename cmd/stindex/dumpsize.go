@@ -80,6 +80,22 @@ func dumpsize(ldb *db.Instance) {
 			id := binary.BigEndian.Uint32(key[1:])
 			ele.key = fmt.Sprintf("DEVICEIDX:%d", id)
 
+		case db.KeyTypeFolderSelection:
+			id := binary.BigEndian.Uint32(key[1:])
+			ele.key = fmt.Sprintf("SELECTION:%d", id)
+
+		case db.KeyTypeMigration:
+			ele.key = fmt.Sprintf("MIGRATION:%s", key[1:])
+
+		case db.KeyTypeBlockShared:
+			hash := key[1 : 1+32]
+			folder := binary.BigEndian.Uint32(key[1+32:])
+			name := nulString(key[1+32+4:])
+			ele.key = fmt.Sprintf("SHAREDBLOCK:%x:%d:%s", hash, folder, name)
+
+		case db.KeyTypeBackup:
+			ele.key = fmt.Sprintf("BACKUP:%s", key[1:])
+
 		default:
 			ele.key = fmt.Sprintf("UNKNOWN:%x", key)
 		}
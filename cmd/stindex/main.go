@@ -38,10 +38,22 @@ func main() {
 	}
 
 	if mode == "dump" {
-		dump(ldb)
+		withShards(ldb, dump)
 	} else if mode == "dumpsize" {
-		dumpsize(ldb)
+		withShards(ldb, dumpsize)
 	} else {
 		fmt.Println("Unknown mode")
 	}
 }
+
+// withShards calls fn with the database and then with the shard of each
+// folder.
+func withShards(ldb *db.Instance, fn func(*db.Instance)) {
+	fn(ldb)
+	for _, folder := range ldb.ListFolders() {
+		if shard := ldb.FolderDB(folder); shard != ldb {
+			fmt.Printf("Folder %q: %s\n", folder, shard.Location())
+			fn(shard)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/stats"
+)
+
+const (
+	alertCheckInterval = time.Minute
+	// Alerts beyond this are counted, but not kept, until the next digest.
+	maxPendingAlerts = 100
+)
+
+type alertModel interface {
+	ConnectedTo(deviceID protocol.DeviceID) bool
+	DeviceStatistics() map[string]stats.DeviceStatistics
+}
+
+type alert struct {
+	time    time.Time
+	message string
+}
+
+// The alert service watches for things the user should hear about even when
+// not looking at the GUI, such as a device having been offline for a long
+// time, and e-mails them as a digest. Ongoing conditions are alerted about
+// once, and again only after having cleared.
+type alertService struct {
+	myID     protocol.DeviceID
+	cfg      *config.Wrapper
+	model    alertModel
+	send     func(cfg config.AlertConfiguration, subject, body string) error
+	pending  []alert
+	dropped  int             // alerts not kept since the last digest
+	active   map[string]bool // ongoing conditions already alerted about, by key
+	lastSent time.Time
+	stop     chan struct{} // signals time to stop
+	started  chan struct{} // signals startup complete
+}
+
+func newAlertService(myID protocol.DeviceID, cfg *config.Wrapper, model alertModel) *alertService {
+	return &alertService{
+		myID:    myID,
+		cfg:     cfg,
+		model:   model,
+		send:    sendAlertMail,
+		active:  make(map[string]bool),
+		stop:    make(chan struct{}),
+		started: make(chan struct{}),
+	}
+}
+
+// Serve runs the alert service.
+func (s *alertService) Serve() {
+	sub := events.Default.Subscribe(events.StateChanged | events.ConflictCreated)
+	defer events.Default.Unsubscribe(sub)
+
+	t := time.NewTicker(alertCheckInterval)
+	defer t.Stop()
+
+	select {
+	case <-s.started:
+		// The started channel has already been closed; do nothing.
+	default:
+		close(s.started)
+	}
+
+	for {
+		select {
+		case ev := <-sub.C():
+			s.handleEvent(s.cfg.Alerts(), ev)
+		case now := <-t.C:
+			acfg := s.cfg.Alerts()
+			s.check(acfg, now)
+			s.flush(acfg, now)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the alert service.
+func (s *alertService) Stop() {
+	close(s.stop)
+}
+
+// WaitForStart returns once the alert service is ready to receive events,
+// or immediately if it's already running.
+func (s *alertService) WaitForStart() {
+	<-s.started
+}
+
+func (s *alertService) handleEvent(acfg config.AlertConfiguration, ev events.Event) {
+	if !acfg.Enabled {
+		return
+	}
+
+	switch ev.Type {
+	case events.StateChanged:
+		data, ok := ev.Data.(map[string]interface{})
+		if !ok || !acfg.FolderErrors {
+			return
+		}
+		folder, _ := data["folder"].(string)
+		key := "folderError/" + folder
+		if data["to"] != "error" {
+			s.clear(key)
+			return
+		}
+		msg, _ := data["error"].(string)
+		s.raise(key, ev.Time, fmt.Sprintf("Folder %s stopped with an error: %s", s.folderName(folder), msg))
+
+	case events.ConflictCreated:
+		data, ok := ev.Data.(map[string]string)
+		if !ok || !acfg.Conflicts {
+			return
+		}
+		s.add(ev.Time, fmt.Sprintf("Conflict copy %s created in folder %s.", data["item"], s.folderName(data["folder"])))
+	}
+}
+
+// check looks for the conditions that aren't signalled by events: devices
+// that have been offline too long, and folders running out of disk space.
+func (s *alertService) check(acfg config.AlertConfiguration, now time.Time) {
+	if !acfg.Enabled {
+		s.pending = nil
+		s.dropped = 0
+		s.active = make(map[string]bool)
+		return
+	}
+
+	if acfg.DeviceOfflineH > 0 {
+		limit := time.Duration(acfg.DeviceOfflineH) * time.Hour
+		devStats := s.model.DeviceStatistics()
+		for id, dcfg := range s.cfg.Devices() {
+			key := "deviceOffline/" + id.String()
+			lastSeen := devStats[id.String()].LastSeen
+			// A device never seen has a last seen time of the epoch; we
+			// can't tell how long it's been offline.
+			if id == s.myID || dcfg.Paused || lastSeen.Unix() <= 0 || now.Sub(lastSeen) < limit || s.model.ConnectedTo(id) {
+				s.clear(key)
+				continue
+			}
+			s.raise(key, now, fmt.Sprintf("Device %s has been offline since %s.", deviceName(dcfg), lastSeen.Format(time.RFC1123)))
+		}
+	}
+
+	if acfg.MinDiskFreePct > 0 {
+		for id, fcfg := range s.cfg.Folders() {
+			key := "diskLow/" + id
+			if fcfg.Paused {
+				s.clear(key)
+				continue
+			}
+			free, err := osutil.DiskFreePercentage(fcfg.Path())
+			if err != nil || free >= acfg.MinDiskFreePct {
+				s.clear(key)
+				continue
+			}
+			s.raise(key, now, fmt.Sprintf("Folder %s is low on disk space (%.1f%% free).", s.folderName(id), free))
+		}
+	}
+}
+
+// flush sends the pending alerts, unless a digest was sent recently. If
+// sending fails the alerts are kept for the next attempt.
+func (s *alertService) flush(acfg config.AlertConfiguration, now time.Time) {
+	if !acfg.Enabled || len(s.pending) == 0 && s.dropped == 0 {
+		return
+	}
+	if now.Sub(s.lastSent) < time.Duration(acfg.DigestIntervalM)*time.Minute {
+		return
+	}
+	s.lastSent = now
+
+	if err := s.send(acfg, s.digestSubject(), s.digestBody()); err != nil {
+		l.Warnln("Sending alert e-mail:", err)
+		return
+	}
+	s.pending = nil
+	s.dropped = 0
+}
+
+// raise adds an alert for the condition identified by the key, unless it's
+// already been alerted about.
+func (s *alertService) raise(key string, t time.Time, msg string) {
+	if s.active[key] {
+		return
+	}
+	s.active[key] = true
+	s.add(t, msg)
+}
+
+// clear notes that the condition identified by the key no longer applies.
+func (s *alertService) clear(key string) {
+	delete(s.active, key)
+}
+
+func (s *alertService) add(t time.Time, msg string) {
+	if len(s.pending) >= maxPendingAlerts {
+		s.dropped++
+		return
+	}
+	s.pending = append(s.pending, alert{time: t, message: msg})
+}
+
+func (s *alertService) folderName(id string) string {
+	if fcfg, ok := s.cfg.Folder(id); ok && fcfg.Label != "" {
+		return fmt.Sprintf("%q (%s)", fcfg.Label, id)
+	}
+	return fmt.Sprintf("%q", id)
+}
+
+func (s *alertService) digestSubject() string {
+	n := len(s.pending) + s.dropped
+	name := s.myID.String()
+	if dcfg, ok := s.cfg.Device(s.myID); ok {
+		name = deviceName(dcfg)
+	}
+	if n == 1 {
+		return fmt.Sprintf("Syncthing on %s: 1 alert", name)
+	}
+	return fmt.Sprintf("Syncthing on %s: %d alerts", name, n)
+}
+
+func (s *alertService) digestBody() string {
+	var buf bytes.Buffer
+	for _, a := range s.pending {
+		fmt.Fprintf(&buf, "%s  %s\n", a.time.Format("2006-01-02 15:04:05"), a.message)
+	}
+	if s.dropped > 0 {
+		fmt.Fprintf(&buf, "\n... and %d more not shown.\n", s.dropped)
+	}
+	return buf.String()
+}
+
+func deviceName(dcfg config.DeviceConfiguration) string {
+	if dcfg.Name != "" {
+		return fmt.Sprintf("%q (%s)", dcfg.Name, dcfg.DeviceID.Short())
+	}
+	return dcfg.DeviceID.String()
+}
+
+// sendAlertMail sends an e-mail as configured. Authentication is used when
+// a user is set, in which case the server must either support STARTTLS or
+// be on localhost.
+func sendAlertMail(cfg config.AlertConfiguration, subject, body string) error {
+	if cfg.SMTPServer == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return errors.New("SMTP server, sender or recipients not configured")
+	}
+
+	var auth smtp.Auth
+	if cfg.SMTPUser != "" {
+		host, _, err := net.SplitHostPort(cfg.SMTPServer)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", cfg.SMTPUser, resolveSecret(cfg.SMTPPassword), host)
+	}
+
+	return smtp.SendMail(cfg.SMTPServer, auth, cfg.From, cfg.To, alertMessage(cfg, subject, body, time.Now()))
+}
+
+// alertMessage returns the e-mail, headers and all, with CRLF line endings.
+func alertMessage(cfg config.AlertConfiguration, subject, body string, date time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return buf.Bytes()
+}
@@ -0,0 +1,167 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/stats"
+)
+
+type fakeAlertModel struct {
+	connected map[protocol.DeviceID]bool
+	stats     map[string]stats.DeviceStatistics
+}
+
+func (m *fakeAlertModel) ConnectedTo(deviceID protocol.DeviceID) bool {
+	return m.connected[deviceID]
+}
+
+func (m *fakeAlertModel) DeviceStatistics() map[string]stats.DeviceStatistics {
+	return m.stats
+}
+
+func TestAlertService(t *testing.T) {
+	myID, _ := protocol.DeviceIDFromString("GYRZZQB-IRNPV4Z-T7TC52W-EQYJ3TT-FDQW6MW-DFLMU42-SSSU6EM-FBK2VAY")
+	remote, _ := protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+	now := time.Now()
+
+	acfg := config.AlertConfiguration{
+		Enabled:         true,
+		DigestIntervalM: 60,
+		DeviceOfflineH:  24,
+		FolderErrors:    true,
+		Conflicts:       true,
+	}
+	cfg := config.Wrap("/dev/null", config.Configuration{
+		Devices: []config.DeviceConfiguration{
+			{DeviceID: myID, Name: "home"},
+			{DeviceID: remote, Name: "laptop"},
+		},
+		Folders: []config.FolderConfiguration{
+			{ID: "default", Label: "Default Folder"},
+		},
+	})
+	model := &fakeAlertModel{
+		connected: make(map[protocol.DeviceID]bool),
+		stats: map[string]stats.DeviceStatistics{
+			myID.String():   {LastSeen: time.Unix(0, 0)},
+			remote.String(): {LastSeen: now.Add(-48 * time.Hour)},
+		},
+	}
+
+	var subjects, bodies []string
+	s := newAlertService(myID, cfg, model)
+	s.send = func(_ config.AlertConfiguration, subject, body string) error {
+		subjects = append(subjects, subject)
+		bodies = append(bodies, body)
+		return nil
+	}
+
+	// The offline device is alerted about once, until it reconnects
+
+	s.check(acfg, now)
+	s.check(acfg, now)
+	if len(s.pending) != 1 || !strings.Contains(s.pending[0].message, `"laptop"`) {
+		t.Fatalf("Unexpected pending alerts %+v", s.pending)
+	}
+	model.connected[remote] = true
+	s.check(acfg, now)
+	model.connected[remote] = false
+	s.check(acfg, now)
+	if len(s.pending) != 2 {
+		t.Fatalf("Expected a new alert after reconnecting, got %+v", s.pending)
+	}
+
+	s.handleEvent(acfg, events.Event{Time: now, Type: events.StateChanged, Data: map[string]interface{}{
+		"folder": "default",
+		"from":   "idle",
+		"to":     "error",
+		"error":  "folder path missing",
+	}})
+	s.handleEvent(acfg, events.Event{Time: now, Type: events.ConflictCreated, Data: map[string]string{
+		"folder": "default",
+		"item":   "notes.sync-conflict-20170102-150405.txt",
+	}})
+
+	// The alerts are sent as a digest, at most once per interval
+
+	s.flush(acfg, now)
+	if len(bodies) != 1 || subjects[0] != `Syncthing on "home" (GYRZZQB): 4 alerts` {
+		t.Fatalf("Unexpected digests %q", subjects)
+	}
+	for _, expected := range []string{"laptop", "folder path missing", "notes.sync-conflict-20170102-150405.txt", `"Default Folder" (default)`} {
+		if !strings.Contains(bodies[0], expected) {
+			t.Errorf("Digest %q lacks %q", bodies[0], expected)
+		}
+	}
+	if len(s.pending) != 0 {
+		t.Error("Alerts still pending after sending")
+	}
+
+	s.handleEvent(acfg, events.Event{Time: now, Type: events.ConflictCreated, Data: map[string]string{
+		"folder": "default",
+		"item":   "other.sync-conflict-20170102-150405.txt",
+	}})
+	s.flush(acfg, now.Add(time.Minute))
+	if len(bodies) != 1 {
+		t.Fatal("Sent a digest before the interval passed")
+	}
+
+	// Failed digests are retried
+
+	s.send = func(config.AlertConfiguration, string, string) error {
+		return errors.New("no route to host")
+	}
+	s.flush(acfg, now.Add(time.Hour))
+	if len(s.pending) != 1 {
+		t.Errorf("Lost alerts on failure to send, %+v", s.pending)
+	}
+
+	// Nothing is collected while disabled
+
+	acfg.Enabled = false
+	s.check(acfg, now)
+	s.handleEvent(acfg, events.Event{Time: now, Type: events.ConflictCreated, Data: map[string]string{
+		"folder": "default",
+		"item":   "third.sync-conflict-20170102-150405.txt",
+	}})
+	if len(s.pending) != 0 {
+		t.Errorf("Unexpected pending alerts while disabled, %+v", s.pending)
+	}
+}
+
+func TestAlertMessage(t *testing.T) {
+	acfg := config.AlertConfiguration{
+		From: "syncthing@example.com",
+		To:   []string{"me@example.com", "you@example.com"},
+	}
+	date := time.Date(2017, 1, 2, 15, 4, 5, 0, time.UTC)
+	msg := string(alertMessage(acfg, "Syncthing on \"hémma\": 1 alert", "one\ntwo\n", date))
+
+	expected := "From: syncthing@example.com\r\n" +
+		"To: me@example.com, you@example.com\r\n" +
+		"Subject: =?utf-8?q?Syncthing_on_\"h=C3=A9mma\":_1_alert?=\r\n" +
+		"Date: Mon, 02 Jan 2017 15:04:05 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"one\r\ntwo\r\n"
+	if msg != expected {
+		t.Errorf("Unexpected message\n%q\nexpected\n%q", msg, expected)
+	}
+
+	if err := sendAlertMail(config.AlertConfiguration{}, "subject", "body"); err == nil {
+		t.Error("Expected an error sending without a server")
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+
+	"github.com/syncthing/syncthing/lib/osutil"
+)
+
+// backupDBViaRest has the running Syncthing back up its database to the
+// file, incremental to the backup with the since ID if given, and returns
+// the ID of the backup.
+func backupDBViaRest(file, since string) (string, error) {
+	r, err := newRESTRequest("GET", "rest/db/backup")
+	if err != nil {
+		return "", err
+	}
+	if since != "" {
+		r.URL.RawQuery = url.Values{"since": []string{since}}.Encode()
+	}
+
+	// A backup of a large database takes a while, so there's no timeout.
+	resp, err := restClient(0).Do(r)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		bs, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		return "", errors.New(string(bs))
+	}
+
+	fd, err := osutil.CreateAtomic(file)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(fd, resp.Body); err != nil {
+		fd.Close()
+		return "", err
+	}
+	if err := fd.Close(); err != nil {
+		return "", err
+	}
+	return resp.Header.Get("X-Backup-ID"), nil
+}
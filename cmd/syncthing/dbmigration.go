@@ -0,0 +1,182 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syncthing/syncthing/lib/tlsutil"
+)
+
+const migrationLogInterval = 30 * time.Second
+
+// migrationStatus is the progress of the running database migration, as
+// written to the migration status file and served on the GUI address.
+type migrationStatus struct {
+	db.MigrationProgress
+	Pending []string `json:"pending"`
+	Percent int      `json:"percent"` // -1 when unknown
+}
+
+// runDBMigrations runs the pending database migrations before anything else
+// uses the database. While they run, the progress is logged with an
+// estimate of when they're done, written to the migration status file for
+// the monitor process, and served on the GUI address. An interrupt or
+// terminate signal stops them safely, after which we exit; they continue
+// where they left off on the next start.
+func runDBMigrations(ldb *db.Instance, guiCfg config.GUIConfiguration) {
+	pending := ldb.PendingMigrations()
+	if len(pending) == 0 {
+		return
+	}
+
+	statusFile := locations[locMigration]
+	defer os.Remove(statusFile)
+
+	mut := sync.NewMutex()
+	status := migrationStatus{Pending: pending, Percent: -1}
+	status.Name = pending[0]
+	writeMigrationStatus(statusFile, status)
+
+	if guiCfg.Enabled {
+		listener, err := serveMigrationStatus(guiCfg, func() migrationStatus {
+			mut.Lock()
+			defer mut.Unlock()
+			return status
+		})
+		if err != nil {
+			l.Infoln("Serving database migration status:", err)
+		} else {
+			defer listener.Close()
+		}
+	}
+
+	cancel := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.Signal(15))
+	defer signal.Stop(sigs)
+	go func() {
+		select {
+		case <-sigs:
+			l.Infoln("Stopping the database migration; it continues on the next start")
+			close(cancel)
+		case <-done:
+		}
+	}()
+
+	var lastLog time.Time
+	err := ldb.RunMigrations(cancel, func(p db.MigrationProgress) {
+		mut.Lock()
+		status.MigrationProgress = p
+		status.Percent = p.Percent()
+		cur := status
+		mut.Unlock()
+
+		writeMigrationStatus(statusFile, cur)
+		if time.Since(lastLog) > migrationLogInterval {
+			lastLog = time.Now()
+			l.Infoln(migrationProgressString(cur))
+		}
+	})
+	if err == db.ErrMigrationCancelled {
+		ldb.Close()
+		os.Remove(statusFile)
+		os.Exit(exitSuccess)
+	}
+}
+
+// migrationProgressString describes the progress of the migration, for
+// the log.
+func migrationProgressString(s migrationStatus) string {
+	if s.Percent < 0 {
+		return fmt.Sprintf("Database migration %q in progress", s.Name)
+	}
+	if s.ETA.IsZero() {
+		return fmt.Sprintf("Database migration %q is %d%% done", s.Name, s.Percent)
+	}
+	left := s.ETA.Sub(time.Now())
+	if left < 0 {
+		left = 0
+	}
+	return fmt.Sprintf("Database migration %q is %d%% done, about %v left (ETA %s)", s.Name, s.Percent, left/time.Second*time.Second, s.ETA.Format("15:04:05"))
+}
+
+func writeMigrationStatus(file string, s migrationStatus) {
+	bs, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	if err := ioutil.WriteFile(file, bs, 0600); err != nil {
+		l.Debugln("Writing migration status:", err)
+	}
+}
+
+// readMigrationStatus returns the status of the migration in progress, if
+// any.
+func readMigrationStatus(file string) (migrationStatus, bool) {
+	bs, err := ioutil.ReadFile(file)
+	if err != nil {
+		return migrationStatus{}, false
+	}
+	var s migrationStatus
+	if err := json.Unmarshal(bs, &s); err != nil {
+		return migrationStatus{}, false
+	}
+	return s, true
+}
+
+// serveMigrationStatus serves the migration status at
+// /rest/system/migration on the GUI address until closed, and a notice that
+// the GUI is unavailable elsewhere. Only the progress of the migration is
+// revealed, so it requires no authentication.
+func serveMigrationStatus(guiCfg config.GUIConfiguration, status func() migrationStatus) (net.Listener, error) {
+	listener, err := net.Listen("tcp", guiCfg.Address())
+	if err != nil {
+		return nil, err
+	}
+	if cert, err := tls.LoadX509KeyPair(locations[locHTTPSCertFile], locations[locHTTPSKeyFile]); err == nil {
+		listener = &tlsutil.DowngradingListener{
+			Listener: listener,
+			TLSConfig: &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				MinVersion:   tls.VersionTLS10,
+			},
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rest/system/migration", func(w http.ResponseWriter, r *http.Request) {
+		sendJSON(w, status())
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "%s.\nThe GUI is available once it's done. Stopping Syncthing is safe; the migration continues on the next start.\n", migrationProgressString(status()))
+	})
+
+	srv := &http.Server{
+		Handler:     mux,
+		ReadTimeout: 10 * time.Second,
+	}
+	go srv.Serve(listener)
+	return listener, nil
+}
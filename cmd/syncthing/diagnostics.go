@@ -0,0 +1,144 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/logger"
+	"github.com/syncthing/syncthing/lib/redact"
+)
+
+// redactor returns the redactor for the kinds of values given by the
+// "redact" parameter of the request, or def when there is none. The paths
+// and names of the folders and devices in the config are recognized in
+// text, as are the home and config directories.
+func (s *apiService) redactor(r *http.Request, def redact.Kinds) (*redact.Redactor, error) {
+	kinds := def
+	if param, ok := r.URL.Query()["redact"]; ok && len(param) > 0 {
+		var err error
+		kinds, err = redact.ParseKinds(param[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var paths, names []string
+	for _, dir := range baseDirs {
+		paths = append(paths, dir)
+	}
+	for id, folder := range s.cfg.Folders() {
+		paths = append(paths, folder.Path(), folder.RawPath)
+		names = append(names, id, folder.Label)
+	}
+	for _, device := range s.cfg.Devices() {
+		names = append(names, device.Name)
+	}
+	return redact.New(s.redactKey, kinds, paths, names), nil
+}
+
+// redactedLog returns the log lines with their messages redacted.
+func redactedLog(lines []logger.Line, r *redact.Redactor) []logger.Line {
+	res := make([]logger.Line, len(lines))
+	for i, line := range lines {
+		res[i] = logger.Line{When: line.When, Message: r.Text(line.Message)}
+	}
+	return res
+}
+
+// redactedEvents returns the events with their data redacted.
+func redactedEvents(evs []events.Event, r *redact.Redactor) []events.Event {
+	res := make([]events.Event, len(evs))
+	for i, ev := range evs {
+		res[i] = ev
+		data, err := r.Value(ev.Data)
+		if err != nil {
+			data = redact.Redacted
+		}
+		res[i].Data = data
+	}
+	return res
+}
+
+// getSystemSupport returns a zip file with what's useful to diagnose a
+// problem: the version, the config without secrets, the log, the recent
+// events and the errors. Paths, names, device IDs and addresses in it are
+// pseudonymized unless otherwise requested with the redact parameter, so
+// that it can be shared in public.
+func (s *apiService) getSystemSupport(w http.ResponseWriter, r *http.Request) {
+	red, err := s.redactor(r, redact.All)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cfg, err := red.Value(s.cfg.RawCopy())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var logTxt bytes.Buffer
+	for _, line := range redactedLog(s.systemLog.Since(time.Time{}), red) {
+		fmt.Fprintf(&logTxt, "%s: %s\n", line.When.Format(time.RFC3339), line.Message)
+	}
+
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"version.json", map[string]string{
+			"version":     Version,
+			"codename":    Codename,
+			"longVersion": LongVersion,
+			"os":          runtime.GOOS,
+			"arch":        runtime.GOARCH,
+		}},
+		{"config.json", cfg},
+		{"log.txt", logTxt.Bytes()},
+		{"events.json", redactedEvents(s.eventSub.Since(0, []events.Event{}, 0), red)},
+		{"errors.json", redactedLog(s.guiErrors.Since(time.Time{}), red)},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		bs, ok := file.content.([]byte)
+		if !ok {
+			bs, err = json.MarshalIndent(file.content, "", "  ")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate})
+		if err == nil {
+			_, err = fw.Write(bs)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("syncthing-support-%s-%s-%s-%s.zip", runtime.GOOS, runtime.GOARCH, Version, time.Now().Format("150405")) // hhmmss
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,47 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/redact"
+)
+
+func TestRedactedEvents(t *testing.T) {
+	device := protocol.LocalDeviceID.String()
+	evs := []events.Event{
+		{SubscriptionID: 1, Type: events.Starting, Data: map[string]string{"home": "/home/user/.config/syncthing"}},
+		{SubscriptionID: 2, Type: events.DeviceConnected, Data: map[string]string{"id": device, "addr": "192.168.1.12:22000"}},
+		{SubscriptionID: 3, Type: events.ItemFinished, Data: map[string]interface{}{"folder": "photos", "item": "2017/beach.jpg", "error": nil}},
+	}
+
+	red := redact.New([]byte("key"), redact.All, nil, []string{"photos"})
+	bs, err := json.Marshal(redactedEvents(evs, red))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(bs)
+
+	for _, leak := range []string{"/home/user", device, "192.168.1.12", "photos", "beach"} {
+		if strings.Contains(out, leak) {
+			t.Errorf("%q leaks through in %s", leak, out)
+		}
+	}
+	for _, kept := range []string{"Starting", "DeviceConnected", "ItemFinished", ".jpg", red.DeviceID(device)} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("%q missing from %s", kept, out)
+		}
+	}
+	if evs[2].Data.(map[string]interface{})["item"] != "2017/beach.jpg" {
+		t.Error("The original events were modified")
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+const (
+	eventWebhookQueueSize  = 1000 // events waiting to be sent, per webhook
+	eventWebhookRetries    = 5
+	eventWebhookRetryDelay = 5 * time.Second // before the first retry, doubling for each one after
+)
+
+// The events sent to webhooks that don't select any, the same as those
+// served at /rest/events by default.
+const defaultWebhookEvents = events.AllEvents &^ events.LocalChangeDetected &^ events.RemoteChangeDetected &^ events.APITokenUsed
+
+type webhooksConfig interface {
+	Webhooks() []config.WebhookConfiguration
+}
+
+type queuedWebhookEvent struct {
+	hook config.WebhookConfiguration
+	body []byte
+}
+
+// The event webhook service POSTs events to the configured webhooks as they
+// happen. Each webhook has a queue of its own, sent in order, so that one
+// that is slow or down doesn't hold up the others. A failed POST is retried
+// a few times, waiting longer each time, before the event is given up on;
+// events that don't fit the queue are dropped.
+type eventWebhookService struct {
+	cfg        webhooksConfig
+	post       func(hook config.WebhookConfiguration, body []byte) error
+	retryDelay time.Duration
+	queues     map[string]chan queuedWebhookEvent // URL -> events to send
+	stop       chan struct{}                      // signals time to stop
+	started    chan struct{}                      // signals startup complete
+}
+
+func newEventWebhookService(cfg webhooksConfig) *eventWebhookService {
+	return &eventWebhookService{
+		cfg:        cfg,
+		post:       postEventWebhook,
+		retryDelay: eventWebhookRetryDelay,
+		queues:     make(map[string]chan queuedWebhookEvent),
+		stop:       make(chan struct{}),
+		started:    make(chan struct{}),
+	}
+}
+
+// Serve runs the event webhook service.
+func (s *eventWebhookService) Serve() {
+	sub := events.Default.Subscribe(events.AllEvents)
+	defer events.Default.Unsubscribe(sub)
+
+	select {
+	case <-s.started:
+		// The started channel has already been closed; do nothing.
+	default:
+		close(s.started)
+	}
+
+	for {
+		select {
+		case ev := <-sub.C():
+			s.handleEvent(ev)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the event webhook service.
+func (s *eventWebhookService) Stop() {
+	close(s.stop)
+}
+
+// WaitForStart returns once the event webhook service is ready to receive
+// events, or immediately if it's already running.
+func (s *eventWebhookService) WaitForStart() {
+	<-s.started
+}
+
+// handleEvent queues the event for the webhooks that want it.
+func (s *eventWebhookService) handleEvent(ev events.Event) {
+	var body []byte
+	for _, hook := range s.cfg.Webhooks() {
+		if hook.Paused || hook.URL == "" || webhookEventMask(hook.Events)&ev.Type == 0 {
+			continue
+		}
+
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(ev); err != nil {
+				l.Warnf("Encoding %v event for webhooks: %v", ev.Type, err)
+				return
+			}
+		}
+
+		queue, ok := s.queues[hook.URL]
+		if !ok {
+			queue = make(chan queuedWebhookEvent, eventWebhookQueueSize)
+			s.queues[hook.URL] = queue
+			go s.send(queue)
+		}
+		select {
+		case queue <- queuedWebhookEvent{hook, body}:
+		default:
+			l.Debugf("Dropping %v event for webhook %s, which isn't keeping up", ev.Type, hook.URL)
+		}
+	}
+}
+
+// send POSTs the events of a webhook's queue in order, retrying each a
+// few times.
+func (s *eventWebhookService) send(queue chan queuedWebhookEvent) {
+	for {
+		select {
+		case ev := <-queue:
+			delay := s.retryDelay
+			for try := 0; ; try++ {
+				err := s.post(ev.hook, ev.body)
+				if err == nil {
+					break
+				}
+				if try == eventWebhookRetries {
+					l.Warnf("Sending event to webhook %s: %v; giving up on it", ev.hook.URL, err)
+					break
+				}
+				l.Debugf("Sending event to webhook %s: %v; retrying in %v", ev.hook.URL, err, delay)
+				select {
+				case <-time.After(delay):
+				case <-s.stop:
+					return
+				}
+				delay *= 2
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// webhookEventMask returns the event types with the given names, or the
+// default ones if there are none. Unknown names are ignored.
+func webhookEventMask(names []string) events.EventType {
+	if len(names) == 0 {
+		return defaultWebhookEvents
+	}
+	var mask events.EventType
+	for _, name := range names {
+		mask |= events.UnmarshalEventType(name)
+	}
+	return mask
+}
+
+// webhookSignature returns the value of the X-Syncthing-Signature header
+// for the body: the hex encoded HMAC-SHA256 of it, using the secret as the
+// key.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func postEventWebhook(hook config.WebhookConfiguration, body []byte) error {
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hook.Secret != "" {
+		req.Header.Set("X-Syncthing-Signature", webhookSignature(resolveSecret(hook.Secret), body))
+	}
+
+	resp, err := newWebhookClient().Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return nil
+}
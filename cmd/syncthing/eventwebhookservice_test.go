@@ -0,0 +1,85 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+type fakeWebhooksConfig []config.WebhookConfiguration
+
+func (c fakeWebhooksConfig) Webhooks() []config.WebhookConfiguration {
+	return c
+}
+
+func TestEventWebhookService(t *testing.T) {
+	requests := make(chan events.Event, 10)
+	failures := 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		var ev events.Event
+		if err := json.Unmarshal(bs, &ev); err != nil {
+			t.Error(err)
+		}
+		if r.Header.Get("X-Syncthing-Signature") != webhookSignature("secret", bs) {
+			t.Errorf("Unexpected signature %q", r.Header.Get("X-Syncthing-Signature"))
+		}
+		if failures > 0 {
+			failures--
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		requests <- ev
+	}))
+	defer srv.Close()
+
+	s := newEventWebhookService(fakeWebhooksConfig{
+		{URL: srv.URL, Events: []string{"ItemFinished", "DeviceConnected"}, Secret: "secret"},
+		{URL: srv.URL + "/paused", Paused: true},
+	})
+	s.retryDelay = time.Millisecond
+	defer s.Stop()
+
+	s.handleEvent(events.Event{GlobalID: 1, Type: events.StateChanged})
+	s.handleEvent(events.Event{GlobalID: 2, Type: events.ItemFinished, Data: map[string]interface{}{"item": "foo"}})
+	s.handleEvent(events.Event{GlobalID: 3, Type: events.DeviceConnected})
+
+	// Only the selected events are sent, in order, the first after a retry
+
+	for _, expected := range []int{2, 3} {
+		select {
+		case ev := <-requests:
+			if ev.GlobalID != expected {
+				t.Errorf("Got event %d, expected %d", ev.GlobalID, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for event %d", expected)
+		}
+	}
+	select {
+	case ev := <-requests:
+		t.Errorf("Unexpected event %d", ev.GlobalID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWebhookEventMask(t *testing.T) {
+	if mask := webhookEventMask(nil); mask&events.LocalChangeDetected != 0 || mask&events.ItemFinished == 0 {
+		t.Errorf("Unexpected default mask %b", mask)
+	}
+	if mask := webhookEventMask([]string{"ItemFinished", "Nonsense"}); mask != events.ItemFinished {
+		t.Errorf("Unexpected mask %b", mask)
+	}
+}
@@ -7,12 +7,14 @@
 package main
 
 import (
+	"bytes"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -25,18 +27,23 @@ import (
 
 	"github.com/rcrowley/go-metrics"
 	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/connections"
 	"github.com/syncthing/syncthing/lib/db"
 	"github.com/syncthing/syncthing/lib/discover"
 	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore"
 	"github.com/syncthing/syncthing/lib/logger"
 	"github.com/syncthing/syncthing/lib/model"
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/rand"
+	"github.com/syncthing/syncthing/lib/redact"
 	"github.com/syncthing/syncthing/lib/stats"
 	"github.com/syncthing/syncthing/lib/sync"
 	"github.com/syncthing/syncthing/lib/tlsutil"
 	"github.com/syncthing/syncthing/lib/upgrade"
+	"github.com/syncthing/syncthing/lib/versioner"
 	"github.com/vitrun/qart/qr"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -51,48 +58,82 @@ type apiService struct {
 	httpsCertFile      string
 	httpsKeyFile       string
 	statics            *staticsServer
+	defaultAssetDir    string
 	model              modelIntf
 	eventSub           events.BufferedSubscription
 	diskEventSub       events.BufferedSubscription
 	discoverer         discover.CachingMux
 	connectionsService connectionsIntf
+	notifications      notificationsIntf
 	fss                *folderSummaryService
 	systemConfigMut    sync.Mutex    // serializes posts to /rest/system/config
 	stop               chan struct{} // signals intentional stop
 	configChanged      chan struct{} // signals intentional listener close due to config change
 	started            chan string   // signals startup complete by sending the listener address, for testing only
+	cliAPIKeyFile      string        // where to leave the CLI API key, if anywhere
 	startedOnce        chan struct{} // the service has started successfully at least once
 
 	guiErrors logger.Recorder
 	systemLog logger.Recorder
+	redactKey []byte // the key of the pseudonyms in redacted diagnostics
 }
 
 type modelIntf interface {
 	GlobalDirectoryTree(folder, prefix string, levels int, dirsonly bool) map[string]interface{}
+	GlobalNameCollisions(folder string) ([][]string, error)
+	FolderConflicts(folder string) ([]model.Conflict, error)
+	ResolveConflict(folder, name string, keepCopy bool) error
+	RepairCaseCollisions(folder string, policy config.CaseCollisionPolicy) ([]model.CaseRepair, error)
+	FolderManifest(folder string, local bool, fn func(model.ManifestEntry) bool) error
+	VerifyManifest(folder string, entries []model.ManifestEntry) (model.ManifestReport, error)
+	Unselected(folder string) ([]string, error)
+	SetSelected(folder, dir string, selected bool) error
 	Completion(device protocol.DeviceID, folder string) model.FolderCompletion
 	Override(folder string)
 	NeedFolderFiles(folder string, page, perpage int) ([]db.FileInfoTruncated, []db.FileInfoTruncated, []db.FileInfoTruncated, int)
 	NeedSize(folder string) db.Counts
 	ConnectionStats() map[string]interface{}
 	DeviceStatistics() map[string]stats.DeviceStatistics
+	DeviceConnections(device protocol.DeviceID) ([]stats.ConnectionRecord, error)
+	ManageDevice(device protocol.DeviceID, req protocol.ManagementRequest) ([]byte, error)
 	FolderStatistics() map[string]stats.FolderStatistics
 	CurrentFolderFile(folder string, file string) (protocol.FileInfo, bool)
 	CurrentGlobalFile(folder string, file string) (protocol.FileInfo, bool)
 	ResetFolder(folder string)
-	Availability(folder, file string, version protocol.Vector, block protocol.BlockInfo) []model.Availability
+	PromoteStandby(takePauseState bool) error
+	NewDatabaseBackup(since string) (*db.Backup, error)
+	Availability(folder string, file protocol.FileInfo, block protocol.BlockInfo) []model.Availability
 	GetIgnores(folder string) ([]string, []string, error)
 	SetIgnores(folder string, content []string) error
+	PreviewIgnores(folder string, content []string) (model.IgnoresPreview, error)
 	DelayScan(folder string, next time.Duration)
 	ScanFolder(folder string) error
 	ScanFolders() map[string]error
 	ScanFolderSubdirs(folder string, subs []string) error
+	QueueChanges(folder string, changes []string) error
+	FetchPlaceholders(folder string, paths []string) (int, error)
 	BringToFront(folder, file string)
+	Prioritize(folder string, files, patterns []string) error
 	ConnectedTo(deviceID protocol.DeviceID) bool
 	GlobalSize(folder string) db.Counts
 	LocalSize(folder string) db.Counts
+	QuotaExceeded(folder string) bool
+	WriteOnceViolations(folder string) int
 	CurrentSequence(folder string) (int64, bool)
 	RemoteSequence(folder string) (int64, bool)
 	State(folder string) (string, time.Time, error)
+	PendingDependencies(folder string) []string
+	FolderCapabilities() map[string]fs.Capabilities
+	RemoteClusterConfig(device protocol.DeviceID) (protocol.ClusterConfig, bool)
+	SnapshotFolder(folder, name string, devices []protocol.DeviceID) (config.FolderConfiguration, error)
+	FolderErrors(folder string) ([]model.FileError, error)
+	FolderContentStatistics(folder string, largest, days int) (model.FolderContentStatistics, error)
+	RetryFolderErrors(folder string, ids []string) error
+	IgnoreFolderErrors(folder string, ids []string) error
+	FolderVersions(folder, dir string) (map[string][]versioner.FileVersion, error)
+	FolderVersionDiff(folder, file string, versionTime time.Time) (model.VersionDiff, error)
+	FolderVersionsAt(folder, dir string, at time.Time) (map[string]time.Time, error)
+	RestoreFolderVersions(folder string, versions map[string]time.Time) (map[string]string, error)
 }
 
 type configIntf interface {
@@ -104,6 +145,7 @@ type configIntf interface {
 	Folders() map[string]config.FolderConfiguration
 	Devices() map[protocol.DeviceID]config.DeviceConfiguration
 	SetDevice(config.DeviceConfiguration) error
+	SetFolder(config.FolderConfiguration) error
 	Save() error
 	ListenAddresses() []string
 	RequiresRestart() bool
@@ -111,31 +153,52 @@ type configIntf interface {
 
 type connectionsIntf interface {
 	Status() map[string]interface{}
+	SecurityAlerts() []connections.SecurityAlert
+	AcknowledgeSecurityAlert(id int) bool
 }
 
-func newAPIService(id protocol.DeviceID, cfg configIntf, httpsCertFile, httpsKeyFile, assetDir string, m modelIntf, eventSub events.BufferedSubscription, diskEventSub events.BufferedSubscription, discoverer discover.CachingMux, connectionsService connectionsIntf, errors, systemLog logger.Recorder) *apiService {
+type notificationsIntf interface {
+	Raise(n notification) notification
+	List(unacknowledged bool) []notification
+	Acknowledge(id int) bool
+	Delete(id int) bool
+}
+
+func newAPIService(id protocol.DeviceID, cfg configIntf, httpsCertFile, httpsKeyFile, assetDir string, m modelIntf, eventSub events.BufferedSubscription, diskEventSub events.BufferedSubscription, discoverer discover.CachingMux, connectionsService connectionsIntf, notifications notificationsIntf, errors, systemLog logger.Recorder) *apiService {
 	service := &apiService{
 		id:                 id,
 		cfg:                cfg,
 		httpsCertFile:      httpsCertFile,
 		httpsKeyFile:       httpsKeyFile,
-		statics:            newStaticsServer(cfg.GUI().Theme, assetDir),
+		defaultAssetDir:    assetDir,
 		model:              m,
 		eventSub:           eventSub,
 		diskEventSub:       diskEventSub,
 		discoverer:         discoverer,
 		connectionsService: connectionsService,
+		notifications:      notifications,
 		systemConfigMut:    sync.NewMutex(),
 		stop:               make(chan struct{}),
 		configChanged:      make(chan struct{}),
 		startedOnce:        make(chan struct{}),
 		guiErrors:          errors,
 		systemLog:          systemLog,
+		redactKey:          []byte(rand.String(32)),
 	}
+	service.statics = newStaticsServer(cfg.GUI().Theme, service.assetDir(cfg.GUI()))
 
 	return service
 }
 
+// assetDir returns the directory with files overriding the compiled in GUI
+// assets; the configured one if set, otherwise the default.
+func (s *apiService) assetDir(guiCfg config.GUIConfiguration) string {
+	if guiCfg.AssetDir != "" {
+		return guiCfg.AssetDir
+	}
+	return s.defaultAssetDir
+}
+
 func (s *apiService) getListener(guiCfg config.GUIConfiguration) (net.Listener, error) {
 	cert, err := tls.LoadX509KeyPair(s.httpsCertFile, s.httpsKeyFile)
 	if err != nil {
@@ -227,56 +290,113 @@ func (s *apiService) Serve() {
 
 	// The GET handlers
 	getRestMux := http.NewServeMux()
-	getRestMux.HandleFunc("/rest/db/completion", s.getDBCompletion)              // device folder
-	getRestMux.HandleFunc("/rest/db/file", s.getDBFile)                          // folder file
-	getRestMux.HandleFunc("/rest/db/ignores", s.getDBIgnores)                    // folder
-	getRestMux.HandleFunc("/rest/db/need", s.getDBNeed)                          // folder [perpage] [page]
-	getRestMux.HandleFunc("/rest/db/status", s.getDBStatus)                      // folder
-	getRestMux.HandleFunc("/rest/db/browse", s.getDBBrowse)                      // folder [prefix] [dirsonly] [levels]
-	getRestMux.HandleFunc("/rest/events", s.getIndexEvents)                      // since [limit] [timeout]
-	getRestMux.HandleFunc("/rest/events/disk", s.getDiskEvents)                  // since [limit] [timeout]
-	getRestMux.HandleFunc("/rest/stats/device", s.getDeviceStats)                // -
-	getRestMux.HandleFunc("/rest/stats/folder", s.getFolderStats)                // -
-	getRestMux.HandleFunc("/rest/svc/deviceid", s.getDeviceID)                   // id
-	getRestMux.HandleFunc("/rest/svc/lang", s.getLang)                           // -
-	getRestMux.HandleFunc("/rest/svc/report", s.getReport)                       // -
-	getRestMux.HandleFunc("/rest/svc/random/string", s.getRandomString)          // [length]
-	getRestMux.HandleFunc("/rest/system/browse", s.getSystemBrowse)              // current
-	getRestMux.HandleFunc("/rest/system/config", s.getSystemConfig)              // -
-	getRestMux.HandleFunc("/rest/system/config/insync", s.getSystemConfigInsync) // -
-	getRestMux.HandleFunc("/rest/system/connections", s.getSystemConnections)    // -
-	getRestMux.HandleFunc("/rest/system/discovery", s.getSystemDiscovery)        // -
-	getRestMux.HandleFunc("/rest/system/error", s.getSystemError)                // -
-	getRestMux.HandleFunc("/rest/system/ping", s.restPing)                       // -
-	getRestMux.HandleFunc("/rest/system/status", s.getSystemStatus)              // -
-	getRestMux.HandleFunc("/rest/system/upgrade", s.getSystemUpgrade)            // -
-	getRestMux.HandleFunc("/rest/system/version", s.getSystemVersion)            // -
-	getRestMux.HandleFunc("/rest/system/debug", s.getSystemDebug)                // -
-	getRestMux.HandleFunc("/rest/system/log", s.getSystemLog)                    // [since]
-	getRestMux.HandleFunc("/rest/system/log.txt", s.getSystemLogTxt)             // [since]
+	getRestMux.HandleFunc("/rest/db/completion", s.getDBCompletion)               // device folder
+	getRestMux.HandleFunc("/rest/db/file", s.getDBFile)                           // folder file
+	getRestMux.HandleFunc("/rest/db/ignores", s.getDBIgnores)                     // folder
+	getRestMux.HandleFunc("/rest/db/need", s.getDBNeed)                           // folder [perpage] [page]
+	getRestMux.HandleFunc("/rest/db/status", s.getDBStatus)                       // folder
+	getRestMux.HandleFunc("/rest/db/browse", s.getDBBrowse)                       // folder [prefix] [dirsonly] [levels]
+	getRestMux.HandleFunc("/rest/db/collisions", s.getDBCollisions)               // folder
+	getRestMux.HandleFunc("/rest/db/manifest", s.getDBManifest)                   // folder [local]
+	getRestMux.HandleFunc("/rest/db/selection", s.getDBSelection)                 // folder
+	getRestMux.HandleFunc("/rest/db/backup", s.getDBBackup)                       // [since]
+	getRestMux.HandleFunc("/rest/events", s.getIndexEvents)                       // since [limit] [timeout] [redact]
+	getRestMux.HandleFunc("/rest/events/disk", s.getDiskEvents)                   // since [limit] [timeout] [redact]
+	getRestMux.HandleFunc("/rest/folder/case-conflicts", s.getDBCollisions)       // folder
+	getRestMux.HandleFunc("/rest/folder/conflicts", s.getFolderConflicts)         // folder
+	getRestMux.HandleFunc("/rest/folder/errors", s.getFolderErrors)               // folder [class...]
+	getRestMux.HandleFunc("/rest/folder/statistics", s.getFolderStatistics)       // folder [largest] [days]
+	getRestMux.HandleFunc("/rest/folder/versions", s.getFolderVersions)           // folder [dir]
+	getRestMux.HandleFunc("/rest/folder/versions/diff", s.getFolderVersionDiff)   // folder file time
+	getRestMux.HandleFunc("/rest/manage/config", s.getManageConfig)               // device
+	getRestMux.HandleFunc("/rest/notifications", s.getNotifications)              // [unacknowledged]
+	getRestMux.HandleFunc("/rest/shares", s.getShares)                            // -
+	getRestMux.HandleFunc("/rest/stats/device", s.getDeviceStats)                 // -
+	getRestMux.HandleFunc("/rest/stats/device/history", s.getDeviceHistory)       // device
+	getRestMux.HandleFunc("/rest/stats/folder", s.getFolderStats)                 // -
+	getRestMux.HandleFunc("/rest/svc/deviceid", s.getDeviceID)                    // id
+	getRestMux.HandleFunc("/rest/svc/lang", s.getLang)                            // -
+	getRestMux.HandleFunc("/rest/svc/locale", s.getLocale)                        // -
+	getRestMux.HandleFunc("/rest/svc/report", s.getReport)                        // -
+	getRestMux.HandleFunc("/rest/svc/random/string", s.getRandomString)           // [length]
+	getRestMux.HandleFunc("/rest/svc/themes", s.getThemes)                        // -
+	getRestMux.HandleFunc("/rest/system/browse", s.getSystemBrowse)               // current
+	getRestMux.HandleFunc("/rest/system/capabilities", s.getSystemCapabilities)   // -
+	getRestMux.HandleFunc("/rest/system/clusterconfig", s.getSystemClusterConfig) // device
+	getRestMux.HandleFunc("/rest/system/apitokens", s.getSystemAPITokens)         // -
+	getRestMux.HandleFunc("/rest/system/config", s.getSystemConfig)               // -
+	getRestMux.HandleFunc("/rest/system/config/insync", s.getSystemConfigInsync)  // -
+	getRestMux.HandleFunc("/rest/system/connections", s.getSystemConnections)     // -
+	getRestMux.HandleFunc("/rest/system/discovery", s.getSystemDiscovery)         // -
+	getRestMux.HandleFunc("/rest/system/error", s.getSystemError)                 // -
+	getRestMux.HandleFunc("/rest/system/features", s.getSystemFeatures)           // -
+	getRestMux.HandleFunc("/rest/system/ping", s.restPing)                        // -
+	getRestMux.HandleFunc("/rest/system/powerprofile", s.getSystemPowerProfile)   // -
+	getRestMux.HandleFunc("/rest/system/security", s.getSystemSecurity)           // -
+	getRestMux.HandleFunc("/rest/system/state", s.getSystemState)                 // [since] [timeout]
+	getRestMux.HandleFunc("/rest/system/status", s.getSystemStatus)               // -
+	getRestMux.HandleFunc("/rest/system/support", s.getSystemSupport)             // [redact]
+	getRestMux.HandleFunc("/rest/system/upgrade", s.getSystemUpgrade)             // -
+	getRestMux.HandleFunc("/rest/system/version", s.getSystemVersion)             // -
+	getRestMux.HandleFunc("/rest/system/debug", s.getSystemDebug)                 // -
+	getRestMux.HandleFunc("/rest/system/log", s.getSystemLog)                     // [since] [redact]
+	getRestMux.HandleFunc("/rest/system/log.txt", s.getSystemLogTxt)              // [since] [redact]
 
 	// The POST handlers
 	postRestMux := http.NewServeMux()
-	postRestMux.HandleFunc("/rest/db/prio", s.postDBPrio)                          // folder file [perpage] [page]
-	postRestMux.HandleFunc("/rest/db/ignores", s.postDBIgnores)                    // folder
-	postRestMux.HandleFunc("/rest/db/override", s.postDBOverride)                  // folder
-	postRestMux.HandleFunc("/rest/db/scan", s.postDBScan)                          // folder [sub...] [delay]
-	postRestMux.HandleFunc("/rest/system/config", s.postSystemConfig)              // <body>
-	postRestMux.HandleFunc("/rest/system/error", s.postSystemError)                // <body>
-	postRestMux.HandleFunc("/rest/system/error/clear", s.postSystemErrorClear)     // -
-	postRestMux.HandleFunc("/rest/system/ping", s.restPing)                        // -
-	postRestMux.HandleFunc("/rest/system/reset", s.postSystemReset)                // [folder]
-	postRestMux.HandleFunc("/rest/system/restart", s.postSystemRestart)            // -
-	postRestMux.HandleFunc("/rest/system/shutdown", s.postSystemShutdown)          // -
-	postRestMux.HandleFunc("/rest/system/upgrade", s.postSystemUpgrade)            // -
-	postRestMux.HandleFunc("/rest/system/pause", s.makeDevicePauseHandler(true))   // device
-	postRestMux.HandleFunc("/rest/system/resume", s.makeDevicePauseHandler(false)) // device
-	postRestMux.HandleFunc("/rest/system/debug", s.postSystemDebug)                // [enable] [disable]
+	postRestMux.HandleFunc("/rest/db/prio", s.postDBPrio)                                // folder file [perpage] [page]
+	postRestMux.HandleFunc("/rest/db/prioritize", s.postDBPrioritize)                    // folder [file...] [pattern...] [perpage] [page]
+	postRestMux.HandleFunc("/rest/db/ignores", s.postDBIgnores)                          // folder
+	postRestMux.HandleFunc("/rest/db/override", s.postDBOverride)                        // folder
+	postRestMux.HandleFunc("/rest/db/pause", s.makeFolderPauseHandler(true))             // folder [owner] [reason]
+	postRestMux.HandleFunc("/rest/db/resume", s.makeFolderPauseHandler(false))           // folder [owner] [force]
+	postRestMux.HandleFunc("/rest/db/scan", s.postDBScan)                                // folder [sub...] [delay]
+	postRestMux.HandleFunc("/rest/db/changes", s.postDBChanges)                          // folder [path...] [<body>]
+	postRestMux.HandleFunc("/rest/db/fetch", s.postDBFetch)                              // folder [path...]
+	postRestMux.HandleFunc("/rest/db/snapshot", s.postDBSnapshot)                        // folder name [device...]
+	postRestMux.HandleFunc("/rest/db/verify", s.postDBVerify)                            // folder
+	postRestMux.HandleFunc("/rest/db/selection", s.postDBSelection)                      // folder path selected
+	postRestMux.HandleFunc("/rest/folder/case-conflicts", s.postFolderCaseConflicts)     // folder [strategy]
+	postRestMux.HandleFunc("/rest/folder/conflicts", s.postFolderConflicts)              // folder file winner
+	postRestMux.HandleFunc("/rest/folder/errors/retry", s.postFolderErrorsRetry)         // folder [id...] [class...]
+	postRestMux.HandleFunc("/rest/folder/errors/ignore", s.postFolderErrorsIgnore)       // folder [id...] [class...]
+	postRestMux.HandleFunc("/rest/folder/ignores/preview", s.postFolderIgnoresPreview)   // folder <body>
+	postRestMux.HandleFunc("/rest/folder/versions", s.postFolderVersions)                // folder [dir time] [dryrun] <body>
+	postRestMux.HandleFunc("/rest/manage/config", s.postManageConfig)                    // device <body>
+	postRestMux.HandleFunc("/rest/manage/pause", s.makeManagePauseHandler(true))         // device folder|target [owner] [reason]
+	postRestMux.HandleFunc("/rest/manage/resume", s.makeManagePauseHandler(false))       // device folder|target [owner] [force]
+	postRestMux.HandleFunc("/rest/manage/restart", s.postManageRestart)                  // device
+	postRestMux.HandleFunc("/rest/notifications", s.postNotification)                    // <body>
+	postRestMux.HandleFunc("/rest/notifications/ack", s.postNotificationAck)             // [id]
+	postRestMux.HandleFunc("/rest/notifications/delete", s.postNotificationDelete)       // id
+	postRestMux.HandleFunc("/rest/shares", s.postShares)                                 // folder [path] [hours]
+	postRestMux.HandleFunc("/rest/shares/revoke", s.postSharesRevoke)                    // id
+	postRestMux.HandleFunc("/rest/svc/folder/check", s.postFolderCheck)                  // <body>
+	postRestMux.HandleFunc("/rest/svc/locale", s.postLocale)                             // [lang]
+	postRestMux.HandleFunc("/rest/system/apikey/rotate", s.postSystemAPIKeyRotate)       // [revoke]
+	postRestMux.HandleFunc("/rest/system/apitokens", s.postSystemAPITokens)              // name scope... [folder...]
+	postRestMux.HandleFunc("/rest/system/apitokens/revoke", s.postSystemAPITokensRevoke) // name
+	postRestMux.HandleFunc("/rest/system/config", s.postSystemConfig)                    // <body>
+	postRestMux.HandleFunc("/rest/system/error", s.postSystemError)                      // <body>
+	postRestMux.HandleFunc("/rest/system/error/clear", s.postSystemErrorClear)           // -
+	postRestMux.HandleFunc("/rest/system/ping", s.restPing)                              // -
+	postRestMux.HandleFunc("/rest/system/promote", s.postSystemPromote)                  // [pause]
+	postRestMux.HandleFunc("/rest/system/powerprofile", s.postSystemPowerProfile)        // profile
+	postRestMux.HandleFunc("/rest/system/reset", s.postSystemReset)                      // [folder]
+	postRestMux.HandleFunc("/rest/system/security/ack", s.postSystemSecurityAck)         // id
+	postRestMux.HandleFunc("/rest/system/sessions/clear", s.postSystemSessionsClear)     // -
+	postRestMux.HandleFunc("/rest/system/restart", s.postSystemRestart)                  // -
+	postRestMux.HandleFunc("/rest/system/shutdown", s.postSystemShutdown)                // -
+	postRestMux.HandleFunc("/rest/system/upgrade", s.postSystemUpgrade)                  // -
+	postRestMux.HandleFunc("/rest/system/pause", s.makeDevicePauseHandler(true))         // device [owner] [reason]
+	postRestMux.HandleFunc("/rest/system/resume", s.makeDevicePauseHandler(false))       // device [owner] [force]
+	postRestMux.HandleFunc("/rest/system/debug", s.postSystemDebug)                      // [enable] [disable]
 
 	// Debug endpoints, not for general use
 	debugMux := http.NewServeMux()
 	debugMux.HandleFunc("/rest/debug/peerCompletion", s.getPeerCompletion)
 	debugMux.HandleFunc("/rest/debug/httpmetrics", s.getSystemHTTPMetrics)
+	debugMux.HandleFunc("/rest/debug/lockmetrics", s.getSystemLockMetrics)
 	debugMux.HandleFunc("/rest/debug/cpuprof", s.getCPUProf) // duration
 	debugMux.HandleFunc("/rest/debug/heapprof", s.getHeapProf)
 	getRestMux.Handle("/rest/debug/", s.whenDebugging(debugMux))
@@ -289,6 +409,7 @@ func (s *apiService) Serve() {
 	mux := http.NewServeMux()
 	mux.Handle("/rest/", restMux)
 	mux.HandleFunc("/qr/", s.getQR)
+	mux.HandleFunc("/metrics", s.getMetrics)
 
 	// Serve compiled in assets unless an asset directory was set (for development)
 	mux.Handle("/", s.statics)
@@ -305,9 +426,33 @@ func (s *apiService) Serve() {
 	// Add our version and ID as a header to responses
 	handler = withDetailsMiddleware(s.id, handler)
 
-	// Wrap everything in basic auth, if user/password is set.
-	if len(guiCfg.User) > 0 && len(guiCfg.Password) > 0 {
-		handler = basicAuthAndSessionMiddleware("sessionid-"+s.id.String()[:5], guiCfg, handler)
+	// Wrap everything in basic auth, if authentication is configured.
+	if guiCfg.IsAuthEnabled() {
+		handler = basicAuthAndSessionMiddleware("sessionid-"+s.id.String()[:5], guiCfg, s.scopeMiddleware, handler)
+	}
+
+	// Requests carrying an API token skip the above, being limited to the
+	// token's scopes instead.
+	handler = apiTokenMiddleware(guiCfg, s.scopeMiddleware, withDetailsMiddleware(s.id, mux), handler)
+
+	// As do those from ourselves on the command line, carrying the CLI API
+	// key.
+	if s.cliAPIKeyFile != "" {
+		if key, err := writeCLIAPIKey(s.cliAPIKeyFile); err != nil {
+			l.Warnln("Writing CLI API key:", err)
+		} else {
+			handler = cliAPIKeyMiddleware(key, withDetailsMiddleware(s.id, mux), handler)
+		}
+	}
+
+	// Serve under a path prefix, if set. This must be inside the HTTPS
+	// redirect, which needs to see the full path.
+	if basePath := guiCfg.BasePath(); basePath != "" {
+		handler = basePathMiddleware(basePath, handler)
+	}
+
+	if guiCfg.StrictCSP {
+		handler = cspMiddleware(handler)
 	}
 
 	// Redirect to HTTPS if we are supposed to
@@ -390,7 +535,7 @@ func (s *apiService) CommitConfiguration(from, to config.Configuration) bool {
 	// No action required when this changes, so mask the fact that it changed at all.
 	from.GUI.Debugging = to.GUI.Debugging
 
-	if to.GUI == from.GUI {
+	if reflect.DeepEqual(to.GUI, from.GUI) {
 		return true
 	}
 
@@ -398,6 +543,10 @@ func (s *apiService) CommitConfiguration(from, to config.Configuration) bool {
 		s.statics.setTheme(to.GUI.Theme)
 	}
 
+	if to.GUI.AssetDir != from.GUI.AssetDir {
+		s.statics.setAssetDir(s.assetDir(to.GUI))
+	}
+
 	// Tell the serve loop to restart
 	s.configChanged <- struct{}{}
 
@@ -498,6 +647,71 @@ func redirectToHTTPSMiddleware(h http.Handler) http.Handler {
 	})
 }
 
+func basePathMiddleware(basePath string, h http.Handler) http.Handler {
+	stripped := http.StripPrefix(basePath, h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == basePath:
+			// The GUI uses relative URLs, so make sure we're in the
+			// "directory".
+			http.Redirect(w, r, basePath+"/", http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, basePath+"/"):
+			stripped.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// The GUI only loads resources from ourselves, so the policy can be strict.
+// Inline styles are used by some of the vendored components. Angular does
+// without eval, as the GUI is marked ng-csp.
+const strictCSP = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'; base-uri 'self'; form-action 'self'"
+
+func cspMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", strictCSP)
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		h.ServeHTTP(w, r)
+	})
+}
+
+// remoteAddress returns the address of the client that made the request.
+// When the request was passed on by a trusted proxy, the client address is
+// taken from the X-Forwarded-For header, skipping over any further trusted
+// proxies. Entries further to the left could have been set by the client
+// itself and are never trusted.
+func remoteAddress(r *http.Request, trustedProxies []*net.IPNet) string {
+	addr, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		addr = r.RemoteAddr
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+	for i := len(forwarded) - 1; i >= 0 && isTrustedProxy(addr, trustedProxies); i-- {
+		next := strings.TrimSpace(forwarded[i])
+		if next == "" {
+			break
+		}
+		addr = next
+	}
+	return addr
+}
+
+func isTrustedProxy(addr string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipnet := range trustedProxies {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 func noCacheMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Cache-Control", "max-age=0, no-cache, no-store")
@@ -602,6 +816,471 @@ func (s *apiService) getDBBrowse(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, s.model.GlobalDirectoryTree(folder, prefix, levels, dirsonly))
 }
 
+func (s *apiService) getDBCollisions(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	collisions, err := s.model.GlobalNameCollisions(qs.Get("folder"))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	if collisions == nil {
+		collisions = [][]string{}
+	}
+	sendJSON(w, collisions)
+}
+
+func (s *apiService) getFolderConflicts(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	conflicts, err := s.model.FolderConflicts(qs.Get("folder"))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	if conflicts == nil {
+		conflicts = []model.Conflict{}
+	}
+	sendJSON(w, conflicts)
+}
+
+// getFolderErrors returns the errors syncing files in the folder, those of
+// the given classes if any, along with the number of errors in each class.
+func (s *apiService) getFolderErrors(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	errs, err := s.model.FolderErrors(qs.Get("folder"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	classes := make(map[string]int)
+	for _, err := range errs {
+		classes[err.Class]++
+	}
+	selected := []model.FileError{}
+	for _, err := range errs {
+		if len(qs["class"]) == 0 || stringIn(err.Class, qs["class"]) {
+			selected = append(selected, err)
+		}
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"folder":  qs.Get("folder"),
+		"errors":  selected,
+		"classes": classes,
+	})
+}
+
+// getFolderStatistics returns what's in the local copy of the folder and
+// how much of it changes: a histogram of the file sizes, the bytes per
+// extension, the largest files and the churn per day.
+func (s *apiService) getFolderStatistics(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	largest, err := strconv.Atoi(qs.Get("largest"))
+	if err != nil || largest < 0 {
+		largest = 10
+	}
+	days, err := strconv.Atoi(qs.Get("days"))
+	if err != nil || days < 0 {
+		days = 30
+	}
+
+	stats, err := s.model.FolderContentStatistics(qs.Get("folder"), largest, days)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	sendJSON(w, stats)
+}
+
+func (s *apiService) postFolderErrorsRetry(w http.ResponseWriter, r *http.Request) {
+	s.handleFolderErrors(w, r, s.model.RetryFolderErrors)
+}
+
+func (s *apiService) postFolderErrorsIgnore(w http.ResponseWriter, r *http.Request) {
+	s.handleFolderErrors(w, r, s.model.IgnoreFolderErrors)
+}
+
+// handleFolderErrors calls fn with the IDs of the errors given by the id and
+// class parameters, or all of them when there are none, and returns the
+// errors as they are then.
+func (s *apiService) handleFolderErrors(w http.ResponseWriter, r *http.Request, fn func(folder string, ids []string) error) {
+	qs := r.URL.Query()
+	folder := qs.Get("folder")
+
+	errs, err := s.model.FolderErrors(folder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var ids []string
+	for _, err := range errs {
+		all := len(qs["id"]) == 0 && len(qs["class"]) == 0
+		if all || stringIn(err.ID, qs["id"]) || stringIn(err.Class, qs["class"]) {
+			ids = append(ids, err.ID)
+		}
+	}
+	if err := fn(folder, ids); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	r.URL.RawQuery = url.Values{"folder": []string{folder}}.Encode()
+	s.getFolderErrors(w, r)
+}
+
+func (s *apiService) getFolderVersions(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	versions, err := s.model.FolderVersions(qs.Get("folder"), qs.Get("dir"))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	sendJSON(w, versions)
+}
+
+func (s *apiService) getFolderVersionDiff(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	versionTime, err := time.Parse(time.RFC3339, qs.Get("time"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	diff, err := s.model.FolderVersionDiff(qs.Get("folder"), qs.Get("file"), versionTime)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	sendJSON(w, diff)
+}
+
+// postFolderVersions restores the versions in the body, a map of file names
+// to version times, or with the time parameter those bringing the directory
+// back to how it was at the time. With dryrun, the versions are returned
+// but not restored.
+func (s *apiService) postFolderVersions(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	folder := qs.Get("folder")
+
+	var versions map[string]time.Time
+	if at := qs.Get("time"); at != "" {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		versions, err = s.model.FolderVersionsAt(folder, qs.Get("dir"), t)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	} else {
+		err := json.NewDecoder(r.Body).Decode(&versions)
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	errs := map[string]string{}
+	if qs.Get("dryrun") == "" {
+		var err error
+		errs, err = s.model.RestoreFolderVersions(folder, versions)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"versions": versions,
+		"errors":   errs,
+	})
+}
+
+func (s *apiService) postFolderConflicts(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	var keepCopy bool
+	switch qs.Get("winner") {
+	case "copy":
+		keepCopy = true
+	case "file":
+		keepCopy = false
+	default:
+		http.Error(w, "winner must be either copy or file", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.model.ResolveConflict(qs.Get("folder"), qs.Get("file"), keepCopy); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+}
+
+func (s *apiService) postFolderCaseConflicts(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	folder := qs.Get("folder")
+
+	// Unless given, the strategy is the one configured for the folder.
+	policy := s.cfg.Folders()[folder].CaseCollisions
+	if strategy := qs.Get("strategy"); strategy != "" {
+		policy.UnmarshalText([]byte(strategy))
+	}
+	if policy == config.CaseCollisionReport {
+		http.Error(w, "strategy must be either rename or quarantine", http.StatusBadRequest)
+		return
+	}
+
+	repairs, err := s.model.RepairCaseCollisions(folder, policy)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	if repairs == nil {
+		repairs = []model.CaseRepair{}
+	}
+	sendJSON(w, repairs)
+}
+
+func (s *apiService) getShares(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, s.cfg.RawCopy().Shares)
+}
+
+// postShares creates a share link to the path in the folder, valid for the
+// given number of hours or until revoked. Only the hash of its token is
+// kept, so the link is only available in the response. Shares that have
+// expired are dropped at the same time.
+func (s *apiService) postShares(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	folder, ok := s.cfg.Folders()[qs.Get("folder")]
+	if !ok {
+		http.Error(w, "Invalid folder ID", http.StatusNotFound)
+		return
+	}
+	if !folder.ShareLinks {
+		http.Error(w, "Folder doesn't allow share links", http.StatusForbidden)
+		return
+	}
+
+	name := osutil.NativeFilename(strings.Trim(qs.Get("path"), "/"))
+	if _, err := lstatNoSymlinks(folder.Path(), filepath.Clean(name)); err != nil || ignore.IsInternal(name) {
+		http.Error(w, "No such file or directory", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	token := rand.String(32)
+	share := config.ShareConfiguration{
+		ID:     rand.String(8),
+		Hash:   config.HashAPIKey(token),
+		Folder: folder.ID,
+		Path:   name,
+	}
+	if hours, _ := strconv.Atoi(qs.Get("hours")); hours > 0 {
+		share.Expires = now.Add(time.Duration(hours) * time.Hour).Truncate(time.Second)
+	}
+
+	cfg := s.cfg.RawCopy()
+	shares := cfg.Shares[:0]
+	for _, existing := range cfg.Shares {
+		if !existing.Expired(now) {
+			shares = append(shares, existing)
+		}
+	}
+	cfg.Shares = append(shares, share)
+	if err := s.cfg.Replace(cfg); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if err := s.cfg.Save(); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"share": share,
+		"url":   shareURL(r, s.cfg.Options(), token),
+	})
+}
+
+func (s *apiService) postSharesRevoke(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	cfg := s.cfg.RawCopy()
+	shares := cfg.Shares[:0]
+	for _, share := range cfg.Shares {
+		if share.ID != id {
+			shares = append(shares, share)
+		}
+	}
+	if len(shares) == len(cfg.Shares) {
+		http.Error(w, "No such share", http.StatusNotFound)
+		return
+	}
+	cfg.Shares = shares
+	if err := s.cfg.Replace(cfg); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if err := s.cfg.Save(); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+}
+
+// shareURL returns the link to the share on the share gateway, or just its
+// path if the gateway isn't enabled. A gateway listening on all addresses
+// is assumed to be reachable by the host name the request was made to.
+func shareURL(r *http.Request, opts config.OptionsConfiguration, token string) string {
+	if opts.ShareGatewayAddress == "" {
+		return "/" + token + "/"
+	}
+	host, port, err := net.SplitHostPort(opts.ShareGatewayAddress)
+	if err != nil {
+		return "/" + token + "/"
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+	}
+	scheme := "http"
+	if opts.ShareGatewayTLS {
+		scheme = "https"
+	}
+	u := url.URL{Scheme: scheme, Host: net.JoinHostPort(host, port), Path: "/" + token + "/"}
+	return u.String()
+}
+
+func (s *apiService) getDBManifest(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	folder := qs.Get("folder")
+	local := qs.Get("local") != ""
+
+	// The manifest may well be too large to hold in memory, so it's
+	// streamed as a JSON array with one entry per line.
+	headerSent := false
+	enc := json.NewEncoder(w)
+	var encErr error
+	err := s.model.FolderManifest(folder, local, func(e model.ManifestEntry) bool {
+		if !headerSent {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte("[\n"))
+			headerSent = true
+		} else {
+			w.Write([]byte(","))
+		}
+		encErr = enc.Encode(e)
+		return encErr == nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if encErr != nil {
+		// Too late to report it, the client sees the truncated output.
+		l.Debugln("Sending manifest:", encErr)
+		return
+	}
+
+	if !headerSent {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte("["))
+	}
+	w.Write([]byte("]\n"))
+}
+
+func (s *apiService) getDBBackup(w http.ResponseWriter, r *http.Request) {
+	since := r.URL.Query().Get("since")
+
+	backup, err := s.model.NewDatabaseBackup(since)
+	if err == db.ErrBackupNotFound {
+		http.Error(w, err.Error(), 404)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer backup.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="syncthing-index-%s.stbak"`, backup.ID))
+	w.Header().Set("X-Backup-ID", backup.ID)
+	if err := backup.Write(w); err != nil {
+		// Too late to report it; the backup lacks its end and won't
+		// restore.
+		l.Debugln("Sending database backup:", err)
+	}
+}
+
+func (s *apiService) postDBVerify(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	var entries []model.ManifestEntry
+	err := json.NewDecoder(r.Body).Decode(&entries)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := s.model.VerifyManifest(qs.Get("folder"), entries)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	sendJSON(w, report)
+}
+
+func (s *apiService) getDBSelection(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	unselected, err := s.model.Unselected(qs.Get("folder"))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	if unselected == nil {
+		unselected = []string{}
+	}
+	sendJSON(w, map[string][]string{
+		"unselected": unselected,
+	})
+}
+
+func (s *apiService) postDBSelection(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	selected, err := strconv.ParseBool(qs.Get("selected"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.model.SetSelected(qs.Get("folder"), qs.Get("path"), selected); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+}
+
 func (s *apiService) getDBCompletion(w http.ResponseWriter, r *http.Request) {
 	var qs = r.URL.Query()
 	var folder = qs.Get("folder")
@@ -613,13 +1292,16 @@ func (s *apiService) getDBCompletion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	comp := s.model.Completion(device, folder)
-	sendJSON(w, map[string]interface{}{
+	sendJSON(w, completionInfo(s.model.Completion(device, folder)))
+}
+
+func completionInfo(comp model.FolderCompletion) map[string]interface{} {
+	return map[string]interface{}{
 		"completion":  comp.CompletionPct,
 		"needBytes":   comp.NeedBytes,
 		"globalBytes": comp.GlobalBytes,
 		"needDeletes": comp.NeedDeletes,
-	})
+	}
 }
 
 func (s *apiService) getDBStatus(w http.ResponseWriter, r *http.Request) {
@@ -644,11 +1326,21 @@ func folderSummary(cfg configIntf, m modelIntf, folder string) map[string]interf
 
 	res["inSyncFiles"], res["inSyncBytes"] = global.Files-need.Files, global.Bytes-need.Bytes
 
+	res["quotaExceeded"] = m.QuotaExceeded(folder)
+	res["writeOnceViolations"] = m.WriteOnceViolations(folder)
+
+	if folderCfg, ok := cfg.Folders()[folder]; ok && folderCfg.Paused {
+		res["pausedBy"], res["pauseReason"] = folderCfg.PausedBy, folderCfg.PauseReason
+	}
+
 	var err error
 	res["state"], res["stateChanged"], err = m.State(folder)
 	if err != nil {
 		res["error"] = err.Error()
 	}
+	if res["state"] == "sync-waiting" {
+		res["waitingFor"] = m.PendingDependencies(folder)
+	}
 
 	ourSeq, _ := m.CurrentSequence(folder)
 	remoteSeq, _ := m.RemoteSequence(folder)
@@ -674,6 +1366,32 @@ func (s *apiService) postDBOverride(w http.ResponseWriter, r *http.Request) {
 	go s.model.Override(folder)
 }
 
+func (s *apiService) postDBSnapshot(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	var devices []protocol.DeviceID
+	for _, deviceStr := range qs["device"] {
+		device, err := protocol.DeviceIDFromString(deviceStr)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		devices = append(devices, device)
+	}
+
+	// Taking a snapshot adds a folder to the config.
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	cfg, err := s.model.SnapshotFolder(qs.Get("folder"), qs.Get("name"), devices)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	sendJSON(w, cfg)
+}
+
 func (s *apiService) getDBNeed(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 
@@ -705,8 +1423,33 @@ func (s *apiService) getSystemConnections(w http.ResponseWriter, r *http.Request
 	sendJSON(w, s.model.ConnectionStats())
 }
 
-func (s *apiService) getDeviceStats(w http.ResponseWriter, r *http.Request) {
-	sendJSON(w, s.model.DeviceStatistics())
+func (s *apiService) getDeviceStats(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, s.model.DeviceStatistics())
+}
+
+// getDeviceHistory returns the history of connections to the device,
+// the current one first, with a summary of it.
+func (s *apiService) getDeviceHistory(w http.ResponseWriter, r *http.Request) {
+	device, err := protocol.DeviceIDFromString(r.URL.Query().Get("device"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, err := s.model.DeviceConnections(device)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if records == nil {
+		records = []stats.ConnectionRecord{}
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"device":      device.String(),
+		"connections": records,
+		"summary":     stats.SummarizeConnections(records, time.Now()),
+	})
 }
 
 func (s *apiService) getFolderStats(w http.ResponseWriter, r *http.Request) {
@@ -726,7 +1469,7 @@ func (s *apiService) getDBFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	av := s.model.Availability(folder, file, protocol.Vector{}, protocol.BlockInfo{})
+	av := s.model.Availability(folder, protocol.FileInfo{Name: file}, protocol.BlockInfo{})
 	sendJSON(w, map[string]interface{}{
 		"global":       jsonFileInfo(gf),
 		"local":        jsonFileInfo(lf),
@@ -750,22 +1493,66 @@ func (s *apiService) postSystemConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if to.GUI.Password != s.cfg.GUI().Password {
+	if err := prepareConfig(s.cfg, &to); err != nil {
+		l.Warnln("Preparing config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Activate and save
+
+	if err := s.cfg.Replace(to); err != nil {
+		l.Warnln("Replacing config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.cfg.Save(); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// prepareConfig readies a new config to replace the current one, as posted
+// to the REST API or sent by a controller: changed passwords are hashed,
+// secrets moved to the keychain and the usage reporting settings fixed up.
+func prepareConfig(cfg configIntf, to *config.Configuration) error {
+	if to.GUI.Password != cfg.GUI().Password {
 		if to.GUI.Password != "" {
 			hash, err := bcrypt.GenerateFromPassword([]byte(to.GUI.Password), 0)
 			if err != nil {
-				l.Warnln("bcrypting password:", err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+				return fmt.Errorf("bcrypting password: %v", err)
 			}
 
 			to.GUI.Password = string(hash)
 		}
 	}
 
+	// Likewise for the accounts, whose passwords are unchanged unless they
+	// differ from those of the accounts by the same name
+	curAccounts := make(map[string]string)
+	for _, account := range cfg.GUI().Accounts {
+		curAccounts[account.Name] = account.Password
+	}
+	for i, account := range to.GUI.Accounts {
+		if account.Password == "" || account.Password == curAccounts[account.Name] {
+			continue
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(account.Password), 0)
+		if err != nil {
+			return fmt.Errorf("bcrypting password: %v", err)
+		}
+		to.GUI.Accounts[i].Password = string(hash)
+	}
+
+	if err := storeSecrets(to); err != nil {
+		return fmt.Errorf("storing secrets in the keychain: %v", err)
+	}
+
 	// Fixup usage reporting settings
 
-	if curAcc := s.cfg.Options().URAccepted; to.Options.URAccepted > curAcc {
+	if curAcc := cfg.Options().URAccepted; to.Options.URAccepted > curAcc {
 		// UR was enabled
 		to.Options.URAccepted = usageReportVersion
 		to.Options.URUniqueID = rand.String(8)
@@ -774,8 +1561,58 @@ func (s *apiService) postSystemConfig(w http.ResponseWriter, r *http.Request) {
 		to.Options.URAccepted = -1
 		to.Options.URUniqueID = ""
 	}
+	return nil
+}
 
-	// Activate and save
+func (s *apiService) postSystemAPIKeyRotate(w http.ResponseWriter, r *http.Request) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	revoke, _ := strconv.ParseBool(r.URL.Query().Get("revoke"))
+
+	to := s.cfg.RawCopy()
+	apiKey := to.GUI.RotateAPIKey(revoke)
+
+	if err := s.cfg.Replace(to); err != nil {
+		l.Warnln("Replacing config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.cfg.Save(); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// This is the only time the key is available in plain text.
+	sendJSON(w, map[string]string{
+		"apiKey": apiKey,
+	})
+}
+
+func (s *apiService) getSystemPowerProfile(w http.ResponseWriter, r *http.Request) {
+	opts := s.cfg.Options()
+	sendJSON(w, map[string]interface{}{
+		"profile": opts.PowerProfile,
+		"active":  opts.ActivePowerProfile,
+	})
+}
+
+func (s *apiService) postSystemPowerProfile(w http.ResponseWriter, r *http.Request) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	name := r.URL.Query().Get("profile")
+	var profile config.PowerProfile
+	profile.UnmarshalText([]byte(name))
+	if profile.String() != name {
+		http.Error(w, "unknown power profile", http.StatusBadRequest)
+		return
+	}
+
+	to := s.cfg.RawCopy()
+	to.Options.PowerProfile = profile
 
 	if err := s.cfg.Replace(to); err != nil {
 		l.Warnln("Replacing config:", err)
@@ -788,6 +1625,8 @@ func (s *apiService) postSystemConfig(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
+	s.getSystemPowerProfile(w, r)
 }
 
 func (s *apiService) getSystemConfigInsync(w http.ResponseWriter, r *http.Request) {
@@ -892,16 +1731,159 @@ func (s *apiService) postSystemError(w http.ResponseWriter, r *http.Request) {
 	l.Warnln(string(bs))
 }
 
+func (s *apiService) postSystemPromote(w http.ResponseWriter, r *http.Request) {
+	if err := s.model.PromoteStandby(r.URL.Query().Get("pause") != ""); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	s.flushResponse(`{"ok": "promoted"}`, w)
+}
+
 func (s *apiService) postSystemErrorClear(w http.ResponseWriter, r *http.Request) {
 	s.guiErrors.Clear()
 }
 
+func (s *apiService) getSystemCapabilities(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, s.model.FolderCapabilities())
+}
+
+// featureStatus is an experimental feature as listed at
+// /rest/system/features.
+type featureStatus struct {
+	config.Feature
+	Enabled bool `json:"enabled"`
+}
+
+func (s *apiService) getSystemFeatures(w http.ResponseWriter, r *http.Request) {
+	opts := s.cfg.Options()
+	features := config.Features()
+	res := make([]featureStatus, len(features))
+	for i, feature := range features {
+		res[i] = featureStatus{
+			Feature: feature,
+			Enabled: opts.FeatureEnabled(feature.Name),
+		}
+	}
+	sendJSON(w, res)
+}
+
+func (s *apiService) getSystemClusterConfig(w http.ResponseWriter, r *http.Request) {
+	device, err := protocol.DeviceIDFromString(r.URL.Query().Get("device"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cm, ok := s.model.RemoteClusterConfig(device)
+	if !ok {
+		http.Error(w, "No cluster config received from device", http.StatusNotFound)
+		return
+	}
+	sendJSON(w, cm)
+}
+
+func (s *apiService) getSystemSecurity(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, map[string][]connections.SecurityAlert{
+		"alerts": s.connectionsService.SecurityAlerts(),
+	})
+}
+
+func (s *apiService) postSystemSecurityAck(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.connectionsService.AcknowledgeSecurityAlert(id) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (s *apiService) getNotifications(w http.ResponseWriter, r *http.Request) {
+	unacknowledged := r.URL.Query().Get("unacknowledged") == "true"
+	sendJSON(w, map[string][]notification{
+		"notifications": s.notifications.List(unacknowledged),
+	})
+}
+
+func (s *apiService) postNotification(w http.ResponseWriter, r *http.Request) {
+	var n notification
+	err := json.NewDecoder(r.Body).Decode(&n)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch n.Severity {
+	case severityInfo, severityWarning, severityError:
+	case "":
+		n.Severity = severityInfo
+	default:
+		http.Error(w, "invalid severity", http.StatusBadRequest)
+		return
+	}
+	if n.Message == "" {
+		http.Error(w, "message required", http.StatusBadRequest)
+		return
+	}
+	if n.Kind == "" {
+		n.Kind = "user"
+	}
+
+	sendJSON(w, s.notifications.Raise(n))
+}
+
+func (s *apiService) postNotificationAck(w http.ResponseWriter, r *http.Request) {
+	id := 0
+	if idStr := r.URL.Query().Get("id"); idStr != "" {
+		var err error
+		id, err = strconv.Atoi(idStr)
+		if err != nil || id <= 0 {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+	}
+	if !s.notifications.Acknowledge(id) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (s *apiService) postNotificationDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.notifications.Delete(id) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (s *apiService) postSystemSessionsClear(w http.ResponseWriter, r *http.Request) {
+	clearSessions()
+}
+
+func (s *apiService) getThemes(w http.ResponseWriter, r *http.Request) {
+	theme, assetDir := s.statics.currentTheme()
+	sendJSON(w, map[string]interface{}{
+		"themes":   s.statics.availableThemes(),
+		"current":  theme,
+		"assetDir": assetDir,
+	})
+}
+
 func (s *apiService) getSystemLog(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 	since, err := time.Parse(time.RFC3339, q.Get("since"))
 	l.Debugln(err)
+	red, err := s.redactor(r, redact.None)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	sendJSON(w, map[string][]logger.Line{
-		"messages": s.systemLog.Since(since),
+		"messages": redactedLog(s.systemLog.Since(since), red),
 	})
 }
 
@@ -909,9 +1891,14 @@ func (s *apiService) getSystemLogTxt(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 	since, err := time.Parse(time.RFC3339, q.Get("since"))
 	l.Debugln(err)
+	red, err := s.redactor(r, redact.None)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 
-	for _, line := range s.systemLog.Since(since) {
+	for _, line := range redactedLog(s.systemLog.Since(since), red) {
 		fmt.Fprintf(w, "%s: %s\n", line.When.Format(time.RFC3339), line.Message)
 	}
 }
@@ -920,15 +1907,11 @@ func (s *apiService) getSystemHTTPMetrics(w http.ResponseWriter, r *http.Request
 	stats := make(map[string]interface{})
 	metrics.Each(func(name string, intf interface{}) {
 		if m, ok := intf.(*metrics.StandardTimer); ok {
-			pct := m.Percentiles([]float64{0.50, 0.95, 0.99})
-			for i := range pct {
-				pct[i] /= 1e6 // ns to ms
-			}
 			stats[name] = map[string]interface{}{
 				"count":         m.Count(),
 				"sumMs":         m.Sum() / 1e6, // ns to ms
 				"ratesPerS":     []float64{m.Rate1(), m.Rate5(), m.Rate15()},
-				"percentilesMs": pct,
+				"percentilesMs": percentilesMs(m),
 			}
 		}
 	})
@@ -936,6 +1919,34 @@ func (s *apiService) getSystemHTTPMetrics(w http.ResponseWriter, r *http.Request
 	w.Write(bs)
 }
 
+// getSystemLockMetrics returns the lock metrics by call site. These are
+// only collected when STLOCKMETRICS is set.
+func (s *apiService) getSystemLockMetrics(w http.ResponseWriter, r *http.Request) {
+	stats := make(map[string]interface{})
+	for at, m := range sync.LockMetrics() {
+		stats[at] = map[string]interface{}{
+			"count":             m.Wait.Count(),
+			"contended":         m.Contended.Count(),
+			"waitSumMs":         m.Wait.Sum() / 1e6, // ns to ms
+			"waitPercentilesMs": percentilesMs(m.Wait),
+			"holdSumMs":         m.Hold.Sum() / 1e6, // ns to ms
+			"holdPercentilesMs": percentilesMs(m.Hold),
+		}
+	}
+	bs, _ := json.MarshalIndent(stats, "", "  ")
+	w.Write(bs)
+}
+
+// percentilesMs returns the 50th, 95th and 99th percentiles of the timer,
+// in milliseconds.
+func percentilesMs(t metrics.Timer) []float64 {
+	pct := t.Percentiles([]float64{0.50, 0.95, 0.99})
+	for i := range pct {
+		pct[i] /= 1e6 // ns to ms
+	}
+	return pct
+}
+
 func (s *apiService) getSystemDiscovery(w http.ResponseWriter, r *http.Request) {
 	devices := make(map[string]discover.CacheEntry)
 
@@ -1006,6 +2017,33 @@ func (s *apiService) postDBIgnores(w http.ResponseWriter, r *http.Request) {
 	s.getDBIgnores(w, r)
 }
 
+// postFolderIgnoresPreview returns what would change if the folder's
+// ignore patterns were those in the body, as for POST /rest/db/ignores,
+// without saving them.
+func (s *apiService) postFolderIgnoresPreview(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	bs, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	var data map[string][]string
+	if err := json.Unmarshal(bs, &data); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	preview, err := s.model.PreviewIgnores(qs.Get("folder"), data["ignore"])
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	sendJSON(w, preview)
+}
+
 func (s *apiService) getIndexEvents(w http.ResponseWriter, r *http.Request) {
 	s.fss.gotEventRequest()
 	s.getEvents(w, r, s.eventSub)
@@ -1022,6 +2060,11 @@ func (s *apiService) getEvents(w http.ResponseWriter, r *http.Request, eventSub
 	timeoutStr := qs.Get("timeout")
 	since, _ := strconv.Atoi(sinceStr)
 	limit, _ := strconv.Atoi(limitStr)
+	red, err := s.redactor(r, redact.None)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	timeout := defaultEventTimeout
 	if timeoutSec, timeoutErr := strconv.Atoi(timeoutStr); timeoutErr == nil && timeoutSec >= 0 { // 0 is a valid timeout
@@ -1040,6 +2083,9 @@ func (s *apiService) getEvents(w http.ResponseWriter, r *http.Request, eventSub
 	if 0 < limit && limit < len(evs) {
 		evs = evs[len(evs)-limit:]
 	}
+	if red.Kinds() != redact.None {
+		evs = redactedEvents(evs, red)
+	}
 
 	sendJSON(w, evs)
 }
@@ -1127,8 +2173,19 @@ func (s *apiService) makeDevicePauseHandler(paused bool) http.HandlerFunc {
 		cfg, ok := s.cfg.Devices()[device]
 		if !ok {
 			http.Error(w, "not found", http.StatusNotFound)
+			return
 		}
 
+		if owner := qs.Get("owner"); owner != "" && !config.IsPauseOwner(owner) {
+			http.Error(w, "unknown owner", http.StatusBadRequest)
+			return
+		}
+
+		cfg.PausedBy, cfg.PauseReason, err = pauseOwner(qs, paused, cfg.PausedBy, cfg.PauseReason)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		cfg.Paused = paused
 		if err := s.cfg.SetDevice(cfg); err != nil {
 			http.Error(w, err.Error(), 500)
@@ -1136,6 +2193,56 @@ func (s *apiService) makeDevicePauseHandler(paused bool) http.HandlerFunc {
 	}
 }
 
+func (s *apiService) makeFolderPauseHandler(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var qs = r.URL.Query()
+
+		cfg, ok := s.cfg.Folders()[qs.Get("folder")]
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		if owner := qs.Get("owner"); owner != "" && !config.IsPauseOwner(owner) {
+			http.Error(w, "unknown owner", http.StatusBadRequest)
+			return
+		}
+
+		var err error
+		cfg.PausedBy, cfg.PauseReason, err = pauseOwner(qs, paused, cfg.PausedBy, cfg.PauseReason)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		cfg.Paused = paused
+		if err := s.cfg.SetFolder(cfg); err != nil {
+			http.Error(w, err.Error(), 500)
+		}
+	}
+}
+
+// pauseOwner returns who paused a folder or device and why, after the
+// request to pause or resume it, given who paused it and why until then.
+// The owner and reason are taken from the request, the owner being the user
+// unless given. Resuming what another owner paused may need forcing, as
+// config.CheckResume says.
+func pauseOwner(qs url.Values, paused bool, pausedBy, pauseReason string) (string, string, error) {
+	owner := qs.Get("owner")
+	if owner == "" {
+		owner = config.PauseOwnerUser
+	}
+
+	if paused {
+		return owner, qs.Get("reason"), nil
+	}
+	if qs.Get("force") == "" {
+		if err := config.CheckResume(owner, pausedBy, pauseReason); err != nil {
+			return "", "", err
+		}
+	}
+	return "", "", nil
+}
+
 func (s *apiService) postDBScan(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 	folder := qs.Get("folder")
@@ -1161,6 +2268,45 @@ func (s *apiService) postDBScan(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// postDBChanges queues paths changed outside of Syncthing to be scanned,
+// given as path parameters or as a JSON list in the body.
+func (s *apiService) postDBChanges(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	changes := qs["path"]
+
+	bs, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if len(bytes.TrimSpace(bs)) > 0 {
+		var paths []string
+		if err := json.Unmarshal(bs, &paths); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		changes = append(changes, paths...)
+	}
+
+	if err := s.model.QueueChanges(qs.Get("folder"), changes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+// postDBFetch asks for the data of placeholder files, or of all those in
+// the folder if no paths are given.
+func (s *apiService) postDBFetch(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	n, err := s.model.FetchPlaceholders(qs.Get("folder"), qs["path"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sendJSON(w, map[string]int{"files": n})
+}
+
 func (s *apiService) postDBPrio(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 	folder := qs.Get("folder")
@@ -1169,6 +2315,17 @@ func (s *apiService) postDBPrio(w http.ResponseWriter, r *http.Request) {
 	s.getDBNeed(w, r)
 }
 
+// postDBPrioritize moves the given files, and those matching the given glob
+// patterns, to the front of the folder's pull queue.
+func (s *apiService) postDBPrioritize(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	if err := s.model.Prioritize(qs.Get("folder"), qs["file"], qs["pattern"]); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.getDBNeed(w, r)
+}
+
 func (s *apiService) getQR(w http.ResponseWriter, r *http.Request) {
 	var qs = r.URL.Query()
 	var text = qs.Get("text")
@@ -1350,3 +2507,12 @@ func addressIsLocalhost(addr string) bool {
 		return false
 	}
 }
+
+func stringIn(s string, ss []string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -10,8 +10,11 @@ import (
 	"bytes"
 	"encoding/base64"
 	"net/http"
+	"os"
+	"os/exec"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/events"
@@ -20,19 +23,44 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+type session struct {
+	created  time.Time
+	lastUsed time.Time
+	login    login
+}
+
 var (
-	sessions    = make(map[string]bool)
+	sessions    = make(map[string]*session)
 	sessionsMut = sync.NewMutex()
 )
 
-func emitLoginAttempt(success bool, username string) {
+func emitLoginAttempt(success bool, username, remoteAddress string) {
 	events.Default.Log(events.LoginAttempt, map[string]interface{}{
-		"success":  success,
-		"username": username,
+		"success":       success,
+		"username":      username,
+		"remoteAddress": remoteAddress,
 	})
 }
 
-func basicAuthAndSessionMiddleware(cookieName string, cfg config.GUIConfiguration, next http.Handler) http.Handler {
+// basicAuthAndSessionMiddleware requires users to log in, unless they have
+// an API key. What a user may do is restricted by their role, and by the
+// given scope function for users limited to some folders. In the OIDC auth
+// mode users log in at the provider instead.
+func basicAuthAndSessionMiddleware(cookieName string, cfg config.GUIConfiguration, scope func(folders []string, next http.Handler) http.Handler, next http.Handler) http.Handler {
+	trustedProxies := cfg.TrustedProxyNets()
+	auth := newAuthenticator(cfg)
+	var oidc *oidcProvider
+	if cfg.AuthMode == config.AuthModeOIDC {
+		oidc = newOIDCProvider(cfg, cookieName+"-oidc")
+	}
+	serveAs := func(login login, w http.ResponseWriter, r *http.Request) {
+		handler := scope(login.folders, next)
+		if login.readOnly {
+			handler = readOnlyMiddleware(handler)
+		}
+		handler.ServeHTTP(w, r)
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if cfg.IsValidAPIKey(r.Header.Get("X-API-Key")) {
 			next.ServeHTTP(w, r)
@@ -40,17 +68,40 @@ func basicAuthAndSessionMiddleware(cookieName string, cfg config.GUIConfiguratio
 		}
 
 		cookie, err := r.Cookie(cookieName)
-		if err == nil && cookie != nil {
-			sessionsMut.Lock()
-			_, ok := sessions[cookie.Value]
-			sessionsMut.Unlock()
-			if ok {
-				next.ServeHTTP(w, r)
+		if err == nil && cookie != nil && useSession(cfg, cookie.Value, time.Now()) {
+			if login, ok := sessionLogin(cookie.Value); ok {
+				serveAs(login, w, r)
 				return
 			}
 		}
 
-		httpl.Debugln("Sessionless HTTP request with authentication; this is expensive.")
+		remote := remoteAddress(r, trustedProxies)
+
+		if oidc != nil {
+			if r.URL.Path != oidcCallbackPath {
+				oidc.redirect(w, r)
+				return
+			}
+			login, err := oidc.callback(r, time.Now())
+			if err != nil {
+				l.Infof("Login with OpenID Connect from %s: %v", remote, err)
+				emitLoginAttempt(false, login.username, remote)
+				http.Error(w, "Not Authorized", http.StatusUnauthorized)
+				return
+			}
+			sessionid := newSession(cfg, time.Now(), login)
+			http.SetCookie(w, &http.Cookie{
+				Name:   cookieName,
+				Value:  sessionid,
+				Path:   cfg.BasePath() + "/",
+				MaxAge: cfg.SessionLifetimeS,
+			})
+			emitLoginAttempt(true, login.username, remote)
+			http.Redirect(w, r, cfg.BasePath()+"/", http.StatusFound)
+			return
+		}
+
+		httpl.Debugln("Sessionless HTTP request with authentication from", remote, "; this is expensive.")
 
 		error := func() {
 			time.Sleep(time.Duration(rand.Intn(100)+100) * time.Millisecond)
@@ -77,55 +128,281 @@ func basicAuthAndSessionMiddleware(cookieName string, cfg config.GUIConfiguratio
 			return
 		}
 
-		// Check if the username is correct, assuming it was sent as UTF-8
-		username := string(fields[0])
-		if username == cfg.User {
-			goto usernameOK
+		login, ok := auth.authenticate(fields[0], fields[1])
+		if !ok {
+			emitLoginAttempt(false, login.username, remote)
+			error()
+			return
 		}
 
-		// ... check it again, converting it from assumed ISO-8859-1 to UTF-8
-		username = string(iso88591ToUTF8(fields[0]))
-		if username == cfg.User {
-			goto usernameOK
+		sessionid := newSession(cfg, time.Now(), login)
+		http.SetCookie(w, &http.Cookie{
+			Name:   cookieName,
+			Value:  sessionid,
+			MaxAge: cfg.SessionLifetimeS,
+		})
+
+		emitLoginAttempt(true, login.username, remote)
+		serveAs(login, w, r)
+	})
+}
+
+// readOnlyMiddleware refuses requests that could change anything, for users
+// with the read only role.
+func readOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			http.Error(w, "Forbidden: read only user", http.StatusForbidden)
+			return
 		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// An authenticator checks the credentials of a user logging in to the GUI or
+// API. The credentials are as sent by the browser, in either UTF-8 or
+// ISO-8859-1. The username is returned as interpreted, for logging, also
+// when the login is refused.
+type authenticator interface {
+	authenticate(username, password []byte) (login, bool)
+}
 
-		// Neither of the possible interpretations match the configured username
-		emitLoginAttempt(false, username)
-		error()
-		return
+// A login is a user as authenticated by an authenticator.
+type login struct {
+	username string
+	readOnly bool     // may look, but not touch; only GET requests are allowed
+	folders  []string // the folders the user is limited to, or nil for all
+}
 
-	usernameOK:
-		// Check password as given (assumes UTF-8 encoding)
-		password := fields[1]
-		if err := bcrypt.CompareHashAndPassword([]byte(cfg.Password), password); err == nil {
-			goto passwordOK
+func newAuthenticator(cfg config.GUIConfiguration) authenticator {
+	switch cfg.AuthMode {
+	case "", config.AuthModeStatic:
+		return staticAuthenticator{
+			user:         cfg.User,
+			passwordHash: resolveSecret(cfg.Password),
+			accounts:     cfg.Accounts,
+		}
+	case config.AuthModeCommand:
+		return commandAuthenticator{
+			command: cfg.AuthCommand,
 		}
+	case config.AuthModeOIDC:
+		// Users log in at the provider, not with a password here
+		return refusingAuthenticator{}
+	default:
+		l.Warnf("Unknown GUI authentication mode %q; all logins will be refused", cfg.AuthMode)
+		return refusingAuthenticator{}
+	}
+}
 
-		// ... check it again, converting it from assumed ISO-8859-1 to UTF-8
-		password = iso88591ToUTF8(password)
-		if err := bcrypt.CompareHashAndPassword([]byte(cfg.Password), password); err == nil {
-			goto passwordOK
+// The staticAuthenticator accepts the user and bcrypt hashed password from
+// the config, and those of the accounts, which are limited to their folders.
+type staticAuthenticator struct {
+	user         string
+	passwordHash string
+	accounts     []config.AccountConfiguration
+}
+
+func (a staticAuthenticator) authenticate(username, password []byte) (login, bool) {
+	user, ok := checkPassword(a.user, a.passwordHash, username, password)
+	if ok {
+		return user, true
+	}
+	for _, account := range a.accounts {
+		if user, ok := checkPassword(account.Name, account.Password, username, password); ok {
+			user.readOnly = account.ReadOnly
+			// Never nil, which would mean all folders
+			user.folders = append([]string{}, account.Folders...)
+			return user, true
 		}
+	}
+	return user, false
+}
 
-		// Neither of the attempts to verify the password checked out
-		emitLoginAttempt(false, username)
-		error()
-		return
+// checkPassword returns true if the credentials are those of the user with
+// the bcrypt hashed password.
+func checkPassword(name, passwordHash string, username, password []byte) (login, bool) {
+	// Check if the username is correct, assuming it was sent as UTF-8, and
+	// again converting it from assumed ISO-8859-1 to UTF-8
+	user := login{username: string(username)}
+	if user.username != name {
+		user.username = string(iso88591ToUTF8(username))
+		if user.username != name {
+			return user, false
+		}
+	}
 
-	passwordOK:
-		sessionid := rand.String(32)
-		sessionsMut.Lock()
-		sessions[sessionid] = true
-		sessionsMut.Unlock()
-		http.SetCookie(w, &http.Cookie{
-			Name:   cookieName,
-			Value:  sessionid,
-			MaxAge: 0,
-		})
+	// Same thing for the password
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), password); err == nil {
+		return user, true
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), iso88591ToUTF8(password)); err == nil {
+		return user, true
+	}
+	return user, false
+}
 
-		emitLoginAttempt(true, username)
-		next.ServeHTTP(w, r)
+const authCommandTimeout = 10 * time.Second
+
+// The commandAuthenticator runs an external command, which is given the
+// username and password on separate lines on stdin and accepts them by
+// exiting successfully. This allows authenticating against PAM, LDAP and
+// the like by way of a small script, without linking to them here. The
+// command may print, one per line, the role of the user, "admin" (the
+// default) or "readonly", for example based on group membership, and
+// "folder:<id>" for each folder the user is limited to.
+type commandAuthenticator struct {
+	command string
+}
+
+func (a commandAuthenticator) authenticate(username, password []byte) (login, bool) {
+	// We can't try both encodings here without running the command twice,
+	// so ISO-8859-1 is assumed when it isn't valid UTF-8.
+	if !utf8.Valid(username) {
+		username = iso88591ToUTF8(username)
+	}
+	if !utf8.Valid(password) {
+		password = iso88591ToUTF8(password)
+	}
+	user := login{username: string(username)}
+	if bytes.ContainsAny(username, "\r\n") || bytes.ContainsAny(password, "\r\n") {
+		return user, false
+	}
+
+	var input bytes.Buffer
+	input.Write(username)
+	input.WriteByte('\n')
+	input.Write(password)
+	input.WriteByte('\n')
+
+	var output bytes.Buffer
+	cmd := exec.Command(a.command)
+	cmd.Stdin = &input
+	cmd.Stdout = &output
+	// Don't hand our own credentials to the command
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, "STGUIAUTH=") && !strings.HasPrefix(env, "STGUIAPIKEY=") {
+			cmd.Env = append(cmd.Env, env)
+		}
+	}
+
+	if err := cmd.Start(); err != nil {
+		l.Warnln("Running GUI authentication command:", err)
+		return user, false
+	}
+	timer := time.AfterFunc(authCommandTimeout, func() {
+		cmd.Process.Kill()
 	})
+	err := cmd.Wait()
+	timer.Stop()
+	if err != nil {
+		httpl.Debugf("Authentication command refused %q: %v", user.username, err)
+		return user, false
+	}
+
+	for _, line := range strings.Split(output.String(), "\n") {
+		switch line = strings.TrimSpace(line); {
+		case line == "", line == "admin":
+		case line == "readonly":
+			user.readOnly = true
+		case strings.HasPrefix(line, "folder:"):
+			user.folders = append(user.folders, strings.TrimPrefix(line, "folder:"))
+		default:
+			l.Warnf("GUI authentication command returned unknown line %q for %q", line, user.username)
+			return user, false
+		}
+	}
+	return user, true
+}
+
+// The refusingAuthenticator is used when the auth mode is unknown, and with
+// OIDC, where there are no passwords to check.
+type refusingAuthenticator struct{}
+
+func (refusingAuthenticator) authenticate(username, password []byte) (login, bool) {
+	return login{username: string(username)}, false
+}
+
+// useSession returns true if the session exists and has neither reached
+// its maximum lifetime nor been idle for too long, and marks it as used.
+// Expired sessions are forgotten.
+func useSession(cfg config.GUIConfiguration, id string, now time.Time) bool {
+	sessionsMut.Lock()
+	defer sessionsMut.Unlock()
+
+	sess, ok := sessions[id]
+	if !ok {
+		return false
+	}
+	if sessionExpired(cfg, sess, now) {
+		delete(sessions, id)
+		return false
+	}
+	sess.lastUsed = now
+	return true
+}
+
+// sessionLogin returns the user logged in with the session.
+func sessionLogin(id string) (login, bool) {
+	sessionsMut.Lock()
+	defer sessionsMut.Unlock()
+
+	sess, ok := sessions[id]
+	if !ok {
+		return login{}, false
+	}
+	return sess.login, true
+}
+
+// newSession creates and returns a new session ID. If that brings us above
+// the configured limit, the least recently used sessions are logged out.
+func newSession(cfg config.GUIConfiguration, now time.Time, login login) string {
+	sessionsMut.Lock()
+	defer sessionsMut.Unlock()
+
+	for id, sess := range sessions {
+		if sessionExpired(cfg, sess, now) {
+			delete(sessions, id)
+		}
+	}
+
+	if cfg.MaxSessions > 0 {
+		for len(sessions) >= cfg.MaxSessions {
+			var oldestID string
+			var oldest *session
+			for id, sess := range sessions {
+				if oldest == nil || sess.lastUsed.Before(oldest.lastUsed) {
+					oldestID, oldest = id, sess
+				}
+			}
+			delete(sessions, oldestID)
+		}
+	}
+
+	id := rand.String(32)
+	sessions[id] = &session{
+		created:  now,
+		lastUsed: now,
+		login:    login,
+	}
+	return id
+}
+
+func sessionExpired(cfg config.GUIConfiguration, sess *session, now time.Time) bool {
+	if cfg.SessionLifetimeS > 0 && now.Sub(sess.created) > time.Duration(cfg.SessionLifetimeS)*time.Second {
+		return true
+	}
+	if cfg.SessionIdleTimeoutS > 0 && now.Sub(sess.lastUsed) > time.Duration(cfg.SessionIdleTimeoutS)*time.Second {
+		return true
+	}
+	return false
+}
+
+// clearSessions logs out all sessions.
+func clearSessions() {
+	sessionsMut.Lock()
+	sessions = make(map[string]*session)
+	sessionsMut.Unlock()
 }
 
 // Convert an ISO-8859-1 encoded byte string to UTF-8. Works by the
@@ -0,0 +1,73 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/subtle"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/rand"
+)
+
+// The API key is only kept hashed in the config, so Syncthing run from the
+// command line against a running instance, as with -upgrade, can't use it.
+// Instead the running instance makes up a CLI API key each time the GUI
+// starts and leaves it in the config directory, readable only by the user.
+// The key is accepted from the loopback interface only.
+
+// writeCLIAPIKey makes up a new CLI API key, writes it to the named file
+// and returns it.
+func writeCLIAPIKey(name string) (string, error) {
+	key := rand.String(32)
+	f, err := osutil.CreateAtomic(name)
+	if err != nil {
+		return "", err
+	}
+	// A failed write makes the close fail as well
+	f.Write([]byte(key + "\n"))
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// readCLIAPIKey returns the CLI API key in the named file, or the empty
+// string if there is none.
+func readCLIAPIKey(name string) string {
+	bs, err := ioutil.ReadFile(name)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(bs))
+}
+
+// cliAPIKeyMiddleware serves the requests from the loopback interface
+// carrying the CLI API key in the X-API-Key header with the CLI handler,
+// without login or CSRF token. Other requests are passed on to next.
+func cliAPIKeyMiddleware(key string, cliHandler, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := r.Header.Get("X-API-Key")
+		if given != "" && subtle.ConstantTimeCompare([]byte(given), []byte(key)) == 1 && remoteIsLoopback(r.RemoteAddr) {
+			cliHandler.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func remoteIsLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,146 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/rand"
+)
+
+// A folderCheck is the result of checking a prospective folder
+// configuration before it is added. Errors are things that will prevent
+// the folder from working, warnings are things the user should know about.
+type folderCheck struct {
+	Path         string           `json:"path"`
+	Exists       bool             `json:"exists"`
+	Creatable    bool             `json:"creatable"`
+	Writable     bool             `json:"writable"`
+	FreeBytes    int64            `json:"freeBytes"`
+	FreePct      float64          `json:"freePct"`
+	Capabilities *fs.Capabilities `json:"capabilities"`
+	Errors       []string         `json:"errors"`
+	Warnings     []string         `json:"warnings"`
+}
+
+func (c *folderCheck) errorf(format string, args ...interface{}) {
+	c.Errors = append(c.Errors, fmt.Sprintf(format, args...))
+}
+
+func (c *folderCheck) warnf(format string, args ...interface{}) {
+	c.Warnings = append(c.Warnings, fmt.Sprintf(format, args...))
+}
+
+// checkFolder verifies that the folder can be used as configured, given
+// the already existing folders. The folder path is not created, but
+// temporary files are created and removed in it (or in the closest
+// existing parent directory) to find out what the filesystem supports.
+func checkFolder(folder config.FolderConfiguration, existing map[string]config.FolderConfiguration) folderCheck {
+	c := folderCheck{
+		Errors:   []string{},
+		Warnings: []string{},
+	}
+
+	if folder.RawPath == "" {
+		c.errorf("The folder path cannot be blank.")
+		return c
+	}
+	c.Path = config.NewFolderConfiguration(folder.ID, folder.RawPath).Path()
+	cleanPath := filepath.Clean(c.Path)
+
+	for id, other := range existing {
+		if id == folder.ID {
+			continue
+		}
+		otherPath := filepath.Clean(other.Path())
+		switch {
+		case otherPath == cleanPath:
+			c.errorf("The path is already used by folder %s.", other.Description())
+		case strings.HasPrefix(cleanPath, otherPath+string(filepath.Separator)):
+			c.warnf("The path is inside folder %s.", other.Description())
+		case strings.HasPrefix(otherPath, cleanPath+string(filepath.Separator)):
+			c.warnf("The path contains folder %s.", other.Description())
+		}
+	}
+
+	// Find the directory to probe; the folder itself, or the closest
+	// parent that exists if the folder is yet to be created.
+	probeDir := cleanPath
+	if info, err := os.Stat(cleanPath); err == nil {
+		if !info.IsDir() {
+			c.errorf("The path exists but is not a directory.")
+			return c
+		}
+		c.Exists = true
+	} else {
+		for {
+			parent := filepath.Dir(probeDir)
+			if parent == probeDir {
+				c.errorf("The path cannot be created: %v", err)
+				return c
+			}
+			probeDir = parent
+			if info, err := os.Stat(probeDir); err == nil && info.IsDir() {
+				break
+			}
+		}
+	}
+
+	caps, err := fs.ProbeCapabilities(probeDir, ignore.TempName("probe-"+rand.String(8)))
+	switch {
+	case err == nil:
+		c.Capabilities = &caps
+		c.Writable = true
+		c.Creatable = !c.Exists
+	case !c.Exists:
+		c.errorf("The path cannot be created: %v", err)
+	case folder.Type != config.FolderTypeSendOnly:
+		c.errorf("The path is not writable: %v", err)
+	}
+
+	if free, err := osutil.DiskFreeBytes(probeDir); err == nil {
+		c.FreeBytes = free
+	}
+	if pct, err := osutil.DiskFreePercentage(probeDir); err == nil {
+		c.FreePct = pct
+		if folder.MinDiskFreePct > 0 && pct < folder.MinDiskFreePct {
+			c.warnf("Only %.1f%% of the disk is free, less than the required %.1f%%.", pct, folder.MinDiskFreePct)
+		}
+	}
+
+	if c.Capabilities != nil {
+		if !caps.Symlinks {
+			c.warnf("The filesystem does not support symlinks; they will not be synced.")
+		}
+		if !caps.CaseSensitive {
+			c.warnf("The filesystem is case insensitive; files whose names differ only in case will conflict.")
+		}
+	}
+
+	return c
+}
+
+func (s *apiService) postFolderCheck(w http.ResponseWriter, r *http.Request) {
+	var folder config.FolderConfiguration
+	err := json.NewDecoder(r.Body).Decode(&folder)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sendJSON(w, checkFolder(folder, s.cfg.Folders()))
+}
@@ -0,0 +1,181 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+const (
+	defaultLocale = "en"
+	langAssetDir  = "assets/lang"
+	langPrefix    = "lang-"
+	langSuffix    = ".json"
+)
+
+// localeFormat describes how numbers and dates are usually written in a
+// locale, so that all clients can render them the same way.
+type localeFormat struct {
+	DecimalSeparator   string `json:"decimalSeparator"`
+	ThousandsSeparator string `json:"thousandsSeparator"`
+	DateOrder          string `json:"dateOrder"` // "ymd", "dmy" or "mdy"
+}
+
+var (
+	// Languages that use a decimal point rather than a decimal comma.
+	decimalPointLangs = map[string]bool{"en": true, "ja": true, "ko": true, "zh": true}
+	// Languages with a decimal comma that group thousands by a period
+	// rather than by a (non breaking) space.
+	periodGroupingLangs = map[string]bool{"ca": true, "da": true, "de": true, "el": true, "es": true, "eu": true, "id": true, "it": true, "nl": true, "pt": true, "tr": true, "vi": true}
+	// Languages that write dates year first.
+	ymdLangs = map[string]bool{"hu": true, "ja": true, "ko": true, "lt": true, "sv": true, "zh": true}
+)
+
+// availableLanguages returns the languages we have translations for, from
+// both the compiled in assets and the asset override directory.
+func (s *staticsServer) availableLanguages() []string {
+	_, assetDir := s.currentTheme()
+
+	var files []string
+	prefix := config.DefaultTheme + "/" + langAssetDir + "/"
+	for file := range s.assets {
+		if strings.HasPrefix(file, prefix) {
+			files = append(files, file[len(prefix):])
+		}
+	}
+	if assetDir != "" {
+		matches, _ := filepath.Glob(filepath.Join(assetDir, config.DefaultTheme, filepath.FromSlash(langAssetDir), langPrefix+"*"+langSuffix))
+		for _, match := range matches {
+			files = append(files, filepath.Base(match))
+		}
+	}
+
+	var langs []string
+	seen := make(map[string]bool)
+	for _, file := range files {
+		if !strings.HasPrefix(file, langPrefix) || !strings.HasSuffix(file, langSuffix) {
+			continue
+		}
+		lang := file[len(langPrefix) : len(file)-len(langSuffix)]
+		if !seen[lang] {
+			seen[lang] = true
+			langs = append(langs, lang)
+		}
+	}
+
+	sort.Strings(langs)
+	return langs
+}
+
+// acceptedLanguages returns the languages from an Accept-Language header,
+// lower cased, in the order given.
+func acceptedLanguages(header string) []string {
+	var langs []string
+	for _, l := range strings.Split(header, ",") {
+		parts := strings.SplitN(l, ";", 2)
+		langs = append(langs, strings.ToLower(strings.TrimSpace(parts[0])))
+	}
+	return langs
+}
+
+// negotiateLocale returns the first available language that matches one of
+// the accepted ones. As in the GUI, "en" matches "en" or "en-US", while
+// "zh-tw" matches only "zh-TW" and not "zh-CN".
+func negotiateLocale(accepted, available []string) string {
+	for _, lang := range accepted {
+		if len(lang) < 2 {
+			continue
+		}
+		for _, possible := range available {
+			possibleLower := strings.ToLower(possible)
+			if strings.HasPrefix(possibleLower, lang) || strings.HasPrefix(lang, possibleLower) {
+				return possible
+			}
+		}
+	}
+	return defaultLocale
+}
+
+func formatForLocale(locale string) localeFormat {
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "-@"); i > 0 {
+		lang = lang[:i]
+	}
+
+	f := localeFormat{
+		DecimalSeparator:   ",",
+		ThousandsSeparator: " ",
+		DateOrder:          "dmy",
+	}
+	if decimalPointLangs[lang] {
+		f.DecimalSeparator, f.ThousandsSeparator = ".", ","
+	} else if periodGroupingLangs[lang] {
+		f.ThousandsSeparator = "."
+	}
+	switch {
+	case ymdLangs[lang]:
+		f.DateOrder = "ymd"
+	case locale == "en":
+		f.DateOrder = "mdy"
+	}
+	return f
+}
+
+func (s *apiService) getLocale(w http.ResponseWriter, r *http.Request) {
+	available := s.statics.availableLanguages()
+	override := s.cfg.GUI().Language
+
+	locale := override
+	if locale == "" {
+		locale = negotiateLocale(acceptedLanguages(r.Header.Get("Accept-Language")), available)
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"available": available,
+		"locale":    locale,
+		"override":  override,
+		"format":    formatForLocale(locale),
+	})
+}
+
+func (s *apiService) postLocale(w http.ResponseWriter, r *http.Request) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	lang := r.URL.Query().Get("lang")
+	if lang != "" {
+		found := false
+		for _, available := range s.statics.availableLanguages() {
+			if lang == available {
+				found = true
+				break
+			}
+		}
+		if !found {
+			http.Error(w, "unknown language", http.StatusBadRequest)
+			return
+		}
+	}
+
+	to := s.cfg.RawCopy()
+	to.GUI.Language = lang
+	if err := s.cfg.Replace(to); err != nil {
+		l.Warnln("Replacing config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.cfg.Save(); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// The /rest/manage endpoints pass management commands on to the device
+// given by the device parameter, over our connection to it. The device
+// carries them out if we're its controller, and its errors are returned as
+// they are.
+
+func (s *apiService) getManageConfig(w http.ResponseWriter, r *http.Request) {
+	device, err := protocol.DeviceIDFromString(r.URL.Query().Get("device"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	bs, err := s.model.ManageDevice(device, protocol.ManagementRequest{
+		Command: protocol.ManagementGetConfig,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, json.RawMessage(bs))
+}
+
+func (s *apiService) postManageConfig(w http.ResponseWriter, r *http.Request) {
+	device, err := protocol.DeviceIDFromString(r.URL.Query().Get("device"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	bs, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, err = s.model.ManageDevice(device, protocol.ManagementRequest{
+		Command: protocol.ManagementSetConfig,
+		Config:  bs,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// makeManagePauseHandler pauses or resumes the folder, or the target
+// device, on the managed device, with the owner and reason as for pausing
+// our own.
+func (s *apiService) makeManagePauseHandler(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qs := r.URL.Query()
+		device, err := protocol.DeviceIDFromString(qs.Get("device"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		req := protocol.ManagementRequest{
+			Command: protocol.ManagementResume,
+			Folder:  qs.Get("folder"),
+			Owner:   qs.Get("owner"),
+			Reason:  qs.Get("reason"),
+			Force:   qs.Get("force") != "",
+		}
+		if paused {
+			req.Command = protocol.ManagementPause
+		}
+		if req.Folder == "" {
+			req.Device, err = protocol.DeviceIDFromString(qs.Get("target"))
+			if err != nil {
+				http.Error(w, "folder or target: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if _, err := s.model.ManageDevice(device, req); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func (s *apiService) postManageRestart(w http.ResponseWriter, r *http.Request) {
+	device, err := protocol.DeviceIDFromString(r.URL.Query().Get("device"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, err = s.model.ManageDevice(device, protocol.ManagementRequest{
+		Command: protocol.ManagementRestart,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,442 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto"
+	"crypto/rsa"
+	_ "crypto/sha256" // for RS256
+	_ "crypto/sha512" // for RS384 and RS512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/rand"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// Logging in with OpenID Connect
+//
+// In the oidc auth mode users log in at an OpenID Connect provider rather
+// than here. Someone without a session who opens the GUI is sent to the
+// provider, which sends them back to /oidc/callback with a code once they
+// have logged in. The code is exchanged for an ID token, signed by the
+// provider, that says who they are and which groups they're in, and the
+// groups decide their role. Requests to the REST API without a session or
+// an API key are refused as usual, rather than sent to the provider.
+
+const (
+	oidcCallbackPath   = "/oidc/callback"
+	oidcLoginTimeout   = 10 * time.Minute // for logging in at the provider
+	oidcMaxLogins      = 1000             // in progress at once
+	oidcClockSkew      = time.Minute      // allowed between us and the provider
+	oidcRequestTimeout = 30 * time.Second
+)
+
+// The signature algorithms accepted for ID tokens.
+var oidcHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+type oidcProvider struct {
+	cfg          config.OIDCConfiguration
+	clientSecret string
+	basePath     string
+	stateCookie  string
+	client       *http.Client
+
+	mut       sync.Mutex
+	discovery *oidcDiscovery            // fetched when first needed
+	keys      map[string]*rsa.PublicKey // key ID -> key, fetched again when a new one is used
+	logins    map[string]oidcLogin      // state -> login in progress
+}
+
+// An oidcDiscovery is the provider's discovery document, as far as we need
+// it.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// An oidcLogin is a user logging in at the provider.
+type oidcLogin struct {
+	nonce       string
+	redirectURL string
+	expires     time.Time
+}
+
+func newOIDCProvider(cfg config.GUIConfiguration, stateCookie string) *oidcProvider {
+	return &oidcProvider{
+		cfg:          cfg.OIDC,
+		clientSecret: resolveSecret(cfg.OIDC.ClientSecret),
+		basePath:     cfg.BasePath(),
+		stateCookie:  stateCookie,
+		client:       &http.Client{Timeout: oidcRequestTimeout},
+		mut:          sync.NewMutex(),
+		keys:         make(map[string]*rsa.PublicKey),
+		logins:       make(map[string]oidcLogin),
+	}
+}
+
+// redirect sends the browser to the provider to log in. Requests other
+// than for the pages of the GUI are refused instead.
+func (p *oidcProvider) redirect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" || strings.HasPrefix(r.URL.Path, "/rest/") {
+		http.Error(w, "Not Authorized", http.StatusUnauthorized)
+		return
+	}
+	disc, err := p.getDiscovery()
+	if err != nil {
+		l.Warnln("OpenID Connect provider:", err)
+		http.Error(w, "OpenID Connect provider unavailable", http.StatusBadGateway)
+		return
+	}
+	authURL, err := url.Parse(disc.AuthorizationEndpoint)
+	if err != nil {
+		l.Warnln("OpenID Connect provider: authorization endpoint:", err)
+		http.Error(w, "OpenID Connect provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	state, nonce := rand.String(32), rand.String(32)
+	redirectURL := p.redirectURL(r)
+	now := time.Now()
+	p.mut.Lock()
+	for s, login := range p.logins {
+		if now.After(login.expires) {
+			delete(p.logins, s)
+		}
+	}
+	if len(p.logins) >= oidcMaxLogins {
+		p.mut.Unlock()
+		http.Error(w, "Too many logins in progress", http.StatusServiceUnavailable)
+		return
+	}
+	p.logins[state] = oidcLogin{
+		nonce:       nonce,
+		redirectURL: redirectURL,
+		expires:     now.Add(oidcLoginTimeout),
+	}
+	p.mut.Unlock()
+
+	// The state is also kept in a cookie, so that only the browser that
+	// started logging in can finish it.
+	http.SetCookie(w, &http.Cookie{
+		Name:     p.stateCookie,
+		Value:    state,
+		Path:     p.basePath + oidcCallbackPath,
+		MaxAge:   int(oidcLoginTimeout / time.Second),
+		HttpOnly: true,
+	})
+
+	qs := authURL.Query()
+	qs.Set("response_type", "code")
+	qs.Set("client_id", p.cfg.ClientID)
+	qs.Set("redirect_uri", redirectURL)
+	qs.Set("scope", strings.Join(append([]string{"openid"}, p.cfg.Scopes...), " "))
+	qs.Set("state", state)
+	qs.Set("nonce", nonce)
+	authURL.RawQuery = qs.Encode()
+	http.Redirect(w, r, authURL.String(), http.StatusFound)
+}
+
+// redirectURL returns where the provider should send the browser back to.
+func (p *oidcProvider) redirectURL(r *http.Request) string {
+	if p.cfg.RedirectURL != "" {
+		return p.cfg.RedirectURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + p.basePath + oidcCallbackPath
+}
+
+// callback finishes logging in a user sent back by the provider, returning
+// who they are and their role. The username is returned, if known, also
+// when the login is refused.
+func (p *oidcProvider) callback(r *http.Request, now time.Time) (login, error) {
+	qs := r.URL.Query()
+	state := qs.Get("state")
+	if cookie, err := r.Cookie(p.stateCookie); err != nil || state == "" || cookie.Value != state {
+		return login{}, errors.New("not logging in from this browser")
+	}
+	p.mut.Lock()
+	pending, ok := p.logins[state]
+	delete(p.logins, state)
+	p.mut.Unlock()
+	if !ok || now.After(pending.expires) {
+		return login{}, errors.New("took too long to log in")
+	}
+	if msg := qs.Get("error"); msg != "" {
+		return login{}, fmt.Errorf("refused by the provider: %s %s", msg, qs.Get("error_description"))
+	}
+
+	token, err := p.exchange(qs.Get("code"), pending.redirectURL)
+	if err != nil {
+		return login{}, err
+	}
+	claims, err := p.verify(token, pending.nonce, now)
+	if err != nil {
+		return login{}, err
+	}
+	return p.login(claims)
+}
+
+// exchange returns the ID token the code is exchanged for.
+func (p *oidcProvider) exchange(code, redirectURL string) (string, error) {
+	disc, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURL},
+	}
+	req, err := http.NewRequest("POST", disc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.clientSecret))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil && resp.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request: %s %s", resp.Status, res.Error)
+	}
+	if res.IDToken == "" {
+		return "", errors.New("no ID token in the token response")
+	}
+	return res.IDToken, nil
+}
+
+// verify checks that the ID token is signed by the provider, for us, for
+// this login and current, and returns its claims.
+func (p *oidcProvider) verify(token, nonce string, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("ID token header: %v", err)
+	}
+	hash, ok := oidcHashes[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported ID token algorithm %q", header.Alg)
+	}
+	key, err := p.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("ID token signature: %v", err)
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), sig); err != nil {
+		return nil, errors.New("invalid ID token signature")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("ID token claims: %v", err)
+	}
+	disc, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); iss != disc.Issuer {
+		return nil, fmt.Errorf("ID token from another issuer, %q", iss)
+	}
+	if !stringIn(p.cfg.ClientID, claimStrings(claims["aud"])) {
+		return nil, errors.New("ID token for another client")
+	}
+	if exp, _ := claims["exp"].(float64); now.After(time.Unix(int64(exp), 0).Add(oidcClockSkew)) {
+		return nil, errors.New("ID token expired")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, errors.New("ID token for another login")
+	}
+	return claims, nil
+}
+
+// login returns the user the claims are about, with the role their groups
+// give them. Users in neither the admin nor the read only groups are
+// refused.
+func (p *oidcProvider) login(claims map[string]interface{}) (login, error) {
+	var user login
+	for _, claim := range []string{p.cfg.UsernameClaim, "email", "sub"} {
+		if name, ok := claims[claim].(string); ok && name != "" {
+			user.username = name
+			break
+		}
+	}
+	groups := claimStrings(claims[p.cfg.GroupsClaim])
+	for _, group := range groups {
+		if stringIn(group, p.cfg.AdminGroups) {
+			return user, nil
+		}
+	}
+	for _, group := range groups {
+		if stringIn(group, p.cfg.ReadOnlyGroups) {
+			user.readOnly = true
+			return user, nil
+		}
+	}
+	return user, errors.New("not in an admin or read only group")
+}
+
+// getDiscovery returns the provider's discovery document, fetching it the
+// first time.
+func (p *oidcProvider) getDiscovery() (*oidcDiscovery, error) {
+	p.mut.Lock()
+	disc := p.discovery
+	p.mut.Unlock()
+	if disc != nil {
+		return disc, nil
+	}
+
+	issuer := strings.TrimSuffix(p.cfg.Issuer, "/")
+	disc = new(oidcDiscovery)
+	if err := p.getJSON(issuer+"/.well-known/openid-configuration", disc); err != nil {
+		return nil, fmt.Errorf("discovery: %v", err)
+	}
+	if strings.TrimSuffix(disc.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery: issuer %q differs from the configured one", disc.Issuer)
+	}
+	if disc.AuthorizationEndpoint == "" || disc.TokenEndpoint == "" || disc.JWKSURI == "" {
+		return nil, errors.New("discovery: missing endpoints")
+	}
+
+	p.mut.Lock()
+	p.discovery = disc
+	p.mut.Unlock()
+	return disc, nil
+}
+
+// key returns the provider's signing key with the ID, fetching the keys
+// again if it's one we don't have, as the provider may have rotated them.
+func (p *oidcProvider) key(id string) (*rsa.PublicKey, error) {
+	p.mut.Lock()
+	key, ok := p.keys[id]
+	p.mut.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	disc, err := p.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(disc.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("signing keys: %v", err)
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	p.mut.Lock()
+	p.keys = keys
+	p.mut.Unlock()
+	if key, ok = keys[id]; !ok {
+		return nil, fmt.Errorf("unknown signing key %q", id)
+	}
+	return key, nil
+}
+
+func (p *oidcProvider) getJSON(url string, into interface{}) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
+
+// decodeJWTPart decodes the base64url encoded JSON of a part of a JWT.
+func decodeJWTPart(part string, into interface{}) error {
+	bs, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, into)
+}
+
+// claimStrings returns the claim, which is a string or a list of them, as
+// a list.
+func claimStrings(claim interface{}) []string {
+	switch claim := claim.(type) {
+	case string:
+		return []string{claim}
+	case []interface{}:
+		var strs []string
+		for _, v := range claim {
+			if s, ok := v.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// A fakeOIDCProvider issues ID tokens for whoever the test says has logged
+// in.
+type fakeOIDCProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mut    sync.Mutex
+	nonce  string
+	claims map[string]interface{}
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeOIDCProvider{key: key, mut: sync.NewMutex()}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		sendJSON(w, map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/auth?prompt=login",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		sendJSON(w, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key1",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, _ := r.BasicAuth(); id != "syncthing" || secret != "secret" || r.FormValue("code") != "code" {
+			http.Error(w, `{"error": "invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		p.mut.Lock()
+		claims := map[string]interface{}{
+			"iss":   p.URL,
+			"aud":   "syncthing",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": p.nonce,
+		}
+		for k, v := range p.claims {
+			claims[k] = v
+		}
+		p.mut.Unlock()
+		sendJSON(w, map[string]string{"id_token": p.sign(t, claims)})
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+func (p *fakeOIDCProvider) sign(t *testing.T, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "key1"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := newFakeOIDCProvider(t)
+	defer idp.Close()
+
+	cfg := new(mockedConfig)
+	cfg.gui.AuthMode = config.AuthModeOIDC
+	cfg.gui.OIDC = config.OIDCConfiguration{
+		Issuer:         idp.URL,
+		ClientID:       "syncthing",
+		ClientSecret:   "secret",
+		UsernameClaim:  "preferred_username",
+		GroupsClaim:    "groups",
+		AdminGroups:    []string{"admins"},
+		ReadOnlyGroups: []string{"viewers"},
+	}
+	baseURL, err := startHTTP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// login goes through the login at the provider as the user, returning
+	// the response to coming back from the provider
+	login := func(claims map[string]interface{}) (*http.Client, *http.Response) {
+		jar, _ := cookiejar.New(nil)
+		client := &http.Client{
+			Jar: jar,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		resp, err := client.Get(baseURL + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		auth, err := url.Parse(resp.Header.Get("Location"))
+		if resp.StatusCode != http.StatusFound || err != nil || !strings.HasPrefix(auth.String(), idp.URL+"/auth?") {
+			t.Fatalf("Expected a redirect to the provider, got %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+		}
+		qs := auth.Query()
+		if qs.Get("client_id") != "syncthing" || qs.Get("scope") != "openid" || qs.Get("prompt") != "login" {
+			t.Errorf("Unexpected authorization request %v", qs)
+		}
+
+		idp.mut.Lock()
+		idp.nonce = qs.Get("nonce")
+		idp.claims = claims
+		idp.mut.Unlock()
+		resp, err = client.Get(qs.Get("redirect_uri") + "?code=code&state=" + qs.Get("state"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return client, resp
+	}
+
+	client, resp := login(map[string]interface{}{"preferred_username": "jb", "groups": []string{"users", "admins"}})
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/" {
+		t.Fatalf("Expected a redirect to the GUI after logging in, got %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	resp, err = client.Get(baseURL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the GUI once logged in, got %d", resp.StatusCode)
+	}
+
+	// Users in neither group aren't let in
+
+	_, resp = login(map[string]interface{}{"preferred_username": "mallory", "groups": "users"})
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a user in no group to be refused, got %d", resp.StatusCode)
+	}
+
+	// Nor is coming back to finish someone else's login, or calling the
+	// REST API without logging in
+
+	resp, err = http.Get(baseURL + oidcCallbackPath + "?code=code&state=forged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a forged state to be refused, got %d", resp.StatusCode)
+	}
+	resp, err = http.Get(baseURL + "/rest/system/version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected the REST API to be refused, got %d", resp.StatusCode)
+	}
+}
+
+func TestOIDCVerify(t *testing.T) {
+	idp := newFakeOIDCProvider(t)
+	defer idp.Close()
+
+	cfg := config.GUIConfiguration{
+		AuthMode: config.AuthModeOIDC,
+		OIDC: config.OIDCConfiguration{
+			Issuer:         idp.URL + "/",
+			ClientID:       "syncthing",
+			GroupsClaim:    "groups",
+			ReadOnlyGroups: []string{"viewers"},
+		},
+	}
+	p := newOIDCProvider(cfg, "state")
+	now := time.Now()
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":    idp.URL,
+			"aud":    []string{"other", "syncthing"},
+			"exp":    now.Add(time.Minute).Unix(),
+			"nonce":  "nonce",
+			"email":  "jb@example.com",
+			"groups": []string{"viewers"},
+		}
+	}
+
+	claims, err := p.verify(idp.sign(t, valid()), "nonce", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, err := p.login(claims); err != nil || user.username != "jb@example.com" || !user.readOnly {
+		t.Errorf("Unexpected login %+v, %v", user, err)
+	}
+
+	for name, change := range map[string]func(map[string]interface{}){
+		"issuer":   func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" },
+		"audience": func(c map[string]interface{}) { c["aud"] = "other" },
+		"expired":  func(c map[string]interface{}) { c["exp"] = now.Add(-time.Hour).Unix() },
+		"nonce":    func(c map[string]interface{}) { c["nonce"] = "replayed" },
+	} {
+		claims := valid()
+		change(claims)
+		if _, err := p.verify(idp.sign(t, claims), "nonce", now); err == nil {
+			t.Errorf("Expected a token with the wrong %s to be refused", name)
+		}
+	}
+
+	token := idp.sign(t, valid())
+	tampered := token[:len(token)-4] + "AAAA"
+	if _, err := p.verify(tampered, "nonce", now); err == nil {
+		t.Error("Expected a tampered token to be refused")
+	}
+}
+
+func TestOIDCPendingLogins(t *testing.T) {
+	idp := newFakeOIDCProvider(t)
+	defer idp.Close()
+
+	cfg := config.GUIConfiguration{
+		AuthMode: config.AuthModeOIDC,
+		OIDC:     config.OIDCConfiguration{Issuer: idp.URL, ClientID: "syncthing"},
+	}
+	p := newOIDCProvider(cfg, "state")
+	redirect := func() int {
+		w := httptest.NewRecorder()
+		p.redirect(w, httptest.NewRequest("GET", "/", nil))
+		return w.Code
+	}
+
+	// Logins that took too long are forgotten, and only so many may be in
+	// progress at once
+
+	expired := time.Now().Add(-time.Second)
+	for i := 0; i < oidcMaxLogins; i++ {
+		p.logins[fmt.Sprint(i)] = oidcLogin{expires: expired}
+	}
+	if code := redirect(); code != http.StatusFound {
+		t.Fatalf("Expected a redirect to the provider, got %d", code)
+	}
+	if len(p.logins) != 1 {
+		t.Errorf("Expected expired logins to be forgotten, %d left", len(p.logins))
+	}
+	for len(p.logins) < oidcMaxLogins {
+		redirect()
+	}
+	if code := redirect(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected logins beyond the limit to be refused, got %d", code)
+	}
+
+	// Without groups to give them a role, nobody is let in
+
+	if _, err := p.login(map[string]interface{}{"sub": "jb"}); err == nil {
+		t.Error("Expected a user without a role to be refused")
+	}
+}
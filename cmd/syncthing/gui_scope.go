@@ -0,0 +1,361 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/stats"
+)
+
+// Endpoints that a user scoped to some folders may use on those folders, as
+// given by the folder parameter.
+var (
+	scopedFolderGets = map[string]bool{
+		"/rest/db/browse":     true,
+		"/rest/db/collisions": true,
+		"/rest/db/completion": true,
+		"/rest/db/file":       true,
+		"/rest/db/ignores":    true,
+		"/rest/db/manifest":   true,
+		"/rest/db/need":       true,
+		"/rest/db/selection":  true,
+		"/rest/db/status":     true,
+	}
+	scopedFolderPosts = map[string]bool{
+		"/rest/db/ignores":   true,
+		"/rest/db/override":  true,
+		"/rest/db/pause":     true,
+		"/rest/db/prio":      true,
+		"/rest/db/resume":    true,
+		"/rest/db/scan":      true,
+		"/rest/db/selection": true,
+		"/rest/db/verify":    true,
+	}
+)
+
+// Endpoints that a user scoped to some folders may use on the devices
+// sharing them, as given by the device parameter.
+var scopedDevicePosts = map[string]bool{
+	"/rest/system/pause":  true,
+	"/rest/system/resume": true,
+}
+
+// Endpoints that don't reveal anything about folders or devices, and so
+// may be used by anyone.
+var unscopedGets = map[string]bool{
+	"/rest/svc/deviceid":         true,
+	"/rest/svc/lang":             true,
+	"/rest/svc/locale":           true,
+	"/rest/svc/random/string":    true,
+	"/rest/svc/themes":           true,
+	"/rest/system/capabilities":  true,
+	"/rest/system/config/insync": true,
+	"/rest/system/features":      true,
+	"/rest/system/ping":          true,
+	"/rest/system/status":        true,
+	"/rest/system/version":       true,
+}
+
+// scopeMiddleware restricts the REST API to the given folders, and the
+// devices sharing them. Listings of folders and devices are filtered, and
+// requests about anything else are refused. So is everything that isn't
+// about a single folder or device, such as viewing events, as it can't be
+// filtered sensibly, except for changing the config, which is limited to
+// the settings of the folders and devices in scope. A nil list of folders
+// means no restrictions.
+func (s *apiService) scopeMiddleware(folders []string, next http.Handler) http.Handler {
+	if folders == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics" {
+			visibleFolders, visibleDevices := scopeVisible(s.cfg.RawCopy(), s.id, folders)
+			s.serveMetrics(w, visibleFolders, visibleDevices)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, "/rest/") {
+			// The GUI itself
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cfg := s.cfg.RawCopy()
+		visibleFolders, visibleDevices := scopeVisible(cfg, s.id, folders)
+
+		qs := r.URL.Query()
+		folderOK := visibleFolders[qs.Get("folder")]
+		deviceOK := false
+		if device := qs.Get("device"); device != "" {
+			id, err := protocol.DeviceIDFromString(device)
+			deviceOK = err == nil && visibleDevices[id] && id != s.id
+			folderOK = folderOK && err == nil && visibleDevices[id]
+		}
+
+		switch {
+		case r.Method == "GET" && scopedFolderGets[r.URL.Path],
+			r.Method == "POST" && scopedFolderPosts[r.URL.Path]:
+			if !folderOK {
+				http.Error(w, "Forbidden: folder not in scope", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+
+		case r.Method == "POST" && scopedDevicePosts[r.URL.Path]:
+			if !deviceOK {
+				http.Error(w, "Forbidden: device not in scope", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+
+		case r.Method == "GET" && unscopedGets[r.URL.Path]:
+			next.ServeHTTP(w, r)
+
+		case r.Method == "GET" && r.URL.Path == "/rest/system/config":
+			sendJSON(w, scopedConfig(cfg, visibleFolders, visibleDevices))
+
+		case r.Method == "POST" && r.URL.Path == "/rest/system/config":
+			s.postScopedConfig(w, r, visibleFolders, visibleDevices)
+
+		case r.Method == "GET" && r.URL.Path == "/rest/system/connections":
+			sendJSON(w, scopedConnectionStats(s.model.ConnectionStats(), visibleDevices))
+
+		case r.Method == "GET" && r.URL.Path == "/rest/stats/device":
+			res := make(map[string]stats.DeviceStatistics)
+			for device, stat := range s.model.DeviceStatistics() {
+				if id, err := protocol.DeviceIDFromString(device); err == nil && visibleDevices[id] {
+					res[device] = stat
+				}
+			}
+			sendJSON(w, res)
+
+		case r.Method == "GET" && r.URL.Path == "/rest/stats/folder":
+			res := make(map[string]stats.FolderStatistics)
+			for folder, stat := range s.model.FolderStatistics() {
+				if visibleFolders[folder] {
+					res[folder] = stat
+				}
+			}
+			sendJSON(w, res)
+
+		default:
+			http.Error(w, "Forbidden: not available to users limited to some folders", http.StatusForbidden)
+		}
+	})
+}
+
+// scopeVisible returns the folders of the list that exist, and the devices
+// sharing them, including ourselves.
+func scopeVisible(cfg config.Configuration, myID protocol.DeviceID, folders []string) (map[string]bool, map[protocol.DeviceID]bool) {
+	visibleFolders := make(map[string]bool)
+	visibleDevices := map[protocol.DeviceID]bool{
+		myID: true,
+	}
+	for _, folder := range folders {
+		for _, fcfg := range cfg.Folders {
+			if fcfg.ID != folder {
+				continue
+			}
+			visibleFolders[folder] = true
+			for _, device := range fcfg.Devices {
+				visibleDevices[device.DeviceID] = true
+			}
+		}
+	}
+	return visibleFolders, visibleDevices
+}
+
+// scopedConfig returns the config with just the visible folders and
+// devices. The GUI and alert settings, including the credentials, are left
+// out.
+func scopedConfig(cfg config.Configuration, visibleFolders map[string]bool, visibleDevices map[protocol.DeviceID]bool) config.Configuration {
+	folders := []config.FolderConfiguration{}
+	for _, fcfg := range cfg.Folders {
+		if visibleFolders[fcfg.ID] {
+			folders = append(folders, fcfg)
+		}
+	}
+	devices := []config.DeviceConfiguration{}
+	for _, dcfg := range cfg.Devices {
+		if visibleDevices[dcfg.DeviceID] {
+			devices = append(devices, dcfg)
+		}
+	}
+
+	cfg.Folders = folders
+	cfg.Devices = devices
+	cfg.IgnoredDevices = nil
+	cfg.GUI = config.GUIConfiguration{}
+	cfg.Alerts = config.AlertConfiguration{}
+	return cfg
+}
+
+// postScopedConfig applies the changes to the config that a user scoped to
+// some folders made to the folders and devices in scope.
+func (s *apiService) postScopedConfig(w http.ResponseWriter, r *http.Request, visibleFolders map[string]bool, visibleDevices map[protocol.DeviceID]bool) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	posted, err := config.ReadJSON(r.Body, myID)
+	r.Body.Close()
+	if err != nil {
+		l.Warnln("Decoding posted config:", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	to, err := mergeScopedConfig(s.cfg.RawCopy(), posted, s.id, visibleFolders, visibleDevices)
+	if err != nil {
+		http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if err := s.cfg.Replace(to); err != nil {
+		l.Warnln("Replacing config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.cfg.Save(); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// mergeScopedConfig returns the config with the settings of the folders and
+// devices in scope taken from the posted one, which is as returned by
+// scopedConfig with changes. Only the settings that don't reach beyond the
+// folders and devices in scope are taken, as listed by mergeScopedFolder
+// and mergeScopedDevice; everything else, including our own device, is left
+// as it was. Folders and devices may be neither added nor removed, and
+// folders not shared with devices out of scope.
+func mergeScopedConfig(cfg, posted config.Configuration, myID protocol.DeviceID, visibleFolders map[string]bool, visibleDevices map[protocol.DeviceID]bool) (config.Configuration, error) {
+	postedFolders := make(map[string]config.FolderConfiguration)
+	for _, fcfg := range posted.Folders {
+		if !visibleFolders[fcfg.ID] {
+			return cfg, fmt.Errorf("folder %q not in scope", fcfg.ID)
+		}
+		for _, device := range fcfg.Devices {
+			if !visibleDevices[device.DeviceID] {
+				return cfg, fmt.Errorf("device %s not in scope", device.DeviceID)
+			}
+		}
+		postedFolders[fcfg.ID] = fcfg
+	}
+	postedDevices := make(map[protocol.DeviceID]config.DeviceConfiguration)
+	for _, dcfg := range posted.Devices {
+		if !visibleDevices[dcfg.DeviceID] {
+			return cfg, fmt.Errorf("device %s not in scope", dcfg.DeviceID)
+		}
+		postedDevices[dcfg.DeviceID] = dcfg
+	}
+
+	folders := make([]config.FolderConfiguration, len(cfg.Folders))
+	for i, fcfg := range cfg.Folders {
+		folders[i] = fcfg
+		if !visibleFolders[fcfg.ID] {
+			continue
+		}
+		postedFcfg, ok := postedFolders[fcfg.ID]
+		if !ok {
+			return cfg, fmt.Errorf("folder %q may not be removed", fcfg.ID)
+		}
+		folders[i] = mergeScopedFolder(fcfg, postedFcfg)
+	}
+	devices := make([]config.DeviceConfiguration, len(cfg.Devices))
+	for i, dcfg := range cfg.Devices {
+		devices[i] = dcfg
+		if !visibleDevices[dcfg.DeviceID] || dcfg.DeviceID == myID {
+			continue
+		}
+		postedDcfg, ok := postedDevices[dcfg.DeviceID]
+		if !ok {
+			return cfg, fmt.Errorf("device %s may not be removed", dcfg.DeviceID)
+		}
+		devices[i] = mergeScopedDevice(dcfg, postedDcfg)
+	}
+
+	cfg.Folders = folders
+	cfg.Devices = devices
+	return cfg, nil
+}
+
+// mergeScopedFolder returns the folder with the settings a user limited to
+// it may change taken from the posted one. The rest, such as where the
+// folder is, what it runs, its groups and links, and where it takes its
+// settings from, reach beyond it. So do the webhooks of the devices it's
+// shared with; those shared with anew have none.
+func mergeScopedFolder(fcfg, posted config.FolderConfiguration) config.FolderConfiguration {
+	existing := make(map[protocol.DeviceID]config.FolderDeviceConfiguration)
+	for _, device := range fcfg.Devices {
+		existing[device.DeviceID] = device
+	}
+	devices := make([]config.FolderDeviceConfiguration, 0, len(posted.Devices))
+	for _, device := range posted.Devices {
+		if cur, ok := existing[device.DeviceID]; ok {
+			devices = append(devices, cur)
+		} else {
+			devices = append(devices, config.FolderDeviceConfiguration{DeviceID: device.DeviceID})
+		}
+	}
+
+	fcfg.Label = posted.Label
+	fcfg.Devices = devices
+	fcfg.RescanIntervalS = posted.RescanIntervalS
+	fcfg.IgnorePerms = posted.IgnorePerms
+	fcfg.AutoNormalize = posted.AutoNormalize
+	fcfg.MinDiskFreePct = posted.MinDiskFreePct
+	fcfg.Order = posted.Order
+	fcfg.IgnoreDelete = posted.IgnoreDelete
+	fcfg.MaxConflicts = posted.MaxConflicts
+	fcfg.ConflictPolicy = posted.ConflictPolicy
+	fcfg.ConflictNaming = posted.ConflictNaming
+	fcfg.PriorityPatterns = posted.PriorityPatterns
+	fcfg.MaxSendKbps = posted.MaxSendKbps
+	fcfg.MaxRecvKbps = posted.MaxRecvKbps
+	return fcfg
+}
+
+// mergeScopedDevice returns the device with the settings a user limited to
+// folders it shares may change taken from the posted one. Whether it
+// introduces devices, which folders it may have, its addresses and whether
+// it controls us are left as they were.
+func mergeScopedDevice(dcfg, posted config.DeviceConfiguration) config.DeviceConfiguration {
+	dcfg.Name = posted.Name
+	dcfg.Compression = posted.Compression
+	dcfg.SyncWindows = posted.SyncWindows
+	dcfg.MaxSendKbps = posted.MaxSendKbps
+	dcfg.MaxRecvKbps = posted.MaxRecvKbps
+	dcfg.ZstdLevel = posted.ZstdLevel
+	return dcfg
+}
+
+// scopedConnectionStats returns the connection statistics for just the
+// visible devices.
+func scopedConnectionStats(res map[string]interface{}, visibleDevices map[protocol.DeviceID]bool) map[string]interface{} {
+	conns, _ := res["connections"].(map[string]model.ConnectionInfo)
+	scoped := make(map[string]model.ConnectionInfo)
+	for device, conn := range conns {
+		if id, err := protocol.DeviceIDFromString(device); err == nil && visibleDevices[id] {
+			scoped[device] = conn
+		}
+	}
+
+	filtered := make(map[string]interface{}, len(res))
+	for k, v := range res {
+		filtered[k] = v
+	}
+	filtered["connections"] = scoped
+	return filtered
+}
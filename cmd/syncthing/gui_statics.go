@@ -15,6 +15,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/syncthing/syncthing/lib/auto"
@@ -23,42 +24,49 @@ import (
 )
 
 type staticsServer struct {
-	assetDir        string
-	assets          map[string][]byte
-	availableThemes []string
+	assets map[string][]byte
 
-	mut   sync.RWMutex
-	theme string
+	mut      sync.RWMutex
+	theme    string
+	assetDir string // files here override the compiled in assets
 }
 
 func newStaticsServer(theme, assetDir string) *staticsServer {
-	s := &staticsServer{
+	return &staticsServer{
 		assetDir: assetDir,
 		assets:   auto.Assets(),
 		mut:      sync.NewRWMutex(),
 		theme:    theme,
 	}
+}
+
+// availableThemes returns the compiled in themes, plus any extra themes
+// found in the asset override directory.
+func (s *staticsServer) availableThemes() []string {
+	s.mut.RLock()
+	assetDir := s.assetDir
+	s.mut.RUnlock()
 
+	var themes []string
 	seen := make(map[string]struct{})
-	// Load themes from compiled in assets.
-	for file := range auto.Assets() {
+	for file := range s.assets {
 		theme := strings.Split(file, "/")[0]
 		if _, ok := seen[theme]; !ok {
 			seen[theme] = struct{}{}
-			s.availableThemes = append(s.availableThemes, theme)
+			themes = append(themes, theme)
 		}
 	}
 	if assetDir != "" {
-		// Load any extra themes from the asset override dir.
 		for _, dir := range dirNames(assetDir) {
 			if _, ok := seen[dir]; !ok {
 				seen[dir] = struct{}{}
-				s.availableThemes = append(s.availableThemes, dir)
+				themes = append(themes, dir)
 			}
 		}
 	}
 
-	return s
+	sort.Strings(themes)
+	return themes
 }
 
 func (s *staticsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -83,11 +91,12 @@ func (s *staticsServer) serveAsset(w http.ResponseWriter, r *http.Request) {
 
 	s.mut.RLock()
 	theme := s.theme
+	assetDir := s.assetDir
 	s.mut.RUnlock()
 
 	// Check for an override for the current theme.
-	if s.assetDir != "" {
-		p := filepath.Join(s.assetDir, theme, filepath.FromSlash(file))
+	if assetDir != "" {
+		p := filepath.Join(assetDir, theme, filepath.FromSlash(file))
 		if _, err := os.Stat(p); err == nil {
 			http.ServeFile(w, r, p)
 			return
@@ -98,8 +107,8 @@ func (s *staticsServer) serveAsset(w http.ResponseWriter, r *http.Request) {
 	bs, ok := s.assets[theme+"/"+file]
 	if !ok {
 		// Check for an overridden default asset.
-		if s.assetDir != "" {
-			p := filepath.Join(s.assetDir, config.DefaultTheme, filepath.FromSlash(file))
+		if assetDir != "" {
+			p := filepath.Join(assetDir, config.DefaultTheme, filepath.FromSlash(file))
 			if _, err := os.Stat(p); err == nil {
 				http.ServeFile(w, r, p)
 				return
@@ -134,7 +143,7 @@ func (s *staticsServer) serveAsset(w http.ResponseWriter, r *http.Request) {
 
 func (s *staticsServer) serveThemes(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, map[string][]string{
-		"themes": s.availableThemes,
+		"themes": s.availableThemes(),
 	})
 }
 
@@ -171,6 +180,18 @@ func (s *staticsServer) setTheme(theme string) {
 	s.mut.Unlock()
 }
 
+func (s *staticsServer) setAssetDir(assetDir string) {
+	s.mut.Lock()
+	s.assetDir = assetDir
+	s.mut.Unlock()
+}
+
+func (s *staticsServer) currentTheme() (theme, assetDir string) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.theme, s.assetDir
+}
+
 func (s *staticsServer) String() string {
 	return fmt.Sprintf("staticsServer@%p", s)
 }
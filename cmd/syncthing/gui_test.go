@@ -13,12 +13,19 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"strconv"
 	"strings"
 	"testing"
+	"testing/quick"
 	"time"
 
 	"github.com/d4l3k/messagediff"
@@ -26,6 +33,7 @@ import (
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/sync"
 	"github.com/thejerf/suture"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestCSRFToken(t *testing.T) {
@@ -70,7 +78,7 @@ func TestStopAfterBrokenConfig(t *testing.T) {
 	}
 	w := config.Wrap("/dev/null", cfg)
 
-	srv := newAPIService(protocol.LocalDeviceID, w, "../../test/h1/https-cert.pem", "../../test/h1/https-key.pem", "", nil, nil, nil, nil, nil, nil, nil)
+	srv := newAPIService(protocol.LocalDeviceID, w, "../../test/h1/https-cert.pem", "../../test/h1/https-key.pem", "", nil, nil, nil, nil, nil, nil, nil, nil)
 	srv.started = make(chan string)
 
 	sup := suture.NewSimple("test")
@@ -145,6 +153,19 @@ func TestAssetsDir(t *testing.T) {
 
 	// only exists as overridden default/d so use that
 	expectURLToContain(t, s.URL+"/d", "overridden-default")
+
+	// themes from both the compiled in assets and the override dir
+	themes := e.availableThemes()
+	if diff, equal := messagediff.PrettyDiff([]string{"default", "foo", "testfolder"}, themes); !equal {
+		t.Errorf("Incorrect themes. Diff:\n%s", diff)
+	}
+
+	// without the override dir only compiled in assets are served
+	e.setAssetDir("")
+	expectURLToContain(t, s.URL+"/a", "foo")
+	if themes := e.availableThemes(); len(themes) != 2 {
+		t.Errorf("Expected only the compiled in themes, not %v", themes)
+	}
 }
 
 func expectURLToContain(t *testing.T, url, exp string) {
@@ -233,6 +254,36 @@ func TestAPIServiceRequests(t *testing.T) {
 			Type:   "application/json",
 			Prefix: "null",
 		},
+		{
+			URL:    "/rest/db/collisions?folder=default",
+			Code:   200,
+			Type:   "application/json",
+			Prefix: "[",
+		},
+		{
+			URL:    "/rest/notifications",
+			Code:   200,
+			Type:   "application/json",
+			Prefix: "{",
+		},
+		{
+			URL:    "/rest/db/manifest?folder=default",
+			Code:   200,
+			Type:   "application/json",
+			Prefix: "[]",
+		},
+		{
+			URL:    "/rest/db/selection?folder=default",
+			Code:   200,
+			Type:   "application/json",
+			Prefix: "{",
+		},
+		{
+			URL:    "/rest/folder/conflicts?folder=default",
+			Code:   200,
+			Type:   "application/json",
+			Prefix: "[",
+		},
 
 		// /rest/stats
 		{
@@ -463,6 +514,655 @@ func TestHTTPLogin(t *testing.T) {
 	}
 }
 
+func TestCommandAuthenticator(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test script requires a shell")
+	}
+
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Accepts üser with the password räksmörgås, in UTF-8
+	script := filepath.Join(dir, "auth.sh")
+	err = ioutil.WriteFile(script, []byte("#!/bin/sh\nread user\nread pass\n[ \"$user\" = \"\xc3\xbcser\" ] && [ \"$pass\" = \"r\xc3\xa4ksm\xc3\xb6rg\xc3\xa5s\" ]\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := newAuthenticator(config.GUIConfiguration{
+		AuthMode:    config.AuthModeCommand,
+		AuthCommand: script,
+	})
+
+	cases := []struct {
+		user, pass string
+		ok         bool
+	}{
+		{"üser", "räksmörgås", true},
+		{"\xfcser", "r\xe4ksm\xf6rg\xe5s", true}, // ISO-8859-1
+		{"üser", "rksmrgs", false},
+		{"user", "räksmörgås", false},
+		{"üser", "räksmörgås\nüser", false},
+	}
+
+	for _, tc := range cases {
+		user, ok := auth.authenticate([]byte(tc.user), []byte(tc.pass))
+		if ok != tc.ok {
+			t.Errorf("authenticate(%q, %q) = %v, expected %v", tc.user, tc.pass, ok, tc.ok)
+		}
+		if tc.ok && (user.username != "üser" || user.readOnly) {
+			t.Errorf("authenticate(%q, %q) returned %+v", tc.user, tc.pass, user)
+		}
+	}
+
+	auth = newAuthenticator(config.GUIConfiguration{
+		AuthMode:    config.AuthModeCommand,
+		AuthCommand: filepath.Join(dir, "nonexistent"),
+	})
+	if _, ok := auth.authenticate([]byte("üser"), []byte("räksmörgås")); ok {
+		t.Error("missing command should refuse logins")
+	}
+}
+
+func TestReadOnlyRole(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test script requires a shell")
+	}
+
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "auth.sh")
+	err = ioutil.WriteFile(script, []byte("#!/bin/sh\nread user\nread pass\n[ \"$user\" = viewer ] && [ \"$pass\" = secret ] && echo readonly\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := new(mockedConfig)
+	cfg.gui.AuthMode = config.AuthModeCommand
+	cfg.gui.AuthCommand = script
+	baseURL, err := startHTTP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Looking is fine
+
+	req, _ := http.NewRequest("GET", baseURL, nil)
+	req.SetBasicAuth("viewer", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected non-200 return code %d for read only GET", resp.StatusCode)
+	}
+	var session *http.Cookie
+	for _, cookie := range resp.Cookies() {
+		if strings.HasPrefix(cookie.Name, "sessionid-") {
+			session = cookie
+		}
+	}
+	if session == nil {
+		t.Fatal("Expected a session cookie")
+	}
+
+	// Touching is not, with the password or with the session
+
+	req, _ = http.NewRequest("POST", baseURL+"/rest/system/error/clear", nil)
+	req.SetBasicAuth("viewer", "secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Unexpected non-403 return code %d for read only POST", resp.StatusCode)
+	}
+
+	req, _ = http.NewRequest("POST", baseURL+"/rest/system/error/clear", nil)
+	req.AddCookie(session)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Unexpected non-403 return code %d for read only POST with session", resp.StatusCode)
+	}
+}
+
+func TestFolderScope(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test script requires a shell")
+	}
+
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "auth.sh")
+	err = ioutil.WriteFile(script, []byte("#!/bin/sh\nread user\nread pass\n[ \"$user\" = tenant ] && [ \"$pass\" = secret ] && echo folder:mine\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := new(mockedConfig)
+	cfg.gui.AuthMode = config.AuthModeCommand
+	cfg.gui.AuthCommand = script
+	baseURL, err := startHTTP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Get a CSRF token from the GUI, which is visible to all
+
+	req, _ := http.NewRequest("GET", baseURL, nil)
+	req.SetBasicAuth("tenant", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	var csrf *http.Cookie
+	for _, cookie := range resp.Cookies() {
+		if strings.HasPrefix(cookie.Name, "CSRF-Token-") {
+			csrf = cookie
+		}
+	}
+	if resp.StatusCode != http.StatusOK || csrf == nil {
+		t.Fatalf("Unexpected return code %d or missing CSRF cookie", resp.StatusCode)
+	}
+
+	cases := []struct {
+		method, path string
+		status       int
+	}{
+		{"GET", "/rest/system/version", http.StatusOK},
+		{"GET", "/rest/system/config", http.StatusOK},
+		{"GET", "/rest/stats/folder", http.StatusOK},
+		{"GET", "/rest/db/status?folder=theirs", http.StatusForbidden},
+		{"POST", "/rest/db/scan?folder=theirs", http.StatusForbidden},
+		{"POST", "/rest/db/scan", http.StatusForbidden},
+		{"GET", "/rest/events", http.StatusForbidden},
+		{"GET", "/rest/system/browse", http.StatusForbidden},
+		{"POST", "/rest/system/config", http.StatusBadRequest}, // limited to the folders in scope, but this isn't a config
+		{"POST", "/rest/system/restart", http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		req, _ := http.NewRequest(tc.method, baseURL+tc.path, nil)
+		req.SetBasicAuth("tenant", "secret")
+		req.Header.Set("X-"+csrf.Name, csrf.Value)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s %s: unexpected return code %d, expected %d", tc.method, tc.path, resp.StatusCode, tc.status)
+		}
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	const hash = "$2a$10$IdIZTxTg/dCNuNEGlmLynOjqg4B1FvDKuIV5e0BB3pnWVHNb8.GSq" // bcrypt of "räksmörgås" in UTF-8
+	cfg := new(mockedConfig)
+	cfg.gui.User = "admin"
+	cfg.gui.Password = hash
+	cfg.gui.Accounts = []config.AccountConfiguration{
+		{Name: "kid", Password: hash, Folders: []string{"homework"}},
+		{Name: "guest", Password: hash, ReadOnly: true, Folders: []string{"homework"}},
+		{Name: "nothing", Password: hash},
+	}
+	baseURL, err := startHTTP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Get a CSRF token from the GUI, which is visible to all
+
+	req, _ := http.NewRequest("GET", baseURL, nil)
+	req.SetBasicAuth("kid", "räksmörgås")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	var csrf *http.Cookie
+	for _, cookie := range resp.Cookies() {
+		if strings.HasPrefix(cookie.Name, "CSRF-Token-") {
+			csrf = cookie
+		}
+	}
+	if resp.StatusCode != http.StatusOK || csrf == nil {
+		t.Fatalf("Unexpected return code %d or missing CSRF cookie", resp.StatusCode)
+	}
+
+	cases := []struct {
+		user, method, path string
+		status             int
+	}{
+		{"admin", "GET", "/rest/system/discovery", http.StatusOK},
+		{"kid", "GET", "/rest/system/version", http.StatusOK},
+		{"kid", "GET", "/rest/system/discovery", http.StatusForbidden},
+		{"kid", "GET", "/rest/db/status?folder=theirs", http.StatusForbidden},
+		{"kid", "POST", "/rest/system/pause", http.StatusForbidden},
+		{"guest", "GET", "/rest/stats/folder", http.StatusOK},
+		{"guest", "POST", "/rest/db/scan?folder=homework", http.StatusForbidden},
+		{"nothing", "GET", "/rest/db/status?folder=homework", http.StatusForbidden},
+		{"nobody", "GET", "/rest/system/version", http.StatusUnauthorized},
+	}
+
+	for _, tc := range cases {
+		req, _ := http.NewRequest(tc.method, baseURL+tc.path, nil)
+		req.SetBasicAuth(tc.user, "räksmörgås")
+		req.Header.Set("X-"+csrf.Name, csrf.Value)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s %s %s: unexpected return code %d, expected %d", tc.user, tc.method, tc.path, resp.StatusCode, tc.status)
+		}
+	}
+}
+
+func TestMergeScopedConfig(t *testing.T) {
+	device1, _ := protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+	device2, _ := protocol.DeviceIDFromString("GYRZZQB-IRNPV4Z-T7TC52W-EQYJ3TT-FDQW6MW-DFLMU42-SSSU6EM-FBK2VAY")
+	device3, _ := protocol.DeviceIDFromString("LGFPDIT-7SKNNJL-VJZA4FC-7QNCRKA-CE753K7-2BW5QDK-2FOZ7FR-FEP57QJ")
+
+	cfg := config.Configuration{
+		Folders: []config.FolderConfiguration{
+			{ID: "mine", RawPath: "/home/kid", PrePullCommand: "/bin/check", Devices: []config.FolderDeviceConfiguration{{DeviceID: device1}, {DeviceID: device2}}},
+			{ID: "theirs", Devices: []config.FolderDeviceConfiguration{{DeviceID: device1}, {DeviceID: device3}}},
+		},
+		Devices: []config.DeviceConfiguration{
+			{DeviceID: device1, Name: "server"},
+			{DeviceID: device2, Name: "laptop"},
+			{DeviceID: device3, Name: "phone"},
+		},
+		Options: config.OptionsConfiguration{MaxSendKbps: 100},
+	}
+	folders, devices := scopeVisible(cfg, device1, []string{"mine"})
+
+	edit := func(fn func(*config.Configuration)) (config.Configuration, error) {
+		posted := scopedConfig(cfg.Copy(), folders, devices)
+		fn(&posted)
+		return mergeScopedConfig(cfg.Copy(), posted, device1, folders, devices)
+	}
+
+	merged, err := edit(func(posted *config.Configuration) {
+		posted.Folders[0].Label = "Homework"
+		posted.Folders[0].RawPath = "/etc"
+		posted.Folders[0].PrePullCommand = "/bin/evil"
+		posted.Devices[0].Name = "mine now"
+		posted.Devices[1].Name = "my laptop"
+		posted.Options.MaxSendKbps = 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Folders[0].Label != "Homework" || merged.Devices[1].Name != "my laptop" {
+		t.Errorf("Expected the changes in scope to be applied: %+v %+v", merged.Folders[0], merged.Devices[1])
+	}
+	if merged.Folders[0].RawPath != "/home/kid" || merged.Folders[0].PrePullCommand != "/bin/check" {
+		t.Errorf("Expected the path and commands to be kept: %+v", merged.Folders[0])
+	}
+	if merged.Devices[0].Name != "server" || merged.Options.MaxSendKbps != 100 || len(merged.Folders) != 2 || merged.Devices[2].Name != "phone" {
+		t.Errorf("Expected everything else to be kept: %+v", merged)
+	}
+
+	refused := map[string]func(*config.Configuration){
+		"removing a folder": func(posted *config.Configuration) { posted.Folders = nil },
+		"adding a folder": func(posted *config.Configuration) {
+			posted.Folders = append(posted.Folders, config.FolderConfiguration{ID: "theirs"})
+		},
+		"sharing out of scope": func(posted *config.Configuration) {
+			posted.Folders[0].Devices = append(posted.Folders[0].Devices, config.FolderDeviceConfiguration{DeviceID: device3})
+		},
+		"removing a device": func(posted *config.Configuration) { posted.Devices = posted.Devices[:1] },
+	}
+	for name, fn := range refused {
+		if _, err := edit(fn); err == nil {
+			t.Errorf("Expected %s to be refused", name)
+		}
+	}
+}
+
+func TestMergeScopedFields(t *testing.T) {
+	device1, _ := protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+	device2, _ := protocol.DeviceIDFromString("GYRZZQB-IRNPV4Z-T7TC52W-EQYJ3TT-FDQW6MW-DFLMU42-SSSU6EM-FBK2VAY")
+	rnd := rand.New(rand.NewSource(1))
+
+	// change sets each exported field of the posted struct but those
+	// skipped to something else, and checks that those editable end up in
+	// the merged one while all others are reset.
+	change := func(name string, orig interface{}, skip, editable map[string]bool, merge func(posted reflect.Value) reflect.Value) {
+		typ := reflect.TypeOf(orig)
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath != "" || skip[field.Name] {
+				continue
+			}
+			posted := reflect.New(typ).Elem()
+			posted.Set(reflect.ValueOf(orig))
+			for {
+				value, ok := quick.Value(field.Type, rnd)
+				if !ok {
+					t.Fatalf("Can't generate a %s.%s", name, field.Name)
+				}
+				if !reflect.DeepEqual(value.Interface(), posted.Field(i).Interface()) {
+					posted.Field(i).Set(value)
+					break
+				}
+			}
+
+			merged := merge(posted)
+			if editable[field.Name] && !reflect.DeepEqual(merged.Field(i).Interface(), posted.Field(i).Interface()) {
+				t.Errorf("Expected %s.%s to be changed", name, field.Name)
+			}
+			if !editable[field.Name] && !reflect.DeepEqual(merged.Field(i).Interface(), reflect.ValueOf(orig).Field(i).Interface()) {
+				t.Errorf("Expected %s.%s to be reset", name, field.Name)
+			}
+		}
+	}
+
+	fcfg := config.FolderConfiguration{
+		ID:      "mine",
+		RawPath: "/home/kid",
+		Devices: []config.FolderDeviceConfiguration{{DeviceID: device1}, {DeviceID: device2, CompletionWebhook: "https://example.com/"}},
+	}
+	change("folder", fcfg, map[string]bool{"ID": true, "Devices": true}, map[string]bool{
+		"Label": true, "RescanIntervalS": true, "IgnorePerms": true, "AutoNormalize": true,
+		"MinDiskFreePct": true, "Order": true, "IgnoreDelete": true, "MaxConflicts": true,
+		"ConflictPolicy": true, "ConflictNaming": true, "PriorityPatterns": true,
+		"MaxSendKbps": true, "MaxRecvKbps": true,
+	}, func(posted reflect.Value) reflect.Value {
+		return reflect.ValueOf(mergeScopedFolder(fcfg, posted.Interface().(config.FolderConfiguration)))
+	})
+
+	dcfg := config.DeviceConfiguration{DeviceID: device2, Name: "laptop"}
+	change("device", dcfg, map[string]bool{"DeviceID": true}, map[string]bool{
+		"Name": true, "Compression": true, "SyncWindows": true,
+		"MaxSendKbps": true, "MaxRecvKbps": true, "ZstdLevel": true,
+	}, func(posted reflect.Value) reflect.Value {
+		return reflect.ValueOf(mergeScopedDevice(dcfg, posted.Interface().(config.DeviceConfiguration)))
+	})
+
+	// The folder may be shared with other devices in scope, and unshared,
+	// but the settings for each device are kept, and none given to those
+	// it's newly shared with.
+	posted := fcfg
+	posted.Devices = []config.FolderDeviceConfiguration{
+		{DeviceID: device2, CompletionWebhook: "https://evil.example.com/", IntroducedBy: device1},
+	}
+	if merged := mergeScopedFolder(fcfg, posted); len(merged.Devices) != 1 || merged.Devices[0] != fcfg.Devices[1] {
+		t.Errorf("Expected the existing device settings kept, got %+v", merged.Devices)
+	}
+	posted.Devices = []config.FolderDeviceConfiguration{{DeviceID: device2}}
+	fcfg.Devices = fcfg.Devices[:1]
+	if merged := mergeScopedFolder(fcfg, posted); len(merged.Devices) != 1 || merged.Devices[0] != (config.FolderDeviceConfiguration{DeviceID: device2}) {
+		t.Errorf("Expected the device shared with without settings, got %+v", merged.Devices)
+	}
+}
+
+func TestPrepareConfig(t *testing.T) {
+	cfg := new(mockedConfig)
+	cfg.gui.Password = "$2a$10$current"
+	cfg.gui.Accounts = []config.AccountConfiguration{{Name: "kid", Password: "$2a$10$kid"}}
+
+	to := config.Configuration{
+		GUI: config.GUIConfiguration{
+			Password: "plaintext",
+			Accounts: []config.AccountConfiguration{{Name: "kid", Password: "$2a$10$kid"}, {Name: "guest", Password: "guest"}},
+		},
+	}
+	if err := prepareConfig(cfg, &to); err != nil {
+		t.Fatal(err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(to.GUI.Password), []byte("plaintext")) != nil {
+		t.Errorf("Expected the changed password to be hashed, got %q", to.GUI.Password)
+	}
+	if to.GUI.Accounts[0].Password != "$2a$10$kid" || bcrypt.CompareHashAndPassword([]byte(to.GUI.Accounts[1].Password), []byte("guest")) != nil {
+		t.Errorf("Expected only the new account password to be hashed, got %+v", to.GUI.Accounts)
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	cfg := new(mockedConfig)
+	now := time.Now()
+	reader, err := cfg.gui.AddAPIToken("reader", []string{config.APITokenScopeRead}, []string{"mine"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher, err := cfg.gui.AddAPIToken("watcher", []string{config.APITokenScopeEvents}, nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := cfg.gui.AddAPIToken("admin", []string{config.APITokenScopeConfig, config.APITokenScopeControl}, nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseURL, err := startHTTP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests with a token need no CSRF token, but are limited to the
+	// token's scopes and folders
+
+	cases := []struct {
+		token, method, path string
+		status              int
+	}{
+		{reader, "GET", "/rest/system/version", http.StatusOK},
+		{reader, "GET", "/rest/stats/folder", http.StatusOK},
+		{reader, "GET", "/rest/db/status?folder=theirs", http.StatusForbidden},
+		{reader, "POST", "/rest/db/scan?folder=mine", http.StatusForbidden},
+		{reader, "GET", "/rest/system/config", http.StatusForbidden}, // with its secrets
+		{reader, "GET", "/rest/shares", http.StatusForbidden},
+		{reader, "POST", "/rest/system/apitokens?name=other&scope=read", http.StatusForbidden},
+		{reader, "GET", "/rest/db/backup", http.StatusForbidden},
+		{reader, "GET", "/rest/system/log?redact=false", http.StatusForbidden},
+		{reader, "GET", "/rest/system/log?redact=paths", http.StatusForbidden},
+		{reader, "GET", "/rest/system/nonexistent", http.StatusForbidden},
+		{watcher, "GET", "/rest/system/version", http.StatusForbidden},
+		{admin, "GET", "/rest/system/version", http.StatusForbidden},
+		{admin, "GET", "/rest/system/config", http.StatusOK},
+		{admin, "POST", "/rest/system/apitokens?name=other&scope=read", http.StatusOK},
+		{admin, "POST", "/rest/system/apitokens?name=other&scope=everything", http.StatusBadRequest},
+		{admin, "GET", "/rest/manage/config?device=nonsense", http.StatusBadRequest}, // allowed, the config being secret
+		{watcher, "GET", "/rest/manage/config?device=nonsense", http.StatusForbidden},
+		{"nonsense", "POST", "/rest/system/apitokens?name=other&scope=read", http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		req, _ := http.NewRequest(tc.method, baseURL+tc.path, nil)
+		req.Header.Set("X-API-Key", tc.token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s %s: unexpected return code %d, expected %d", tc.method, tc.path, resp.StatusCode, tc.status)
+		}
+	}
+
+	// Following the events is allowed by either scope
+
+	for _, scope := range []string{config.APITokenScopeEvents, config.APITokenScopeRead} {
+		token := config.APITokenConfiguration{Scopes: []string{scope}}
+		req, _ := http.NewRequest("GET", "/rest/events/disk", nil)
+		if !apiTokenAllows(token, req) {
+			t.Errorf("Events not allowed with scope %s", scope)
+		}
+	}
+
+	// Promoting and resetting rewrite the config and the database, and
+	// are not mere control
+
+	control := config.APITokenConfiguration{Scopes: []string{config.APITokenScopeControl}}
+	for _, path := range []string{"/rest/system/promote", "/rest/system/reset"} {
+		req, _ := http.NewRequest("POST", path, nil)
+		if apiTokenAllows(control, req) {
+			t.Errorf("%s allowed with the control scope", path)
+		}
+	}
+
+	// The support bundle is redacted unless asked otherwise, the log only
+	// when asked
+
+	read := config.APITokenConfiguration{Scopes: []string{config.APITokenScopeRead}}
+	for path, allowed := range map[string]bool{
+		"/rest/system/support":              true,
+		"/rest/system/support?redact=true":  true,
+		"/rest/system/support?redact=false": false,
+		"/rest/system/log":                  false,
+		"/rest/system/log.txt?redact=all":   true,
+	} {
+		req, _ := http.NewRequest("GET", path, nil)
+		if apiTokenAllows(read, req) != allowed {
+			t.Errorf("GET %s: allowed should be %v with the read scope", path, allowed)
+		}
+	}
+}
+
+func TestCLIAPIKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "cli-apikey.txt")
+	key, err := writeCLIAPIKey(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read := readCLIAPIKey(name); read != key {
+		t.Fatalf("Read CLI API key %q, expected %q", read, key)
+	}
+	if read := readCLIAPIKey(filepath.Join(dir, "nonexistent")); read != "" {
+		t.Errorf("Read CLI API key %q from a nonexistent file", read)
+	}
+
+	// The key is accepted from the loopback interface only
+
+	cli := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	handler := cliAPIKeyMiddleware(key, cli, next)
+
+	cases := []struct {
+		key, remoteAddr string
+		status          int
+	}{
+		{key, "127.0.0.1:1234", http.StatusOK},
+		{key, "[::1]:1234", http.StatusOK},
+		{key, "192.0.2.42:1234", http.StatusForbidden},
+		{"nonsense", "127.0.0.1:1234", http.StatusForbidden},
+		{"", "127.0.0.1:1234", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("POST", "/rest/system/upgrade", nil)
+		req.RemoteAddr = tc.remoteAddr
+		req.Header.Set("X-API-Key", tc.key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("Key %q from %s: unexpected return code %d, expected %d", tc.key, tc.remoteAddr, rec.Code, tc.status)
+		}
+	}
+}
+
+func TestScopedConfig(t *testing.T) {
+	device1, _ := protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+	device2, _ := protocol.DeviceIDFromString("GYRZZQB-IRNPV4Z-T7TC52W-EQYJ3TT-FDQW6MW-DFLMU42-SSSU6EM-FBK2VAY")
+	device3, _ := protocol.DeviceIDFromString("LGFPDIT-7SKNNJL-VJZA4FC-7QNCRKA-CE753K7-2BW5QDK-2FOZ7FR-FEP57QJ")
+
+	cfg := config.Configuration{
+		Folders: []config.FolderConfiguration{
+			{ID: "mine", Devices: []config.FolderDeviceConfiguration{{DeviceID: device1}, {DeviceID: device2}}},
+			{ID: "theirs", Devices: []config.FolderDeviceConfiguration{{DeviceID: device1}, {DeviceID: device3}}},
+		},
+		Devices: []config.DeviceConfiguration{
+			{DeviceID: device1},
+			{DeviceID: device2},
+			{DeviceID: device3},
+		},
+		GUI: config.GUIConfiguration{
+			User:     "admin",
+			Password: "hash",
+		},
+		Alerts: config.AlertConfiguration{
+			SMTPUser:     "mailer",
+			SMTPPassword: "secret",
+		},
+	}
+
+	folders, devices := scopeVisible(cfg, device1, []string{"mine", "nonexistent"})
+	if len(folders) != 1 || !folders["mine"] {
+		t.Errorf("Unexpected visible folders %v", folders)
+	}
+	if len(devices) != 2 || !devices[device1] || !devices[device2] {
+		t.Errorf("Unexpected visible devices %v", devices)
+	}
+
+	scoped := scopedConfig(cfg, folders, devices)
+	if len(scoped.Folders) != 1 || scoped.Folders[0].ID != "mine" {
+		t.Errorf("Unexpected folders in scoped config: %+v", scoped.Folders)
+	}
+	if len(scoped.Devices) != 2 || scoped.Devices[0].DeviceID != device1 || scoped.Devices[1].DeviceID != device2 {
+		t.Errorf("Unexpected devices in scoped config: %+v", scoped.Devices)
+	}
+	if scoped.GUI.User != "" || scoped.GUI.Password != "" {
+		t.Error("GUI credentials in scoped config")
+	}
+	if scoped.Alerts.SMTPPassword != "" {
+		t.Error("SMTP credentials in scoped config")
+	}
+	if len(cfg.Folders) != 2 || len(cfg.Devices) != 3 {
+		t.Error("Original config was modified")
+	}
+}
+
+func TestUnknownAuthModeRefuses(t *testing.T) {
+	cfg := new(mockedConfig)
+	cfg.gui.AuthMode = "nonexistent"
+	cfg.gui.User = "üser"
+	cfg.gui.Password = "$2a$10$IdIZTxTg/dCNuNEGlmLynOjqg4B1FvDKuIV5e0BB3pnWVHNb8.GSq" // bcrypt of "räksmörgås" in UTF-8
+	baseURL, err := startHTTP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", baseURL, nil)
+	req.SetBasicAuth("üser", "räksmörgås")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Unexpected non-401 return code %d for unknown auth mode", resp.StatusCode)
+	}
+}
+
 func startHTTP(cfg *mockedConfig) (string, error) {
 	model := new(mockedModel)
 	httpsCertFile := "../../test/h1/https-cert.pem"
@@ -472,13 +1172,14 @@ func startHTTP(cfg *mockedConfig) (string, error) {
 	diskEventSub := new(mockedEventSub)
 	discoverer := new(mockedCachingMux)
 	connections := new(mockedConnections)
+	notifications := newNotificationService("")
 	errorLog := new(mockedLoggerRecorder)
 	systemLog := new(mockedLoggerRecorder)
 	addrChan := make(chan string)
 
 	// Instantiate the API service
 	svc := newAPIService(protocol.LocalDeviceID, cfg, httpsCertFile, httpsKeyFile, assetDir, model,
-		eventSub, diskEventSub, discoverer, connections, errorLog, systemLog)
+		eventSub, diskEventSub, discoverer, connections, notifications, errorLog, systemLog)
 	svc.started = addrChan
 
 	// Actually start the API service
@@ -924,3 +1625,236 @@ func TestOptionsRequest(t *testing.T) {
 		t.Fatal("OPTIONS on /rest/system/status should return a 'Access-Control-Allow-Headers: Content-Type, X-API-KEY' header")
 	}
 }
+
+func TestSessionLimits(t *testing.T) {
+	defer clearSessions()
+
+	cfg := config.GUIConfiguration{
+		SessionLifetimeS:    3600,
+		SessionIdleTimeoutS: 600,
+		MaxSessions:         2,
+	}
+	start := time.Now()
+
+	first := newSession(cfg, start, login{})
+	if !useSession(cfg, first, start.Add(5*time.Minute)) {
+		t.Error("fresh session should be valid")
+	}
+	if !useSession(cfg, first, start.Add(14*time.Minute)) {
+		t.Error("session used within the idle timeout should be valid")
+	}
+	if useSession(cfg, first, start.Add(25*time.Minute)) {
+		t.Error("idle session should have expired")
+	}
+
+	second := newSession(cfg, start, login{})
+	for i := 1; i < 6; i++ {
+		useSession(cfg, second, start.Add(time.Duration(i)*9*time.Minute))
+	}
+	if useSession(cfg, second, start.Add(61*time.Minute)) {
+		t.Error("session should have expired after its lifetime")
+	}
+
+	third := newSession(cfg, start, login{})
+	fourth := newSession(cfg, start.Add(time.Minute), login{})
+	useSession(cfg, third, start.Add(2*time.Minute))
+	fifth := newSession(cfg, start.Add(3*time.Minute), login{})
+	if useSession(cfg, fourth, start.Add(4*time.Minute)) {
+		t.Error("least recently used session should have been logged out")
+	}
+	if !useSession(cfg, third, start.Add(4*time.Minute)) || !useSession(cfg, fifth, start.Add(4*time.Minute)) {
+		t.Error("most recently used sessions should remain")
+	}
+
+	clearSessions()
+	if useSession(cfg, third, start.Add(5*time.Minute)) {
+		t.Error("session should have been logged out")
+	}
+}
+
+func TestRemoteAddress(t *testing.T) {
+	cfg := config.GUIConfiguration{
+		TrustedProxies: []string{"192.0.2.1", "10.0.0.0/8", "invalid"},
+	}
+	trusted := cfg.TrustedProxyNets()
+	if len(trusted) != 2 {
+		t.Fatal("expected two trusted proxy networks, got", trusted)
+	}
+
+	cases := []struct {
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		// Not from a proxy; the header is ignored
+		{"198.51.100.7:1234", []string{"203.0.113.5"}, "198.51.100.7"},
+		// From a trusted proxy
+		{"192.0.2.1:1234", []string{"203.0.113.5"}, "203.0.113.5"},
+		{"192.0.2.1:1234", nil, "192.0.2.1"},
+		// Through a chain of trusted proxies, with a spoofed entry from
+		// the client to the left
+		{"192.0.2.1:1234", []string{"1.2.3.4, 203.0.113.5", "10.1.2.3"}, "203.0.113.5"},
+	}
+
+	for _, tc := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remoteAddr
+		for _, hdr := range tc.forwarded {
+			r.Header.Add("X-Forwarded-For", hdr)
+		}
+		if addr := remoteAddress(r, trusted); addr != tc.expected {
+			t.Errorf("remoteAddress(%s, %v) = %s, expected %s", tc.remoteAddr, tc.forwarded, addr, tc.expected)
+		}
+	}
+}
+
+func TestBasePathMiddleware(t *testing.T) {
+	var served string
+	h := basePathMiddleware("/syncthing", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = r.URL.Path
+	}))
+
+	cases := []struct {
+		path   string
+		status int
+		served string
+	}{
+		{"/syncthing", http.StatusMovedPermanently, ""},
+		{"/syncthing/", http.StatusOK, "/"},
+		{"/syncthing/rest/system/ping", http.StatusOK, "/rest/system/ping"},
+		{"/rest/system/ping", http.StatusNotFound, ""},
+		{"/syncthingfoo", http.StatusNotFound, ""},
+	}
+
+	for _, tc := range cases {
+		served = ""
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", tc.path, nil))
+		if rec.Code != tc.status || served != tc.served {
+			t.Errorf("%s: got status %d serving %q, expected %d serving %q", tc.path, rec.Code, served, tc.status, tc.served)
+		}
+	}
+}
+
+func TestLocale(t *testing.T) {
+	available := newStaticsServer("default", "").availableLanguages()
+	for _, lang := range []string{"en", "de", "zh-CN", "zh-TW"} {
+		found := false
+		for _, a := range available {
+			if a == lang {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("language %q not in available languages %v", lang, available)
+		}
+	}
+
+	cases := []struct {
+		header   string
+		expected string
+	}{
+		{"", "en"},
+		{"de-DE,de;q=0.8,en;q=0.5", "de"},
+		{"zh-TW,zh;q=0.8", "zh-TW"},
+		{"x,xx-YY", "en"},
+		{"xx,fr;q=0.5", "fr"},
+	}
+	for _, tc := range cases {
+		if locale := negotiateLocale(acceptedLanguages(tc.header), available); locale != tc.expected {
+			t.Errorf("negotiateLocale(%q) = %q, expected %q", tc.header, locale, tc.expected)
+		}
+	}
+
+	if f := formatForLocale("en"); f.DecimalSeparator != "." || f.DateOrder != "mdy" {
+		t.Error("unexpected format for en:", f)
+	}
+	if f := formatForLocale("de"); f.DecimalSeparator != "," || f.ThousandsSeparator != "." || f.DateOrder != "dmy" {
+		t.Error("unexpected format for de:", f)
+	}
+	if f := formatForLocale("zh-CN"); f.DateOrder != "ymd" {
+		t.Error("unexpected format for zh-CN:", f)
+	}
+}
+
+func TestCheckFolder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "foldercheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	existing := map[string]config.FolderConfiguration{
+		"existing": config.NewFolderConfiguration("existing", filepath.Join(dir, "existing")),
+	}
+
+	cases := []struct {
+		path      string
+		ok        bool
+		exists    bool
+		creatable bool
+	}{
+		{"", false, false, false},
+		{dir, true, true, false},
+		{filepath.Join(dir, "new", "folder"), true, false, true},
+		{filepath.Join(dir, "file"), false, false, false},
+		{filepath.Join(dir, "existing"), false, false, true},
+	}
+
+	for _, tc := range cases {
+		c := checkFolder(config.FolderConfiguration{ID: "new", RawPath: tc.path}, existing)
+		if ok := len(c.Errors) == 0; ok != tc.ok {
+			t.Errorf("%q: expected ok %v, got errors %v", tc.path, tc.ok, c.Errors)
+		}
+		if c.Exists != tc.exists || c.Creatable != tc.creatable {
+			t.Errorf("%q: expected exists %v and creatable %v, got %+v", tc.path, tc.exists, tc.creatable, c)
+		}
+		if tc.ok && c.Capabilities == nil {
+			t.Errorf("%q: expected capabilities to be probed", tc.path)
+		}
+	}
+
+	// The folder itself should not have been created
+	if _, err := os.Stat(filepath.Join(dir, "new")); !os.IsNotExist(err) {
+		t.Error("checking a folder should not create it")
+	}
+
+	// Editing the existing folder doesn't conflict with itself
+	if c := checkFolder(existing["existing"], existing); len(c.Errors) != 0 {
+		t.Error("unexpected errors checking existing folder:", c.Errors)
+	}
+}
+
+func TestPauseOwner(t *testing.T) {
+	cases := []struct {
+		query            string
+		paused           bool
+		pausedBy, reason string
+		expectedBy       string
+		expectedReason   string
+		expectedErr      bool
+	}{
+		{"", true, "", "", config.PauseOwnerUser, "", false},
+		{"owner=low-disk&reason=under+5%25+free", true, "", "", config.PauseOwnerLowDisk, "under 5% free", false},
+		{"", false, config.PauseOwnerSchedule, "office hours", "", "", false},
+		{"owner=schedule", false, config.PauseOwnerSchedule, "office hours", "", "", false},
+		{"owner=schedule", false, config.PauseOwnerUser, "on holiday", "", "", true},
+		{"owner=schedule&force=true", false, config.PauseOwnerUser, "on holiday", "", "", false},
+		{"owner=policy", false, "", "", "", "", false},
+	}
+
+	for _, tc := range cases {
+		qs, _ := url.ParseQuery(tc.query)
+		by, reason, err := pauseOwner(qs, tc.paused, tc.pausedBy, tc.reason)
+		if (err != nil) != tc.expectedErr {
+			t.Errorf("%q: unexpected error %v", tc.query, err)
+			continue
+		}
+		if by != tc.expectedBy || reason != tc.expectedReason {
+			t.Errorf("%q: paused by %q for %q, expected %q for %q", tc.query, by, reason, tc.expectedBy, tc.expectedReason)
+		}
+	}
+}
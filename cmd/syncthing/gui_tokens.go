@@ -0,0 +1,303 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/redact"
+)
+
+// An apiTokenInfo is an API token as listed at /rest/system/apitokens,
+// without its hash.
+type apiTokenInfo struct {
+	Name    string    `json:"name"`
+	Scopes  []string  `json:"scopes"`
+	Folders []string  `json:"folders"`
+	Created time.Time `json:"created"`
+}
+
+// apiTokenMiddleware serves the requests carrying an API token in the
+// X-API-Key header with the token handler, limited to what the token may
+// do. Like those carrying the API key they need neither a login nor a CSRF
+// token. Every use of a token is logged as an APITokenUsed event, for
+// auditing. Other requests are passed on to next.
+func apiTokenMiddleware(cfg config.GUIConfiguration, scope func(folders []string, next http.Handler) http.Handler, tokenHandler, next http.Handler) http.Handler {
+	if len(cfg.APITokens) == 0 {
+		return next
+	}
+	trustedProxies := cfg.TrustedProxyNets()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := cfg.APIToken(r.Header.Get("X-API-Key"))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed := apiTokenAllows(token, r)
+		events.Default.Log(events.APITokenUsed, map[string]interface{}{
+			"token":         token.Name,
+			"method":        r.Method,
+			"path":          r.URL.Path,
+			"remoteAddress": remoteAddress(r, trustedProxies),
+			"allowed":       allowed,
+		})
+		if !allowed {
+			http.Error(w, "Forbidden: not in the scopes of the API token", http.StatusForbidden)
+			return
+		}
+
+		var folders []string
+		if len(token.Folders) > 0 {
+			folders = token.Folders
+		}
+		// Set the access-control-allow-origin header for CORS requests, as
+		// for the API key
+		w.Header().Add("Access-Control-Allow-Origin", "*")
+		scope(folders, tokenHandler).ServeHTTP(w, r)
+	})
+}
+
+// The scopes needed for each endpoint, by method. Endpoints not listed are
+// refused to all tokens. Looking at the config, ours or that of a managed
+// device, with its secrets, is part of changing it, as is anything that
+// dumps the database or rewrites the folders wholesale.
+var apiTokenGetScopes = map[string]string{
+	"/rest/db/completion":         config.APITokenScopeRead,
+	"/rest/db/file":               config.APITokenScopeRead,
+	"/rest/db/ignores":            config.APITokenScopeRead,
+	"/rest/db/need":               config.APITokenScopeRead,
+	"/rest/db/status":             config.APITokenScopeRead,
+	"/rest/db/browse":             config.APITokenScopeRead,
+	"/rest/db/collisions":         config.APITokenScopeRead,
+	"/rest/db/manifest":           config.APITokenScopeRead,
+	"/rest/db/selection":          config.APITokenScopeRead,
+	"/rest/db/backup":             config.APITokenScopeConfig,
+	"/rest/events":                config.APITokenScopeEvents,
+	"/rest/events/disk":           config.APITokenScopeEvents,
+	"/rest/folder/case-conflicts": config.APITokenScopeRead,
+	"/rest/folder/conflicts":      config.APITokenScopeRead,
+	"/rest/folder/errors":         config.APITokenScopeRead,
+	"/rest/folder/statistics":     config.APITokenScopeRead,
+	"/rest/folder/versions":       config.APITokenScopeRead,
+	"/rest/folder/versions/diff":  config.APITokenScopeRead,
+	"/rest/manage/config":         config.APITokenScopeConfig,
+	"/rest/notifications":         config.APITokenScopeRead,
+	"/rest/shares":                config.APITokenScopeConfig,
+	"/rest/stats/device":          config.APITokenScopeRead,
+	"/rest/stats/device/history":  config.APITokenScopeRead,
+	"/rest/stats/folder":          config.APITokenScopeRead,
+	"/rest/svc/deviceid":          config.APITokenScopeRead,
+	"/rest/svc/lang":              config.APITokenScopeRead,
+	"/rest/svc/locale":            config.APITokenScopeRead,
+	"/rest/svc/report":            config.APITokenScopeRead,
+	"/rest/svc/random/string":     config.APITokenScopeRead,
+	"/rest/svc/themes":            config.APITokenScopeRead,
+	"/rest/system/browse":         config.APITokenScopeRead,
+	"/rest/system/capabilities":   config.APITokenScopeRead,
+	"/rest/system/clusterconfig":  config.APITokenScopeRead,
+	"/rest/system/apitokens":      config.APITokenScopeConfig,
+	"/rest/system/config":         config.APITokenScopeConfig,
+	"/rest/system/config/insync":  config.APITokenScopeRead,
+	"/rest/system/connections":    config.APITokenScopeRead,
+	"/rest/system/discovery":      config.APITokenScopeRead,
+	"/rest/system/error":          config.APITokenScopeRead,
+	"/rest/system/features":       config.APITokenScopeRead,
+	"/rest/system/ping":           config.APITokenScopeRead,
+	"/rest/system/powerprofile":   config.APITokenScopeRead,
+	"/rest/system/security":       config.APITokenScopeRead,
+	"/rest/system/state":          config.APITokenScopeRead,
+	"/rest/system/status":         config.APITokenScopeRead,
+	"/rest/system/support":        config.APITokenScopeRead, // if redacted, see below
+	"/rest/system/upgrade":        config.APITokenScopeRead,
+	"/rest/system/version":        config.APITokenScopeRead,
+	"/rest/system/debug":          config.APITokenScopeRead,
+	"/rest/system/log":            config.APITokenScopeRead, // if redacted, see below
+	"/rest/system/log.txt":        config.APITokenScopeRead, // if redacted, see below
+	"/rest/debug/peerCompletion":  config.APITokenScopeRead,
+	"/rest/debug/httpmetrics":     config.APITokenScopeRead,
+	"/rest/debug/lockmetrics":     config.APITokenScopeRead,
+	"/rest/debug/cpuprof":         config.APITokenScopeConfig,
+	"/rest/debug/heapprof":        config.APITokenScopeConfig,
+	"/metrics":                    config.APITokenScopeRead,
+}
+
+var apiTokenPostScopes = map[string]string{
+	"/rest/db/prio":                 config.APITokenScopeControl,
+	"/rest/db/prioritize":           config.APITokenScopeControl,
+	"/rest/db/ignores":              config.APITokenScopeControl,
+	"/rest/db/override":             config.APITokenScopeControl,
+	"/rest/db/pause":                config.APITokenScopeControl,
+	"/rest/db/resume":               config.APITokenScopeControl,
+	"/rest/db/scan":                 config.APITokenScopeControl,
+	"/rest/db/changes":              config.APITokenScopeControl,
+	"/rest/db/fetch":                config.APITokenScopeControl,
+	"/rest/db/snapshot":             config.APITokenScopeControl,
+	"/rest/db/verify":               config.APITokenScopeControl,
+	"/rest/db/selection":            config.APITokenScopeControl,
+	"/rest/folder/case-conflicts":   config.APITokenScopeControl,
+	"/rest/folder/conflicts":        config.APITokenScopeControl,
+	"/rest/folder/errors/retry":     config.APITokenScopeControl,
+	"/rest/folder/errors/ignore":    config.APITokenScopeControl,
+	"/rest/folder/ignores/preview":  config.APITokenScopeRead,
+	"/rest/folder/versions":         config.APITokenScopeControl,
+	"/rest/manage/config":           config.APITokenScopeConfig,
+	"/rest/manage/pause":            config.APITokenScopeControl,
+	"/rest/manage/resume":           config.APITokenScopeControl,
+	"/rest/manage/restart":          config.APITokenScopeControl,
+	"/rest/notifications":           config.APITokenScopeControl,
+	"/rest/notifications/ack":       config.APITokenScopeControl,
+	"/rest/notifications/delete":    config.APITokenScopeControl,
+	"/rest/shares":                  config.APITokenScopeConfig,
+	"/rest/shares/revoke":           config.APITokenScopeConfig,
+	"/rest/svc/folder/check":        config.APITokenScopeRead,
+	"/rest/svc/locale":              config.APITokenScopeControl,
+	"/rest/system/apikey/rotate":    config.APITokenScopeConfig,
+	"/rest/system/apitokens":        config.APITokenScopeConfig,
+	"/rest/system/apitokens/revoke": config.APITokenScopeConfig,
+	"/rest/system/config":           config.APITokenScopeConfig,
+	"/rest/system/error":            config.APITokenScopeControl,
+	"/rest/system/error/clear":      config.APITokenScopeControl,
+	"/rest/system/ping":             config.APITokenScopeRead,
+	"/rest/system/promote":          config.APITokenScopeConfig,
+	"/rest/system/powerprofile":     config.APITokenScopeControl,
+	"/rest/system/reset":            config.APITokenScopeConfig,
+	"/rest/system/security/ack":     config.APITokenScopeControl,
+	"/rest/system/sessions/clear":   config.APITokenScopeControl,
+	"/rest/system/restart":          config.APITokenScopeControl,
+	"/rest/system/shutdown":         config.APITokenScopeControl,
+	"/rest/system/upgrade":          config.APITokenScopeControl,
+	"/rest/system/pause":            config.APITokenScopeControl,
+	"/rest/system/resume":           config.APITokenScopeControl,
+	"/rest/system/debug":            config.APITokenScopeControl,
+}
+
+// apiTokenAllows returns true if the request is within the scopes of the
+// token. Looking at the events is part of looking at everything. The
+// support bundle and the log need the read scope only when fully redacted,
+// and the config scope otherwise.
+func apiTokenAllows(token config.APITokenConfiguration, r *http.Request) bool {
+	var scopes map[string]string
+	switch r.Method {
+	case "GET":
+		scopes = apiTokenGetScopes
+	case "POST":
+		scopes = apiTokenPostScopes
+	default:
+		return false
+	}
+
+	path := r.URL.Path
+	scope, ok := scopes[path]
+	if !ok {
+		if r.Method == "GET" && strings.HasPrefix(path, "/qr/") {
+			scope = config.APITokenScopeRead
+		} else {
+			return false
+		}
+	}
+
+	switch {
+	case scope == config.APITokenScopeEvents:
+		return token.HasScope(config.APITokenScopeEvents) || token.HasScope(config.APITokenScopeRead)
+	case path == "/rest/system/support":
+		// Redacted unless asked otherwise
+		if !fullyRedacted(r, redact.All) {
+			scope = config.APITokenScopeConfig
+		}
+	case path == "/rest/system/log", path == "/rest/system/log.txt":
+		if !fullyRedacted(r, redact.None) {
+			scope = config.APITokenScopeConfig
+		}
+	}
+	return token.HasScope(scope)
+}
+
+// fullyRedacted returns true if the "redact" parameter of the request, or
+// def when there is none, redacts all kinds of values.
+func fullyRedacted(r *http.Request, def redact.Kinds) bool {
+	kinds := def
+	if param, ok := r.URL.Query()["redact"]; ok && len(param) > 0 {
+		var err error
+		kinds, err = redact.ParseKinds(param[0])
+		if err != nil {
+			return false
+		}
+	}
+	return kinds == redact.All
+}
+
+func (s *apiService) getSystemAPITokens(w http.ResponseWriter, r *http.Request) {
+	tokens := []apiTokenInfo{}
+	for _, token := range s.cfg.GUI().APITokens {
+		tokens = append(tokens, apiTokenInfo{
+			Name:    token.Name,
+			Scopes:  token.Scopes,
+			Folders: token.Folders,
+			Created: token.Created,
+		})
+	}
+	sendJSON(w, tokens)
+}
+
+func (s *apiService) postSystemAPITokens(w http.ResponseWriter, r *http.Request) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	qs := r.URL.Query()
+	to := s.cfg.RawCopy()
+	token, err := to.GUI.AddAPIToken(qs.Get("name"), qs["scope"], qs["folder"], time.Now().Truncate(time.Second))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.cfg.Replace(to); err != nil {
+		l.Warnln("Replacing config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.cfg.Save(); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// This is the only time the token is available in plain text.
+	sendJSON(w, map[string]string{
+		"token": token,
+	})
+}
+
+func (s *apiService) postSystemAPITokensRevoke(w http.ResponseWriter, r *http.Request) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
+
+	to := s.cfg.RawCopy()
+	if !to.GUI.RevokeAPIToken(r.URL.Query().Get("name")) {
+		http.Error(w, "No such API token", http.StatusNotFound)
+		return
+	}
+
+	if err := s.cfg.Replace(to); err != nil {
+		l.Warnln("Replacing config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.cfg.Save(); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// getSystemState returns the state of the folders and devices that changed
+// since the given event ID, along with the current errors, so that the GUI
+// can refresh with a single request. Everything is returned when since is
+// zero, when the configuration changed, or when events were missed; "full"
+// is then true and the state replaces rather than updates what the caller
+// has. Like /rest/events, it waits up to timeout seconds for something to
+// change, but by default returns right away.
+func (s *apiService) getSystemState(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	since, _ := strconv.Atoi(qs.Get("since"))
+	timeout, _ := strconv.Atoi(qs.Get("timeout"))
+	if timeout < 0 {
+		timeout = 0
+	}
+
+	evs := s.eventSub.Since(since, nil, time.Duration(timeout)*time.Second)
+	sequence := since
+	if len(evs) > 0 {
+		sequence = evs[len(evs)-1].SubscriptionID
+	}
+
+	folders, devices, full := stateChanges(since, evs)
+	if full {
+		folders = make(map[string]bool)
+		for id := range s.cfg.Folders() {
+			folders[id] = true
+		}
+		devices = make(map[string]bool)
+		for id := range s.cfg.Devices() {
+			devices[id.String()] = true
+		}
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"sequence":   sequence,
+		"full":       full,
+		"folders":    s.folderStates(folders),
+		"devices":    s.deviceStates(devices),
+		"completion": s.completionStates(folders),
+		"errors":     s.guiErrors.Since(time.Time{}),
+	})
+}
+
+// stateChanges returns the folders and devices the events are about, or
+// whether everything should be considered changed instead.
+func stateChanges(since int, evs []events.Event) (folders, devices map[string]bool, full bool) {
+	if since <= 0 || len(evs) > 0 && evs[0].SubscriptionID > since+1 {
+		return nil, nil, true
+	}
+
+	folders = make(map[string]bool)
+	devices = make(map[string]bool)
+	for _, ev := range evs {
+		if ev.Type == events.ConfigSaved {
+			return nil, nil, true
+		}
+		var folder, device string
+		switch data := ev.Data.(type) {
+		case map[string]interface{}:
+			folder, _ = data["folder"].(string)
+			device, _ = data["device"].(string)
+			if device == "" {
+				device, _ = data["id"].(string)
+			}
+		case map[string]string:
+			folder = data["folder"]
+			device = data["device"]
+			if device == "" {
+				device = data["id"]
+			}
+		}
+		if folder != "" {
+			folders[folder] = true
+		}
+		if device == "" {
+			continue
+		}
+		if _, err := protocol.DeviceIDFromString(device); err == nil {
+			devices[device] = true
+		}
+	}
+	return folders, devices, false
+}
+
+// folderStates returns the summaries of the folders, or nil for those that
+// no longer exist.
+func (s *apiService) folderStates(folders map[string]bool) map[string]interface{} {
+	cfgs := s.cfg.Folders()
+	res := make(map[string]interface{}, len(folders))
+	for folder := range folders {
+		if _, ok := cfgs[folder]; !ok {
+			res[folder] = nil
+			continue
+		}
+		res[folder] = folderSummary(s.cfg, s.model, folder)
+	}
+	return res
+}
+
+// deviceStates returns the connection and statistics of the devices, or
+// nil for those that no longer exist.
+func (s *apiService) deviceStates(devices map[string]bool) map[string]interface{} {
+	cfgs := s.cfg.Devices()
+	conns, _ := s.model.ConnectionStats()["connections"].(map[string]model.ConnectionInfo)
+	devStats := s.model.DeviceStatistics()
+	res := make(map[string]interface{}, len(devices))
+	for device := range devices {
+		id, err := protocol.DeviceIDFromString(device)
+		if _, ok := cfgs[id]; err != nil || !ok {
+			res[device] = nil
+			continue
+		}
+		res[device] = map[string]interface{}{
+			"connection": conns[device],
+			"statistics": devStats[device],
+		}
+	}
+	return res
+}
+
+// completionStates returns how far each of the other devices sharing the
+// folders is in completing them, by folder and device, as /rest/db/completion
+// does.
+func (s *apiService) completionStates(folders map[string]bool) map[string]map[string]interface{} {
+	cfgs := s.cfg.Folders()
+	res := make(map[string]map[string]interface{}, len(folders))
+	for folder := range folders {
+		cfg, ok := cfgs[folder]
+		if !ok {
+			continue
+		}
+		res[folder] = make(map[string]interface{}, len(cfg.Devices))
+		for _, device := range cfg.DeviceIDs() {
+			if device == s.id {
+				continue
+			}
+			res[folder][device.String()] = completionInfo(s.model.Completion(device, folder))
+		}
+	}
+	return res
+}
@@ -0,0 +1,50 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestStateChanges(t *testing.T) {
+	device := protocol.LocalDeviceID.String()
+	evs := []events.Event{
+		{SubscriptionID: 6, Type: events.StateChanged, Data: map[string]interface{}{"folder": "default", "from": "idle", "to": "scanning"}},
+		{SubscriptionID: 7, Type: events.DeviceConnected, Data: map[string]string{"id": device, "addr": "127.0.0.1:22000"}},
+		{SubscriptionID: 8, Type: events.FolderCompletion, Data: map[string]interface{}{"folder": "other", "device": device, "completion": 50.0}},
+		{SubscriptionID: 9, Type: events.Starting, Data: map[string]string{"home": "/tmp"}},
+	}
+
+	folders, devices, full := stateChanges(5, evs)
+	if full {
+		t.Fatal("Unexpected full state")
+	}
+	if !reflect.DeepEqual(folders, map[string]bool{"default": true, "other": true}) {
+		t.Errorf("Unexpected changed folders %v", folders)
+	}
+	if !reflect.DeepEqual(devices, map[string]bool{device: true}) {
+		t.Errorf("Unexpected changed devices %v", devices)
+	}
+
+	// Everything changed at the start, after missed events, or when the
+	// config was saved
+
+	if _, _, full := stateChanges(0, evs); !full {
+		t.Error("Not full state without a previous sequence")
+	}
+	if _, _, full := stateChanges(4, evs); !full {
+		t.Error("Not full state after missing an event")
+	}
+	evs = append(evs, events.Event{SubscriptionID: 10, Type: events.ConfigSaved})
+	if _, _, full := stateChanges(5, evs); !full {
+		t.Error("Not full state after the config was saved")
+	}
+}
@@ -29,10 +29,13 @@ const (
 	locDatabase                   = "database"
 	locLogFile                    = "logFile"
 	locCsrfTokens                 = "csrfTokens"
+	locNotifications              = "notifications"
 	locPanicLog                   = "panicLog"
 	locAuditLog                   = "auditLog"
 	locGUIAssets                  = "GUIAssets"
 	locDefFolder                  = "defFolder"
+	locMigration                  = "migrationStatus"
+	locCLIAPIKey                  = "cliAPIKey"
 )
 
 // Platform dependent directories
@@ -51,10 +54,13 @@ var locations = map[locationEnum]string{
 	locDatabase:      "${config}/index-v0.14.0.db",
 	locLogFile:       "${config}/syncthing.log", // -logfile on Windows
 	locCsrfTokens:    "${config}/csrftokens.txt",
+	locNotifications: "${config}/notifications.json",
 	locPanicLog:      "${config}/panic-${timestamp}.log",
 	locAuditLog:      "${config}/audit-${timestamp}.log",
 	locGUIAssets:     "${config}/gui",
 	locDefFolder:     "${home}/Sync",
+	locMigration:     "${config}/migration.json",
+	locCLIAPIKey:     "${config}/cli-apikey.txt",
 }
 
 // expandLocations replaces the variables in the location map with actual
@@ -42,6 +42,7 @@ import (
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/rand"
+	"github.com/syncthing/syncthing/lib/secrets"
 	"github.com/syncthing/syncthing/lib/sha256"
 	"github.com/syncthing/syncthing/lib/tlsutil"
 	"github.com/syncthing/syncthing/lib/upgrade"
@@ -187,6 +188,14 @@ are mostly useful for developers. Use with care.
  STDEADLOCKTHRESHOLD Used for debugging internal deadlocks; sets debug
                      sensitivity.  Use only under direction of a developer.
 
+ STDEADLOCKDUMP    Used for debugging internal deadlocks; the number of
+                   seconds to wait for a lock before logging the stacks of
+                   all goroutines. Use only under direction of a developer.
+
+ STLOCKMETRICS     Collect lock wait and hold times per call site, exported at
+                   /metrics and shown at /rest/debug/lockmetrics when GUI
+                   debugging is enabled.
+
  STNORESTART       Equivalent to the -no-restart argument. Disable the
                    Syncthing monitor process which handles restarts for some
                    configuration changes, upgrades, crashes and also log file
@@ -223,32 +232,36 @@ var (
 )
 
 type RuntimeOptions struct {
-	confDir        string
-	resetDatabase  bool
-	resetDeltaIdxs bool
-	showVersion    bool
-	showPaths      bool
-	doUpgrade      bool
-	doUpgradeCheck bool
-	upgradeTo      string
-	noBrowser      bool
-	browserOnly    bool
-	hideConsole    bool
-	logFile        string
-	auditEnabled   bool
-	auditFile      string
-	verbose        bool
-	paused         bool
-	unpaused       bool
-	guiAddress     string
-	guiAPIKey      string
-	generateDir    string
-	noRestart      bool
-	profiler       string
-	assetDir       string
-	cpuProfile     bool
-	stRestarting   bool
-	logFlags       int
+	confDir         string
+	resetDatabase   bool
+	resetDeltaIdxs  bool
+	showVersion     bool
+	showPaths       bool
+	doUpgrade       bool
+	doUpgradeCheck  bool
+	upgradeTo       string
+	noBrowser       bool
+	browserOnly     bool
+	hideConsole     bool
+	logFile         string
+	auditEnabled    bool
+	auditFile       string
+	verbose         bool
+	paused          bool
+	unpaused        bool
+	guiAddress      string
+	guiAPIKey       string
+	generateDir     string
+	noRestart       bool
+	profiler        string
+	assetDir        string
+	cpuProfile      bool
+	stRestarting    bool
+	logFlags        int
+	monitorAddress  string
+	backupDatabase  string
+	backupSince     string
+	restoreDatabase string
 }
 
 func defaultRuntimeOptions() RuntimeOptions {
@@ -287,8 +300,12 @@ func parseCommandLineOptions() RuntimeOptions {
 	flag.BoolVar(&options.noBrowser, "no-browser", false, "Do not start browser")
 	flag.BoolVar(&options.browserOnly, "browser-only", false, "Open GUI in browser")
 	flag.BoolVar(&options.noRestart, "no-restart", options.noRestart, "Disable monitor process, managed restarts and log file writing")
+	flag.StringVar(&options.monitorAddress, "monitor-address", "", "Serve the monitor process control API on this loopback address or Unix socket path")
 	flag.BoolVar(&options.resetDatabase, "reset-database", false, "Reset the database, forcing a full rescan and resync")
 	flag.BoolVar(&options.resetDeltaIdxs, "reset-deltas", false, "Reset delta index IDs, forcing a full index exchange")
+	flag.StringVar(&options.backupDatabase, "backup-database", "", "Back up the database of the running Syncthing to the file, then exit")
+	flag.StringVar(&options.backupSince, "backup-since", "", "Make the backup incremental to the backup with this ID")
+	flag.StringVar(&options.restoreDatabase, "restore-database", "", "Restore the database from a full backup and the incremental ones after it, a list separated like PATH")
 	flag.BoolVar(&options.doUpgrade, "upgrade", false, "Perform upgrade")
 	flag.BoolVar(&options.doUpgradeCheck, "upgrade-check", false, "Check for available upgrade")
 	flag.BoolVar(&options.showVersion, "version", false, "Show version")
@@ -411,6 +428,23 @@ func main() {
 		return
 	}
 
+	if options.backupDatabase != "" {
+		id, err := backupDBViaRest(options.backupDatabase, options.backupSince)
+		if err != nil {
+			l.Fatalln("Backup:", err)
+		}
+		l.Infof("Backed up the database to %s; the backup ID is %s", options.backupDatabase, id)
+		return
+	}
+
+	if options.restoreDatabase != "" {
+		if err := db.RestoreBackup(locations[locDatabase], filepath.SplitList(options.restoreDatabase)); err != nil {
+			l.Fatalln("Restore:", err)
+		}
+		l.Infoln("Restored the database from", options.restoreDatabase)
+		return
+	}
+
 	// ---BEGIN TEMPORARY HACK---
 	//
 	// Remove once v0.14.21-v0.14.22 are rare enough. Those versions,
@@ -556,26 +590,11 @@ func performUpgrade(release upgrade.Release) {
 }
 
 func upgradeViaRest() error {
-	cfg, _ := loadConfig()
-	u, err := url.Parse(cfg.GUI().URL())
+	r, err := newRESTRequest("POST", "rest/system/upgrade")
 	if err != nil {
 		return err
 	}
-	u.Path = path.Join(u.Path, "rest/system/upgrade")
-	target := u.String()
-	r, _ := http.NewRequest("POST", target, nil)
-	r.Header.Set("X-API-Key", cfg.GUI().APIKey)
-
-	tr := &http.Transport{
-		Dial:            dialer.Dial,
-		Proxy:           http.ProxyFromEnvironment,
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{
-		Transport: tr,
-		Timeout:   60 * time.Second,
-	}
-	resp, err := client.Do(r)
+	resp, err := restClient(60 * time.Second).Do(r)
 	if err != nil {
 		return err
 	}
@@ -591,6 +610,44 @@ func upgradeViaRest() error {
 	return err
 }
 
+// newRESTRequest returns a request for the endpoint of the REST API of the
+// running Syncthing.
+func newRESTRequest(method, endpoint string) (*http.Request, error) {
+	cfg, _ := loadConfig()
+	u, err := url.Parse(cfg.GUI().URL())
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, endpoint)
+	target := u.String()
+	r, _ := http.NewRequest(method, target, nil)
+	// The API key is only stored hashed in the config, so unless it's given
+	// on the command line or in the environment we use the CLI API key left
+	// by the running Syncthing.
+	apiKey := os.Getenv("STGUIAPIKEY")
+	if apiKey == "" {
+		apiKey = readCLIAPIKey(locations[locCLIAPIKey])
+	}
+	if apiKey == "" {
+		return nil, errors.New("API key required; use -gui-apikey")
+	}
+	r.Header.Set("X-API-Key", apiKey)
+	return r, nil
+}
+
+// restClient returns a client for the REST API of the running Syncthing.
+func restClient(timeout time.Duration) *http.Client {
+	tr := &http.Transport{
+		Dial:            dialer.Dial,
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	return &http.Client{
+		Transport: tr,
+		Timeout:   timeout,
+	}
+}
+
 func syncthingMain(runtimeOptions RuntimeOptions) {
 	setupSignalHandling()
 
@@ -618,10 +675,16 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 	errors := logger.NewRecorder(l, logger.LevelWarn, maxSystemErrors, 0)
 	systemLog := logger.NewRecorder(l, logger.LevelDebug, maxSystemLog, initialSystemLog)
 
+	notifications := newNotificationService(locations[locNotifications])
+	l.AddHandler(logger.LevelWarn, notifications.warning)
+	mainService.Add(notifications)
+
 	// Event subscription for the API; must start early to catch the early
 	// events. The LocalChangeDetected event might overwhelm the event
 	// receiver in some situations so we will not subscribe to it here.
-	apiSub := events.NewBufferedSubscription(events.Default.Subscribe(events.AllEvents&^events.LocalChangeDetected&^events.RemoteChangeDetected), 1000)
+	// Nor to APITokenUsed, as a token following the events would be woken
+	// by its own requests.
+	apiSub := events.NewBufferedSubscription(events.Default.Subscribe(events.AllEvents&^events.LocalChangeDetected&^events.RemoteChangeDetected&^events.APITokenUsed), 1000)
 	diskSub := events.NewBufferedSubscription(events.Default.Subscribe(events.LocalChangeDetected|events.RemoteChangeDetected), 1000)
 
 	if len(os.Getenv("GOMAXPROCS")) == 0 {
@@ -669,6 +732,17 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 		l.Fatalln("Short device IDs are in conflict. Unlucky!\n  Regenerate the device ID of one of the following:\n  ", err)
 	}
 
+	secretStore = secrets.NewStore(baseDirs["config"])
+	if cfg.Options().UseKeychain {
+		raw := cfg.RawCopy()
+		if err := storeSecrets(&raw); err != nil {
+			l.Warnln("Storing secrets in the keychain:", err)
+		} else if raw.GUI.Password != cfg.GUI().Password || raw.Alerts.SMTPPassword != cfg.Alerts().SMTPPassword {
+			cfg.Replace(raw)
+			cfg.Save()
+		}
+	}
+
 	if len(runtimeOptions.profiler) > 0 {
 		go func() {
 			l.Debugln("Starting profiler on", runtimeOptions.profiler)
@@ -741,7 +815,12 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 	}
 
 	dbFile := locations[locDatabase]
-	ldb, err := db.Open(dbFile)
+	dbProfile := db.StorageProfileDefault
+	if opts.StorageProfile == config.StorageProfileFlash {
+		l.Infoln("Using the flash storage profile; writes are batched")
+		dbProfile = db.StorageProfileFlash
+	}
+	ldb, err := db.OpenWithProfile(dbFile, dbProfile)
 
 	if err != nil {
 		l.Fatalln("Cannot open database:", err, "- Is another copy of Syncthing already running?")
@@ -754,6 +833,7 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 
 	protectedFiles := []string{
 		locations[locDatabase],
+		db.ShardsLocation(locations[locDatabase]),
 		locations[locConfigFile],
 		locations[locCertFile],
 		locations[locKeyFile],
@@ -776,10 +856,28 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 	}
 	if cfg.RawCopy().OriginalVersion < 19 {
 		// Converts old symlink types to new in the entire database.
-		ldb.ConvertSymlinkTypes()
+		ldb.ScheduleMigration(db.MigrationSymlinkTypes)
+	}
+	runDBMigrations(ldb, cfg.GUI())
+
+	if opts.SharedBlockIndex {
+		l.Infoln("Indexing the blocks of all folders together")
+	}
+	if err := ldb.SetSharedBlockIndex(opts.SharedBlockIndex); err != nil {
+		l.Warnln("Indexing blocks:", err)
 	}
 
 	m := model.NewModel(cfg, myID, myDeviceName(cfg), "syncthing", Version, ldb, protectedFiles)
+	m.SetRestartHandler(restart)
+	m.SetConfigHandler(func(to config.Configuration) error {
+		if err := prepareConfig(cfg, &to); err != nil {
+			return err
+		}
+		if err := cfg.Replace(to); err != nil {
+			return err
+		}
+		return cfg.Save()
+	})
 
 	if t := os.Getenv("STDEADLOCKTIMEOUT"); len(t) > 0 {
 		it, err := strconv.Atoi(t)
@@ -805,6 +903,20 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 		m.StartFolder(folderCfg.ID)
 	}
 
+	mainService.Add(newAlertService(myID, cfg, m))
+	mainService.Add(newCompletionWebhookService(cfg, m))
+	mainService.Add(newEventWebhookService(cfg))
+	mainService.Add(newMQTTService(myID, cfg))
+	mainService.Add(newPowerService(cfg))
+
+	webdavService := newWebDAVService(cfg, m, locations[locHTTPSCertFile], locations[locHTTPSKeyFile])
+	cfg.Subscribe(webdavService)
+	mainService.Add(webdavService)
+
+	shareService := newShareService(cfg, locations[locHTTPSCertFile], locations[locHTTPSKeyFile])
+	cfg.Subscribe(shareService)
+	mainService.Add(shareService)
+
 	mainService.Add(m)
 
 	// Start discovery
@@ -814,7 +926,7 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 
 	// Start connection management
 
-	connectionsService := connections.NewService(cfg, myID, m, tlsCfg, cachedDiscovery, bepProtocolName, tlsDefaultCommonName, lans)
+	connectionsService := connections.NewService(cfg, myID, m, db.NewNamespacedKV(ldb, string([]byte{db.KeyTypeSecurityAlert})), tlsCfg, cachedDiscovery, bepProtocolName, tlsDefaultCommonName, lans)
 	mainService.Add(connectionsService)
 
 	if cfg.Options().GlobalAnnEnabled {
@@ -852,7 +964,7 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 
 	// GUI
 
-	setupGUI(mainService, cfg, m, apiSub, diskSub, cachedDiscovery, connectionsService, errors, systemLog, runtimeOptions)
+	setupGUI(mainService, cfg, m, apiSub, diskSub, cachedDiscovery, connectionsService, notifications, errors, systemLog, runtimeOptions)
 
 	if runtimeOptions.cpuProfile {
 		f, err := os.Create(fmt.Sprintf("cpu-%d.pprof", os.Getpid()))
@@ -1066,7 +1178,7 @@ func startAuditing(mainService *suture.Supervisor, auditFile string) {
 	l.Infoln("Audit log in", auditDest)
 }
 
-func setupGUI(mainService *suture.Supervisor, cfg *config.Wrapper, m *model.Model, apiSub events.BufferedSubscription, diskSub events.BufferedSubscription, discoverer discover.CachingMux, connectionsService *connections.Service, errors, systemLog logger.Recorder, runtimeOptions RuntimeOptions) {
+func setupGUI(mainService *suture.Supervisor, cfg *config.Wrapper, m *model.Model, apiSub events.BufferedSubscription, diskSub events.BufferedSubscription, discoverer discover.CachingMux, connectionsService *connections.Service, notifications *notificationService, errors, systemLog logger.Recorder, runtimeOptions RuntimeOptions) {
 	guiCfg := cfg.GUI()
 
 	if !guiCfg.Enabled {
@@ -1077,7 +1189,8 @@ func setupGUI(mainService *suture.Supervisor, cfg *config.Wrapper, m *model.Mode
 		l.Warnln("Insecure admin access is enabled.")
 	}
 
-	api := newAPIService(myID, cfg, locations[locHTTPSCertFile], locations[locHTTPSKeyFile], runtimeOptions.assetDir, m, apiSub, diskSub, discoverer, connectionsService, errors, systemLog)
+	api := newAPIService(myID, cfg, locations[locHTTPSCertFile], locations[locHTTPSKeyFile], runtimeOptions.assetDir, m, apiSub, diskSub, discoverer, connectionsService, notifications, errors, systemLog)
+	api.cliAPIKeyFile = locations[locCLIAPIKey]
 	cfg.Subscribe(api)
 	mainService.Add(api)
 
@@ -1137,7 +1250,7 @@ func defaultConfig(myName string) config.Configuration {
 }
 
 func resetDB() error {
-	return os.RemoveAll(locations[locDatabase])
+	return db.Remove(locations[locDatabase])
 }
 
 func restart() {
@@ -1320,6 +1433,7 @@ func checkShortIDs(cfg *config.Wrapper) error {
 func showPaths() {
 	fmt.Printf("Configuration file:\n\t%s\n\n", locations[locConfigFile])
 	fmt.Printf("Database directory:\n\t%s\n\n", locations[locDatabase])
+	fmt.Printf("Database folder shards directory:\n\t%s\n\n", db.ShardsLocation(locations[locDatabase]))
 	fmt.Printf("Device private key & certificate files:\n\t%s\n\t%s\n\n", locations[locKeyFile], locations[locCertFile])
 	fmt.Printf("HTTPS private key & certificate files:\n\t%s\n\t%s\n\n", locations[locHTTPSKeyFile], locations[locHTTPSCertFile])
 	fmt.Printf("Log file:\n\t%s\n\n", locations[locLogFile])
@@ -1331,9 +1445,11 @@ func setPauseState(cfg *config.Wrapper, paused bool) {
 	raw := cfg.RawCopy()
 	for i := range raw.Devices {
 		raw.Devices[i].Paused = paused
+		raw.Devices[i].PausedBy = config.PauseOwnerUser
 	}
 	for i := range raw.Folders {
 		raw.Folders[i].Paused = paused
+		raw.Folders[i].PausedBy = config.PauseOwnerUser
 	}
 	if err := cfg.Replace(raw); err != nil {
 		l.Fatalln("Cannot adjust paused state:", err)
@@ -0,0 +1,183 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// Metrics are served at /metrics in the Prometheus text format, so that
+// the progress of folders and the traffic with devices can be scraped into
+// dashboards rather than polled from the REST API. Folders that are paused
+// are left out, as are devices we aren't connected to from the traffic.
+// The lock metrics, collected when STLOCKMETRICS is set, are only served
+// to those that may see everything.
+
+// getMetrics serves the metrics for all folders and devices.
+func (s *apiService) getMetrics(w http.ResponseWriter, r *http.Request) {
+	s.serveMetrics(w, nil, nil)
+}
+
+// serveMetrics serves the metrics for the visible folders and devices, or
+// all of them if nil.
+func (s *apiService) serveMetrics(w http.ResponseWriter, visibleFolders map[string]bool, visibleDevices map[protocol.DeviceID]bool) {
+	mw := newMetricsWriter()
+
+	var folders []string
+	for id, fcfg := range s.cfg.Folders() {
+		if !fcfg.Paused && (visibleFolders == nil || visibleFolders[id]) {
+			folders = append(folders, id)
+		}
+	}
+	sort.Strings(folders)
+
+	mw.family("syncthing_folder_global_bytes", "gauge", "Size of the global state of the folder.")
+	mw.family("syncthing_folder_need_bytes", "gauge", "Bytes needed to bring the folder up to date.")
+	mw.family("syncthing_folder_need_items", "gauge", "Files, directories, symlinks and deletes needed to bring the folder up to date.")
+	mw.family("syncthing_folder_completion_percent", "gauge", "How much of the global state of the folder we have.")
+	mw.family("syncthing_folder_pull_errors", "gauge", "Files that failed to sync in the last pull.")
+	for _, folder := range folders {
+		labels := []string{"folder", folder}
+		global := s.model.GlobalSize(folder)
+		need := s.model.NeedSize(folder)
+		mw.sample("syncthing_folder_global_bytes", labels, float64(global.Bytes))
+		mw.sample("syncthing_folder_need_bytes", labels, float64(need.Bytes))
+		mw.sample("syncthing_folder_need_items", labels, float64(need.Files+need.Directories+need.Symlinks+need.Deleted))
+		completion := 100.0
+		if global.Bytes > 0 {
+			completion = 100 * (1 - float64(need.Bytes)/float64(global.Bytes))
+		}
+		mw.sample("syncthing_folder_completion_percent", labels, completion)
+		if fileErrors, err := s.model.FolderErrors(folder); err == nil {
+			mw.sample("syncthing_folder_pull_errors", labels, float64(len(fileErrors)))
+		}
+	}
+
+	devices := s.cfg.Devices()
+	conns, _ := s.model.ConnectionStats()["connections"].(map[string]model.ConnectionInfo)
+	var ids []string
+	for id := range conns {
+		deviceID, err := protocol.DeviceIDFromString(id)
+		if err != nil || deviceID == s.id || visibleDevices != nil && !visibleDevices[deviceID] {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	mw.family("syncthing_device_connected", "gauge", "Whether we are connected to the device, by the type of connection.")
+	mw.family("syncthing_device_received_bytes_total", "counter", "Bytes received from the device on the current connection.")
+	mw.family("syncthing_device_sent_bytes_total", "counter", "Bytes sent to the device on the current connection.")
+	for _, id := range ids {
+		ci := conns[id]
+		deviceID, _ := protocol.DeviceIDFromString(id)
+		labels := []string{"device", id, "name", devices[deviceID].Name}
+		if !ci.Connected {
+			mw.sample("syncthing_device_connected", append(labels, "type", ""), 0)
+			continue
+		}
+		mw.sample("syncthing_device_connected", append(labels, "type", ci.Type), 1)
+		mw.sample("syncthing_device_received_bytes_total", labels, float64(ci.InBytesTotal))
+		mw.sample("syncthing_device_sent_bytes_total", labels, float64(ci.OutBytesTotal))
+	}
+
+	if visibleFolders == nil && visibleDevices == nil {
+		writeLockMetrics(mw, sync.LockMetrics())
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	mw.WriteTo(w)
+}
+
+// writeLockMetrics adds the lock metrics, by call site.
+func writeLockMetrics(mw *metricsWriter, lockMetrics map[string]sync.LockMetric) {
+	var sites []string
+	for at := range lockMetrics {
+		sites = append(sites, at)
+	}
+	sort.Strings(sites)
+
+	mw.family("syncthing_lock_acquired_total", "counter", "Times the locks taken at the call site were acquired.")
+	mw.family("syncthing_lock_contended_total", "counter", "Times the locks taken at the call site were held or waited on by someone else.")
+	mw.family("syncthing_lock_wait_seconds_total", "counter", "Time spent waiting for the locks taken at the call site.")
+	mw.family("syncthing_lock_hold_seconds_total", "counter", "Time the locks taken at the call site were held.")
+	for _, at := range sites {
+		m := lockMetrics[at]
+		labels := []string{"at", at}
+		mw.sample("syncthing_lock_acquired_total", labels, float64(m.Wait.Count()))
+		mw.sample("syncthing_lock_contended_total", labels, float64(m.Contended.Count()))
+		mw.sample("syncthing_lock_wait_seconds_total", labels, float64(m.Wait.Sum())/1e9)
+		mw.sample("syncthing_lock_hold_seconds_total", labels, float64(m.Hold.Sum())/1e9)
+	}
+}
+
+// metricsWriter collects metrics and writes them in the Prometheus text
+// format, each with its samples together.
+type metricsWriter struct {
+	names    []string                 // in the order declared
+	families map[string]*bytes.Buffer // name -> help, type and samples
+}
+
+func newMetricsWriter() *metricsWriter {
+	return &metricsWriter{
+		families: make(map[string]*bytes.Buffer),
+	}
+}
+
+// family declares a metric, which must be done before adding samples of it.
+func (m *metricsWriter) family(name, typ, help string) {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	m.names = append(m.names, name)
+	m.families[name] = buf
+}
+
+// sample adds a value of the metric, labelled by the given name and value
+// pairs.
+func (m *metricsWriter) sample(name string, labels []string, value float64) {
+	buf := m.families[name]
+	buf.WriteString(name)
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i == 0 {
+			buf.WriteByte('{')
+		} else {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(buf, "%s=\"%s\"", labels[i], metricsLabelEscaper.Replace(labels[i+1]))
+	}
+	if len(labels) > 1 {
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	buf.WriteByte('\n')
+}
+
+// WriteTo writes the metrics in the order they were declared.
+func (m *metricsWriter) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for _, name := range m.names {
+		n, err := m.families[name].WriteTo(w)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+var metricsLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
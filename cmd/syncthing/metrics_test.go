@@ -0,0 +1,75 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+func TestMetricsWriter(t *testing.T) {
+	mw := newMetricsWriter()
+	mw.family("test_bytes", "gauge", "Some bytes.")
+	mw.family("test_total", "counter", "Some count.")
+	mw.sample("test_total", nil, 3)
+	mw.sample("test_bytes", []string{"folder", "default"}, 1234567)
+	mw.sample("test_bytes", []string{"folder", `a "quoted" \ folder` + "\n", "device", "x"}, 0.5)
+
+	var buf bytes.Buffer
+	if _, err := mw.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Samples follow their own help and type lines, whatever the order
+	// they were added in.
+	expected := `# HELP test_bytes Some bytes.
+# TYPE test_bytes gauge
+test_bytes{folder="default"} 1.234567e+06
+test_bytes{folder="a \"quoted\" \\ folder\n",device="x"} 0.5
+# HELP test_total Some count.
+# TYPE test_total counter
+test_total 3
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected metrics:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestLockMetrics(t *testing.T) {
+	m := sync.LockMetric{
+		Wait:      metrics.NewTimer(),
+		Hold:      metrics.NewTimer(),
+		Contended: metrics.NewCounter(),
+	}
+	m.Wait.Update(500 * time.Millisecond)
+	m.Wait.Update(time.Second)
+	m.Hold.Update(2 * time.Second)
+	m.Contended.Inc(1)
+
+	mw := newMetricsWriter()
+	writeLockMetrics(mw, map[string]sync.LockMetric{"model.go:123": m})
+
+	var buf bytes.Buffer
+	if _, err := mw.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`syncthing_lock_acquired_total{at="model.go:123"} 2`,
+		`syncthing_lock_contended_total{at="model.go:123"} 1`,
+		`syncthing_lock_wait_seconds_total{at="model.go:123"} 1.5`,
+		`syncthing_lock_hold_seconds_total{at="model.go:123"} 2`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("Missing %q in metrics:\n%s", line, buf.String())
+		}
+	}
+}
@@ -52,6 +52,10 @@ func (c *mockedConfig) SetDevice(config.DeviceConfiguration) error {
 	return nil
 }
 
+func (c *mockedConfig) SetFolder(config.FolderConfiguration) error {
+	return nil
+}
+
 func (c *mockedConfig) Save() error {
 	return nil
 }
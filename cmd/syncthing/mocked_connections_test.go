@@ -6,8 +6,18 @@
 
 package main
 
+import "github.com/syncthing/syncthing/lib/connections"
+
 type mockedConnections struct{}
 
 func (m *mockedConnections) Status() map[string]interface{} {
 	return nil
 }
+
+func (m *mockedConnections) SecurityAlerts() []connections.SecurityAlert {
+	return nil
+}
+
+func (m *mockedConnections) AcknowledgeSecurityAlert(id int) bool {
+	return false
+}
@@ -9,10 +9,13 @@ package main
 import (
 	"time"
 
+	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/model"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/stats"
+	"github.com/syncthing/syncthing/lib/versioner"
 )
 
 type mockedModel struct{}
@@ -21,6 +24,38 @@ func (m *mockedModel) GlobalDirectoryTree(folder, prefix string, levels int, dir
 	return nil
 }
 
+func (m *mockedModel) GlobalNameCollisions(folder string) ([][]string, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) FolderConflicts(folder string) ([]model.Conflict, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) RepairCaseCollisions(folder string, policy config.CaseCollisionPolicy) ([]model.CaseRepair, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) ResolveConflict(folder, name string, keepCopy bool) error {
+	return nil
+}
+
+func (m *mockedModel) FolderManifest(folder string, local bool, fn func(model.ManifestEntry) bool) error {
+	return nil
+}
+
+func (m *mockedModel) VerifyManifest(folder string, entries []model.ManifestEntry) (model.ManifestReport, error) {
+	return model.ManifestReport{}, nil
+}
+
+func (m *mockedModel) Unselected(folder string) ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) SetSelected(folder, dir string, selected bool) error {
+	return nil
+}
+
 func (m *mockedModel) Completion(device protocol.DeviceID, folder string) model.FolderCompletion {
 	return model.FolderCompletion{}
 }
@@ -39,6 +74,14 @@ func (m *mockedModel) ConnectionStats() map[string]interface{} {
 	return nil
 }
 
+func (m *mockedModel) DeviceConnections(device protocol.DeviceID) ([]stats.ConnectionRecord, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) ManageDevice(device protocol.DeviceID, req protocol.ManagementRequest) ([]byte, error) {
+	return nil, nil
+}
+
 func (m *mockedModel) DeviceStatistics() map[string]stats.DeviceStatistics {
 	return nil
 }
@@ -58,7 +101,15 @@ func (m *mockedModel) CurrentGlobalFile(folder string, file string) (protocol.Fi
 func (m *mockedModel) ResetFolder(folder string) {
 }
 
-func (m *mockedModel) Availability(folder, file string, version protocol.Vector, block protocol.BlockInfo) []model.Availability {
+func (m *mockedModel) PromoteStandby(takePauseState bool) error {
+	return nil
+}
+
+func (m *mockedModel) NewDatabaseBackup(since string) (*db.Backup, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) Availability(folder string, file protocol.FileInfo, block protocol.BlockInfo) []model.Availability {
 	return nil
 }
 
@@ -70,6 +121,10 @@ func (m *mockedModel) SetIgnores(folder string, content []string) error {
 	return nil
 }
 
+func (m *mockedModel) PreviewIgnores(folder string, content []string) (model.IgnoresPreview, error) {
+	return model.IgnoresPreview{}, nil
+}
+
 func (m *mockedModel) PauseDevice(device protocol.DeviceID) {
 }
 
@@ -85,12 +140,52 @@ func (m *mockedModel) ScanFolders() map[string]error {
 	return nil
 }
 
+func (m *mockedModel) QueueChanges(folder string, changes []string) error {
+	return nil
+}
+
+func (m *mockedModel) FetchPlaceholders(folder string, paths []string) (int, error) {
+	return 0, nil
+}
+
 func (m *mockedModel) ScanFolderSubdirs(folder string, subs []string) error {
 	return nil
 }
 
 func (m *mockedModel) BringToFront(folder, file string) {}
 
+func (m *mockedModel) Prioritize(folder string, files, patterns []string) error {
+	return nil
+}
+
+func (m *mockedModel) FolderErrors(folder string) ([]model.FileError, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) RetryFolderErrors(folder string, ids []string) error {
+	return nil
+}
+
+func (m *mockedModel) IgnoreFolderErrors(folder string, ids []string) error {
+	return nil
+}
+
+func (m *mockedModel) FolderVersions(folder, dir string) (map[string][]versioner.FileVersion, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) FolderVersionDiff(folder, file string, versionTime time.Time) (model.VersionDiff, error) {
+	return model.VersionDiff{}, nil
+}
+
+func (m *mockedModel) FolderVersionsAt(folder, dir string, at time.Time) (map[string]time.Time, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) RestoreFolderVersions(folder string, versions map[string]time.Time) (map[string]string, error) {
+	return nil, nil
+}
+
 func (m *mockedModel) ConnectedTo(deviceID protocol.DeviceID) bool {
 	return false
 }
@@ -103,6 +198,18 @@ func (m *mockedModel) LocalSize(folder string) db.Counts {
 	return db.Counts{}
 }
 
+func (m *mockedModel) QuotaExceeded(folder string) bool {
+	return false
+}
+
+func (m *mockedModel) WriteOnceViolations(folder string) int {
+	return 0
+}
+
+func (m *mockedModel) FolderContentStatistics(folder string, largest, days int) (model.FolderContentStatistics, error) {
+	return model.FolderContentStatistics{}, nil
+}
+
 func (m *mockedModel) CurrentSequence(folder string) (int64, bool) {
 	return 0, false
 }
@@ -114,3 +221,19 @@ func (m *mockedModel) RemoteSequence(folder string) (int64, bool) {
 func (m *mockedModel) State(folder string) (string, time.Time, error) {
 	return "", time.Time{}, nil
 }
+
+func (m *mockedModel) PendingDependencies(folder string) []string {
+	return nil
+}
+
+func (m *mockedModel) FolderCapabilities() map[string]fs.Capabilities {
+	return nil
+}
+
+func (m *mockedModel) RemoteClusterConfig(device protocol.DeviceID) (protocol.ClusterConfig, bool) {
+	return protocol.ClusterConfig{}, false
+}
+
+func (m *mockedModel) SnapshotFolder(folder, name string, devices []protocol.DeviceID) (config.FolderConfiguration, error) {
+	return config.FolderConfiguration{}, nil
+}
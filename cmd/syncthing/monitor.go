@@ -17,7 +17,9 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/sync"
 )
 
@@ -61,6 +63,18 @@ func monitorMain(runtimeOptions RuntimeOptions) {
 	args := os.Args
 	var restarts [countRestarts]time.Time
 
+	sup := newSupervisor(args, func(apiKey string) bool {
+		// The config is read anew, to have the current keys
+		cfg, err := config.Load(locations[locConfigFile], protocol.EmptyDeviceID)
+		return err == nil && cfg.GUI().IsValidAPIKey(apiKey)
+	})
+	dst = io.MultiWriter(dst, sup.log)
+	if runtimeOptions.monitorAddress != "" {
+		if err := sup.Serve(runtimeOptions.monitorAddress); err != nil {
+			l.Warnln("Monitor control endpoint:", err)
+		}
+	}
+
 	stopSign := make(chan os.Signal, 1)
 	sigTerm := syscall.Signal(15)
 	signal.Notify(stopSign, os.Interrupt, sigTerm)
@@ -97,6 +111,7 @@ func monitorMain(runtimeOptions RuntimeOptions) {
 		if err != nil {
 			l.Fatalln(err)
 		}
+		sup.childStarted(cmd.Process.Pid, restarts[:])
 
 		stdoutMut.Lock()
 		stdoutFirstLines = make([]string, 0, 10)
@@ -127,8 +142,7 @@ func monitorMain(runtimeOptions RuntimeOptions) {
 		select {
 		case s := <-stopSign:
 			l.Infof("Signal %d received; exiting", s)
-			cmd.Process.Kill()
-			<-exit
+			stopChild(cmd, stopSign, exit)
 			return
 
 		case s := <-restartSign:
@@ -136,6 +150,14 @@ func monitorMain(runtimeOptions RuntimeOptions) {
 			cmd.Process.Signal(sigHup)
 			err = <-exit
 
+		case <-sup.restart:
+			l.Infoln("Restart requested; restarting")
+			if err := cmd.Process.Signal(sigHup); err != nil {
+				// Not supported on Windows
+				cmd.Process.Kill()
+			}
+			err = <-exit
+
 		case err = <-exit:
 			if err == nil {
 				// Successful exit indicates an intentional shutdown
@@ -156,6 +178,7 @@ func monitorMain(runtimeOptions RuntimeOptions) {
 			}
 		}
 
+		sup.childExited(err)
 		l.Infoln("Syncthing exited:", err)
 		time.Sleep(1 * time.Second)
 
@@ -168,6 +191,28 @@ func monitorMain(runtimeOptions RuntimeOptions) {
 	}
 }
 
+// stopChild stops the Syncthing process and waits for it to exit. A
+// database migration in progress is asked to stop safely instead, unless
+// another stop signal is received while waiting for it, as killing the
+// process halfway through would lose the progress since the last
+// checkpoint.
+func stopChild(cmd *exec.Cmd, stopSign chan os.Signal, exit chan error) {
+	status, ok := readMigrationStatus(locations[locMigration])
+	if !ok || cmd.Process.Signal(os.Interrupt) != nil {
+		cmd.Process.Kill()
+		<-exit
+		return
+	}
+
+	l.Infof("%s; waiting for it to stop safely (signal again to kill)", migrationProgressString(status))
+	select {
+	case <-exit:
+	case <-stopSign:
+		cmd.Process.Kill()
+		<-exit
+	}
+}
+
 func copyStderr(stderr io.Reader, dst io.Writer) {
 	br := bufio.NewReader(stderr)
 
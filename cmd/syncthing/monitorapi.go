@@ -0,0 +1,310 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+const supervisorLogLines = 1000
+
+// The supervisor keeps track of the Syncthing process run by the monitor,
+// and serves its status, recent output and a few actions on the monitor's
+// control address. That lets wrappers and service managers ask the monitor
+// for things rather than signal it blindly. On a TCP address, which any
+// local process or web page may reach, requests must carry the GUI's API
+// key in the X-API-Key header and be addressed to the loopback address.
+type supervisor struct {
+	args        []string // the monitor's command line
+	restart     chan struct{}
+	log         *lineBuffer
+	apiKeyValid func(apiKey string) bool
+
+	mut          sync.Mutex
+	childPID     int // zero when not running
+	startedAt    time.Time
+	starts       int
+	recentStarts []time.Time
+	lastExit     string
+	lastExitTime time.Time
+}
+
+// supervisorStatus is served at /rest/monitor/status.
+type supervisorStatus struct {
+	PID          int         `json:"pid"`
+	Version      string      `json:"version"`
+	Running      bool        `json:"running"`
+	ChildPID     int         `json:"childPID"`
+	ChildStarted time.Time   `json:"childStarted"`
+	Starts       int         `json:"starts"`
+	RecentStarts []time.Time `json:"recentStarts"` // within the crash loop window
+	CrashLoop    bool        `json:"crashLoop"`    // the monitor gives up if the process exits again within the window
+	LastExit     string      `json:"lastExit"`
+	LastExitTime time.Time   `json:"lastExitTime"`
+}
+
+func newSupervisor(args []string, apiKeyValid func(apiKey string) bool) *supervisor {
+	return &supervisor{
+		args:        args,
+		restart:     make(chan struct{}, 1),
+		log:         newLineBuffer(supervisorLogLines),
+		apiKeyValid: apiKeyValid,
+		mut:         sync.NewMutex(),
+	}
+}
+
+// childStarted records the start of the process, given the times of the
+// latest starts.
+func (s *supervisor) childStarted(pid int, starts []time.Time) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.childPID = pid
+	s.startedAt = time.Now()
+	s.starts++
+	s.recentStarts = s.recentStarts[:0]
+	for _, t := range starts {
+		if !t.IsZero() && time.Since(t) < loopThreshold {
+			s.recentStarts = append(s.recentStarts, t)
+		}
+	}
+
+	// A restart requested while not running is done by this start.
+	select {
+	case <-s.restart:
+	default:
+	}
+}
+
+func (s *supervisor) childExited(err error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.childPID = 0
+	s.lastExit = "exited successfully"
+	if err != nil {
+		s.lastExit = err.Error()
+	}
+	s.lastExitTime = time.Now()
+}
+
+func (s *supervisor) status() supervisorStatus {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return supervisorStatus{
+		PID:          os.Getpid(),
+		Version:      Version,
+		Running:      s.childPID != 0,
+		ChildPID:     s.childPID,
+		ChildStarted: s.startedAt,
+		Starts:       s.starts,
+		RecentStarts: append([]time.Time{}, s.recentStarts...),
+		CrashLoop:    len(s.recentStarts) >= countRestarts,
+		LastExit:     s.lastExit,
+		LastExitTime: s.lastExitTime,
+	}
+}
+
+// Serve serves the control endpoint on the address, which is either a
+// loopback address and port, or the path of a Unix socket.
+func (s *supervisor) Serve(addr string) error {
+	listener, err := supervisorListen(addr)
+	if err != nil {
+		return err
+	}
+	l.Infoln("Monitor control endpoint listening on", listener.Addr())
+
+	handler := s.handler()
+	if listener.Addr().Network() == "tcp" {
+		handler = s.authMiddleware(handler)
+	}
+	srv := &http.Server{
+		Handler:     handler,
+		ReadTimeout: 10 * time.Second,
+	}
+	go srv.Serve(listener)
+	return nil
+}
+
+func supervisorListen(addr string) (net.Listener, error) {
+	if strings.ContainsRune(addr, '/') {
+		// Only the owner may connect. A stale socket from a previous run
+		// would prevent listening, but anything else at the address is
+		// left alone.
+		if info, err := os.Lstat(addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(addr)
+		}
+		listener, err := net.Listen("unix", addr)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(addr, 0600); err != nil {
+			listener.Close()
+			return nil, err
+		}
+		return listener, nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, errors.New("the monitor control address must be a loopback address")
+	}
+	return net.Listen("tcp", addr)
+}
+
+func (s *supervisor) handler() http.Handler {
+	getRestMux := http.NewServeMux()
+	getRestMux.HandleFunc("/rest/monitor/status", s.getStatus) // -
+	getRestMux.HandleFunc("/rest/monitor/log", s.getLog)       // [lines]
+
+	postRestMux := http.NewServeMux()
+	postRestMux.HandleFunc("/rest/monitor/restart", s.postRestart) // -
+	postRestMux.HandleFunc("/rest/monitor/upgrade", s.postUpgrade) // -
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			getRestMux.ServeHTTP(w, r)
+		case "POST":
+			postRestMux.ServeHTTP(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// authMiddleware refuses requests without a valid API key, and those for
+// another host name, which a web page rebinding its own name to the
+// loopback address would send.
+func (s *supervisor) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !addressIsLocalhost(r.Host) {
+			http.Error(w, "Host check error", http.StatusForbidden)
+			return
+		}
+		if !s.apiKeyValid(r.Header.Get("X-API-Key")) {
+			http.Error(w, "Not Authorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *supervisor) getStatus(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, s.status())
+}
+
+func (s *supervisor) getLog(w http.ResponseWriter, r *http.Request) {
+	n := supervisorLogLines
+	if lines := r.URL.Query().Get("lines"); lines != "" {
+		var err error
+		if n, err = strconv.Atoi(lines); err != nil || n < 0 {
+			http.Error(w, "Invalid lines", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, line := range s.log.Lines(n) {
+		fmt.Fprint(w, line)
+	}
+}
+
+func (s *supervisor) postRestart(w http.ResponseWriter, r *http.Request) {
+	if !s.status().Running {
+		http.Error(w, "Syncthing is not running", http.StatusServiceUnavailable)
+		return
+	}
+	select {
+	case s.restart <- struct{}{}:
+	default:
+		// Already requested
+	}
+	sendJSON(w, map[string]string{"ok": "restarting"})
+}
+
+// postUpgrade runs an upgrade the same way as the -upgrade option does.
+// Syncthing, if running, does the upgrade and exits to have the monitor
+// restarted with the new version.
+func (s *supervisor) postUpgrade(w http.ResponseWriter, r *http.Request) {
+	args := append(append([]string{}, s.args[1:]...), "-upgrade")
+	out, err := exec.Command(s.args[0], args...).CombinedOutput()
+	code := 0
+	if exiterr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
+			code = status.ExitStatus()
+		}
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch code {
+	case 0:
+		sendJSON(w, map[string]string{"ok": "upgraded"})
+	case exitUpgrading:
+		sendJSON(w, map[string]string{"ok": "upgrading"})
+	case exitNoUpgradeAvailable:
+		sendJSON(w, map[string]string{"ok": "no upgrade available"})
+	default:
+		http.Error(w, strings.TrimSpace(string(out)), http.StatusInternalServerError)
+	}
+}
+
+// A lineBuffer is an io.Writer keeping the last lines written to it, one
+// line per write.
+type lineBuffer struct {
+	lines []string
+	next  int // where the next line goes, once full
+	mut   sync.Mutex
+}
+
+func newLineBuffer(size int) *lineBuffer {
+	return &lineBuffer{
+		lines: make([]string, 0, size),
+		mut:   sync.NewMutex(),
+	}
+}
+
+func (b *lineBuffer) Write(bs []byte) (int, error) {
+	b.mut.Lock()
+	if len(b.lines) < cap(b.lines) {
+		b.lines = append(b.lines, string(bs))
+	} else {
+		b.lines[b.next] = string(bs)
+		b.next = (b.next + 1) % len(b.lines)
+	}
+	b.mut.Unlock()
+	return len(bs), nil
+}
+
+// Lines returns the last n lines, oldest first.
+func (b *lineBuffer) Lines(n int) []string {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	lines := append(append([]string{}, b.lines[b.next:]...), b.lines[:b.next]...)
+	if n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
@@ -0,0 +1,163 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestLineBuffer(t *testing.T) {
+	b := newLineBuffer(3)
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(b, "line %d\n", i)
+	}
+
+	if lines := fmt.Sprint(b.Lines(10)); lines != "[line 2\n line 3\n line 4\n]" {
+		t.Errorf("Unexpected lines %q", lines)
+	}
+	if lines := fmt.Sprint(b.Lines(1)); lines != "[line 4\n]" {
+		t.Errorf("Unexpected last line %q", lines)
+	}
+}
+
+func TestSupervisorStatus(t *testing.T) {
+	s := newSupervisor([]string{"syncthing"}, nil)
+	handler := s.handler()
+
+	get := func(url string, code int) []byte {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != code {
+			t.Fatalf("GET %s: unexpected code %d", url, w.Code)
+		}
+		return w.Body.Bytes()
+	}
+	status := func() supervisorStatus {
+		var status supervisorStatus
+		if err := json.Unmarshal(get("/rest/monitor/status", http.StatusOK), &status); err != nil {
+			t.Fatal(err)
+		}
+		return status
+	}
+
+	// A restart needs a running process.
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/rest/monitor/restart", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected code %d restarting while not running", w.Code)
+	}
+
+	// Four quick starts are a crash loop.
+
+	now := time.Now()
+	starts := []time.Time{now.Add(-2 * loopThreshold), now.Add(-2 * time.Second), now.Add(-time.Second), now}
+	s.childStarted(42, starts)
+	st := status()
+	if !st.Running || st.ChildPID != 42 || len(st.RecentStarts) != 3 || st.CrashLoop {
+		t.Errorf("Unexpected status %+v", st)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/rest/monitor/restart", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Unexpected code %d restarting", w.Code)
+	}
+	select {
+	case <-s.restart:
+	default:
+		t.Error("Restart not requested")
+	}
+
+	s.childExited(errors.New("exit status 1"))
+	starts[0] = now.Add(-3 * time.Second)
+	s.childStarted(43, starts)
+	if st := status(); !st.CrashLoop || st.Starts != 2 || st.LastExit != "exit status 1" {
+		t.Errorf("Unexpected status %+v", st)
+	}
+
+	// The output is kept.
+
+	fmt.Fprintln(s.log, "first")
+	fmt.Fprintln(s.log, "second")
+	if out := string(get("/rest/monitor/log?lines=1", http.StatusOK)); out != "second\n" {
+		t.Errorf("Unexpected log %q", out)
+	}
+	get("/rest/monitor/log?lines=x", http.StatusBadRequest)
+}
+
+func TestSupervisorAuth(t *testing.T) {
+	s := newSupervisor([]string{"syncthing"}, func(apiKey string) bool {
+		return apiKey == "key"
+	})
+	handler := s.authMiddleware(s.handler())
+
+	for _, tc := range []struct {
+		host, apiKey string
+		code         int
+	}{
+		{"127.0.0.1:8385", "key", http.StatusOK},
+		{"localhost:8385", "key", http.StatusOK},
+		{"127.0.0.1:8385", "", http.StatusUnauthorized},
+		{"127.0.0.1:8385", "wrong", http.StatusUnauthorized},
+		{"evil.example.com:8385", "key", http.StatusForbidden},
+	} {
+		r := httptest.NewRequest("GET", "/rest/monitor/status", nil)
+		r.Host = tc.host
+		if tc.apiKey != "" {
+			r.Header.Set("X-API-Key", tc.apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tc.code {
+			t.Errorf("Host %s, API key %q: got %d, expected %d", tc.host, tc.apiKey, w.Code, tc.code)
+		}
+	}
+}
+
+func TestSupervisorListenKeepsFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix sockets on Windows")
+	}
+
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Something other than a socket at the address isn't removed to make
+	// way for one.
+	addr := filepath.Join(dir, "monitor")
+	if err := ioutil.WriteFile(addr, []byte("precious"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if listener, err := supervisorListen(addr); err == nil {
+		listener.Close()
+		t.Fatal("Listened in place of a regular file")
+	}
+	if bs, err := ioutil.ReadFile(addr); err != nil || string(bs) != "precious" {
+		t.Fatal("Regular file at the address was touched:", err)
+	}
+
+	os.Remove(addr)
+	listener, err := supervisorListen(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+}
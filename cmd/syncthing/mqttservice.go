@@ -0,0 +1,286 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/mqtt"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+const (
+	mqttRetryInterval = 30 * time.Second // between connection attempts, and pings when idle
+	mqttTimeout       = 10 * time.Second
+	mqttKeepAlive     = time.Minute
+)
+
+// The events that change what's published.
+const mqttEvents = events.StateChanged | events.FolderSummary | events.FolderCompletion |
+	events.FolderPaused | events.FolderResumed |
+	events.DeviceConnected | events.DeviceDisconnected | events.DevicePaused | events.DeviceResumed
+
+type mqttConfig interface {
+	MQTT() config.MQTTConfiguration
+}
+
+type mqttPublisher interface {
+	Publish(topic string, message []byte, qos byte, retain bool) error
+	Ping() error
+	Close() error
+}
+
+// The MQTT service publishes the state of folders and devices to a broker,
+// as it changes, for home automation and the like to act on. Each piece of
+// state has a topic of its own under the configured prefix:
+//
+//	<prefix>/status                           online or offline
+//	<prefix>/folder/<id>/state                idle, scanning, syncing, ...
+//	<prefix>/folder/<id>/summary              the folder summary, as JSON
+//	<prefix>/folder/<id>/paused               true or false
+//	<prefix>/folder/<id>/completion/<device>  percent of the folder the device has
+//	<prefix>/device/<id>/connected            true or false
+//	<prefix>/device/<id>/paused               true or false
+//
+// While the broker can't be reached the latest message for each topic is
+// kept, and published once it can.
+type mqttService struct {
+	myID        protocol.DeviceID
+	cfg         mqttConfig
+	dial        func(broker string, opts mqtt.Options) (mqttPublisher, error)
+	client      mqttPublisher
+	clientCfg   config.MQTTConfiguration // that the client was connected with
+	pending     map[string][]byte        // topic -> message
+	nextAttempt time.Time                // at connecting
+	failing     bool
+	stop        chan struct{} // signals time to stop
+	started     chan struct{} // signals startup complete
+}
+
+func newMQTTService(myID protocol.DeviceID, cfg mqttConfig) *mqttService {
+	return &mqttService{
+		myID:    myID,
+		cfg:     cfg,
+		dial:    dialMQTT,
+		pending: make(map[string][]byte),
+		stop:    make(chan struct{}),
+		started: make(chan struct{}),
+	}
+}
+
+// Serve runs the MQTT service.
+func (s *mqttService) Serve() {
+	sub := events.Default.Subscribe(mqttEvents)
+	defer events.Default.Unsubscribe(sub)
+	defer s.disconnect()
+
+	ticker := time.NewTicker(mqttRetryInterval)
+	defer ticker.Stop()
+
+	select {
+	case <-s.started:
+		// The started channel has already been closed; do nothing.
+	default:
+		close(s.started)
+	}
+
+	for {
+		select {
+		case ev := <-sub.C():
+			s.handleEvent(ev)
+			s.flush(time.Now())
+		case now := <-ticker.C:
+			if s.client != nil && len(s.pending) == 0 {
+				if err := s.client.Ping(); err != nil {
+					l.Debugln("MQTT ping:", err)
+					s.disconnect()
+				}
+			}
+			s.flush(now)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the MQTT service.
+func (s *mqttService) Stop() {
+	close(s.stop)
+}
+
+// WaitForStart returns once the MQTT service is ready to receive events, or
+// immediately if it's already running.
+func (s *mqttService) WaitForStart() {
+	<-s.started
+}
+
+// handleEvent queues the message for the state the event changes, if
+// publishing is enabled.
+func (s *mqttService) handleEvent(ev events.Event) {
+	cfg := s.cfg.MQTT()
+	if !cfg.Enabled {
+		return
+	}
+
+	if levels, msg := mqttMessage(ev); levels != nil {
+		s.pending[mqttTopic(cfg, levels...)] = msg
+	}
+}
+
+// flush publishes the queued messages, connecting to the broker first if
+// need be. Messages that fail to publish are kept for the next flush.
+func (s *mqttService) flush(now time.Time) {
+	cfg := s.cfg.MQTT()
+	if !cfg.Enabled || cfg.Broker == "" {
+		s.disconnect()
+		for topic := range s.pending {
+			delete(s.pending, topic)
+		}
+		return
+	}
+	if s.client != nil && cfg != s.clientCfg {
+		s.disconnect()
+	}
+	if len(s.pending) == 0 && s.client != nil {
+		return
+	}
+	if s.client == nil && !s.connect(cfg, now) {
+		return
+	}
+
+	for topic, msg := range s.pending {
+		if err := s.client.Publish(topic, msg, byte(cfg.QoS), cfg.Retain); err != nil {
+			l.Debugf("MQTT publish to %s: %v", topic, err)
+			s.disconnect()
+			return
+		}
+		delete(s.pending, topic)
+	}
+}
+
+// connect connects to the broker, unless the last attempt was too recent,
+// and announces that we're online. A failure is warned about the first
+// time only.
+func (s *mqttService) connect(cfg config.MQTTConfiguration, now time.Time) bool {
+	if now.Before(s.nextAttempt) {
+		return false
+	}
+	s.nextAttempt = now.Add(mqttRetryInterval)
+
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = "syncthing-" + s.myID.Short().String()
+	}
+	statusTopic := mqttTopic(cfg, "status")
+	client, err := s.dial(cfg.Broker, mqtt.Options{
+		ClientID:    clientID,
+		Username:    cfg.Username,
+		Password:    resolveSecret(cfg.Password),
+		KeepAlive:   mqttKeepAlive,
+		Timeout:     mqttTimeout,
+		WillTopic:   statusTopic,
+		WillMessage: []byte("offline"),
+		WillQoS:     byte(cfg.QoS),
+		WillRetain:  true,
+	})
+	if err == nil {
+		err = client.Publish(statusTopic, []byte("online"), byte(cfg.QoS), true)
+		if err != nil {
+			client.Close()
+		}
+	}
+	if err != nil {
+		if !s.failing {
+			l.Warnf("Connecting to MQTT broker %s: %v", cfg.Broker, err)
+		} else {
+			l.Debugf("Connecting to MQTT broker %s: %v", cfg.Broker, err)
+		}
+		s.failing = true
+		return false
+	}
+
+	if s.failing {
+		l.Infoln("Connected to MQTT broker", cfg.Broker)
+	}
+	s.failing = false
+	s.client = client
+	s.clientCfg = cfg
+	return true
+}
+
+// disconnect disconnects from the broker, if connected, letting the
+// subscribers know we're offline.
+func (s *mqttService) disconnect() {
+	if s.client == nil {
+		return
+	}
+	s.client.Publish(mqttTopic(s.clientCfg, "status"), []byte("offline"), 0, true)
+	s.client.Close()
+	s.client = nil
+}
+
+// mqttMessage returns the message for the state the event changes, and
+// its topic as levels under the prefix, or nil levels if there is none.
+func mqttMessage(ev events.Event) ([]string, []byte) {
+	switch ev.Type {
+	case events.StateChanged:
+		data := ev.Data.(map[string]interface{})
+		return []string{"folder", data["folder"].(string), "state"}, []byte(data["to"].(string))
+
+	case events.FolderSummary:
+		data := ev.Data.(map[string]interface{})
+		bs, err := json.Marshal(data["summary"])
+		if err != nil {
+			l.Debugln("MQTT folder summary:", err)
+			return nil, nil
+		}
+		return []string{"folder", data["folder"].(string), "summary"}, bs
+
+	case events.FolderCompletion:
+		data := ev.Data.(map[string]interface{})
+		completion := strconv.FormatFloat(data["completion"].(float64), 'f', -1, 64)
+		return []string{"folder", data["folder"].(string), "completion", data["device"].(string)}, []byte(completion)
+
+	case events.FolderPaused, events.FolderResumed:
+		data := ev.Data.(map[string]string)
+		return []string{"folder", data["id"], "paused"}, []byte(strconv.FormatBool(ev.Type == events.FolderPaused))
+
+	case events.DeviceConnected, events.DeviceDisconnected:
+		data := ev.Data.(map[string]string)
+		return []string{"device", data["id"], "connected"}, []byte(strconv.FormatBool(ev.Type == events.DeviceConnected))
+
+	case events.DevicePaused, events.DeviceResumed:
+		data := ev.Data.(map[string]string)
+		return []string{"device", data["device"], "paused"}, []byte(strconv.FormatBool(ev.Type == events.DevicePaused))
+	}
+
+	return nil, nil
+}
+
+// mqttTopic returns the topic under the configured prefix, with the levels
+// made safe to use: the separator and wildcards are replaced.
+func mqttTopic(cfg config.MQTTConfiguration, levels ...string) string {
+	parts := make([]string, 0, len(levels)+1)
+	if prefix := strings.Trim(cfg.TopicPrefix, "/"); prefix != "" {
+		parts = append(parts, prefix)
+	}
+	for _, level := range levels {
+		parts = append(parts, mqttLevelEscaper.Replace(level))
+	}
+	return strings.Join(parts, "/")
+}
+
+var mqttLevelEscaper = strings.NewReplacer("/", "_", "+", "_", "#", "_")
+
+func dialMQTT(broker string, opts mqtt.Options) (mqttPublisher, error) {
+	return mqtt.Dial(broker, opts)
+}
@@ -0,0 +1,145 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/mqtt"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+type fakeMQTTConfig struct {
+	cfg config.MQTTConfiguration
+}
+
+func (c *fakeMQTTConfig) MQTT() config.MQTTConfiguration {
+	return c.cfg
+}
+
+type fakeMQTTClient struct {
+	published map[string]string
+	fail      bool
+	closed    bool
+}
+
+func (c *fakeMQTTClient) Publish(topic string, message []byte, qos byte, retain bool) error {
+	if c.fail {
+		return errors.New("publish failed")
+	}
+	c.published[topic] = string(message)
+	return nil
+}
+
+func (c *fakeMQTTClient) Ping() error {
+	return nil
+}
+
+func (c *fakeMQTTClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestMQTTService(t *testing.T) {
+	cfg := &fakeMQTTConfig{config.MQTTConfiguration{
+		Enabled:     true,
+		Broker:      "tcp://localhost",
+		TopicPrefix: "home/syncthing/",
+		Retain:      true,
+	}}
+	var dials int
+	var opts mqtt.Options
+	client := &fakeMQTTClient{published: make(map[string]string)}
+
+	s := newMQTTService(protocol.LocalDeviceID, cfg)
+	s.dial = func(broker string, o mqtt.Options) (mqttPublisher, error) {
+		dials++
+		opts = o
+		if broker != "tcp://localhost" {
+			return nil, errors.New("unreachable")
+		}
+		return client, nil
+	}
+
+	now := time.Now()
+	s.handleEvent(events.Event{Type: events.StateChanged, Data: map[string]interface{}{"folder": "a/b", "from": "scanning", "to": "idle"}})
+	s.handleEvent(events.Event{Type: events.DeviceConnected, Data: map[string]string{"id": "device1"}})
+	s.handleEvent(events.Event{Type: events.DeviceDisconnected, Data: map[string]string{"id": "device1", "error": "closed"}})
+	s.handleEvent(events.Event{Type: events.FolderCompletion, Data: map[string]interface{}{"folder": "default", "device": "device2", "completion": 99.5}})
+	s.handleEvent(events.Event{Type: events.FolderPaused, Data: map[string]string{"id": "default", "label": "Default"}})
+	s.handleEvent(events.Event{Type: events.FolderSummary, Data: map[string]interface{}{"folder": "default", "summary": map[string]interface{}{"needBytes": 0}}})
+	s.flush(now)
+
+	if opts.ClientID != "syncthing-"+protocol.LocalDeviceID.Short().String() {
+		t.Errorf("Unexpected client ID %q", opts.ClientID)
+	}
+	if opts.WillTopic != "home/syncthing/status" || string(opts.WillMessage) != "offline" {
+		t.Errorf("Unexpected will %q: %q", opts.WillTopic, opts.WillMessage)
+	}
+
+	expected := map[string]string{
+		"home/syncthing/status":                            "online",
+		"home/syncthing/folder/a_b/state":                  "idle",
+		"home/syncthing/device/device1/connected":          "false",
+		"home/syncthing/folder/default/completion/device2": "99.5",
+		"home/syncthing/folder/default/paused":             "true",
+		"home/syncthing/folder/default/summary":            `{"needBytes":0}`,
+	}
+	if len(client.published) != len(expected) {
+		t.Errorf("Published %d messages, expected %d: %v", len(client.published), len(expected), client.published)
+	}
+	for topic, msg := range expected {
+		if client.published[topic] != msg {
+			t.Errorf("Published %q to %s, expected %q", client.published[topic], topic, msg)
+		}
+	}
+
+	// Messages that fail to publish are kept until they can be
+
+	client.fail = true
+	s.handleEvent(events.Event{Type: events.DevicePaused, Data: map[string]string{"device": "device1"}})
+	s.flush(now)
+	if !client.closed || s.client != nil {
+		t.Fatal("Expected a disconnect after failing to publish")
+	}
+	client.fail = false
+	s.flush(now.Add(time.Second))
+	if dials != 1 {
+		t.Errorf("Expected no attempt to reconnect this soon, got %d dials", dials)
+	}
+	s.flush(now.Add(mqttRetryInterval))
+	if dials != 2 {
+		t.Errorf("Expected an attempt to reconnect, got %d dials", dials)
+	}
+	if client.published["home/syncthing/device/device1/paused"] != "true" {
+		t.Error("Expected the pending message to be published after reconnecting")
+	}
+
+	// Changing the broker reconnects; an unreachable one keeps what's pending
+
+	cfg.cfg.Broker = "tcp://elsewhere"
+	s.handleEvent(events.Event{Type: events.DeviceResumed, Data: map[string]string{"device": "device1"}})
+	s.flush(now.Add(2 * mqttRetryInterval))
+	if s.client != nil || dials != 3 {
+		t.Errorf("Expected a failed attempt to connect to the new broker, got %d dials", dials)
+	}
+	if len(s.pending) != 1 {
+		t.Errorf("Expected one pending message, got %d", len(s.pending))
+	}
+
+	// Disabling drops what's pending
+
+	cfg.cfg.Enabled = false
+	s.flush(now.Add(3 * mqttRetryInterval))
+	if len(s.pending) != 0 || dials != 3 {
+		t.Errorf("Expected nothing pending and no attempt to connect, got %d pending and %d dials", len(s.pending), dials)
+	}
+}
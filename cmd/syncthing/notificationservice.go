@@ -0,0 +1,308 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/connections"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/logger"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// Notification severities
+const (
+	severityInfo    = "info"
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+const (
+	maxNotifications = 250
+	// Warnings logged while the service is busy are dropped beyond this.
+	notificationWarningBuffer = 64
+)
+
+// A notification is something the user should know about, such as an error
+// or a device asking to connect. It is kept, across restarts, until deleted
+// or until it falls off the end of the list. Raising a notification with the
+// same kind and key as an unacknowledged one updates that one instead of
+// adding another.
+type notification struct {
+	ID           int       `json:"id"`
+	Time         time.Time `json:"time"`     // when first raised
+	LastTime     time.Time `json:"lastTime"` // when last raised
+	Count        int       `json:"count"`    // times raised
+	Severity     string    `json:"severity"` // "info", "warning" or "error"
+	Kind         string    `json:"kind"`     // e.g. "pendingDevice"
+	Key          string    `json:"key"`      // identifies the subject within the kind
+	Message      string    `json:"message"`
+	Acknowledged bool      `json:"acknowledged"`
+}
+
+// The notification service collects notifications from events and logged
+// warnings, and persists them to a file.
+type notificationService struct {
+	path          string // where the notifications are kept; not persisted if empty
+	notifications []notification
+	nextID        int
+	mut           sync.Mutex
+	warnings      chan string
+	stop          chan struct{} // signals time to stop
+	started       chan struct{} // signals startup complete
+}
+
+func newNotificationService(path string) *notificationService {
+	s := &notificationService{
+		path:     path,
+		nextID:   1,
+		mut:      sync.NewMutex(),
+		warnings: make(chan string, notificationWarningBuffer),
+		stop:     make(chan struct{}),
+		started:  make(chan struct{}),
+	}
+	s.load()
+	return s
+}
+
+// Serve runs the notification service.
+func (s *notificationService) Serve() {
+	sub := events.Default.Subscribe(events.DeviceRejected | events.FolderRejected | events.FolderErrors | events.SecurityAlert)
+	defer events.Default.Unsubscribe(sub)
+
+	select {
+	case <-s.started:
+		// The started channel has already been closed; do nothing.
+	default:
+		close(s.started)
+	}
+
+	for {
+		select {
+		case ev := <-sub.C():
+			if n, ok := eventNotification(ev); ok {
+				s.Raise(n)
+			}
+		case msg := <-s.warnings:
+			s.Raise(notification{
+				Severity: severityWarning,
+				Kind:     "warning",
+				Key:      msg,
+				Message:  msg,
+			})
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the notification service.
+func (s *notificationService) Stop() {
+	close(s.stop)
+}
+
+// WaitForStart returns once the notification service is ready to receive
+// events, or immediately if it's already running.
+func (s *notificationService) WaitForStart() {
+	<-s.started
+}
+
+// warning is a logger handler that turns logged warnings into
+// notifications. As it's called with the logger locked, it must not block
+// or log anything.
+func (s *notificationService) warning(_ logger.LogLevel, msg string) {
+	select {
+	case s.warnings <- msg:
+	default:
+	}
+}
+
+// eventNotification returns the notification for the event, if any.
+func eventNotification(ev events.Event) (notification, bool) {
+	switch ev.Type {
+	case events.DeviceRejected:
+		data, ok := ev.Data.(map[string]string)
+		if !ok {
+			return notification{}, false
+		}
+		return notification{
+			Severity: severityInfo,
+			Kind:     "pendingDevice",
+			Key:      data["device"],
+			Message:  fmt.Sprintf("Device %s (%q at %s) wants to connect.", data["device"], data["name"], data["address"]),
+		}, true
+
+	case events.FolderRejected:
+		data, ok := ev.Data.(map[string]string)
+		if !ok {
+			return notification{}, false
+		}
+		return notification{
+			Severity: severityInfo,
+			Kind:     "pendingFolder",
+			Key:      data["device"] + "/" + data["folder"],
+			Message:  fmt.Sprintf("Device %s wants to share folder %q (%s).", data["device"], data["folderLabel"], data["folder"]),
+		}, true
+
+	case events.FolderErrors:
+		data, ok := ev.Data.(map[string]interface{})
+		if !ok {
+			return notification{}, false
+		}
+		folder, _ := data["folder"].(string)
+		errs := reflect.ValueOf(data["errors"])
+		if errs.Kind() != reflect.Slice || errs.Len() == 0 {
+			return notification{}, false
+		}
+		return notification{
+			Severity: severityError,
+			Kind:     "folderErrors",
+			Key:      folder,
+			Message:  fmt.Sprintf("Folder %q failed to sync %d items.", folder, errs.Len()),
+		}, true
+
+	case events.SecurityAlert:
+		alert, ok := ev.Data.(connections.SecurityAlert)
+		if !ok {
+			return notification{}, false
+		}
+		return notification{
+			Severity: severityError,
+			Kind:     "securityAlert",
+			Key:      alert.Kind + "/" + alert.Device.String() + "/" + alert.Resembles.String(),
+			Message:  alert.Message,
+		}, true
+	}
+
+	return notification{}, false
+}
+
+// Raise records the notification and returns it as stored.
+func (s *notificationService) Raise(n notification) notification {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	now := time.Now()
+	for i := range s.notifications {
+		existing := &s.notifications[i]
+		if !existing.Acknowledged && existing.Kind == n.Kind && existing.Key == n.Key {
+			existing.Severity = n.Severity
+			existing.Message = n.Message
+			existing.LastTime = now
+			existing.Count++
+			s.save()
+			return *existing
+		}
+	}
+
+	n.ID = s.nextID
+	s.nextID++
+	n.Time = now
+	n.LastTime = now
+	n.Count = 1
+	n.Acknowledged = false
+	s.notifications = append(s.notifications, n)
+	if len(s.notifications) > maxNotifications {
+		s.notifications = s.notifications[len(s.notifications)-maxNotifications:]
+	}
+	s.save()
+	return n
+}
+
+// List returns the notifications, oldest first, optionally only the
+// unacknowledged ones.
+func (s *notificationService) List(unacknowledged bool) []notification {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	res := make([]notification, 0, len(s.notifications))
+	for _, n := range s.notifications {
+		if !unacknowledged || !n.Acknowledged {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// Acknowledge marks the notification with the given ID, or all of them if
+// the ID is zero, as seen. It returns false if there is no such notification.
+func (s *notificationService) Acknowledge(id int) bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	found := false
+	for i := range s.notifications {
+		if id == 0 || s.notifications[i].ID == id {
+			s.notifications[i].Acknowledged = true
+			found = true
+		}
+	}
+	if found {
+		s.save()
+	}
+	return found || id == 0
+}
+
+// Delete removes the notification with the given ID. It returns false if
+// there is no such notification.
+func (s *notificationService) Delete(id int) bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for i, n := range s.notifications {
+		if n.ID == id {
+			s.notifications = append(s.notifications[:i], s.notifications[i+1:]...)
+			s.save()
+			return true
+		}
+	}
+	return false
+}
+
+func (s *notificationService) load() {
+	if s.path == "" {
+		return
+	}
+	fd, err := os.Open(s.path)
+	if err != nil {
+		return
+	}
+	defer fd.Close()
+
+	if err := json.NewDecoder(fd).Decode(&s.notifications); err != nil {
+		l.Infoln("Loading notifications:", err)
+		s.notifications = nil
+		return
+	}
+	for _, n := range s.notifications {
+		if n.ID >= s.nextID {
+			s.nextID = n.ID + 1
+		}
+	}
+}
+
+// save writes the notifications to disk. Errors are ignored, as there isn't
+// much to do about them and logging them would raise yet another
+// notification.
+func (s *notificationService) save() {
+	if s.path == "" {
+		return
+	}
+	fd, err := osutil.CreateAtomic(s.path)
+	if err != nil {
+		return
+	}
+	// A failed write makes Close fail as well, leaving the old file in place.
+	json.NewEncoder(fd).Encode(s.notifications)
+	fd.Close()
+}
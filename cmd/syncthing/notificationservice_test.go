@@ -0,0 +1,110 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/logger"
+)
+
+func TestNotificationService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "notifications.json")
+
+	s := newNotificationService(path)
+
+	first := s.Raise(notification{Severity: severityError, Kind: "folderErrors", Key: "default", Message: "one"})
+	second := s.Raise(notification{Severity: severityError, Kind: "folderErrors", Key: "default", Message: "two"})
+	other := s.Raise(notification{Severity: severityInfo, Kind: "pendingDevice", Key: "device", Message: "device"})
+
+	// Raising the same thing again updates the notification
+
+	if second.ID != first.ID || second.Count != 2 || second.Message != "two" {
+		t.Errorf("Expected an update of %+v, got %+v", first, second)
+	}
+	if other.ID == first.ID {
+		t.Error("Expected a new notification")
+	}
+	if list := s.List(false); len(list) != 2 {
+		t.Fatalf("Unexpected notifications %+v", list)
+	}
+
+	// ... unless it was acknowledged
+
+	if !s.Acknowledge(first.ID) {
+		t.Fatal("Failed to acknowledge")
+	}
+	third := s.Raise(notification{Severity: severityError, Kind: "folderErrors", Key: "default", Message: "three"})
+	if third.ID == first.ID || third.Count != 1 {
+		t.Errorf("Expected a new notification, got %+v", third)
+	}
+	if list := s.List(true); len(list) != 2 || list[0].ID != other.ID || list[1].ID != third.ID {
+		t.Errorf("Unexpected unacknowledged notifications %+v", list)
+	}
+
+	if !s.Delete(other.ID) || s.Delete(other.ID) {
+		t.Error("Unexpected delete result")
+	}
+	if s.Acknowledge(other.ID) {
+		t.Error("Acknowledged deleted notification")
+	}
+
+	// The notifications survive a restart, and IDs aren't reused
+
+	s = newNotificationService(path)
+	list := s.List(false)
+	if len(list) != 2 || list[0].ID != first.ID || !list[0].Acknowledged || list[1].ID != third.ID || list[1].Acknowledged {
+		t.Fatalf("Unexpected notifications after reload %+v", list)
+	}
+	if n := s.Raise(notification{Kind: "user", Message: "new"}); n.ID <= third.ID {
+		t.Errorf("Reused ID %d", n.ID)
+	}
+
+	if !s.Acknowledge(0) || len(s.List(true)) != 0 {
+		t.Error("Failed to acknowledge all")
+	}
+}
+
+func TestNotificationServiceSources(t *testing.T) {
+	s := newNotificationService("")
+	go s.Serve()
+	defer s.Stop()
+	s.WaitForStart()
+
+	events.Default.Log(events.DeviceRejected, map[string]string{
+		"name":    "stranger",
+		"device":  "AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR",
+		"address": "192.0.2.42:22000",
+	})
+	s.warning(logger.LevelWarn, "something went wrong")
+
+	// Wait for the service to pick them up
+	for i := 0; i < 100 && len(s.List(false)) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	kinds := make(map[string]notification)
+	for _, n := range s.List(false) {
+		kinds[n.Kind] = n
+	}
+	if n, ok := kinds["pendingDevice"]; !ok || n.Key != "AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR" || n.Severity != severityInfo {
+		t.Errorf("Missing or unexpected pending device notification %+v", n)
+	}
+	if n, ok := kinds["warning"]; !ok || n.Message != "something went wrong" || n.Severity != severityWarning {
+		t.Errorf("Missing or unexpected warning notification %+v", n)
+	}
+}
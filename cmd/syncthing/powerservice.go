@@ -0,0 +1,83 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/osutil"
+)
+
+const powerCheckInterval = time.Minute
+
+// The power service keeps track of whether we're running on battery, for
+// the auto power profile to switch between power saving and the balanced
+// profile.
+type powerService struct {
+	cfg       *config.Wrapper
+	onBattery func() (bool, error)
+	stop      chan struct{} // signals time to stop
+	started   chan struct{} // signals startup complete
+}
+
+func newPowerService(cfg *config.Wrapper) *powerService {
+	return &powerService{
+		cfg:       cfg,
+		onBattery: osutil.OnBattery,
+		stop:      make(chan struct{}),
+		started:   make(chan struct{}),
+	}
+}
+
+// Serve runs the power service.
+func (s *powerService) Serve() {
+	t := time.NewTicker(powerCheckInterval)
+	defer t.Stop()
+
+	select {
+	case <-s.started:
+		// The started channel has already been closed; do nothing.
+	default:
+		close(s.started)
+	}
+
+	s.check()
+	for {
+		select {
+		case <-t.C:
+			s.check()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the power service.
+func (s *powerService) Stop() {
+	close(s.stop)
+}
+
+// WaitForStart returns once the power service is running, or immediately
+// if it's already running.
+func (s *powerService) WaitForStart() {
+	<-s.started
+}
+
+func (s *powerService) check() {
+	if s.cfg.Options().PowerProfile != config.PowerProfileAuto {
+		return
+	}
+	onBattery, err := s.onBattery()
+	if err != nil {
+		l.Debugln("Checking power state:", err)
+		return
+	}
+	if err := s.cfg.SetOnBattery(onBattery); err != nil {
+		l.Warnln("Switching power profile:", err)
+	}
+}
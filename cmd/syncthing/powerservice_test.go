@@ -0,0 +1,45 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+func TestPowerService(t *testing.T) {
+	cfg := config.Wrap("/dev/null", config.Configuration{
+		Options: config.OptionsConfiguration{PowerProfile: config.PowerProfileAuto},
+	})
+	onBattery := false
+
+	s := newPowerService(cfg)
+	s.onBattery = func() (bool, error) {
+		return onBattery, nil
+	}
+
+	s.check()
+	if p := cfg.Options().ActivePowerProfile; p != config.PowerProfileBalanced {
+		t.Errorf("Unexpected power profile %v on mains power", p)
+	}
+
+	onBattery = true
+	s.check()
+	if p := cfg.Options().ActivePowerProfile; p != config.PowerProfilePowerSaver {
+		t.Errorf("Unexpected power profile %v on battery", p)
+	}
+
+	// A fixed profile stays as it is
+	opts := cfg.Options()
+	opts.PowerProfile = config.PowerProfilePerformance
+	cfg.SetOptions(opts)
+	s.check()
+	if p := cfg.Options().ActivePowerProfile; p != config.PowerProfilePerformance {
+		t.Errorf("Unexpected power profile %v when set to performance", p)
+	}
+}
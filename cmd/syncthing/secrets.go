@@ -0,0 +1,51 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/secrets"
+)
+
+// The operating system's secret store, once started.
+var secretStore secrets.Store
+
+// secretName returns the name to keep the secret under. Several devices
+// may run as the same user, so it's made distinct for each.
+func secretName(what string) string {
+	return what + "-" + myID.Short().String()
+}
+
+// resolveSecret returns the config value, or the secret it refers to. A
+// secret that can't be read is empty.
+func resolveSecret(value string) string {
+	secret, err := secrets.Resolve(secretStore, value)
+	if err != nil {
+		l.Warnln("Reading secret from the keychain:", err)
+		return ""
+	}
+	return secret
+}
+
+// storeSecrets moves the GUI and SMTP passwords in the config to the secret
+// store, if so configured, leaving references to them in their place.
+func storeSecrets(cfg *config.Configuration) error {
+	if !cfg.Options.UseKeychain || secretStore == nil {
+		return nil
+	}
+	password, err := secrets.Move(secretStore, secretName("gui-password"), cfg.GUI.Password)
+	if err != nil {
+		return err
+	}
+	smtpPassword, err := secrets.Move(secretStore, secretName("smtp-password"), cfg.Alerts.SMTPPassword)
+	if err != nil {
+		return err
+	}
+	cfg.GUI.Password = password
+	cfg.Alerts.SMTPPassword = smtpPassword
+	return nil
+}
@@ -0,0 +1,283 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/ignore"
+)
+
+// The shareService is the share gateway: it serves the files behind share
+// links, read only, on the configured address. A link is the token of a
+// share followed by the path within it, and needs no other authentication.
+// Directories are listed, files downloaded. Internal and temporary files
+// are hidden, and symlinks are neither listed nor followed.
+type shareService struct {
+	cfg      shareConfig
+	certFile string
+	keyFile  string
+	stop     chan struct{}
+}
+
+type shareConfig interface {
+	Options() config.OptionsConfiguration
+	Folders() map[string]config.FolderConfiguration
+	Share(token string) (config.ShareConfiguration, bool)
+}
+
+func newShareService(cfg shareConfig, certFile, keyFile string) *shareService {
+	return &shareService{
+		cfg:      cfg,
+		certFile: certFile,
+		keyFile:  keyFile,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Serve runs the share gateway.
+func (s *shareService) Serve() {
+	opts := s.cfg.Options()
+	if opts.ShareGatewayAddress == "" {
+		<-s.stop
+		return
+	}
+
+	listener, err := net.Listen("tcp", opts.ShareGatewayAddress)
+	if err != nil {
+		l.Warnln("Starting share gateway:", err)
+		<-s.stop
+		return
+	}
+	if opts.ShareGatewayTLS {
+		cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+		if err != nil {
+			l.Warnln("Starting share gateway:", err)
+			listener.Close()
+			<-s.stop
+			return
+		}
+		listener = tls.NewListener(listener, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS10,
+		})
+	}
+	l.Infoln("Share gateway listening on", listener.Addr())
+
+	srv := http.Server{Handler: s}
+	go srv.Serve(listener)
+	<-s.stop
+	listener.Close()
+}
+
+// Stop stops the share gateway.
+func (s *shareService) Stop() {
+	close(s.stop)
+}
+
+func (s *shareService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The token is in the URL, so it mustn't be passed on to links from
+	// the files, nor the pages be indexed.
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+
+	parts := strings.SplitN(strings.TrimPrefix(path.Clean(r.URL.Path), "/"), "/", 2)
+	root, ok := s.shareRoot(parts[0])
+	if !ok {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	name := root.name
+	if len(parts) == 2 {
+		name = filepath.Join(name, filepath.FromSlash(parts[1]))
+	}
+
+	info, err := lstatNoSymlinks(root.folder, name)
+	if err != nil || ignore.IsInternal(name) || ignore.IsTemporary(name) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if !info.IsDir() {
+		fd, err := os.Open(filepath.Join(root.folder, name))
+		if err != nil {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		defer fd.Close()
+		http.ServeContent(w, r, info.Name(), info.ModTime(), fd)
+		return
+	}
+
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		http.Redirect(w, r, path.Base(r.URL.Path)+"/", http.StatusMovedPermanently)
+		return
+	}
+	s.list(w, root.folder, name, r.URL.Path)
+}
+
+// A shareRoot is what a share link gives access to: the file or directory
+// name within the folder at the path.
+type shareRoot struct {
+	folder string
+	name   string
+}
+
+// shareRoot returns what the share with the token gives access to, if it
+// exists, hasn't expired and its folder still allows share links.
+func (s *shareService) shareRoot(token string) (shareRoot, bool) {
+	share, ok := s.cfg.Share(token)
+	if !ok || token == "" || share.Expired(time.Now()) {
+		return shareRoot{}, false
+	}
+	folder, ok := s.cfg.Folders()[share.Folder]
+	if !ok || !folder.ShareLinks || folder.Paused || folder.Virtual {
+		return shareRoot{}, false
+	}
+	return shareRoot{folder: folder.Path(), name: filepath.Clean(share.Path)}, true
+}
+
+// lstatNoSymlinks returns the file info of the name within the root, as
+// long as neither it nor any of its parent directories is a symlink.
+func lstatNoSymlinks(root, name string) (os.FileInfo, error) {
+	if name == ".." || strings.HasPrefix(name, ".."+string(os.PathSeparator)) || filepath.IsAbs(name) {
+		return nil, os.ErrNotExist
+	}
+	// The folder itself may well be a symlink.
+	p := root
+	info, err := os.Stat(p)
+	for _, elem := range strings.Split(name, string(os.PathSeparator)) {
+		if err != nil {
+			return nil, err
+		}
+		if elem == "." || elem == "" {
+			continue
+		}
+		p = filepath.Join(p, elem)
+		info, err = os.Lstat(p)
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return nil, os.ErrNotExist
+		}
+	}
+	return info, err
+}
+
+type shareEntry struct {
+	Name     string
+	Href     string
+	Dir      bool
+	Size     int64
+	Modified time.Time
+}
+
+type shareEntryList []shareEntry
+
+func (l shareEntryList) Len() int      { return len(l) }
+func (l shareEntryList) Swap(a, b int) { l[a], l[b] = l[b], l[a] }
+func (l shareEntryList) Less(a, b int) bool {
+	if l[a].Dir != l[b].Dir {
+		return l[a].Dir
+	}
+	return l[a].Name < l[b].Name
+}
+
+var shareListTemplate = template.Must(template.New("list").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="referrer" content="no-referrer"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+{{range .Entries}}<tr><td><a href="{{.Href}}">{{.Name}}{{if .Dir}}/{{end}}</a></td><td>{{if not .Dir}}{{.Size}}{{end}}</td><td>{{.Modified.Format "2006-01-02 15:04"}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// list writes an HTML listing of the directory, directories first.
+func (s *shareService) list(w http.ResponseWriter, root, name, urlPath string) {
+	fd, err := os.Open(filepath.Join(root, name))
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	infos, err := fd.Readdir(-1)
+	fd.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var entries []shareEntry
+	for _, info := range infos {
+		child := filepath.Join(name, info.Name())
+		if ignore.IsInternal(child) || ignore.IsTemporary(child) || info.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+		// Relative, with a "./" so that a name with a colon isn't taken
+		// for a scheme.
+		href := "./" + (&url.URL{Path: info.Name()}).EscapedPath()
+		if info.IsDir() {
+			href += "/"
+		}
+		entries = append(entries, shareEntry{
+			Name:     info.Name(),
+			Href:     href,
+			Dir:      info.IsDir(),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+	}
+	sort.Sort(shareEntryList(entries))
+
+	// The title is the path within the share, without the token.
+	title := "/"
+	if parts := strings.SplitN(strings.TrimPrefix(urlPath, "/"), "/", 2); len(parts) == 2 {
+		title += parts[1]
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	shareListTemplate.Execute(w, map[string]interface{}{
+		"Title":   title,
+		"Entries": entries,
+	})
+}
+
+func (s *shareService) String() string {
+	return fmt.Sprintf("shareService@%p", s)
+}
+
+func (s *shareService) VerifyConfiguration(from, to config.Configuration) error {
+	if to.Options.ShareGatewayAddress == "" {
+		return nil
+	}
+	_, err := net.ResolveTCPAddr("tcp", to.Options.ShareGatewayAddress)
+	return err
+}
+
+func (s *shareService) CommitConfiguration(from, to config.Configuration) bool {
+	// Listening on another address, or with or without TLS, requires a
+	// restart
+	return from.Options.ShareGatewayAddress == to.Options.ShareGatewayAddress &&
+		from.Options.ShareGatewayTLS == to.Options.ShareGatewayTLS
+}
@@ -0,0 +1,124 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+func TestShareService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"docs/a.txt", "docs/sub/b.txt", ".stversions/a.txt", "private.txt"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Symlink(filepath.Join(dir, "private.txt"), filepath.Join(dir, "docs", "link"))
+
+	cfg := config.Wrap("/dev/null", config.Configuration{
+		Folders: []config.FolderConfiguration{
+			{ID: "default", RawPath: dir, ShareLinks: true},
+			{ID: "closed", RawPath: dir},
+		},
+		Shares: []config.ShareConfiguration{
+			{Hash: config.HashAPIKey("docs"), Folder: "default", Path: "docs"},
+			{Hash: config.HashAPIKey("file"), Folder: "default", Path: "private.txt"},
+			{Hash: config.HashAPIKey("all"), Folder: "default"},
+			{Hash: config.HashAPIKey("expired"), Folder: "default", Expires: time.Now().Add(-time.Hour)},
+			{Hash: config.HashAPIKey("closed"), Folder: "closed"},
+		},
+	})
+	s := newShareService(cfg, "", "")
+
+	get := func(method, path string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/docs/a.txt", 200, "docs/a.txt"},
+		{"/docs/sub/b.txt", 200, "docs/sub/b.txt"},
+		{"/file", 200, "private.txt"},
+		{"/docs", http.StatusMovedPermanently, ""},
+		{"/docs/../private.txt", 404, ""},
+		{"/all/docs/a.txt", 200, "docs/a.txt"},
+		{"/all/.stversions/a.txt", 404, ""},
+		{"/docs/link", 404, ""},
+		{"/unknown/a.txt", 404, ""},
+		{"/expired/docs/a.txt", 404, ""},
+		{"/closed/docs/a.txt", 404, ""},
+		{"/", 404, ""},
+	}
+	for _, tc := range cases {
+		w := get("GET", tc.path)
+		if w.Code != tc.code {
+			t.Errorf("GET %s got %d, expected %d", tc.path, w.Code, tc.code)
+		} else if tc.body != "" && w.Body.String() != tc.body {
+			t.Errorf("GET %s got %q", tc.path, w.Body.String())
+		}
+	}
+
+	w := get("GET", "/docs/")
+	listing := w.Body.String()
+	if w.Code != 200 || !strings.Contains(listing, `href="./a.txt"`) || !strings.Contains(listing, `href="./sub/"`) {
+		t.Errorf("Unexpected listing %d %s", w.Code, listing)
+	}
+	if strings.Contains(listing, "link") {
+		t.Errorf("Listing shows the symlink: %s", listing)
+	}
+	if listing := get("GET", "/all/").Body.String(); strings.Contains(listing, ".stversions") || !strings.Contains(listing, `href="./docs/"`) {
+		t.Errorf("Unexpected listing of the folder: %s", listing)
+	}
+
+	if w := get("PUT", "/docs/a.txt"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT got %d", w.Code)
+	}
+}
+
+func TestShareURL(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/rest/shares", nil)
+	r.Host = "example.com:8384"
+
+	cases := []struct {
+		address string
+		tls     bool
+		url     string
+	}{
+		{"", false, "/tok/"},
+		{":8385", false, "http://example.com:8385/tok/"},
+		{"0.0.0.0:8385", true, "https://example.com:8385/tok/"},
+		{"192.0.2.1:8385", false, "http://192.0.2.1:8385/tok/"},
+	}
+	for _, tc := range cases {
+		opts := config.OptionsConfiguration{ShareGatewayAddress: tc.address, ShareGatewayTLS: tc.tls}
+		if u := shareURL(r, opts, "tok"); u != tc.url {
+			t.Errorf("shareURL for %q got %q, expected %q", tc.address, u, tc.url)
+		}
+	}
+}
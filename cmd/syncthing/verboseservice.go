@@ -178,6 +178,41 @@ func (s *verboseService) formatEvent(ev events.Event) string {
 		label := data["label"]
 		return fmt.Sprintf("Folder %v (%v) was resumed", id, label)
 
+	case events.FolderAutoPaused:
+		data := ev.Data.(map[string]string)
+		id := data["id"]
+		label := data["label"]
+		return fmt.Sprintf("Folder %v (%v) was paused by its schedule", id, label)
+
+	case events.FolderAutoResumed:
+		data := ev.Data.(map[string]string)
+		id := data["id"]
+		label := data["label"]
+		return fmt.Sprintf("Folder %v (%v) was resumed by its schedule", id, label)
+
+	case events.QuotaExceeded:
+		data := ev.Data.(map[string]interface{})
+		folder := data["folder"]
+		denied := data["denied"]
+		return fmt.Sprintf("Folder %v is at its maximum size; %v files were not pulled", folder, denied)
+
+	case events.WriteOnceViolation:
+		data := ev.Data.(map[string]interface{})
+		folder := data["folder"]
+		files := data["files"].([]string)
+		return fmt.Sprintf("Folder %v is write once; refused changes to %d existing files", folder, len(files))
+
+	case events.DeviceAutoPaused:
+		data := ev.Data.(map[string]interface{})
+		device := data["device"]
+		until := data["until"]
+		return fmt.Sprintf("Device %v has reached its monthly transfer cap; paused until %v", device, until)
+
+	case events.DeviceAutoResumed:
+		data := ev.Data.(map[string]interface{})
+		device := data["device"]
+		return fmt.Sprintf("Device %v is no longer over its monthly transfer cap", device)
+
 	case events.ListenAddressesChanged:
 		data := ev.Data.(map[string]interface{})
 		address := data["address"]
@@ -195,6 +230,25 @@ func (s *verboseService) formatEvent(ev events.Event) string {
 			success = "failed"
 		}
 		return fmt.Sprintf("Login %s for username %s.", success, username)
+
+	case events.APITokenUsed:
+		data := ev.Data.(map[string]interface{})
+		verdict := "allowed"
+		if !data["allowed"].(bool) {
+			verdict = "refused"
+		}
+		return fmt.Sprintf("API token %q %s %s %s from %s", data["token"], verdict, data["method"], data["path"], data["remoteAddress"])
+
+	case events.ManagementCommand:
+		data := ev.Data.(map[string]interface{})
+		if err := data["error"].(string); err != "" {
+			return fmt.Sprintf("Management command %s from device %v refused: %s", data["command"], data["device"], err)
+		}
+		return fmt.Sprintf("Management command %s from device %v", data["command"], data["device"])
+
+	case events.ConflictCreated:
+		data := ev.Data.(map[string]string)
+		return fmt.Sprintf("Conflict copy %s created in folder %q", data["item"], data["folder"])
 	}
 
 	return fmt.Sprintf("%s %#v", ev.Type, ev)
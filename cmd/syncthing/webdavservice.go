@@ -0,0 +1,252 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syncthing/syncthing/lib/webdav"
+)
+
+// How long a successful WebDAV login is remembered. WebDAV clients send
+// their credentials with every request, and checking them is expensive.
+const webdavLoginLifetime = time.Minute
+
+type webdavModel interface {
+	ScanFolderSubdirs(folder string, subs []string) error
+	VirtualFilesystem(folder string) fs.Filesystem
+}
+
+// The webdavService serves the folders over WebDAV on the configured
+// address, to the users that may log in to the GUI. Users limited to some
+// folders see only those, and read only users can't change anything.
+// Without GUI authentication, WebDAV is only served on localhost. On other
+// addresses it's served over HTTPS, with the GUI's certificate, so that the
+// passwords aren't sent in the clear. Virtual folders are served read only,
+// as their files are on the other devices.
+type webdavService struct {
+	cfg      configIntf
+	model    webdavModel
+	certFile string
+	keyFile  string
+	logins   map[string]webdavLogin // by Authorization header
+	mut      sync.Mutex
+	stop     chan struct{}
+}
+
+type webdavLogin struct {
+	login
+	expires time.Time
+}
+
+func newWebDAVService(cfg configIntf, m webdavModel, certFile, keyFile string) *webdavService {
+	return &webdavService{
+		cfg:      cfg,
+		model:    m,
+		certFile: certFile,
+		keyFile:  keyFile,
+		logins:   make(map[string]webdavLogin),
+		mut:      sync.NewMutex(),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Serve runs the WebDAV service.
+func (s *webdavService) Serve() {
+	addr := s.cfg.Options().WebDAVAddress
+	if addr == "" {
+		<-s.stop
+		return
+	}
+	if !s.cfg.GUI().IsAuthEnabled() && !addressIsLocalhost(addr) {
+		l.Warnln("Not serving WebDAV on", addr, "as GUI authentication is not set up")
+		<-s.stop
+		return
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		l.Warnln("Starting WebDAV:", err)
+		<-s.stop
+		return
+	}
+	if !addressIsLocalhost(addr) {
+		cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+		if err != nil {
+			l.Warnln("Starting WebDAV:", err)
+			listener.Close()
+			<-s.stop
+			return
+		}
+		listener = tls.NewListener(listener, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS10,
+		})
+	}
+	l.Infoln("WebDAV listening on", listener.Addr())
+
+	srv := http.Server{Handler: s}
+	go srv.Serve(listener)
+	<-s.stop
+	listener.Close()
+}
+
+// Stop stops the WebDAV service.
+func (s *webdavService) Stop() {
+	close(s.stop)
+}
+
+func (s *webdavService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Like the GUI, check the host when serving on localhost, so that
+	// other sites can't get at the folders through DNS rebinding.
+	if addressIsLocalhost(s.cfg.Options().WebDAVAddress) && !s.cfg.GUI().InsecureSkipHostCheck && !addressIsLocalhost(r.Host) {
+		http.Error(w, "Host check error", http.StatusForbidden)
+		return
+	}
+
+	login, ok := s.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Basic realm=\"Syncthing\"")
+		http.Error(w, "Not Authorized", http.StatusUnauthorized)
+		return
+	}
+
+	if login.readOnly {
+		switch r.Method {
+		case "GET", "HEAD", "OPTIONS", "PROPFIND":
+		default:
+			http.Error(w, "Forbidden: read only user", http.StatusForbidden)
+			return
+		}
+	}
+
+	folders := func() map[string]webdav.Folder {
+		return webdavFolders(s.cfg.Folders(), login.folders, s.model.VirtualFilesystem)
+	}
+	webdav.NewHandler(fs.DefaultFilesystem, folders, s.changed).ServeHTTP(w, r)
+}
+
+// authenticate returns the user making the request. Anyone may make
+// requests when GUI authentication is not set up.
+func (s *webdavService) authenticate(r *http.Request) (login, bool) {
+	guiCfg := s.cfg.GUI()
+	if !guiCfg.IsAuthEnabled() {
+		return login{}, true
+	}
+
+	hdr := r.Header.Get("Authorization")
+	now := time.Now()
+	s.mut.Lock()
+	cached, ok := s.logins[hdr]
+	s.mut.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.login, true
+	}
+
+	if len(hdr) < 6 || hdr[:6] != "Basic " {
+		return login{}, false
+	}
+	bs, err := base64.StdEncoding.DecodeString(hdr[6:])
+	if err != nil {
+		return login{}, false
+	}
+	fields := bytes.SplitN(bs, []byte(":"), 2)
+	if len(fields) != 2 {
+		return login{}, false
+	}
+
+	user, ok := newAuthenticator(guiCfg).authenticate(fields[0], fields[1])
+	remote := remoteAddress(r, guiCfg.TrustedProxyNets())
+	emitLoginAttempt(ok, user.username, remote)
+	if !ok {
+		return login{}, false
+	}
+
+	s.mut.Lock()
+	for key, cached := range s.logins {
+		if now.After(cached.expires) {
+			delete(s.logins, key)
+		}
+	}
+	s.logins[hdr] = webdavLogin{user, now.Add(webdavLoginLifetime)}
+	s.mut.Unlock()
+	return user, true
+}
+
+// changed rescans what was changed over WebDAV, so it's synced right away.
+func (s *webdavService) changed(folder, name string) {
+	go func() {
+		if err := s.model.ScanFolderSubdirs(folder, []string{name}); err != nil {
+			l.Infof("Scanning %q in folder %q after WebDAV change: %v", name, folder, err)
+		}
+	}()
+}
+
+func (s *webdavService) String() string {
+	return fmt.Sprintf("webdavService@%p", s)
+}
+
+func (s *webdavService) VerifyConfiguration(from, to config.Configuration) error {
+	if to.Options.WebDAVAddress == "" {
+		return nil
+	}
+	_, err := net.ResolveTCPAddr("tcp", to.Options.WebDAVAddress)
+	return err
+}
+
+func (s *webdavService) CommitConfiguration(from, to config.Configuration) bool {
+	// Users and passwords may have changed, so they must log in again.
+	s.mut.Lock()
+	s.logins = make(map[string]webdavLogin)
+	s.mut.Unlock()
+
+	// Listening on another address requires a restart
+	return from.Options.WebDAVAddress == to.Options.WebDAVAddress
+}
+
+// webdavFolders returns the folders to serve: those that aren't paused or
+// snapshots, limited to the given ones unless that's nil. Virtual folders
+// are served from the filesystem returned by virtual.
+func webdavFolders(cfgs map[string]config.FolderConfiguration, limit []string, virtual func(folder string) fs.Filesystem) map[string]webdav.Folder {
+	allowed := make(map[string]bool, len(limit))
+	for _, id := range limit {
+		allowed[id] = true
+	}
+
+	folders := make(map[string]webdav.Folder)
+	for id, cfg := range cfgs {
+		if cfg.Paused || cfg.SnapshotOf != "" {
+			continue
+		}
+		if limit != nil && !allowed[id] {
+			continue
+		}
+		if cfg.Virtual {
+			folders[id] = webdav.Folder{
+				ID:    id,
+				Label: cfg.Label,
+				FS:    virtual(id),
+			}
+			continue
+		}
+		folders[id] = webdav.Folder{
+			ID:    id,
+			Label: cfg.Label,
+			Path:  cfg.Path(),
+		}
+	}
+	return folders
+}
@@ -0,0 +1,121 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeWebDAVModel struct {
+	scanned chan string
+}
+
+func (m *fakeWebDAVModel) ScanFolderSubdirs(folder string, subs []string) error {
+	m.scanned <- folder + ":" + subs[0]
+	return nil
+}
+
+func (m *fakeWebDAVModel) VirtualFilesystem(folder string) fs.Filesystem {
+	return nil
+}
+
+func TestWebDAVService(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	cfg := config.Wrap("/dev/null", config.Configuration{
+		GUI: config.GUIConfiguration{User: "user", Password: string(hash)},
+		Folders: []config.FolderConfiguration{
+			{ID: "default", RawPath: "testdata"},
+			{ID: "virtual", RawPath: "testdata", Virtual: true},
+		},
+	})
+	m := &fakeWebDAVModel{scanned: make(chan string, 1)}
+	s := newWebDAVService(cfg, m, "", "")
+
+	do := func(method, user, password string) int {
+		r, _ := http.NewRequest(method, "/default/", nil)
+		if user != "" {
+			r.SetBasicAuth(user, password)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := do("PROPFIND", "", ""); code != http.StatusUnauthorized {
+		t.Errorf("Unauthenticated request got %d", code)
+	}
+	if code := do("PROPFIND", "user", "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("Request with the wrong password got %d", code)
+	}
+	if code := do("PROPFIND", "user", "secret"); code != 207 {
+		t.Errorf("Authenticated request got %d", code)
+	}
+
+	// A changed password is required at once, not after the remembered
+	// login expires.
+	oldCfg := cfg.RawCopy()
+	newCfg := cfg.RawCopy()
+	newHash, _ := bcrypt.GenerateFromPassword([]byte("changed"), bcrypt.MinCost)
+	newCfg.GUI.Password = string(newHash)
+	if err := cfg.Replace(newCfg); err != nil {
+		t.Fatal(err)
+	}
+	s.CommitConfiguration(oldCfg, newCfg)
+	if code := do("PROPFIND", "user", "secret"); code != http.StatusUnauthorized {
+		t.Errorf("Request with the old password got %d", code)
+	}
+
+	s.changed("default", "file")
+	if scanned := <-m.scanned; scanned != "default:file" {
+		t.Errorf("Unexpected scan %q", scanned)
+	}
+
+	virtual := func(folder string) fs.Filesystem {
+		return fs.DefaultFilesystem
+	}
+	folders := webdavFolders(cfg.Folders(), []string{"other"}, virtual)
+	if len(folders) != 0 {
+		t.Errorf("Folders not limited: %v", folders)
+	}
+	folders = webdavFolders(cfg.Folders(), nil, virtual)
+	if len(folders) != 2 || folders["default"].Path != cfg.Folders()["default"].Path() || folders["default"].FS != nil {
+		t.Errorf("Unexpected folders %v", folders)
+	}
+	if folders["virtual"].Path != "" || folders["virtual"].FS != fs.DefaultFilesystem {
+		t.Errorf("Virtual folder not served from its filesystem: %v", folders["virtual"])
+	}
+}
+
+func TestWebDAVHostCheck(t *testing.T) {
+	cfg := config.Wrap("/dev/null", config.Configuration{
+		Options: config.OptionsConfiguration{WebDAVAddress: "127.0.0.1:8385"},
+		Folders: []config.FolderConfiguration{
+			{ID: "default", RawPath: "testdata"},
+		},
+	})
+	s := newWebDAVService(cfg, &fakeWebDAVModel{}, "", "")
+
+	for host, expected := range map[string]int{
+		"localhost:8385":   207,
+		"127.0.0.1:8385":   207,
+		"evil.example.com": http.StatusForbidden,
+	} {
+		r, _ := http.NewRequest("PROPFIND", "/default/", nil)
+		r.Host = host
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != expected {
+			t.Errorf("Request for host %q got %d, expected %d", host, w.Code, expected)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/dialer"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+const (
+	webhookCheckInterval = 2 * time.Second
+	defaultWebhookDelay  = 10 * time.Second
+	webhookTimeout       = 30 * time.Second
+)
+
+type webhookModel interface {
+	Completion(device protocol.DeviceID, folder string) model.FolderCompletion
+	NeedSize(folder string) db.Counts
+	ConnectedTo(deviceID protocol.DeviceID) bool
+}
+
+// The payload POSTed to a completion webhook, as JSON.
+type webhookPayload struct {
+	Folder      string    `json:"folder"`
+	FolderLabel string    `json:"folderLabel"`
+	Device      string    `json:"device"`
+	Time        time.Time `json:"time"`
+}
+
+type webhookKey struct {
+	folder string
+	device protocol.DeviceID
+}
+
+type webhookState struct {
+	complete bool
+	since    time.Time // when it became complete
+	called   bool      // whether the webhook has been called since
+}
+
+// The completionWebhookService calls the completion webhook of a folder
+// device once the device has been fully in sync with the folder for the
+// folder's webhook delay, and again only after it's been out of sync in
+// between. Devices already in sync when first seen don't count as having
+// become so.
+type completionWebhookService struct {
+	cfg     configIntf
+	model   webhookModel
+	call    func(url string, payload webhookPayload)
+	states  map[webhookKey]*webhookState
+	dirty   map[string]bool // folders that may have changed since the last check
+	stop    chan struct{}   // signals time to stop
+	started chan struct{}   // signals startup complete
+}
+
+func newCompletionWebhookService(cfg configIntf, m webhookModel) *completionWebhookService {
+	return &completionWebhookService{
+		cfg:     cfg,
+		model:   m,
+		call:    postWebhookAsync,
+		states:  make(map[webhookKey]*webhookState),
+		dirty:   make(map[string]bool),
+		stop:    make(chan struct{}),
+		started: make(chan struct{}),
+	}
+}
+
+// Serve runs the completion webhook service.
+func (s *completionWebhookService) Serve() {
+	sub := events.Default.Subscribe(events.LocalIndexUpdated | events.RemoteIndexUpdated | events.StateChanged | events.DeviceConnected)
+	defer events.Default.Unsubscribe(sub)
+
+	t := time.NewTicker(webhookCheckInterval)
+	defer t.Stop()
+
+	select {
+	case <-s.started:
+		// The started channel has already been closed; do nothing.
+	default:
+		close(s.started)
+	}
+
+	for {
+		select {
+		case ev := <-sub.C():
+			s.handleEvent(ev)
+		case now := <-t.C:
+			s.check(now)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the completion webhook service.
+func (s *completionWebhookService) Stop() {
+	close(s.stop)
+}
+
+// WaitForStart returns once the completion webhook service is ready to
+// receive events, or immediately if it's already running.
+func (s *completionWebhookService) WaitForStart() {
+	<-s.started
+}
+
+// handleEvent makes note of the folders that need checking.
+func (s *completionWebhookService) handleEvent(ev events.Event) {
+	if ev.Type == events.DeviceConnected {
+		for id := range s.cfg.Folders() {
+			s.dirty[id] = true
+		}
+		return
+	}
+	if data, ok := ev.Data.(map[string]interface{}); ok {
+		if folder, ok := data["folder"].(string); ok {
+			s.dirty[folder] = true
+		}
+	}
+}
+
+// check looks at the devices with webhooks in the folders that have
+// changed, and those waiting for their delay to pass, and calls the
+// webhooks that are due.
+func (s *completionWebhookService) check(now time.Time) {
+	seen := make(map[webhookKey]bool)
+
+	for id, fcfg := range s.cfg.Folders() {
+		if fcfg.Paused {
+			continue
+		}
+		delay := time.Duration(fcfg.WebhookDelayS) * time.Second
+		if delay <= 0 {
+			delay = defaultWebhookDelay
+		}
+
+		for _, dev := range fcfg.Devices {
+			if dev.CompletionWebhook == "" {
+				continue
+			}
+			key := webhookKey{id, dev.DeviceID}
+			seen[key] = true
+
+			st, ok := s.states[key]
+			if ok && !s.dirty[id] && (!st.complete || st.called) {
+				// Nothing can have changed
+				continue
+			}
+
+			complete, known := s.completion(id, dev.DeviceID)
+			if !known {
+				continue
+			}
+			if !ok {
+				s.states[key] = &webhookState{complete: complete, since: now, called: complete}
+				continue
+			}
+
+			switch {
+			case !complete:
+				st.complete = false
+				st.called = false
+			case !st.complete:
+				st.complete = true
+				st.since = now
+			}
+
+			if st.complete && !st.called && now.Sub(st.since) >= delay {
+				st.called = true
+				s.call(dev.CompletionWebhook, webhookPayload{
+					Folder:      id,
+					FolderLabel: fcfg.Label,
+					Device:      dev.DeviceID.String(),
+					Time:        now,
+				})
+			}
+		}
+	}
+
+	for key := range s.states {
+		if !seen[key] {
+			delete(s.states, key)
+		}
+	}
+	s.dirty = make(map[string]bool)
+}
+
+// completion returns whether the device is fully in sync with the folder,
+// and whether we know that; we don't for devices we're not connected to.
+func (s *completionWebhookService) completion(folder string, device protocol.DeviceID) (complete, known bool) {
+	if device == myID {
+		need := s.model.NeedSize(folder)
+		return need.Files == 0 && need.Directories == 0 && need.Symlinks == 0 && need.Deleted == 0, true
+	}
+	if !s.model.ConnectedTo(device) {
+		return false, false
+	}
+	comp := s.model.Completion(device, folder)
+	return comp.NeedBytes == 0 && comp.NeedDeletes == 0, true
+}
+
+func postWebhookAsync(url string, payload webhookPayload) {
+	go func() {
+		if err := postWebhook(url, payload); err != nil {
+			l.Warnf("Calling completion webhook for folder %q and device %s: %v", payload.Folder, payload.Device, err)
+		}
+	}()
+}
+
+func postWebhook(url string, payload webhookPayload) error {
+	bs, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := newWebhookClient().Post(url, "application/json", bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return nil
+}
+
+// newWebhookClient returns an HTTP client for calling webhooks, dialing
+// through the proxy if one is set.
+func newWebhookClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Dial:  dialer.Dial,
+			Proxy: http.ProxyFromEnvironment,
+		},
+		Timeout: webhookTimeout,
+	}
+}
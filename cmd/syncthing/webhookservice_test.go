@@ -0,0 +1,157 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+type fakeWebhookModel struct {
+	connected bool
+	need      map[protocol.DeviceID]int64
+}
+
+func (m *fakeWebhookModel) Completion(device protocol.DeviceID, folder string) model.FolderCompletion {
+	return model.FolderCompletion{NeedBytes: m.need[device]}
+}
+
+func (m *fakeWebhookModel) NeedSize(folder string) db.Counts {
+	return db.Counts{Files: int(m.need[myID])}
+}
+
+func (m *fakeWebhookModel) ConnectedTo(deviceID protocol.DeviceID) bool {
+	return m.connected
+}
+
+func TestCompletionWebhookService(t *testing.T) {
+	remote, _ := protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+	cfg := config.Wrap("/dev/null", config.Configuration{
+		Folders: []config.FolderConfiguration{
+			{
+				ID:            "default",
+				Label:         "Default Folder",
+				WebhookDelayS: 10,
+				Devices: []config.FolderDeviceConfiguration{
+					{DeviceID: myID, CompletionWebhook: "http://localhost/local"},
+					{DeviceID: remote, CompletionWebhook: "http://localhost/remote"},
+				},
+			},
+		},
+	})
+	m := &fakeWebhookModel{
+		connected: true,
+		need:      map[protocol.DeviceID]int64{myID: 1, remote: 1},
+	}
+
+	var calls []webhookPayload
+	s := newCompletionWebhookService(cfg, m)
+	s.call = func(url string, payload webhookPayload) {
+		if (url == "http://localhost/local") != (payload.Device == myID.String()) {
+			t.Errorf("Called %s for device %s", url, payload.Device)
+		}
+		calls = append(calls, payload)
+	}
+	changed := func() {
+		s.handleEvent(events.Event{Type: events.RemoteIndexUpdated, Data: map[string]interface{}{"folder": "default"}})
+	}
+
+	now := time.Now()
+	s.check(now)
+
+	// The remote device completes; the webhook is called once the delay
+	// has passed, and only once
+
+	m.need[remote] = 0
+	changed()
+	s.check(now.Add(time.Second))
+	s.check(now.Add(10 * time.Second))
+	if len(calls) != 0 {
+		t.Fatalf("Webhook called before the delay passed: %+v", calls)
+	}
+	s.check(now.Add(11 * time.Second))
+	changed()
+	s.check(now.Add(20 * time.Second))
+	if len(calls) != 1 || calls[0].Device != remote.String() || calls[0].Folder != "default" || calls[0].FolderLabel != "Default Folder" {
+		t.Fatalf("Unexpected webhook calls %+v", calls)
+	}
+
+	// Falling out of sync before the delay passes cancels the call
+
+	m.need[myID] = 0
+	changed()
+	s.check(now.Add(30 * time.Second))
+	m.need[myID] = 1
+	changed()
+	s.check(now.Add(35 * time.Second))
+	s.check(now.Add(45 * time.Second))
+	if len(calls) != 1 {
+		t.Fatalf("Unexpected webhook calls %+v", calls)
+	}
+
+	// Completing again calls the webhook again
+
+	m.need[myID] = 0
+	m.need[remote] = 1
+	changed()
+	s.check(now.Add(50 * time.Second))
+	m.need[remote] = 0
+	changed()
+	s.check(now.Add(51 * time.Second))
+	s.check(now.Add(62 * time.Second))
+	if len(calls) != 3 || calls[1].Device != myID.String() || calls[2].Device != remote.String() {
+		t.Fatalf("Unexpected webhook calls %+v", calls)
+	}
+
+	// Devices that are already complete when first seen, or whose
+	// completion we don't know, don't count
+
+	s = newCompletionWebhookService(cfg, m)
+	s.call = func(url string, payload webhookPayload) {
+		t.Errorf("Unexpected call to %s", url)
+	}
+	m.connected = false
+	s.check(now)
+	s.check(now.Add(time.Minute))
+	m.connected = true
+	changed()
+	s.check(now.Add(2 * time.Minute))
+	s.check(now.Add(3 * time.Minute))
+}
+
+func TestPostWebhook(t *testing.T) {
+	received := make(chan webhookPayload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || r.Method != "POST" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		received <- payload
+	}))
+	defer srv.Close()
+
+	if err := postWebhook(srv.URL, webhookPayload{Folder: "default", Device: "device"}); err != nil {
+		t.Fatal(err)
+	}
+	if payload := <-received; payload.Folder != "default" || payload.Device != "device" {
+		t.Errorf("Unexpected payload %+v", payload)
+	}
+
+	if err := postWebhook(srv.URL+"/\x00", webhookPayload{}); err == nil {
+		t.Error("Expected an error for a bad URL")
+	}
+}
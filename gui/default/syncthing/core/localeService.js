@@ -34,14 +34,15 @@ angular.module('syncthing.core')
         this.$get = ['$http', '$translate', '$location', function ($http, $translate, $location) {
 
             /**
-             * Requests the server in order to get the browser's requested locale strings.
+             * Requests the server in order to get the locale to use. The server
+             * negotiates it from the browser's requested locales, unless a
+             * language is set in the GUI configuration.
              *
-             * @returns promise which on success resolves with a locales array
+             * @returns promise which on success resolves with the locale
+             * information: available, locale, override and format
              */
-            function readBrowserLocales() {
-                // @TODO: check if there is nice way to utilize window.navigator.languages or similar api.
-
-                return $http.get(urlbase + "/svc/lang");
+            function readServerLocale() {
+                return $http.get(urlbase + "/svc/locale");
             }
 
             function autoConfigLocale() {
@@ -51,50 +52,25 @@ angular.module('syncthing.core')
                     savedLang = _localStorage[_SYNLANG];
                 }
 
-                if(params.lang) {
+                if (params.lang) {
                     useLocale(params.lang, true);
-                } else if (savedLang) {
-                    useLocale(savedLang);
-                } else {
-                    readBrowserLocales().success(function (langs) {
-                        // Find the first language in the list provided by the user's browser
-                        // that is a prefix of a language we have available. That is, "en"
-                        // sent by the browser will match "en" or "en-US", while "zh-TW" will
-                        // match only "zh-TW" and not "zh-CN".
-
-                        var i,
-                            lang,
-                            matching,
-                            locale = _defaultLocale;
-
-                        for (i = 0; i < langs.length; i++) {
-                            lang = langs[i];
-
-                            if (lang.length < 2) {
-                                continue;
-                            }
-
-                            matching = _availableLocales.filter(function (possibleLang) {
-                                // The langs returned by the /rest/langs call will be in lower
-                                // case. We compare to the lowercase version of the language
-                                // code we have as well.
-                                possibleLang = possibleLang.toLowerCase();
-                                if (possibleLang.length > lang.length) {
-                                    return possibleLang.indexOf(lang) === 0;
-                                } else {
-                                    return lang.indexOf(possibleLang) === 0;
-                                }
-                            });
+                    return;
+                }
 
-                            if (matching.length >= 1) {
-                                locale = matching[0];
-                                break;
-                            }
-                        }
+                readServerLocale().success(function (info) {
+                    if (info.override) {
+                        useLocale(info.override);
+                    } else if (savedLang) {
+                        useLocale(savedLang);
+                    } else if (_availableLocales.indexOf(info.locale) >= 0) {
+                        useLocale(info.locale);
+                    } else {
                         // Fallback if nothing matched
-                        useLocale(locale);
-                    });
-                }
+                        useLocale(_defaultLocale);
+                    }
+                }).error(function () {
+                    useLocale(savedLang || _defaultLocale);
+                });
             }
 
             function useLocale(language, save2Storage) {
@@ -8,6 +8,7 @@ angular.module('syncthing.core')
         // private/helper definitions
 
         var prevDate = 0;
+        var stateSequence = 0;
         var navigatingAway = false;
         var online = false;
         var restarting = false;
@@ -358,12 +359,8 @@ angular.module('syncthing.core')
             });
             $scope.devices.sort(deviceCompare);
             $scope.folders = folderMap($scope.config.folders);
-            Object.keys($scope.folders).forEach(function (folder) {
-                refreshFolder(folder);
-                $scope.folders[folder].devices.forEach(function (deviceCfg) {
-                    refreshCompletion(deviceCfg.deviceID, folder);
-                });
-            });
+            stateSequence = 0;
+            refreshState();
 
             // If we're not listening on localhost, and there is no
             // authentication configured, and the magic setting to silence the
@@ -508,10 +505,32 @@ angular.module('syncthing.core')
             }).error($scope.emitHTTPError);
         }
 
-        function refreshErrors() {
-            $http.get(urlbase + '/system/error').success(function (data) {
+        function refreshState() {
+            $http.get(urlbase + '/system/state?since=' + stateSequence).success(function (data) {
+                var folder, device;
+                stateSequence = data.sequence;
+
+                for (folder in data.folders) {
+                    if (data.folders[folder]) {
+                        $scope.model[folder] = data.folders[folder];
+                    } else {
+                        delete $scope.model[folder];
+                    }
+                }
+                recalcLocalStateTotal();
+
+                for (folder in data.completion) {
+                    for (device in data.completion[folder]) {
+                        if (!$scope.completion[device]) {
+                            $scope.completion[device] = {};
+                        }
+                        $scope.completion[device][folder] = data.completion[folder][device];
+                        recalcCompletion(device);
+                    }
+                }
+
                 $scope.errors = data.errors;
-                console.log("refreshErrors", data);
+                console.log("refreshState", data);
             }).error($scope.emitHTTPError);
         }
 
@@ -637,7 +656,7 @@ angular.module('syncthing.core')
             refreshSystem();
             refreshDiscoveryCache();
             refreshConnectionStats();
-            refreshErrors();
+            refreshState();
         };
 
         $scope.folderStatus = function (folderCfg) {
@@ -1344,6 +1363,10 @@ angular.module('syncthing.core')
             $scope.currentFolder.devices.forEach(function (n) {
                 $scope.currentFolder.selectedDevices[n.deviceID] = true;
             });
+            $scope.currentFolder.selectedGroups = {};
+            ($scope.currentFolder.groups || []).forEach(function (id) {
+                $scope.currentFolder.selectedGroups[id] = true;
+            });
             if ($scope.currentFolder.versioning && $scope.currentFolder.versioning.type === "trashcan") {
                 $scope.currentFolder.trashcanFileVersioning = true;
                 $scope.currentFolder.fileVersioningSelector = "trashcan";
@@ -1362,6 +1385,14 @@ angular.module('syncthing.core')
                 $scope.currentFolder.externalFileVersioning = true;
                 $scope.currentFolder.fileVersioningSelector = "external";
                 $scope.currentFolder.externalCommand = $scope.currentFolder.versioning.params.command;
+            } else if ($scope.currentFolder.versioning && $scope.currentFolder.versioning.type === "snapshot") {
+                $scope.currentFolder.fileVersioningSelector = "snapshot";
+                $scope.currentFolder.snapshotFilesystem = $scope.currentFolder.versioning.params.filesystem;
+                $scope.currentFolder.snapshotKeep = +$scope.currentFolder.versioning.params.keep;
+                $scope.currentFolder.snapshotMaxAge = Math.floor(+$scope.currentFolder.versioning.params.maxAge / 86400);
+                // Not editable here, but kept.
+                $scope.currentFolder.snapshotSource = $scope.currentFolder.versioning.params.source;
+                $scope.currentFolder.snapshotsPath = $scope.currentFolder.versioning.params.snapshotsPath;
             } else {
                 $scope.currentFolder.fileVersioningSelector = "none";
             }
@@ -1377,6 +1408,9 @@ angular.module('syncthing.core')
                 $scope.currentFolder.staggeredMaxAge = 365;
             }
             $scope.currentFolder.externalCommand = $scope.currentFolder.externalCommand || "";
+            $scope.currentFolder.snapshotFilesystem = $scope.currentFolder.snapshotFilesystem || "btrfs";
+            $scope.currentFolder.snapshotKeep = $scope.currentFolder.snapshotKeep || 0;
+            $scope.currentFolder.snapshotMaxAge = $scope.currentFolder.snapshotMaxAge || 0;
 
             $scope.editingExisting = true;
             $scope.folderEditor.$setPristine();
@@ -1386,12 +1420,18 @@ angular.module('syncthing.core')
         $scope.addFolder = function () {
             $scope.currentFolder = {
                 selectedDevices: {},
+                selectedGroups: {},
                 type: "readwrite",
                 rescanIntervalS: 60,
                 minDiskFreePct: 1,
                 maxConflicts: 10,
                 fsync: true,
                 order: "random",
+                conflictPolicy: "copy",
+                conflictNaming: "time",
+                pullHookFailure: "ignore",
+                priority: "normal",
+                settingsFrom: "",
                 fileVersioningSelector: "none",
                 trashcanClean: 0,
                 simpleKeep: 5,
@@ -1399,6 +1439,9 @@ angular.module('syncthing.core')
                 staggeredCleanInterval: 3600,
                 staggeredVersionsPath: "",
                 externalCommand: "",
+                snapshotFilesystem: "btrfs",
+                snapshotKeep: 0,
+                snapshotMaxAge: 0,
                 autoNormalize: true
             };
             $scope.editingExisting = false;
@@ -1415,11 +1458,17 @@ angular.module('syncthing.core')
                 id: folder,
                 label: folderLabel,
                 selectedDevices: {},
+                selectedGroups: {},
                 rescanIntervalS: 60,
                 minDiskFreePct: 1,
                 maxConflicts: 10,
                 fsync: true,
                 order: "random",
+                conflictPolicy: "copy",
+                conflictNaming: "time",
+                pullHookFailure: "ignore",
+                priority: "normal",
+                settingsFrom: "",
                 fileVersioningSelector: "none",
                 trashcanClean: 0,
                 simpleKeep: 5,
@@ -1427,6 +1476,9 @@ angular.module('syncthing.core')
                 staggeredCleanInterval: 3600,
                 staggeredVersionsPath: "",
                 externalCommand: "",
+                snapshotFilesystem: "btrfs",
+                snapshotKeep: 0,
+                snapshotMaxAge: 0,
                 autoNormalize: true,
                 viewFlags: {
                     importFromOtherDevice: true
@@ -1451,16 +1503,33 @@ angular.module('syncthing.core')
         $scope.saveFolder = function () {
             $('#editFolder').modal('hide');
             var folderCfg = $scope.currentFolder;
+            // Completion webhooks aren't edited here; keep them, along with
+            // which devices the folder is shared with through a group.
+            var webhooks = {};
+            var fromGroup = {};
+            (folderCfg.devices || []).forEach(function (n) {
+                webhooks[n.deviceID] = n.completionWebhook;
+                fromGroup[n.deviceID] = n.fromGroup;
+            });
             folderCfg.devices = [];
             folderCfg.selectedDevices[$scope.myID] = true;
             for (var deviceID in folderCfg.selectedDevices) {
                 if (folderCfg.selectedDevices[deviceID] === true) {
                     folderCfg.devices.push({
-                        deviceID: deviceID
+                        deviceID: deviceID,
+                        completionWebhook: webhooks[deviceID],
+                        fromGroup: fromGroup[deviceID]
                     });
                 }
             }
             delete folderCfg.selectedDevices;
+            folderCfg.groups = [];
+            for (var groupID in folderCfg.selectedGroups) {
+                if (folderCfg.selectedGroups[groupID] === true) {
+                    folderCfg.groups.push(groupID);
+                }
+            }
+            delete folderCfg.selectedGroups;
 
             if (folderCfg.fileVersioningSelector === "trashcan") {
                 folderCfg.versioning = {
@@ -1503,6 +1572,22 @@ angular.module('syncthing.core')
                 };
                 delete folderCfg.externalFileVersioning;
                 delete folderCfg.externalCommand;
+            } else if (folderCfg.fileVersioningSelector === "snapshot") {
+                folderCfg.versioning = {
+                    'type': 'snapshot',
+                    'params': {
+                        'filesystem': '' + folderCfg.snapshotFilesystem,
+                        'keep': '' + folderCfg.snapshotKeep,
+                        'maxAge': '' + (folderCfg.snapshotMaxAge * 86400),
+                        'source': '' + (folderCfg.snapshotSource || ''),
+                        'snapshotsPath': '' + (folderCfg.snapshotsPath || '')
+                    }
+                };
+                delete folderCfg.snapshotFilesystem;
+                delete folderCfg.snapshotKeep;
+                delete folderCfg.snapshotMaxAge;
+                delete folderCfg.snapshotSource;
+                delete folderCfg.snapshotsPath;
             } else {
                 delete folderCfg.versioning;
             }
@@ -1624,12 +1709,27 @@ angular.module('syncthing.core')
         };
 
         $scope.showFailed = function (folder) {
+            $scope.failedFolder = folder;
             $scope.failedCurrent = $scope.failed[folder];
             $('#failed').modal().on('hidden.bs.modal', function () {
+                $scope.failedFolder = undefined;
                 $scope.failedCurrent = undefined;
             });
         };
 
+        // Retries or ignores until changed the failed item with the id, or
+        // all of them when there's no id.
+        $scope.triageFailed = function (action, id) {
+            var url = urlbase + '/folder/errors/' + action + '?folder=' + encodeURIComponent($scope.failedFolder);
+            if (id) {
+                url += '&id=' + encodeURIComponent(id);
+            }
+            $http.post(url).success(function (data) {
+                $scope.failed[data.folder] = data.errors;
+                $scope.failedCurrent = data.errors;
+            }).error($scope.emitHTTPError);
+        };
+
         $scope.hasFailedFiles = function (folder) {
             if (!$scope.failed[folder]) {
                 return false;
@@ -0,0 +1,310 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package bitmap implements compressed bitmaps of 32 bit integers, after the
+// Roaring bitmaps of Chambi, Lemire, Kaser and Godin.
+//
+// The integers are split on their high 16 bits into containers that each
+// hold the low 16 bits in whichever form takes the least memory: a list of
+// runs for mostly consecutive values, a sorted array for a few scattered
+// ones, or a plain bitmap for many. A set of block indexes, say, which
+// mostly fills up in order, then takes a few bytes rather than four per
+// block.
+package bitmap
+
+import "sort"
+
+const (
+	// An array container takes two bytes per value, a bitmap container
+	// 8 KiB regardless, so above this many values a bitmap is smaller.
+	maxArrayValues = 4096
+	bitmapWords    = 1 << 16 / 64
+)
+
+type containerKind int
+
+const (
+	kindRuns containerKind = iota
+	kindArray
+	kindBitmap
+)
+
+// A run is the values from start to last, inclusive.
+type run struct {
+	start, last uint16
+}
+
+// A container holds the low 16 bits of the values with the same high 16
+// bits, in the form that takes the least memory.
+type container struct {
+	key   uint16
+	kind  containerKind
+	runs  []run    // sorted, neither overlapping nor adjacent
+	array []uint16 // sorted
+	bits  []uint64
+	n     int // values
+	nruns int // runs of consecutive values, whatever the form
+}
+
+// A Bitmap is a set of uint32. The zero value is an empty set, ready to
+// use. A Bitmap isn't safe for concurrent use.
+type Bitmap struct {
+	containers []*container // sorted by key
+}
+
+// New returns a bitmap of the values.
+func New(values ...uint32) *Bitmap {
+	b := new(Bitmap)
+	for _, v := range values {
+		b.Add(v)
+	}
+	return b
+}
+
+// Add adds the value, returning false if it was there already.
+func (b *Bitmap) Add(v uint32) bool {
+	key := uint16(v >> 16)
+	i := b.search(key)
+	if i == len(b.containers) || b.containers[i].key != key {
+		b.containers = append(b.containers, nil)
+		copy(b.containers[i+1:], b.containers[i:])
+		b.containers[i] = &container{key: key}
+	}
+	return b.containers[i].add(uint16(v))
+}
+
+// Contains returns whether the value is in the bitmap.
+func (b *Bitmap) Contains(v uint32) bool {
+	key := uint16(v >> 16)
+	i := b.search(key)
+	if i == len(b.containers) || b.containers[i].key != key {
+		return false
+	}
+	return b.containers[i].contains(uint16(v))
+}
+
+// Len returns the number of values in the bitmap.
+func (b *Bitmap) Len() int {
+	n := 0
+	for _, c := range b.containers {
+		n += c.n
+	}
+	return n
+}
+
+// Clear removes all the values.
+func (b *Bitmap) Clear() {
+	b.containers = nil
+}
+
+// Each calls fn with each value in the bitmap, in increasing order, until
+// it returns false.
+func (b *Bitmap) Each(fn func(uint32) bool) {
+	for _, c := range b.containers {
+		high := uint32(c.key) << 16
+		if !c.each(func(low uint16) bool { return fn(high | uint32(low)) }) {
+			return
+		}
+	}
+}
+
+// Size returns the approximate number of bytes the bitmap takes in memory.
+func (b *Bitmap) Size() int {
+	size := 24 + 8*cap(b.containers)
+	for _, c := range b.containers {
+		size += 112 + 4*cap(c.runs) + 2*cap(c.array) + 8*cap(c.bits)
+	}
+	return size
+}
+
+func (b *Bitmap) search(key uint16) int {
+	return sort.Search(len(b.containers), func(i int) bool {
+		return b.containers[i].key >= key
+	})
+}
+
+func (c *container) add(v uint16) bool {
+	if c.kind == kindRuns {
+		if !c.addRun(v) {
+			return false
+		}
+		c.nruns = len(c.runs)
+	} else {
+		if c.contains(v) {
+			return false
+		}
+
+		// The value may join the runs before and after it.
+		c.nruns++
+		if v > 0 && c.contains(v-1) {
+			c.nruns--
+		}
+		if v < 1<<16-1 && c.contains(v+1) {
+			c.nruns--
+		}
+
+		if c.kind == kindArray {
+			i := c.searchArray(v)
+			c.array = append(c.array, 0)
+			copy(c.array[i+1:], c.array[i:])
+			c.array[i] = v
+		} else {
+			c.bits[v/64] |= 1 << (v % 64)
+		}
+	}
+
+	c.n++
+	c.convert()
+	return true
+}
+
+// addRun adds the value to the runs, extending or merging the runs it's
+// adjacent to, and returns false if it was there already.
+func (c *container) addRun(v uint16) bool {
+	// The common case of values added in order
+	if l := len(c.runs); l > 0 && c.runs[l-1].last < v {
+		if c.runs[l-1].last == v-1 {
+			c.runs[l-1].last = v
+		} else {
+			c.runs = append(c.runs, run{v, v})
+		}
+		return true
+	}
+
+	// The first run that ends at or after v
+	i := sort.Search(len(c.runs), func(i int) bool {
+		return c.runs[i].last >= v
+	})
+	if i < len(c.runs) && c.runs[i].start <= v {
+		return false
+	}
+
+	extendsPrev := i > 0 && c.runs[i-1].last == v-1
+	extendsNext := i < len(c.runs) && c.runs[i].start == v+1
+	switch {
+	case extendsPrev && extendsNext:
+		c.runs[i-1].last = c.runs[i].last
+		c.runs = append(c.runs[:i], c.runs[i+1:]...)
+	case extendsPrev:
+		c.runs[i-1].last = v
+	case extendsNext:
+		c.runs[i].start = v
+	default:
+		c.runs = append(c.runs, run{})
+		copy(c.runs[i+1:], c.runs[i:])
+		c.runs[i] = run{v, v}
+	}
+	return true
+}
+
+// size returns the bytes the values take in the form.
+func (c *container) size(kind containerKind) int {
+	switch kind {
+	case kindRuns:
+		return 4 * c.nruns
+	case kindArray:
+		if c.n > maxArrayValues {
+			return 1 << 16
+		}
+		return 2 * c.n
+	default:
+		return 8 * bitmapWords
+	}
+}
+
+// convert changes the container to the form that takes the least memory,
+// once the current one takes half as much again, so that it doesn't go back
+// and forth between forms of about the same size.
+func (c *container) convert() {
+	best := c.kind
+	for _, kind := range []containerKind{kindRuns, kindArray, kindBitmap} {
+		if c.size(kind) < c.size(best) {
+			best = kind
+		}
+	}
+	if size := c.size(best); c.size(c.kind) <= size+size/2 {
+		return
+	}
+
+	var runs []run
+	var array []uint16
+	var bits []uint64
+	switch best {
+	case kindRuns:
+		runs = make([]run, 0, c.nruns)
+		c.each(func(v uint16) bool {
+			if l := len(runs); l > 0 && runs[l-1].last == v-1 {
+				runs[l-1].last = v
+			} else {
+				runs = append(runs, run{v, v})
+			}
+			return true
+		})
+	case kindArray:
+		array = make([]uint16, 0, c.n)
+		c.each(func(v uint16) bool {
+			array = append(array, v)
+			return true
+		})
+	case kindBitmap:
+		bits = make([]uint64, bitmapWords)
+		c.each(func(v uint16) bool {
+			bits[v/64] |= 1 << (v % 64)
+			return true
+		})
+	}
+	c.kind, c.runs, c.array, c.bits = best, runs, array, bits
+}
+
+func (c *container) contains(v uint16) bool {
+	switch c.kind {
+	case kindRuns:
+		i := sort.Search(len(c.runs), func(i int) bool {
+			return c.runs[i].last >= v
+		})
+		return i < len(c.runs) && c.runs[i].start <= v
+	case kindArray:
+		i := c.searchArray(v)
+		return i < len(c.array) && c.array[i] == v
+	default:
+		return c.bits[v/64]&(1<<(v%64)) != 0
+	}
+}
+
+func (c *container) searchArray(v uint16) int {
+	return sort.Search(len(c.array), func(i int) bool {
+		return c.array[i] >= v
+	})
+}
+
+func (c *container) each(fn func(uint16) bool) bool {
+	switch c.kind {
+	case kindRuns:
+		for _, r := range c.runs {
+			for v := int(r.start); v <= int(r.last); v++ {
+				if !fn(uint16(v)) {
+					return false
+				}
+			}
+		}
+	case kindArray:
+		for _, v := range c.array {
+			if !fn(v) {
+				return false
+			}
+		}
+	default:
+		for w, word := range c.bits {
+			for bit := uint(0); word != 0; bit++ {
+				if word&1 != 0 && !fn(uint16(w*64+int(bit))) {
+					return false
+				}
+				word >>= 1
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package bitmap
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBitmap(t *testing.T) {
+	// Values of different densities in different containers: runs,
+	// scattered values, and the many of a bitmap.
+	var values []uint32
+	for v := uint32(0); v < 20000; v++ {
+		values = append(values, v)
+	}
+	for i := 0; i < 1000; i++ {
+		values = append(values, 1<<16+uint32(rand.Intn(1<<16)))
+	}
+	for i := 0; i < 40000; i++ {
+		values = append(values, 3<<16+uint32(rand.Intn(1<<16)))
+	}
+	values = append(values, 1<<32-1, 5<<16, 5<<16+1<<16-1)
+
+	b := new(Bitmap)
+	want := make(map[uint32]bool)
+	for _, i := range rand.Perm(len(values)) {
+		v := values[i]
+		if b.Add(v) == want[v] {
+			t.Fatalf("Add(%d) returned %v, already there %v", v, !want[v], want[v])
+		}
+		want[v] = true
+	}
+
+	if b.Len() != len(want) {
+		t.Errorf("Len() = %d, expected %d", b.Len(), len(want))
+	}
+	for v := range want {
+		if !b.Contains(v) {
+			t.Fatalf("%d missing", v)
+		}
+	}
+	for _, v := range []uint32{20000, 2 << 16, 5<<16 + 1, 1<<32 - 2} {
+		if b.Contains(v) != want[v] {
+			t.Errorf("Contains(%d) = %v", v, !want[v])
+		}
+	}
+
+	sorted := make([]int, 0, len(want))
+	for v := range want {
+		sorted = append(sorted, int(v))
+	}
+	sort.Ints(sorted)
+	i := 0
+	b.Each(func(v uint32) bool {
+		if int(v) != sorted[i] {
+			t.Fatalf("Each gave %d at %d, expected %d", v, i, sorted[i])
+		}
+		i++
+		return true
+	})
+	if i != len(sorted) {
+		t.Errorf("Each gave %d values, expected %d", i, len(sorted))
+	}
+
+	if b.containers[0].kind != kindRuns || b.containers[1].kind != kindArray || b.containers[2].kind != kindBitmap {
+		t.Errorf("Unexpected container kinds %d, %d, %d", b.containers[0].kind, b.containers[1].kind, b.containers[2].kind)
+	}
+
+	b.Clear()
+	if b.Len() != 0 || b.Contains(0) {
+		t.Error("Cleared bitmap is not empty")
+	}
+}
+
+func TestBitmapSequentialSize(t *testing.T) {
+	// A million values in order take a few runs.
+	b := new(Bitmap)
+	for v := uint32(0); v < 1e6; v++ {
+		b.Add(v)
+	}
+	if size := b.Size(); size > 4096 {
+		t.Errorf("A million sequential values take %d bytes", size)
+	}
+}
+
+// The benchmarks add the block indexes of a file of a given number of
+// blocks, as they'd be downloaded, to a bitmap and to a slice; the B/op
+// show the memory each takes.
+
+func benchmarkBitmap(b *testing.B, blocks int, shuffled bool) {
+	indexes := blockIndexes(blocks, shuffled)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bm := new(Bitmap)
+		for _, idx := range indexes {
+			bm.Add(uint32(idx))
+		}
+	}
+}
+
+func benchmarkSlice(b *testing.B, blocks int, shuffled bool) {
+	indexes := blockIndexes(blocks, shuffled)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var s []int32
+		for _, idx := range indexes {
+			s = append(s, int32(idx))
+		}
+	}
+}
+
+func blockIndexes(blocks int, shuffled bool) []int {
+	if shuffled {
+		return rand.Perm(blocks)
+	}
+	indexes := make([]int, blocks)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+func BenchmarkBitmapSequential1k(b *testing.B)   { benchmarkBitmap(b, 1000, false) }
+func BenchmarkSliceSequential1k(b *testing.B)    { benchmarkSlice(b, 1000, false) }
+func BenchmarkBitmapSequential100k(b *testing.B) { benchmarkBitmap(b, 100000, false) }
+func BenchmarkSliceSequential100k(b *testing.B)  { benchmarkSlice(b, 100000, false) }
+func BenchmarkBitmapShuffled1k(b *testing.B)     { benchmarkBitmap(b, 1000, true) }
+func BenchmarkSliceShuffled1k(b *testing.B)      { benchmarkSlice(b, 1000, true) }
+func BenchmarkBitmapShuffled100k(b *testing.B)   { benchmarkBitmap(b, 100000, true) }
+func BenchmarkSliceShuffled100k(b *testing.B)    { benchmarkSlice(b, 100000, true) }
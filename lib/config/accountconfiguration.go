@@ -0,0 +1,27 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// An AccountConfiguration is a user of the GUI besides the main one, for
+// sharing a device among a family or an office. Such users only see and
+// manage their folders and the devices sharing them; with no folders they
+// see none.
+type AccountConfiguration struct {
+	Name     string   `xml:"name,attr" json:"name"`
+	Password string   `xml:"password" json:"password"` // bcrypt hashed, as for the main user
+	ReadOnly bool     `xml:"readOnly,attr" json:"readOnly"`
+	Folders  []string `xml:"folder" json:"folders"`
+}
+
+func (a AccountConfiguration) Copy() AccountConfiguration {
+	cp := a
+	if a.Folders != nil {
+		cp.Folders = make([]string, len(a.Folders))
+		copy(cp.Folders, a.Folders)
+	}
+	return cp
+}
@@ -0,0 +1,33 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// AlertConfiguration describes when and how to send alerts by e-mail.
+// Alerts are collected and sent as a digest at most once per
+// DigestIntervalM minutes.
+type AlertConfiguration struct {
+	Enabled         bool     `xml:"enabled,attr" json:"enabled"`
+	SMTPServer      string   `xml:"smtpServer" json:"smtpServer"` // host:port
+	SMTPUser        string   `xml:"smtpUser,omitempty" json:"smtpUser"`
+	SMTPPassword    string   `xml:"smtpPassword,omitempty" json:"smtpPassword"`
+	From            string   `xml:"from" json:"from"`
+	To              []string `xml:"to" json:"to"`
+	DigestIntervalM int      `xml:"digestIntervalM" json:"digestIntervalM" default:"60"`
+	DeviceOfflineH  int      `xml:"deviceOfflineH" json:"deviceOfflineH" default:"24"` // 0 to not alert on offline devices
+	FolderErrors    bool     `xml:"folderErrors" json:"folderErrors" default:"true"`   // alert when a folder stops with an error
+	MinDiskFreePct  float64  `xml:"minDiskFreePct" json:"minDiskFreePct" default:"5"`  // 0 to not alert on low disk space
+	Conflicts       bool     `xml:"conflicts" json:"conflicts" default:"true"`         // alert when a conflict copy is created
+}
+
+func (c AlertConfiguration) Copy() AlertConfiguration {
+	cp := c
+	if c.To != nil {
+		cp.To = make([]string, len(c.To))
+		copy(cp.To, c.To)
+	}
+	return cp
+}
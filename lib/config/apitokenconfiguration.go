@@ -0,0 +1,59 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import "time"
+
+// What an API token may do, as given in its scopes.
+const (
+	APITokenScopeRead    = "read"    // look at anything but the config and database backups, events included; support bundles and logs only redacted
+	APITokenScopeEvents  = "events"  // follow the events, and nothing else
+	APITokenScopeConfig  = "config"  // look at and change the config, including the API keys and tokens, back up the database, promote and reset
+	APITokenScopeControl = "control" // everything else that changes something, such as scanning, pausing and restarting
+)
+
+// An APITokenConfiguration is a named key to the REST API, used like the
+// API key but limited to its scopes and, if any, folders.
+type APITokenConfiguration struct {
+	Name    string    `xml:"name,attr" json:"name"`
+	Hash    string    `xml:"hash" json:"hash"` // of the token, as for API keys; the token itself isn't kept
+	Scopes  []string  `xml:"scope" json:"scopes"`
+	Folders []string  `xml:"folder" json:"folders"` // the folders the token is limited to, or none for all
+	Created time.Time `xml:"created" json:"created"`
+}
+
+// HasScope returns true if the token may do what the scope allows.
+func (t APITokenConfiguration) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (t APITokenConfiguration) Copy() APITokenConfiguration {
+	cp := t
+	if t.Scopes != nil {
+		cp.Scopes = make([]string, len(t.Scopes))
+		copy(cp.Scopes, t.Scopes)
+	}
+	if t.Folders != nil {
+		cp.Folders = make([]string, len(t.Folders))
+		copy(cp.Folders, t.Folders)
+	}
+	return cp
+}
+
+func validAPITokenScope(scope string) bool {
+	switch scope {
+	case APITokenScopeRead, APITokenScopeEvents, APITokenScopeConfig, APITokenScopeControl:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// CaseCollisionPolicy decides what happens to files whose names differ only
+// in case or Unicode normalization, and so can't coexist on case
+// insensitive filesystems. All but one of the colliding files are moved
+// aside.
+type CaseCollisionPolicy int
+
+const (
+	CaseCollisionReport     CaseCollisionPolicy = iota // default is to leave them be, and only report them
+	CaseCollisionRename                                // the others are renamed to names that don't collide
+	CaseCollisionQuarantine                            // the others are moved out of the folder into .stquarantine
+)
+
+func (p CaseCollisionPolicy) String() string {
+	switch p {
+	case CaseCollisionReport:
+		return "report"
+	case CaseCollisionRename:
+		return "rename"
+	case CaseCollisionQuarantine:
+		return "quarantine"
+	default:
+		return "unknown"
+	}
+}
+
+func (p CaseCollisionPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *CaseCollisionPolicy) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "report":
+		*p = CaseCollisionReport
+	case "rename":
+		*p = CaseCollisionRename
+	case "quarantine":
+		*p = CaseCollisionQuarantine
+	default:
+		*p = CaseCollisionReport
+	}
+	return nil
+}
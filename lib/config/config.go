@@ -20,7 +20,6 @@ import (
 	"strings"
 
 	"github.com/syncthing/syncthing/lib/protocol"
-	"github.com/syncthing/syncthing/lib/rand"
 	"github.com/syncthing/syncthing/lib/upgrade"
 	"github.com/syncthing/syncthing/lib/util"
 )
@@ -70,6 +69,9 @@ func New(myID protocol.DeviceID) Configuration {
 	util.SetDefaults(&cfg)
 	util.SetDefaults(&cfg.Options)
 	util.SetDefaults(&cfg.GUI)
+	util.SetDefaults(&cfg.GUI.OIDC)
+	util.SetDefaults(&cfg.Alerts)
+	util.SetDefaults(&cfg.MQTT)
 
 	// Can't happen.
 	if err := cfg.prepare(myID); err != nil {
@@ -85,6 +87,9 @@ func ReadXML(r io.Reader, myID protocol.DeviceID) (Configuration, error) {
 	util.SetDefaults(&cfg)
 	util.SetDefaults(&cfg.Options)
 	util.SetDefaults(&cfg.GUI)
+	util.SetDefaults(&cfg.GUI.OIDC)
+	util.SetDefaults(&cfg.Alerts)
+	util.SetDefaults(&cfg.MQTT)
 
 	if err := xml.NewDecoder(r).Decode(&cfg); err != nil {
 		return Configuration{}, err
@@ -103,6 +108,9 @@ func ReadJSON(r io.Reader, myID protocol.DeviceID) (Configuration, error) {
 	util.SetDefaults(&cfg)
 	util.SetDefaults(&cfg.Options)
 	util.SetDefaults(&cfg.GUI)
+	util.SetDefaults(&cfg.GUI.OIDC)
+	util.SetDefaults(&cfg.Alerts)
+	util.SetDefaults(&cfg.MQTT)
 
 	bs, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -121,13 +129,18 @@ func ReadJSON(r io.Reader, myID protocol.DeviceID) (Configuration, error) {
 }
 
 type Configuration struct {
-	Version        int                   `xml:"version,attr" json:"version"`
-	Folders        []FolderConfiguration `xml:"folder" json:"folders"`
-	Devices        []DeviceConfiguration `xml:"device" json:"devices"`
-	GUI            GUIConfiguration      `xml:"gui" json:"gui"`
-	Options        OptionsConfiguration  `xml:"options" json:"options"`
-	IgnoredDevices []protocol.DeviceID   `xml:"ignoredDevice" json:"ignoredDevices"`
-	XMLName        xml.Name              `xml:"configuration" json:"-"`
+	Version        int                        `xml:"version,attr" json:"version"`
+	Folders        []FolderConfiguration      `xml:"folder" json:"folders"`
+	Devices        []DeviceConfiguration      `xml:"device" json:"devices"`
+	Groups         []DeviceGroupConfiguration `xml:"group" json:"groups"`
+	GUI            GUIConfiguration           `xml:"gui" json:"gui"`
+	Options        OptionsConfiguration       `xml:"options" json:"options"`
+	Alerts         AlertConfiguration         `xml:"alerts" json:"alerts"`
+	MQTT           MQTTConfiguration          `xml:"mqtt" json:"mqtt"`
+	IgnoredDevices []protocol.DeviceID        `xml:"ignoredDevice" json:"ignoredDevices"`
+	Shares         []ShareConfiguration       `xml:"share" json:"shares"`
+	Webhooks       []WebhookConfiguration     `xml:"webhook" json:"webhooks"`
+	XMLName        xml.Name                   `xml:"configuration" json:"-"`
 
 	OriginalVersion int `xml:"-" json:"-"` // The version we read from disk, before any conversion
 }
@@ -147,12 +160,29 @@ func (cfg Configuration) Copy() Configuration {
 		newCfg.Devices[i] = cfg.Devices[i].Copy()
 	}
 
+	// Deep copy DeviceGroupConfigurations
+	newCfg.Groups = make([]DeviceGroupConfiguration, len(cfg.Groups))
+	for i := range newCfg.Groups {
+		newCfg.Groups[i] = cfg.Groups[i].Copy()
+	}
+
 	newCfg.Options = cfg.Options.Copy()
+	newCfg.GUI = cfg.GUI.Copy()
+	newCfg.Alerts = cfg.Alerts.Copy()
 
 	// DeviceIDs are values
 	newCfg.IgnoredDevices = make([]protocol.DeviceID, len(cfg.IgnoredDevices))
 	copy(newCfg.IgnoredDevices, cfg.IgnoredDevices)
 
+	// ShareConfigurations are values
+	newCfg.Shares = make([]ShareConfiguration, len(cfg.Shares))
+	copy(newCfg.Shares, cfg.Shares)
+
+	newCfg.Webhooks = make([]WebhookConfiguration, len(cfg.Webhooks))
+	for i := range newCfg.Webhooks {
+		newCfg.Webhooks[i] = cfg.Webhooks[i].Copy()
+	}
+
 	return newCfg
 }
 
@@ -204,15 +234,27 @@ func (cfg *Configuration) clean() error {
 	if cfg.Folders == nil {
 		cfg.Folders = []FolderConfiguration{}
 	}
+	if cfg.Groups == nil {
+		cfg.Groups = []DeviceGroupConfiguration{}
+	}
 	if cfg.IgnoredDevices == nil {
 		cfg.IgnoredDevices = []protocol.DeviceID{}
 	}
+	if cfg.Shares == nil {
+		cfg.Shares = []ShareConfiguration{}
+	}
+	if cfg.Webhooks == nil {
+		cfg.Webhooks = []WebhookConfiguration{}
+	}
 	if cfg.Options.AlwaysLocalNets == nil {
 		cfg.Options.AlwaysLocalNets = []string{}
 	}
 	if cfg.Options.UnackedNotificationIDs == nil {
 		cfg.Options.UnackedNotificationIDs = []string{}
 	}
+	if cfg.Options.ExperimentalFeatures == nil {
+		cfg.Options.ExperimentalFeatures = []string{}
+	}
 
 	// Prepare folders and check for duplicates. Duplicates are bad and
 	// dangerous, can't currently be resolved in the GUI, and shouldn't
@@ -267,19 +309,39 @@ func (cfg *Configuration) clean() error {
 
 	// Build a list of available devices
 	existingDevices := make(map[protocol.DeviceID]bool)
+	restrictedDevices := make(map[protocol.DeviceID]DeviceConfiguration)
 	for _, device := range cfg.Devices {
 		existingDevices[device.DeviceID] = true
+		if len(device.AllowedFolders) > 0 {
+			restrictedDevices[device.DeviceID] = device
+		}
 	}
 
 	// Ensure that the device list is free from duplicates
 	cfg.Devices = ensureNoDuplicateDevices(cfg.Devices)
 
+	// Build a list of device groups, ignoring duplicates
+	groups := make(map[string]DeviceGroupConfiguration)
+	uniqueGroups := cfg.Groups[:0]
+	for _, group := range cfg.Groups {
+		if _, ok := groups[group.ID]; ok {
+			l.Warnf("Duplicate device group ID %q in configuration; ignoring.", group.ID)
+			continue
+		}
+		groups[group.ID] = group
+		uniqueGroups = append(uniqueGroups, group)
+	}
+	cfg.Groups = uniqueGroups
+
 	sort.Sort(DeviceConfigurationList(cfg.Devices))
+	// Ensure that folders are shared with the members of their groups
 	// Ensure that any loose devices are not present in the wrong places
 	// Ensure that there are no duplicate devices
 	// Ensure that the versioning configuration parameter map is not nil
 	for i := range cfg.Folders {
+		ensureGroupDevices(&cfg.Folders[i], groups)
 		cfg.Folders[i].Devices = ensureExistingDevices(cfg.Folders[i].Devices, existingDevices)
+		cfg.Folders[i].Devices = ensureAllowedDevices(cfg.Folders[i].ID, cfg.Folders[i].Devices, restrictedDevices)
 		cfg.Folders[i].Devices = ensureNoDuplicateFolderDevices(cfg.Folders[i].Devices)
 		if cfg.Folders[i].Versioning.Params == nil {
 			cfg.Folders[i].Versioning.Params = map[string]string{}
@@ -287,6 +349,9 @@ func (cfg *Configuration) clean() error {
 		sort.Sort(FolderDeviceConfigurationList(cfg.Folders[i].Devices))
 	}
 
+	// Folders waiting for each other would wait forever
+	ensureAcyclicDependencies(cfg.Folders)
+
 	// An empty address list is equivalent to a single "dynamic" entry
 	for i := range cfg.Devices {
 		n := &cfg.Devices[i]
@@ -295,14 +360,54 @@ func (cfg *Configuration) clean() error {
 		}
 	}
 
+	// Sync windows that can't be parsed would otherwise be silently ignored
+	for i := range cfg.Devices {
+		n := &cfg.Devices[i]
+		windows := n.SyncWindows[:0]
+		for _, window := range n.SyncWindows {
+			if _, _, err := parseSyncWindow(window); err != nil {
+				l.Warnf("Device %v: %v; ignoring.", n.DeviceID, err)
+				continue
+			}
+			windows = append(windows, window)
+		}
+		n.SyncWindows = windows
+	}
+
+	// Only a paused device has someone who paused it
+	for i := range cfg.Devices {
+		n := &cfg.Devices[i]
+		if !n.Paused {
+			n.PausedBy, n.PauseReason = "", ""
+		}
+	}
+
+	// There isn't a 29th day in every month
+	for i := range cfg.Devices {
+		n := &cfg.Devices[i]
+		if n.CapResetDay < 0 || n.CapResetDay > 28 {
+			l.Warnf("Device %v: cap reset day %d is not between 1 and 28; using the first of the month.", n.DeviceID, n.CapResetDay)
+			n.CapResetDay = 0
+		}
+	}
+
+	// A misspelled feature would otherwise be silently left disabled
+	for _, name := range cfg.Options.ExperimentalFeatures {
+		if !featureRegistered(name) {
+			l.Warnf("Unknown experimental feature %q; ignoring.", name)
+		}
+	}
+
 	// Very short reconnection intervals are annoying
 	if cfg.Options.ReconnectIntervalS < 5 {
 		cfg.Options.ReconnectIntervalS = 5
 	}
 
-	if cfg.GUI.APIKey == "" {
-		cfg.GUI.APIKey = rand.String(32)
-	}
+	// API keys are only stored hashed. A new key is not generated
+	// automatically, as nobody would know it; see GUIConfiguration.RotateAPIKey.
+	cfg.GUI.hashAPIKey()
+	cfg.GUI.prepareAPITokens()
+	cfg.GUI.prepareAccounts()
 
 	// The list of ignored devices should not contain any devices that have
 	// been manually added to the config.
@@ -317,6 +422,43 @@ func (cfg *Configuration) clean() error {
 	return nil
 }
 
+// ensureAcyclicDependencies removes the folder dependencies that would
+// complete a cycle, including those of folders on themselves.
+func ensureAcyclicDependencies(folders []FolderConfiguration) {
+	deps := make(map[string][]string)
+	for i := range folders {
+		folder := &folders[i]
+		if len(folder.PullAfter) == 0 {
+			continue
+		}
+		pullAfter := folder.PullAfter[:0]
+		for _, dep := range util.UniqueStrings(folder.PullAfter) {
+			if dep == folder.ID || dependsOn(deps, dep, folder.ID, make(map[string]bool)) {
+				l.Warnf("Folder %q: pulling after folder %q would create a dependency cycle; ignoring.", folder.ID, dep)
+				continue
+			}
+			pullAfter = append(pullAfter, dep)
+			deps[folder.ID] = append(deps[folder.ID], dep)
+		}
+		folder.PullAfter = pullAfter
+	}
+}
+
+// dependsOn returns whether the folder depends on the other one, directly
+// or indirectly.
+func dependsOn(deps map[string][]string, folder, other string, seen map[string]bool) bool {
+	if seen[folder] {
+		return false
+	}
+	seen[folder] = true
+	for _, dep := range deps[folder] {
+		if dep == other || dependsOn(deps, dep, other, seen) {
+			return true
+		}
+	}
+	return false
+}
+
 func convertV18V19(cfg *Configuration) {
 	// Triggers a database tweak
 	cfg.Version = 19
@@ -547,6 +689,22 @@ loop:
 	return devices[0:count]
 }
 
+func ensureAllowedDevices(folder string, devices []FolderDeviceConfiguration, restrictedDevices map[protocol.DeviceID]DeviceConfiguration) []FolderDeviceConfiguration {
+	count := len(devices)
+	i := 0
+loop:
+	for i < count {
+		if device, ok := restrictedDevices[devices[i].DeviceID]; ok && !device.FolderAllowed(folder) {
+			l.Warnf("Folder %q is shared with device %v, which is not allowed by its folder policy; unsharing.", folder, device.DeviceID)
+			devices[i] = devices[count-1]
+			count--
+			continue loop
+		}
+		i++
+	}
+	return devices[0:count]
+}
+
 func ensureNoDuplicateFolderDevices(devices []FolderDeviceConfiguration) []FolderDeviceConfiguration {
 	count := len(devices)
 	i := 0
@@ -17,6 +17,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/d4l3k/messagediff"
 	"github.com/syncthing/syncthing/lib/protocol"
@@ -64,7 +65,24 @@ func TestDefaultValues(t *testing.T) {
 		OverwriteRemoteDevNames: false,
 		TempIndexMinBlocks:      10,
 		UnackedNotificationIDs:  []string{},
+		ExperimentalFeatures:    []string{},
 		WeakHashSelectionMethod: WeakHashAuto,
+		MaxClockSkewS:           60,
+		StorageProfile:          StorageProfileDefault,
+		RouteBlockRequests:      false,
+		ColdStorage:             false,
+		PowerProfile:            PowerProfileBalanced,
+		ActivePowerProfile:      PowerProfileBalanced,
+		WebDAVAddress:           "",
+		VirtualCacheMiB:         256,
+		MaxSendKbpsLAN:          0,
+		MaxRecvKbpsLAN:          0,
+		MaxSendKbpsWAN:          0,
+		MaxRecvKbpsWAN:          0,
+		MaxSendKbpsRelay:        0,
+		MaxRecvKbpsRelay:        0,
+		LimiterBurstKiB:         512,
+		ConnectionHistoryDays:   30,
 	}
 
 	cfg := New(device1)
@@ -203,6 +221,23 @@ func TestOverriddenValues(t *testing.T) {
 			"channelNotification", // added in 17->18 migration
 		},
 		WeakHashSelectionMethod: WeakHashNever,
+		MaxClockSkewS:           300,
+		StorageProfile:          StorageProfileFlash,
+		RouteBlockRequests:      true,
+		ColdStorage:             true,
+		PowerProfile:            PowerProfilePowerSaver,
+		ActivePowerProfile:      PowerProfilePowerSaver,
+		WebDAVAddress:           "127.0.0.1:8385",
+		VirtualCacheMiB:         64,
+		MaxSendKbpsLAN:          25000,
+		MaxRecvKbpsLAN:          25000,
+		MaxSendKbpsWAN:          625,
+		MaxRecvKbpsWAN:          1250,
+		MaxSendKbpsRelay:        125,
+		MaxRecvKbpsRelay:        250,
+		LimiterBurstKiB:         1024,
+		ConnectionHistoryDays:   7,
+		ExperimentalFeatures:    []string{"someFeature"},
 	}
 
 	os.Unsetenv("STNOUPGRADE")
@@ -401,7 +436,14 @@ func TestWindowsPaths(t *testing.T) {
 	}
 
 	folder.RawPath = `\\192.0.2.22\network\share`
-	expected = folder.RawPath
+	expected = `\\?\UNC\192.0.2.22\network\share`
+	actual = folder.Path()
+	if actual != expected {
+		t.Errorf("%q != %q", actual, expected)
+	}
+
+	folder.RawPath = `\\192.0.2.22\network`
+	expected = `\\?\UNC\192.0.2.22\network\`
 	actual = folder.Path()
 	if actual != expected {
 		t.Errorf("%q != %q", actual, expected)
@@ -606,6 +648,16 @@ func TestGUIConfigURL(t *testing.T) {
 			t.Errorf("Incorrect URL %s != %s for addr %s", u, tc[1], tc[0])
 		}
 	}
+
+	for _, basePath := range []string{"syncthing", "/syncthing", "/syncthing/"} {
+		c := GUIConfiguration{
+			RawAddress:  "127.0.0.1:8080",
+			RawBasePath: basePath,
+		}
+		if u := c.URL(); u != "http://127.0.0.1:8080/syncthing/" {
+			t.Errorf("Incorrect URL %s for base path %q", u, basePath)
+		}
+	}
 }
 
 func TestDuplicateDevices(t *testing.T) {
@@ -777,3 +829,485 @@ func TestSharesRemovedOnDeviceRemoval(t *testing.T) {
 		t.Error("Unexpected extra device")
 	}
 }
+
+func TestFolderPolicy(t *testing.T) {
+	cfg := Configuration{
+		Devices: []DeviceConfiguration{
+			{DeviceID: device1},
+			{DeviceID: device2, AllowedFolders: []string{"allowed"}},
+			{DeviceID: device3},
+		},
+		Folders: []FolderConfiguration{
+			{
+				ID:      "allowed",
+				Devices: []FolderDeviceConfiguration{{DeviceID: device2}, {DeviceID: device3}},
+			},
+			{
+				ID:      "forbidden",
+				Devices: []FolderDeviceConfiguration{{DeviceID: device2}, {DeviceID: device3}},
+			},
+		},
+	}
+
+	cfg.prepare(device1)
+
+	shared := func(folder FolderConfiguration, id protocol.DeviceID) bool {
+		for _, dev := range folder.DeviceIDs() {
+			if dev == id {
+				return true
+			}
+		}
+		return false
+	}
+	if !shared(cfg.Folders[0], device2) || !shared(cfg.Folders[0], device3) {
+		t.Error("Allowed folder should be shared with devices 2 and 3")
+	}
+	if shared(cfg.Folders[1], device2) {
+		t.Error("Forbidden folder should not be shared with device 2")
+	}
+	if !shared(cfg.Folders[1], device3) {
+		t.Error("Forbidden folder should still be shared with unrestricted device 3")
+	}
+}
+
+func TestAPIKeyHashing(t *testing.T) {
+	cfg := Configuration{
+		GUI: GUIConfiguration{APIKey: "plaintext"},
+	}
+	cfg.prepare(device1)
+
+	if cfg.GUI.APIKey != "" {
+		t.Error("plain text API key should have been removed")
+	}
+	if !cfg.GUI.IsValidAPIKey("plaintext") {
+		t.Error("migrated API key should still be valid")
+	}
+
+	first := cfg.GUI.RotateAPIKey(false)
+	second := cfg.GUI.RotateAPIKey(false)
+	if cfg.GUI.IsValidAPIKey("plaintext") {
+		t.Error("API key should have been rotated out")
+	}
+	if !cfg.GUI.IsValidAPIKey(first) || !cfg.GUI.IsValidAPIKey(second) {
+		t.Error("the two most recent API keys should be valid")
+	}
+	for _, hash := range cfg.GUI.APIKeyHashes {
+		if strings.Contains(hash, first) || strings.Contains(hash, second) {
+			t.Error("API key stored in plain text")
+		}
+	}
+
+	third := cfg.GUI.RotateAPIKey(true)
+	if cfg.GUI.IsValidAPIKey(second) || !cfg.GUI.IsValidAPIKey(third) {
+		t.Error("revoking rotation should leave only the new API key valid")
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	var cfg GUIConfiguration
+	value, err := cfg.AddAPIToken("monitor", []string{APITokenScopeRead}, []string{"default"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.AddAPIToken("monitor", []string{APITokenScopeRead}, nil, time.Now()); err == nil {
+		t.Error("duplicate token name should be refused")
+	}
+	if _, err := cfg.AddAPIToken("other", []string{"everything"}, nil, time.Now()); err == nil {
+		t.Error("unknown scope should be refused")
+	}
+
+	token, ok := cfg.APIToken(value)
+	if !ok || token.Name != "monitor" || !token.HasScope(APITokenScopeRead) || token.HasScope(APITokenScopeControl) {
+		t.Errorf("unexpected token %+v", token)
+	}
+	if strings.Contains(token.Hash, value) {
+		t.Error("API token stored in plain text")
+	}
+	if cfg.IsValidAPIKey(value) {
+		t.Error("API token should not be a valid API key")
+	}
+
+	if !cfg.RevokeAPIToken("monitor") || cfg.RevokeAPIToken("monitor") {
+		t.Error("token should be revoked once")
+	}
+	if _, ok := cfg.APIToken(value); ok {
+		t.Error("revoked token should not be valid")
+	}
+}
+
+func TestGUIAccounts(t *testing.T) {
+	cfg := New(device1)
+	cfg.GUI.User = "admin"
+	cfg.GUI.Accounts = []AccountConfiguration{
+		{Name: "kid", Folders: []string{"homework"}},
+		{Name: "admin"},
+		{Name: ""},
+		{Name: "guest", ReadOnly: true},
+		{Name: "kid"},
+	}
+	cfg.prepare(device1)
+
+	if len(cfg.GUI.Accounts) != 2 || cfg.GUI.Accounts[0].Name != "kid" || cfg.GUI.Accounts[1].Name != "guest" {
+		t.Errorf("Expected the accounts without a name of their own to be dropped, got %+v", cfg.GUI.Accounts)
+	}
+
+	cp := cfg.Copy()
+	cp.GUI.Accounts[0].Folders[0] = "games"
+	if cfg.GUI.Accounts[0].Folders[0] != "homework" {
+		t.Error("Copy shares the folders of the accounts")
+	}
+}
+
+func TestGUIAuthEnabled(t *testing.T) {
+	cases := []struct {
+		gui     GUIConfiguration
+		enabled bool
+	}{
+		{GUIConfiguration{}, false},
+		{GUIConfiguration{User: "user"}, false},
+		{GUIConfiguration{User: "user", Password: "hash"}, true},
+		{GUIConfiguration{AuthMode: AuthModeStatic, User: "user", Password: "hash"}, true},
+		{GUIConfiguration{AuthMode: AuthModeCommand, User: "user", Password: "hash"}, false},
+		{GUIConfiguration{AuthMode: AuthModeCommand, AuthCommand: "/bin/auth"}, true},
+		{GUIConfiguration{Accounts: []AccountConfiguration{{Name: "kid", Password: "hash"}}}, true},
+		{GUIConfiguration{AuthMode: AuthModeOIDC, OIDC: OIDCConfiguration{Issuer: "https://id.example.com"}}, false},
+		{GUIConfiguration{AuthMode: AuthModeOIDC, OIDC: OIDCConfiguration{Issuer: "https://id.example.com", ClientID: "syncthing"}}, true},
+		{GUIConfiguration{AuthMode: "ldap"}, true},
+	}
+
+	for _, tc := range cases {
+		if res := tc.gui.IsAuthEnabled(); res != tc.enabled {
+			t.Errorf("IsAuthEnabled() for %+v = %v, expected %v", tc.gui, res, tc.enabled)
+		}
+	}
+}
+
+func TestSyncWindows(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2017, 1, 2, hour, minute, 0, 0, time.Local)
+	}
+
+	cases := []struct {
+		windows []string
+		t       time.Time
+		in      bool
+	}{
+		{nil, at(12, 0), true},
+		{[]string{"01:00-06:00"}, at(0, 59), false},
+		{[]string{"01:00-06:00"}, at(1, 0), true},
+		{[]string{"01:00-06:00"}, at(5, 59), true},
+		{[]string{"01:00-06:00"}, at(6, 0), false},
+		{[]string{"22:00-06:00"}, at(23, 30), true},
+		{[]string{"22:00-06:00"}, at(3, 0), true},
+		{[]string{"22:00-06:00"}, at(12, 0), false},
+		{[]string{"01:00-06:00", "12:00-13:00"}, at(12, 30), true},
+		{[]string{"18:00-24:00"}, at(23, 59), true},
+		{[]string{"nonsense"}, at(12, 0), false},
+	}
+
+	for _, tc := range cases {
+		dev := DeviceConfiguration{SyncWindows: tc.windows}
+		if in := dev.InSyncWindow(tc.t); in != tc.in {
+			t.Errorf("InSyncWindow(%s) with %v = %v, expected %v", tc.t.Format("15:04"), tc.windows, in, tc.in)
+		}
+	}
+
+	// Invalid windows are dropped from the config
+
+	cfg := Configuration{
+		Devices: []DeviceConfiguration{
+			{DeviceID: device1, SyncWindows: []string{"01:00-06:00", "nonsense", "25:00-26:00", "12:60-13:00"}},
+		},
+	}
+	cfg.prepare(device1)
+	if windows := cfg.Devices[0].SyncWindows; len(windows) != 1 || windows[0] != "01:00-06:00" {
+		t.Errorf("Unexpected sync windows after prepare: %v", windows)
+	}
+}
+
+func TestCapPeriod(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	}
+
+	cases := []struct {
+		resetDay   int
+		t          time.Time
+		start, end time.Time
+	}{
+		{0, date(2017, 3, 15), date(2017, 3, 1), date(2017, 4, 1)},
+		{1, date(2017, 3, 1), date(2017, 3, 1), date(2017, 4, 1)},
+		{15, date(2017, 3, 15), date(2017, 3, 15), date(2017, 4, 15)},
+		{15, date(2017, 3, 14), date(2017, 2, 15), date(2017, 3, 15)},
+		{28, date(2017, 1, 2), date(2016, 12, 28), date(2017, 1, 28)},
+		{31, date(2017, 3, 15), date(2017, 3, 1), date(2017, 4, 1)},
+	}
+
+	for _, tc := range cases {
+		dev := DeviceConfiguration{CapResetDay: tc.resetDay}
+		start, end := dev.CapPeriod(tc.t.Add(time.Hour))
+		if !start.Equal(tc.start) || !end.Equal(tc.end) {
+			t.Errorf("CapPeriod(%s) resetting on day %d = %s to %s, expected %s to %s", tc.t.Format("2006-01-02"), tc.resetDay,
+				start.Format("2006-01-02"), end.Format("2006-01-02"), tc.start.Format("2006-01-02"), tc.end.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestFolderDependencyCycles(t *testing.T) {
+	cfg := Configuration{
+		Folders: []FolderConfiguration{
+			{ID: "a", PullAfter: []string{"a", "b", "missing"}},
+			{ID: "b", PullAfter: []string{"c", "c"}},
+			{ID: "c", PullAfter: []string{"a", "d"}},
+			{ID: "d"},
+		},
+	}
+	cfg.prepare(device1)
+
+	expected := map[string][]string{
+		"a": {"b", "missing"},
+		"b": {"c"},
+		"c": {"d"},
+		"d": nil,
+	}
+	for _, folder := range cfg.Folders {
+		if !reflect.DeepEqual(folder.PullAfter, expected[folder.ID]) && (len(folder.PullAfter) != 0 || len(expected[folder.ID]) != 0) {
+			t.Errorf("Folder %q pulls after %v, expected %v", folder.ID, folder.PullAfter, expected[folder.ID])
+		}
+	}
+}
+
+func TestPriorityPatterns(t *testing.T) {
+	cfg := Configuration{
+		Folders: []FolderConfiguration{
+			{ID: "a", PriorityPatterns: []string{"*.pdf", "[", "docs/*"}},
+		},
+	}
+	cfg.prepare(device1)
+
+	if expected := []string{"*.pdf", "docs/*"}; !reflect.DeepEqual(cfg.Folders[0].PriorityPatterns, expected) {
+		t.Errorf("Priority patterns %v, expected %v", cfg.Folders[0].PriorityPatterns, expected)
+	}
+}
+
+func TestPauseOwners(t *testing.T) {
+	cfg := Configuration{
+		Folders: []FolderConfiguration{
+			{ID: "a", Paused: true, PausedBy: PauseOwnerLowDisk, PauseReason: "under 5% free"},
+			{ID: "b", PausedBy: PauseOwnerUser, PauseReason: "left over"},
+		},
+		Devices: []DeviceConfiguration{
+			{DeviceID: device1},
+			{DeviceID: device2, Paused: true, PausedBy: PauseOwnerSchedule, PauseReason: "office hours"},
+			{DeviceID: device3, PausedBy: PauseOwnerPolicy},
+		},
+	}
+	cfg.prepare(device1)
+
+	if f := cfg.Folders[0]; f.PausedBy != PauseOwnerLowDisk || f.PauseReason != "under 5% free" {
+		t.Errorf("Expected the owner of the paused folder to be kept, got %+v", f)
+	}
+	if f := cfg.Folders[1]; f.PausedBy != "" || f.PauseReason != "" {
+		t.Errorf("Expected the owner of the unpaused folder to be cleared, got %+v", f)
+	}
+	if d := cfg.Devices[1]; d.PausedBy != PauseOwnerSchedule || d.PauseReason != "office hours" {
+		t.Errorf("Expected the owner of the paused device to be kept, got %+v", d)
+	}
+	if d := cfg.Devices[2]; d.PausedBy != "" {
+		t.Errorf("Expected the owner of the unpaused device to be cleared, got %+v", d)
+	}
+}
+
+func TestPauseSchedules(t *testing.T) {
+	cfg := Configuration{
+		Folders: []FolderConfiguration{
+			{ID: "a", PauseSchedules: []string{"* 9-16 * * mon-fri", "* 25 * * *", "0 0 1 1"}},
+		},
+	}
+	cfg.prepare(device1)
+
+	folder := cfg.Folders[0]
+	if expected := []string{"* 9-16 * * mon-fri"}; !reflect.DeepEqual(folder.PauseSchedules, expected) {
+		t.Errorf("Pause schedules %v, expected %v", folder.PauseSchedules, expected)
+	}
+
+	// 2017-06-05 is a Monday
+	cases := []struct {
+		when   string
+		paused bool
+	}{
+		{"2017-06-05 09:00", true},
+		{"2017-06-05 16:59", true},
+		{"2017-06-05 17:00", false},
+		{"2017-06-05 08:59", false},
+		{"2017-06-09 12:00", true},
+		{"2017-06-10 12:00", false},
+		{"2017-06-11 12:00", false},
+	}
+	for _, tc := range cases {
+		when, err := time.ParseInLocation("2006-01-02 15:04", tc.when, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if paused := folder.ScheduledPause(when); paused != tc.paused {
+			t.Errorf("ScheduledPause(%s) = %v, expected %v", tc.when, paused, tc.paused)
+		}
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	cases := []struct {
+		expr string
+		in   []string
+		out  []string
+	}{
+		{"*/15 * * * *", []string{"2017-06-05 10:00", "2017-06-05 10:45"}, []string{"2017-06-05 10:01", "2017-06-05 10:50"}},
+		{"5/20 * * * *", []string{"2017-06-05 10:05", "2017-06-05 10:45"}, []string{"2017-06-05 10:00", "2017-06-05 10:15"}},
+		{"* 22-23,0-5 * * *", []string{"2017-06-05 23:30", "2017-06-06 05:59"}, []string{"2017-06-05 06:00", "2017-06-05 21:59"}},
+		{"* * * jul-aug *", []string{"2017-07-01 00:00", "2017-08-31 23:59"}, []string{"2017-06-30 23:59", "2017-09-01 00:00"}},
+		// Sunday is both 0 and 7
+		{"* * * * 7", []string{"2017-06-11 12:00"}, []string{"2017-06-10 12:00"}},
+		// Either the day of month or of week
+		{"* * 1 * sat", []string{"2017-06-01 12:00", "2017-06-10 12:00"}, []string{"2017-06-02 12:00"}},
+		// Unless either starts with "*", a step over every day
+		{"* * */2 * mon", []string{"2017-06-05 12:00"}, []string{"2017-06-07 12:00", "2017-06-12 12:00"}},
+		{"* * 1 * */2", []string{"2017-06-01 12:00"}, []string{"2017-06-03 12:00", "2017-09-01 12:00"}},
+	}
+	for _, tc := range cases {
+		s, err := ParseSchedule(tc.expr)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %v", tc.expr, err)
+			continue
+		}
+		for _, when := range tc.in {
+			if tm, _ := time.Parse("2006-01-02 15:04", when); !s.Contains(tm) {
+				t.Errorf("%q should contain %s", tc.expr, when)
+			}
+		}
+		for _, when := range tc.out {
+			if tm, _ := time.Parse("2006-01-02 15:04", when); s.Contains(tm) {
+				t.Errorf("%q should not contain %s", tc.expr, when)
+			}
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("ParseSchedule(%q) should fail", expr)
+		}
+	}
+}
+
+func TestMinBlockSize(t *testing.T) {
+	cases := []struct {
+		kib, size int
+	}{
+		{0, protocol.BlockSize},
+		{16, 16 << 10},
+		{64, 64 << 10},
+		{128, 128 << 10},
+		{8, protocol.BlockSize},
+		{48, protocol.BlockSize},
+		{256, protocol.BlockSize},
+		{-16, protocol.BlockSize},
+	}
+
+	for _, tc := range cases {
+		folder := FolderConfiguration{ID: "default", MinBlockSizeKiB: tc.kib}
+		folder.prepare()
+		if size := folder.MinBlockSize(); size != tc.size {
+			t.Errorf("MinBlockSize() with minBlockSizeKiB %d = %d, expected %d", tc.kib, size, tc.size)
+		}
+	}
+}
+
+func TestDeviceGroups(t *testing.T) {
+	cfg := Configuration{
+		Devices: []DeviceConfiguration{
+			{DeviceID: device1},
+			{DeviceID: device2},
+			{DeviceID: device3},
+			{DeviceID: device4, AllowedFolders: []string{"other"}},
+		},
+		Groups: []DeviceGroupConfiguration{
+			{ID: "laptops", Devices: []protocol.DeviceID{device2, device4}},
+		},
+		Folders: []FolderConfiguration{
+			{
+				ID:      "default",
+				Devices: []FolderDeviceConfiguration{{DeviceID: device2}, {DeviceID: device3}},
+				Groups:  []string{"laptops", "missing"},
+			},
+		},
+	}
+	cfg.prepare(device1)
+	w := Wrap("/dev/null", cfg)
+
+	shared := func() map[protocol.DeviceID]bool {
+		res := make(map[protocol.DeviceID]bool)
+		for _, dev := range w.Folders()["default"].Devices {
+			res[dev.DeviceID] = dev.FromGroup
+		}
+		return res
+	}
+
+	// Device 2 is shared with directly, and device 4 isn't allowed
+	expected := map[protocol.DeviceID]bool{device1: false, device2: false, device3: false}
+	if devs := shared(); !reflect.DeepEqual(devs, expected) {
+		t.Errorf("Shared with %v, expected %v", devs, expected)
+	}
+	if groups := w.Folders()["default"].Groups; !reflect.DeepEqual(groups, []string{"laptops"}) {
+		t.Errorf("Unexpected groups %v", groups)
+	}
+
+	// Members joining and leaving the group join and leave the folder
+
+	raw := w.RawCopy()
+	raw.Groups[0].Devices = []protocol.DeviceID{device1, device3}
+	raw.Folders[0].Devices = []FolderDeviceConfiguration{{DeviceID: device1}, {DeviceID: device2}}
+	if err := w.Replace(raw); err != nil {
+		t.Fatal(err)
+	}
+	expected = map[protocol.DeviceID]bool{device1: false, device2: false, device3: true}
+	if devs := shared(); !reflect.DeepEqual(devs, expected) {
+		t.Errorf("Shared with %v, expected %v", devs, expected)
+	}
+
+	raw = w.RawCopy()
+	raw.Groups[0].Devices = []protocol.DeviceID{device1}
+	if err := w.Replace(raw); err != nil {
+		t.Fatal(err)
+	}
+	expected = map[protocol.DeviceID]bool{device1: false, device2: false}
+	if devs := shared(); !reflect.DeepEqual(devs, expected) {
+		t.Errorf("Shared with %v, expected %v", devs, expected)
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	RegisterFeature("testFeatureB", "Does another thing")
+	RegisterFeature("testFeatureA", "Does a thing")
+
+	var names []string
+	for _, feature := range Features() {
+		if strings.HasPrefix(feature.Name, "testFeature") {
+			names = append(names, feature.Name)
+		}
+	}
+	if len(names) != 2 || names[0] != "testFeatureA" || names[1] != "testFeatureB" {
+		t.Errorf("Unexpected registered features: %v", names)
+	}
+
+	cfg := Wrap("/tmp/test", Configuration{
+		Options: OptionsConfiguration{ExperimentalFeatures: []string{"testFeatureA", "unknownFeature"}},
+	})
+	if !cfg.FeatureEnabled("testFeatureA") {
+		t.Error("Enabled feature reported as disabled")
+	}
+	if cfg.FeatureEnabled("testFeatureB") {
+		t.Error("Disabled feature reported as enabled")
+	}
+	if !featureRegistered("testFeatureA") || featureRegistered("unknownFeature") {
+		t.Error("Unexpected registration state")
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// ConflictPolicy decides what happens when a file has been changed on
+// another device in conflict with our version of it.
+type ConflictPolicy int
+
+const (
+	ConflictPolicyCopy        ConflictPolicy = iota // default is to keep ours as a conflict copy
+	ConflictPolicyKeepNewest                        // the most recently modified version wins
+	ConflictPolicyKeepLargest                       // the largest version wins
+	ConflictPolicyKeepLocal                         // our version wins
+	ConflictPolicyKeepRemote                        // the other device's version wins
+)
+
+func (p ConflictPolicy) String() string {
+	switch p {
+	case ConflictPolicyCopy:
+		return "copy"
+	case ConflictPolicyKeepNewest:
+		return "keepNewest"
+	case ConflictPolicyKeepLargest:
+		return "keepLargest"
+	case ConflictPolicyKeepLocal:
+		return "keepLocal"
+	case ConflictPolicyKeepRemote:
+		return "keepRemote"
+	default:
+		return "unknown"
+	}
+}
+
+func (p ConflictPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *ConflictPolicy) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "copy":
+		*p = ConflictPolicyCopy
+	case "keepNewest":
+		*p = ConflictPolicyKeepNewest
+	case "keepLargest":
+		*p = ConflictPolicyKeepLargest
+	case "keepLocal":
+		*p = ConflictPolicyKeepLocal
+	case "keepRemote":
+		*p = ConflictPolicyKeepRemote
+	default:
+		*p = ConflictPolicyCopy
+	}
+	return nil
+}
+
+// ConflictNaming decides how conflict copies are named. Either way the name
+// is that of the file, with ".sync-conflict-" and a time in the format
+// 20060102-150405 inserted before the extension.
+type ConflictNaming int
+
+const (
+	ConflictNamingTime    ConflictNaming = iota // default is the local time the copy was made
+	ConflictNamingVersion                       // the UTC modification time of the copy and the short ID of the device that made it, the same on every device
+)
+
+func (n ConflictNaming) String() string {
+	switch n {
+	case ConflictNamingTime:
+		return "time"
+	case ConflictNamingVersion:
+		return "version"
+	default:
+		return "unknown"
+	}
+}
+
+func (n ConflictNaming) MarshalText() ([]byte, error) {
+	return []byte(n.String()), nil
+}
+
+func (n *ConflictNaming) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "version":
+		*n = ConflictNamingVersion
+	default:
+		*n = ConflictNamingTime
+	}
+	return nil
+}
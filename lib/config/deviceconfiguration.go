@@ -6,7 +6,12 @@
 
 package config
 
-import "github.com/syncthing/syncthing/lib/protocol"
+import (
+	"fmt"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
 
 type DeviceConfiguration struct {
 	DeviceID                 protocol.DeviceID    `xml:"id,attr" json:"deviceID"`
@@ -18,6 +23,17 @@ type DeviceConfiguration struct {
 	SkipIntroductionRemovals bool                 `xml:"skipIntroductionRemovals,attr" json:"skipIntroductionRemovals"`
 	IntroducedBy             protocol.DeviceID    `xml:"introducedBy,attr" json:"introducedBy"`
 	Paused                   bool                 `xml:"paused" json:"paused"`
+	PausedBy                 string               `xml:"pausedBy,omitempty" json:"pausedBy"`            // one of the PauseOwner constants
+	PauseReason              string               `xml:"pauseReason,omitempty" json:"pauseReason"`      // for those wondering whether to resume it
+	AllowedFolders           []string             `xml:"allowedFolder,omitempty" json:"allowedFolders"` // empty means no restriction
+	SyncWindows              []string             `xml:"syncWindow,omitempty" json:"syncWindows"`       // "HH:MM-HH:MM" in local time; empty means always
+	MaxSendKbps              int                  `xml:"maxSendKbps" json:"maxSendKbps"`                // KiB/s to this device, on top of the other limits; 0 for unlimited
+	MaxRecvKbps              int                  `xml:"maxRecvKbps" json:"maxRecvKbps"`                // KiB/s from this device, on top of the other limits; 0 for unlimited
+	ZstdLevel                int                  `xml:"zstdLevel" json:"zstdLevel"`                    // 1 (fastest) to 22 (smallest); 0 for the default, -1 to use LZ4 only
+	InboundOnly              bool                 `xml:"inboundOnly" json:"inboundOnly"`                // never dial the device, only accept its connections
+	MonthlyCapMiB            int                  `xml:"monthlyCapMiB" json:"monthlyCapMiB"`            // MiB sent to and received from this device per month; 0 for no cap
+	CapResetDay              int                  `xml:"capResetDay" json:"capResetDay"`                // the day of the month the cap starts over, 1 to 28; 0 for the first
+	Controller               bool                 `xml:"controller" json:"controller"`                  // accept management commands from the device, changing the config, pausing and resuming, and restarting
 }
 
 func NewDeviceConfiguration(id protocol.DeviceID, name string) DeviceConfiguration {
@@ -31,9 +47,105 @@ func (orig DeviceConfiguration) Copy() DeviceConfiguration {
 	c := orig
 	c.Addresses = make([]string, len(orig.Addresses))
 	copy(c.Addresses, orig.Addresses)
+	if orig.AllowedFolders != nil {
+		c.AllowedFolders = make([]string, len(orig.AllowedFolders))
+		copy(c.AllowedFolders, orig.AllowedFolders)
+	}
+	if orig.SyncWindows != nil {
+		c.SyncWindows = make([]string, len(orig.SyncWindows))
+		copy(c.SyncWindows, orig.SyncWindows)
+	}
 	return c
 }
 
+// FolderAllowed returns true if the local policy permits the given folder to
+// be shared with this device.
+func (cfg DeviceConfiguration) FolderAllowed(folder string) bool {
+	if len(cfg.AllowedFolders) == 0 {
+		return true
+	}
+	for _, allowed := range cfg.AllowedFolders {
+		if allowed == folder {
+			return true
+		}
+	}
+	return false
+}
+
+// CompressionZstdLevel returns the zstd compression level to use with the
+// device, if it supports zstd, or zero to use LZ4.
+func (cfg DeviceConfiguration) CompressionZstdLevel() int {
+	switch {
+	case cfg.ZstdLevel < 0:
+		return 0
+	case cfg.ZstdLevel == 0:
+		return protocol.DefaultZstdLevel
+	case cfg.ZstdLevel > protocol.MaxZstdLevel:
+		return protocol.MaxZstdLevel
+	default:
+		return cfg.ZstdLevel
+	}
+}
+
+// InSyncWindow returns true if we may be connected to the device at the
+// given time, that is if it's within one of the sync windows or there are
+// none. Windows that can't be parsed are ignored.
+func (cfg DeviceConfiguration) InSyncWindow(t time.Time) bool {
+	if len(cfg.SyncWindows) == 0 {
+		return true
+	}
+	minute := t.Hour()*60 + t.Minute()
+	for _, window := range cfg.SyncWindows {
+		start, end, err := parseSyncWindow(window)
+		if err != nil {
+			continue
+		}
+		if start <= end && minute >= start && minute < end {
+			return true
+		}
+		if start > end && (minute >= start || minute < end) {
+			// The window spans midnight
+			return true
+		}
+	}
+	return false
+}
+
+// CapPeriod returns the start and end of the month of transfers, as counted
+// against the monthly cap, that the given time falls in.
+func (cfg DeviceConfiguration) CapPeriod(t time.Time) (start, end time.Time) {
+	day := cfg.CapResetDay
+	if day < 1 || day > 28 {
+		day = 1
+	}
+	start = time.Date(t.Year(), t.Month(), day, 0, 0, 0, 0, t.Location())
+	if t.Before(start) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start, start.AddDate(0, 1, 0)
+}
+
+// CapBytes returns the monthly cap in bytes, or zero for no cap.
+func (cfg DeviceConfiguration) CapBytes() int64 {
+	if cfg.MonthlyCapMiB <= 0 {
+		return 0
+	}
+	return int64(cfg.MonthlyCapMiB) << 20
+}
+
+// parseSyncWindow parses a window such as "22:00-06:00" and returns its
+// start and end as minutes past midnight.
+func parseSyncWindow(window string) (start, end int, err error) {
+	var h1, m1, h2, m2 int
+	if n, _ := fmt.Sscanf(window, "%d:%d-%d:%d", &h1, &m1, &h2, &m2); n != 4 {
+		return 0, 0, fmt.Errorf("sync window %q is not of the form HH:MM-HH:MM", window)
+	}
+	if h1 < 0 || h1 > 24 || h2 < 0 || h2 > 24 || m1 < 0 || m1 > 59 || m2 < 0 || m2 > 59 || h1 == 24 && m1 > 0 || h2 == 24 && m2 > 0 {
+		return 0, 0, fmt.Errorf("sync window %q has an invalid time", window)
+	}
+	return h1*60 + m1, h2*60 + m2, nil
+}
+
 type DeviceConfigurationList []DeviceConfiguration
 
 func (l DeviceConfigurationList) Less(a, b int) bool {
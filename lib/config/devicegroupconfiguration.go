@@ -0,0 +1,68 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/util"
+)
+
+// A DeviceGroupConfiguration is a named set of devices that folders can be
+// shared with as a whole. Folders shared with a group are shared with each
+// of its members, and with the new members as they are added.
+type DeviceGroupConfiguration struct {
+	ID      string              `xml:"id,attr" json:"id"`
+	Name    string              `xml:"name,attr" json:"name"`
+	Devices []protocol.DeviceID `xml:"device" json:"devices"`
+}
+
+func (g DeviceGroupConfiguration) Copy() DeviceGroupConfiguration {
+	c := g
+	c.Devices = make([]protocol.DeviceID, len(g.Devices))
+	copy(c.Devices, g.Devices)
+	return c
+}
+
+// ensureGroupDevices shares the folder with the members of the groups it's
+// shared with. Devices that were added for a group are removed first, so
+// that devices leaving a group also leave its folders.
+func ensureGroupDevices(folder *FolderConfiguration, groups map[string]DeviceGroupConfiguration) {
+	devices := folder.Devices[:0]
+	for _, device := range folder.Devices {
+		if !device.FromGroup {
+			devices = append(devices, device)
+		}
+	}
+
+	present := make(map[protocol.DeviceID]bool, len(devices))
+	for _, device := range devices {
+		present[device.DeviceID] = true
+	}
+
+	folderGroups := folder.Groups[:0]
+	for _, id := range util.UniqueStrings(folder.Groups) {
+		group, ok := groups[id]
+		if !ok {
+			l.Warnf("Folder %q is shared with unknown device group %q; ignoring.", folder.ID, id)
+			continue
+		}
+		folderGroups = append(folderGroups, id)
+		for _, id := range group.Devices {
+			if present[id] {
+				continue
+			}
+			present[id] = true
+			devices = append(devices, FolderDeviceConfiguration{
+				DeviceID:  id,
+				FromGroup: true,
+			})
+		}
+	}
+
+	folder.Devices = devices
+	folder.Groups = folderGroups
+}
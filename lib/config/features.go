@@ -0,0 +1,74 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"sort"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// A Feature is an experimental behaviour that is built in but stays off
+// until enabled by listing its name among the experimental features in
+// the options. Features are registered by the packages implementing them,
+// and checked with FeatureEnabled where the behaviour differs.
+type Feature struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+var (
+	features    = make(map[string]Feature)
+	featuresMut = sync.NewMutex()
+)
+
+// RegisterFeature makes the experimental feature known, to be listed and
+// checked for typos in the config. It's meant to be called from init.
+func RegisterFeature(name, description string) {
+	featuresMut.Lock()
+	defer featuresMut.Unlock()
+	if _, ok := features[name]; ok {
+		panic("experimental feature " + name + " registered twice")
+	}
+	features[name] = Feature{
+		Name:        name,
+		Description: description,
+	}
+}
+
+// Features returns the registered experimental features, sorted by name.
+func Features() []Feature {
+	featuresMut.Lock()
+	defer featuresMut.Unlock()
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	res := make([]Feature, len(names))
+	for i, name := range names {
+		res[i] = features[name]
+	}
+	return res
+}
+
+func featureRegistered(name string) bool {
+	featuresMut.Lock()
+	defer featuresMut.Unlock()
+	_, ok := features[name]
+	return ok
+}
+
+// FeatureEnabled returns true if the experimental feature is enabled.
+func (opts OptionsConfiguration) FeatureEnabled(name string) bool {
+	for _, enabled := range opts.ExperimentalFeatures {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
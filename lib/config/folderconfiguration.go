@@ -11,8 +11,9 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-	"strings"
+	"time"
 
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
 )
@@ -41,7 +42,50 @@ type FolderConfiguration struct {
 	DisableTempIndexes    bool                        `xml:"disableTempIndexes" json:"disableTempIndexes"`
 	Fsync                 bool                        `xml:"fsync" json:"fsync"`
 	Paused                bool                        `xml:"paused" json:"paused"`
+	PausedBy              string                      `xml:"pausedBy" json:"pausedBy"`                         // Who paused the folder, one of the PauseOwner constants.
+	PauseReason           string                      `xml:"pauseReason" json:"pauseReason"`                   // Why the folder was paused, for those wondering whether to resume it.
 	WeakHashThresholdPct  int                         `xml:"weakHashThresholdPct" json:"weakHashThresholdPct"` // Use weak hash if more than X percent of the file has changed. Set to -1 to always use weak hash.
+	MtimeOnlyChanges      bool                        `xml:"mtimeOnlyChanges" json:"mtimeOnlyChanges"`         // When receiving a change of nothing but the modification time, verify the existing data against the new block hashes before keeping it.
+	StrictDeleteOrdering  bool                        `xml:"strictDeleteOrdering" json:"strictDeleteOrdering"` // Postpone deletes until all other changes in the same pull have been applied without error.
+	SnapshotOf            string                      `xml:"snapshotOf" json:"snapshotOf"`                     // The ID of the folder this is a frozen snapshot of. Snapshots are never rescanned.
+	MaxSendKbps           int                         `xml:"maxSendKbps" json:"maxSendKbps"`                   // Limit for block data sent for this folder, on top of the global limit; 0 for unlimited.
+	MaxRecvKbps           int                         `xml:"maxRecvKbps" json:"maxRecvKbps"`                   // Limit for block data received for this folder, on top of the global limit; 0 for unlimited.
+	DetectAppends         bool                        `xml:"detectAppends" json:"detectAppends"`               // When a file grew, check the blocks it had against their weak hashes only, and hash just the rest if they match.
+	WebhookDelayS         int                         `xml:"webhookDelayS" json:"webhookDelayS"`               // How long a device must stay in sync before its completion webhook is called; 0 for the default of ten seconds.
+	PullAfter             []string                    `xml:"pullAfter" json:"pullAfter"`                       // The IDs of folders that must be up to date before this folder pulls.
+	MinBlockSizeKiB       int                         `xml:"minBlockSizeKiB" json:"minBlockSizeKiB"`           // Hash files in blocks of at least this size, a power of two from 16 to 128, while all devices sharing the folder understand that; larger files use larger blocks. 0 for the standard 128 KiB.
+	ConflictPolicy        ConflictPolicy              `xml:"conflictPolicy" json:"conflictPolicy"`
+	Priority              FolderPriority              `xml:"priority" json:"priority"`                 // While a folder of a higher priority is pulling, folders of lower priority pull one block at a time.
+	Groups                []string                    `xml:"group" json:"groups"`                      // The IDs of the device groups the folder is shared with, in addition to its devices.
+	ShareSettings         bool                        `xml:"shareSettings" json:"shareSettings"`       // Send the ignore patterns, versioning and minimum block size to the devices that take them from us.
+	SettingsFrom          protocol.DeviceID           `xml:"settingsFrom" json:"settingsFrom"`         // The device to take the ignore patterns, versioning and minimum block size from, if any.
+	LocalSettings         []string                    `xml:"localSetting" json:"localSettings"`        // The settings to keep as they are rather than take from SettingsFrom.
+	SharedIgnores         bool                        `xml:"sharedIgnores" json:"sharedIgnores"`       // Keep the ignore patterns the same on all devices sharing the folder that set this, taking those changed most recently. Not for patterns taken from SettingsFrom.
+	Virtual               bool                        `xml:"virtual" json:"virtual"`                   // Don't keep the files locally, but fetch their data from the other devices as it's read.
+	PriorityPatterns      []string                    `xml:"priorityPattern" json:"priorityPatterns"`  // Glob patterns of files to pull before the others, in order. Patterns without a slash match the file name in any directory.
+	PauseSchedules        []string                    `xml:"pauseSchedule" json:"pauseSchedules"`      // Cron like expressions of the minutes during which the folder neither scans nor pulls.
+	UseChangeJournal      bool                        `xml:"useChangeJournal" json:"useChangeJournal"` // Rescan only what the filesystem's change journal lists as changed since the last scan, rather than walking the folder. NTFS, requiring administrator rights, or on Linux any filesystem through fanotify, requiring CAP_SYS_ADMIN and CAP_DAC_READ_SEARCH.
+	MaxDiskReadKbps       int                         `xml:"maxDiskReadKbps" json:"maxDiskReadKbps"`   // Limit for reading files in the folder, when scanning, copying blocks and sending them; 0 for unlimited.
+	MaxDiskWriteKbps      int                         `xml:"maxDiskWriteKbps" json:"maxDiskWriteKbps"` // Limit for writing files pulled to the folder; 0 for unlimited.
+	MaxDiskReadIOPS       int                         `xml:"maxDiskReadIOPS" json:"maxDiskReadIOPS"`   // Limit for the number of reads per second, as above; 0 for unlimited.
+	MaxDiskWriteIOPS      int                         `xml:"maxDiskWriteIOPS" json:"maxDiskWriteIOPS"` // Limit for the number of writes per second, as above; 0 for unlimited.
+	MaxFolderSize         int64                       `xml:"maxFolderSize" json:"maxFolderSize"`       // The most bytes the local copy of the folder may take; files that would take it over aren't pulled. 0 for unlimited.
+	StandbyDevices        []protocol.DeviceID         `xml:"standbyDevice" json:"standbyDevices"`      // On a standby, the devices the primary shares the folder with, to share it with when promoted.
+	StandbyPaused         bool                        `xml:"standbyPaused" json:"standbyPaused"`       // On a standby, whether the primary has the folder paused.
+	CaseCollisions        CaseCollisionPolicy         `xml:"caseCollisions" json:"caseCollisions"`     // What to do after each scan about files whose names collide on case insensitive filesystems.
+	ShareLinks            bool                        `xml:"shareLinks" json:"shareLinks"`             // Allow links to the folder's files, served read only by the share gateway to anyone with the link.
+	ScanCheckpointS       int                         `xml:"scanCheckpointS" json:"scanCheckpointS"`   // How often to record how far a full scan has got, so that it resumes from there if interrupted by a restart; 0 to not record it.
+	ScanStreamBatch       int                         `xml:"scanStreamBatch" json:"scanStreamBatch"`   // Hash files as soon as a scan finds them, and announce them to other devices in batches of this many, so that they sync while the scan goes on; 0 to hash once the walk is done and announce in batches of 100 files or 256 MiB.
+	Placeholders          bool                        `xml:"placeholders" json:"placeholders"`         // Sync only the metadata of files, keeping empty placeholders on disk until their data is fetched on demand.
+	ConflictNaming        ConflictNaming              `xml:"conflictNaming" json:"conflictNaming"`     // How conflict copies are named: by when they were made, or by the version they keep, the same on every device.
+	ConflictSidecars      bool                        `xml:"conflictSidecars" json:"conflictSidecars"` // Write a JSON file describing both versions next to each conflict copy, for tools that resolve conflicts.
+	PrePullCommand        string                      `xml:"prePullCommand" json:"prePullCommand"`     // Run before each file, directory or symlink is synced, in the folder, with the STFOLDER, STFOLDERPATH, STPATH, STTYPE and STACTION environment variables describing it.
+	PostPullCommand       string                      `xml:"postPullCommand" json:"postPullCommand"`   // Run after each item is synced, with the same environment variables as well as STRESULT, "success" or "failure", and STERROR.
+	PullHookTimeoutS      int                         `xml:"pullHookTimeoutS" json:"pullHookTimeoutS"` // How long a pull hook may run before it's killed and counts as failed; 0 for the default of a minute.
+	PullHookFailure       PullHookFailure             `xml:"pullHookFailure" json:"pullHookFailure"`   // What a pull hook failing means for the item.
+	MergeCommand          string                      `xml:"mergeCommand" json:"mergeCommand"`         // Run to merge the versions of a file changed in conflict, rather than keep a conflict copy, for the files matching MergePatterns. It gets the versions to merge, and where to write the result, in the STOURS, STTHEIRS, STBASE and STMERGED environment variables.
+	MergePatterns         []string                    `xml:"mergePattern" json:"mergePatterns"`        // Glob patterns of the files to merge, as for PriorityPatterns.
+	WriteOnce             bool                        `xml:"writeOnce" json:"writeOnce"`               // Never let changes from other devices modify or delete the files we have; only new files are pulled. The refused changes are listed as errors.
 
 	cachedPath string
 
@@ -51,8 +95,22 @@ type FolderConfiguration struct {
 type FolderDeviceConfiguration struct {
 	DeviceID     protocol.DeviceID `xml:"id,attr" json:"deviceID"`
 	IntroducedBy protocol.DeviceID `xml:"introducedBy,attr" json:"introducedBy"`
+	// A URL that is POSTed to when the device has become fully in sync
+	// with the folder. For our own device, that's when we have all of it.
+	CompletionWebhook string `xml:"completionWebhook,attr,omitempty" json:"completionWebhook"`
+	// Set when the folder is shared with the device because it's a member
+	// of one of the folder's groups, rather than directly.
+	FromGroup bool `xml:"fromGroup,attr,omitempty" json:"fromGroup"`
 }
 
+// The folder settings that can be taken from another device, by the names
+// used in LocalSettings.
+const (
+	FolderSettingIgnores      = "ignores"
+	FolderSettingVersioning   = "versioning"
+	FolderSettingMinBlockSize = "minBlockSize"
+)
+
 func NewFolderConfiguration(id, path string) FolderConfiguration {
 	f := FolderConfiguration{
 		ID:      id,
@@ -67,9 +125,37 @@ func (f FolderConfiguration) Copy() FolderConfiguration {
 	c.Devices = make([]FolderDeviceConfiguration, len(f.Devices))
 	copy(c.Devices, f.Devices)
 	c.Versioning = f.Versioning.Copy()
+	c.PullAfter = make([]string, len(f.PullAfter))
+	copy(c.PullAfter, f.PullAfter)
+	c.Groups = make([]string, len(f.Groups))
+	copy(c.Groups, f.Groups)
+	c.LocalSettings = make([]string, len(f.LocalSettings))
+	copy(c.LocalSettings, f.LocalSettings)
+	c.PriorityPatterns = make([]string, len(f.PriorityPatterns))
+	copy(c.PriorityPatterns, f.PriorityPatterns)
+	c.MergePatterns = make([]string, len(f.MergePatterns))
+	copy(c.MergePatterns, f.MergePatterns)
+	c.PauseSchedules = make([]string, len(f.PauseSchedules))
+	copy(c.PauseSchedules, f.PauseSchedules)
+	c.StandbyDevices = make([]protocol.DeviceID, len(f.StandbyDevices))
+	copy(c.StandbyDevices, f.StandbyDevices)
 	return c
 }
 
+// TakesSetting returns whether the setting is taken from the device named
+// by SettingsFrom, rather than kept local.
+func (f FolderConfiguration) TakesSetting(setting string) bool {
+	if f.SettingsFrom == protocol.EmptyDeviceID {
+		return false
+	}
+	for _, local := range f.LocalSettings {
+		if local == setting {
+			return false
+		}
+	}
+	return true
+}
+
 func (f FolderConfiguration) Path() string {
 	// This is intentionally not a pointer method, because things like
 	// cfg.Folders["default"].Path() should be valid.
@@ -147,9 +233,67 @@ func (f *FolderConfiguration) prepare() {
 		f.Versioning.Params = make(map[string]string)
 	}
 
+	// Only a paused folder has someone who paused it
+	if !f.Paused {
+		f.PausedBy, f.PauseReason = "", ""
+	}
+
 	if f.WeakHashThresholdPct == 0 {
 		f.WeakHashThresholdPct = 25
 	}
+
+	if size := f.MinBlockSizeKiB << 10; size != 0 && (size < protocol.MinBlockSize || size > protocol.BlockSize || size&(size-1) != 0) {
+		f.MinBlockSizeKiB = 0
+	}
+
+	patterns := f.PriorityPatterns[:0]
+	for _, pattern := range f.PriorityPatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			l.Warnf("Folder %q has invalid priority pattern %q; ignoring.", f.ID, pattern)
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	f.PriorityPatterns = patterns
+
+	patterns = f.MergePatterns[:0]
+	for _, pattern := range f.MergePatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			l.Warnf("Folder %q has invalid merge pattern %q; ignoring.", f.ID, pattern)
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	f.MergePatterns = patterns
+
+	schedules := f.PauseSchedules[:0]
+	for _, schedule := range f.PauseSchedules {
+		if _, err := ParseSchedule(schedule); err != nil {
+			l.Warnf("Folder %q has invalid pause schedule: %v; ignoring.", f.ID, err)
+			continue
+		}
+		schedules = append(schedules, schedule)
+	}
+	f.PauseSchedules = schedules
+}
+
+// ScheduledPause returns whether the folder is to be paused at the time
+// according to its pause schedules.
+func (f FolderConfiguration) ScheduledPause(t time.Time) bool {
+	for _, expr := range f.PauseSchedules {
+		if schedule, err := ParseSchedule(expr); err == nil && schedule.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// MinBlockSize returns the smallest block size to hash files in, in bytes.
+func (f FolderConfiguration) MinBlockSize() int {
+	if f.MinBlockSizeKiB == 0 {
+		return protocol.BlockSize
+	}
+	return f.MinBlockSizeKiB << 10
 }
 
 func (f *FolderConfiguration) cleanedPath() string {
@@ -174,10 +318,11 @@ func (f *FolderConfiguration) cleanedPath() string {
 		}
 	}
 
-	// Attempt to enable long filename support on Windows. We may still not
-	// have an absolute path here if the previous steps failed.
-	if runtime.GOOS == "windows" && filepath.IsAbs(cleaned) && !strings.HasPrefix(f.RawPath, `\\`) {
-		return `\\?\` + cleaned
+	// Attempt to enable long filename support on Windows, including for
+	// UNC paths. We may still not have an absolute path here if the
+	// previous steps failed, in which case it's left as is.
+	if runtime.GOOS == "windows" {
+		return fs.LongFilename(cleaned)
 	}
 
 	// If we're not on Windows, we want the path to end with a slash to
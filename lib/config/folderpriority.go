@@ -0,0 +1,47 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// FolderPriority is the class deciding how folders share the puller
+// workers and bandwidth when several of them need data at once. Higher
+// values take precedence.
+type FolderPriority int
+
+const (
+	FolderPriorityLow    FolderPriority = -1
+	FolderPriorityNormal FolderPriority = 0 // default
+	FolderPriorityHigh   FolderPriority = 1
+)
+
+func (p FolderPriority) String() string {
+	switch p {
+	case FolderPriorityLow:
+		return "low"
+	case FolderPriorityNormal:
+		return "normal"
+	case FolderPriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+func (p FolderPriority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *FolderPriority) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "low":
+		*p = FolderPriorityLow
+	case "high":
+		*p = FolderPriorityHigh
+	default:
+		*p = FolderPriorityNormal
+	}
+	return nil
+}
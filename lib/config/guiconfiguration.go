@@ -7,22 +7,59 @@
 package config
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
 	"net/url"
 	"os"
 	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/rand"
+)
+
+const (
+	apiKeyHashPrefix = "sha256:"
+	// The number of API keys that remain valid after a rotation, so that
+	// clients can switch over to the new key at their leisure.
+	maxActiveAPIKeys = 2
+)
+
+// Ways of authenticating users of the GUI and API, as set in AuthMode.
+const (
+	AuthModeStatic  = "static"  // the user and password in the config; the default
+	AuthModeCommand = "command" // an external command, e.g. a wrapper around PAM or LDAP tools
+	AuthModeOIDC    = "oidc"    // an OpenID Connect provider, for single sign-on
 )
 
 type GUIConfiguration struct {
-	Enabled               bool   `xml:"enabled,attr" json:"enabled" default:"true"`
-	RawAddress            string `xml:"address" json:"address" default:"127.0.0.1:8384"`
-	User                  string `xml:"user,omitempty" json:"user"`
-	Password              string `xml:"password,omitempty" json:"password"`
-	RawUseTLS             bool   `xml:"tls,attr" json:"useTLS"`
-	APIKey                string `xml:"apikey,omitempty" json:"apiKey"`
-	InsecureAdminAccess   bool   `xml:"insecureAdminAccess,omitempty" json:"insecureAdminAccess"`
-	Theme                 string `xml:"theme" json:"theme" default:"default"`
-	Debugging             bool   `xml:"debugging,attr" json:"debugging"`
-	InsecureSkipHostCheck bool   `xml:"insecureSkipHostcheck,omitempty" json:"insecureSkipHostcheck"`
+	Enabled               bool                    `xml:"enabled,attr" json:"enabled" default:"true"`
+	RawAddress            string                  `xml:"address" json:"address" default:"127.0.0.1:8384"`
+	User                  string                  `xml:"user,omitempty" json:"user"`
+	Password              string                  `xml:"password,omitempty" json:"password"`
+	RawUseTLS             bool                    `xml:"tls,attr" json:"useTLS"`
+	APIKey                string                  `xml:"apikey,omitempty" json:"apiKey"` // plain text; hashed into APIKeyHashes when loaded
+	APIKeyHashes          []string                `xml:"apikeyHash,omitempty" json:"apiKeyHashes"`
+	InsecureAdminAccess   bool                    `xml:"insecureAdminAccess,omitempty" json:"insecureAdminAccess"`
+	Theme                 string                  `xml:"theme" json:"theme" default:"default"`
+	AssetDir              string                  `xml:"assetDir,omitempty" json:"assetDir"` // files here override the compiled in GUI assets, per theme
+	Debugging             bool                    `xml:"debugging,attr" json:"debugging"`
+	InsecureSkipHostCheck bool                    `xml:"insecureSkipHostcheck,omitempty" json:"insecureSkipHostcheck"`
+	SessionLifetimeS      int                     `xml:"sessionLifetimeS,omitempty" json:"sessionLifetimeS"`       // 0 for until restart
+	SessionIdleTimeoutS   int                     `xml:"sessionIdleTimeoutS,omitempty" json:"sessionIdleTimeoutS"` // 0 for none
+	MaxSessions           int                     `xml:"maxSessions,omitempty" json:"maxSessions"`                 // 0 for unlimited
+	RawBasePath           string                  `xml:"basePath,omitempty" json:"basePath"`                       // when served in a subdirectory by a reverse proxy
+	TrustedProxies        []string                `xml:"trustedProxy,omitempty" json:"trustedProxies"`             // addresses or networks allowed to set X-Forwarded-For
+	StrictCSP             bool                    `xml:"strictCSP,omitempty" json:"strictCSP"`
+	Language              string                  `xml:"language,omitempty" json:"language"`       // overrides the language negotiated with the browser
+	AuthMode              string                  `xml:"authMode,omitempty" json:"authMode"`       // one of the AuthMode constants
+	AuthCommand           string                  `xml:"authCommand,omitempty" json:"authCommand"` // for AuthModeCommand; given the username and password on stdin, may print the role and folders
+	APITokens             []APITokenConfiguration `xml:"apiToken,omitempty" json:"apiTokens"`      // named keys to the REST API, each limited in what it may do
+	OIDC                  OIDCConfiguration       `xml:"oidc" json:"oidc"`                         // for AuthModeOIDC
+	Accounts              []AccountConfiguration  `xml:"account,omitempty" json:"accounts"`        // for AuthModeStatic, users besides the main one, limited to some folders
 }
 
 func (c GUIConfiguration) Address() string {
@@ -57,7 +94,7 @@ func (c GUIConfiguration) URL() string {
 	u := url.URL{
 		Scheme: "http",
 		Host:   c.Address(),
-		Path:   "/",
+		Path:   c.BasePath() + "/",
 	}
 
 	if c.UseTLS() {
@@ -78,8 +115,58 @@ func (c GUIConfiguration) URL() string {
 	return u.String()
 }
 
+// BasePath returns the path prefix the GUI is served under, with a leading
+// but no trailing slash, or the empty string when served at the root.
+func (c GUIConfiguration) BasePath() string {
+	p := strings.Trim(c.RawBasePath, "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// TrustedProxyNets returns the parsed list of trusted proxies. Single
+// addresses are returned as networks containing just that address.
+// Entries that can't be parsed are skipped.
+func (c GUIConfiguration) TrustedProxyNets() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, proxy := range c.TrustedProxies {
+		if _, ipnet, err := net.ParseCIDR(proxy); err == nil {
+			nets = append(nets, ipnet)
+			continue
+		}
+		ip := net.ParseIP(proxy)
+		if ip == nil {
+			l.Infoln("Skipping invalid trusted proxy", proxy)
+			continue
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets
+}
+
+// IsAuthEnabled returns true if users must log in to use the GUI and the API
+// without an API key. An unknown auth mode counts as enabled, so that a typo
+// doesn't open up the GUI.
+func (c GUIConfiguration) IsAuthEnabled() bool {
+	switch c.AuthMode {
+	case "", AuthModeStatic:
+		return c.User != "" && c.Password != "" || len(c.Accounts) > 0
+	case AuthModeCommand:
+		return c.AuthCommand != ""
+	case AuthModeOIDC:
+		return c.OIDC.Issuer != "" && c.OIDC.ClientID != ""
+	default:
+		return true
+	}
+}
+
 // IsValidAPIKey returns true when the given API key is valid, including both
-// the value in config and any overrides
+// the values in config and any overrides
 func (c GUIConfiguration) IsValidAPIKey(apiKey string) bool {
 	switch apiKey {
 	case "":
@@ -87,8 +174,173 @@ func (c GUIConfiguration) IsValidAPIKey(apiKey string) bool {
 
 	case c.APIKey, os.Getenv("STGUIAPIKEY"):
 		return true
+	}
 
-	default:
-		return false
+	hash := []byte(HashAPIKey(apiKey))
+	valid := false
+	for _, stored := range c.APIKeyHashes {
+		if subtle.ConstantTimeCompare(hash, []byte(stored)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// HashAPIKey returns the form in which an API key is stored in the config.
+// The keys are long random strings, so a plain hash is as good as any.
+func HashAPIKey(apiKey string) string {
+	hash := sha256.Sum256([]byte(apiKey))
+	return apiKeyHashPrefix + hex.EncodeToString(hash[:])
+}
+
+// RotateAPIKey generates a new API key, stores its hash and returns the key
+// itself, which can't be recovered from the config later. The previous key
+// stays valid as well, unless revokeOthers is set.
+func (c *GUIConfiguration) RotateAPIKey(revokeOthers bool) string {
+	c.hashAPIKey()
+	apiKey := rand.String(32)
+	hashes := append(c.APIKeyHashes, HashAPIKey(apiKey))
+	keep := maxActiveAPIKeys
+	if revokeOthers {
+		keep = 1
+	}
+	if len(hashes) > keep {
+		hashes = hashes[len(hashes)-keep:]
+	}
+	c.APIKeyHashes = hashes
+	return apiKey
+}
+
+// APIToken returns the API token with the given value, if there is one.
+func (c GUIConfiguration) APIToken(value string) (APITokenConfiguration, bool) {
+	if value == "" {
+		return APITokenConfiguration{}, false
+	}
+	hash := []byte(HashAPIKey(value))
+	var found APITokenConfiguration
+	ok := false
+	for _, token := range c.APITokens {
+		if subtle.ConstantTimeCompare(hash, []byte(token.Hash)) == 1 {
+			found, ok = token, true
+		}
+	}
+	return found, ok
+}
+
+// AddAPIToken generates a new API token with the name, scopes and folders,
+// stores its hash and returns the token itself, which can't be recovered
+// from the config later.
+func (c *GUIConfiguration) AddAPIToken(name string, scopes, folders []string, now time.Time) (string, error) {
+	if name == "" {
+		return "", errors.New("an API token needs a name")
+	}
+	for _, token := range c.APITokens {
+		if token.Name == name {
+			return "", fmt.Errorf("there is already an API token named %q", name)
+		}
+	}
+	if len(scopes) == 0 {
+		return "", errors.New("an API token needs at least one scope")
+	}
+	for _, scope := range scopes {
+		if !validAPITokenScope(scope) {
+			return "", fmt.Errorf("unknown API token scope %q", scope)
+		}
+	}
+
+	value := rand.String(32)
+	c.APITokens = append(c.APITokens, APITokenConfiguration{
+		Name:    name,
+		Hash:    HashAPIKey(value),
+		Scopes:  scopes,
+		Folders: folders,
+		Created: now,
+	})
+	return value, nil
+}
+
+// RevokeAPIToken removes the API token with the name, returning false if
+// there is none.
+func (c *GUIConfiguration) RevokeAPIToken(name string) bool {
+	for i, token := range c.APITokens {
+		if token.Name == name {
+			c.APITokens = append(c.APITokens[:i:i], c.APITokens[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// prepareAPITokens drops the unknown scopes of the API tokens.
+func (c *GUIConfiguration) prepareAPITokens() {
+	for i, token := range c.APITokens {
+		scopes := token.Scopes[:0]
+		for _, scope := range token.Scopes {
+			if !validAPITokenScope(scope) {
+				l.Warnf("API token %q has unknown scope %q; ignoring.", token.Name, scope)
+				continue
+			}
+			scopes = append(scopes, scope)
+		}
+		c.APITokens[i].Scopes = scopes
+	}
+}
+
+// prepareAccounts drops the accounts without a name, or with the name of
+// the main user or of another account, as it's not clear who's who.
+func (c *GUIConfiguration) prepareAccounts() {
+	seen := map[string]bool{
+		c.User: true,
+	}
+	accounts := c.Accounts[:0]
+	for _, account := range c.Accounts {
+		if account.Name == "" || seen[account.Name] {
+			l.Warnf("GUI account %q lacks a name, or has that of another user; ignoring.", account.Name)
+			continue
+		}
+		seen[account.Name] = true
+		accounts = append(accounts, account)
+	}
+	c.Accounts = accounts
+}
+
+// hashAPIKey replaces a plain text API key with its hash.
+func (c *GUIConfiguration) hashAPIKey() {
+	if c.APIKey == "" {
+		return
+	}
+	hash := HashAPIKey(c.APIKey)
+	c.APIKey = ""
+	for _, existing := range c.APIKeyHashes {
+		if existing == hash {
+			return
+		}
+	}
+	c.APIKeyHashes = append(c.APIKeyHashes, hash)
+}
+
+func (c GUIConfiguration) Copy() GUIConfiguration {
+	cp := c
+	if c.APIKeyHashes != nil {
+		cp.APIKeyHashes = make([]string, len(c.APIKeyHashes))
+		copy(cp.APIKeyHashes, c.APIKeyHashes)
+	}
+	if c.TrustedProxies != nil {
+		cp.TrustedProxies = make([]string, len(c.TrustedProxies))
+		copy(cp.TrustedProxies, c.TrustedProxies)
+	}
+	if c.APITokens != nil {
+		cp.APITokens = make([]APITokenConfiguration, len(c.APITokens))
+		for i, token := range c.APITokens {
+			cp.APITokens[i] = token.Copy()
+		}
+	}
+	cp.OIDC = c.OIDC.Copy()
+	if c.Accounts != nil {
+		cp.Accounts = make([]AccountConfiguration, len(c.Accounts))
+		for i, account := range c.Accounts {
+			cp.Accounts[i] = account.Copy()
+		}
 	}
+	return cp
 }
@@ -0,0 +1,21 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// MQTTConfiguration describes the MQTT broker that the state of folders and
+// devices is published to, for home automation and the like. Topics are
+// under the prefix, such as "syncthing/folder/default/state".
+type MQTTConfiguration struct {
+	Enabled     bool   `xml:"enabled,attr" json:"enabled"`
+	Broker      string `xml:"broker" json:"broker"`               // tcp://host:1883, or tls://host:8883
+	ClientID    string `xml:"clientID,omitempty" json:"clientID"` // empty for "syncthing-" and the short device ID
+	Username    string `xml:"username,omitempty" json:"username"`
+	Password    string `xml:"password,omitempty" json:"password"`
+	TopicPrefix string `xml:"topicPrefix" json:"topicPrefix" default:"syncthing"`
+	QoS         int    `xml:"qos" json:"qos"`                      // 0 or 1
+	Retain      bool   `xml:"retain" json:"retain" default:"true"` // so that subscribers get the current state when they subscribe
+}
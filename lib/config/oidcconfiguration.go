@@ -0,0 +1,40 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// OIDCConfiguration describes the OpenID Connect provider that users log in
+// to the GUI with, in AuthModeOIDC, and how their roles follow from the
+// groups the provider says they're in. Users in neither the admin nor the
+// read only groups are refused, even when the provider lets them log in.
+type OIDCConfiguration struct {
+	Issuer         string   `xml:"issuer,omitempty" json:"issuer"` // such as https://accounts.example.com, where the discovery document is found
+	ClientID       string   `xml:"clientID,omitempty" json:"clientID"`
+	ClientSecret   string   `xml:"clientSecret,omitempty" json:"clientSecret"`
+	RedirectURL    string   `xml:"redirectURL,omitempty" json:"redirectURL"` // the GUI's address and /oidc/callback; empty to use the one the browser sees
+	Scopes         []string `xml:"scope,omitempty" json:"scopes"`            // requested besides openid, such as profile, email or groups
+	UsernameClaim  string   `xml:"usernameClaim" json:"usernameClaim" default:"preferred_username"`
+	GroupsClaim    string   `xml:"groupsClaim" json:"groupsClaim" default:"groups"`
+	AdminGroups    []string `xml:"adminGroup,omitempty" json:"adminGroups"`
+	ReadOnlyGroups []string `xml:"readOnlyGroup,omitempty" json:"readOnlyGroups"`
+}
+
+func (c OIDCConfiguration) Copy() OIDCConfiguration {
+	cp := c
+	if c.Scopes != nil {
+		cp.Scopes = make([]string, len(c.Scopes))
+		copy(cp.Scopes, c.Scopes)
+	}
+	if c.AdminGroups != nil {
+		cp.AdminGroups = make([]string, len(c.AdminGroups))
+		copy(cp.AdminGroups, c.AdminGroups)
+	}
+	if c.ReadOnlyGroups != nil {
+		cp.ReadOnlyGroups = make([]string, len(c.ReadOnlyGroups))
+		copy(cp.ReadOnlyGroups, c.ReadOnlyGroups)
+	}
+	return cp
+}
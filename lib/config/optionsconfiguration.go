@@ -10,6 +10,8 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+
+	"github.com/syncthing/syncthing/lib/protocol"
 )
 
 type WeakHashSelectionMethod int
@@ -94,6 +96,13 @@ func (WeakHashSelectionMethod) ParseDefault(value string) (interface{}, error) {
 	return m, err
 }
 
+const (
+	StorageProfileDefault = "default"
+	// The flash storage profile trades memory and latency for fewer and
+	// larger writes, to reduce wear on eMMC, SD cards and the like.
+	StorageProfileFlash = "flash"
+)
+
 type OptionsConfiguration struct {
 	ListenAddresses         []string                `xml:"listenAddress" json:"listenAddresses" default:"default"`
 	GlobalAnnServers        []string                `xml:"globalAnnounceServer" json:"globalAnnounceServers" json:"globalAnnounceServer" default:"default"`
@@ -131,6 +140,29 @@ type OptionsConfiguration struct {
 	UnackedNotificationIDs  []string                `xml:"unackedNotificationID" json:"unackedNotificationIDs"`
 	TrafficClass            int                     `xml:"trafficClass" json:"trafficClass"`
 	WeakHashSelectionMethod WeakHashSelectionMethod `xml:"weakHashSelectionMethod" json:"weakHashSelectionMethod"`
+	MaxClockSkewS           int                     `xml:"maxClockSkewS" json:"maxClockSkewS" default:"60"`        // warn about and correct for larger skews; 0 for off
+	StorageProfile          string                  `xml:"storageProfile" json:"storageProfile" default:"default"` // "default", or "flash" for fewer, larger writes
+	RouteBlockRequests      bool                    `xml:"routeBlockRequests" json:"routeBlockRequests"`           // fetch blocks via, and for, devices in between when not connected to the source
+	ColdStorage             bool                    `xml:"coldStorage" json:"coldStorage"`                         // tell other devices to request data from us only as a last resort
+	PowerProfile            PowerProfile            `xml:"powerProfile" json:"powerProfile"`
+	ActivePowerProfile      PowerProfile            `xml:"-" json:"activePowerProfile"`                                     // the power profile resolved for the current power state
+	WebDAVAddress           string                  `xml:"webdavAddress" json:"webdavAddress"`                              // serve the folders over WebDAV on this address; empty for off
+	ShareGatewayAddress     string                  `xml:"shareGatewayAddress" json:"shareGatewayAddress"`                  // serve share links to the folders that allow them on this address; empty for off
+	ShareGatewayTLS         bool                    `xml:"shareGatewayTLS" json:"shareGatewayTLS"`                          // serve share links over HTTPS, with the GUI's certificate
+	VirtualCacheMiB         int                     `xml:"virtualCacheMiB" json:"virtualCacheMiB" default:"256"`            // how much of the data read from virtual folders to keep in memory
+	MaxSendKbpsLAN          int                     `xml:"maxSendKbpsLAN" json:"maxSendKbpsLAN"`                            // send limit for connections over LAN addresses, on top of maxSendKbps; 0 for unlimited
+	MaxRecvKbpsLAN          int                     `xml:"maxRecvKbpsLAN" json:"maxRecvKbpsLAN"`                            // receive limit for connections over LAN addresses, on top of maxRecvKbps; 0 for unlimited
+	MaxSendKbpsWAN          int                     `xml:"maxSendKbpsWAN" json:"maxSendKbpsWAN"`                            // send limit for direct connections over other addresses
+	MaxRecvKbpsWAN          int                     `xml:"maxRecvKbpsWAN" json:"maxRecvKbpsWAN"`                            // receive limit for direct connections over other addresses
+	MaxSendKbpsRelay        int                     `xml:"maxSendKbpsRelay" json:"maxSendKbpsRelay"`                        // send limit for relayed connections
+	MaxRecvKbpsRelay        int                     `xml:"maxRecvKbpsRelay" json:"maxRecvKbpsRelay"`                        // receive limit for relayed connections
+	LimiterBurstKiB         int                     `xml:"limiterBurstKiB" json:"limiterBurstKiB" default:"512"`            // how much data may be sent or received at once before the rate limits apply
+	SharedBlockIndex        bool                    `xml:"sharedBlockIndex" json:"sharedBlockIndex"`                        // index the blocks of all folders together, to find blocks to copy from other folders faster
+	StandbyFor              protocol.DeviceID       `xml:"standbyFor" json:"standbyFor"`                                    // mirror the folders and devices of this device, to take over from it when promoted
+	StandbyFolderPath       string                  `xml:"standbyFolderPath" json:"standbyFolderPath"`                      // where to put the folders mirrored from the primary; empty for the home directory
+	UseKeychain             bool                    `xml:"useKeychain" json:"useKeychain"`                                  // keep the GUI and SMTP passwords in the operating system's secret store, with references to them in the config
+	ConnectionHistoryDays   int                     `xml:"connectionHistoryDays" json:"connectionHistoryDays" default:"30"` // how long to keep the history of connections to each device; 0 for none
+	ExperimentalFeatures    []string                `xml:"experimentalFeature" json:"experimentalFeatures"`                 // names of experimental features to enable, as listed at /rest/system/features
 
 	DeprecatedUPnPEnabled  bool     `xml:"upnpEnabled,omitempty" json:"-"`
 	DeprecatedUPnPLeaseM   int      `xml:"upnpLeaseMinutes,omitempty" json:"-"`
@@ -149,5 +181,7 @@ func (orig OptionsConfiguration) Copy() OptionsConfiguration {
 	copy(c.AlwaysLocalNets, orig.AlwaysLocalNets)
 	c.UnackedNotificationIDs = make([]string, len(orig.UnackedNotificationIDs))
 	copy(c.UnackedNotificationIDs, orig.UnackedNotificationIDs)
+	c.ExperimentalFeatures = make([]string, len(orig.ExperimentalFeatures))
+	copy(c.ExperimentalFeatures, orig.ExperimentalFeatures)
 	return c
 }
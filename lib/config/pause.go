@@ -0,0 +1,42 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import "fmt"
+
+// Who paused a folder or device, as set in PausedBy, so that whoever
+// resumes it knows whose decision they're undoing.
+const (
+	PauseOwnerUser     = "user"     // someone using the GUI or the REST API; the default
+	PauseOwnerSchedule = "schedule" // a schedule, built in or an external job
+	PauseOwnerPolicy   = "policy"   // a rule, such as a transfer cap or one set by an administrator
+	PauseOwnerLowDisk  = "low-disk" // a check that the disk is running out of space
+)
+
+// IsPauseOwner returns true if the owner is one of the PauseOwner constants.
+func IsPauseOwner(owner string) bool {
+	switch owner {
+	case PauseOwnerUser, PauseOwnerSchedule, PauseOwnerPolicy, PauseOwnerLowDisk:
+		return true
+	default:
+		return false
+	}
+}
+
+// CheckResume returns an error if the owner may not resume what pausedBy
+// paused. People may resume whatever is paused, but anything else, such as
+// a schedule, may only resume what it paused itself, so that automations
+// don't undo the pauses of others unknowingly.
+func CheckResume(owner, pausedBy, pauseReason string) error {
+	if owner == PauseOwnerUser || pausedBy == "" || pausedBy == owner {
+		return nil
+	}
+	if pauseReason != "" {
+		return fmt.Errorf("paused by %s (%s), not %s", pausedBy, pauseReason, owner)
+	}
+	return fmt.Errorf("paused by %s, not %s", pausedBy, owner)
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// PowerProfile bundles the settings trading speed of scanning and syncing
+// against power use.
+type PowerProfile int
+
+const (
+	PowerProfileBalanced    PowerProfile = iota // default, the settings as configured
+	PowerProfilePerformance                     // hash on all cores, everywhere
+	PowerProfilePowerSaver                      // scan less often, hash on one core, limit transfers
+	PowerProfileAuto                            // power saver when on battery, balanced otherwise
+)
+
+func (p PowerProfile) String() string {
+	switch p {
+	case PowerProfileBalanced:
+		return "balanced"
+	case PowerProfilePerformance:
+		return "performance"
+	case PowerProfilePowerSaver:
+		return "powerSaver"
+	case PowerProfileAuto:
+		return "auto"
+	default:
+		return "unknown"
+	}
+}
+
+func (p PowerProfile) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *PowerProfile) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "performance":
+		*p = PowerProfilePerformance
+	case "powerSaver":
+		*p = PowerProfilePowerSaver
+	case "auto":
+		*p = PowerProfileAuto
+	default:
+		*p = PowerProfileBalanced
+	}
+	return nil
+}
+
+// Resolve returns the profile to use given the power state; the same
+// profile unless it's auto.
+func (p PowerProfile) Resolve(onBattery bool) PowerProfile {
+	switch {
+	case p != PowerProfileAuto:
+		return p
+	case onBattery:
+		return PowerProfilePowerSaver
+	default:
+		return PowerProfileBalanced
+	}
+}
+
+// PowerProfileSettings are what a power profile changes compared to the
+// settings as configured.
+type PowerProfileSettings struct {
+	RescanIntervalFactor int  // folder rescan intervals are multiplied by this
+	MaxHashers           int  // hashers per folder; 0 for no limit
+	AllCoresHashing      bool // by default hash on all cores, also on interactive operating systems
+	MaxSendKbps          int  // 0 for no limit
+	MaxRecvKbps          int  // 0 for no limit
+}
+
+// Settings returns the settings of the resolved profile.
+func (p PowerProfile) Settings() PowerProfileSettings {
+	switch p {
+	case PowerProfilePerformance:
+		return PowerProfileSettings{RescanIntervalFactor: 1, AllCoresHashing: true}
+	case PowerProfilePowerSaver:
+		return PowerProfileSettings{RescanIntervalFactor: 4, MaxHashers: 1, MaxSendKbps: 2048, MaxRecvKbps: 2048}
+	default:
+		return PowerProfileSettings{RescanIntervalFactor: 1}
+	}
+}
+
+// LimitKbps returns the stricter of two rate limits, where zero or less
+// means no limit.
+func LimitKbps(a, b int) int {
+	switch {
+	case a <= 0:
+		return b
+	case b <= 0, a < b:
+		return a
+	default:
+		return b
+	}
+}
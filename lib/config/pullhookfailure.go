@@ -0,0 +1,41 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// PullHookFailure decides what a pull hook failing, by exiting with an
+// error or running out of time, means for the item it was run for.
+type PullHookFailure int
+
+const (
+	PullHookFailureIgnore PullHookFailure = iota // default is to log it and carry on
+	PullHookFailureError                         // the item fails to sync; the pre pull hook failing keeps it from being pulled
+)
+
+func (p PullHookFailure) String() string {
+	switch p {
+	case PullHookFailureIgnore:
+		return "ignore"
+	case PullHookFailureError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+func (p PullHookFailure) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *PullHookFailure) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "error":
+		*p = PullHookFailureError
+	default:
+		*p = PullHookFailureIgnore
+	}
+	return nil
+}
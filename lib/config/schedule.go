@@ -0,0 +1,146 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Schedule is a set of minutes, given by a cron like expression with the
+// five fields minute, hour, day of month, month and day of week. Each field
+// is "*", a number, a range such as "9-17", any of which may be followed by
+// a step such as "/15", or a comma separated list of those. Months and days
+// of the week may also be given by their first three letters, and Sunday is
+// both 0 and 7. As with cron, when both the day of month and the day of week
+// are restricted, neither starting with "*", a day matching either is in
+// the schedule.
+//
+// For example, "* 9-16 * * mon-fri" is working hours, every minute from
+// 09:00 to 16:59 on weekdays.
+type Schedule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	anyDay   bool // the day of month starts with "*"
+	anyWeek  bool // the day of week starts with "*"
+}
+
+var (
+	monthNames   = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// ParseSchedule parses a cron like schedule expression.
+func ParseSchedule(expr string) (Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("schedule %q: expected five fields, got %d", expr, len(fields))
+	}
+
+	var s Schedule
+	var err error
+	if s.minutes, err = parseScheduleField(fields[0], 0, 59, nil, 0); err != nil {
+		return Schedule{}, fmt.Errorf("schedule %q: minute: %v", expr, err)
+	}
+	if s.hours, err = parseScheduleField(fields[1], 0, 23, nil, 0); err != nil {
+		return Schedule{}, fmt.Errorf("schedule %q: hour: %v", expr, err)
+	}
+	if s.days, err = parseScheduleField(fields[2], 1, 31, nil, 0); err != nil {
+		return Schedule{}, fmt.Errorf("schedule %q: day of month: %v", expr, err)
+	}
+	if s.months, err = parseScheduleField(fields[3], 1, 12, monthNames, 1); err != nil {
+		return Schedule{}, fmt.Errorf("schedule %q: month: %v", expr, err)
+	}
+	if s.weekdays, err = parseScheduleField(fields[4], 0, 7, weekdayNames, 0); err != nil {
+		return Schedule{}, fmt.Errorf("schedule %q: day of week: %v", expr, err)
+	}
+	if s.weekdays&(1<<7) != 0 {
+		s.weekdays |= 1 << 0
+	}
+	s.anyDay = strings.HasPrefix(fields[2], "*")
+	s.anyWeek = strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// Contains returns whether the minute t is in is part of the schedule.
+func (s Schedule) Contains(t time.Time) bool {
+	if s.minutes&(1<<uint(t.Minute())) == 0 ||
+		s.hours&(1<<uint(t.Hour())) == 0 ||
+		s.months&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	day := s.days&(1<<uint(t.Day())) != 0
+	weekday := s.weekdays&(1<<uint(t.Weekday())) != 0
+	if s.anyDay || s.anyWeek {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// parseScheduleField returns the set of values from min to max given by the
+// field, as bits. The names, if any, stand for the values from nameBase.
+func parseScheduleField(field string, min, max int, names []string, nameBase int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			var err error
+			rng = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		var lo, hi int
+		if rng == "*" {
+			lo, hi = min, max
+		} else {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = parseScheduleValue(bounds[0], min, max, names, nameBase); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = parseScheduleValue(bounds[1], min, max, names, nameBase); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// As with cron, "5/15" means from 5 to the end.
+				hi = max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseScheduleValue(s string, min, max int, names []string, nameBase int) (int, error) {
+	for i, name := range names {
+		if strings.ToLower(s) == name {
+			return i + nameBase, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("invalid value %q, expected %d-%d", s, min, max)
+	}
+	return v, nil
+}
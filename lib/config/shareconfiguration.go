@@ -0,0 +1,25 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import "time"
+
+// A ShareConfiguration is a link to a file or directory in a folder, that
+// the share gateway serves read only to anyone who has its token, without
+// them being a device the folder is shared with.
+type ShareConfiguration struct {
+	ID      string    `xml:"id,attr" json:"id"`     // to refer to the share by, such as to revoke it
+	Hash    string    `xml:"hash,attr" json:"hash"` // of the token, as for API keys; the token itself isn't kept
+	Folder  string    `xml:"folder,attr" json:"folder"`
+	Path    string    `xml:"path,attr" json:"path"`       // within the folder; empty for all of it
+	Expires time.Time `xml:"expires,attr" json:"expires"` // zero for never
+}
+
+// Expired returns whether the share has expired at the given time.
+func (s ShareConfiguration) Expired(now time.Time) bool {
+	return !s.Expires.IsZero() && now.After(s.Expires)
+}
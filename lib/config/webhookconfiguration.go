@@ -0,0 +1,25 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// A WebhookConfiguration is a URL that events are POSTed to as they
+// happen, each as JSON in the same form as served at /rest/events.
+type WebhookConfiguration struct {
+	URL    string   `xml:"url,attr" json:"url"`
+	Events []string `xml:"event" json:"events"`                 // event types, such as "ItemFinished"; empty for those served at /rest/events by default
+	Secret string   `xml:"secret,omitempty" json:"secret"`      // to sign the payloads with, using HMAC-SHA256; empty to not sign them
+	Paused bool     `xml:"paused,attr,omitempty" json:"paused"` // don't send anything for the time being
+}
+
+func (c WebhookConfiguration) Copy() WebhookConfiguration {
+	cp := c
+	if c.Events != nil {
+		cp.Events = make([]string, len(c.Events))
+		copy(cp.Events, c.Events)
+	}
+	return cp
+}
@@ -7,6 +7,7 @@
 package config
 
 import (
+	"crypto/subtle"
 	"os"
 	"sync/atomic"
 
@@ -56,11 +57,13 @@ type Wrapper struct {
 	mut       sync.Mutex
 
 	requiresRestart uint32 // an atomic bool
+	onBattery       bool   // for resolving the power profile
 }
 
 // Wrap wraps an existing Configuration structure and ties it to a file on
 // disk.
 func Wrap(path string, cfg Configuration) *Wrapper {
+	cfg.Options.ActivePowerProfile = cfg.Options.PowerProfile.Resolve(false)
 	w := &Wrapper{
 		cfg:  cfg,
 		path: path,
@@ -141,6 +144,7 @@ func (w *Wrapper) replaceLocked(to Configuration) error {
 	if err := to.clean(); err != nil {
 		return err
 	}
+	to.Options.ActivePowerProfile = to.Options.PowerProfile.Resolve(w.onBattery)
 
 	for _, sub := range w.subs {
 		l.Debugln(sub, "verifying configuration")
@@ -273,6 +277,13 @@ func (w *Wrapper) Options() OptionsConfiguration {
 	return w.cfg.Options
 }
 
+// FeatureEnabled returns true if the experimental feature is enabled.
+func (w *Wrapper) FeatureEnabled(name string) bool {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.cfg.Options.FeatureEnabled(name)
+}
+
 // SetOptions replaces the current options configuration object.
 func (w *Wrapper) SetOptions(opts OptionsConfiguration) error {
 	w.mut.Lock()
@@ -298,6 +309,31 @@ func (w *Wrapper) SetGUI(gui GUIConfiguration) error {
 	return w.replaceLocked(newCfg)
 }
 
+// Alerts returns the current alert configuration object.
+func (w *Wrapper) Alerts() AlertConfiguration {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.cfg.Alerts
+}
+
+// Webhooks returns the webhooks that events are sent to.
+func (w *Wrapper) Webhooks() []WebhookConfiguration {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	res := make([]WebhookConfiguration, len(w.cfg.Webhooks))
+	for i := range w.cfg.Webhooks {
+		res[i] = w.cfg.Webhooks[i].Copy()
+	}
+	return res
+}
+
+// MQTT returns the MQTT broker configuration.
+func (w *Wrapper) MQTT() MQTTConfiguration {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.cfg.MQTT
+}
+
 // IgnoredDevice returns whether or not connection attempts from the given
 // device should be silently ignored.
 func (w *Wrapper) IgnoredDevice(id protocol.DeviceID) bool {
@@ -335,6 +371,23 @@ func (w *Wrapper) Folder(id string) (FolderConfiguration, bool) {
 	return FolderConfiguration{}, false
 }
 
+// Share returns the share with the given token and an "ok" bool. The
+// hashes of the tokens are compared in constant time, as they're secrets.
+func (w *Wrapper) Share(token string) (ShareConfiguration, bool) {
+	if token == "" {
+		return ShareConfiguration{}, false
+	}
+	hash := []byte(HashAPIKey(token))
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	for _, share := range w.cfg.Shares {
+		if subtle.ConstantTimeCompare([]byte(share.Hash), hash) == 1 {
+			return share, true
+		}
+	}
+	return ShareConfiguration{}, false
+}
+
 // Save writes the configuration to disk, and generates a ConfigSaved event.
 func (w *Wrapper) Save() error {
 	fd, err := osutil.CreateAtomic(w.path)
@@ -388,6 +441,21 @@ func (w *Wrapper) ListenAddresses() []string {
 	return util.UniqueStrings(addresses)
 }
 
+// SetOnBattery updates the power state that the power profile is resolved
+// for. Should the resolved profile change, that's a configuration change.
+func (w *Wrapper) SetOnBattery(onBattery bool) error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	if onBattery == w.onBattery {
+		return nil
+	}
+	w.onBattery = onBattery
+	if w.cfg.Options.PowerProfile.Resolve(onBattery) == w.cfg.Options.ActivePowerProfile {
+		return nil
+	}
+	return w.replaceLocked(w.cfg.Copy())
+}
+
 func (w *Wrapper) RequiresRestart() bool {
 	return atomic.LoadUint32(&w.requiresRestart) != 0
 }
@@ -0,0 +1,162 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+const (
+	// An unknown device has a device ID that looks a lot like a known one.
+	AlertNearMissID = "nearMissDeviceID"
+	// An unknown device uses the name of a known one. Either the known
+	// device was reset or had its certificate reissued, or someone is
+	// pretending to be it.
+	AlertReusedName = "reusedDeviceName"
+	// A known device presented a certificate with an unexpected name.
+	AlertCertificateName = "certificateName"
+
+	maxSecurityAlerts = 100
+	// The number of characters at the start and end of the device ID
+	// string that a human is likely to compare.
+	nearMissChars = 7
+)
+
+// A SecurityAlert describes a connection attempt that could be an attempt
+// to impersonate one of our devices. It is kept until acknowledged, after
+// which alerts of its kind aren't raised for the device resembling the same
+// known device again.
+type SecurityAlert struct {
+	ID        int               `json:"id"`
+	Time      time.Time         `json:"time"`
+	Kind      string            `json:"kind"`
+	Device    protocol.DeviceID `json:"device"`    // the device that connected
+	Resembles protocol.DeviceID `json:"resembles"` // the known device it was mistaken for
+	Address   string            `json:"address"`
+	Message   string            `json:"message"`
+}
+
+type securityAlerts struct {
+	alerts       []SecurityAlert
+	nextID       int
+	acknowledged AlertStore // kind, device and resembled device -> time acknowledged
+	mut          sync.Mutex
+}
+
+func newSecurityAlerts(acknowledged AlertStore) *securityAlerts {
+	return &securityAlerts{
+		nextID:       1,
+		acknowledged: acknowledged,
+		mut:          sync.NewMutex(),
+	}
+}
+
+// raise records the alert, unless there already is one of the same kind
+// for the same device, or such an alert has been acknowledged, and emits a
+// SecurityAlert event.
+func (a *securityAlerts) raise(alert SecurityAlert) {
+	a.mut.Lock()
+	if _, ok := a.acknowledged.Time(acknowledgedKey(alert)); ok {
+		a.mut.Unlock()
+		return
+	}
+	for _, existing := range a.alerts {
+		if existing.Kind == alert.Kind && existing.Device == alert.Device && existing.Resembles == alert.Resembles {
+			a.mut.Unlock()
+			return
+		}
+	}
+	alert.ID = a.nextID
+	a.nextID++
+	alert.Time = time.Now()
+	a.alerts = append(a.alerts, alert)
+	if len(a.alerts) > maxSecurityAlerts {
+		a.alerts = a.alerts[len(a.alerts)-maxSecurityAlerts:]
+	}
+	a.mut.Unlock()
+
+	l.Warnln("Security alert:", alert.Message)
+	events.Default.Log(events.SecurityAlert, alert)
+}
+
+func (a *securityAlerts) list() []SecurityAlert {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	res := make([]SecurityAlert, len(a.alerts))
+	copy(res, a.alerts)
+	return res
+}
+
+func (a *securityAlerts) acknowledge(id int) bool {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	for i, alert := range a.alerts {
+		if alert.ID == id {
+			a.alerts = append(a.alerts[:i], a.alerts[i+1:]...)
+			a.acknowledged.PutTime(acknowledgedKey(alert), time.Now())
+			return true
+		}
+	}
+	return false
+}
+
+func acknowledgedKey(alert SecurityAlert) string {
+	return alert.Kind + "/" + alert.Device.String() + "/" + alert.Resembles.String()
+}
+
+// checkImpersonation raises alerts if an unknown device looks like it
+// tries to pass itself off as one of the known devices, including
+// ourselves.
+func (a *securityAlerts) checkImpersonation(devices map[protocol.DeviceID]config.DeviceConfiguration, myID, remoteID protocol.DeviceID, addr net.Addr, hello protocol.HelloResult) {
+	if _, ok := devices[remoteID]; ok {
+		return
+	}
+
+	address := ""
+	if addr != nil {
+		address = addr.String()
+	}
+
+	for id, cfg := range devices {
+		if nearMissID(id, remoteID) {
+			a.raise(SecurityAlert{
+				Kind:      AlertNearMissID,
+				Device:    remoteID,
+				Resembles: id,
+				Address:   address,
+				Message:   fmt.Sprintf("Unknown device %v at %s has a device ID confusingly similar to that of %v. Verify the full device ID before adding it.", remoteID, address, id),
+			})
+		}
+		if id != myID && hello.DeviceName != "" && strings.EqualFold(hello.DeviceName, cfg.Name) {
+			a.raise(SecurityAlert{
+				Kind:      AlertReusedName,
+				Device:    remoteID,
+				Resembles: id,
+				Address:   address,
+				Message:   fmt.Sprintf("Unknown device %v at %s uses the name %q of known device %v. If that device was reset or got a new certificate, update its device ID; otherwise it may be impersonating it.", remoteID, address, hello.DeviceName, id),
+			})
+		}
+	}
+}
+
+// nearMissID returns true if the two different device IDs agree on the
+// parts that people usually compare when checking a device ID.
+func nearMissID(a, b protocol.DeviceID) bool {
+	if a == b {
+		return false
+	}
+	as, bs := a.String(), b.String()
+	return as[:nearMissChars] == bs[:nearMissChars] || as[len(as)-nearMissChars:] == bs[len(bs)-nearMissChars:]
+}
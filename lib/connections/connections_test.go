@@ -6,8 +6,14 @@
 
 package connections
 
-import "testing"
-import "net/url"
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
 
 func TestFixupPort(t *testing.T) {
 	cases := [][2]string{
@@ -24,3 +30,129 @@ func TestFixupPort(t *testing.T) {
 		}
 	}
 }
+
+func TestNearMissID(t *testing.T) {
+	known, _ := protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+	other, _ := protocol.DeviceIDFromString("GYRZZQB-IRNPV4Z-T7TC52W-EQYJ3TT-FDQW6MW-DFLMU42-SSSU6EM-FBK2VAY")
+	nearMiss := known
+	nearMiss[31] ^= 0x55 // only affects the last group(s)
+
+	if nearMissID(known, known) {
+		t.Error("an ID is not a near miss of itself")
+	}
+	if nearMissID(known, other) {
+		t.Error("unrelated IDs are not near misses")
+	}
+	if !nearMissID(known, nearMiss) {
+		t.Error("IDs with the same first group should be near misses")
+	}
+}
+
+func TestSecurityAlerts(t *testing.T) {
+	myID, _ := protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+	known, _ := protocol.DeviceIDFromString("GYRZZQB-IRNPV4Z-T7TC52W-EQYJ3TT-FDQW6MW-DFLMU42-SSSU6EM-FBK2VAY")
+	unknown, _ := protocol.DeviceIDFromString("LGFPDIT-7SKNNJL-VJZA4FC-7QNCRKA-CE753K7-2BW5QDK-2FOZ7FR-FEP57QJ")
+	devices := map[protocol.DeviceID]config.DeviceConfiguration{
+		myID:  {DeviceID: myID, Name: "laptop"},
+		known: {DeviceID: known, Name: "server"},
+	}
+
+	store := make(mapAlertStore)
+	a := newSecurityAlerts(store)
+
+	a.checkImpersonation(devices, myID, known, nil, protocol.HelloResult{DeviceName: "server"})
+	a.checkImpersonation(devices, myID, unknown, nil, protocol.HelloResult{DeviceName: "other"})
+	if alerts := a.list(); len(alerts) != 0 {
+		t.Fatal("unexpected alerts", alerts)
+	}
+
+	a.checkImpersonation(devices, myID, unknown, nil, protocol.HelloResult{DeviceName: "Server"})
+	a.checkImpersonation(devices, myID, unknown, nil, protocol.HelloResult{DeviceName: "Server"})
+	alerts := a.list()
+	if len(alerts) != 1 || alerts[0].Kind != AlertReusedName || alerts[0].Resembles != known {
+		t.Fatal("expected a single reused name alert, not", alerts)
+	}
+
+	if a.acknowledge(alerts[0].ID + 1) {
+		t.Error("acknowledged nonexistent alert")
+	}
+	if !a.acknowledge(alerts[0].ID) {
+		t.Error("failed to acknowledge alert")
+	}
+	if alerts := a.list(); len(alerts) != 0 {
+		t.Error("acknowledged alert still present", alerts)
+	}
+
+	// Once acknowledged, the alert stays so, also after a restart.
+	a = newSecurityAlerts(store)
+	a.checkImpersonation(devices, myID, unknown, nil, protocol.HelloResult{DeviceName: "Server"})
+	if alerts := a.list(); len(alerts) != 0 {
+		t.Error("acknowledged alert raised again", alerts)
+	}
+
+	// But the same device passing itself off as another known device is
+	// another matter.
+	other, _ := protocol.DeviceIDFromString("MFZWI3D-BONSGYC-YLTMRWG-C43ENR5-QXGZDMM-FZWI3DP-BONSGYY-LTMRWAD")
+	devices[other] = config.DeviceConfiguration{DeviceID: other, Name: "nas"}
+	a.checkImpersonation(devices, myID, unknown, nil, protocol.HelloResult{DeviceName: "NAS"})
+	if alerts := a.list(); len(alerts) != 1 || alerts[0].Resembles != other {
+		t.Error("expected a reused name alert for the other device, not", alerts)
+	}
+}
+
+type mapAlertStore map[string]time.Time
+
+func (s mapAlertStore) PutTime(key string, val time.Time) {
+	s[key] = val
+}
+
+func (s mapAlertStore) Time(key string) (time.Time, bool) {
+	t, ok := s[key]
+	return t, ok
+}
+
+func TestLimiterLevels(t *testing.T) {
+	device, _ := protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+	cfg := config.Wrap("/dev/null", config.Configuration{
+		Devices: []config.DeviceConfiguration{{DeviceID: device, MaxSendKbps: 10}},
+		Options: config.OptionsConfiguration{
+			MaxSendKbps:      1000,
+			MaxSendKbpsLAN:   200,
+			MaxSendKbpsRelay: 100,
+		},
+	})
+	lim := newLimiter(cfg)
+
+	// The device's limit applies, then the class's, then the global one
+	// except on LAN
+
+	levels := lim.levels(device, addressClassRelay)
+	if levels[0].write.Limit() != 10*1024 || levels[1].write.Limit() != 100*1024 || levels[2].write.Limit() != 1000*1024 {
+		t.Errorf("Unexpected relay limits %v %v %v", levels[0].write.Limit(), levels[1].write.Limit(), levels[2].write.Limit())
+	}
+	levels = lim.levels(protocol.LocalDeviceID, addressClassLAN)
+	if levels[0].write != nil || levels[1].write.Limit() != 200*1024 || levels[2].write != nil {
+		t.Error("Unexpected LAN limits", levels)
+	}
+	if levels[1].write.Burst() != limiterBurstSize {
+		t.Error("Unexpected burst size", levels[1].write.Burst())
+	}
+
+	// Changing a rate keeps the buckets, changing the burst size replaces
+	// them
+
+	from := cfg.RawCopy()
+	to := from.Copy()
+	to.Options.MaxSendKbpsLAN = 300
+	to.Options.LimitBandwidthInLan = true
+	lim.CommitConfiguration(from, to)
+	if lan := lim.levels(device, addressClassLAN); lan[1].write != levels[1].write || lan[1].write.Limit() != 300*1024 || lan[2].write == nil {
+		t.Error("Unexpected LAN limits after changing the rate", lan)
+	}
+	from, to = to, to.Copy()
+	to.Options.LimiterBurstKiB = 64
+	lim.CommitConfiguration(from, to)
+	if lan := lim.levels(device, addressClassLAN); lan[1].write == levels[1].write || lan[1].write.Burst() != 64<<10 {
+		t.Error("Unexpected LAN limits after changing the burst size", lan)
+	}
+}
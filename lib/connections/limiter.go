@@ -9,27 +9,62 @@ package connections
 import (
 	"fmt"
 	"io"
-	"sync/atomic"
 
 	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
 	"golang.org/x/net/context"
 	"golang.org/x/time/rate"
 )
 
-// limiter manages a read and write rate limit, reacting to config changes
-// as appropriate.
+// An addressClass is the kind of address a connection is over, each of
+// which has its own rate limits.
+type addressClass int
+
+const (
+	addressClassWAN addressClass = iota
+	addressClassLAN
+	addressClassRelay
+)
+
+func (c addressClass) String() string {
+	switch c {
+	case addressClassLAN:
+		return "LAN"
+	case addressClassRelay:
+		return "relay"
+	default:
+		return "WAN"
+	}
+}
+
+// limiter manages the read and write rate limits, reacting to config changes
+// as appropriate. The limits form a hierarchy: data to or from a device is
+// limited by the device's own limits, by those for the class of address it's
+// connected over, and by the global limits, which apply to LAN connections
+// only when so configured. Each limit is a token bucket that allows bursts
+// of the configured size.
 type limiter struct {
-	write     *rate.Limiter
-	read      *rate.Limiter
-	limitsLAN atomicBool
+	global    buckets
+	classes   map[addressClass]buckets
+	devices   map[protocol.DeviceID]buckets
+	limitsLAN bool
+	mut       sync.RWMutex
+}
+
+// buckets are the token buckets for one level of limits.
+type buckets struct {
+	read  *rate.Limiter
+	write *rate.Limiter
 }
 
 const limiterBurstSize = 4 * 128 << 10
 
 func newLimiter(cfg *config.Wrapper) *limiter {
 	l := &limiter{
-		write: rate.NewLimiter(rate.Inf, limiterBurstSize),
-		read:  rate.NewLimiter(rate.Inf, limiterBurstSize),
+		classes: make(map[addressClass]buckets),
+		devices: make(map[protocol.DeviceID]buckets),
+		mut:     sync.NewRWMutex(),
 	}
 	cfg.Subscribe(l)
 	prev := config.Configuration{Options: config.OptionsConfiguration{MaxRecvKbps: -1, MaxSendKbps: -1}}
@@ -37,12 +72,25 @@ func newLimiter(cfg *config.Wrapper) *limiter {
 	return l
 }
 
-func (lim *limiter) newReadLimiter(r io.Reader, isLAN bool) io.Reader {
-	return &limitedReader{reader: r, limiter: lim, isLAN: isLAN}
+func (lim *limiter) newReadLimiter(r io.Reader, device protocol.DeviceID, class addressClass) io.Reader {
+	return &limitedReader{reader: r, limiter: lim, device: device, class: class}
+}
+
+func (lim *limiter) newWriteLimiter(w io.Writer, device protocol.DeviceID, class addressClass) io.Writer {
+	return &limitedWriter{writer: w, limiter: lim, device: device, class: class}
 }
 
-func (lim *limiter) newWriteLimiter(w io.Writer, isLAN bool) io.Writer {
-	return &limitedWriter{writer: w, limiter: lim, isLAN: isLAN}
+// levels returns the buckets that apply to the device over the class of
+// address, most specific first.
+func (lim *limiter) levels(device protocol.DeviceID, class addressClass) [3]buckets {
+	lim.mut.RLock()
+	defer lim.mut.RUnlock()
+
+	levels := [3]buckets{lim.devices[device], lim.classes[class]}
+	if class != addressClassLAN || lim.limitsLAN {
+		levels[2] = lim.global
+	}
+	return levels
 }
 
 func (lim *limiter) VerifyConfiguration(from, to config.Configuration) error {
@@ -50,44 +98,56 @@ func (lim *limiter) VerifyConfiguration(from, to config.Configuration) error {
 }
 
 func (lim *limiter) CommitConfiguration(from, to config.Configuration) bool {
-	if from.Options.MaxRecvKbps == to.Options.MaxRecvKbps &&
-		from.Options.MaxSendKbps == to.Options.MaxSendKbps &&
-		from.Options.LimitBandwidthInLan == to.Options.LimitBandwidthInLan {
-		return true
+	burst := to.Options.LimiterBurstKiB << 10
+	if burst <= 0 {
+		burst = limiterBurstSize
 	}
 
-	// The rate variables are in KiB/s in the config (despite the camel casing
-	// of the name). We multiply by 1024 to get bytes/s.
-
-	if to.Options.MaxRecvKbps <= 0 {
-		lim.read.SetLimit(rate.Inf)
-	} else {
-		lim.read.SetLimit(1024 * rate.Limit(to.Options.MaxRecvKbps))
-	}
+	// The power profile may limit the global rates further.
+	profile := to.Options.ActivePowerProfile.Settings()
+	maxRecvKbps := config.LimitKbps(to.Options.MaxRecvKbps, profile.MaxRecvKbps)
+	maxSendKbps := config.LimitKbps(to.Options.MaxSendKbps, profile.MaxSendKbps)
 
-	if to.Options.MaxSendKbps <= 0 {
-		lim.write.SetLimit(rate.Inf)
-	} else {
-		lim.write.SetLimit(1024 * rate.Limit(to.Options.MaxSendKbps))
-	}
+	lim.mut.Lock()
+	defer lim.mut.Unlock()
 
-	lim.limitsLAN.set(to.Options.LimitBandwidthInLan)
+	lim.global = lim.global.update(maxRecvKbps, maxSendKbps, burst)
+	lim.classes[addressClassLAN] = lim.classes[addressClassLAN].update(to.Options.MaxRecvKbpsLAN, to.Options.MaxSendKbpsLAN, burst)
+	lim.classes[addressClassWAN] = lim.classes[addressClassWAN].update(to.Options.MaxRecvKbpsWAN, to.Options.MaxSendKbpsWAN, burst)
+	lim.classes[addressClassRelay] = lim.classes[addressClassRelay].update(to.Options.MaxRecvKbpsRelay, to.Options.MaxSendKbpsRelay, burst)
+	lim.limitsLAN = to.Options.LimitBandwidthInLan
 
-	sendLimitStr := "is unlimited"
-	recvLimitStr := "is unlimited"
-	if to.Options.MaxSendKbps > 0 {
-		sendLimitStr = fmt.Sprintf("limit is %d KiB/s", to.Options.MaxSendKbps)
+	devices := make(map[protocol.DeviceID]buckets, len(to.Devices))
+	for _, dev := range to.Devices {
+		devices[dev.DeviceID] = lim.devices[dev.DeviceID].update(dev.MaxRecvKbps, dev.MaxSendKbps, burst)
 	}
-	if to.Options.MaxRecvKbps > 0 {
-		recvLimitStr = fmt.Sprintf("limit is %d KiB/s", to.Options.MaxRecvKbps)
+	lim.devices = devices
+
+	if from.Options.MaxRecvKbps == to.Options.MaxRecvKbps &&
+		from.Options.MaxSendKbps == to.Options.MaxSendKbps &&
+		from.Options.LimitBandwidthInLan == to.Options.LimitBandwidthInLan &&
+		from.Options.ActivePowerProfile == to.Options.ActivePowerProfile &&
+		from.Options.MaxRecvKbpsLAN == to.Options.MaxRecvKbpsLAN &&
+		from.Options.MaxSendKbpsLAN == to.Options.MaxSendKbpsLAN &&
+		from.Options.MaxRecvKbpsWAN == to.Options.MaxRecvKbpsWAN &&
+		from.Options.MaxSendKbpsWAN == to.Options.MaxSendKbpsWAN &&
+		from.Options.MaxRecvKbpsRelay == to.Options.MaxRecvKbpsRelay &&
+		from.Options.MaxSendKbpsRelay == to.Options.MaxSendKbpsRelay {
+		return true
 	}
-	l.Infof("Send rate %s, receive rate %s", sendLimitStr, recvLimitStr)
 
+	l.Infof("Send rate %s, receive rate %s", limitString(maxSendKbps), limitString(maxRecvKbps))
 	if to.Options.LimitBandwidthInLan {
 		l.Infoln("Rate limits apply to LAN connections")
 	} else {
 		l.Infoln("Rate limits do not apply to LAN connections")
 	}
+	for _, class := range []addressClass{addressClassLAN, addressClassWAN, addressClassRelay} {
+		send, recv := lim.classes[class].write.Limit(), lim.classes[class].read.Limit()
+		if send != rate.Inf || recv != rate.Inf {
+			l.Infof("%s send rate %s, receive rate %s", class, limitString(int(send/1024)), limitString(int(recv/1024)))
+		}
+	}
 
 	return true
 }
@@ -97,17 +157,51 @@ func (lim *limiter) String() string {
 	return "connections.limiter"
 }
 
+// update returns the buckets with the given rates in KiB/s, zero or less
+// meaning unlimited. The existing buckets are kept, along with the tokens
+// they hold, unless the burst size changes.
+func (b buckets) update(readKbps, writeKbps, burst int) buckets {
+	return buckets{
+		read:  updateBucket(b.read, readKbps, burst),
+		write: updateBucket(b.write, writeKbps, burst),
+	}
+}
+
+func updateBucket(b *rate.Limiter, kbps, burst int) *rate.Limiter {
+	// The rate variables are in KiB/s in the config (despite the camel
+	// casing of the name). We multiply by 1024 to get bytes/s.
+	limit := rate.Inf
+	if kbps > 0 {
+		limit = 1024 * rate.Limit(kbps)
+	}
+	if b == nil || b.Burst() != burst {
+		return rate.NewLimiter(limit, burst)
+	}
+	b.SetLimit(limit)
+	return b
+}
+
+func limitString(kbps int) string {
+	if kbps <= 0 {
+		return "is unlimited"
+	}
+	return fmt.Sprintf("limit is %d KiB/s", kbps)
+}
+
 // limitedReader is a rate limited io.Reader
 type limitedReader struct {
 	reader  io.Reader
 	limiter *limiter
-	isLAN   bool
+	device  protocol.DeviceID
+	class   addressClass
 }
 
 func (r *limitedReader) Read(buf []byte) (int, error) {
 	n, err := r.reader.Read(buf)
-	if !r.isLAN || r.limiter.limitsLAN.get() {
-		take(r.limiter.read, n)
+	for _, level := range r.limiter.levels(r.device, r.class) {
+		if level.read != nil {
+			take(level.read, n)
+		}
 	}
 	return n, err
 }
@@ -116,12 +210,15 @@ func (r *limitedReader) Read(buf []byte) (int, error) {
 type limitedWriter struct {
 	writer  io.Writer
 	limiter *limiter
-	isLAN   bool
+	device  protocol.DeviceID
+	class   addressClass
 }
 
 func (w *limitedWriter) Write(buf []byte) (int, error) {
-	if !w.isLAN || w.limiter.limitsLAN.get() {
-		take(w.limiter.write, len(buf))
+	for _, level := range w.limiter.levels(w.device, w.class) {
+		if level.write != nil {
+			take(level.write, len(buf))
+		}
 	}
 	return w.writer.Write(buf)
 }
@@ -130,7 +227,12 @@ func (w *limitedWriter) Write(buf []byte) (int, error) {
 // to WaitN can be larger than the limiter burst size so we split it up into
 // several calls when necessary.
 func take(l *rate.Limiter, tokens int) {
-	if tokens < limiterBurstSize {
+	if l.Limit() == rate.Inf {
+		return
+	}
+
+	burst := l.Burst()
+	if tokens < burst {
 		// This is the by far more common case so we get it out of the way
 		// early.
 		l.WaitN(context.TODO(), tokens)
@@ -138,27 +240,13 @@ func take(l *rate.Limiter, tokens int) {
 	}
 
 	for tokens > 0 {
-		// Consume limiterBurstSize tokens at a time until we're done.
-		if tokens > limiterBurstSize {
-			l.WaitN(context.TODO(), limiterBurstSize)
-			tokens -= limiterBurstSize
+		// Consume burst tokens at a time until we're done.
+		if tokens > burst {
+			l.WaitN(context.TODO(), burst)
+			tokens -= burst
 		} else {
 			l.WaitN(context.TODO(), tokens)
 			tokens = 0
 		}
 	}
 }
-
-type atomicBool int32
-
-func (b *atomicBool) set(v bool) {
-	if v {
-		atomic.StoreInt32((*int32)(b), 1)
-	} else {
-		atomic.StoreInt32((*int32)(b), 0)
-	}
-}
-
-func (b *atomicBool) get() bool {
-	return atomic.LoadInt32((*int32)(b)) != 0
-}
@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/syncthing/syncthing/lib/config"
@@ -90,9 +91,11 @@ type Service struct {
 
 	curConMut         sync.Mutex
 	currentConnection map[protocol.DeviceID]completeConn
+
+	alerts *securityAlerts
 }
 
-func NewService(cfg *config.Wrapper, myID protocol.DeviceID, mdl Model, tlsCfg *tls.Config, discoverer discover.Finder,
+func NewService(cfg *config.Wrapper, myID protocol.DeviceID, mdl Model, alertStore AlertStore, tlsCfg *tls.Config, discoverer discover.Finder,
 	bepProtocolName string, tlsDefaultCommonName string, lans []*net.IPNet) *Service {
 
 	service := &Service{
@@ -131,6 +134,8 @@ func NewService(cfg *config.Wrapper, myID protocol.DeviceID, mdl Model, tlsCfg *
 
 		curConMut:         sync.NewMutex(),
 		currentConnection: make(map[protocol.DeviceID]completeConn),
+
+		alerts: newSecurityAlerts(alertStore),
 	}
 	cfg.Subscribe(service)
 
@@ -215,6 +220,8 @@ next:
 		}
 		c.SetDeadline(time.Time{})
 
+		s.alerts.checkImpersonation(s.cfg.Devices(), s.myID, remoteID, c.RemoteAddr(), hello)
+
 		// The Model will return an error for devices that we don't want to
 		// have a connection with for whatever reason, for example unknown devices.
 		if err := s.model.OnHello(remoteID, c.RemoteAddr(), hello); err != nil {
@@ -251,6 +258,12 @@ next:
 			panic("bug: unknown device should already have been rejected")
 		}
 
+		if !deviceCfg.InSyncWindow(time.Now()) {
+			l.Infof("Connection from %s at %s (%s) rejected: outside of the device's sync windows", remoteID, c.RemoteAddr(), c.Type())
+			c.Close()
+			continue
+		}
+
 		// Verify the name on the certificate. By default we set it to
 		// "syncthing" when generating, but the user may have replaced
 		// the certificate and used another name.
@@ -262,20 +275,31 @@ next:
 			// Incorrect certificate name is something the user most
 			// likely wants to know about, since it's an advanced
 			// config. Warn instead of Info.
-			l.Warnf("Bad certificate from %s (%v): %v", remoteID, c.RemoteAddr(), err)
+			s.alerts.raise(SecurityAlert{
+				Kind:      AlertCertificateName,
+				Device:    remoteID,
+				Resembles: remoteID,
+				Address:   c.RemoteAddr().String(),
+				Message:   fmt.Sprintf("Bad certificate from %s (%v): %v", remoteID, c.RemoteAddr(), err),
+			})
 			c.Close()
 			continue next
 		}
 
 		// Wrap the connection in rate limiters. The limiter itself will
-		// keep up with config changes to the rate and whether or not LAN
+		// keep up with config changes to the rates and whether or not LAN
 		// connections are limited.
-		isLAN := s.isLAN(c.RemoteAddr())
-		wr := s.limiter.newWriteLimiter(c, isLAN)
-		rd := s.limiter.newReadLimiter(c, isLAN)
+		class := s.addressClass(c)
+		wr := s.limiter.newWriteLimiter(c, remoteID, class)
+		rd := s.limiter.newReadLimiter(c, remoteID, class)
 
 		name := fmt.Sprintf("%s-%s (%s)", c.LocalAddr(), c.RemoteAddr(), c.Type())
-		protoConn := protocol.NewConnection(remoteID, rd, wr, s.model, name, deviceCfg.Compression)
+		// Compress with zstd if both sides support it, LZ4 otherwise.
+		zstdLevel := 0
+		if hello.Zstd && protocol.ZstdSupported {
+			zstdLevel = deviceCfg.CompressionZstdLevel()
+		}
+		protoConn := protocol.NewConnection(remoteID, rd, wr, s.model, name, deviceCfg.Compression, zstdLevel)
 		modelConn := completeConn{c, protoConn}
 
 		l.Infof("Established secure connection to %s at %s (%s)", remoteID, name, tlsCipherSuiteNames[c.ConnectionState().CipherSuite])
@@ -323,7 +347,7 @@ func (s *Service) connect() {
 				continue
 			}
 
-			if deviceCfg.Paused {
+			if deviceCfg.Paused || s.model.TransferCapped(deviceID) {
 				continue
 			}
 
@@ -333,6 +357,22 @@ func (s *Service) connect() {
 			s.curConMut.Unlock()
 			priorityKnown := ok && connected
 
+			if !deviceCfg.InSyncWindow(now) {
+				// Not dialing, and hanging up if connected. Since this loop
+				// runs at least once a minute, so does the check.
+				if priorityKnown {
+					l.Infof("Closing connection to %s outside of its sync windows", deviceID)
+					ct.Close()
+				}
+				continue
+			}
+
+			if deviceCfg.InboundOnly {
+				// It connects to us, however good or bad a connection it
+				// makes.
+				continue
+			}
+
 			if priorityKnown && ct.internalConn.priority == bestDialerPrio {
 				// Things are already as good as they can get.
 				continue
@@ -414,6 +454,19 @@ func (s *Service) connect() {
 	}
 }
 
+// addressClass returns the class of address the connection is over, for
+// rate limiting.
+func (s *Service) addressClass(c internalConn) addressClass {
+	switch {
+	case strings.HasPrefix(c.Type(), "relay"):
+		return addressClassRelay
+	case s.isLAN(c.RemoteAddr()):
+		return addressClassLAN
+	default:
+		return addressClassWAN
+	}
+}
+
 func (s *Service) isLAN(addr net.Addr) bool {
 	tcpaddr, ok := addr.(*net.TCPAddr)
 	if !ok {
@@ -566,6 +619,19 @@ func (s *Service) Status() map[string]interface{} {
 	return result
 }
 
+// SecurityAlerts returns the security alerts that have not yet been
+// acknowledged, oldest first.
+func (s *Service) SecurityAlerts() []SecurityAlert {
+	return s.alerts.list()
+}
+
+// AcknowledgeSecurityAlert removes the alert with the given ID, and keeps
+// alerts of its kind for its device from being raised again. It returns
+// false if there is no such alert.
+func (s *Service) AcknowledgeSecurityAlert(id int) bool {
+	return s.alerts.acknowledge(id)
+}
+
 func (s *Service) getDialerFactory(cfg config.Configuration, uri *url.URL) (dialerFactory, error) {
 	dialerFactory, ok := dialers[uri.Scheme]
 	if !ok {
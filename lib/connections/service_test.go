@@ -0,0 +1,103 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+func TestInboundOnlyNotDialed(t *testing.T) {
+	myID, _ := protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+	inbound, _ := protocol.DeviceIDFromString("GYRZZQB-IRNPV4Z-T7TC52W-EQYJ3TT-FDQW6MW-DFLMU42-SSSU6EM-FBK2VAY")
+	outbound, _ := protocol.DeviceIDFromString("LGFPDIT-7SKNNJL-VJZA4FC-7QNCRKA-CE753K7-2BW5QDK-2FOZ7FR-FEP57QJ")
+
+	// The devices are dialed in the order configured, so by the time the
+	// outbound one is, the inbound one has been passed over.
+	cfg := config.Wrap("/tmp/test", config.Configuration{
+		Devices: []config.DeviceConfiguration{
+			{DeviceID: inbound, Addresses: []string{"test://inbound"}, InboundOnly: true},
+			{DeviceID: outbound, Addresses: []string{"test://outbound"}},
+		},
+	})
+	dialed := make(chan protocol.DeviceID, 16)
+	dialers["test"] = testDialerFactory{dialed}
+	defer delete(dialers, "test")
+
+	s := &Service{
+		cfg:               cfg,
+		myID:              myID,
+		model:             testModel{},
+		curConMut:         sync.NewMutex(),
+		currentConnection: make(map[protocol.DeviceID]completeConn),
+	}
+	go s.connect()
+
+	select {
+	case id := <-dialed:
+		if id != outbound {
+			t.Fatalf("Dialed %v, expected only %v", id, outbound)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the outbound device to be dialed")
+	}
+}
+
+type testModel struct {
+	Model
+}
+
+func (testModel) ConnectedTo(protocol.DeviceID) bool {
+	return false
+}
+
+func (testModel) TransferCapped(protocol.DeviceID) bool {
+	return false
+}
+
+// testDialerFactory makes dialers that report what they were to dial and
+// then fail.
+type testDialerFactory struct {
+	dialed chan<- protocol.DeviceID
+}
+
+func (f testDialerFactory) New(*config.Wrapper, *tls.Config) genericDialer {
+	return testDialer(f)
+}
+
+func (testDialerFactory) Priority() int {
+	return 10
+}
+
+func (testDialerFactory) Enabled(config.Configuration) bool {
+	return true
+}
+
+func (testDialerFactory) String() string {
+	return "Test Dialer"
+}
+
+type testDialer testDialerFactory
+
+func (d testDialer) Dial(id protocol.DeviceID, uri *url.URL) (internalConn, error) {
+	select {
+	case d.dialed <- id:
+	default:
+	}
+	return internalConn{}, errors.New("not dialing in tests")
+}
+
+func (testDialer) RedialFrequency() time.Duration {
+	return time.Minute
+}
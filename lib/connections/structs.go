@@ -116,10 +116,18 @@ type Model interface {
 	protocol.Model
 	AddConnection(conn Connection, hello protocol.HelloResult)
 	ConnectedTo(remoteID protocol.DeviceID) bool
+	TransferCapped(remoteID protocol.DeviceID) bool
 	OnHello(protocol.DeviceID, net.Addr, protocol.HelloResult) error
 	GetHello(protocol.DeviceID) protocol.HelloIntf
 }
 
+// An AlertStore keeps the times that security alerts were acknowledged, by
+// key, across restarts.
+type AlertStore interface {
+	PutTime(key string, val time.Time)
+	Time(key string) (time.Time, bool)
+}
+
 // serviceFunc wraps a function to create a suture.Service without stop
 // functionality.
 type serviceFunc func()
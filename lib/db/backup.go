@@ -0,0 +1,486 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/rand"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// A backup is a gzipped stream of records, each a type byte followed by the
+// uvarint length of the payload and the payload:
+//
+//	header    the magic, then the IDs of the backup and of its base, if
+//	          incremental, each uvarint length prefixed
+//	database  the name of a database: empty for the main one, otherwise
+//	          the folder of a shard
+//	manifest  the SHA-256 digests of the chunks of the database, in order
+//	chunk     the digest of a chunk, then its entries, each a uvarint
+//	          length prefixed key and value
+//	end
+//
+// Each database is followed by its manifest and then its chunks. The
+// entries are split into chunks where the hashes of their keys say, so
+// changing some entries changes the chunks they're in but not how the rest
+// are chunked. An incremental backup leaves out the chunks in its base;
+// restoring it takes them from there.
+const (
+	backupHeader byte = iota + 1
+	backupDatabase
+	backupManifest
+	backupChunk
+	backupEnd
+)
+
+const (
+	backupMagic         = "STDBBAK1"
+	backupChunkEntries  = 256     // on average, as chosen by the key hashes
+	backupMaxChunkBytes = 4 << 20 // a chunk ends after this many bytes regardless
+	backupMaxRecord     = 64 << 20
+	backupKeepManifests = 8 // the latest backups that can be the base of an incremental one
+)
+
+var (
+	ErrBackupNotFound = errors.New("no such backup to base an incremental backup on; make a full backup")
+	errBackupCorrupt  = errors.New("corrupt backup")
+	errBackupStop     = errors.New("stop reading the backup")
+)
+
+type backupDigest [sha256.Size]byte
+
+// A Backup is a backup of the database and its shards in the making. Each
+// database is backed up as of a snapshot, all taken together when the
+// backup is made, so the database can be used meanwhile.
+type Backup struct {
+	ID   string
+	Base string // the backup this one is incremental to, if any
+
+	db   *Instance
+	dbs  []backupSnapshot
+	base map[backupDigest]struct{}
+}
+
+type backupSnapshot struct {
+	name string
+	snap *leveldb.Snapshot
+}
+
+// NewBackup prepares a backup of the database. With the ID of one of the
+// latest backups as base, it's an incremental backup of what changed since
+// then. Close the backup when done with it.
+func (db *Instance) NewBackup(base string) (*Backup, error) {
+	b := &Backup{
+		ID:   time.Now().UTC().Format("20060102T150405Z") + "-" + rand.String(4),
+		Base: base,
+		db:   db,
+	}
+
+	if base != "" {
+		bs, err := db.Get(db.backupKey(base), nil)
+		if err == leveldb.ErrNotFound {
+			return nil, ErrBackupNotFound
+		} else if err != nil {
+			return nil, err
+		}
+		b.base = make(map[backupDigest]struct{}, len(bs)/sha256.Size)
+		for ; len(bs) >= sha256.Size; bs = bs[sha256.Size:] {
+			var d backupDigest
+			copy(d[:], bs)
+			b.base[d] = struct{}{}
+		}
+	}
+
+	dbs := []*Instance{db}
+	names := []string{""}
+	if db.shards != nil {
+		for _, folder := range db.shards.list() {
+			dbs = append(dbs, db.shards.get(folder))
+			names = append(names, folder)
+		}
+	}
+	for i, idb := range dbs {
+		snap, err := idb.GetSnapshot()
+		if err != nil {
+			b.Close()
+			return nil, err
+		}
+		b.dbs = append(b.dbs, backupSnapshot{names[i], snap})
+	}
+
+	return b, nil
+}
+
+// Close releases the snapshots of the backup.
+func (b *Backup) Close() {
+	for _, s := range b.dbs {
+		s.snap.Release()
+	}
+	b.dbs = nil
+}
+
+// Write writes the backup to w. Once it's written, it can be the base of
+// incremental backups.
+func (b *Backup) Write(w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	bw := &backupWriter{w: gw}
+
+	var header []byte
+	header = append(header, backupMagic...)
+	header = appendBytes(header, []byte(b.ID))
+	header = appendBytes(header, []byte(b.Base))
+	bw.record(backupHeader, header)
+
+	var manifest []byte
+	for _, s := range b.dbs {
+		bw.record(backupDatabase, []byte(s.name))
+
+		var digests []byte
+		err := backupChunks(s.snap, func(d backupDigest, _ []byte) error {
+			digests = append(digests, d[:]...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		bw.record(backupManifest, digests)
+		manifest = append(manifest, digests...)
+
+		err = backupChunks(s.snap, func(d backupDigest, data []byte) error {
+			if _, ok := b.base[d]; !ok {
+				bw.record(backupChunk, append(d[:], data...))
+			}
+			return bw.err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	bw.record(backupEnd, nil)
+	if bw.err != nil {
+		return bw.err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+
+	return b.db.saveBackupManifest(b.ID, manifest)
+}
+
+// backupChunks calls fn with the digest and the data of each chunk of the
+// entries in the snapshot, in order.
+func backupChunks(snap *leveldb.Snapshot, fn func(backupDigest, []byte) error) error {
+	var data []byte
+	entries := 0
+	flush := func() error {
+		if entries == 0 {
+			return nil
+		}
+		err := fn(sha256.Sum256(data), data)
+		data = data[:0]
+		entries = 0
+		return err
+	}
+
+	h := fnv.New32a()
+	iter := snap.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		key := iter.Key()
+		if key[0] == KeyTypeBackup {
+			continue
+		}
+		data = appendBytes(data, key)
+		data = appendBytes(data, iter.Value())
+		entries++
+
+		h.Reset()
+		h.Write(key)
+		if h.Sum32()%backupChunkEntries == 0 || len(data) >= backupMaxChunkBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// saveBackupManifest keeps the digests of the chunks of the backup, for
+// incremental backups to be based on, and forgets those of older backups
+// than the latest few.
+func (db *Instance) saveBackupManifest(id string, digests []byte) error {
+	if err := db.Put(db.backupKey(id), digests, nil); err != nil {
+		return err
+	}
+
+	// The IDs start with the time, so the oldest come first.
+	var keys [][]byte
+	iter := db.NewIterator(util.BytesPrefix([]byte{KeyTypeBackup}), nil)
+	for iter.Next() {
+		keys = append(keys, append([]byte(nil), iter.Key()...))
+	}
+	iter.Release()
+	for i := 0; i < len(keys)-backupKeepManifests; i++ {
+		db.Delete(keys[i], nil)
+	}
+	return nil
+}
+
+// backupKey returns a byte slice encoding the following information:
+//
+//	keyTypeBackup (1 byte)
+//	backup ID (variable size)
+func (db *Instance) backupKey(id string) []byte {
+	return append([]byte{KeyTypeBackup}, id...)
+}
+
+// RestoreBackup creates the database at the path from the backups: a full
+// backup followed by the incremental ones, each based on the one before.
+// The database is restored as of the last of them. There must not be a
+// database at the path already.
+func RestoreBackup(file string, backups []string) (err error) {
+	if len(backups) == 0 {
+		return errors.New("no backups to restore")
+	}
+	for _, path := range []string{file, ShardsLocation(file)} {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("there is a database at %s already", path)
+		}
+	}
+
+	prevID := ""
+	for _, path := range backups {
+		id, base, err := readBackupHeader(path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if base != prevID {
+			if prevID == "" {
+				return fmt.Errorf("%s is incremental to backup %s, which should come before it", path, base)
+			}
+			return fmt.Errorf("%s is incremental to backup %q, not to %s", path, base, prevID)
+		}
+		prevID = id
+	}
+
+	// The databases and which chunks go in them, as of the last backup
+
+	dbs := make(map[string]*leveldb.DB)
+	defer func() {
+		for _, ldb := range dbs {
+			ldb.Close()
+		}
+		if err != nil {
+			Remove(file)
+		}
+	}()
+
+	wanted := make(map[backupDigest][]*leveldb.DB)
+	var cur *leveldb.DB
+	last := backups[len(backups)-1]
+	err = readBackup(last, func(typ byte, payload []byte) error {
+		switch typ {
+		case backupDatabase:
+			path := file
+			if len(payload) > 0 {
+				path = filepath.Join(ShardsLocation(file), hex.EncodeToString(payload))
+			}
+			ldb, err := leveldb.OpenFile(path, nil)
+			if err != nil {
+				return err
+			}
+			dbs[string(payload)] = ldb
+			cur = ldb
+		case backupManifest:
+			if cur == nil || len(payload)%sha256.Size != 0 {
+				return errBackupCorrupt
+			}
+			for ; len(payload) > 0; payload = payload[sha256.Size:] {
+				var d backupDigest
+				copy(d[:], payload)
+				wanted[d] = append(wanted[d], cur)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %v", last, err)
+	}
+
+	// The chunks, from whichever backup has them
+
+	for _, path := range backups {
+		err = readBackup(path, func(typ byte, payload []byte) error {
+			if typ != backupChunk || len(payload) < sha256.Size {
+				return nil
+			}
+			var d backupDigest
+			copy(d[:], payload)
+			targets, ok := wanted[d]
+			if !ok {
+				return nil
+			}
+			data := payload[sha256.Size:]
+			if sha256.Sum256(data) != d {
+				return errBackupCorrupt
+			}
+
+			batch := new(leveldb.Batch)
+			for len(data) > 0 {
+				key, rest, ok := readBytes(data)
+				if !ok {
+					return errBackupCorrupt
+				}
+				val, rest, ok := readBytes(rest)
+				if !ok {
+					return errBackupCorrupt
+				}
+				batch.Put(key, val)
+				data = rest
+			}
+			for _, ldb := range targets {
+				if err := ldb.Write(batch, nil); err != nil {
+					return err
+				}
+			}
+			delete(wanted, d)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	if len(wanted) > 0 {
+		return fmt.Errorf("%d chunks are in none of the backups", len(wanted))
+	}
+	return nil
+}
+
+// readBackupHeader returns the ID of the backup and of its base.
+func readBackupHeader(path string) (id, base string, err error) {
+	err = readBackup(path, func(typ byte, payload []byte) error {
+		payload = payload[len(backupMagic):]
+		idBytes, payload, ok := readBytes(payload)
+		if !ok {
+			return errBackupCorrupt
+		}
+		baseBytes, _, ok := readBytes(payload)
+		if !ok {
+			return errBackupCorrupt
+		}
+		id, base = string(idBytes), string(baseBytes)
+		return errBackupStop
+	})
+	if err == errBackupStop {
+		err = nil
+	}
+	return id, base, err
+}
+
+// readBackup calls fn with each record of the backup after checking the
+// header, until the end record.
+func readBackup(path string, fn func(typ byte, payload []byte) error) error {
+	fd, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	gr, err := gzip.NewReader(fd)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(gr)
+
+	for first := true; ; first = false {
+		typ, err := r.ReadByte()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		} else if err != nil {
+			return err
+		}
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		if size > backupMaxRecord {
+			return errBackupCorrupt
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return err
+		}
+
+		if first != (typ == backupHeader) {
+			return errBackupCorrupt
+		}
+		if first && !bytes.HasPrefix(payload, []byte(backupMagic)) {
+			return errors.New("not a database backup")
+		}
+		if typ == backupEnd {
+			return nil
+		}
+		if err := fn(typ, payload); err != nil {
+			return err
+		}
+	}
+}
+
+type backupWriter struct {
+	w   io.Writer
+	buf [1 + binary.MaxVarintLen64]byte
+	err error
+}
+
+// record writes a record, unless an earlier write failed.
+func (w *backupWriter) record(typ byte, payload []byte) {
+	if w.err != nil {
+		return
+	}
+	w.buf[0] = typ
+	n := binary.PutUvarint(w.buf[1:], uint64(len(payload)))
+	if _, w.err = w.w.Write(w.buf[:1+n]); w.err != nil {
+		return
+	}
+	_, w.err = w.w.Write(payload)
+}
+
+// appendBytes appends bs, prefixed with its uvarint length.
+func appendBytes(dst, bs []byte) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(bs)))
+	dst = append(dst, buf[:n]...)
+	return append(dst, bs...)
+}
+
+// readBytes returns the uvarint length prefixed bytes at the start of bs,
+// and the rest.
+func readBytes(bs []byte) ([]byte, []byte, bool) {
+	size, n := binary.Uvarint(bs)
+	if n <= 0 || uint64(len(bs)-n) < size {
+		return nil, nil, false
+	}
+	return bs[n : n+int(size)], bs[n+int(size):], true
+}
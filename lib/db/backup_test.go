@@ -0,0 +1,117 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func writeBackup(t *testing.T, ldb *Instance, base, path string) string {
+	b, err := ldb.NewBackup(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	fd, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	if err := b.Write(fd); err != nil {
+		t.Fatal(err)
+	}
+	return b.ID
+}
+
+func countBackupChunks(t *testing.T, path string) int {
+	n := 0
+	err := readBackup(path, func(typ byte, _ []byte) error {
+		if typ == backupChunk {
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ldb, err := Open(filepath.Join(dir, "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+
+	a := NewFileSet("a", ldb)
+	a.Update(protocol.LocalDeviceID, []protocol.FileInfo{f1})
+	NewFileSet("b", ldb).Update(protocol.LocalDeviceID, []protocol.FileInfo{f2})
+
+	full := filepath.Join(dir, "full.stbak")
+	fullID := writeBackup(t, ldb, "", full)
+
+	// The incremental backup has what changed since the full one; changes
+	// after it's made aren't in it.
+
+	a.Update(protocol.LocalDeviceID, []protocol.FileInfo{f3})
+	incr := filepath.Join(dir, "incr.stbak")
+	writeBackup(t, ldb, fullID, incr)
+	a.Update(remoteDevice, []protocol.FileInfo{f1})
+
+	if full, incr := countBackupChunks(t, full), countBackupChunks(t, incr); incr >= full {
+		t.Errorf("The incremental backup (%d chunks) should have fewer chunks than the full one (%d chunks)", incr, full)
+	}
+
+	restored := filepath.Join(dir, "restored.db")
+	if err := RestoreBackup(restored, []string{full, incr}); err != nil {
+		t.Fatal(err)
+	}
+	rdb, err := Open(restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	ra := NewFileSet("a", rdb)
+	if n := countHave(ra, protocol.LocalDeviceID); n != 2 {
+		t.Errorf("Expected two files restored in folder a, got %d", n)
+	}
+	if n := countHave(ra, remoteDevice); n != 0 {
+		t.Errorf("Expected no remote files restored, got %d", n)
+	}
+	if f, ok := NewFileSet("b", rdb).Get(protocol.LocalDeviceID, f2.Name); !ok || f.Name != f2.Name {
+		t.Error("File not restored in folder b")
+	}
+
+	// The incremental backup alone, or before its base, doesn't restore.
+
+	if err := RestoreBackup(filepath.Join(dir, "incr.db"), []string{incr}); err == nil {
+		t.Error("Restoring an incremental backup without its base should fail")
+	}
+	if err := RestoreBackup(filepath.Join(dir, "reverse.db"), []string{incr, full}); err == nil {
+		t.Error("Restoring backups in the wrong order should fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "reverse.db")); !os.IsNotExist(err) {
+		t.Error("A failed restore should not leave a database behind")
+	}
+
+	if _, err := ldb.NewBackup("nonexistent"); err != ErrBackupNotFound {
+		t.Errorf("Expected ErrBackupNotFound, got %v", err)
+	}
+}
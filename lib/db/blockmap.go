@@ -24,29 +24,39 @@ const maxBatchSize = 256 << 10
 type BlockMap struct {
 	db     *Instance
 	folder uint32
+
+	main         *Instance // where the shared block index is, if enabled
+	sharedFolder uint32    // the folder in the shared block index
 }
 
 func NewBlockMap(db *Instance, folder uint32) *BlockMap {
-	return &BlockMap{
-		db:     db,
-		folder: folder,
+	m := &BlockMap{
+		db:           db,
+		folder:       folder,
+		main:         db.mainDB(),
+		sharedFolder: folder,
+	}
+	if m.main != db {
+		// The folder IDs of a shard are its own.
+		name, _ := db.folderIdx.Val(folder)
+		m.sharedFolder = m.main.folderIdx.ID(name)
 	}
+	return m
 }
 
-// Add files to the block map, ignoring any deleted or invalid files.
+// Add files to the block map, ignoring any deleted, invalid or placeholder files.
 func (m *BlockMap) Add(files []protocol.FileInfo) error {
-	batch := new(leveldb.Batch)
+	batch, sharedBatch := new(leveldb.Batch), new(leveldb.Batch)
 	buf := make([]byte, 4)
 	var key []byte
 	for _, file := range files {
 		if batch.Len() > maxBatchSize {
-			if err := m.db.Write(batch, nil); err != nil {
+			if err := m.write(batch, sharedBatch); err != nil {
 				return err
 			}
-			batch.Reset()
 		}
 
-		if file.IsDirectory() || file.IsDeleted() || file.IsInvalid() {
+		if file.IsDirectory() || file.IsDeleted() || file.IsInvalid() || file.IsPlaceholder() {
 			continue
 		}
 
@@ -54,32 +64,39 @@ func (m *BlockMap) Add(files []protocol.FileInfo) error {
 			binary.BigEndian.PutUint32(buf, uint32(i))
 			key = m.blockKeyInto(key, block.Hash, file.Name)
 			batch.Put(key, buf)
+			if m.main.sharedBlocks {
+				key = sharedBlockKeyInto(key, block.Hash, m.sharedFolder, file.Name)
+				sharedBatch.Put(key, buf)
+			}
 		}
 	}
-	return m.db.Write(batch, nil)
+	return m.write(batch, sharedBatch)
 }
 
-// Update block map state, removing any deleted or invalid files.
+// Update block map state, removing any deleted, invalid or placeholder files.
 func (m *BlockMap) Update(files []protocol.FileInfo) error {
-	batch := new(leveldb.Batch)
+	batch, sharedBatch := new(leveldb.Batch), new(leveldb.Batch)
 	buf := make([]byte, 4)
 	var key []byte
 	for _, file := range files {
 		if batch.Len() > maxBatchSize {
-			if err := m.db.Write(batch, nil); err != nil {
+			if err := m.write(batch, sharedBatch); err != nil {
 				return err
 			}
-			batch.Reset()
 		}
 
 		if file.IsDirectory() {
 			continue
 		}
 
-		if file.IsDeleted() || file.IsInvalid() {
+		if file.IsDeleted() || file.IsInvalid() || file.IsPlaceholder() {
 			for _, block := range file.Blocks {
 				key = m.blockKeyInto(key, block.Hash, file.Name)
 				batch.Delete(key)
+				if m.main.sharedBlocks {
+					key = sharedBlockKeyInto(key, block.Hash, m.sharedFolder, file.Name)
+					sharedBatch.Delete(key)
+				}
 			}
 			continue
 		}
@@ -88,50 +105,75 @@ func (m *BlockMap) Update(files []protocol.FileInfo) error {
 			binary.BigEndian.PutUint32(buf, uint32(i))
 			key = m.blockKeyInto(key, block.Hash, file.Name)
 			batch.Put(key, buf)
+			if m.main.sharedBlocks {
+				key = sharedBlockKeyInto(key, block.Hash, m.sharedFolder, file.Name)
+				sharedBatch.Put(key, buf)
+			}
 		}
 	}
-	return m.db.Write(batch, nil)
+	return m.write(batch, sharedBatch)
 }
 
 // Discard block map state, removing the given files
 func (m *BlockMap) Discard(files []protocol.FileInfo) error {
-	batch := new(leveldb.Batch)
+	batch, sharedBatch := new(leveldb.Batch), new(leveldb.Batch)
 	var key []byte
 	for _, file := range files {
 		if batch.Len() > maxBatchSize {
-			if err := m.db.Write(batch, nil); err != nil {
+			if err := m.write(batch, sharedBatch); err != nil {
 				return err
 			}
-			batch.Reset()
 		}
 
 		for _, block := range file.Blocks {
 			key = m.blockKeyInto(key, block.Hash, file.Name)
 			batch.Delete(key)
+			if m.main.sharedBlocks {
+				key = sharedBlockKeyInto(key, block.Hash, m.sharedFolder, file.Name)
+				sharedBatch.Delete(key)
+			}
 		}
 	}
-	return m.db.Write(batch, nil)
+	return m.write(batch, sharedBatch)
 }
 
 // Drop block map, removing all entries related to this block map from the db.
 func (m *BlockMap) Drop() error {
-	batch := new(leveldb.Batch)
+	batch, sharedBatch := new(leveldb.Batch), new(leveldb.Batch)
 	iter := m.db.NewIterator(util.BytesPrefix(m.blockKeyInto(nil, nil, "")[:keyPrefixLen+keyFolderLen]), nil)
 	defer iter.Release()
 	for iter.Next() {
 		if batch.Len() > maxBatchSize {
-			if err := m.db.Write(batch, nil); err != nil {
+			if err := m.write(batch, sharedBatch); err != nil {
 				return err
 			}
-			batch.Reset()
 		}
 
 		batch.Delete(iter.Key())
+		if m.main.sharedBlocks {
+			sharedBatch.Delete(sharedBlockKeyInto(nil, blockKeyHash(iter.Key()), m.sharedFolder, blockKeyName(iter.Key())))
+		}
 	}
 	if iter.Error() != nil {
 		return iter.Error()
 	}
-	return m.db.Write(batch, nil)
+	return m.write(batch, sharedBatch)
+}
+
+// write writes and resets the batches of changes to the block map and to
+// the shared block index.
+func (m *BlockMap) write(batch, sharedBatch *leveldb.Batch) error {
+	if err := m.db.Write(batch, nil); err != nil {
+		return err
+	}
+	batch.Reset()
+	if m.main.sharedBlocks {
+		if err := m.main.Write(sharedBatch, nil); err != nil {
+			return err
+		}
+		sharedBatch.Reset()
+	}
+	return nil
 }
 
 func (m *BlockMap) blockKeyInto(o, hash []byte, file string) []byte {
@@ -164,11 +206,16 @@ func (f *BlockFinder) String() string {
 // reason. The iterator finally returns the result, whether or not a
 // satisfying block was eventually found.
 func (f *BlockFinder) Iterate(folders []string, hash []byte, iterFn func(string, string, int32) bool) bool {
+	if f.db.sharedBlocks {
+		return f.iterateShared(folders, hash, iterFn)
+	}
+
 	var key []byte
 	for _, folder := range folders {
-		folderID := f.db.folderIdx.ID([]byte(folder))
+		fdb := f.db.FolderDB(folder)
+		folderID := fdb.folderIdx.ID([]byte(folder))
 		key = blockKeyInto(key, hash, folderID, "")
-		iter := f.db.NewIterator(util.BytesPrefix(key), nil)
+		iter := fdb.NewIterator(util.BytesPrefix(key), nil)
 		defer iter.Release()
 
 		for iter.Next() && iter.Error() == nil {
@@ -182,17 +229,110 @@ func (f *BlockFinder) Iterate(folders []string, hash []byte, iterFn func(string,
 	return false
 }
 
+// iterateShared is Iterate using the index of the blocks of all folders, to
+// find them in one go instead of looking in each folder in turn.
+func (f *BlockFinder) iterateShared(folders []string, hash []byte, iterFn func(string, string, int32) bool) bool {
+	names := make(map[uint32]string, len(folders))
+	for _, folder := range folders {
+		names[f.db.folderIdx.ID([]byte(folder))] = folder
+	}
+
+	key := sharedBlockKeyInto(nil, hash, 0, "")[:keyPrefixLen+keyHashLen]
+	iter := f.db.NewIterator(util.BytesPrefix(key), nil)
+	defer iter.Release()
+
+	for iter.Next() && iter.Error() == nil {
+		folder, ok := names[binary.BigEndian.Uint32(iter.Key()[keyPrefixLen+keyHashLen:])]
+		if !ok {
+			continue
+		}
+		file := string(iter.Key()[keyPrefixLen+keyHashLen+keyFolderLen:])
+		index := int32(binary.BigEndian.Uint32(iter.Value()))
+		if iterFn(folder, osutil.NativeFilename(file), index) {
+			return true
+		}
+	}
+	return false
+}
+
 // Fix repairs incorrect blockmap entries, removing the old entry and
 // replacing it with a new entry for the given block
 func (f *BlockFinder) Fix(folder, file string, index int32, oldHash, newHash []byte) error {
 	buf := make([]byte, 4)
 	binary.BigEndian.PutUint32(buf, uint32(index))
 
-	folderID := f.db.folderIdx.ID([]byte(folder))
+	fdb := f.db.FolderDB(folder)
+	folderID := fdb.folderIdx.ID([]byte(folder))
 	batch := new(leveldb.Batch)
 	batch.Delete(blockKeyInto(nil, oldHash, folderID, file))
 	batch.Put(blockKeyInto(nil, newHash, folderID, file), buf)
-	return f.db.Write(batch, nil)
+	if err := fdb.Write(batch, nil); err != nil {
+		return err
+	}
+
+	if f.db.sharedBlocks {
+		folderID = f.db.folderIdx.ID([]byte(folder))
+		batch.Reset()
+		batch.Delete(sharedBlockKeyInto(nil, oldHash, folderID, file))
+		batch.Put(sharedBlockKeyInto(nil, newHash, folderID, file), buf)
+		return f.db.Write(batch, nil)
+	}
+	return nil
+}
+
+// SetSharedBlockIndex sets whether the blocks of all folders are indexed
+// together by hash, besides by folder, so that a block can be looked up in
+// all of them at once. That takes another entry in the database per block.
+// The index is built when enabled and removed when not, so this is to be
+// called before the folders are used.
+func (db *Instance) SetSharedBlockIndex(enabled bool) error {
+	db.sharedBlocks = false
+	db.dropPrefix([]byte{KeyTypeBlockShared})
+	if !enabled {
+		return nil
+	}
+
+	if err := db.addSharedBlocks(db); err != nil {
+		return err
+	}
+	if db.shards != nil {
+		for _, shard := range db.shards.all() {
+			if err := db.addSharedBlocks(shard); err != nil {
+				return err
+			}
+		}
+	}
+
+	db.sharedBlocks = true
+	return nil
+}
+
+// addSharedBlocks adds the blocks in the database, or shard, to the shared
+// block index.
+func (db *Instance) addSharedBlocks(from *Instance) error {
+	batch := new(leveldb.Batch)
+	iter := from.NewIterator(util.BytesPrefix([]byte{KeyTypeBlock}), nil)
+	defer iter.Release()
+	for iter.Next() {
+		if batch.Len() > maxBatchSize {
+			if err := db.Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+
+		key := iter.Key()
+		folder := binary.BigEndian.Uint32(key[keyPrefixLen:])
+		if from != db {
+			name, _ := from.folderIdx.Val(folder)
+			folder = db.folderIdx.ID(name)
+		}
+		batch.Put(sharedBlockKeyInto(nil, blockKeyHash(key), folder, blockKeyName(key)), iter.Value())
+	}
+	if iter.Error() != nil {
+		return iter.Error()
+	}
+	return db.Write(batch, nil)
 }
 
 // m.blockKey returns a byte slice encoding the following information:
@@ -214,6 +354,30 @@ func blockKeyInto(o, hash []byte, folder uint32, file string) []byte {
 	return o
 }
 
+// sharedBlockKeyInto returns a byte slice encoding the following information:
+//	   keyTypeBlockShared (1 byte)
+//	   block hash (32 bytes)
+//	   folder (4 bytes)
+//	   file name (variable size)
+func sharedBlockKeyInto(o, hash []byte, folder uint32, file string) []byte {
+	reqLen := keyPrefixLen + keyHashLen + keyFolderLen + len(file)
+	if cap(o) < reqLen {
+		o = make([]byte, reqLen)
+	} else {
+		o = o[:reqLen]
+	}
+	o[0] = KeyTypeBlockShared
+	copy(o[keyPrefixLen:keyPrefixLen+keyHashLen], hash)
+	binary.BigEndian.PutUint32(o[keyPrefixLen+keyHashLen:], folder)
+	copy(o[keyPrefixLen+keyHashLen+keyFolderLen:], []byte(file))
+	return o
+}
+
+// blockKeyHash returns the block hash from the block key
+func blockKeyHash(data []byte) []byte {
+	return data[keyPrefixLen+keyFolderLen : keyPrefixLen+keyFolderLen+keyHashLen]
+}
+
 // blockKeyName returns the file name from the block key
 func blockKeyName(data []byte) string {
 	if len(data) < keyPrefixLen+keyFolderLen+keyHashLen+1 {
@@ -250,3 +250,70 @@ func TestBlockFinderFix(t *testing.T) {
 		t.Fatal("Block not found")
 	}
 }
+
+func TestBlockFinderShared(t *testing.T) {
+	db, f := setup()
+
+	m1 := NewBlockMap(db, db.folderIdx.ID([]byte("folder1")))
+	m2 := NewBlockMap(db, db.folderIdx.ID([]byte("folder2")))
+
+	// Blocks added before the shared index is enabled are indexed when it
+	// is, those after as they're added.
+
+	if err := m1.Add([]protocol.FileInfo{f1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSharedBlockIndex(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m2.Add([]protocol.FileInfo{f1, f2}); err != nil {
+		t.Fatal(err)
+	}
+
+	var found []string
+	iterFn := func(folder, file string, index int32) bool {
+		found = append(found, folder+":"+file)
+		return false
+	}
+	f.Iterate(folders, f1.Blocks[3].Hash, iterFn)
+	if len(found) != 2 || found[0] != "folder1:f1" || found[1] != "folder2:f1" {
+		t.Fatal("Unexpected blocks", found)
+	}
+
+	// Only the given folders are looked in.
+
+	found = nil
+	f.Iterate([]string{"folder2"}, f1.Blocks[3].Hash, iterFn)
+	if len(found) != 1 || found[0] != "folder2:f1" {
+		t.Fatal("Unexpected blocks", found)
+	}
+
+	// Removing files and folders removes them from the shared index.
+
+	if err := m2.Discard([]protocol.FileInfo{f1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m1.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if f.Iterate(folders, f1.Blocks[3].Hash, iterFn) {
+		t.Fatal("Unexpected block")
+	}
+	if !f.Iterate(folders, f2.Blocks[0].Hash, func(string, string, int32) bool { return true }) {
+		t.Fatal("Block not found")
+	}
+
+	// Disabling the index removes it, without losing the blocks.
+
+	if err := db.SetSharedBlockIndex(false); err != nil {
+		t.Fatal(err)
+	}
+	iter := db.NewIterator(util.BytesPrefix([]byte{KeyTypeBlockShared}), nil)
+	defer iter.Release()
+	if iter.Next() {
+		t.Error("The shared index was not removed")
+	}
+	if !f.Iterate(folders, f2.Blocks[0].Hash, func(string, string, int32) bool { return true }) {
+		t.Fatal("Block not found")
+	}
+}
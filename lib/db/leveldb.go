@@ -25,6 +25,12 @@ const (
 	KeyTypeFolderIdx
 	KeyTypeDeviceIdx
 	KeyTypeIndexID
+	KeyTypeFolderSelection
+	KeyTypeMigration
+	KeyTypeBlockShared
+	KeyTypeBackup
+	KeyTypeScanCheckpoint
+	KeyTypeSecurityAlert
 )
 
 func (l VersionList) String() string {
@@ -60,8 +66,12 @@ type dbReader interface {
 	Get([]byte, *opt.ReadOptions) ([]byte, error)
 }
 
-// Flush batches to disk when they contain this many records.
-const batchFlushSize = 64
+// Flush batches to disk when they contain this many records, or this many
+// when using the flash storage profile.
+const (
+	batchFlushSize      = 64
+	flashBatchFlushSize = 1024
+)
 
 func getFile(db dbReader, key []byte) (protocol.FileInfo, bool) {
 	bs, err := db.Get(key, nil)
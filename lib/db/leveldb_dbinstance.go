@@ -32,6 +32,12 @@ type Instance struct {
 	location  string
 	folderIdx *smallIndex
 	deviceIdx *smallIndex
+	flushSize int // batch size at which transactions are written out
+
+	sharedBlocks bool // blocks are also indexed by hash across folders
+
+	main   *Instance // the main database, for a folder's shard
+	shards *shardSet // the shards of the folders, for a sharded main database
 }
 
 const (
@@ -41,12 +47,48 @@ const (
 	keyHashLen   = 32
 )
 
+// A StorageProfile tunes how the database writes to disk.
+type StorageProfile int
+
+const (
+	StorageProfileDefault StorageProfile = iota
+	// Keep more in memory before writing, to make fewer and larger writes
+	// to flash storage.
+	StorageProfileFlash
+)
+
 func Open(file string) (*Instance, error) {
+	return OpenWithProfile(file, StorageProfileDefault)
+}
+
+func OpenWithProfile(file string, profile StorageProfile) (*Instance, error) {
 	opts := &opt.Options{
 		OpenFilesCacheCapacity: 100,
 		WriteBuffer:            4 << 20,
 	}
+	flushSize := batchFlushSize
+	if profile == StorageProfileFlash {
+		opts.WriteBuffer = 16 << 20
+		opts.CompactionTableSize = 8 << 20
+		flushSize = flashBatchFlushSize
+	}
+
+	db, err := openLevelDB(file, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	i := newDBInstance(db, file)
+	i.flushSize = flushSize
+	i.shards = newShardSet(i, ShardsLocation(file), opts)
+	if err := i.moveToShards(); err != nil {
+		i.Close()
+		return nil, err
+	}
+	return i, nil
+}
 
+func openLevelDB(file string, opts *opt.Options) (*leveldb.DB, error) {
 	db, err := leveldb.OpenFile(file, opts)
 	if leveldbIsCorrupted(err) {
 		db, err = leveldb.RecoverFile(file, opts)
@@ -55,17 +97,13 @@ func Open(file string) (*Instance, error) {
 		// The database is corrupted, and we've tried to recover it but it
 		// didn't work. At this point there isn't much to do beyond dropping
 		// the database and reindexing...
-		l.Infoln("Database corruption detected, unable to recover. Reinitializing...")
+		l.Infof("Database corruption detected in %s, unable to recover. Reinitializing...", file)
 		if err := os.RemoveAll(file); err != nil {
 			return nil, err
 		}
 		db, err = leveldb.OpenFile(file, opts)
 	}
-	if err != nil {
-		return nil, err
-	}
-
-	return newDBInstance(db, file), nil
+	return db, err
 }
 
 func OpenMemory() *Instance {
@@ -75,8 +113,9 @@ func OpenMemory() *Instance {
 
 func newDBInstance(db *leveldb.DB, location string) *Instance {
 	i := &Instance{
-		DB:       db,
-		location: location,
+		DB:        db,
+		location:  location,
+		flushSize: batchFlushSize,
 	}
 	i.folderIdx = newSmallIndex(i, []byte{KeyTypeFolderIdx})
 	i.deviceIdx = newSmallIndex(i, []byte{KeyTypeDeviceIdx})
@@ -85,7 +124,39 @@ func newDBInstance(db *leveldb.DB, location string) *Instance {
 
 // Committed returns the number of items committed to the database since startup
 func (db *Instance) Committed() int64 {
-	return atomic.LoadInt64(&db.committed)
+	committed := atomic.LoadInt64(&db.committed)
+	if db.shards != nil {
+		for _, shard := range db.shards.open() {
+			committed += shard.Committed()
+		}
+	}
+	return committed
+}
+
+// Close closes the database, along with the shards of the folders.
+func (db *Instance) Close() error {
+	if db.shards != nil {
+		db.shards.close()
+	}
+	return db.DB.Close()
+}
+
+// FolderDB returns the database keeping the index of the folder: its shard,
+// or the database itself when that isn't sharded. The shard can be
+// compacted or backed up by itself.
+func (db *Instance) FolderDB(folder string) *Instance {
+	if db.shards == nil {
+		return db
+	}
+	return db.shards.get(folder)
+}
+
+// mainDB returns the main database, of which this may be a shard.
+func (db *Instance) mainDB() *Instance {
+	if db.main != nil {
+		return db.main
+	}
+	return db
 }
 
 // Location returns the filesystem path where the database is stored
@@ -422,6 +493,10 @@ func (db *Instance) availability(folder, file []byte) []protocol.DeviceID {
 		if !v.Version.Equal(vl.Versions[0].Version) {
 			break
 		}
+		if db.isPlaceholder(folder, v.Device, file) {
+			// The device doesn't have the data.
+			continue
+		}
 		n := protocol.DeviceIDFromBytes(v.Device)
 		devices = append(devices, n)
 	}
@@ -429,6 +504,18 @@ func (db *Instance) availability(folder, file []byte) []protocol.DeviceID {
 	return devices
 }
 
+func (db *Instance) isPlaceholder(folder, device, file []byte) bool {
+	bs, err := db.Get(db.deviceKey(folder, device, file), nil)
+	if err != nil {
+		return false
+	}
+	var f FileInfoTruncated
+	if err := f.Unmarshal(bs); err != nil {
+		return false
+	}
+	return f.IsPlaceholder()
+}
+
 func (db *Instance) withNeed(folder, device []byte, truncate bool, fn Iterator) {
 	t := db.newReadOnlyTransaction()
 	defer t.close()
@@ -532,6 +619,12 @@ func (db *Instance) ListFolders() []string {
 		}
 	}
 
+	if db.shards != nil {
+		for _, folder := range db.shards.list() {
+			folderExists[folder] = true
+		}
+	}
+
 	folders := make([]string, 0, len(folderExists))
 	for k := range folderExists {
 		folders = append(folders, k)
@@ -618,41 +711,6 @@ func (db *Instance) checkGlobals(folder []byte, globalSize *sizeTracker) {
 	l.Debugf("db check completed for %q", folder)
 }
 
-// ConvertSymlinkTypes should be run once only on an old database. It
-// changes SYMLINK_FILE and SYMLINK_DIRECTORY types to the current SYMLINK
-// type (previously SYMLINK_UNKNOWN). It does this for all devices, both
-// local and remote, and does not reset delta indexes. It shouldn't really
-// matter what the symlink type is, but this cleans it up for a possible
-// future when SYMLINK_FILE and SYMLINK_DIRECTORY are no longer understood.
-func (db *Instance) ConvertSymlinkTypes() {
-	t := db.newReadWriteTransaction()
-	defer t.close()
-
-	dbi := t.NewIterator(util.BytesPrefix([]byte{KeyTypeDevice}), nil)
-	defer dbi.Release()
-
-	conv := 0
-	for dbi.Next() {
-		var f protocol.FileInfo
-		if err := f.Unmarshal(dbi.Value()); err != nil {
-			// probably can't happen
-			continue
-		}
-		if f.Type == protocol.FileInfoTypeDeprecatedSymlinkDirectory || f.Type == protocol.FileInfoTypeDeprecatedSymlinkFile {
-			f.Type = protocol.FileInfoTypeSymlink
-			bs, err := f.Marshal()
-			if err != nil {
-				panic("can't happen: " + err.Error())
-			}
-			t.Put(dbi.Key(), bs)
-			t.checkFlush()
-			conv++
-		}
-	}
-
-	l.Infof("Updated symlink type for %d index entries", conv)
-}
-
 // deviceKey returns a byte slice encoding the following information:
 //	   keyTypeDevice (1 byte)
 //	   folder (4 bytes)
@@ -761,6 +819,65 @@ func (db *Instance) mtimesKey(folder []byte) []byte {
 // cause a full index transmission on the next connection.
 func (db *Instance) DropDeltaIndexIDs() {
 	db.dropPrefix([]byte{KeyTypeIndexID})
+	if db.shards != nil {
+		for _, shard := range db.shards.all() {
+			shard.DropDeltaIndexIDs()
+		}
+	}
+}
+
+func (db *Instance) selectionKey(folder []byte) []byte {
+	k := make([]byte, keyPrefixLen+keyFolderLen)
+	k[0] = KeyTypeFolderSelection
+	binary.BigEndian.PutUint32(k[keyPrefixLen:], db.folderIdx.ID(folder))
+	return k
+}
+
+func (db *Instance) getUnselected(folder []byte) []string {
+	bs, err := db.Get(db.selectionKey(folder), nil)
+	if err != nil || len(bs) == 0 {
+		return nil
+	}
+	// The paths are separated by NUL, which can't be part of a file name.
+	return strings.Split(string(bs), "\x00")
+}
+
+func (db *Instance) setUnselected(folder []byte, paths []string) {
+	key := db.selectionKey(folder)
+	if len(paths) == 0 {
+		db.Delete(key, nil)
+		return
+	}
+	db.Put(key, []byte(strings.Join(paths, "\x00")), nil)
+}
+
+func (db *Instance) checkpointKey(folder []byte) []byte {
+	k := make([]byte, keyPrefixLen+keyFolderLen)
+	k[0] = KeyTypeScanCheckpoint
+	binary.BigEndian.PutUint32(k[keyPrefixLen:], db.folderIdx.ID(folder))
+	return k
+}
+
+func (db *Instance) getScanCheckpoint(folder []byte) (path, ignoresHash string, ok bool) {
+	bs, err := db.Get(db.checkpointKey(folder), nil)
+	if err != nil {
+		return "", "", false
+	}
+	// The hash and path are separated by NUL, as in getUnselected.
+	parts := strings.SplitN(string(bs), "\x00", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[1], parts[0], true
+}
+
+func (db *Instance) setScanCheckpoint(folder []byte, path, ignoresHash string) {
+	key := db.checkpointKey(folder)
+	if path == "" {
+		db.Delete(key, nil)
+		return
+	}
+	db.Put(key, []byte(ignoresHash+"\x00"+path), nil)
 }
 
 func (db *Instance) dropMtimes(folder []byte) {
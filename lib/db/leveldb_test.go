@@ -8,6 +8,9 @@ package db
 
 import (
 	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -62,3 +65,30 @@ func TestGlobalKey(t *testing.T) {
 		t.Error("should not have been found")
 	}
 }
+
+func TestOpenWithProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		profile   StorageProfile
+		flushSize int
+	}{
+		{StorageProfileDefault, batchFlushSize},
+		{StorageProfileFlash, flashBatchFlushSize},
+	}
+
+	for i, tc := range cases {
+		db, err := OpenWithProfile(filepath.Join(dir, "db"), tc.profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if db.flushSize != tc.flushSize {
+			t.Errorf("%d: flush size %d != expected %d", i, db.flushSize, tc.flushSize)
+		}
+		db.Close()
+	}
+}
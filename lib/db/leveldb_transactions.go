@@ -61,7 +61,7 @@ func (t readWriteTransaction) close() {
 }
 
 func (t readWriteTransaction) checkFlush() {
-	if t.Batch.Len() > batchFlushSize {
+	if t.Batch.Len() > t.db.flushSize {
 		t.flush()
 		t.Batch.Reset()
 	}
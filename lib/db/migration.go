@@ -0,0 +1,246 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// ErrMigrationCancelled is returned by RunMigrations when cancelled. The
+// migration continues where it left off when next run.
+var ErrMigrationCancelled = errors.New("database migration cancelled")
+
+// A migration converts the entries under a key prefix in place, one by
+// one. The last key converted is recorded along with the conversions, so a
+// migration that's cancelled or killed resumes from there.
+type migration struct {
+	name    string
+	prefix  []byte
+	convert func(value []byte) ([]byte, bool) // returns the new value, if changed
+}
+
+// The migrations, in the order they're run.
+const (
+	MigrationSymlinkTypes = "symlinkTypes"
+)
+
+var migrations = []migration{
+	// Changes SYMLINK_FILE and SYMLINK_DIRECTORY types to the current
+	// SYMLINK type (previously SYMLINK_UNKNOWN), for all devices, both local
+	// and remote, without resetting delta indexes. It shouldn't really
+	// matter what the symlink type is, but this cleans it up for a possible
+	// future when SYMLINK_FILE and SYMLINK_DIRECTORY are no longer
+	// understood.
+	{MigrationSymlinkTypes, []byte{KeyTypeDevice}, convertSymlinkType},
+}
+
+const (
+	migrationCheckpointEntries = 1000
+	migrationProgressInterval  = time.Second
+)
+
+// MigrationProgress is how far a migration is. Done and Total are in bytes
+// of the database, and approximate; Total is zero when unknown.
+type MigrationProgress struct {
+	Name    string    `json:"name"`
+	Done    int64     `json:"done"`
+	Total   int64     `json:"total"`
+	Started time.Time `json:"started"`
+	ETA     time.Time `json:"eta"` // zero when unknown
+}
+
+// Percent returns how far the migration is, in percent, or -1 when
+// unknown.
+func (p MigrationProgress) Percent() int {
+	if p.Total <= 0 {
+		return -1
+	}
+	if p.Done >= p.Total {
+		return 100
+	}
+	return int(100 * p.Done / p.Total)
+}
+
+// ScheduleMigration marks the named migration to be done by RunMigrations,
+// in the database and each of its shards. A migration already scheduled or
+// in progress is unaffected.
+func (db *Instance) ScheduleMigration(name string) {
+	for _, idb := range db.withShards() {
+		key := idb.migrationKey(name)
+		if ok, _ := idb.Has(key, nil); !ok {
+			idb.Put(key, nil, nil)
+		}
+	}
+}
+
+// PendingMigrations returns the names of the migrations that are scheduled
+// or in progress, in the database or any of its shards.
+func (db *Instance) PendingMigrations() []string {
+	dbs := db.withShards()
+	var names []string
+	for _, m := range migrations {
+		for _, idb := range dbs {
+			if ok, _ := idb.Has(idb.migrationKey(m.name), nil); ok {
+				names = append(names, m.name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// RunMigrations runs the pending migrations, in the database and then in
+// each of its shards, calling progress as they go. When cancel is closed
+// they stop at the next safe point and ErrMigrationCancelled is returned.
+func (db *Instance) RunMigrations(cancel <-chan struct{}, progress func(MigrationProgress)) error {
+	for _, idb := range db.withShards() {
+		if err := idb.runMigrations(cancel, progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withShards returns the database followed by its shards, if any.
+func (db *Instance) withShards() []*Instance {
+	if db.shards == nil {
+		return []*Instance{db}
+	}
+	return append([]*Instance{db}, db.shards.all()...)
+}
+
+func (db *Instance) runMigrations(cancel <-chan struct{}, progress func(MigrationProgress)) error {
+	for _, m := range migrations {
+		key := db.migrationKey(m.name)
+		resume, err := db.Get(key, nil)
+		if err != nil {
+			// Not pending
+			continue
+		}
+		if err := db.runMigration(m, key, resume, cancel, progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *Instance) runMigration(m migration, key, resume []byte, cancel <-chan struct{}, progress func(MigrationProgress)) error {
+	rng := util.BytesPrefix(m.prefix)
+	if len(resume) > 0 {
+		l.Infof("Resuming database migration %q", m.name)
+		// The key following the last one converted
+		rng.Start = append(resume, 0)
+	} else {
+		l.Infof("Starting database migration %q", m.name)
+	}
+
+	p := MigrationProgress{
+		Name:    m.name,
+		Started: time.Now(),
+		Total:   db.approximateSize(*util.BytesPrefix(m.prefix)),
+	}
+	doneBefore := db.approximateSize(util.Range{Start: m.prefix, Limit: rng.Start})
+	lastReport := time.Time{}
+
+	t := db.newReadWriteTransaction()
+	defer t.close()
+
+	dbi := t.NewIterator(rng, nil)
+	defer dbi.Release()
+
+	converted, entries := 0, 0
+	for dbi.Next() {
+		if bs, ok := m.convert(dbi.Value()); ok {
+			t.Put(dbi.Key(), bs)
+			converted++
+		}
+		entries++
+		if entries%migrationCheckpointEntries != 0 {
+			continue
+		}
+
+		// Record how far we are along with the conversions so far.
+		last := append([]byte(nil), dbi.Key()...)
+		t.Put(key, last)
+		t.flush()
+		t.Batch.Reset()
+
+		select {
+		case <-cancel:
+			l.Infof("Database migration %q cancelled after converting %d entries", m.name, converted)
+			return ErrMigrationCancelled
+		default:
+		}
+
+		if progress != nil && time.Since(lastReport) > migrationProgressInterval {
+			lastReport = time.Now()
+			p.Done = db.approximateSize(util.Range{Start: m.prefix, Limit: last})
+			p.ETA = migrationETA(p, doneBefore)
+			progress(p)
+		}
+	}
+
+	t.Delete(key)
+	p.Done = p.Total
+	p.ETA = time.Now()
+	if progress != nil {
+		progress(p)
+	}
+	l.Infof("Completed database migration %q, converting %d entries", m.name, converted)
+	return nil
+}
+
+// migrationETA returns when the migration should be done, based on its
+// progress since it started, or the zero time when there's no telling.
+func migrationETA(p MigrationProgress, doneBefore int64) time.Time {
+	done := p.Done - doneBefore
+	elapsed := time.Since(p.Started)
+	if p.Total <= 0 || done <= 0 || elapsed <= 0 {
+		return time.Time{}
+	}
+	remaining := time.Duration(float64(elapsed) * float64(p.Total-p.Done) / float64(done))
+	return time.Now().Add(remaining)
+}
+
+// approximateSize returns the size on disk of the range, or zero when
+// unknown.
+func (db *Instance) approximateSize(rng util.Range) int64 {
+	sizes, err := db.SizeOf([]util.Range{rng})
+	if err != nil {
+		return 0
+	}
+	return sizes.Sum()
+}
+
+// migrationKey returns a byte slice encoding the following information:
+//
+//	keyTypeMigration (1 byte)
+//	name (variable size)
+func (db *Instance) migrationKey(name string) []byte {
+	return append([]byte{KeyTypeMigration}, name...)
+}
+
+func convertSymlinkType(value []byte) ([]byte, bool) {
+	var f protocol.FileInfo
+	if err := f.Unmarshal(value); err != nil {
+		// probably can't happen
+		return nil, false
+	}
+	if f.Type != protocol.FileInfoTypeDeprecatedSymlinkDirectory && f.Type != protocol.FileInfoTypeDeprecatedSymlinkFile {
+		return nil, false
+	}
+	f.Type = protocol.FileInfoTypeSymlink
+	bs, err := f.Marshal()
+	if err != nil {
+		panic("can't happen: " + err.Error())
+	}
+	return bs, true
+}
@@ -0,0 +1,83 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestSymlinkTypesMigration(t *testing.T) {
+	fld := []byte("folder")
+	dev := protocol.LocalDeviceID[:]
+	const files = 2*migrationCheckpointEntries + 10
+
+	db := OpenMemory()
+	for i := 0; i < files; i++ {
+		f := protocol.FileInfo{
+			Name: fmt.Sprintf("file%05d", i),
+			Type: protocol.FileInfoTypeDeprecatedSymlinkFile,
+		}
+		bs, err := f.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Put(db.deviceKey(fld, dev, []byte(f.Name)), bs, nil)
+	}
+
+	db.ScheduleMigration(MigrationSymlinkTypes)
+	if pending := db.PendingMigrations(); len(pending) != 1 || pending[0] != MigrationSymlinkTypes {
+		t.Fatalf("unexpected pending migrations %v", pending)
+	}
+
+	// Cancelled at the first checkpoint
+
+	cancel := make(chan struct{})
+	close(cancel)
+	if err := db.RunMigrations(cancel, nil); err != ErrMigrationCancelled {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+	if pending := db.PendingMigrations(); len(pending) != 1 {
+		t.Fatalf("cancelled migration should be pending, got %v", pending)
+	}
+	if n := countSymlinkTypes(db, fld, dev); n != migrationCheckpointEntries {
+		t.Errorf("expected %d converted entries before cancellation, got %d", migrationCheckpointEntries, n)
+	}
+
+	// Scheduling again keeps the progress
+
+	db.ScheduleMigration(MigrationSymlinkTypes)
+
+	var last MigrationProgress
+	if err := db.RunMigrations(make(chan struct{}), func(p MigrationProgress) {
+		last = p
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if pending := db.PendingMigrations(); len(pending) != 0 {
+		t.Errorf("unexpected pending migrations %v", pending)
+	}
+	if last.Name != MigrationSymlinkTypes || last.ETA.IsZero() {
+		t.Errorf("unexpected final progress %+v", last)
+	}
+	if n := countSymlinkTypes(db, fld, dev); n != files {
+		t.Errorf("expected %d converted entries, got %d", files, n)
+	}
+}
+
+func countSymlinkTypes(db *Instance, folder, device []byte) int {
+	n := 0
+	db.withHave(folder, device, nil, false, func(fi FileIntf) bool {
+		if fi.(protocol.FileInfo).Type == protocol.FileInfoTypeSymlink {
+			n++
+		}
+		return true
+	})
+	return n
+}
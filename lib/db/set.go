@@ -114,6 +114,7 @@ func (s *sizeTracker) Size() Counts {
 }
 
 func NewFileSet(folder string, db *Instance) *FileSet {
+	db = db.FolderDB(folder)
 	var s = FileSet{
 		remoteSequence: make(map[protocol.DeviceID]int64),
 		folder:         folder,
@@ -306,6 +307,30 @@ func (s *FileSet) MtimeFS() *fs.MtimeFS {
 	return fs.NewMtimeFS(kv)
 }
 
+// Unselected returns the directories of the folder that are not synced
+// locally, as set by SetUnselected.
+func (s *FileSet) Unselected() []string {
+	return s.db.getUnselected([]byte(s.folder))
+}
+
+// SetUnselected persists the directories of the folder that are not synced
+// locally, replacing any previous set.
+func (s *FileSet) SetUnselected(dirs []string) {
+	s.db.setUnselected([]byte(s.folder), dirs)
+}
+
+// ScanCheckpoint returns how far an interrupted full scan of the folder
+// got, and the hash of the ignore patterns it was made with, if any.
+func (s *FileSet) ScanCheckpoint() (path, ignoresHash string, ok bool) {
+	return s.db.getScanCheckpoint([]byte(s.folder))
+}
+
+// SetScanCheckpoint persists how far a full scan of the folder has got; an
+// empty path clears it.
+func (s *FileSet) SetScanCheckpoint(path, ignoresHash string) {
+	s.db.setScanCheckpoint([]byte(s.folder), path, ignoresHash)
+}
+
 func (s *FileSet) ListDevices() []protocol.DeviceID {
 	s.updateMutex.Lock()
 	devices := make([]protocol.DeviceID, 0, len(s.remoteSequence))
@@ -335,13 +360,20 @@ func maxSequence(fs []protocol.FileInfo) int64 {
 // DropFolder clears out all information related to the given folder from the
 // database.
 func DropFolder(db *Instance, folder string) {
+	if db.shards != nil {
+		if db.sharedBlocks {
+			shard := db.FolderDB(folder)
+			NewBlockMap(shard, shard.folderIdx.ID([]byte(folder))).Drop()
+		}
+		db.shards.drop(folder)
+		return
+	}
+
 	db.dropFolder([]byte(folder))
 	db.dropMtimes([]byte(folder))
-	bm := &BlockMap{
-		db:     db,
-		folder: db.folderIdx.ID([]byte(folder)),
-	}
-	bm.Drop()
+	db.setUnselected([]byte(folder), nil)
+	db.setScanCheckpoint([]byte(folder), "", "")
+	NewBlockMap(db, db.folderIdx.ID([]byte(folder))).Drop()
 }
 
 func normalizeFilenames(fs []protocol.FileInfo) {
@@ -10,6 +10,7 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"reflect"
 	"sort"
 	"testing"
 
@@ -416,6 +417,38 @@ func TestInvalidAvailability(t *testing.T) {
 	}
 }
 
+func TestPlaceholderAvailability(t *testing.T) {
+	ldb := db.OpenMemory()
+
+	s := db.NewFileSet("test", ldb)
+
+	remote0Have := fileList{
+		protocol.FileInfo{Name: "both", Version: protocol.Vector{Counters: []protocol.Counter{{ID: myID, Value: 1001}}}, Blocks: genBlocks(2)},
+		protocol.FileInfo{Name: "r1only", Version: protocol.Vector{Counters: []protocol.Counter{{ID: myID, Value: 1002}}}, Blocks: genBlocks(5), Placeholder: true},
+	}
+	remote1Have := fileList{
+		protocol.FileInfo{Name: "both", Version: protocol.Vector{Counters: []protocol.Counter{{ID: myID, Value: 1001}}}, Blocks: genBlocks(2)},
+		protocol.FileInfo{Name: "r1only", Version: protocol.Vector{Counters: []protocol.Counter{{ID: myID, Value: 1002}}}, Blocks: genBlocks(5)},
+	}
+
+	s.Replace(remoteDevice0, remote0Have)
+	s.Replace(remoteDevice1, remote1Have)
+
+	if av := s.Availability("both"); len(av) != 2 {
+		t.Error("Incorrect availability for 'both':", av)
+	}
+
+	if av := s.Availability("r1only"); len(av) != 1 || av[0] != remoteDevice1 {
+		t.Error("Incorrect availability for 'r1only':", av)
+	}
+
+	// The device with the placeholder doesn't need the file.
+
+	if need := needList(s, remoteDevice0); len(need) != 0 {
+		t.Error("Incorrect need:", need)
+	}
+}
+
 func TestGlobalReset(t *testing.T) {
 	ldb := db.OpenMemory()
 
@@ -731,3 +764,55 @@ func TestIndexID(t *testing.T) {
 		t.Errorf("index ID changed; %d != %d", again, id)
 	}
 }
+
+func TestUnselected(t *testing.T) {
+	ldb := db.OpenMemory()
+
+	s := db.NewFileSet("test", ldb)
+	if unselected := s.Unselected(); len(unselected) != 0 {
+		t.Fatalf("expected nothing unselected by default, got %v", unselected)
+	}
+
+	expected := []string{"a/b", "c"}
+	s.SetUnselected(expected)
+
+	// It's persisted, and so seen by a new file set for the folder.
+	s = db.NewFileSet("test", ldb)
+	if unselected := s.Unselected(); !reflect.DeepEqual(unselected, expected) {
+		t.Errorf("unselected %v != expected %v", unselected, expected)
+	}
+	if unselected := db.NewFileSet("other", ldb).Unselected(); len(unselected) != 0 {
+		t.Errorf("selection leaked to another folder: %v", unselected)
+	}
+
+	db.DropFolder(ldb, "test")
+	if unselected := s.Unselected(); len(unselected) != 0 {
+		t.Errorf("expected selection to be dropped with the folder, got %v", unselected)
+	}
+}
+
+func TestScanCheckpoint(t *testing.T) {
+	ldb := db.OpenMemory()
+
+	s := db.NewFileSet("test", ldb)
+	if _, _, ok := s.ScanCheckpoint(); ok {
+		t.Fatal("expected no checkpoint by default")
+	}
+
+	s.SetScanCheckpoint("a/b", "hash")
+	s = db.NewFileSet("test", ldb)
+	if path, hash, ok := s.ScanCheckpoint(); !ok || path != "a/b" || hash != "hash" {
+		t.Errorf("checkpoint %q, %q, %v != a/b, hash, true", path, hash, ok)
+	}
+
+	s.SetScanCheckpoint("", "")
+	if _, _, ok := s.ScanCheckpoint(); ok {
+		t.Error("expected the checkpoint to be cleared")
+	}
+
+	s.SetScanCheckpoint("c", "")
+	db.DropFolder(ldb, "test")
+	if _, _, ok := s.ScanCheckpoint(); ok {
+		t.Error("expected the checkpoint to be dropped with the folder")
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// The index of each folder is kept in a database of its own, a shard, in a
+// directory next to the main database. A folder can then be reset,
+// compacted or backed up by itself, and a corrupted shard only loses the
+// index of that folder. Each shard numbers its folder and devices itself,
+// so it doesn't depend on the main database, which keeps what isn't per
+// folder: statistics, migrations and the shared block index.
+const shardsSuffix = ".folders"
+
+// Shards keep fewer files open than the main database, as there may be
+// many of them.
+const shardOpenFilesCacheCapacity = 16
+
+// The key types kept in the shards
+var shardKeyTypes = []byte{
+	KeyTypeDevice,
+	KeyTypeGlobal,
+	KeyTypeBlock,
+	KeyTypeVirtualMtime,
+	KeyTypeIndexID,
+	KeyTypeFolderSelection,
+	KeyTypeScanCheckpoint,
+}
+
+// ShardsLocation returns the directory of the shards of the database at
+// the path.
+func ShardsLocation(file string) string {
+	return file + shardsSuffix
+}
+
+// Remove removes the database at the path, along with its shards.
+func Remove(file string) error {
+	if err := os.RemoveAll(ShardsLocation(file)); err != nil {
+		return err
+	}
+	return os.RemoveAll(file)
+}
+
+type shardSet struct {
+	main   *Instance
+	dir    string
+	opts   opt.Options
+	shards map[string]*Instance
+	mut    sync.Mutex
+}
+
+func newShardSet(main *Instance, dir string, opts *opt.Options) *shardSet {
+	s := &shardSet{
+		main:   main,
+		dir:    dir,
+		opts:   *opts,
+		shards: make(map[string]*Instance),
+		mut:    sync.NewMutex(),
+	}
+	s.opts.OpenFilesCacheCapacity = shardOpenFilesCacheCapacity
+	return s
+}
+
+// path returns the directory of the folder's shard. The folder ID is hex
+// encoded, as it may contain any characters and folder IDs differing only
+// in case must not share a shard on case insensitive file systems.
+func (s *shardSet) path(folder string) string {
+	return filepath.Join(s.dir, hex.EncodeToString([]byte(folder)))
+}
+
+// get returns the shard of the folder, opening or creating it as needed.
+func (s *shardSet) get(folder string) *Instance {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if shard, ok := s.shards[folder]; ok {
+		return shard
+	}
+
+	path := s.path(folder)
+	ldb, err := openLevelDB(path, &s.opts)
+	if err != nil {
+		panic("opening database shard: " + err.Error())
+	}
+	shard := newDBInstance(ldb, path)
+	shard.flushSize = s.main.flushSize
+	shard.main = s.main
+	s.shards[folder] = shard
+	return shard
+}
+
+// list returns the folders that have shards, sorted.
+func (s *shardSet) list() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	exists := make(map[string]bool, len(s.shards))
+	for folder := range s.shards {
+		exists[folder] = true
+	}
+	infos, _ := ioutil.ReadDir(s.dir)
+	for _, info := range infos {
+		folder, err := hex.DecodeString(info.Name())
+		if err == nil && info.IsDir() {
+			exists[string(folder)] = true
+		}
+	}
+
+	folders := make([]string, 0, len(exists))
+	for folder := range exists {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+	return folders
+}
+
+// open returns the shards that are open.
+func (s *shardSet) open() []*Instance {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	shards := make([]*Instance, 0, len(s.shards))
+	for _, shard := range s.shards {
+		shards = append(shards, shard)
+	}
+	return shards
+}
+
+// all returns all the shards, opening them as needed.
+func (s *shardSet) all() []*Instance {
+	folders := s.list()
+	shards := make([]*Instance, len(folders))
+	for i, folder := range folders {
+		shards[i] = s.get(folder)
+	}
+	return shards
+}
+
+// drop removes the index of the folder. A shard that isn't open is removed
+// altogether; one that is may still be used, and is emptied instead.
+func (s *shardSet) drop(folder string) {
+	s.mut.Lock()
+	shard, ok := s.shards[folder]
+	if !ok {
+		os.RemoveAll(s.path(folder))
+		s.mut.Unlock()
+		return
+	}
+	s.mut.Unlock()
+
+	for _, keyType := range shardKeyTypes {
+		shard.dropPrefix([]byte{keyType})
+	}
+	shard.CompactRange(util.Range{})
+}
+
+func (s *shardSet) close() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for folder, shard := range s.shards {
+		shard.DB.Close()
+		delete(s.shards, folder)
+	}
+}
+
+// moveToShards moves the index of each folder from the main database, where
+// it was kept before there were shards, to the folder's shard. The keys are
+// renumbered for the shard on the way. Each batch is written to the shards
+// before it's removed from the main database, so this can be interrupted
+// and resumed.
+func (db *Instance) moveToShards() error {
+	moved := 0
+	batches := make(map[*Instance]*leveldb.Batch)
+	remove := new(leveldb.Batch)
+
+	flush := func() error {
+		for shard, batch := range batches {
+			if err := shard.Write(batch, nil); err != nil {
+				return err
+			}
+			delete(batches, shard)
+		}
+		if err := db.Write(remove, nil); err != nil {
+			return err
+		}
+		moved += remove.Len()
+		remove.Reset()
+		return nil
+	}
+
+	for _, keyType := range shardKeyTypes {
+		iter := db.NewIterator(util.BytesPrefix([]byte{keyType}), nil)
+		for iter.Next() {
+			if remove.Len() > maxBatchSize {
+				if err := flush(); err != nil {
+					iter.Release()
+					return err
+				}
+			}
+
+			key := iter.Key()
+			remove.Delete(key)
+			shard, shardKey, ok := db.shardKey(key)
+			if !ok {
+				// Of a folder or device that no longer exists
+				continue
+			}
+			batch, ok := batches[shard]
+			if !ok {
+				batch = new(leveldb.Batch)
+				batches[shard] = batch
+			}
+			batch.Put(shardKey, iter.Value())
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+	if moved > 0 {
+		l.Infof("Moved %d database entries to the folder shards", moved)
+		// Migrations in progress start over in the shards, as where they
+		// were is no longer where they are.
+		for _, name := range db.PendingMigrations() {
+			db.Delete(db.migrationKey(name), nil)
+			db.ScheduleMigration(name)
+		}
+		db.CompactRange(util.Range{})
+	}
+	return nil
+}
+
+// shardKey returns the shard for the key of the main database, and the
+// key in that shard.
+func (db *Instance) shardKey(key []byte) (*Instance, []byte, bool) {
+	var folderID, deviceID uint32
+	var rest []byte
+	hasDevice := false
+
+	switch key[0] {
+	case KeyTypeDevice:
+		folderID = binary.BigEndian.Uint32(key[keyPrefixLen:])
+		deviceID = binary.BigEndian.Uint32(key[keyPrefixLen+keyFolderLen:])
+		hasDevice = true
+		rest = key[keyPrefixLen+keyFolderLen+keyDeviceLen:]
+	case KeyTypeIndexID:
+		deviceID = binary.BigEndian.Uint32(key[keyPrefixLen:])
+		folderID = binary.BigEndian.Uint32(key[keyPrefixLen+keyDeviceLen:])
+		hasDevice = true
+	default:
+		folderID = binary.BigEndian.Uint32(key[keyPrefixLen:])
+		rest = key[keyPrefixLen+keyFolderLen:]
+	}
+
+	folder, ok := db.folderIdx.Val(folderID)
+	if !ok {
+		return nil, nil, false
+	}
+	var device []byte
+	if hasDevice {
+		if device, ok = db.deviceIdx.Val(deviceID); !ok {
+			return nil, nil, false
+		}
+	}
+
+	shard := db.FolderDB(string(folder))
+	switch key[0] {
+	case KeyTypeDevice:
+		return shard, shard.deviceKey(folder, device, rest), true
+	case KeyTypeIndexID:
+		return shard, shard.indexIDKey(device, folder), true
+	case KeyTypeGlobal:
+		return shard, shard.globalKey(folder, rest), true
+	case KeyTypeBlock:
+		hash := rest[:keyHashLen]
+		return shard, blockKeyInto(nil, hash, shard.folderIdx.ID(folder), string(rest[keyHashLen:])), true
+	case KeyTypeVirtualMtime:
+		return shard, append(shard.mtimesKey(folder), rest...), true
+	case KeyTypeScanCheckpoint:
+		return shard, shard.checkpointKey(folder), true
+	default: // KeyTypeFolderSelection
+		return shard, shard.selectionKey(folder), true
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+var remoteDevice = protocol.DeviceID{1, 2, 3}
+
+func countHave(s *FileSet, device protocol.DeviceID) int {
+	n := 0
+	s.WithHaveTruncated(device, func(FileIntf) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func TestShards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "index.db")
+
+	ldb, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	NewFileSet("a", ldb).Update(protocol.LocalDeviceID, []protocol.FileInfo{f1})
+	NewFileSet("B", ldb).Update(protocol.LocalDeviceID, []protocol.FileInfo{f2})
+	NewFileSet("b", ldb).Update(protocol.LocalDeviceID, []protocol.FileInfo{f3})
+
+	if iter := ldb.NewIterator(util.BytesPrefix([]byte{KeyTypeDevice}), nil); iter.Next() {
+		t.Error("The index should be in the shards, not the main database")
+	}
+	if folders := ldb.ListFolders(); !reflect.DeepEqual(folders, []string{"B", "a", "b"}) {
+		t.Errorf("Unexpected folders %v", folders)
+	}
+	ldb.Close()
+
+	// The shards are there when reopened, and blocks are found in them.
+
+	ldb, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := NewFileSet("b", ldb).Get(protocol.LocalDeviceID, f3.Name); !ok || f.Name != f3.Name {
+		t.Error("File not found after reopening")
+	}
+	found := NewBlockFinder(ldb).Iterate([]string{"B"}, f2.Blocks[1].Hash, func(folder, file string, index int32) bool {
+		return folder == "B" && file == f2.Name && index == 1
+	})
+	if !found {
+		t.Error("Block not found")
+	}
+
+	// Dropping a folder that's in use empties its shard, one that isn't
+	// removes it.
+
+	DropFolder(ldb, "b")
+	if _, ok := NewFileSet("b", ldb).Get(protocol.LocalDeviceID, f3.Name); ok {
+		t.Error("Dropped file found")
+	}
+	DropFolder(ldb, "a")
+	if _, err := os.Stat(ldb.shards.path("a")); !os.IsNotExist(err) {
+		t.Error("The shard of the dropped folder was not removed")
+	}
+	if folders := ldb.ListFolders(); !reflect.DeepEqual(folders, []string{"B", "b"}) {
+		t.Errorf("Unexpected folders %v", folders)
+	}
+	ldb.Close()
+}
+
+func TestMoveToShards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "index.db")
+
+	// An index from before there were shards
+
+	ldb, err := openLevelDB(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := newDBInstance(ldb, path)
+	s := NewFileSet("a", old)
+	s.Update(protocol.LocalDeviceID, []protocol.FileInfo{f1, f2})
+	s.Update(remoteDevice, []protocol.FileInfo{f1})
+	s.SetIndexID(remoteDevice, 42)
+	s.SetUnselected([]string{"dir"})
+	s.MtimeFS()
+	NewFileSet("b", old).Update(remoteDevice, []protocol.FileInfo{f3})
+	old.ScheduleMigration(MigrationSymlinkTypes)
+	ldb.Close()
+
+	sharded, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sharded.Close()
+
+	for _, keyType := range shardKeyTypes {
+		if iter := sharded.NewIterator(util.BytesPrefix([]byte{keyType}), nil); iter.Next() {
+			t.Errorf("Key type %d left in the main database", keyType)
+		}
+	}
+
+	s = NewFileSet("a", sharded)
+	if n := countHave(s, protocol.LocalDeviceID); n != 2 {
+		t.Errorf("%d local files, expected 2", n)
+	}
+	if n := countHave(s, remoteDevice); n != 1 {
+		t.Errorf("%d remote files, expected 1", n)
+	}
+	if g, ok := s.GetGlobal(f2.Name); !ok || g.Name != f2.Name {
+		t.Error("Global file not found")
+	}
+	if id := s.IndexID(remoteDevice); id != 42 {
+		t.Errorf("Index ID %v, expected 42", id)
+	}
+	if dirs := s.Unselected(); !reflect.DeepEqual(dirs, []string{"dir"}) {
+		t.Errorf("Unexpected unselected directories %v", dirs)
+	}
+	if n := countHave(NewFileSet("b", sharded), remoteDevice); n != 1 {
+		t.Errorf("%d files in the other folder, expected 1", n)
+	}
+
+	// The migration is redone in the shards.
+
+	if ok, _ := sharded.FolderDB("a").Has(sharded.migrationKey(MigrationSymlinkTypes), nil); !ok {
+		t.Error("The pending migration should be scheduled in the shards")
+	}
+	if err := sharded.RunMigrations(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if pending := sharded.PendingMigrations(); len(pending) != 0 {
+		t.Errorf("Migrations %v still pending", pending)
+	}
+}
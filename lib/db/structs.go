@@ -29,6 +29,10 @@ func (f FileInfoTruncated) IsInvalid() bool {
 	return f.Invalid
 }
 
+func (f FileInfoTruncated) IsPlaceholder() bool {
+	return f.Placeholder
+}
+
 func (f FileInfoTruncated) IsDirectory() bool {
 	return f.Type == protocol.FileInfoTypeDirectory
 }
@@ -63,3 +67,10 @@ func (f FileInfoTruncated) FileName() string {
 func (f FileInfoTruncated) ModTime() time.Time {
 	return time.Unix(f.ModifiedS, int64(f.ModifiedNs))
 }
+
+func (f FileInfoTruncated) BlockSize() int {
+	if f.RawBlockSize == 0 {
+		return protocol.BlockSize
+	}
+	return int(f.RawBlockSize)
+}
@@ -68,6 +68,9 @@ type FileInfoTruncated struct {
 	NoPermissions bool                                                `protobuf:"varint,8,opt,name=no_permissions,json=noPermissions,proto3" json:"no_permissions,omitempty"`
 	Version       protocol.Vector                                     `protobuf:"bytes,9,opt,name=version" json:"version"`
 	Sequence      int64                                               `protobuf:"varint,10,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	RawBlockSize  int32                                               `protobuf:"varint,13,opt,name=raw_block_size,json=rawBlockSize,proto3" json:"raw_block_size,omitempty"`
+	Placeholder   bool                                                `protobuf:"varint,14,opt,name=placeholder,proto3" json:"placeholder,omitempty"`
+	ClockSkewMs   int64                                               `protobuf:"varint,15,opt,name=clock_skew_ms,json=clockSkewMs,proto3" json:"clock_skew_ms,omitempty"`
 	SymlinkTarget string                                              `protobuf:"bytes,17,opt,name=symlink_target,json=symlinkTarget,proto3" json:"symlink_target,omitempty"`
 }
 
@@ -236,6 +239,26 @@ func (m *FileInfoTruncated) MarshalTo(dAtA []byte) (int, error) {
 		i++
 		i = encodeVarintStructs(dAtA, i, uint64(m.ModifiedBy))
 	}
+	if m.RawBlockSize != 0 {
+		dAtA[i] = 0x68
+		i++
+		i = encodeVarintStructs(dAtA, i, uint64(m.RawBlockSize))
+	}
+	if m.Placeholder {
+		dAtA[i] = 0x70
+		i++
+		if m.Placeholder {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.ClockSkewMs != 0 {
+		dAtA[i] = 0x78
+		i++
+		i = encodeVarintStructs(dAtA, i, uint64(m.ClockSkewMs))
+	}
 	if len(m.SymlinkTarget) > 0 {
 		dAtA[i] = 0x8a
 		i++
@@ -337,6 +360,15 @@ func (m *FileInfoTruncated) ProtoSize() (n int) {
 	if m.ModifiedBy != 0 {
 		n += 1 + sovStructs(uint64(m.ModifiedBy))
 	}
+	if m.RawBlockSize != 0 {
+		n += 1 + sovStructs(uint64(m.RawBlockSize))
+	}
+	if m.Placeholder {
+		n += 2
+	}
+	if m.ClockSkewMs != 0 {
+		n += 1 + sovStructs(uint64(m.ClockSkewMs))
+	}
 	l = len(m.SymlinkTarget)
 	if l > 0 {
 		n += 2 + l + sovStructs(uint64(l))
@@ -830,6 +862,64 @@ func (m *FileInfoTruncated) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RawBlockSize", wireType)
+			}
+			m.RawBlockSize = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RawBlockSize |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 14:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Placeholder", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Placeholder = bool(v != 0)
+		case 15:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ClockSkewMs", wireType)
+			}
+			m.ClockSkewMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ClockSkewMs |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		case 17:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field SymlinkTarget", wireType)
@@ -988,36 +1078,39 @@ var (
 func init() { proto.RegisterFile("structs.proto", fileDescriptorStructs) }
 
 var fileDescriptorStructs = []byte{
-	// 483 bytes of a gzipped FileDescriptorProto
+	// 540 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x52, 0x4f, 0x6b, 0xdb, 0x4e,
-	0x10, 0xf5, 0xc6, 0x8a, 0xff, 0xac, 0xe2, 0xfc, 0x7e, 0x59, 0x4a, 0x58, 0x0c, 0x95, 0x85, 0xa1,
-	0x20, 0x0a, 0x95, 0x5b, 0x87, 0x5e, 0xda, 0x9b, 0x29, 0x81, 0x40, 0x29, 0x45, 0x09, 0xe9, 0xa5,
-	0x60, 0x2c, 0x69, 0x2c, 0x2f, 0x95, 0x76, 0x55, 0xed, 0xca, 0xa0, 0x7e, 0x92, 0x1e, 0xf3, 0x71,
-	0x7c, 0xec, 0xb9, 0x87, 0xd0, 0xba, 0x9f, 0xa3, 0x50, 0xb4, 0x92, 0x15, 0x1d, 0xdb, 0xdb, 0xbc,
-	0xd9, 0xf7, 0xe6, 0xbd, 0x61, 0x16, 0x8f, 0xa4, 0xca, 0xf2, 0x40, 0x49, 0x37, 0xcd, 0x84, 0x12,
-	0xe4, 0x28, 0xf4, 0xc7, 0xcf, 0x22, 0xa6, 0x36, 0xb9, 0xef, 0x06, 0x22, 0x99, 0x45, 0x22, 0x12,
-	0x33, 0xfd, 0xe4, 0xe7, 0x6b, 0x8d, 0x34, 0xd0, 0x55, 0x25, 0x19, 0xbf, 0x6c, 0xd1, 0x65, 0xc1,
-	0x03, 0xb5, 0x61, 0x3c, 0x6a, 0x55, 0x31, 0xf3, 0xab, 0x09, 0x81, 0x88, 0x67, 0x3e, 0xa4, 0x95,
-	0x6c, 0xfa, 0x01, 0x9b, 0x97, 0x2c, 0x86, 0x5b, 0xc8, 0x24, 0x13, 0x9c, 0x3c, 0xc7, 0xfd, 0x6d,
-	0x55, 0x52, 0x64, 0x23, 0xc7, 0x9c, 0xff, 0xef, 0x1e, 0x44, 0xee, 0x2d, 0x04, 0x4a, 0x64, 0x0b,
-	0x63, 0x77, 0x3f, 0xe9, 0x78, 0x07, 0x1a, 0x39, 0xc7, 0xbd, 0x10, 0xb6, 0x2c, 0x00, 0x7a, 0x64,
-	0x23, 0xe7, 0xc4, 0xab, 0xd1, 0xf4, 0x12, 0x9b, 0xf5, 0xd0, 0xb7, 0x4c, 0x2a, 0xf2, 0x02, 0x0f,
-	0x6a, 0x85, 0xa4, 0xc8, 0xee, 0x3a, 0xe6, 0xfc, 0x3f, 0x37, 0xf4, 0xdd, 0x96, 0x77, 0x3d, 0xb8,
-	0xa1, 0xbd, 0x32, 0xbe, 0xde, 0x4d, 0x3a, 0xd3, 0xdf, 0x5d, 0x7c, 0x56, 0xb2, 0xae, 0xf8, 0x5a,
-	0xdc, 0x64, 0x39, 0x0f, 0x56, 0x0a, 0x42, 0x42, 0xb0, 0xc1, 0x57, 0x09, 0xe8, 0x90, 0x43, 0x4f,
-	0xd7, 0xe4, 0x29, 0x36, 0x54, 0x91, 0x56, 0x39, 0x4e, 0xe7, 0xe7, 0x0f, 0xc1, 0x1b, 0x79, 0x91,
-	0x82, 0xa7, 0x39, 0xa5, 0x5e, 0xb2, 0x2f, 0x40, 0xbb, 0x36, 0x72, 0xba, 0x9e, 0xae, 0x89, 0x8d,
-	0xcd, 0x14, 0xb2, 0x84, 0xc9, 0x2a, 0xa5, 0x61, 0x23, 0x67, 0xe4, 0xb5, 0x5b, 0xe4, 0x31, 0xc6,
-	0x89, 0x08, 0xd9, 0x9a, 0x41, 0xb8, 0x94, 0xf4, 0x58, 0x6b, 0x87, 0x87, 0xce, 0x35, 0xa1, 0xb8,
-	0x1f, 0x42, 0x0c, 0x0a, 0x42, 0xda, 0xb3, 0x91, 0x33, 0xf0, 0x0e, 0xb0, 0x7c, 0x61, 0x7c, 0xbb,
-	0x8a, 0x59, 0x48, 0xfb, 0xd5, 0x4b, 0x0d, 0xc9, 0x13, 0x7c, 0xca, 0xc5, 0xb2, 0xed, 0x3b, 0xd0,
-	0x84, 0x11, 0x17, 0xef, 0x5b, 0xce, 0xad, 0xbb, 0x0c, 0xff, 0xee, 0x2e, 0x63, 0x3c, 0x90, 0xf0,
-	0x39, 0x07, 0x1e, 0x00, 0xc5, 0x3a, 0x69, 0x83, 0xc9, 0x04, 0x9b, 0xcd, 0x1e, 0x5c, 0x52, 0xd3,
-	0x46, 0xce, 0xb1, 0xd7, 0xac, 0xf6, 0x4e, 0x92, 0x8f, 0x2d, 0x82, 0x5f, 0xd0, 0x13, 0x1b, 0x39,
-	0xc6, 0xe2, 0x75, 0x69, 0xf0, 0xfd, 0x7e, 0x72, 0xf1, 0x0f, 0x3f, 0xcd, 0xbd, 0xde, 0x88, 0x4c,
-	0x5d, 0xbd, 0x79, 0x98, 0xbe, 0x28, 0xca, 0x9d, 0x65, 0x91, 0xc4, 0x8c, 0x7f, 0x5a, 0xaa, 0x55,
-	0x16, 0x81, 0xa2, 0x67, 0xfa, 0x8c, 0xa3, 0xba, 0x7b, 0xa3, 0x9b, 0xd5, 0xfd, 0x17, 0x8f, 0x76,
-	0x3f, 0xad, 0xce, 0x6e, 0x6f, 0xa1, 0x6f, 0x7b, 0x0b, 0xfd, 0xd8, 0x5b, 0x9d, 0xbb, 0x5f, 0x16,
-	0xf2, 0x7b, 0xda, 0xe0, 0xe2, 0x4f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x9e, 0xcd, 0xe3, 0xfd, 0x38,
-	0x03, 0x00, 0x00,
+	0x10, 0xf5, 0x26, 0x4a, 0xe2, 0xac, 0x2c, 0xe7, 0x97, 0xe5, 0x47, 0x58, 0x0c, 0xb5, 0x85, 0x69,
+	0x41, 0x14, 0x2a, 0xb7, 0x0e, 0xbd, 0xb4, 0x37, 0x53, 0x02, 0x81, 0xb6, 0x14, 0x39, 0xa4, 0x97,
+	0x82, 0xd0, 0x9f, 0xb1, 0xbd, 0x58, 0xda, 0x75, 0xb5, 0x6b, 0x1b, 0xf5, 0x93, 0xf4, 0x98, 0x8f,
+	0xe3, 0x63, 0xcf, 0x3d, 0x84, 0xd6, 0x3d, 0xf5, 0x5b, 0x14, 0xad, 0x64, 0x47, 0xc7, 0xf6, 0x36,
+	0xf3, 0xf6, 0xbd, 0x99, 0x37, 0x3b, 0x83, 0x2d, 0xa9, 0xb2, 0x65, 0xa4, 0xa4, 0xbb, 0xc8, 0x84,
+	0x12, 0xe4, 0x20, 0x0e, 0x3b, 0xcf, 0xa6, 0x4c, 0xcd, 0x96, 0xa1, 0x1b, 0x89, 0x74, 0x30, 0x15,
+	0x53, 0x31, 0xd0, 0x4f, 0xe1, 0x72, 0xa2, 0x33, 0x9d, 0xe8, 0xa8, 0x94, 0x74, 0x5e, 0xd6, 0xe8,
+	0x32, 0xe7, 0x91, 0x9a, 0x31, 0x3e, 0xad, 0x45, 0x09, 0x0b, 0xcb, 0x0a, 0x91, 0x48, 0x06, 0x21,
+	0x2c, 0x4a, 0x59, 0xff, 0x23, 0x36, 0xaf, 0x58, 0x02, 0xb7, 0x90, 0x49, 0x26, 0x38, 0x79, 0x8e,
+	0x4f, 0x56, 0x65, 0x48, 0x91, 0x8d, 0x1c, 0x73, 0xf8, 0x9f, 0xbb, 0x13, 0xb9, 0xb7, 0x10, 0x29,
+	0x91, 0x8d, 0x8c, 0xcd, 0x7d, 0xaf, 0xe1, 0xed, 0x68, 0xe4, 0x02, 0x1f, 0xc7, 0xb0, 0x62, 0x11,
+	0xd0, 0x03, 0x1b, 0x39, 0x2d, 0xaf, 0xca, 0xfa, 0x57, 0xd8, 0xac, 0x8a, 0xbe, 0x65, 0x52, 0x91,
+	0x17, 0xb8, 0x59, 0x29, 0x24, 0x45, 0xf6, 0xa1, 0x63, 0x0e, 0xcf, 0xdc, 0x38, 0x74, 0x6b, 0xbd,
+	0xab, 0xc2, 0x7b, 0xda, 0x2b, 0xe3, 0xeb, 0x5d, 0xaf, 0xd1, 0xff, 0x6d, 0xe0, 0xf3, 0x82, 0x75,
+	0xcd, 0x27, 0xe2, 0x26, 0x5b, 0xf2, 0x28, 0x50, 0x10, 0x13, 0x82, 0x0d, 0x1e, 0xa4, 0xa0, 0x4d,
+	0x9e, 0x7a, 0x3a, 0x26, 0x4f, 0xb1, 0xa1, 0xf2, 0x45, 0xe9, 0xa3, 0x3d, 0xbc, 0x78, 0x30, 0xbe,
+	0x97, 0xe7, 0x0b, 0xf0, 0x34, 0xa7, 0xd0, 0x4b, 0xf6, 0x05, 0xe8, 0xa1, 0x8d, 0x9c, 0x43, 0x4f,
+	0xc7, 0xc4, 0xc6, 0xe6, 0x02, 0xb2, 0x94, 0xc9, 0xd2, 0xa5, 0x61, 0x23, 0xc7, 0xf2, 0xea, 0x10,
+	0x79, 0x84, 0x71, 0x2a, 0x62, 0x36, 0x61, 0x10, 0xfb, 0x92, 0x1e, 0x69, 0xed, 0xe9, 0x0e, 0x19,
+	0x13, 0x8a, 0x4f, 0x62, 0x48, 0x40, 0x41, 0x4c, 0x8f, 0x6d, 0xe4, 0x34, 0xbd, 0x5d, 0x5a, 0xbc,
+	0x30, 0xbe, 0x0a, 0x12, 0x16, 0xd3, 0x93, 0xf2, 0xa5, 0x4a, 0xc9, 0x13, 0xdc, 0xe6, 0xc2, 0xaf,
+	0xf7, 0x6d, 0x6a, 0x82, 0xc5, 0xc5, 0x87, 0x5a, 0xe7, 0xda, 0x5e, 0x4e, 0xff, 0x6e, 0x2f, 0x1d,
+	0xdc, 0x94, 0xf0, 0x79, 0x09, 0x3c, 0x02, 0x8a, 0xb5, 0xd3, 0x7d, 0x4e, 0x7a, 0xd8, 0xdc, 0xcf,
+	0xc1, 0x25, 0x35, 0x6d, 0xe4, 0x1c, 0x79, 0xfb, 0xd1, 0xde, 0x4b, 0xf2, 0xa9, 0x46, 0x08, 0x73,
+	0xda, 0xb2, 0x91, 0x63, 0x8c, 0x5e, 0x17, 0x0d, 0xbe, 0xdf, 0xf7, 0x2e, 0xff, 0xe1, 0xd2, 0xdc,
+	0xf1, 0x4c, 0x64, 0xea, 0xfa, 0xcd, 0x43, 0xf5, 0x51, 0x4e, 0x1e, 0xe3, 0x76, 0x16, 0xac, 0xfd,
+	0x30, 0x11, 0xd1, 0xdc, 0xd7, 0x6b, 0xb0, 0xb4, 0x83, 0x56, 0x16, 0xac, 0x47, 0x05, 0x38, 0xde,
+	0xad, 0x23, 0x09, 0x22, 0x98, 0x89, 0x24, 0x86, 0x8c, 0xb6, 0xf5, 0xb7, 0xd4, 0x21, 0xd2, 0xc7,
+	0x56, 0x54, 0xd6, 0x98, 0xc3, 0xda, 0x4f, 0x25, 0x3d, 0xd3, 0x73, 0x9a, 0x1a, 0x1c, 0xcf, 0x61,
+	0xfd, 0x4e, 0x16, 0xff, 0x2b, 0xf3, 0x34, 0x61, 0x7c, 0xee, 0xab, 0x20, 0x9b, 0x82, 0xa2, 0xe7,
+	0xfa, 0x64, 0xac, 0x0a, 0xbd, 0xd1, 0x60, 0x79, 0x6b, 0xa3, 0xff, 0x37, 0x3f, 0xbb, 0x8d, 0xcd,
+	0xb6, 0x8b, 0xbe, 0x6d, 0xbb, 0xe8, 0xc7, 0xb6, 0xdb, 0xb8, 0xfb, 0xd5, 0x45, 0xe1, 0xb1, 0x1e,
+	0xe6, 0xf2, 0xcf, 0x00, 0xa0, 0x47, 0x6b, 0x16, 0xa4, 0x03, 0x00, 0x00,
 }
@@ -15,7 +15,9 @@ import (
 	"github.com/syncthing/syncthing/lib/sync"
 )
 
-type EventType int
+// An EventType is one of the event types below, or a mask of several. There
+// are more than 32 of them, so it's 64 bits wide on all platforms.
+type EventType uint64
 
 const (
 	Starting EventType = 1 << iota
@@ -45,8 +47,19 @@ const (
 	FolderResumed
 	ListenAddressesChanged
 	LoginAttempt
-
-	AllEvents = (1 << iota) - 1
+	DeviceClockSkew
+	SecurityAlert
+	ConflictCreated
+	FolderAutoPaused
+	FolderAutoResumed
+	QuotaExceeded
+	DeviceAutoPaused
+	DeviceAutoResumed
+	APITokenUsed
+	ManagementCommand
+	WriteOnceViolation
+
+	AllEvents EventType = (1 << iota) - 1
 )
 
 var runningTests = false
@@ -109,6 +122,28 @@ func (t EventType) String() string {
 		return "ListenAddressesChanged"
 	case LoginAttempt:
 		return "LoginAttempt"
+	case DeviceClockSkew:
+		return "DeviceClockSkew"
+	case SecurityAlert:
+		return "SecurityAlert"
+	case ConflictCreated:
+		return "ConflictCreated"
+	case FolderAutoPaused:
+		return "FolderAutoPaused"
+	case FolderAutoResumed:
+		return "FolderAutoResumed"
+	case QuotaExceeded:
+		return "QuotaExceeded"
+	case DeviceAutoPaused:
+		return "DeviceAutoPaused"
+	case DeviceAutoResumed:
+		return "DeviceAutoResumed"
+	case APITokenUsed:
+		return "APITokenUsed"
+	case ManagementCommand:
+		return "ManagementCommand"
+	case WriteOnceViolation:
+		return "WriteOnceViolation"
 	default:
 		return "Unknown"
 	}
@@ -118,6 +153,22 @@ func (t EventType) MarshalText() ([]byte, error) {
 	return []byte(t.String()), nil
 }
 
+func (t *EventType) UnmarshalText(bs []byte) error {
+	*t = UnmarshalEventType(string(bs))
+	return nil
+}
+
+// UnmarshalEventType returns the event type with the given name, or zero
+// if there is none.
+func UnmarshalEventType(s string) EventType {
+	for t := EventType(1); t&AllEvents != 0; t <<= 1 {
+		if t.String() == s {
+			return t
+		}
+	}
+	return 0
+}
+
 const BufferSize = 64
 
 type Logger struct {
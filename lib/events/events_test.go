@@ -321,3 +321,14 @@ func TestSinceUsesSubscriptionId(t *testing.T) {
 		t.Fatal("Incorrect number of events:", len(events))
 	}
 }
+
+func TestUnmarshalEventType(t *testing.T) {
+	for _, et := range []EventType{Starting, ItemFinished, DeviceAutoResumed} {
+		if res := UnmarshalEventType(et.String()); res != et {
+			t.Errorf("UnmarshalEventType(%q) = %v, expected %v", et.String(), res, et)
+		}
+	}
+	if res := UnmarshalEventType("Unknown"); res != 0 {
+		t.Errorf("UnmarshalEventType(Unknown) = %v, expected zero", res)
+	}
+}
@@ -12,6 +12,8 @@ import (
 )
 
 // The BasicFilesystem implements all aspects by delegating to package os.
+// On Windows, absolute paths are passed on in the long form, so that deep
+// trees work regardless of the 260 character path limit.
 type BasicFilesystem struct {
 }
 
@@ -20,19 +22,19 @@ func NewBasicFilesystem() *BasicFilesystem {
 }
 
 func (f *BasicFilesystem) Chmod(name string, mode FileMode) error {
-	return os.Chmod(name, os.FileMode(mode))
+	return os.Chmod(LongFilename(name), os.FileMode(mode))
 }
 
 func (f *BasicFilesystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
-	return os.Chtimes(name, atime, mtime)
+	return os.Chtimes(LongFilename(name), atime, mtime)
 }
 
 func (f *BasicFilesystem) Mkdir(name string, perm FileMode) error {
-	return os.Mkdir(name, os.FileMode(perm))
+	return os.Mkdir(LongFilename(name), os.FileMode(perm))
 }
 
 func (f *BasicFilesystem) Lstat(name string) (FileInfo, error) {
-	fi, err := os.Lstat(name)
+	fi, err := os.Lstat(LongFilename(name))
 	if err != nil {
 		return nil, err
 	}
@@ -40,15 +42,15 @@ func (f *BasicFilesystem) Lstat(name string) (FileInfo, error) {
 }
 
 func (f *BasicFilesystem) Remove(name string) error {
-	return os.Remove(name)
+	return os.Remove(LongFilename(name))
 }
 
 func (f *BasicFilesystem) Rename(oldpath, newpath string) error {
-	return os.Rename(oldpath, newpath)
+	return os.Rename(LongFilename(oldpath), LongFilename(newpath))
 }
 
 func (f *BasicFilesystem) Stat(name string) (FileInfo, error) {
-	fi, err := os.Stat(name)
+	fi, err := os.Stat(LongFilename(name))
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +58,7 @@ func (f *BasicFilesystem) Stat(name string) (FileInfo, error) {
 }
 
 func (f *BasicFilesystem) DirNames(name string) ([]string, error) {
-	fd, err := os.OpenFile(name, os.O_RDONLY, 0777)
+	fd, err := os.OpenFile(LongFilename(name), os.O_RDONLY, 0777)
 	if err != nil {
 		return nil, err
 	}
@@ -71,11 +73,11 @@ func (f *BasicFilesystem) DirNames(name string) ([]string, error) {
 }
 
 func (f *BasicFilesystem) Open(name string) (File, error) {
-	return os.Open(name)
+	return os.Open(LongFilename(name))
 }
 
 func (f *BasicFilesystem) Create(name string) (File, error) {
-	return os.Create(name)
+	return os.Create(LongFilename(name))
 }
 
 // fsFileInfo implements the fs.FileInfo interface on top of an os.FileInfo.
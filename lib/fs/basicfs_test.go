@@ -0,0 +1,78 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBasicFilesystemLongPaths(t *testing.T) {
+	os.RemoveAll("testdata")
+	defer os.RemoveAll("testdata")
+
+	root, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Build a tree well past the 260 character limit of Windows, out of
+	// directory names that are each within the limits.
+	fs := NewBasicFilesystem()
+	dir := root
+	if err := fs.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	component := strings.Repeat("x", 50)
+	for i := 0; i < 8; i++ {
+		dir = filepath.Join(dir, component)
+		if err := fs.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := filepath.Join(dir, "file")
+	if len(file) < 400 {
+		t.Fatal("test path too short:", len(file))
+	}
+
+	fd, err := fs.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+
+	if info, err := fs.Stat(file); err != nil || !info.IsRegular() {
+		t.Fatal("stat of long path:", info, err)
+	}
+	renamed := file + "-renamed"
+	if err := fs.Rename(file, renamed); err != nil {
+		t.Fatal(err)
+	}
+
+	var found []string
+	err = fs.Walk(root, func(path string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsRegular() {
+			found = append(found, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0] != renamed {
+		t.Errorf("walk found %v, expected only %q", found, renamed)
+	}
+
+	if err := fs.Remove(renamed); err != nil {
+		t.Fatal(err)
+	}
+}
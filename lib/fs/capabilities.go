@@ -0,0 +1,65 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The size of the hole created when probing for sparse file support.
+const probeSparseSize = 1 << 20
+
+// Capabilities describes the features a filesystem supports, as found by
+// probing it.
+type Capabilities struct {
+	Symlinks      bool `json:"symlinks"`
+	CaseSensitive bool `json:"caseSensitive"`
+	Xattrs        bool `json:"xattrs"`
+	SparseFiles   bool `json:"sparseFiles"`
+}
+
+// ProbeCapabilities finds out what the filesystem holding dir supports, by
+// creating and removing a few files in dir, named after base. That should
+// be a name that isn't synced, in case they are left behind. An error
+// is returned if that isn't possible, most likely because dir is not
+// writable.
+func ProbeCapabilities(dir, base string) (Capabilities, error) {
+	var caps Capabilities
+
+	name := filepath.Join(dir, base)
+	fd, err := os.Create(name)
+	if err != nil {
+		return caps, err
+	}
+	defer os.Remove(name)
+
+	// A file extended by truncation has a hole where nothing was written,
+	// which takes no space if sparse files are supported.
+	if err := fd.Truncate(probeSparseSize); err == nil {
+		if info, err := fd.Stat(); err == nil {
+			caps.SparseFiles = isSparse(info)
+		}
+	}
+	fd.Close()
+
+	link := name + ".link"
+	if err := DefaultFilesystem.CreateSymlink(link, base); err == nil {
+		caps.Symlinks = true
+		os.Remove(link)
+	}
+
+	upper := filepath.Join(dir, strings.ToUpper(base))
+	if _, err := os.Lstat(upper); os.IsNotExist(err) {
+		caps.CaseSensitive = true
+	}
+
+	caps.Xattrs = xattrsSupported(name)
+
+	return caps, nil
+}
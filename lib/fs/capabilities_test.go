@@ -0,0 +1,37 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestProbeCapabilities(t *testing.T) {
+	os.RemoveAll("testdata")
+	defer os.RemoveAll("testdata")
+	os.Mkdir("testdata", 0755)
+
+	caps, err := ProbeCapabilities("testdata", ".syncthing.probe.tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS == "linux" && (!caps.Symlinks || !caps.CaseSensitive) {
+		t.Errorf("expected symlinks and case sensitivity on Linux, got %+v", caps)
+	}
+
+	// The probe should clean up after itself
+	if fis, _ := ioutil.ReadDir("testdata"); len(fis) != 0 {
+		t.Errorf("probe left %d files behind", len(fis))
+	}
+
+	if _, err := ProbeCapabilities("testdata/nonexistent", ".syncthing.probe.tmp"); err == nil {
+		t.Error("expected probing a nonexistent directory to fail")
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build windows
+
+package fs
+
+import "os"
+
+func isSparse(info os.FileInfo) bool {
+	// Files are only sparse on NTFS when explicitly marked as such, which
+	// we don't do.
+	return false
+}
@@ -0,0 +1,21 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build linux freebsd
+
+package fs
+
+import "golang.org/x/sys/unix"
+
+const probeXattr = "user.syncthing.probe"
+
+func xattrsSupported(name string) bool {
+	if err := unix.Setxattr(name, probeXattr, []byte{1}, 0); err != nil {
+		return false
+	}
+	unix.Removexattr(name, probeXattr)
+	return true
+}
@@ -0,0 +1,39 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import "errors"
+
+var (
+	// ErrJournalUnsupported is returned by OpenChangeJournal when the
+	// filesystem keeps no change journal we can read.
+	ErrJournalUnsupported = errors.New("change journal not supported")
+	// ErrJournalReset is returned by Changes when some of the changes since
+	// the cursor may be missing from the journal, as it has been recreated
+	// or has wrapped around since.
+	ErrJournalReset = errors.New("change journal reset")
+)
+
+// A ChangeJournal lists the files and directories changed under a
+// directory, as recorded by the filesystem itself. That's much quicker
+// than walking a large tree to find them.
+type ChangeJournal interface {
+	// Cursor returns the current position in the journal.
+	Cursor() (JournalCursor, error)
+	// Changes returns the paths changed since the cursor, relative to the
+	// directory the journal was opened for, and the cursor to pass the next
+	// time. The paths may no longer exist, and may be listed more than
+	// once.
+	Changes(since JournalCursor) ([]string, JournalCursor, error)
+	Close() error
+}
+
+// A JournalCursor is a position in a change journal.
+type JournalCursor struct {
+	ID       uint64 // identifies the journal, which changes when it's recreated
+	Position int64
+}
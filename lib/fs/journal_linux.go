@@ -0,0 +1,326 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build linux
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/syncthing/syncthing/lib/rand"
+	"github.com/syncthing/syncthing/lib/sync"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	fanClassNotif     = 0x0
+	fanCloexec        = 0x1
+	fanNonblock       = 0x2
+	fanReportDirFID   = 0x400
+	fanReportName     = 0x800
+	fanMarkAdd        = 0x1
+	fanMarkFilesystem = 0x100
+
+	fanModify     = 0x2
+	fanAttrib     = 0x4
+	fanCloseWrite = 0x8
+	fanMovedFrom  = 0x40
+	fanMovedTo    = 0x80
+	fanCreate     = 0x100
+	fanDelete     = 0x200
+	fanQOverflow  = 0x4000
+	fanOndir      = 0x40000000
+
+	fanEventInfoTypeDFIDName = 2
+	fanotifyMetadataVersion  = 3
+
+	fanotifyEvents     = fanModify | fanAttrib | fanCloseWrite | fanMovedFrom | fanMovedTo | fanCreate | fanDelete | fanOndir
+	fanotifyBufferSize = 64 << 10
+
+	// Above this many changes kept for the next call to Changes, the
+	// journal is reset rather than grow without bound.
+	maxFanotifyChanges = 1 << 20
+)
+
+// A variable, as the negative constant can't be converted to a uintptr.
+var atFDCWD = unix.AT_FDCWD
+
+// The offsets of the fields we use in a fanotify_event_metadata, and in
+// the fanotify_event_info_fid records following it.
+const (
+	fanMetaEventLen    = 0
+	fanMetaVersion     = 4
+	fanMetaMetadataLen = 6
+	fanMetaMask        = 8
+	fanMetaMinLength   = 24
+
+	fanInfoType      = 0
+	fanInfoLen       = 2
+	fanInfoHandle    = 12 // after the header and the filesystem ID
+	fanHandleBytes   = 0
+	fanHandleHeader  = 8
+	fanInfoMinLength = fanInfoHandle + fanHandleHeader
+)
+
+// The fanotifyJournal keeps the changes under a directory, as reported by
+// fanotify for the whole filesystem holding it, so without watching each
+// directory in the tree. Changes are only known from when the journal is
+// opened; each journal gets a new ID so that cursors from before then
+// lead to a walk. Marking a whole filesystem requires CAP_SYS_ADMIN,
+// finding the paths of the changes CAP_DAC_READ_SEARCH, and both Linux
+// 5.9 or later.
+type fanotifyJournal struct {
+	group *os.File // the fanotify group, read in the background
+	mount int      // a descriptor on the filesystem, to open handles by
+	root  string   // the directory, with symlinks evaluated
+
+	mut     sync.Mutex
+	id      uint64
+	base    int64    // the position of changes[0]
+	changes []string // relative to root
+	err     error    // reading failed, and no more changes are known
+}
+
+// OpenChangeJournal opens the change journal of the filesystem holding dir,
+// for changes under dir. On Linux that's a journal kept from fanotify
+// events, and requires the capabilities to mark the filesystem.
+func OpenChangeJournal(dir string) (ChangeJournal, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, _, errno := unix.Syscall(unix.SYS_FANOTIFY_INIT, fanClassNotif|fanCloexec|fanNonblock|fanReportDirFID|fanReportName, unix.O_RDONLY|unix.O_LARGEFILE, 0)
+	if errno != 0 {
+		// Too old a kernel, or not allowed to.
+		return nil, ErrJournalUnsupported
+	}
+	if err := fanotifyMark(int(fd), root); err != nil {
+		unix.Close(int(fd))
+		return nil, ErrJournalUnsupported
+	}
+	mount, err := unix.Open(root, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		unix.Close(int(fd))
+		return nil, err
+	}
+
+	j := &fanotifyJournal{
+		// The group is non blocking, so reads go through the runtime's
+		// poller, and closing it ends the read in progress.
+		group: os.NewFile(fd, "fanotify"),
+		mount: mount,
+		root:  root,
+		mut:   sync.NewMutex(),
+		id:    uint64(rand.Int63()),
+	}
+	if _, err := j.openHandle(root); err != nil {
+		// Paths can't be found from the events.
+		j.group.Close()
+		unix.Close(mount)
+		return nil, ErrJournalUnsupported
+	}
+	go j.read()
+	return j, nil
+}
+
+func fanotifyMark(fd int, path string) error {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if unsafe.Sizeof(uintptr(0)) == 4 {
+		// The 64 bit mask is passed as two words on 32 bit platforms.
+		_, _, errno = unix.Syscall6(unix.SYS_FANOTIFY_MARK, uintptr(fd), fanMarkAdd|fanMarkFilesystem, uintptr(uint32(fanotifyEvents)), 0, uintptr(atFDCWD), uintptr(unsafe.Pointer(p)))
+	} else {
+		_, _, errno = unix.Syscall6(unix.SYS_FANOTIFY_MARK, uintptr(fd), fanMarkAdd|fanMarkFilesystem, fanotifyEvents, uintptr(atFDCWD), uintptr(unsafe.Pointer(p)), 0)
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (j *fanotifyJournal) Cursor() (JournalCursor, error) {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+	if j.err != nil {
+		return JournalCursor{}, j.err
+	}
+	return JournalCursor{ID: j.id, Position: j.base + int64(len(j.changes))}, nil
+}
+
+// Changes returns the changes since the cursor, and forgets those before
+// it, as there's just the one reader.
+func (j *fanotifyJournal) Changes(since JournalCursor) ([]string, JournalCursor, error) {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+	if j.err != nil {
+		return nil, since, j.err
+	}
+	end := j.base + int64(len(j.changes))
+	if since.ID != j.id || since.Position < j.base || since.Position > end {
+		return nil, since, ErrJournalReset
+	}
+
+	j.changes = j.changes[since.Position-j.base:]
+	j.base = since.Position
+
+	seen := make(map[string]struct{}, len(j.changes))
+	var changes []string
+	for _, change := range j.changes {
+		if _, ok := seen[change]; !ok {
+			seen[change] = struct{}{}
+			changes = append(changes, change)
+		}
+	}
+	return changes, JournalCursor{ID: j.id, Position: end}, nil
+}
+
+func (j *fanotifyJournal) Close() error {
+	err := j.group.Close()
+	unix.Close(j.mount)
+	return err
+}
+
+// read reads the events until the group is closed, keeping the paths
+// under the root. When events have been lost the journal is reset.
+func (j *fanotifyJournal) read() {
+	buf := make([]byte, fanotifyBufferSize)
+	for {
+		n, err := j.group.Read(buf)
+		if err != nil {
+			j.mut.Lock()
+			j.err = err
+			j.changes = nil
+			j.mut.Unlock()
+			return
+		}
+
+		var changes []string
+		reset := false
+		for evs := buf[:n]; len(evs) >= fanMetaMinLength; {
+			length := int(*(*uint32)(unsafe.Pointer(&evs[fanMetaEventLen])))
+			if length < fanMetaMinLength || length > len(evs) {
+				break
+			}
+			if rel, ok, overflow := j.eventPath(evs[:length]); overflow {
+				reset = true
+			} else if ok {
+				changes = append(changes, rel)
+			}
+			evs = evs[length:]
+		}
+
+		j.mut.Lock()
+		if reset || len(j.changes)+len(changes) > maxFanotifyChanges {
+			j.id++
+			j.base += int64(len(j.changes))
+			j.changes = nil
+		} else {
+			j.changes = append(j.changes, changes...)
+		}
+		j.mut.Unlock()
+	}
+}
+
+// eventPath returns the path of the file in the event relative to the root,
+// if it's under the root, and whether the event is that events were lost.
+// Events name the parent directory by its handle and the file by its name,
+// so the directory is opened by its handle to find its path. A directory
+// that has since been removed can't be opened; its own removal is then an
+// event as well.
+func (j *fanotifyJournal) eventPath(ev []byte) (rel string, ok bool, overflow bool) {
+	if ev[fanMetaVersion] != fanotifyMetadataVersion {
+		return "", false, false
+	}
+	mask := *(*uint64)(unsafe.Pointer(&ev[fanMetaMask]))
+	if mask&fanQOverflow != 0 {
+		return "", false, true
+	}
+
+	info := ev[*(*uint16)(unsafe.Pointer(&ev[fanMetaMetadataLen])):]
+	for len(info) >= fanInfoMinLength {
+		length := int(*(*uint16)(unsafe.Pointer(&info[fanInfoLen])))
+		if length < fanInfoMinLength || length > len(info) {
+			return "", false, false
+		}
+		if info[fanInfoType] != fanEventInfoTypeDFIDName {
+			info = info[length:]
+			continue
+		}
+
+		handle := info[fanInfoHandle:length]
+		nameStart := fanHandleHeader + int(*(*uint32)(unsafe.Pointer(&handle[fanHandleBytes])))
+		if nameStart >= len(handle) {
+			return "", false, false
+		}
+		name := handle[nameStart:]
+		if i := strings.IndexByte(string(name), 0); i >= 0 {
+			name = name[:i]
+		}
+
+		dir, err := j.handlePath(handle[:nameStart])
+		if err != nil {
+			return "", false, false
+		}
+		return j.relative(filepath.Join(dir, string(name)))
+	}
+	return "", false, false
+}
+
+// handlePath returns the current path of the directory with the handle.
+func (j *fanotifyJournal) handlePath(handle []byte) (string, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_OPEN_BY_HANDLE_AT, uintptr(j.mount), uintptr(unsafe.Pointer(&handle[0])), unix.O_RDONLY|unix.O_PATH|unix.O_CLOEXEC)
+	if errno != 0 {
+		return "", errno
+	}
+	defer unix.Close(int(fd))
+	path, err := os.Readlink("/proc/self/fd/" + strconv.Itoa(int(fd)))
+	if err != nil {
+		return "", err
+	}
+	if strings.HasSuffix(path, " (deleted)") {
+		return "", os.ErrNotExist
+	}
+	return path, nil
+}
+
+// openHandle checks that a directory can be opened by its handle, as the
+// paths of the events are found that way.
+func (j *fanotifyJournal) openHandle(dir string) (string, error) {
+	// struct file_handle, with room for the largest handle
+	handle := make([]byte, fanHandleHeader+128)
+	*(*uint32)(unsafe.Pointer(&handle[fanHandleBytes])) = 128
+	p, err := unix.BytePtrFromString(dir)
+	if err != nil {
+		return "", err
+	}
+	var mountID int32
+	_, _, errno := unix.Syscall6(unix.SYS_NAME_TO_HANDLE_AT, uintptr(atFDCWD), uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&handle[0])), uintptr(unsafe.Pointer(&mountID)), 0, 0)
+	if errno != 0 {
+		return "", errno
+	}
+	return j.handlePath(handle)
+}
+
+func (j *fanotifyJournal) relative(path string) (string, bool, bool) {
+	if path == j.root || !strings.HasPrefix(path, j.root+string(os.PathSeparator)) {
+		return "", false, false
+	}
+	return path[len(j.root)+1:], true, false
+}
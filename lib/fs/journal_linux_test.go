@@ -0,0 +1,76 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build linux
+
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFanotifyJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "root", "old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "root")
+
+	j, err := OpenChangeJournal(root)
+	if err == ErrJournalUnsupported {
+		t.Skip("fanotify not available:", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	cursor, err := j.Cursor()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "outside"), []byte("x"), 0644)
+	os.Mkdir(filepath.Join(root, "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "sub", "file"), []byte("x"), 0644)
+	os.Rename(filepath.Join(root, "old"), filepath.Join(root, "new"))
+
+	// The events arrive in the background.
+	expected := []string{"new", "old", "sub", filepath.Join("sub", "file")}
+	var changes []string
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		changes, _, err = j.Changes(cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) >= len(expected) {
+			break
+		}
+	}
+	sort.Strings(changes)
+	if len(changes) != len(expected) {
+		t.Fatalf("Unexpected changes %q", changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Fatalf("Unexpected changes %q", changes)
+		}
+	}
+
+	// A cursor from another journal, as from before a restart, needs a
+	// walk.
+	if _, _, err := j.Changes(JournalCursor{ID: cursor.ID + 1}); err != ErrJournalReset {
+		t.Errorf("Expected ErrJournalReset, got %v", err)
+	}
+}
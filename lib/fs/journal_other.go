@@ -0,0 +1,15 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build !windows,!linux
+
+package fs
+
+// OpenChangeJournal opens the change journal of the filesystem holding dir,
+// for changes under dir. There's none on this platform.
+func OpenChangeJournal(dir string) (ChangeJournal, error) {
+	return nil, ErrJournalUnsupported
+}
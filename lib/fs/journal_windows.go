@@ -0,0 +1,289 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build windows
+
+package fs
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+const (
+	fsctlQueryUSNJournal = 0x000900f4
+	fsctlReadUSNJournal  = 0x000900bb
+
+	errorJournalEntryDeleted syscall.Errno = 1181
+
+	fileReadAttributes = 0x80
+	maxLongPath        = 32768
+	usnBufferSize      = 64 << 10
+)
+
+// The offsets of the fields we use in a USN_RECORD_V2.
+const (
+	usnRecordMajorVersion = 4
+	usnRecordParent       = 16
+	usnRecordNameLength   = 56
+	usnRecordNameOffset   = 58
+	usnRecordMinLength    = 60
+)
+
+var (
+	kernel32                              = syscall.NewLazyDLL("kernel32.dll")
+	procOpenFileByID                      = kernel32.NewProc("OpenFileById")
+	procGetFinalPathNameByHandleW         = kernel32.NewProc("GetFinalPathNameByHandleW")
+	procGetVolumePathNameW                = kernel32.NewProc("GetVolumePathNameW")
+	procGetVolumeNameForVolumeMountPointW = kernel32.NewProc("GetVolumeNameForVolumeMountPointW")
+)
+
+// usnJournalData is a USN_JOURNAL_DATA_V0.
+type usnJournalData struct {
+	UsnJournalID    uint64
+	FirstUsn        int64
+	NextUsn         int64
+	LowestValidUsn  int64
+	MaxUsn          int64
+	MaximumSize     uint64
+	AllocationDelta uint64
+}
+
+// readUSNJournalData is a READ_USN_JOURNAL_DATA_V0, which makes the
+// journal return USN_RECORD_V2 records.
+type readUSNJournalData struct {
+	StartUsn          int64
+	ReasonMask        uint32
+	ReturnOnlyOnClose uint32
+	Timeout           uint64
+	BytesToWaitFor    uint64
+	UsnJournalID      uint64
+}
+
+// fileIDDescriptor is a FILE_ID_DESCRIPTOR of the FileIdType.
+type fileIDDescriptor struct {
+	Size   uint32
+	Type   uint32
+	FileID uint64
+	_      [8]byte // the rest of the union with the 128 bit IDs
+}
+
+// The usnJournal reads the NTFS change journal, the USN journal, of the
+// volume holding a directory. Reading it requires administrator rights.
+type usnJournal struct {
+	volume syscall.Handle
+	root   string // the final path of the directory, in the \\?\ form
+}
+
+// OpenChangeJournal opens the change journal of the filesystem holding dir,
+// for changes under dir. On Windows that's the NTFS change journal.
+func OpenChangeJournal(dir string) (ChangeJournal, error) {
+	root, err := finalPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	volumeName, err := volumeName(root)
+	if err != nil {
+		return nil, err
+	}
+	volume, err := syscall.CreateFile(syscall.StringToUTF16Ptr(volumeName), syscall.GENERIC_READ, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE, nil, syscall.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &usnJournal{
+		volume: volume,
+		root:   root,
+	}
+	if _, err := j.query(); err != nil {
+		// Most likely not NTFS, or a volume without an active journal.
+		syscall.CloseHandle(volume)
+		return nil, ErrJournalUnsupported
+	}
+	return j, nil
+}
+
+func (j *usnJournal) Cursor() (JournalCursor, error) {
+	data, err := j.query()
+	if err != nil {
+		return JournalCursor{}, err
+	}
+	return JournalCursor{ID: data.UsnJournalID, Position: data.NextUsn}, nil
+}
+
+func (j *usnJournal) Changes(since JournalCursor) ([]string, JournalCursor, error) {
+	data, err := j.query()
+	if err != nil {
+		return nil, since, err
+	}
+	if data.UsnJournalID != since.ID || since.Position < data.FirstUsn || since.Position < data.LowestValidUsn {
+		return nil, since, ErrJournalReset
+	}
+
+	read := readUSNJournalData{
+		StartUsn:     since.Position,
+		ReasonMask:   0xffffffff,
+		UsnJournalID: data.UsnJournalID,
+	}
+	buf := make([]byte, usnBufferSize)
+	dirs := make(map[uint64]string) // the paths of the parent directories, by file reference number
+	seen := make(map[string]struct{})
+	var changes []string
+
+	for read.StartUsn < data.NextUsn {
+		var n uint32
+		err := syscall.DeviceIoControl(j.volume, fsctlReadUSNJournal, (*byte)(unsafe.Pointer(&read)), uint32(unsafe.Sizeof(read)), &buf[0], uint32(len(buf)), &n, nil)
+		if err == errorJournalEntryDeleted {
+			return nil, since, ErrJournalReset
+		} else if err != nil {
+			return nil, since, err
+		}
+		if n < 8 {
+			break
+		}
+
+		// The buffer starts with the position following the records in it.
+		next := int64(binary.LittleEndian.Uint64(buf))
+		for recs := buf[8:n]; len(recs) >= usnRecordMinLength; {
+			length := int(binary.LittleEndian.Uint32(recs))
+			if length < usnRecordMinLength || length > len(recs) {
+				break
+			}
+			if rel, ok := j.recordPath(recs[:length], dirs); ok {
+				if _, ok := seen[rel]; !ok {
+					seen[rel] = struct{}{}
+					changes = append(changes, rel)
+				}
+			}
+			recs = recs[length:]
+		}
+
+		if next <= read.StartUsn {
+			break
+		}
+		read.StartUsn = next
+	}
+
+	return changes, JournalCursor{ID: data.UsnJournalID, Position: read.StartUsn}, nil
+}
+
+func (j *usnJournal) Close() error {
+	return syscall.CloseHandle(j.volume)
+}
+
+func (j *usnJournal) query() (usnJournalData, error) {
+	var data usnJournalData
+	var n uint32
+	err := syscall.DeviceIoControl(j.volume, fsctlQueryUSNJournal, nil, 0, (*byte)(unsafe.Pointer(&data)), uint32(unsafe.Sizeof(data)), &n, nil)
+	return data, err
+}
+
+// recordPath returns the path of the file in the record relative to the
+// root, if it's under the root. Records only name the parent directory by
+// its file reference number, so it's looked up, and remembered in dirs. A
+// parent that has since been deleted can't be looked up; its own deletion
+// is then in the journal as well.
+func (j *usnJournal) recordPath(rec []byte, dirs map[uint64]string) (string, bool) {
+	if binary.LittleEndian.Uint16(rec[usnRecordMajorVersion:]) != 2 {
+		return "", false
+	}
+
+	parent := binary.LittleEndian.Uint64(rec[usnRecordParent:])
+	dir, ok := dirs[parent]
+	if !ok {
+		dir, _ = j.pathByID(parent)
+		dirs[parent] = dir
+	}
+	if dir == "" {
+		return "", false
+	}
+
+	nameLen := int(binary.LittleEndian.Uint16(rec[usnRecordNameLength:]))
+	nameOff := int(binary.LittleEndian.Uint16(rec[usnRecordNameOffset:]))
+	if nameOff+nameLen > len(rec) {
+		return "", false
+	}
+	name := make([]uint16, nameLen/2)
+	for i := range name {
+		name[i] = binary.LittleEndian.Uint16(rec[nameOff+2*i:])
+	}
+
+	return j.relative(withSeparator(dir) + string(utf16.Decode(name)))
+}
+
+// relative returns the path relative to the root, if it's under it.
+func (j *usnJournal) relative(path string) (string, bool) {
+	prefix := withSeparator(j.root)
+	if len(path) <= len(prefix) || !strings.EqualFold(path[:len(prefix)], prefix) {
+		return "", false
+	}
+	return path[len(prefix):], true
+}
+
+// pathByID returns the final path of the file with the given file
+// reference number.
+func (j *usnJournal) pathByID(id uint64) (string, error) {
+	desc := fileIDDescriptor{FileID: id}
+	desc.Size = uint32(unsafe.Sizeof(desc))
+	r, _, err := procOpenFileByID.Call(uintptr(j.volume), uintptr(unsafe.Pointer(&desc)), fileReadAttributes, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE, 0, syscall.FILE_FLAG_BACKUP_SEMANTICS)
+	h := syscall.Handle(r)
+	if h == syscall.InvalidHandle {
+		return "", err
+	}
+	defer syscall.CloseHandle(h)
+	return finalPathByHandle(h)
+}
+
+// finalPath returns the path of the directory with all links resolved, in
+// the \\?\ form.
+func finalPath(dir string) (string, error) {
+	h, err := syscall.CreateFile(syscall.StringToUTF16Ptr(LongFilename(dir)), fileReadAttributes, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE, nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return "", err
+	}
+	defer syscall.CloseHandle(h)
+	return finalPathByHandle(h)
+}
+
+func finalPathByHandle(h syscall.Handle) (string, error) {
+	buf := make([]uint16, maxLongPath)
+	n, _, err := procGetFinalPathNameByHandleW.Call(uintptr(h), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0)
+	if n == 0 {
+		return "", err
+	}
+	if int(n) > len(buf) {
+		return "", errors.New("path too long")
+	}
+	return syscall.UTF16ToString(buf[:n]), nil
+}
+
+// volumeName returns the name of the volume holding the path, in the form
+// that opens the volume itself, \\?\Volume{GUID}.
+func volumeName(path string) (string, error) {
+	path = strings.TrimPrefix(path, `\\?\`)
+	buf := make([]uint16, maxLongPath)
+	if r, _, err := procGetVolumePathNameW.Call(uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(path))), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf))); r == 0 {
+		return "", err
+	}
+	mountPoint := withSeparator(syscall.UTF16ToString(buf))
+
+	name := make([]uint16, 64)
+	if r, _, err := procGetVolumeNameForVolumeMountPointW.Call(uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(mountPoint))), uintptr(unsafe.Pointer(&name[0])), uintptr(len(name))); r == 0 {
+		return "", err
+	}
+	return strings.TrimSuffix(syscall.UTF16ToString(name), `\`), nil
+}
+
+func withSeparator(path string) string {
+	if strings.HasSuffix(path, `\`) {
+		return path
+	}
+	return path + `\`
+}
@@ -0,0 +1,40 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build windows
+
+package fs
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// LongFilename returns the path in the \\?\ form, which lifts the limit of
+// 260 characters on the length of paths, if it is absolute and not already
+// in that form. UNC paths (\\server\share\...) are converted to
+// \\?\UNC\server\share\.... Relative paths are returned unchanged, as the
+// long form can't express them.
+func LongFilename(path string) string {
+	if strings.HasPrefix(path, `\\?\`) || strings.HasPrefix(path, `\\.\`) || !filepath.IsAbs(path) {
+		return path
+	}
+
+	// The long form disables all normalization of the path, such as
+	// resolving ".." and turning slashes into backslashes, so we need to do
+	// that first.
+	path = filepath.Clean(path)
+	if filepath.VolumeName(path) == path {
+		// The root of a UNC share needs the trailing backslash to be
+		// understood as a directory.
+		path += `\`
+	}
+
+	if strings.HasPrefix(path, `\\`) {
+		return `\\?\UNC\` + path[2:]
+	}
+	return `\\?\` + path
+}
@@ -0,0 +1,36 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build windows
+
+package fs
+
+import "testing"
+
+func TestLongFilename(t *testing.T) {
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{`C:\`, `\\?\C:\`},
+		{`C:\some\dir`, `\\?\C:\some\dir`},
+		{`C:\some\dir\`, `\\?\C:\some\dir`},
+		{`C:/some/../other/dir`, `\\?\C:\other\dir`},
+		{`\\server\share`, `\\?\UNC\server\share\`},
+		{`\\server\share\some\dir`, `\\?\UNC\server\share\some\dir`},
+		{`\\?\C:\already\long`, `\\?\C:\already\long`},
+		{`\\?\UNC\server\share\dir`, `\\?\UNC\server\share\dir`},
+		{`\\.\device`, `\\.\device`},
+		{`relative\path`, `relative\path`},
+		{`C:relative`, `C:relative`},
+	}
+
+	for _, tc := range cases {
+		if res := LongFilename(tc.path); res != tc.expected {
+			t.Errorf("LongFilename(%q) = %q, expected %q", tc.path, res, tc.expected)
+		}
+	}
+}
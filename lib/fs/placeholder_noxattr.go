@@ -0,0 +1,18 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build !linux,!freebsd
+
+package fs
+
+func MarkPlaceholder(name string) error {
+	// Placeholders go unmarked on this platform.
+	return nil
+}
+
+func IsMarkedPlaceholder(name string) bool {
+	return false
+}
@@ -0,0 +1,25 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build linux freebsd
+
+package fs
+
+import "golang.org/x/sys/unix"
+
+const placeholderXattr = "user.syncthing.placeholder"
+
+// MarkPlaceholder sets the extended attribute marking the file as a
+// placeholder for data that hasn't been fetched.
+func MarkPlaceholder(name string) error {
+	return unix.Setxattr(name, placeholderXattr, []byte{1}, 0)
+}
+
+// IsMarkedPlaceholder returns true if the file has the placeholder marker.
+func IsMarkedPlaceholder(name string) bool {
+	sz, err := unix.Getxattr(name, placeholderXattr, nil)
+	return err == nil && sz > 0
+}
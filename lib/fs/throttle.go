@@ -0,0 +1,144 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"io"
+
+	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
+)
+
+// The burst size of the bandwidth limits. Longer reads and writes wait for
+// their bytes in several goes.
+const ioThrottleBurstSize = 4 * 128 << 10
+
+// An IOThrottle limits the rate of reads from and writes to disk, in
+// operations and in bytes per second. Each call to Read, ReadAt or WriteAt
+// through it is an operation. A nil IOThrottle doesn't limit anything, so
+// it can be used whether or not there are limits.
+type IOThrottle struct {
+	readOps    *rate.Limiter
+	readBytes  *rate.Limiter
+	writeOps   *rate.Limiter
+	writeBytes *rate.Limiter
+}
+
+// NewIOThrottle returns a throttle with the given limits, in KiB/s and
+// operations per second, each zero for none. It returns nil when there are
+// no limits at all.
+func NewIOThrottle(readKbps, writeKbps, readOps, writeOps int) *IOThrottle {
+	if readKbps <= 0 && writeKbps <= 0 && readOps <= 0 && writeOps <= 0 {
+		return nil
+	}
+	return &IOThrottle{
+		readOps:    newRateLimiter(readOps, 1),
+		readBytes:  newRateLimiter(1024*readKbps, ioThrottleBurstSize),
+		writeOps:   newRateLimiter(writeOps, 1),
+		writeBytes: newRateLimiter(1024*writeKbps, ioThrottleBurstSize),
+	}
+}
+
+func newRateLimiter(perSecond, burst int) *rate.Limiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	if burst > perSecond {
+		// Allowing more than a second's worth at once would let reads and
+		// writes of less than the burst size go unlimited.
+		burst = perSecond
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), burst)
+}
+
+// WaitRead blocks until a read of the given number of bytes may be done.
+func (t *IOThrottle) WaitRead(bytes int) {
+	if t == nil {
+		return
+	}
+	wait(t.readOps, 1)
+	wait(t.readBytes, bytes)
+}
+
+// WaitWrite blocks until a write of the given number of bytes may be done.
+func (t *IOThrottle) WaitWrite(bytes int) {
+	if t == nil {
+		return
+	}
+	wait(t.writeOps, 1)
+	wait(t.writeBytes, bytes)
+}
+
+// wait takes tokens from the limiter, in several goes when there are more
+// than its burst size.
+func wait(l *rate.Limiter, tokens int) {
+	if l == nil {
+		return
+	}
+	for tokens > 0 {
+		n := tokens
+		if n > l.Burst() {
+			n = l.Burst()
+		}
+		l.WaitN(context.TODO(), n)
+		tokens -= n
+	}
+}
+
+// Reader returns a reader reading from r within the limits.
+func (t *IOThrottle) Reader(r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return throttledReader{r, t}
+}
+
+// ReaderAt returns a reader reading from r within the limits.
+func (t *IOThrottle) ReaderAt(r io.ReaderAt) io.ReaderAt {
+	if t == nil {
+		return r
+	}
+	return throttledReaderAt{r, t}
+}
+
+// WriterAt returns a writer writing to w within the limits.
+func (t *IOThrottle) WriterAt(w io.WriterAt) io.WriterAt {
+	if t == nil {
+		return w
+	}
+	return throttledWriterAt{w, t}
+}
+
+type throttledReader struct {
+	r io.Reader
+	t *IOThrottle
+}
+
+func (r throttledReader) Read(p []byte) (int, error) {
+	r.t.WaitRead(len(p))
+	return r.r.Read(p)
+}
+
+type throttledReaderAt struct {
+	r io.ReaderAt
+	t *IOThrottle
+}
+
+func (r throttledReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.t.WaitRead(len(p))
+	return r.r.ReadAt(p, off)
+}
+
+type throttledWriterAt struct {
+	w io.WriterAt
+	t *IOThrottle
+}
+
+func (w throttledWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	w.t.WaitWrite(len(p))
+	return w.w.WriteAt(p, off)
+}
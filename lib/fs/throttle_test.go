@@ -0,0 +1,67 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIOThrottleUnlimited(t *testing.T) {
+	if th := NewIOThrottle(0, 0, 0, 0); th != nil {
+		t.Fatal("A throttle without limits should be nil")
+	}
+
+	var th *IOThrottle
+	r := bytes.NewReader(nil)
+	if th.Reader(r) != r || th.ReaderAt(r) != r {
+		t.Error("A nil throttle should not wrap")
+	}
+	th.WaitRead(1 << 30)
+	th.WaitWrite(1 << 30)
+}
+
+func TestIOThrottleOps(t *testing.T) {
+	fd, err := ioutil.TempFile("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fd.Name())
+	defer fd.Close()
+
+	// Reads are unlimited, writes at twenty per second.
+
+	th := NewIOThrottle(0, 0, 0, 20)
+	w := th.WriterAt(fd)
+	t0 := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := w.WriteAt([]byte("data"), int64(4*i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(t0); d < 150*time.Millisecond {
+		t.Errorf("Five writes took %v, expected at least 200ms", d)
+	}
+
+	r := th.ReaderAt(fd)
+	buf := make([]byte, 4)
+	t0 = time.Now()
+	for i := 0; i < 20; i++ {
+		if _, err := r.ReadAt(buf, int64(4*(i%5))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(t0); d > 100*time.Millisecond {
+		t.Errorf("Unlimited reads took %v", d)
+	}
+	if string(buf) != "data" {
+		t.Errorf("Read %q", buf)
+	}
+}
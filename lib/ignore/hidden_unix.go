@@ -0,0 +1,19 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build !windows
+
+package ignore
+
+import (
+	"os"
+	"strings"
+)
+
+// isHidden returns whether the file is hidden, that is a dot file.
+func isHidden(info os.FileInfo) bool {
+	return strings.HasPrefix(info.Name(), ".")
+}
@@ -30,13 +30,17 @@ const (
 )
 
 type Pattern struct {
-	pattern string
-	match   glob.Glob
-	result  Result
+	pattern    string
+	match      glob.Glob
+	result     Result
+	predicates []predicate
 }
 
 func (p Pattern) String() string {
 	ret := p.pattern
+	for i := len(p.predicates) - 1; i >= 0; i-- {
+		ret = "(" + p.predicates[i].text + ")" + ret
+	}
 	if p.result&resultInclude != resultInclude {
 		ret = "!" + ret
 	}
@@ -64,13 +68,15 @@ func (r Result) IsCaseFolded() bool {
 }
 
 type Matcher struct {
-	patterns  []Pattern
-	withCache bool
-	matches   *cache
-	curHash   string
-	stop      chan struct{}
-	modtimes  map[string]time.Time
-	mut       sync.Mutex
+	patterns   []Pattern
+	predicates bool     // some of the patterns have predicates
+	unselected []string // directories not synced, slash separated
+	withCache  bool
+	matches    *cache
+	curHash    string
+	stop       chan struct{}
+	modtimes   map[string]time.Time
+	mut        sync.Mutex
 }
 
 func New(withCache bool) *Matcher {
@@ -124,7 +130,7 @@ func (m *Matcher) parseLocked(r io.Reader, file string) error {
 	// Error is saved and returned at the end. We process the patterns
 	// (possibly blank) anyway.
 
-	newHash := hashPatterns(patterns)
+	newHash := hashPatterns(patterns, m.unselected)
 	if newHash == m.curHash {
 		// We've already loaded exactly these patterns.
 		return err
@@ -132,6 +138,13 @@ func (m *Matcher) parseLocked(r io.Reader, file string) error {
 
 	m.curHash = newHash
 	m.patterns = patterns
+	m.predicates = false
+	for _, pattern := range patterns {
+		if len(pattern.predicates) > 0 {
+			m.predicates = true
+			break
+		}
+	}
 	if m.withCache {
 		m.matches = newCache(patterns)
 	}
@@ -159,6 +172,9 @@ func (m *Matcher) patternsUnchanged(file string) bool {
 	return true
 }
 
+// Match returns the result of matching the file against the patterns. As
+// nothing but the name is known, patterns with predicates don't match; use
+// MatchInfo for files at hand.
 func (m *Matcher) Match(file string) (result Result) {
 	if m == nil {
 		return resultNotMatched
@@ -167,7 +183,7 @@ func (m *Matcher) Match(file string) (result Result) {
 	m.mut.Lock()
 	defer m.mut.Unlock()
 
-	if len(m.patterns) == 0 {
+	if len(m.patterns) == 0 && len(m.unselected) == 0 {
 		return resultNotMatched
 	}
 
@@ -184,10 +200,49 @@ func (m *Matcher) Match(file string) (result Result) {
 		}()
 	}
 
-	// Check all the patterns for a match.
+	return m.matchLocked(file, nil)
+}
+
+// MatchInfo returns the result of matching the file, with the given
+// metadata, against the patterns, including those with predicates.
+// Predicates never match directories, whose size and times say little
+// about their contents.
+func (m *Matcher) MatchInfo(file string, info os.FileInfo) Result {
+	if m == nil {
+		return resultNotMatched
+	}
+
+	m.mut.Lock()
+	predicates := m.predicates
+	m.mut.Unlock()
+	if !predicates || info == nil {
+		return m.Match(file)
+	}
+
+	// The result depends on the metadata, so it isn't cached.
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return m.matchLocked(file, info)
+}
+
+func (m *Matcher) matchLocked(file string, info os.FileInfo) Result {
 	file = filepath.ToSlash(file)
+	now := time.Now()
+
+	// Unselected directories and their contents are always ignored,
+	// regardless of any patterns.
+	for _, dir := range m.unselected {
+		if file == dir || strings.HasPrefix(file, dir+"/") {
+			return resultInclude
+		}
+	}
+
+	// Check all the patterns for a match.
 	var lowercaseFile string
 	for _, pattern := range m.patterns {
+		if len(pattern.predicates) > 0 && !pattern.holds(info, now) {
+			continue
+		}
 		if pattern.result.IsCaseFolded() {
 			if lowercaseFile == "" {
 				lowercaseFile = strings.ToLower(file)
@@ -206,6 +261,19 @@ func (m *Matcher) Match(file string) (result Result) {
 	return resultNotMatched
 }
 
+// holds returns whether the file meets all the predicates of the pattern.
+func (p Pattern) holds(info os.FileInfo, now time.Time) bool {
+	if info == nil || info.IsDir() {
+		return false
+	}
+	for _, pred := range p.predicates {
+		if !pred.check(info, now) {
+			return false
+		}
+	}
+	return true
+}
+
 // Patterns return a list of the loaded patterns, as they've been parsed
 func (m *Matcher) Patterns() []string {
 	if m == nil {
@@ -222,6 +290,38 @@ func (m *Matcher) Patterns() []string {
 	return patterns
 }
 
+// SetUnselected sets the directories, as paths relative to the folder root,
+// that are to be ignored in addition to the patterns. These are kept when
+// the patterns are reloaded.
+func (m *Matcher) SetUnselected(dirs []string) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.unselected = make([]string, len(dirs))
+	for i, dir := range dirs {
+		m.unselected[i] = filepath.ToSlash(filepath.Clean(dir))
+	}
+
+	m.curHash = hashPatterns(m.patterns, m.unselected)
+	if m.withCache {
+		m.matches = newCache(m.patterns)
+	}
+}
+
+// Unselected returns the directories set by SetUnselected.
+func (m *Matcher) Unselected() []string {
+	if m == nil {
+		return nil
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	dirs := make([]string, len(m.unselected))
+	copy(dirs, m.unselected)
+	return dirs
+}
+
 func (m *Matcher) Hash() string {
 	m.mut.Lock()
 	defer m.mut.Unlock()
@@ -265,12 +365,17 @@ func (m *Matcher) ShouldIgnore(filename string) bool {
 	return false
 }
 
-func hashPatterns(patterns []Pattern) string {
+func hashPatterns(patterns []Pattern, unselected []string) string {
 	h := md5.New()
 	for _, pat := range patterns {
 		h.Write([]byte(pat.String()))
 		h.Write([]byte("\n"))
 	}
+	for _, dir := range unselected {
+		h.Write([]byte("unselected:"))
+		h.Write([]byte(dir))
+		h.Write([]byte("\n"))
+	}
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
@@ -323,11 +428,21 @@ func parseIgnoreFile(fd io.Reader, currentFile string, modtimes map[string]time.
 				seenPrefix[2] = true
 				pattern.result |= resultDeletable
 				line = line[4:]
+			} else if pred, n, ok, err := parsePredicate(line); err != nil {
+				return err
+			} else if ok {
+				pattern.predicates = append(pattern.predicates, pred)
+				line = line[n:]
 			} else {
 				break
 			}
 		}
 
+		if len(pattern.predicates) > 0 && line == "" {
+			// Just predicates, for files anywhere.
+			line = "**"
+		}
+
 		if pattern.result.IsCaseFolded() {
 			line = strings.ToLower(line)
 		}
@@ -422,7 +537,7 @@ func parseIgnoreFile(fd io.Reader, currentFile string, modtimes map[string]time.
 // root, represents an internal file that should always be ignored. The file
 // path must be clean (i.e., in canonical shortest form).
 func IsInternal(file string) bool {
-	internals := []string{".stfolder", ".stignore", ".stversions"}
+	internals := []string{".stfolder", ".stignore", ".stversions", ".stsnapshots", ".stquarantine"}
 	pathSep := string(os.PathSeparator)
 	for _, internal := range internals {
 		if file == internal {
@@ -813,6 +813,140 @@ func TestGobwasGlobIssue18(t *testing.T) {
 	}
 }
 
+func TestUnselected(t *testing.T) {
+	pats := New(true)
+	if err := pats.Parse(bytes.NewBufferString("!a/b/keep\n*.tmp\n"), ".stignore"); err != nil {
+		t.Fatal(err)
+	}
+	hash := pats.Hash()
+
+	pats.SetUnselected([]string{filepath.FromSlash("a/b")})
+	if pats.Hash() == hash {
+		t.Error("Hash should change with the unselected directories")
+	}
+
+	cases := []struct {
+		file    string
+		ignored bool
+	}{
+		{"a", false},
+		{"a/b", true},
+		{"a/b/c", true},
+		{"a/b/keep", true}, // unselected directories win over patterns
+		{"a/bc", false},
+		{"x.tmp", true},
+	}
+	check := func() {
+		for _, tc := range cases {
+			if res := pats.Match(filepath.FromSlash(tc.file)).IsIgnored(); res != tc.ignored {
+				t.Errorf("Match(%q).IsIgnored() = %v, should be %v", tc.file, res, tc.ignored)
+			}
+		}
+	}
+	check()
+
+	// Reloading the patterns keeps the unselected directories.
+	if err := pats.Parse(bytes.NewBufferString("!a/b/keep\n*.tmp\n"), ".stignore"); err != nil {
+		t.Fatal(err)
+	}
+	check()
+	if unselected := pats.Unselected(); len(unselected) != 1 || unselected[0] != "a/b" {
+		t.Errorf("Unexpected unselected directories %v", unselected)
+	}
+
+	pats.SetUnselected(nil)
+	if pats.Hash() != hash {
+		t.Error("Hash should be back to that of just the patterns")
+	}
+	if pats.Match(filepath.FromSlash("a/b/c")).IsIgnored() {
+		t.Error("Should no longer match a/b/c")
+	}
+}
+
+type fakeInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (f fakeInfo) Name() string       { return f.name }
+func (f fakeInfo) Size() int64        { return f.size }
+func (f fakeInfo) Mode() os.FileMode  { return f.mode }
+func (f fakeInfo) ModTime() time.Time { return f.modTime }
+func (f fakeInfo) IsDir() bool        { return f.mode.IsDir() }
+func (f fakeInfo) Sys() interface{}   { return nil }
+
+func TestPredicates(t *testing.T) {
+	stignore := `
+	!(size>1G)keep.iso
+	(size>1G)
+	(?d)(mtime<2020-01-01)*.log
+	(age>30d)(attr:readonly)cache
+	*.tmp
+	`
+	pats := New(true)
+	if err := pats.Parse(bytes.NewBufferString(stignore), ".stignore"); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Date(2019, 6, 1, 0, 0, 0, 0, time.Local)
+	recent := time.Now().Add(-time.Hour)
+	cases := []struct {
+		file    string
+		info    fakeInfo
+		ignored bool
+	}{
+		{"big.iso", fakeInfo{size: 2 << 30, mode: 0644, modTime: recent}, true},
+		{"dir/big.iso", fakeInfo{size: 2 << 30, mode: 0644, modTime: recent}, true},
+		{"small.iso", fakeInfo{size: 1 << 20, mode: 0644, modTime: recent}, false},
+		{"keep.iso", fakeInfo{size: 2 << 30, mode: 0644, modTime: recent}, false},
+		{"bigdir", fakeInfo{size: 2 << 30, mode: os.ModeDir | 0755, modTime: recent}, false},
+		{"old.log", fakeInfo{size: 10, mode: 0644, modTime: old}, true},
+		{"new.log", fakeInfo{size: 10, mode: 0644, modTime: recent}, false},
+		{"cache", fakeInfo{size: 10, mode: 0444, modTime: old}, true},
+		{"cache", fakeInfo{size: 10, mode: 0644, modTime: old}, false},
+		{"cache", fakeInfo{size: 10, mode: 0444, modTime: recent}, false},
+		{"x.tmp", fakeInfo{size: 10, mode: 0644, modTime: recent}, true},
+	}
+	for _, tc := range cases {
+		if res := pats.MatchInfo(filepath.FromSlash(tc.file), tc.info).IsIgnored(); res != tc.ignored {
+			t.Errorf("MatchInfo(%q, %+v).IsIgnored() = %v, should be %v", tc.file, tc.info, res, tc.ignored)
+		}
+	}
+
+	// Without the metadata, only the plain patterns match.
+	if pats.Match("big.iso").IsIgnored() {
+		t.Error("big.iso should not match without metadata")
+	}
+	if !pats.Match("x.tmp").IsIgnored() {
+		t.Error("x.tmp should match without metadata")
+	}
+	if !pats.MatchInfo("old.log", fakeInfo{mode: 0644, modTime: old}).IsDeletable() {
+		t.Error("old.log should be deletable")
+	}
+
+	// The predicates are kept in the parsed patterns.
+	if pats := pats.Patterns(); pats[0] != "!(size>1G)keep.iso" || pats[7] != "(?d)(mtime<2020-01-01)*.log" {
+		t.Errorf("Unexpected patterns %v", pats)
+	}
+
+	for _, invalid := range []string{"(size>lots)", "(size)x", "(mtime<yesterday)", "(age>3y)", "(attr:shiny)"} {
+		if err := New(false).Parse(bytes.NewBufferString(invalid), ".stignore"); err == nil {
+			t.Errorf("Parsing %q should fail", invalid)
+		}
+	}
+
+	// Other names in parentheses are just patterns.
+	pats = New(false)
+	if err := pats.Parse(bytes.NewBufferString("(draft)*"), ".stignore"); err != nil {
+		t.Fatal(err)
+	}
+	if !pats.Match("(draft)notes.txt").IsIgnored() {
+		t.Error("(draft)notes.txt should match")
+	}
+}
+
 func TestIsInternal(t *testing.T) {
 	cases := []struct {
 		file     string
@@ -824,6 +958,10 @@ func TestIsInternal(t *testing.T) {
 		{".stfolder/foo", true},
 		{".stignore/foo", true},
 		{".stversions/foo", true},
+		{".stsnapshots", true},
+		{".stsnapshots/foo", true},
+		{".stquarantine", true},
+		{".stquarantine/foo", true},
 
 		{".stfolderfoo", false},
 		{".stignorefoo", false},
@@ -834,6 +972,8 @@ func TestIsInternal(t *testing.T) {
 		{"foo/.stfolder", false},
 		{"foo/.stignore", false},
 		{"foo/.stversions", false},
+		{"foo/.stsnapshots", false},
+		{"foo/.stquarantine", false},
 	}
 
 	for _, tc := range cases {
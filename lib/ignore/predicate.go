@@ -0,0 +1,191 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ignore
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A predicate is a condition on the metadata of a file, given in a pattern
+// as a prefix such as "(size>1G)", "(mtime<2020-01-01)", "(age>30d)" or
+// "(attr:hidden)". A pattern with predicates only matches the files that
+// meet all of them.
+type predicate struct {
+	text  string // without the parentheses
+	check func(info os.FileInfo, now time.Time) bool
+}
+
+var predicateKeywords = []string{"size", "mtime", "age", "attr:"}
+
+// parsePredicate parses the predicate at the start of the line, returning
+// it and the length of its text including the parentheses. If the line
+// doesn't start with a predicate ok is false; a malformed one is an error.
+func parsePredicate(line string) (pred predicate, n int, ok bool, err error) {
+	if !strings.HasPrefix(line, "(") {
+		return predicate{}, 0, false, nil
+	}
+	end := strings.IndexByte(line, ')')
+	if end < 0 {
+		return predicate{}, 0, false, nil
+	}
+	text := line[1:end]
+
+	keyword := ""
+	for _, kw := range predicateKeywords {
+		if strings.HasPrefix(text, kw) {
+			keyword = kw
+			break
+		}
+	}
+	if keyword == "" {
+		// Part of the pattern, such as a name in parentheses.
+		return predicate{}, 0, false, nil
+	}
+
+	pred.text = text
+	if keyword == "attr:" {
+		pred.check, err = parseAttr(text[len(keyword):])
+	} else {
+		pred.check, err = parseComparison(keyword, text[len(keyword):])
+	}
+	if err != nil {
+		return predicate{}, 0, false, fmt.Errorf("invalid predicate %q (%v)", text, err)
+	}
+	return pred, end + 1, true, nil
+}
+
+func parseAttr(attr string) (func(os.FileInfo, time.Time) bool, error) {
+	switch attr {
+	case "hidden":
+		return func(info os.FileInfo, _ time.Time) bool {
+			return isHidden(info)
+		}, nil
+	case "readonly":
+		return func(info os.FileInfo, _ time.Time) bool {
+			return info.Mode().Perm()&0200 == 0
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown attribute %q", attr)
+}
+
+func parseComparison(keyword, cond string) (func(os.FileInfo, time.Time) bool, error) {
+	op := ""
+	for _, o := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(cond, o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		return nil, fmt.Errorf("missing comparison")
+	}
+	value := strings.TrimSpace(cond[len(op):])
+
+	switch keyword {
+	case "size":
+		size, err := parseSize(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(info os.FileInfo, _ time.Time) bool {
+			return compare(op, info.Size(), size)
+		}, nil
+
+	case "mtime":
+		t, err := parseDate(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(info os.FileInfo, _ time.Time) bool {
+			return compare(op, info.ModTime().UnixNano(), t.UnixNano())
+		}, nil
+
+	case "age":
+		age, err := parseAge(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(info os.FileInfo, now time.Time) bool {
+			return compare(op, int64(now.Sub(info.ModTime())), int64(age))
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown predicate")
+}
+
+func compare(op string, a, b int64) bool {
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	default:
+		return a == b
+	}
+}
+
+// parseSize parses a size in bytes, optionally with one of the binary
+// multiples K, M, G or T, as in "512K" or "1GiB".
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(s), "B"), "I")
+	mult := int64(1)
+	if s != "" {
+		if i := strings.IndexByte("KMGT", s[len(s)-1]); i >= 0 {
+			mult = 1 << (10 * uint(i+1))
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size")
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// parseDate parses a date as "2006-01-02", in local time, or a full
+// RFC 3339 timestamp.
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date")
+	}
+	return t, nil
+}
+
+// parseAge parses an age as a number of seconds, minutes, hours, days or
+// weeks, as in "90m" or "30d".
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("invalid age")
+	}
+	units := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+	unit, ok := units[s[len(s)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid age")
+	}
+	n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid age")
+	}
+	return time.Duration(n * float64(unit)), nil
+}
@@ -0,0 +1,67 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"container/list"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// A blockCache keeps the most recently used blocks by hash, up to a total
+// size in bytes.
+type blockCache struct {
+	size   int64
+	order  *list.List               // of cachedBlock, most recently used first
+	blocks map[string]*list.Element // by hash
+	mut    sync.Mutex
+}
+
+type cachedBlock struct {
+	hash string
+	data []byte
+}
+
+func newBlockCache() *blockCache {
+	return &blockCache{
+		order:  list.New(),
+		blocks: make(map[string]*list.Element),
+		mut:    sync.NewMutex(),
+	}
+}
+
+func (c *blockCache) get(hash []byte) ([]byte, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	e, ok := c.blocks[string(hash)]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(cachedBlock).data, true
+}
+
+// add caches the block, dropping the least recently used ones to stay
+// within max bytes.
+func (c *blockCache) add(hash, data []byte, max int64) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if _, ok := c.blocks[string(hash)]; ok || int64(len(data)) > max {
+		return
+	}
+	c.blocks[string(hash)] = c.order.PushFront(cachedBlock{string(hash), data})
+	c.size += int64(len(data))
+
+	for c.size > max {
+		e := c.order.Back()
+		block := c.order.Remove(e).(cachedBlock)
+		delete(c.blocks, block.hash)
+		c.size -= int64(len(block.data))
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import "testing"
+
+func TestBlockCache(t *testing.T) {
+	c := newBlockCache()
+	c.add([]byte("a"), make([]byte, 4), 10)
+	c.add([]byte("b"), make([]byte, 4), 10)
+
+	// Using a makes b the least recently used, to be dropped for c
+
+	if _, ok := c.get([]byte("a")); !ok {
+		t.Error("a not cached")
+	}
+	c.add([]byte("c"), make([]byte, 4), 10)
+	if _, ok := c.get([]byte("b")); ok {
+		t.Error("b still cached")
+	}
+	for _, hash := range []string{"a", "c"} {
+		if _, ok := c.get([]byte(hash)); !ok {
+			t.Errorf("%s not cached", hash)
+		}
+	}
+	if c.size != 8 {
+		t.Errorf("Unexpected size %d", c.size)
+	}
+
+	// Blocks larger than the cache aren't cached at all
+
+	c.add([]byte("d"), make([]byte, 11), 10)
+	if _, ok := c.get([]byte("d")); ok || c.size != 8 {
+		t.Error("Oversized block cached")
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// Files quarantined to resolve a name collision are moved into this
+// directory within the folder, under their name in the folder. It's an
+// internal name, so they're no longer synced.
+const quarantineDir = ".stquarantine"
+
+var errNoRepairPolicy = errors.New("no case collision repair strategy given")
+
+// A CaseRepair is a file that was moved aside to resolve a name collision.
+// NewName is relative to the folder, and in the quarantine directory if the
+// file was quarantined.
+type CaseRepair struct {
+	File    string `json:"file"`
+	NewName string `json:"newName"`
+}
+
+// RepairCaseCollisions resolves the name collisions in the folder, as
+// returned by GlobalNameCollisions, according to the policy. Of each group
+// of colliding files the most recently modified one is kept, and the others
+// are renamed or quarantined. Only files we have the current version of
+// can be moved aside, so a collision is resolved on the devices that have
+// all the files, that is those with case sensitive filesystems; the rest
+// then see the result. The moved files are rescanned, so the repair is
+// synced.
+func (m *Model) RepairCaseCollisions(folder string, policy config.CaseCollisionPolicy) ([]CaseRepair, error) {
+	repairs, err := m.repairCaseCollisions(folder, policy)
+	if err != nil || len(repairs) == 0 {
+		return repairs, err
+	}
+	return repairs, m.ScanFolderSubdirs(folder, caseRepairNames(repairs))
+}
+
+// repairCaseCollisionsAfterScan repairs the collisions in the folder
+// according to its configured policy, rescanning the moved files itself as
+// it's called from the folder's scan.
+func (m *Model) repairCaseCollisionsAfterScan(folder string, policy config.CaseCollisionPolicy) {
+	repairs, err := m.repairCaseCollisions(folder, policy)
+	if err != nil {
+		l.Infof("Repairing case collisions in folder %q: %v", folder, err)
+	}
+	if len(repairs) > 0 {
+		m.internalScanFolderSubdirs(folder, caseRepairNames(repairs))
+	}
+}
+
+func (m *Model) repairCaseCollisions(folder string, policy config.CaseCollisionPolicy) ([]CaseRepair, error) {
+	if policy != config.CaseCollisionRename && policy != config.CaseCollisionQuarantine {
+		return nil, errNoRepairPolicy
+	}
+
+	collisions, err := m.GlobalNameCollisions(folder)
+	if err != nil || len(collisions) == 0 {
+		return nil, err
+	}
+
+	m.fmut.RLock()
+	cfg := m.folderCfgs[folder]
+	files := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	root := cfg.Path()
+
+	var repairs []CaseRepair
+	for _, group := range collisions {
+		keep := newestGlobal(files, group)
+		for _, name := range group {
+			if name == keep || !haveCurrent(files, name) {
+				continue
+			}
+			path, err := rootedJoinedPath(root, name)
+			if err != nil {
+				return repairs, err
+			}
+			if !existsExactly(path) {
+				// The filesystem has it under another name; it's not
+				// ours to move.
+				continue
+			}
+
+			newName, err := caseRepairName(root, files, name, policy)
+			if err != nil {
+				return repairs, err
+			}
+			newPath := filepath.Join(root, newName)
+			if err := os.MkdirAll(filepath.Dir(newPath), 0777); err != nil {
+				return repairs, err
+			}
+			if err := osutil.Rename(path, newPath); err != nil {
+				return repairs, err
+			}
+
+			l.Infof("Resolved case collision of %q with %q in folder %q; moved it to %q", name, keep, folder, newName)
+			repairs = append(repairs, CaseRepair{File: name, NewName: newName})
+		}
+	}
+
+	return repairs, nil
+}
+
+// caseRepairName returns a free name to move the file to: in the quarantine
+// directory under its own name if possible, or else with a numbered suffix
+// that doesn't collide.
+func caseRepairName(root string, files *db.FileSet, name string, policy config.CaseCollisionPolicy) (string, error) {
+	dir, base := filepath.Dir(name), filepath.Base(name)
+	if policy == config.CaseCollisionQuarantine {
+		dir = filepath.Join(quarantineDir, dir)
+	}
+	ext := filepath.Ext(base)
+
+	for n := 0; n < 1000; n++ {
+		candidate := base
+		if n > 0 || policy == config.CaseCollisionRename {
+			candidate = fmt.Sprintf("%s.sync-case-%d%s", strings.TrimSuffix(base, ext), n+1, ext)
+		}
+		candidate = filepath.Join(dir, candidate)
+		if _, ok := files.GetGlobalTruncated(candidate); ok {
+			continue
+		}
+		if _, err := osutil.Lstat(filepath.Join(root, candidate)); os.IsNotExist(err) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no free name to move %q to", name)
+}
+
+// newestGlobal returns the most recently modified of the names, going by
+// the global versions, or the first one if none is newer.
+func newestGlobal(files *db.FileSet, names []string) string {
+	newest := names[0]
+	newestFile, _ := files.GetGlobalTruncated(newest)
+	for _, name := range names[1:] {
+		if f, ok := files.GetGlobalTruncated(name); ok && f.ModTime().After(newestFile.ModTime()) {
+			newest, newestFile = name, f
+		}
+	}
+	return newest
+}
+
+// haveCurrent returns whether we have the global version of the file.
+func haveCurrent(files *db.FileSet, name string) bool {
+	local, ok := files.Get(protocol.LocalDeviceID, name)
+	if !ok || local.IsDeleted() || local.IsInvalid() {
+		return false
+	}
+	global, ok := files.GetGlobalTruncated(name)
+	return ok && local.Version.Equal(global.Version)
+}
+
+// existsExactly returns whether the path exists with just that name, as on
+// case insensitive filesystems it may also be found by another case.
+func existsExactly(path string) bool {
+	fd, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return false
+	}
+	defer fd.Close()
+	names, err := fd.Readdirnames(-1)
+	if err != nil {
+		return false
+	}
+	base := filepath.Base(path)
+	for _, name := range names {
+		if name == base {
+			return true
+		}
+	}
+	return false
+}
+
+func caseRepairNames(repairs []CaseRepair) []string {
+	names := make([]string, 0, 2*len(repairs))
+	for _, r := range repairs {
+		names = append(names, r.File)
+		if !strings.HasPrefix(r.NewName, quarantineDir+string(os.PathSeparator)) {
+			names = append(names, r.NewName)
+		}
+	}
+	return names
+}
@@ -0,0 +1,203 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"path/filepath"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/upgrade"
+)
+
+// Cluster config deltas
+//
+// When the set of folders shared with a connected device changes, devices
+// that announced that they accept cluster config deltas are sent one that
+// describes just the changed folders, instead of having their connection
+// closed so that a complete cluster config is exchanged on reconnect. On
+// either side, the index sending for the changed folders is restarted
+// based on the new information.
+
+// RemoteClusterConfig returns the last cluster config received from the
+// device, with any deltas applied.
+func (m *Model) RemoteClusterConfig(device protocol.DeviceID) (protocol.ClusterConfig, bool) {
+	m.pmut.RLock()
+	cm, ok := m.remoteClusterConfigs[device]
+	m.pmut.RUnlock()
+	return cm, ok
+}
+
+// acceptsDeltasLocked returns true if the device is connected, has told us
+// it accepts cluster config deltas, and has been sent our full cluster
+// config already.
+func (m *Model) acceptsDeltasLocked(device protocol.DeviceID) bool {
+	if _, ok := m.conn[device]; !ok {
+		return false
+	}
+	return m.remoteClusterConfigs[device].AcceptsDeltas && m.clusterConfigSent[device]
+}
+
+// clusterConfigUpdatesLocked returns the cluster config deltas to send
+// after a change to the given folder, which was previously shared with the
+// devices in "before". Index sending for the folder is restarted for the
+// devices that get a delta. Devices that don't accept deltas are left out;
+// their connections are closed when the folder is torn down or started.
+// The updates should be sent after the locks are released.
+func (m *Model) clusterConfigUpdatesLocked(folder string, before []protocol.DeviceID) map[protocol.DeviceID]protocol.ClusterConfig {
+	updates := make(map[protocol.DeviceID]protocol.ClusterConfig)
+
+	var after []protocol.DeviceID
+	if _, ok := m.folderRunners[folder]; ok {
+		after = m.folderDevices.sortedDevices(folder)
+	}
+
+	dbLocation := filepath.Dir(m.db.Location())
+	for _, device := range after {
+		if !m.acceptsDeltasLocked(device) {
+			continue
+		}
+		protocolFolder := m.protocolFolderLocked(folder)
+		updates[device] = protocol.ClusterConfig{
+			Folders: []protocol.Folder{protocolFolder},
+			Delta:   true,
+		}
+
+		// If they already told us about the folder we can start sending
+		// right away; otherwise, we will when they do.
+		for _, remoteFolder := range m.remoteClusterConfigs[device].Folders {
+			if remoteFolder.ID == folder && !remoteFolder.Paused && m.cfg.Devices()[device].FolderAllowed(folder) {
+				hello := m.helloMessages[device]
+				m.startIndexSenderLocked(m.conn[device], remoteFolder, dbLocation, m.dropSymlinks(hello), !hello.Placeholders)
+			}
+		}
+	}
+
+	for _, device := range before {
+		if _, ok := updates[device]; ok || !m.acceptsDeltasLocked(device) {
+			continue
+		}
+		updates[device] = protocol.ClusterConfig{
+			Delta:          true,
+			RemovedFolders: []string{folder},
+		}
+	}
+
+	return updates
+}
+
+func (m *Model) sendClusterConfigUpdates(updates map[protocol.DeviceID]protocol.ClusterConfig) {
+	for device, cm := range updates {
+		m.pmut.RLock()
+		conn, ok := m.conn[device]
+		m.pmut.RUnlock()
+		if ok {
+			l.Debugf("Sending cluster config delta to %s: %d folders, %d removed", device, len(cm.Folders), len(cm.RemovedFolders))
+			conn.ClusterConfig(cm)
+		}
+	}
+}
+
+// dropSymlinks returns true if we can't send modern symlink entries to the
+// device. See issue #3802.
+func (m *Model) dropSymlinks(hello protocol.HelloResult) bool {
+	return hello.ClientName == m.clientName && upgrade.CompareVersions(hello.ClientVersion, "v0.14.14") < 0
+}
+
+// startIndexSenderLocked starts sending index data for the folder over the
+// connection, from where the remote side says it's at.
+func (m *Model) startIndexSenderLocked(conn protocol.Connection, folder protocol.Folder, dbLocation string, dropSymlinks, invalidPlaceholders bool) {
+	deviceID := conn.ID()
+	m.stopIndexSenderLocked(deviceID, folder.ID)
+
+	fs := m.folderFiles[folder.ID]
+	myIndexID := fs.IndexID(protocol.LocalDeviceID)
+	mySequence := fs.Sequence(protocol.LocalDeviceID)
+	var startSequence int64
+
+	for _, dev := range folder.Devices {
+		if dev.ID == m.id {
+			// This is the other side's description of what it knows
+			// about us. Lets check to see if we can start sending index
+			// updates directly or need to send the index from start...
+
+			if dev.IndexID == myIndexID {
+				// They say they've seen our index ID before, so we can
+				// send a delta update only.
+
+				if dev.MaxSequence > mySequence {
+					// Safety check. They claim to have more or newer
+					// index data than we have - either we have lost
+					// index data, or reset the index without resetting
+					// the IndexID, or something else weird has
+					// happened. We send a full index to reset the
+					// situation.
+					l.Infof("Device %v folder %s is delta index compatible, but seems out of sync with reality", deviceID, folder.Description())
+					startSequence = 0
+					continue
+				}
+
+				l.Debugf("Device %v folder %s is delta index compatible (mlv=%d)", deviceID, folder.Description(), dev.MaxSequence)
+				startSequence = dev.MaxSequence
+			} else if dev.IndexID != 0 {
+				// They say they've seen an index ID from us, but it's
+				// not the right one. Either they are confused or we
+				// must have reset our database since last talking to
+				// them. We'll start with a full index transfer.
+				l.Infof("Device %v folder %s has mismatching index ID for us (%v != %v)", deviceID, folder.Description(), dev.IndexID, myIndexID)
+				startSequence = 0
+			}
+		} else if dev.ID == deviceID && dev.IndexID != 0 {
+			// This is the other side's description of themselves. We
+			// check to see that it matches the IndexID we have on file,
+			// otherwise we drop our old index data and expect to get a
+			// completely new set.
+
+			theirIndexID := fs.IndexID(deviceID)
+			if dev.IndexID == 0 {
+				// They're not announcing an index ID. This means they
+				// do not support delta indexes and we should clear any
+				// information we have from them before accepting their
+				// index, which will presumably be a full index.
+				fs.Replace(deviceID, nil)
+			} else if dev.IndexID != theirIndexID {
+				// The index ID we have on file is not what they're
+				// announcing. They must have reset their database and
+				// will probably send us a full index. We drop any
+				// information we have and remember this new index ID
+				// instead.
+				l.Infof("Device %v folder %s has a new index ID (%v)", deviceID, folder.Description(), dev.IndexID)
+				fs.Replace(deviceID, nil)
+				fs.SetIndexID(deviceID, dev.IndexID)
+			} else {
+				// They're sending a recognized index ID and will most
+				// likely use delta indexes. We might already have files
+				// that we need to pull so let the folder runner know
+				// that it should recheck the index data.
+				if runner := m.folderRunners[folder.ID]; runner != nil {
+					runner.IndexUpdated()
+				}
+			}
+		}
+	}
+
+	stop := make(chan struct{})
+	if m.indexSenders[folder.ID] == nil {
+		m.indexSenders[folder.ID] = make(map[protocol.DeviceID]chan struct{})
+	}
+	m.indexSenders[folder.ID][deviceID] = stop
+
+	go sendIndexes(conn, folder.ID, fs, m.folderIgnores[folder.ID], startSequence, dbLocation, dropSymlinks, invalidPlaceholders, stop)
+}
+
+// stopIndexSenderLocked stops sending index data for the folder to the
+// device, if we were.
+func (m *Model) stopIndexSenderLocked(device protocol.DeviceID, folder string) {
+	if stop, ok := m.indexSenders[folder][device]; ok {
+		close(stop)
+		delete(m.indexSenders[folder], device)
+	}
+}
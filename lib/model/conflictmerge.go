@@ -0,0 +1,116 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/versioner"
+)
+
+// Merging conflicts
+//
+// A folder can have a merge command run when a file has been changed here
+// and on another device in conflict, to merge the two versions rather than
+// keep ours as a conflict copy. It's run for the files matching the
+// folder's merge patterns, in the folder, with these environment variables:
+//
+//     STFOLDER  the folder ID
+//     STPATH    the path of the file, relative to the folder
+//     STOURS    the path of our version of the file
+//     STTHEIRS  the path of the other device's version
+//     STBASE    the path of a version the two have in common, or empty if
+//               the versioner doesn't have one
+//     STMERGED  where to write the merged file
+//
+// The command exits successfully once it has written the merged file, or
+// with an error if it can't merge the versions, in which case ours is kept
+// as a conflict copy as usual. The merged file takes the place of both
+// versions, ours being archived by the versioner, and is then scanned as a
+// change of our own so that it goes to the other devices.
+//
+// The version in common is the newest archived version that's older than
+// both. That is an ancestor of both, if not always the latest one, which
+// merge tools cope with: the changes since are seen as made on both sides.
+
+const mergeTimeout = time.Minute
+
+// mergeConflict runs the merge command for the file being finished, which
+// is in conflict with ours, and replaces the temporary file with the
+// result. It returns whether the versions were merged.
+func (f *sendReceiveFolder) mergeConflict(state *sharedPullerState) bool {
+	if f.MergeCommand == "" || !f.mergeable(state.file.Name) {
+		return false
+	}
+
+	var base string
+	if info, err := f.mtimeFS.Lstat(state.realName); err == nil {
+		base = f.mergeBase(state.file.Name, info.ModTime(), state.file.ModTime())
+	}
+	mergedName := state.tempName + ".merged"
+	defer os.Remove(mergedName)
+
+	err := f.runCommand(f.MergeCommand, []string{
+		"STFOLDER=" + f.folderID,
+		"STPATH=" + state.file.Name,
+		"STOURS=" + state.realName,
+		"STTHEIRS=" + state.tempName,
+		"STBASE=" + base,
+		"STMERGED=" + mergedName,
+	}, mergeTimeout)
+	if err == nil {
+		err = osutil.Rename(mergedName, state.tempName)
+	}
+	if err == nil && !f.ignorePermissions(state.file) {
+		err = os.Chmod(state.tempName, os.FileMode(state.file.Permissions&0777))
+	}
+	if err != nil {
+		l.Infof("Folder %s: merging the conflicting versions of %s: %v; keeping a conflict copy", f.Description(), state.file.Name, err)
+		return false
+	}
+	l.Infof("Folder %s: merged the conflicting versions of %s", f.Description(), state.file.Name)
+	return true
+}
+
+// mergeable returns true if the file matches one of the merge patterns.
+func (f *sendReceiveFolder) mergeable(name string) bool {
+	for _, pattern := range f.MergePatterns {
+		if matchPriorityPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeBase returns the path of the newest archived version of the file
+// that's older than both of the given modification times, or "" if there
+// is none.
+func (f *sendReceiveFolder) mergeBase(name string, ours, theirs time.Time) string {
+	restorer, ok := f.versioner.(versioner.Restorer)
+	if !ok {
+		return ""
+	}
+	versions, err := restorer.Versions(filepath.Dir(name))
+	if err != nil {
+		l.Debugln(f, "versions for merging", name, err)
+		return ""
+	}
+	for _, version := range versions[name] {
+		if version.ModTime.After(ours) || version.ModTime.After(theirs) {
+			continue
+		}
+		path, err := restorer.VersionPath(name, version.VersionTime)
+		if err != nil {
+			return ""
+		}
+		return path
+	}
+	return ""
+}
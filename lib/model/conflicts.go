@@ -0,0 +1,267 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+const (
+	conflictMarker        = ".sync-conflict-"
+	conflictMarkerTime    = "20060102-150405"
+	conflictSidecarSuffix = ".conflict.json"
+)
+
+var errNotConflictCopy = errors.New("not a conflict copy")
+
+// A Conflict is a file along with the conflict copies that have been made
+// of it. The file itself is described as we have it in the database; it
+// may have since been deleted, in which case Deleted is set.
+type Conflict struct {
+	File       string           `json:"file"`
+	Size       int64            `json:"size"`
+	Modified   time.Time        `json:"modified"`
+	ModifiedBy protocol.ShortID `json:"modifiedBy"`
+	Deleted    bool             `json:"deleted"`
+	Copies     []ConflictCopy   `json:"copies"`
+}
+
+// A ConflictCopy is a conflict copy as found on disk.
+type ConflictCopy struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// A ConflictSidecar describes the two versions of a conflict, for tools
+// that resolve conflicts. It is written next to the conflict copy, named
+// like it with ".conflict.json" appended, if the folder is so configured.
+type ConflictSidecar struct {
+	File    string          `json:"file"`
+	Copy    string          `json:"copy"`
+	Created time.Time       `json:"created"`
+	Copied  ConflictVersion `json:"copied"` // our version, kept as the conflict copy
+	Winner  ConflictVersion `json:"winner"` // the version that took its place
+}
+
+// A ConflictVersion is one of the versions in a conflict.
+type ConflictVersion struct {
+	ModifiedBy protocol.ShortID `json:"modifiedBy"`
+	Modified   time.Time        `json:"modified"`
+	Size       int64            `json:"size"`
+	Deleted    bool             `json:"deleted"`
+	Version    protocol.Vector  `json:"version"`
+}
+
+func conflictVersionOf(f protocol.FileInfo) ConflictVersion {
+	return ConflictVersion{
+		ModifiedBy: f.ModifiedBy,
+		Modified:   f.ModTime(),
+		Size:       f.Size,
+		Deleted:    f.IsDeleted(),
+		Version:    f.Version,
+	}
+}
+
+type conflictList []Conflict
+
+func (l conflictList) Len() int           { return len(l) }
+func (l conflictList) Swap(a, b int)      { l[a], l[b] = l[b], l[a] }
+func (l conflictList) Less(a, b int) bool { return l[a].File < l[b].File }
+
+type conflictCopyList []ConflictCopy
+
+func (l conflictCopyList) Len() int           { return len(l) }
+func (l conflictCopyList) Swap(a, b int)      { l[a], l[b] = l[b], l[a] }
+func (l conflictCopyList) Less(a, b int) bool { return l[a].Name < l[b].Name }
+
+// FolderConflicts returns the conflict copies in the folder, grouped by the
+// file they're copies of. The folder is walked to find them, as conflict
+// copies are made by the puller and only make it into the database once
+// scanned. Ignored files are left out.
+func (m *Model) FolderConflicts(folder string) ([]Conflict, error) {
+	m.fmut.RLock()
+	cfg, ok := m.folderCfgs[folder]
+	files := m.folderFiles[folder]
+	ignores := m.folderIgnores[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil, errFolderMissing
+	}
+
+	root := cfg.Path()
+	conflicts := make(map[string]*Conflict)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Unreadable files and directories can't be listed, but
+			// shouldn't prevent listing the rest.
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return nil
+		}
+		if ignore.IsInternal(rel) || ignore.IsTemporary(rel) || ignores.Match(rel).IsIgnored() {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		orig, ok := conflictOriginal(rel)
+		if !ok {
+			return nil
+		}
+		c, ok := conflicts[orig]
+		if !ok {
+			c = &Conflict{File: orig, Deleted: true}
+			if cur, ok := files.Get(protocol.LocalDeviceID, orig); ok && !cur.IsDeleted() {
+				c.Size = cur.Size
+				c.Modified = cur.ModTime()
+				c.ModifiedBy = cur.ModifiedBy
+				c.Deleted = false
+			}
+			conflicts[orig] = c
+		}
+		c.Copies = append(c.Copies, ConflictCopy{
+			Name:     rel,
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Conflict, 0, len(conflicts))
+	for _, c := range conflicts {
+		sort.Sort(conflictCopyList(c.Copies))
+		res = append(res, *c)
+	}
+	sort.Sort(conflictList(res))
+	return res, nil
+}
+
+// ResolveConflict settles a conflict between a file and one of its
+// conflict copies. If the copy wins it replaces the file, otherwise it is
+// removed. Either way both are rescanned, so the resolution is synced.
+func (m *Model) ResolveConflict(folder, name string, keepCopy bool) error {
+	orig, ok := conflictOriginal(name)
+	if !ok {
+		return errNotConflictCopy
+	}
+
+	m.fmut.RLock()
+	cfg, ok := m.folderCfgs[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return errFolderMissing
+	}
+
+	copyPath, err := rootedJoinedPath(cfg.Path(), name)
+	if err != nil {
+		return err
+	}
+	origPath, err := rootedJoinedPath(cfg.Path(), orig)
+	if err != nil {
+		return err
+	}
+
+	if keepCopy {
+		err = osutil.Rename(copyPath, origPath)
+	} else {
+		err = osutil.InWritableDir(os.Remove, copyPath)
+	}
+	if err != nil {
+		return err
+	}
+	rescan := []string{orig, name}
+	if err := osutil.InWritableDir(os.Remove, copyPath+conflictSidecarSuffix); err == nil {
+		rescan = append(rescan, name+conflictSidecarSuffix)
+	}
+
+	winner := "file"
+	if keepCopy {
+		winner = "conflict copy"
+	}
+	l.Infof("Resolved conflict for %q in folder %q, keeping the %s", orig, folder, winner)
+	return m.ScanFolderSubdirs(folder, rescan)
+}
+
+// conflictOriginal returns the name of the file that the given name is a
+// conflict copy of, and whether it is a conflict copy at all. Conflict
+// copies are named like "name.sync-conflict-20060102-150405.ext", or
+// "name.sync-conflict-20060102-150405-ABCDEFG.ext" when named by version.
+// Sidecars aren't conflict copies.
+func conflictOriginal(name string) (string, bool) {
+	base := filepath.Base(name)
+	if strings.HasSuffix(base, conflictSidecarSuffix) {
+		return "", false
+	}
+	idx := strings.Index(base, conflictMarker)
+	end := idx + len(conflictMarker) + len(conflictMarkerTime)
+	if idx < 0 || end > len(base) {
+		return "", false
+	}
+	if _, err := time.Parse(conflictMarkerTime, base[idx+len(conflictMarker):end]); err != nil {
+		return "", false
+	}
+	if rest := base[end:]; len(rest) >= 8 && rest[0] == '-' && isShortIDString(rest[1:8]) {
+		end += 8
+	}
+	return filepath.Join(filepath.Dir(name), base[:idx]+base[end:]), true
+}
+
+// conflictCopyName returns the name of the conflict copy to make of the
+// file of the given name, keeping the given version.
+func conflictCopyName(name string, naming config.ConflictNaming, copied ConflictVersion, now time.Time) string {
+	ext := filepath.Ext(name)
+	withoutExt := name[:len(name)-len(ext)]
+	if naming == config.ConflictNamingVersion && copied.ModifiedBy != 0 {
+		return withoutExt + conflictMarker + copied.Modified.UTC().Format(conflictMarkerTime) + "-" + copied.ModifiedBy.String() + ext
+	}
+	return withoutExt + conflictMarker + now.Format(conflictMarkerTime) + ext
+}
+
+// isShortIDString returns true if the string is a short device ID, seven
+// characters of base32.
+func isShortIDString(s string) bool {
+	if len(s) != 7 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'A' && c <= 'Z' || c >= '2' && c <= '7') {
+			return false
+		}
+	}
+	return true
+}
+
+// writeConflictSidecar writes the sidecar describing the conflict copy.
+func writeConflictSidecar(path string, sidecar ConflictSidecar) error {
+	bs, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path+conflictSidecarSuffix, append(bs, '\n'), 0644)
+}
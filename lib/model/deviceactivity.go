@@ -26,17 +26,26 @@ func newDeviceActivity() *deviceActivity {
 	}
 }
 
+// leastBusy returns the least busy of the devices. Devices in cold storage
+// are only selected when there are no others, however busy.
 func (m *deviceActivity) leastBusy(availability []Availability) (Availability, bool) {
 	m.mut.Lock()
 	low := 2<<30 - 1
 	found := false
 	var selected Availability
 	for _, info := range availability {
-		if usage := m.act[info.ID]; usage < low {
-			low = usage
-			selected = info
-			found = true
+		usage := m.act[info.ID]
+		if found && info.ColdStorage != selected.ColdStorage {
+			// The one not in cold storage wins, regardless of usage.
+			if info.ColdStorage {
+				continue
+			}
+		} else if usage >= low {
+			continue
 		}
+		low = usage
+		selected = info
+		found = true
 	}
 	m.mut.Unlock()
 	return selected, found
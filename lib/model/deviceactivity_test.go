@@ -13,9 +13,9 @@ import (
 )
 
 func TestDeviceActivity(t *testing.T) {
-	n0 := Availability{protocol.DeviceID([32]byte{1, 2, 3, 4}), false}
-	n1 := Availability{protocol.DeviceID([32]byte{5, 6, 7, 8}), true}
-	n2 := Availability{protocol.DeviceID([32]byte{9, 10, 11, 12}), false}
+	n0 := Availability{protocol.DeviceID([32]byte{1, 2, 3, 4}), false, false, false}
+	n1 := Availability{protocol.DeviceID([32]byte{5, 6, 7, 8}), true, false, false}
+	n2 := Availability{protocol.DeviceID([32]byte{9, 10, 11, 12}), false, false, false}
 	devices := []Availability{n0, n1, n2}
 	na := newDeviceActivity()
 
@@ -58,3 +58,22 @@ func TestDeviceActivity(t *testing.T) {
 		t.Errorf("Least busy device should be n0 (%v) not %v", n0, lb)
 	}
 }
+
+func TestDeviceActivityColdStorage(t *testing.T) {
+	cold := Availability{ID: protocol.DeviceID([32]byte{1, 2, 3, 4}), ColdStorage: true}
+	warm := Availability{ID: protocol.DeviceID([32]byte{5, 6, 7, 8})}
+	na := newDeviceActivity()
+
+	// The device in cold storage is only selected when it's the only one,
+	// however busy the other is.
+	na.using(warm)
+	na.using(warm)
+	for _, devices := range [][]Availability{{cold, warm}, {warm, cold}} {
+		if lb, ok := na.leastBusy(devices); !ok || lb != warm {
+			t.Errorf("Least busy device should be warm (%v) not %v", warm, lb)
+		}
+	}
+	if lb, ok := na.leastBusy([]Availability{cold}); !ok || lb != cold {
+		t.Errorf("Least busy device should be cold (%v) not %v", cold, lb)
+	}
+}
@@ -7,17 +7,19 @@
 package model
 
 import (
+	"github.com/syncthing/syncthing/lib/bitmap"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/sync"
 )
 
 // deviceFolderFileDownloadState holds current download state of a file that
-// a remote device has advertised. blockIndexes represends indexes within
-// FileInfo.Blocks that the remote device already has, and version represents
-// the version of the file that the remote device is downloading.
+// a remote device has advertised. blocks holds the indexes within
+// FileInfo.Blocks that the remote device already has, compressed as they
+// mostly come in order, and version represents the version of the file
+// that the remote device is downloading.
 type deviceFolderFileDownloadState struct {
-	blockIndexes []int32
-	version      protocol.Vector
+	blocks  *bitmap.Bitmap
+	version protocol.Vector
 }
 
 // deviceFolderDownloadState holds current download state of all files that
@@ -35,16 +37,11 @@ func (p *deviceFolderDownloadState) Has(file string, version protocol.Vector, in
 
 	local, ok := p.files[file]
 
-	if !ok || !local.version.Equal(version) {
+	if !ok || !local.version.Equal(version) || index < 0 {
 		return false
 	}
 
-	for _, existingIndex := range local.blockIndexes {
-		if existingIndex == index {
-			return true
-		}
-	}
-	return false
+	return local.blocks.Contains(uint32(index))
 }
 
 // Update updates internal state of what has been downloaded into the temporary
@@ -60,14 +57,17 @@ func (p *deviceFolderDownloadState) Update(updates []protocol.FileDownloadProgre
 		} else if update.UpdateType == protocol.UpdateTypeAppend {
 			if !ok {
 				local = deviceFolderFileDownloadState{
-					blockIndexes: update.BlockIndexes,
-					version:      update.Version,
+					blocks:  new(bitmap.Bitmap),
+					version: update.Version,
 				}
 			} else if !local.version.Equal(update.Version) {
-				local.blockIndexes = append(local.blockIndexes[:0], update.BlockIndexes...)
+				local.blocks.Clear()
 				local.version = update.Version
-			} else {
-				local.blockIndexes = append(local.blockIndexes, update.BlockIndexes...)
+			}
+			for _, index := range update.BlockIndexes {
+				if index >= 0 {
+					local.blocks.Add(uint32(index))
+				}
 			}
 			p.files[update.Name] = local
 		}
@@ -79,7 +79,7 @@ func (p *deviceFolderDownloadState) GetBlockCounts() map[string]int {
 	p.mut.RLock()
 	res := make(map[string]int, len(p.files))
 	for name, state := range p.files {
-		res[name] = len(state.blockIndexes)
+		res[name] = state.blocks.Len()
 	}
 	p.mut.RUnlock()
 	return res
@@ -7,6 +7,7 @@
 package model
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/syncthing/syncthing/lib/protocol"
@@ -109,3 +110,33 @@ func TestDeviceDownloadState(t *testing.T) {
 		}
 	}
 }
+
+// BenchmarkDeviceDownloadState tracks a thousand files of a thousand blocks
+// each being downloaded, in updates of ten blocks; the B/op show the
+// memory it takes.
+func BenchmarkDeviceDownloadState(b *testing.B) {
+	v1 := (protocol.Vector{}).Update(0)
+	var updates []protocol.FileDownloadProgressUpdate
+	for block := int32(0); block < 1000; block += 10 {
+		for file := 0; file < 1000; file++ {
+			updates = append(updates, protocol.FileDownloadProgressUpdate{
+				UpdateType:   protocol.UpdateTypeAppend,
+				Name:         fmt.Sprintf("file%d", file),
+				Version:      v1,
+				BlockIndexes: []int32{block, block + 1, block + 2, block + 3, block + 4, block + 5, block + 6, block + 7, block + 8, block + 9},
+			})
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := newDeviceDownloadState()
+		for j := 0; j < len(updates); j += 1000 {
+			s.Update("folder", updates[j:j+1000])
+		}
+		if !s.Has("folder", "file999", v1, 999) {
+			b.Fatal("Block missing")
+		}
+	}
+}
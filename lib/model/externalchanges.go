@@ -0,0 +1,139 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// External change feeds
+//
+// An application that already knows what changed in a folder, such as a
+// photo library keeping its own database or a watcher on another host,
+// can tell us rather than leave it to the next scan. The changed paths are
+// collected for a while, so that a burst of them is scanned at once, and
+// are then scanned as those from the change journal are: each by itself,
+// walking only directories that are new.
+
+// How long to collect changes for before scanning them.
+const externalChangesDelay = 10 * time.Second
+
+var errInvalidChange = errors.New("invalid changed path")
+
+// changeQueue collects the changed paths per folder until they're due to
+// be scanned.
+type changeQueue struct {
+	delay   time.Duration
+	scan    func(folder string, changes []string)
+	pending map[string]map[string]struct{} // folder -> changed paths
+	timers  map[string]*time.Timer         // folder -> when to scan them
+	mut     sync.Mutex
+}
+
+func newChangeQueue(delay time.Duration, scan func(folder string, changes []string)) *changeQueue {
+	return &changeQueue{
+		delay:   delay,
+		scan:    scan,
+		pending: make(map[string]map[string]struct{}),
+		timers:  make(map[string]*time.Timer),
+		mut:     sync.NewMutex(),
+	}
+}
+
+// add queues the changed paths, to be scanned when the delay since the
+// first change queued for the folder has passed.
+func (q *changeQueue) add(folder string, changes []string) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	pending, ok := q.pending[folder]
+	if !ok {
+		pending = make(map[string]struct{})
+		q.pending[folder] = pending
+	}
+	for _, change := range changes {
+		pending[change] = struct{}{}
+	}
+
+	if _, ok := q.timers[folder]; !ok {
+		q.timers[folder] = time.AfterFunc(q.delay, func() {
+			q.flush(folder)
+		})
+	}
+}
+
+// flush scans the changes queued for the folder right away.
+func (q *changeQueue) flush(folder string) {
+	q.mut.Lock()
+	pending := q.pending[folder]
+	if timer, ok := q.timers[folder]; ok {
+		timer.Stop()
+	}
+	delete(q.pending, folder)
+	delete(q.timers, folder)
+	q.mut.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+	changes := make([]string, 0, len(pending))
+	for change := range pending {
+		changes = append(changes, change)
+	}
+	q.scan(folder, changes)
+}
+
+// QueueChanges queues the paths, relative to the folder, as changed
+// outside of Syncthing. They are scanned shortly, without walking the rest
+// of the folder.
+func (m *Model) QueueChanges(folder string, changes []string) error {
+	m.fmut.RLock()
+	_, okRunner := m.folderRunners[folder]
+	cfg, okCfg := m.folderCfgs[folder]
+	m.fmut.RUnlock()
+
+	if !okRunner {
+		if okCfg && cfg.Paused {
+			return errFolderPaused
+		}
+		return errFolderMissing
+	}
+
+	cleaned := make([]string, len(changes))
+	for i, change := range changes {
+		change = strings.TrimRight(osutil.NativeFilename(change), string(os.PathSeparator))
+		if _, err := rootedJoinedPath("root", change); err != nil && change != "" {
+			return errInvalidChange
+		}
+		cleaned[i] = change
+	}
+
+	l.Debugln("queueing", len(cleaned), "external changes for", folder)
+	m.externalChanges.add(folder, cleaned)
+	return nil
+}
+
+// scanExternalChanges scans the queued changes for the folder.
+func (m *Model) scanExternalChanges(folder string, changes []string) {
+	m.fmut.RLock()
+	runner, ok := m.folderRunners[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		l.Debugln("dropping", len(changes), "external changes for removed folder", folder)
+		return
+	}
+
+	if err := runner.ScanChanges(changes); err != nil {
+		l.Infof("Scanning external changes in folder %q: %v", folder, err)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"sort"
+	"syscall"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// The classes of errors syncing a file, by which they're grouped.
+const (
+	errorClassPermission  = "permission"
+	errorClassNotExist    = "notExist"
+	errorClassExist       = "exist"
+	errorClassNoSpace     = "noSpace"
+	errorClassInvalidName = "invalidName"
+	errorClassUnsupported = "unsupported"
+	errorClassUnavailable = "unavailable"
+	errorClassWriteOnce   = "writeOnce"
+	errorClassOther       = "other"
+)
+
+// A FileError is the error syncing a file. A []FileError is sent as part
+// of an event and will be JSON serialized.
+type FileError struct {
+	ID      string `json:"id"` // stable for as long as the file fails to sync
+	Path    string `json:"path"`
+	Err     string `json:"error"`
+	Class   string `json:"class"`
+	Ignored bool   `json:"ignored"` // ignored until the file changes
+}
+
+type ignoredFileError struct {
+	FileError
+	version protocol.Vector // the version of the file the error is ignored for
+}
+
+func newFileError(path string, err error) FileError {
+	return FileError{
+		ID:    fileErrorID(path),
+		Path:  path,
+		Err:   err.Error(),
+		Class: errorClass(err),
+	}
+}
+
+func fileErrorID(path string) string {
+	hash := sha256.Sum256([]byte(path))
+	return hex.EncodeToString(hash[:6])
+}
+
+// errorClass returns the class of the error syncing a file.
+func errorClass(err error) string {
+	switch err {
+	case errInvalidFilename:
+		return errorClassInvalidName
+	case errSymlinksUnsupported:
+		return errorClassUnsupported
+	case errNoDevice:
+		return errorClassUnavailable
+	case errWriteOnce:
+		return errorClassWriteOnce
+	case errFolderNoSpace, errHomeDiskNoSpace, errInsufficientSpace, errQuotaExceeded:
+		return errorClassNoSpace
+	}
+
+	switch {
+	case os.IsPermission(err):
+		return errorClassPermission
+	case os.IsNotExist(err):
+		return errorClassNotExist
+	case os.IsExist(err):
+		return errorClassExist
+	}
+
+	switch err := err.(type) {
+	case *os.PathError:
+		if err.Err == syscall.ENOSPC {
+			return errorClassNoSpace
+		}
+	case *os.LinkError:
+		if err.Err == syscall.ENOSPC {
+			return errorClassNoSpace
+		}
+	}
+	return errorClassOther
+}
+
+// Errors returns the errors of the last pull, and those ignored until the
+// files change, by path.
+func (f *sendReceiveFolder) Errors() []FileError {
+	f.errorsMut.Lock()
+	errors := make([]FileError, 0, len(f.errors)+len(f.ignoredErrors))
+	for _, err := range f.errors {
+		errors = append(errors, err)
+	}
+	for _, err := range f.ignoredErrors {
+		errors = append(errors, err.FileError)
+	}
+	f.errorsMut.Unlock()
+
+	sort.Sort(fileErrorList(errors))
+	return errors
+}
+
+// RetryErrors forgets the errors with the given IDs, including ignored
+// ones, and pulls right away, those files first.
+func (f *sendReceiveFolder) RetryErrors(ids []string) {
+	f.errorsMut.Lock()
+	for _, id := range ids {
+		for path, err := range f.errors {
+			if err.ID == id {
+				delete(f.errors, path)
+				f.retryFirst[path] = true
+			}
+		}
+		for path, err := range f.ignoredErrors {
+			if err.ID == id {
+				delete(f.ignoredErrors, path)
+				f.retryFirst[path] = true
+			}
+		}
+	}
+	f.errorsMut.Unlock()
+
+	f.IndexUpdated()
+}
+
+// IgnoreErrors stops retrying the files with the errors with the given IDs
+// until they change, that is until there's a new version of them to pull.
+func (f *sendReceiveFolder) IgnoreErrors(ids []string) {
+	f.errorsMut.Lock()
+	defer f.errorsMut.Unlock()
+
+	for _, id := range ids {
+		for path, err := range f.errors {
+			if err.ID != id {
+				continue
+			}
+			file, ok := f.model.CurrentGlobalFile(f.folderID, path)
+			if !ok {
+				continue
+			}
+			err.Ignored = true
+			f.ignoredErrors[path] = ignoredFileError{FileError: err, version: file.Version}
+			delete(f.errors, path)
+		}
+	}
+}
+
+// errorIgnored returns whether the error syncing the file is ignored, as
+// it's the same version as when the error was ignored.
+func (f *sendReceiveFolder) errorIgnored(file protocol.FileInfo) bool {
+	f.errorsMut.Lock()
+	defer f.errorsMut.Unlock()
+
+	err, ok := f.ignoredErrors[file.Name]
+	if !ok {
+		return false
+	}
+	if !file.Version.Equal(err.version) {
+		delete(f.ignoredErrors, file.Name)
+		return false
+	}
+	return true
+}
+
+// takeRetries returns the files retried since the last call.
+func (f *sendReceiveFolder) takeRetries() map[string]bool {
+	f.errorsMut.Lock()
+	defer f.errorsMut.Unlock()
+
+	retry := f.retryFirst
+	f.retryFirst = make(map[string]bool)
+	return retry
+}
@@ -6,13 +6,20 @@
 
 package model
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+var errFolderScheduledPause = errors.New("folder is paused by its schedule")
 
 type folder struct {
 	stateTracker
-	scan  folderScanner
-	model *Model
-	stop  chan struct{}
+	scan     folderScanner
+	schedule folderSchedule
+	journal  folderJournal
+	model    *Model
+	stop     chan struct{}
 }
 
 func (f *folder) IndexUpdated() {
@@ -25,6 +32,11 @@ func (f *folder) DelayScan(next time.Duration) {
 func (f *folder) Scan(subdirs []string) error {
 	return f.scan.Scan(subdirs)
 }
+
+func (f *folder) ScanChanges(changes []string) error {
+	return f.scan.ScanChanges(changes)
+}
+
 func (f *folder) Stop() {
 	close(f.stop)
 }
@@ -35,13 +47,31 @@ func (f *folder) Jobs() ([]string, []string) {
 
 func (f *folder) BringToFront(string) {}
 
-func (f *folder) scanSubdirsIfHealthy(subDirs []string) error {
+func (f *folder) BringMatchingToFront(func(string) bool) {}
+
+func (f *folder) Errors() []FileError {
+	return nil
+}
+
+func (f *folder) RetryErrors([]string) {}
+
+func (f *folder) IgnoreErrors([]string) {}
+
+func (f *folder) scanSubdirsIfHealthy(subDirs []string, changes bool) error {
+	if f.schedule.Paused() {
+		l.Debugln(f, "skipping scan, paused by schedule")
+		return errFolderScheduledPause
+	}
 	if err := f.model.CheckFolderHealth(f.folderID); err != nil {
 		l.Infoln("Skipping folder", f.folderID, "scan due to folder error:", err)
 		return err
 	}
 	l.Debugln(f, "Scanning subdirectories")
-	if err := f.model.internalScanFolderSubdirs(f.folderID, subDirs); err != nil {
+	scan := f.scanSubdirs
+	if changes {
+		scan = f.scanChanges
+	}
+	if err := scan(subDirs); err != nil {
 		// Potentially sets the error twice, once in the scanner just
 		// by doing a check, and once here, if the error returned is
 		// the same one as returned by CheckFolderHealth, though
@@ -51,3 +81,39 @@ func (f *folder) scanSubdirsIfHealthy(subDirs []string) error {
 	}
 	return nil
 }
+
+// scanSubdirs scans the subdirectories, or the whole folder if there are
+// none. The whole folder is scanned by what the change journal lists as
+// changed since the last time, when it can be used.
+func (f *folder) scanSubdirs(subDirs []string) error {
+	if len(subDirs) > 0 {
+		return f.model.internalScanFolderSubdirs(f.folderID, subDirs)
+	}
+
+	if changes, next, ok := f.journal.Changes(); ok {
+		l.Debugln(f, "scanning", len(changes), "changes from the journal")
+		if err := f.model.internalScanFolderChanges(f.folderID, changes); err != nil {
+			return err
+		}
+		f.journal.Advance(next)
+		return nil
+	}
+
+	cursor, ok := f.journal.Cursor()
+	if err := f.model.internalScanFolderSubdirs(f.folderID, nil); err != nil {
+		return err
+	}
+	if ok {
+		f.journal.Advance(cursor)
+	}
+	return nil
+}
+
+// scanChanges scans the changed paths, or walks the folder if there are
+// too many of them or the ignore patterns changed.
+func (f *folder) scanChanges(changes []string) error {
+	if !changesNeedWalk(f.folderID, changes) {
+		return f.model.internalScanFolderChanges(f.folderID, changes)
+	}
+	return f.model.internalScanFolderSubdirs(f.folderID, nil)
+}
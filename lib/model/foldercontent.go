@@ -0,0 +1,323 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+const (
+	maxLargestFiles = 100 // kept by the folder content statistics
+	maxChurnDays    = 90  // of churn kept
+)
+
+// The upper bounds of the file size classes of the histogram, the last
+// class being everything bigger.
+var sizeClasses = []int64{1 << 10, 64 << 10, 1 << 20, 16 << 20, 256 << 20, 4 << 30}
+
+// FolderContentStatistics describes what's in the local copy of a folder
+// and how much of it changes.
+type FolderContentStatistics struct {
+	Files      int              `json:"files"`
+	Bytes      int64            `json:"bytes"`
+	Sizes      []SizeClass      `json:"sizes"`      // histogram of the file sizes
+	Extensions []ExtensionCount `json:"extensions"` // the most bytes first
+	Largest    []LargestFile    `json:"largest"`    // the largest first
+	Churn      []ChurnDay       `json:"churn"`      // the latest day first
+	Since      time.Time        `json:"since"`      // when the churn started being counted
+}
+
+// A SizeClass is the files of at least MinBytes and less than MaxBytes,
+// or of any bigger size if MaxBytes is zero.
+type SizeClass struct {
+	MinBytes int64 `json:"minBytes"`
+	MaxBytes int64 `json:"maxBytes"`
+	Files    int   `json:"files"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// An ExtensionCount is the files with the extension, in lower case and
+// empty for those without one.
+type ExtensionCount struct {
+	Extension string `json:"extension"`
+	Files     int    `json:"files"`
+	Bytes     int64  `json:"bytes"`
+}
+
+type LargestFile struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// A ChurnDay is the changes to the files of the folder during a day, local
+// time, both those made here and those pulled from other devices. Bytes
+// are those of the changed files.
+type ChurnDay struct {
+	Date    string `json:"date"`
+	Files   int    `json:"files"`
+	Bytes   int64  `json:"bytes"`
+	Deleted int    `json:"deleted"`
+}
+
+// A folderContent keeps the statistics about a folder. Those about its
+// files are counted from the local index the first time they're asked
+// for, and after that kept up to date with each change to the index. The
+// churn is counted from when the folder is started, for the last
+// maxChurnDays days.
+//
+// Only the largest files are kept, so when one of them shrinks or goes
+// away the next largest isn't known. Then the statistics are counted from
+// the index again, the next time they're asked for.
+type folderContent struct {
+	mut     sync.Mutex
+	loaded  bool
+	files   int
+	bytes   int64
+	sizes   []SizeClass
+	exts    map[string]*ExtensionCount
+	largest []LargestFile // the largest first
+	churn   []ChurnDay    // the latest day first
+	since   time.Time
+}
+
+func newFolderContent(now time.Time) *folderContent {
+	return &folderContent{
+		mut:   sync.NewMutex(),
+		since: now,
+	}
+}
+
+// update counts the changes to the local index, calling fn to make them.
+// The previous versions of the changed files are looked up, before the
+// change, if the statistics about the files are being kept.
+func (c *folderContent) update(files *db.FileSet, fs []protocol.FileInfo, now time.Time, fn func()) {
+	if c == nil {
+		fn()
+		return
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.loaded {
+		for _, f := range fs {
+			if cur, ok := files.Get(protocol.LocalDeviceID, f.Name); ok {
+				c.remove(cur)
+			}
+		}
+	}
+	fn()
+	if c.loaded {
+		for _, f := range fs {
+			c.add(f, f.ModTime())
+		}
+	}
+
+	date := now.Format("2006-01-02")
+	if len(c.churn) == 0 || c.churn[0].Date != date {
+		c.churn = append([]ChurnDay{{Date: date}}, c.churn...)
+		if len(c.churn) > maxChurnDays {
+			c.churn = c.churn[:maxChurnDays]
+		}
+	}
+	for _, f := range fs {
+		switch {
+		case f.IsDirectory() || f.IsSymlink():
+		case f.IsDeleted():
+			c.churn[0].Deleted++
+		default:
+			c.churn[0].Files++
+			c.churn[0].Bytes += f.Size
+		}
+	}
+}
+
+// statistics returns the statistics, counting them from the local index
+// first if need be. At most the given number of largest files and days of
+// churn are returned.
+func (c *folderContent) statistics(files *db.FileSet, largest, days int) FolderContentStatistics {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if !c.loaded {
+		c.load(files)
+	}
+
+	stats := FolderContentStatistics{
+		Files:      c.files,
+		Bytes:      c.bytes,
+		Sizes:      make([]SizeClass, len(c.sizes)),
+		Extensions: make([]ExtensionCount, 0, len(c.exts)),
+		Since:      c.since,
+	}
+	copy(stats.Sizes, c.sizes)
+	for _, ext := range c.exts {
+		stats.Extensions = append(stats.Extensions, *ext)
+	}
+	sort.Sort(extensionsByBytes(stats.Extensions))
+	if largest > len(c.largest) {
+		largest = len(c.largest)
+	}
+	stats.Largest = make([]LargestFile, largest)
+	copy(stats.Largest, c.largest)
+	if days > len(c.churn) {
+		days = len(c.churn)
+	}
+	stats.Churn = make([]ChurnDay, days)
+	copy(stats.Churn, c.churn)
+	return stats
+}
+
+func (c *folderContent) load(files *db.FileSet) {
+	c.files, c.bytes = 0, 0
+	c.sizes = make([]SizeClass, len(sizeClasses)+1)
+	var min int64
+	for i, max := range sizeClasses {
+		c.sizes[i] = SizeClass{MinBytes: min, MaxBytes: max}
+		min = max
+	}
+	c.sizes[len(sizeClasses)] = SizeClass{MinBytes: min}
+	c.exts = make(map[string]*ExtensionCount)
+	c.largest = nil
+
+	files.WithHaveTruncated(protocol.LocalDeviceID, func(fi db.FileIntf) bool {
+		f := fi.(db.FileInfoTruncated)
+		c.add(f, f.ModTime())
+		return true
+	})
+	c.loaded = true
+}
+
+// add counts the file, if it's one that's on disk.
+func (c *folderContent) add(f db.FileIntf, modified time.Time) {
+	if !onDisk(f) {
+		return
+	}
+	size := f.FileSize()
+	c.files++
+	c.bytes += size
+	class := c.sizeClass(size)
+	class.Files++
+	class.Bytes += size
+	ext := c.extension(f.FileName())
+	ext.Files++
+	ext.Bytes += size
+
+	if len(c.largest) == maxLargestFiles && size <= c.largest[len(c.largest)-1].Size {
+		return
+	}
+	i := sort.Search(len(c.largest), func(i int) bool {
+		return c.largest[i].Size < size
+	})
+	c.largest = append(c.largest, LargestFile{})
+	copy(c.largest[i+1:], c.largest[i:])
+	c.largest[i] = LargestFile{Name: f.FileName(), Size: size, Modified: modified}
+	if len(c.largest) > maxLargestFiles {
+		c.largest = c.largest[:maxLargestFiles]
+	}
+}
+
+// remove stops counting the file, the previous version of one that changed.
+func (c *folderContent) remove(f db.FileIntf) {
+	if !onDisk(f) {
+		return
+	}
+	size := f.FileSize()
+	c.files--
+	c.bytes -= size
+	class := c.sizeClass(size)
+	class.Files--
+	class.Bytes -= size
+	ext := c.extension(f.FileName())
+	ext.Files--
+	ext.Bytes -= size
+	if ext.Files == 0 {
+		delete(c.exts, ext.Extension)
+	}
+
+	for i, largest := range c.largest {
+		if largest.Name == f.FileName() {
+			if len(c.largest) == maxLargestFiles {
+				// The next largest file isn't known
+				c.loaded = false
+			}
+			c.largest = append(c.largest[:i], c.largest[i+1:]...)
+			break
+		}
+	}
+}
+
+func (c *folderContent) sizeClass(size int64) *SizeClass {
+	for i, max := range sizeClasses {
+		if size < max {
+			return &c.sizes[i]
+		}
+	}
+	return &c.sizes[len(sizeClasses)]
+}
+
+func (c *folderContent) extension(name string) *ExtensionCount {
+	key := strings.ToLower(filepath.Ext(name))
+	ext, ok := c.exts[key]
+	if !ok {
+		ext = &ExtensionCount{Extension: key}
+		c.exts[key] = ext
+	}
+	return ext
+}
+
+// onDisk returns true for the files, as opposed to directories and
+// symlinks, that we have a copy of.
+func onDisk(f db.FileIntf) bool {
+	if f.IsDeleted() || f.IsInvalid() || f.IsDirectory() || f.IsSymlink() {
+		return false
+	}
+	if p, ok := f.(interface {
+		IsPlaceholder() bool
+	}); ok && p.IsPlaceholder() {
+		return false
+	}
+	return true
+}
+
+type extensionsByBytes []ExtensionCount
+
+func (l extensionsByBytes) Len() int {
+	return len(l)
+}
+
+func (l extensionsByBytes) Swap(a, b int) {
+	l[a], l[b] = l[b], l[a]
+}
+
+func (l extensionsByBytes) Less(a, b int) bool {
+	if l[a].Bytes != l[b].Bytes {
+		return l[a].Bytes > l[b].Bytes
+	}
+	return l[a].Extension < l[b].Extension
+}
+
+// FolderContentStatistics returns statistics about what's in the local copy
+// of the folder and how much of it changes, with at most the given number
+// of largest files and days of churn.
+func (m *Model) FolderContentStatistics(folder string, largest, days int) (FolderContentStatistics, error) {
+	m.fmut.RLock()
+	files, ok := m.folderFiles[folder]
+	content := m.folderContents[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return FolderContentStatistics{}, errFolderMissing
+	}
+	return content.statistics(files, largest, days), nil
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestFolderContent(t *testing.T) {
+	files := db.NewFileSet("default", db.OpenMemory())
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.Local)
+	c := newFolderContent(now)
+	var version protocol.Vector
+	update := func(fs ...protocol.FileInfo) {
+		for i := range fs {
+			version = version.Update(protocol.LocalDeviceID.Short())
+			fs[i].Version = version
+		}
+		c.update(files, fs, now, func() {
+			files.Update(protocol.LocalDeviceID, fs)
+		})
+	}
+
+	update(
+		protocol.FileInfo{Name: "a.txt", Size: 100},
+		protocol.FileInfo{Name: "b.TXT", Size: 2 << 20},
+		protocol.FileInfo{Name: "c", Size: 10 << 10},
+		protocol.FileInfo{Name: "dir", Type: protocol.FileInfoTypeDirectory},
+		protocol.FileInfo{Name: "gone.txt", Deleted: true},
+	)
+
+	stats := c.statistics(files, 2, 30)
+	if stats.Files != 3 || stats.Bytes != 100+2<<20+10<<10 {
+		t.Errorf("Unexpected totals %d files, %d bytes", stats.Files, stats.Bytes)
+	}
+	if len(stats.Sizes) != len(sizeClasses)+1 || stats.Sizes[0].Files != 1 || stats.Sizes[1].Files != 1 || stats.Sizes[3].Files != 1 {
+		t.Errorf("Unexpected size histogram %+v", stats.Sizes)
+	}
+	if len(stats.Extensions) != 2 || stats.Extensions[0].Extension != ".txt" || stats.Extensions[0].Files != 2 || stats.Extensions[1].Extension != "" {
+		t.Errorf("Unexpected extensions %+v", stats.Extensions)
+	}
+	if len(stats.Largest) != 2 || stats.Largest[0].Name != "b.TXT" || stats.Largest[1].Name != "c" {
+		t.Errorf("Unexpected largest files %+v", stats.Largest)
+	}
+	if len(stats.Churn) != 1 || stats.Churn[0] != (ChurnDay{Date: "2017-06-01", Files: 3, Bytes: 100 + 2<<20 + 10<<10, Deleted: 1}) {
+		t.Errorf("Unexpected churn %+v", stats.Churn)
+	}
+
+	// Changes are counted as they're made, the next day too
+
+	now = now.Add(24 * time.Hour)
+	update(
+		protocol.FileInfo{Name: "a.txt", Size: 200},
+		protocol.FileInfo{Name: "b.TXT", Deleted: true},
+	)
+
+	stats = c.statistics(files, 10, 30)
+	if stats.Files != 2 || stats.Bytes != 200+10<<10 {
+		t.Errorf("Unexpected totals %d files, %d bytes", stats.Files, stats.Bytes)
+	}
+	if len(stats.Extensions) != 2 || stats.Extensions[0].Extension != "" || stats.Extensions[1].Bytes != 200 {
+		t.Errorf("Unexpected extensions %+v", stats.Extensions)
+	}
+	if len(stats.Largest) != 2 || stats.Largest[0].Name != "c" || stats.Largest[1].Size != 200 {
+		t.Errorf("Unexpected largest files %+v", stats.Largest)
+	}
+	if len(stats.Churn) != 2 || stats.Churn[0] != (ChurnDay{Date: "2017-06-02", Files: 1, Bytes: 200, Deleted: 1}) {
+		t.Errorf("Unexpected churn %+v", stats.Churn)
+	}
+
+	// With more files than are kept as the largest, removing one of those
+	// has the others counted again
+
+	var many []protocol.FileInfo
+	for i := 0; i < maxLargestFiles+1; i++ {
+		many = append(many, protocol.FileInfo{Name: fmt.Sprintf("big%d", i), Size: int64(1<<20 + i)})
+	}
+	update(many...)
+	update(protocol.FileInfo{Name: fmt.Sprintf("big%d", maxLargestFiles), Deleted: true})
+	if c.loaded {
+		t.Error("Expected the statistics to need counting again")
+	}
+	stats = c.statistics(files, maxLargestFiles, 0)
+	if len(stats.Largest) != maxLargestFiles || stats.Largest[0].Name != fmt.Sprintf("big%d", maxLargestFiles-1) || stats.Largest[maxLargestFiles-1].Name != "big0" {
+		t.Errorf("Unexpected largest files %+v", stats.Largest[0])
+	}
+	if len(stats.Churn) != 0 {
+		t.Errorf("Expected no churn, got %+v", stats.Churn)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"path/filepath"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+// Above this many changes we might as well walk the folder.
+const maxJournalChanges = 100000
+
+// bundle the use of the filesystem's change journal to rescan folders
+type folderJournal struct {
+	cfg     config.FolderConfiguration
+	journal fs.ChangeJournal // opened on first use
+	failed  bool             // the journal can't be used
+	cursor  fs.JournalCursor // as of the last completed scan
+	valid   bool             // cursor is set
+}
+
+func newFolderJournal(cfg config.FolderConfiguration) folderJournal {
+	return folderJournal{
+		cfg: cfg,
+	}
+}
+
+// Changes returns the paths changed since the last completed scan, and
+// the cursor to Advance to once they're scanned. When the folder needs to
+// be walked instead, ok is false.
+func (j *folderJournal) Changes() (changes []string, next fs.JournalCursor, ok bool) {
+	if j.journal == nil || !j.valid {
+		return nil, next, false
+	}
+
+	changes, next, err := j.journal.Changes(j.cursor)
+	if err != nil {
+		l.Infof("Walking %s, as its changes couldn't be read: %v", j.cfg.Description(), err)
+		j.valid = false
+		return nil, next, false
+	}
+	if changesNeedWalk(j.cfg.Description(), changes) {
+		return nil, next, false
+	}
+	return changes, next, true
+}
+
+// changesNeedWalk returns whether the folder should be walked rather than
+// have just the changed paths scanned.
+func changesNeedWalk(folder string, changes []string) bool {
+	if len(changes) > maxJournalChanges {
+		l.Debugln(folder, "has", len(changes), "changes, walking it instead")
+		return true
+	}
+	for _, change := range changes {
+		if change == "" {
+			// The folder itself.
+			return true
+		}
+		if filepath.Base(change) == ".stignore" {
+			// Files may have become unignored anywhere.
+			l.Debugln(folder, "ignore patterns changed, walking it")
+			return true
+		}
+	}
+	return false
+}
+
+// Cursor returns the current position in the journal, to Advance to once
+// the folder has been walked. When the journal can't be used, ok is false.
+func (j *folderJournal) Cursor() (cursor fs.JournalCursor, ok bool) {
+	if !j.cfg.UseChangeJournal || j.failed {
+		return cursor, false
+	}
+	if j.journal == nil {
+		journal, err := fs.OpenChangeJournal(j.cfg.Path())
+		if err != nil {
+			l.Infof("Not using the change journal for %s: %v", j.cfg.Description(), err)
+			j.failed = true
+			return cursor, false
+		}
+		j.journal = journal
+	}
+
+	cursor, err := j.journal.Cursor()
+	if err != nil {
+		l.Debugln(j.cfg.Description(), "change journal:", err)
+		return cursor, false
+	}
+	return cursor, true
+}
+
+// Advance records that the folder has been scanned up to the cursor.
+func (j *folderJournal) Advance(cursor fs.JournalCursor) {
+	j.cursor = cursor
+	j.valid = true
+}
+
+func (j *folderJournal) Close() {
+	if j.journal != nil {
+		j.journal.Close()
+		j.journal = nil
+		j.valid = false
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
+)
+
+// A folderLimiter limits the rate at which block data is sent and received
+// for a folder. This comes on top of the global limits, which apply to the
+// connections as a whole and know nothing about folders. Unlike those, the
+// folder limits apply regardless of whether the other device is on the LAN.
+// The folder's disk IO is limited too, separately.
+type folderLimiter struct {
+	send *rate.Limiter
+	recv *rate.Limiter
+	disk *fs.IOThrottle // nil when unlimited
+}
+
+const folderLimiterBurstSize = 4 * 128 << 10
+
+func newFolderLimiter(cfg config.FolderConfiguration) *folderLimiter {
+	return &folderLimiter{
+		send: newKbpsLimiter(cfg.MaxSendKbps),
+		recv: newKbpsLimiter(cfg.MaxRecvKbps),
+		disk: fs.NewIOThrottle(cfg.MaxDiskReadKbps, cfg.MaxDiskWriteKbps, cfg.MaxDiskReadIOPS, cfg.MaxDiskWriteIOPS),
+	}
+}
+
+func newKbpsLimiter(kbps int) *rate.Limiter {
+	if kbps <= 0 {
+		return rate.NewLimiter(rate.Inf, folderLimiterBurstSize)
+	}
+	return rate.NewLimiter(1024*rate.Limit(kbps), folderLimiterBurstSize)
+}
+
+// waitSend blocks until the given number of bytes may be sent.
+func (l *folderLimiter) waitSend(bytes int) {
+	take(l.send, bytes)
+}
+
+// waitRecv blocks until the given number of bytes may be received.
+func (l *folderLimiter) waitRecv(bytes int) {
+	take(l.recv, bytes)
+}
+
+// diskThrottle returns the disk IO throttle, if any.
+func (l *folderLimiter) diskThrottle() *fs.IOThrottle {
+	if l == nil {
+		return nil
+	}
+	return l.disk
+}
+
+// take consumes tokens from the limiter. No call to WaitN can be larger
+// than the burst size, so we split it up into several calls when
+// necessary.
+func take(l *rate.Limiter, tokens int) {
+	for tokens > 0 {
+		n := tokens
+		if n > folderLimiterBurstSize {
+			n = folderLimiterBurstSize
+		}
+		l.WaitN(context.TODO(), n)
+		tokens -= n
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+func TestFolderLimiter(t *testing.T) {
+	cfg := config.FolderConfiguration{MaxSendKbps: 1024}
+	lim := newFolderLimiter(cfg)
+
+	// Receiving is unlimited, sending starts with a full burst.
+	t0 := time.Now()
+	lim.waitRecv(16 << 20)
+	lim.waitSend(folderLimiterBurstSize)
+	if d := time.Since(t0); d > 100*time.Millisecond {
+		t.Fatal("Unexpected wait of", d)
+	}
+
+	// Then sending another 256 KiB at 1024 KiB/s takes a quarter second.
+	t0 = time.Now()
+	lim.waitSend(256 << 10)
+	if d := time.Since(t0); d < 200*time.Millisecond {
+		t.Error("Sending was not limited, took", d)
+	}
+}
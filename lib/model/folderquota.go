@@ -0,0 +1,88 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// A folderQuota keeps the local copy of a folder within its maximum size,
+// by having the puller leave out the files that would take it over. The
+// size is that of the files in the local index, so files being pulled
+// count once they're done.
+type folderQuota struct {
+	max int64 // bytes, zero for no quota
+
+	mut      sync.Mutex
+	budget   int64 // how much the folder may still grow during this pull
+	denied   int   // files left out of this pull
+	exceeded bool  // files were left out of the last pull
+}
+
+func newFolderQuota(cfg config.FolderConfiguration) *folderQuota {
+	return &folderQuota{
+		max: cfg.MaxFolderSize,
+		mut: sync.NewMutex(),
+	}
+}
+
+// start is called when a pull starts, with the current size of the folder.
+func (q *folderQuota) start(size int64) {
+	if q == nil || q.max <= 0 {
+		return
+	}
+	q.mut.Lock()
+	q.budget = q.max - size
+	q.denied = 0
+	q.mut.Unlock()
+}
+
+// allow returns whether a file that grows the folder by the given number
+// of bytes may be pulled, and if so takes them from the budget. Files that
+// don't grow the folder are always allowed, but what they free up isn't
+// available until the next pull.
+func (q *folderQuota) allow(growth int64) bool {
+	if q == nil || q.max <= 0 || growth <= 0 {
+		return true
+	}
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	if growth > q.budget {
+		q.denied++
+		return false
+	}
+	q.budget -= growth
+	return true
+}
+
+// finish is called when a pull is done. It returns the number of files
+// left out, if that's the first pull to do so since the last that didn't.
+func (q *folderQuota) finish() (newlyDenied int) {
+	if q == nil || q.max <= 0 {
+		return 0
+	}
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	wasExceeded := q.exceeded
+	q.exceeded = q.denied > 0
+	if q.exceeded && !wasExceeded {
+		return q.denied
+	}
+	return 0
+}
+
+// Exceeded returns whether the last pull left out files to stay within the
+// quota.
+func (q *folderQuota) Exceeded() bool {
+	if q == nil {
+		return false
+	}
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	return q.exceeded
+}
@@ -0,0 +1,61 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+func TestFolderQuota(t *testing.T) {
+	q := newFolderQuota(config.FolderConfiguration{MaxFolderSize: 1000})
+
+	// With 600 bytes in the folder, 400 more fit, in whatever files.
+	q.start(600)
+	if !q.allow(300) || !q.allow(100) {
+		t.Fatal("Files within the quota should be allowed")
+	}
+	if q.allow(1) {
+		t.Error("A file over the quota should not be allowed")
+	}
+	if !q.allow(0) || !q.allow(-500) {
+		t.Error("Files that don't grow the folder should be allowed")
+	}
+	if denied := q.finish(); denied != 1 || !q.Exceeded() {
+		t.Errorf("Expected the quota exceeded with one file denied, got %d", denied)
+	}
+
+	// Still over the quota isn't reported again, but once it's not the
+	// next time is.
+	q.start(1000)
+	q.allow(1)
+	if denied := q.finish(); denied != 0 || !q.Exceeded() {
+		t.Errorf("Expected the quota still exceeded, not reported again, got %d", denied)
+	}
+	q.start(500)
+	q.allow(1)
+	if q.finish(); q.Exceeded() {
+		t.Error("The quota should not be exceeded")
+	}
+	q.start(1000)
+	q.allow(1)
+	if denied := q.finish(); denied != 1 {
+		t.Errorf("Expected the quota exceeded again, got %d", denied)
+	}
+
+	var unlimited *folderQuota
+	unlimited.start(1 << 40)
+	if !unlimited.allow(1<<40) || unlimited.finish() != 0 || unlimited.Exceeded() {
+		t.Error("A nil quota should not limit anything")
+	}
+	unlimited = newFolderQuota(config.FolderConfiguration{})
+	unlimited.start(1 << 40)
+	if !unlimited.allow(1 << 40) {
+		t.Error("A zero quota should not limit anything")
+	}
+}
@@ -14,20 +14,23 @@ import (
 
 type rescanRequest struct {
 	subdirs []string
+	changes bool // subdirs are changed paths, to scan without walking
 	err     chan error
 }
 
 // bundle all folder scan activity
 type folderScanner struct {
 	interval time.Duration
+	factor   func() int // multiplies the interval, as the power profile says
 	timer    *time.Timer
 	now      chan rescanRequest
 	delay    chan time.Duration
 }
 
-func newFolderScanner(config config.FolderConfiguration) folderScanner {
+func newFolderScanner(config config.FolderConfiguration, factor func() int) folderScanner {
 	return folderScanner{
 		interval: time.Duration(config.RescanIntervalS) * time.Second,
+		factor:   factor,
 		timer:    time.NewTimer(time.Millisecond), // The first scan should be done immediately.
 		now:      make(chan rescanRequest),
 		delay:    make(chan time.Duration),
@@ -38,9 +41,13 @@ func (f *folderScanner) Reschedule() {
 	if f.interval == 0 {
 		return
 	}
+	interval := f.interval
+	if f.factor != nil {
+		interval *= time.Duration(f.factor())
+	}
 	// Sleep a random time between 3/4 and 5/4 of the configured interval.
-	sleepNanos := (f.interval.Nanoseconds()*3 + rand.Int63n(2*f.interval.Nanoseconds())) / 4
-	interval := time.Duration(sleepNanos) * time.Nanosecond
+	sleepNanos := (interval.Nanoseconds()*3 + rand.Int63n(2*interval.Nanoseconds())) / 4
+	interval = time.Duration(sleepNanos) * time.Nanosecond
 	l.Debugln(f, "next rescan in", interval)
 	f.timer.Reset(interval)
 }
@@ -54,6 +61,18 @@ func (f *folderScanner) Scan(subdirs []string) error {
 	return <-req.err
 }
 
+// ScanChanges scans the changed paths, as for those read from the change
+// journal.
+func (f *folderScanner) ScanChanges(changes []string) error {
+	req := rescanRequest{
+		subdirs: changes,
+		changes: true,
+		err:     make(chan error),
+	}
+	f.now <- req
+	return <-req.err
+}
+
 func (f *folderScanner) Delay(next time.Duration) {
 	f.delay <- next
 }
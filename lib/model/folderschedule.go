@@ -0,0 +1,63 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+// A folderSchedule pauses a folder during its pause schedules.
+type folderSchedule struct {
+	cfg    config.FolderConfiguration
+	timer  *time.Timer // fires at the start of each minute, when there are schedules
+	paused bool
+}
+
+func newFolderSchedule(cfg config.FolderConfiguration) folderSchedule {
+	timer := time.NewTimer(time.Minute)
+	timer.Stop()
+	return folderSchedule{
+		cfg:   cfg,
+		timer: timer,
+	}
+}
+
+// Update evaluates the schedules at the time, emitting an event when the
+// folder is paused or resumed by them, and sets the timer to do so again
+// at the start of the next minute. It returns whether the folder was
+// resumed.
+func (s *folderSchedule) Update(now time.Time) bool {
+	if len(s.cfg.PauseSchedules) == 0 {
+		return false
+	}
+
+	paused := s.cfg.ScheduledPause(now)
+	resumed := s.paused && !paused
+	if paused != s.paused {
+		eventType := events.FolderAutoResumed
+		if paused {
+			l.Infoln("Pausing", s.cfg.Description(), "as scheduled")
+			eventType = events.FolderAutoPaused
+		} else {
+			l.Infoln("Resuming", s.cfg.Description(), "as scheduled")
+		}
+		events.Default.Log(eventType, map[string]string{"id": s.cfg.ID, "label": s.cfg.Label, "pausedBy": config.PauseOwnerSchedule})
+		s.paused = paused
+	}
+
+	s.timer.Reset(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+	return resumed
+}
+
+// Paused returns whether the folder is paused by its schedules, as of the
+// last update.
+func (s *folderSchedule) Paused() bool {
+	return s.paused
+}
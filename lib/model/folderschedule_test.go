@@ -0,0 +1,61 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+func TestFolderSchedule(t *testing.T) {
+	sub := events.Default.Subscribe(events.FolderAutoPaused | events.FolderAutoResumed)
+	defer events.Default.Unsubscribe(sub)
+
+	s := newFolderSchedule(config.FolderConfiguration{ID: "default", PauseSchedules: []string{"* 9-16 * * *"}})
+	defer s.timer.Stop()
+
+	day := time.Date(2017, 6, 5, 0, 0, 0, 0, time.Local)
+	steps := []struct {
+		hour    int
+		paused  bool
+		resumed bool
+		event   events.EventType
+	}{
+		{8, false, false, 0},
+		{9, true, false, events.FolderAutoPaused},
+		{12, true, false, 0},
+		{17, false, true, events.FolderAutoResumed},
+		{18, false, false, 0},
+	}
+	for _, step := range steps {
+		resumed := s.Update(day.Add(time.Duration(step.hour)*time.Hour + 30*time.Second))
+		if resumed != step.resumed || s.Paused() != step.paused {
+			t.Errorf("At %d:00, resumed %v and paused %v, expected %v and %v", step.hour, resumed, s.Paused(), step.resumed, step.paused)
+		}
+		ev, err := sub.Poll(100 * time.Millisecond)
+		switch {
+		case step.event == 0 && err == nil:
+			t.Errorf("At %d:00, unexpected event %v", step.hour, ev.Type)
+		case step.event != 0 && (err != nil || ev.Type != step.event):
+			t.Errorf("At %d:00, expected event %v, got %v, %v", step.hour, step.event, ev.Type, err)
+		}
+	}
+
+	// Without schedules, the folder is never paused and the timer isn't set.
+	s = newFolderSchedule(config.FolderConfiguration{ID: "default"})
+	if s.Update(day.Add(12*time.Hour)) || s.Paused() {
+		t.Error("Folder without schedules paused or resumed")
+	}
+	select {
+	case <-s.timer.C:
+		t.Error("Timer set without schedules")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// Folder settings convergence
+//
+// A device that shares the settings of a folder sends its ignore patterns,
+// versioning and minimum block size along with the folder in the cluster
+// config, and again whenever they change. Devices that take the settings
+// for the folder from it apply them, except for those they keep local, so
+// that the settings don't silently drift apart.
+
+// folderSettings returns the settings to send for the folder, or nil if
+// they're not shared.
+func folderSettings(cfg config.FolderConfiguration) *protocol.FolderSettings {
+	if !cfg.ShareSettings {
+		return nil
+	}
+
+	settings := &protocol.FolderSettings{
+		VersioningType:  cfg.Versioning.Type,
+		MinBlockSizeKiB: int32(cfg.MinBlockSizeKiB),
+	}
+	if lines, err := readIgnoreLines(cfg); err == nil {
+		settings.Ignores = lines
+	}
+
+	keys := make([]string, 0, len(cfg.Versioning.Params))
+	for key := range cfg.Versioning.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		settings.VersioningParams = append(settings.VersioningParams, protocol.VersioningParam{
+			Key:   key,
+			Value: cfg.Versioning.Params[key],
+		})
+	}
+
+	return settings
+}
+
+// applyFolderSettings takes over the settings sent by the device for the
+// folder, if that's where we take them from.
+func (m *Model) applyFolderSettings(device protocol.DeviceID, folder string, settings *protocol.FolderSettings) {
+	cfg, ok := m.cfg.Folder(folder)
+	if !ok || settings == nil || cfg.SettingsFrom != device {
+		return
+	}
+
+	if cfg.TakesSetting(config.FolderSettingIgnores) {
+		lines, err := readIgnoreLines(cfg)
+		if err == nil && !equalLines(lines, settings.Ignores) {
+			l.Infof("Taking ignore patterns for folder %s from device %v", cfg.Description(), device)
+			if err := m.SetIgnores(folder, settings.Ignores); err != nil {
+				l.Warnf("Taking ignore patterns for folder %s: %v", cfg.Description(), err)
+			}
+		}
+	}
+
+	newCfg := cfg.Copy()
+	if cfg.TakesSetting(config.FolderSettingVersioning) {
+		newCfg.Versioning = config.VersioningConfiguration{
+			Type:   settings.VersioningType,
+			Params: make(map[string]string, len(settings.VersioningParams)),
+		}
+		for _, param := range settings.VersioningParams {
+			newCfg.Versioning.Params[param.Key] = param.Value
+		}
+	}
+	if cfg.TakesSetting(config.FolderSettingMinBlockSize) {
+		newCfg.MinBlockSizeKiB = int(settings.MinBlockSizeKiB)
+	}
+	if reflect.DeepEqual(newCfg.Versioning, cfg.Versioning) && newCfg.MinBlockSizeKiB == cfg.MinBlockSizeKiB {
+		return
+	}
+
+	l.Infof("Taking versioning and block size settings for folder %s from device %v", cfg.Description(), device)
+	if err := m.cfg.SetFolder(newCfg); err != nil {
+		l.Warnf("Taking settings for folder %s: %v", cfg.Description(), err)
+		return
+	}
+	if err := m.cfg.Save(); err != nil {
+		l.Warnln("Failed to save config", err)
+	}
+}
+
+// Shared ignores
+//
+// Devices that share the ignore patterns of a folder, rather than take them
+// from one device, send them with the time the .stignore file was changed.
+// Each takes the patterns changed most recently, setting that time on its
+// own file, so they converge on the same patterns without bouncing them
+// back and forth. For changes at the same time, the patterns of the device
+// with the lowest ID win. Patterns of included files aren't shared.
+
+// sharedIgnores returns the ignore patterns to send for the folder, or nil
+// if they're not shared.
+func sharedIgnores(cfg config.FolderConfiguration) *protocol.SharedIgnores {
+	if !cfg.SharedIgnores {
+		return nil
+	}
+	lines, modified, err := readSharedIgnores(cfg)
+	if err != nil {
+		return nil
+	}
+	shared := &protocol.SharedIgnores{Lines: lines}
+	if !modified.IsZero() {
+		shared.ModifiedNs = modified.UnixNano()
+	}
+	return shared
+}
+
+// applySharedIgnores takes the ignore patterns sent by the device for the
+// folder, if they were changed more recently than ours.
+func (m *Model) applySharedIgnores(device protocol.DeviceID, folder string, shared *protocol.SharedIgnores) {
+	cfg, ok := m.cfg.Folder(folder)
+	if !ok || shared == nil || !cfg.SharedIgnores {
+		return
+	}
+	if cfg.SettingsFrom != protocol.EmptyDeviceID && cfg.TakesSetting(config.FolderSettingIgnores) {
+		// Taken from that device only.
+		return
+	}
+
+	lines, modified, err := readSharedIgnores(cfg)
+	if err != nil || equalLines(lines, shared.Lines) {
+		return
+	}
+	remote := time.Unix(0, shared.ModifiedNs)
+	if remote.Before(modified) || remote.Equal(modified) && device.Compare(m.id) > 0 {
+		// Ours win; the device takes them when we send them.
+		return
+	}
+
+	l.Infof("Taking ignore patterns for folder %s from device %v, where they changed at %v", cfg.Description(), device, remote)
+	if err := m.setIgnores(folder, shared.Lines, remote); err != nil {
+		l.Warnf("Taking ignore patterns for folder %s: %v", cfg.Description(), err)
+	}
+}
+
+// readSharedIgnores returns the lines of the folder's .stignore file and
+// when it was changed, or no lines and the zero time if it doesn't exist.
+func readSharedIgnores(cfg config.FolderConfiguration) ([]string, time.Time, error) {
+	info, err := os.Stat(filepath.Join(cfg.Path(), ".stignore"))
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, nil
+	} else if err != nil {
+		return nil, time.Time{}, err
+	}
+	lines, err := readIgnoreLines(cfg)
+	return lines, info.ModTime(), err
+}
+
+// sendFolderSettings sends the folder's settings to the devices sharing it,
+// after they have changed in a way that doesn't restart the folder.
+func (m *Model) sendFolderSettings(folder string) {
+	m.fmut.Lock()
+	m.pmut.Lock()
+	updates := m.clusterConfigUpdatesLocked(folder, m.folderDevices.sortedDevices(folder))
+	m.pmut.Unlock()
+	m.fmut.Unlock()
+
+	m.sendClusterConfigUpdates(updates)
+}
+
+// readIgnoreLines returns the lines of the folder's .stignore file, which
+// are none if it doesn't exist.
+func readIgnoreLines(cfg config.FolderConfiguration) ([]string, error) {
+	fd, err := os.Open(filepath.Join(cfg.Path(), ".stignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	return lines, scanner.Err()
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -19,6 +19,7 @@ const (
 	FolderIdle folderState = iota
 	FolderScanning
 	FolderSyncing
+	FolderSyncWaiting
 	FolderError
 )
 
@@ -30,6 +31,8 @@ func (s folderState) String() string {
 		return "scanning"
 	case FolderSyncing:
 		return "syncing"
+	case FolderSyncWaiting:
+		return "sync-waiting"
 	case FolderError:
 		return "error"
 	default:
@@ -0,0 +1,123 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// At most this many names of each kind are listed in an IgnoresPreview;
+// they're all counted.
+const maxIgnoresPreviewFiles = 1000
+
+// An IgnoresPreview is what would change if the folder's ignore patterns
+// were replaced: the files now synced that would be ignored, and the
+// ignored files that would be synced.
+type IgnoresPreview struct {
+	Ignored       []string `json:"ignored"`
+	IgnoredCount  int      `json:"ignoredCount"`
+	Included      []string `json:"included"`
+	IncludedCount int      `json:"includedCount"`
+}
+
+// PreviewIgnores returns what would change if the folder's ignore patterns
+// were the given ones, going by the files in the database rather than on
+// disk. The patterns aren't saved.
+func (m *Model) PreviewIgnores(folder string, content []string) (IgnoresPreview, error) {
+	m.fmut.RLock()
+	cfg, ok := m.folderCfgs[folder]
+	files := m.folderFiles[folder]
+	current := m.folderIgnores[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return IgnoresPreview{}, errFolderMissing
+	}
+
+	candidate := ignore.New(false)
+	candidate.SetUnselected(current.Unselected())
+	buf := bytes.NewBufferString(strings.Join(content, "\n"))
+	if err := candidate.Parse(buf, filepath.Join(cfg.Path(), ".stignore")); err != nil {
+		return IgnoresPreview{}, err
+	}
+
+	preview := IgnoresPreview{
+		Ignored:  []string{},
+		Included: []string{},
+	}
+	compare := func(f db.FileInfoTruncated) {
+		info := dbFileInfo{f}
+		was := current.MatchInfo(f.Name, info).IsIgnored()
+		will := candidate.MatchInfo(f.Name, info).IsIgnored()
+		switch {
+		case will && !was:
+			preview.IgnoredCount++
+			if len(preview.Ignored) < maxIgnoresPreviewFiles {
+				preview.Ignored = append(preview.Ignored, f.Name)
+			}
+		case was && !will:
+			preview.IncludedCount++
+			if len(preview.Included) < maxIgnoresPreviewFiles {
+				preview.Included = append(preview.Included, f.Name)
+			}
+		}
+	}
+
+	files.WithGlobalTruncated(func(fi db.FileIntf) bool {
+		if f := fi.(db.FileInfoTruncated); !f.IsDeleted() {
+			compare(f)
+		}
+		return true
+	})
+	// Files we ignore are invalid, which keeps them out of the global
+	// list unless another device has them.
+	files.WithHaveTruncated(protocol.LocalDeviceID, func(fi db.FileIntf) bool {
+		f := fi.(db.FileInfoTruncated)
+		if !f.IsInvalid() || f.IsDeleted() {
+			return true
+		}
+		if _, ok := files.GetGlobalTruncated(f.Name); !ok {
+			compare(f)
+		}
+		return true
+	})
+
+	return preview, nil
+}
+
+// A dbFileInfo describes a file in the database as an os.FileInfo, for
+// matching ignore patterns with predicates against it.
+type dbFileInfo struct {
+	f db.FileInfoTruncated
+}
+
+func (i dbFileInfo) Name() string       { return filepath.Base(i.f.Name) }
+func (i dbFileInfo) Size() int64        { return i.f.Size }
+func (i dbFileInfo) ModTime() time.Time { return i.f.ModTime() }
+func (i dbFileInfo) IsDir() bool        { return i.f.IsDirectory() }
+func (i dbFileInfo) Sys() interface{}   { return nil }
+
+func (i dbFileInfo) Mode() os.FileMode {
+	mode := os.FileMode(i.f.Permissions) & os.ModePerm
+	if !i.f.HasPermissionBits() {
+		mode = 0644
+	}
+	switch {
+	case i.f.IsDirectory():
+		mode |= os.ModeDir
+	case i.f.IsSymlink():
+		mode |= os.ModeSymlink
+	}
+	return mode
+}
@@ -0,0 +1,210 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// How long to wait before restarting as a controller said, for the answer
+// to reach it first.
+const managedRestartDelay = time.Second
+
+var (
+	errNotController      = errors.New("not a controller of this device")
+	errUnknownCommand     = errors.New("unknown management command")
+	errUnknownPauseOwner  = errors.New("unknown pause owner")
+	errRestartUnsupported = errors.New("restarting is not supported")
+	errConfigUnsupported  = errors.New("setting the config is not supported")
+	errDeviceNotConnected = errors.New("device is not connected")
+	errNotManageable      = errors.New("device doesn't support management")
+	errPauseController    = errors.New("the controller can't pause itself")
+)
+
+// SetRestartHandler sets the function that restarts Syncthing when a
+// controller says so. Without one, restarting isn't supported. It must be
+// called before the model is started.
+func (m *Model) SetRestartHandler(restart func()) {
+	m.restart = restart
+}
+
+// SetConfigHandler sets the function that applies a config a controller
+// sends, hashing its passwords and so on as for the REST API, and saves it.
+// Without one, setting the config isn't supported. It must be called before
+// the model is started.
+func (m *Model) SetConfigHandler(setConfig func(config.Configuration) error) {
+	m.setConfig = setConfig
+}
+
+// Manage carries out the management command from the device, if it's a
+// controller of ours, returning the config if asked for it. Every command
+// is logged as a ManagementCommand event, for auditing.
+func (m *Model) Manage(device protocol.DeviceID, req protocol.ManagementRequest) ([]byte, error) {
+	cfg, err := m.manage(device, req)
+
+	data := map[string]interface{}{
+		"device":  device.String(),
+		"command": req.Command.String(),
+		"error":   "",
+	}
+	if req.Folder != "" {
+		data["folder"] = req.Folder
+	}
+	if req.Device != protocol.EmptyDeviceID {
+		data["target"] = req.Device.String()
+	}
+	if err != nil {
+		l.Infof("Management command %v from %v refused: %v", req.Command, device, err)
+		data["error"] = err.Error()
+	} else {
+		l.Infof("Management command %v from %v", req.Command, device)
+	}
+	events.Default.Log(events.ManagementCommand, data)
+
+	return cfg, err
+}
+
+func (m *Model) manage(device protocol.DeviceID, req protocol.ManagementRequest) ([]byte, error) {
+	if deviceCfg, ok := m.cfg.Device(device); !ok || !deviceCfg.Controller {
+		return nil, errNotController
+	}
+
+	switch req.Command {
+	case protocol.ManagementGetConfig:
+		cfg := m.cfg.RawCopy()
+		return json.Marshal(&cfg)
+
+	case protocol.ManagementSetConfig:
+		if m.setConfig == nil {
+			return nil, errConfigUnsupported
+		}
+		to, err := config.ReadJSON(bytes.NewReader(req.Config), m.id)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkManagedConfig(m.cfg.RawCopy(), to); err != nil {
+			return nil, err
+		}
+		return nil, m.setConfig(to)
+
+	case protocol.ManagementPause, protocol.ManagementResume:
+		if err := m.managePause(device, req); err != nil {
+			return nil, err
+		}
+		return nil, m.cfg.Save()
+
+	case protocol.ManagementRestart:
+		if m.restart == nil {
+			return nil, errRestartUnsupported
+		}
+		time.AfterFunc(managedRestartDelay, m.restart)
+		return nil, nil
+
+	default:
+		return nil, errUnknownCommand
+	}
+}
+
+// checkManagedConfig returns an error if the config a controller sends
+// changes any of the commands we run, as being a controller doesn't mean
+// being allowed to run commands here.
+func checkManagedConfig(from, to config.Configuration) error {
+	if to.GUI.AuthCommand != from.GUI.AuthCommand {
+		return errors.New("the GUI authentication command can't be changed remotely")
+	}
+	cur := make(map[string]config.FolderConfiguration)
+	for _, fcfg := range from.Folders {
+		cur[fcfg.ID] = fcfg
+	}
+	for _, fcfg := range to.Folders {
+		old := cur[fcfg.ID]
+		if fcfg.PrePullCommand != old.PrePullCommand || fcfg.PostPullCommand != old.PostPullCommand || fcfg.MergeCommand != old.MergeCommand {
+			return fmt.Errorf("folder %q: the pull hook and merge commands can't be changed remotely", fcfg.ID)
+		}
+		if (fcfg.Versioning.Type == "external" || old.Versioning.Type == "external") && !reflect.DeepEqual(fcfg.Versioning, old.Versioning) {
+			return fmt.Errorf("folder %q: external versioning can't be changed remotely", fcfg.ID)
+		}
+	}
+	return nil
+}
+
+// managePause pauses or resumes the folder or device, as the request from
+// the controller says, recording the owner and reason given as for the REST
+// API.
+func (m *Model) managePause(controller protocol.DeviceID, req protocol.ManagementRequest) error {
+	paused := req.Command == protocol.ManagementPause
+	owner := req.Owner
+	if owner == "" {
+		owner = config.PauseOwnerUser
+	}
+	if !config.IsPauseOwner(owner) {
+		return errUnknownPauseOwner
+	}
+
+	if req.Folder != "" {
+		cfg, ok := m.cfg.Folder(req.Folder)
+		if !ok {
+			return errFolderMissing
+		}
+		if !paused && !req.Force {
+			if err := config.CheckResume(owner, cfg.PausedBy, cfg.PauseReason); err != nil {
+				return err
+			}
+		}
+		cfg.Paused = paused
+		cfg.PausedBy, cfg.PauseReason = "", ""
+		if paused {
+			cfg.PausedBy, cfg.PauseReason = owner, req.Reason
+		}
+		return m.cfg.SetFolder(cfg)
+	}
+
+	if req.Device == controller && paused {
+		return errPauseController
+	}
+	cfg, ok := m.cfg.Device(req.Device)
+	if !ok || req.Device == m.id {
+		return errDeviceUnknown
+	}
+	if !paused && !req.Force {
+		if err := config.CheckResume(owner, cfg.PausedBy, cfg.PauseReason); err != nil {
+			return err
+		}
+	}
+	cfg.Paused = paused
+	cfg.PausedBy, cfg.PauseReason = "", ""
+	if paused {
+		cfg.PausedBy, cfg.PauseReason = owner, req.Reason
+	}
+	return m.cfg.SetDevice(cfg)
+}
+
+// ManageDevice sends the management command to the device, which carries
+// it out if we're its controller, returning the config it answers with, if
+// any.
+func (m *Model) ManageDevice(device protocol.DeviceID, req protocol.ManagementRequest) ([]byte, error) {
+	m.pmut.RLock()
+	conn, ok := m.conn[device]
+	hello := m.helloMessages[device]
+	m.pmut.RUnlock()
+	if !ok {
+		return nil, errDeviceNotConnected
+	}
+	if !hello.Management {
+		return nil, errNotManageable
+	}
+	return conn.Manage(req)
+}
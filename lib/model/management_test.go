@@ -0,0 +1,179 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestManage(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "syncthing-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	cfg := config.Wrap(tmp.Name(), config.Configuration{
+		Devices: []config.DeviceConfiguration{
+			{DeviceID: protocol.LocalDeviceID},
+			{DeviceID: device1, Controller: true},
+			{DeviceID: device2},
+		},
+		Folders: []config.FolderConfiguration{
+			{ID: "default", RawPath: "testdata", Paused: true, PausedBy: config.PauseOwnerUser},
+		},
+	})
+	m := NewModel(cfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+
+	sub := events.Default.Subscribe(events.ManagementCommand)
+	defer events.Default.Unsubscribe(sub)
+
+	// Only the controller may manage us, and every attempt is logged
+
+	if _, err := m.Manage(device2, protocol.ManagementRequest{Command: protocol.ManagementGetConfig}); err != errNotController {
+		t.Errorf("Expected a device that isn't a controller to be refused, got %v", err)
+	}
+	if ev, err := sub.Poll(time.Second); err != nil || ev.Data.(map[string]interface{})["error"] != errNotController.Error() {
+		t.Errorf("Expected a ManagementCommand event for the refusal, got %v (%v)", ev, err)
+	}
+
+	bs, err := m.Manage(device1, protocol.ManagementRequest{Command: protocol.ManagementGetConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got config.Configuration
+	if err := json.Unmarshal(bs, &got); err != nil || len(got.Folders) != 1 || got.Folders[0].ID != "default" {
+		t.Fatalf("Unexpected config %s (%v)", bs, err)
+	}
+
+	got.Folders[0].Label = "Managed"
+	bs, _ = json.Marshal(&got)
+	setConfig := protocol.ManagementRequest{Command: protocol.ManagementSetConfig, Config: bs}
+	if _, err := m.Manage(device1, setConfig); err != errConfigUnsupported {
+		t.Errorf("Expected setting the config to be unsupported, got %v", err)
+	}
+	m.SetConfigHandler(func(to config.Configuration) error {
+		if err := cfg.Replace(to); err != nil {
+			return err
+		}
+		return cfg.Save()
+	})
+	if _, err := m.Manage(device1, setConfig); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Folders()["default"].Label != "Managed" {
+		t.Error("Expected the config to be changed")
+	}
+	if saved, err := ioutil.ReadFile(tmp.Name()); err != nil || !bytes.Contains(saved, []byte("Managed")) {
+		t.Errorf("Expected the config to be saved (%v)", err)
+	}
+
+	// The commands we run can't be changed
+
+	for _, change := range []func(*config.Configuration){
+		func(c *config.Configuration) { c.Folders[0].PrePullCommand = "/bin/evil" },
+		func(c *config.Configuration) { c.Folders[0].MergeCommand = "/bin/evil" },
+		func(c *config.Configuration) {
+			c.Folders[0].Versioning = config.VersioningConfiguration{Type: "external", Params: map[string]string{"command": "/bin/evil"}}
+		},
+		func(c *config.Configuration) { c.GUI.AuthCommand = "/bin/evil" },
+	} {
+		evil := cfg.RawCopy()
+		change(&evil)
+		bs, _ := json.Marshal(&evil)
+		if _, err := m.Manage(device1, protocol.ManagementRequest{Command: protocol.ManagementSetConfig, Config: bs}); err == nil {
+			t.Errorf("Expected changing a command to be refused: %+v", evil.Folders[0])
+		}
+	}
+	if cfg.Folders()["default"].PrePullCommand != "" || cfg.Folders()["default"].Versioning.Type != "" || cfg.GUI().AuthCommand != "" {
+		t.Error("Expected the commands to be unchanged")
+	}
+
+	// Pausing and resuming keep to the owners
+
+	pause := protocol.ManagementRequest{Command: protocol.ManagementPause, Device: device2, Owner: config.PauseOwnerSchedule, Reason: "night"}
+	if _, err := m.Manage(device1, pause); err != nil {
+		t.Fatal(err)
+	}
+	if dev, _ := cfg.Device(device2); !dev.Paused || dev.PausedBy != config.PauseOwnerSchedule || dev.PauseReason != "night" {
+		t.Errorf("Expected the device to be paused by the schedule, got %+v", dev)
+	}
+	resume := protocol.ManagementRequest{Command: protocol.ManagementResume, Folder: "default", Owner: config.PauseOwnerPolicy}
+	if _, err := m.Manage(device1, resume); err == nil {
+		t.Error("Expected resuming what the user paused to need forcing")
+	}
+	resume.Force = true
+	if _, err := m.Manage(device1, resume); err != nil || cfg.Folders()["default"].Paused {
+		t.Errorf("Expected the folder to be resumed (%v)", err)
+	}
+	pause.Device = device1
+	if _, err := m.Manage(device1, pause); err != errPauseController {
+		t.Errorf("Expected the controller not to pause itself, got %v", err)
+	}
+
+	// Restarting takes a restart handler
+
+	if _, err := m.Manage(device1, protocol.ManagementRequest{Command: protocol.ManagementRestart}); err != errRestartUnsupported {
+		t.Errorf("Expected restarting to be unsupported, got %v", err)
+	}
+	restarted := make(chan struct{})
+	m.SetRestartHandler(func() { close(restarted) })
+	if _, err := m.Manage(device1, protocol.ManagementRequest{Command: protocol.ManagementRestart}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-restarted:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected a restart")
+	}
+}
+
+func TestManageDevice(t *testing.T) {
+	cfg := config.Wrap("/tmp/test", config.Configuration{
+		Devices: []config.DeviceConfiguration{{DeviceID: device1}, {DeviceID: device2}},
+	})
+	m := NewModel(cfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+
+	if _, err := m.ManageDevice(device1, protocol.ManagementRequest{Command: protocol.ManagementRestart}); err != errDeviceNotConnected {
+		t.Errorf("Expected the device not to be connected, got %v", err)
+	}
+
+	var sent []protocol.ManagementRequest
+	conn := &fakeConnection{id: device1, manageFn: func(req protocol.ManagementRequest) ([]byte, error) {
+		sent = append(sent, req)
+		return []byte("{}"), nil
+	}}
+	m.AddConnection(conn, protocol.HelloResult{Management: true})
+	if bs, err := m.ManageDevice(device1, protocol.ManagementRequest{Command: protocol.ManagementGetConfig}); err != nil || string(bs) != "{}" {
+		t.Errorf("Unexpected answer %q (%v)", bs, err)
+	}
+	if len(sent) != 1 || sent[0].Command != protocol.ManagementGetConfig {
+		t.Errorf("Unexpected commands sent %+v", sent)
+	}
+
+	// Devices that didn't say they understand management messages aren't
+	// sent any, as they'd close the connection
+
+	m.AddConnection(&fakeConnection{id: device2, manageFn: conn.manageFn}, protocol.HelloResult{})
+	if _, err := m.ManageDevice(device2, protocol.ManagementRequest{Command: protocol.ManagementGetConfig}); err != errNotManageable {
+		t.Errorf("Expected the device not to be manageable, got %v", err)
+	}
+	if len(sent) != 1 {
+		t.Errorf("Unexpected commands sent %+v", sent)
+	}
+}
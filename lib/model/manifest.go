@@ -0,0 +1,241 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+	"golang.org/x/text/unicode/norm"
+)
+
+// A ManifestEntry describes an item in a folder by its contents. The format
+// is independent of the database and wire formats, and of the platform, so
+// that external tools can compare it to what is on disk or to the manifest
+// of another device.
+type ManifestEntry struct {
+	Name          string          `json:"name"` // Slash separated and NFC normalized
+	Type          string          `json:"type"` // "file", "directory" or "symlink"
+	Size          int64           `json:"size"`
+	ModifiedS     int64           `json:"modifiedS"`
+	ModifiedNs    int32           `json:"modifiedNs"`
+	Permissions   uint32          `json:"permissions"`
+	NoPermissions bool            `json:"noPermissions"`
+	SymlinkTarget string          `json:"symlinkTarget,omitempty"`
+	Blocks        []ManifestBlock `json:"blocks,omitempty"`
+}
+
+// A ManifestBlock is a block of a file, identified by its SHA-256 hash.
+type ManifestBlock struct {
+	Offset int64  `json:"offset"`
+	Size   int32  `json:"size"`
+	Hash   string `json:"hash"` // Lowercase hex
+}
+
+// FolderManifest calls fn for each item that exists in the folder, ordered
+// by name, until fn returns false. The global state of the folder is used,
+// or our local state if local is set. Deleted and invalid items are left
+// out.
+func (m *Model) FolderManifest(folder string, local bool, fn func(ManifestEntry) bool) error {
+	m.fmut.RLock()
+	files, ok := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return errFolderMissing
+	}
+
+	iterator := func(fi db.FileIntf) bool {
+		f := fi.(protocol.FileInfo)
+		if f.IsDeleted() || f.IsInvalid() {
+			return true
+		}
+		return fn(manifestEntry(f))
+	}
+
+	if local {
+		files.WithHave(protocol.LocalDeviceID, iterator)
+	} else {
+		files.WithGlobal(iterator)
+	}
+	return nil
+}
+
+func manifestEntry(f protocol.FileInfo) ManifestEntry {
+	e := ManifestEntry{
+		Name:          norm.NFC.String(filepath.ToSlash(f.Name)),
+		Size:          f.Size,
+		ModifiedS:     f.ModifiedS,
+		ModifiedNs:    f.ModifiedNs,
+		Permissions:   f.Permissions & 0777,
+		NoPermissions: f.NoPermissions,
+	}
+
+	switch {
+	case f.IsSymlink():
+		e.Type = "symlink"
+		e.SymlinkTarget = f.SymlinkTarget
+	case f.IsDirectory():
+		e.Type = "directory"
+	default:
+		e.Type = "file"
+		e.Blocks = make([]ManifestBlock, len(f.Blocks))
+		for i, b := range f.Blocks {
+			e.Blocks[i] = ManifestBlock{
+				Offset: b.Offset,
+				Size:   b.Size,
+				Hash:   hex.EncodeToString(b.Hash),
+			}
+		}
+	}
+
+	return e
+}
+
+// A ManifestReport lists the differences between a manifest and what is on
+// disk. The paths are in the same format as in the manifest.
+type ManifestReport struct {
+	Missing  []string `json:"missing"`  // In the manifest, but not on disk
+	Modified []string `json:"modified"` // On disk, but with other contents or of another type
+	Extra    []string `json:"extra"`    // On disk, but not in the manifest
+}
+
+// VerifyManifest compares what is on disk in the folder to the manifest,
+// which is typically one previously returned by FolderManifest. Files are
+// compared by their contents, not by modification time or permissions.
+// Ignored and internal files are not reported as extra, and neither is
+// the contents of an extra directory.
+func (m *Model) VerifyManifest(folder string, entries []ManifestEntry) (ManifestReport, error) {
+	m.fmut.RLock()
+	cfg, ok := m.folderCfgs[folder]
+	ignores := m.folderIgnores[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return ManifestReport{}, errFolderMissing
+	}
+
+	report := ManifestReport{
+		Missing:  []string{},
+		Modified: []string{},
+		Extra:    []string{},
+	}
+
+	expected := make(map[string]struct{}, len(entries))
+	for _, e := range entries {
+		path, err := rootedJoinedPath(cfg.Path(), osutil.NativeFilename(e.Name))
+		if err != nil {
+			return ManifestReport{}, fmt.Errorf("%q: %v", e.Name, err)
+		}
+		expected[e.Name] = struct{}{}
+
+		switch err := verifyManifestEntry(path, e); {
+		case os.IsNotExist(err):
+			report.Missing = append(report.Missing, e.Name)
+		case err != nil:
+			l.Debugf("%v verify %s: %v", m, e.Name, err)
+			report.Modified = append(report.Modified, e.Name)
+		}
+	}
+
+	err := filepath.Walk(cfg.Path(), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(cfg.Path(), path)
+		if err != nil || rel == "." {
+			return nil
+		}
+
+		skip := ignore.IsInternal(rel) || ignore.IsTemporary(rel) || ignores.Match(rel).IsIgnored()
+		if _, ok := expected[norm.NFC.String(filepath.ToSlash(rel))]; !ok && !skip {
+			report.Extra = append(report.Extra, norm.NFC.String(filepath.ToSlash(rel)))
+			skip = true
+		}
+
+		if skip && info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return ManifestReport{}, err
+	}
+
+	sort.Strings(report.Missing)
+	sort.Strings(report.Modified)
+	sort.Strings(report.Extra)
+
+	return report, nil
+}
+
+// verifyManifestEntry returns an error if the item at path doesn't match
+// the manifest entry, satisfying os.IsNotExist if it doesn't exist.
+func verifyManifestEntry(path string, e ManifestEntry) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	switch e.Type {
+	case "symlink":
+		if info.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("not a symlink")
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		if filepath.ToSlash(target) != filepath.ToSlash(e.SymlinkTarget) {
+			return fmt.Errorf("symlink target %q differs", target)
+		}
+		return nil
+
+	case "directory":
+		if !info.IsDir() {
+			return fmt.Errorf("not a directory")
+		}
+		return nil
+
+	case "file":
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("not a regular file")
+		}
+		if info.Size() != e.Size {
+			return fmt.Errorf("size %d differs", info.Size())
+		}
+
+		blocks := make([]protocol.BlockInfo, len(e.Blocks))
+		for i, b := range e.Blocks {
+			hash, err := hex.DecodeString(b.Hash)
+			if err != nil {
+				return err
+			}
+			blocks[i] = protocol.BlockInfo{Offset: b.Offset, Size: b.Size, Hash: hash}
+		}
+
+		fd, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer fd.Close()
+		// All blocks but the last are the size of the first
+		blockSize := protocol.BlockSize
+		if len(blocks) > 0 {
+			blockSize = int(blocks[0].Size)
+		}
+		return scanner.Verify(fd, blockSize, blocks)
+	}
+
+	return fmt.Errorf("unknown type %q", e.Type)
+}
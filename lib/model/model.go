@@ -7,7 +7,6 @@
 package model
 
 import (
-	"bufio"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -30,13 +29,14 @@ import (
 	"github.com/syncthing/syncthing/lib/ignore"
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/rand"
 	"github.com/syncthing/syncthing/lib/scanner"
 	"github.com/syncthing/syncthing/lib/stats"
 	"github.com/syncthing/syncthing/lib/sync"
-	"github.com/syncthing/syncthing/lib/upgrade"
 	"github.com/syncthing/syncthing/lib/versioner"
 	"github.com/syncthing/syncthing/lib/weakhash"
 	"github.com/thejerf/suture"
+	"golang.org/x/text/unicode/norm"
 )
 
 // How many files to send in each Index/IndexUpdate message.
@@ -47,10 +47,15 @@ const (
 
 type service interface {
 	BringToFront(string)
+	BringMatchingToFront(match func(name string) bool)
 	DelayScan(d time.Duration)
 	IndexUpdated()              // Remote index was updated notification
 	Jobs() ([]string, []string) // In progress, Queued
+	Errors() []FileError
+	RetryErrors(ids []string)
+	IgnoreErrors(ids []string)
 	Scan(subs []string) error
+	ScanChanges(changes []string) error
 	Serve()
 	Stop()
 
@@ -63,6 +68,8 @@ type service interface {
 type Availability struct {
 	ID            protocol.DeviceID `json:"id"`
 	FromTemporary bool              `json:"fromTemporary"`
+	Routed        bool              `json:"routed"`      // the device doesn't have the file, but can fetch it for us
+	ColdStorage   bool              `json:"coldStorage"` // the device should only be asked as a last resort
 }
 
 type Model struct {
@@ -72,10 +79,18 @@ type Model struct {
 	db                *db.Instance
 	finder            *db.BlockFinder
 	progressEmitter   *ProgressEmitter
+	pullScheduler     *pullScheduler
+	blockCache        *blockCache     // blocks read from virtual folders
+	virtualActivity   *deviceActivity // requests for virtual folders
+	externalChanges   *changeQueue    // changes reported through the API
+	transferCaps      *transferCaps   // what's transferred per device, against the monthly caps
+	restart           func()          // restarts Syncthing, when a controller says so
+	setConfig         func(config.Configuration) error
 	id                protocol.DeviceID
 	shortID           protocol.ShortID
 	cacheIgnoredFiles bool
 	protectedFiles    []string
+	mappedFiles       *osutil.MappedFileCache
 
 	deviceName    string
 	clientName    string
@@ -90,14 +105,25 @@ type Model struct {
 	folderRunners      map[string]service                                     // folder -> puller or scanner
 	folderRunnerTokens map[string][]suture.ServiceToken                       // folder -> tokens for puller or scanner
 	folderStatRefs     map[string]*stats.FolderStatisticsReference            // folder -> statsRef
+	folderCaps         map[string]fs.Capabilities                             // folder -> what the filesystem supports
+	folderVersioners   map[string]versioner.Versioner                         // folder -> versioner, if versioning
+	indexSenders       map[string]map[protocol.DeviceID]chan struct{}         // folder -> deviceID -> closed to stop sending index data
+	folderLimiters     map[string]*folderLimiter                              // folder -> bandwidth limits
+	folderQuotas       map[string]*folderQuota                                // folder -> size limit
+	folderWriteOnces   map[string]*folderWriteOnce                            // folder -> write once enforcement
+	folderContents     map[string]*folderContent                              // folder -> content statistics
 	fmut               sync.RWMutex                                           // protects the above
 
-	conn                map[protocol.DeviceID]connections.Connection
-	closed              map[protocol.DeviceID]chan struct{}
-	helloMessages       map[protocol.DeviceID]protocol.HelloResult
-	deviceDownloads     map[protocol.DeviceID]*deviceDownloadState
-	remotePausedFolders map[protocol.DeviceID][]string // deviceID -> folders
-	pmut                sync.RWMutex                   // protects the above
+	conn                 map[protocol.DeviceID]connections.Connection
+	closed               map[protocol.DeviceID]chan struct{}
+	helloMessages        map[protocol.DeviceID]protocol.HelloResult
+	deviceDownloads      map[protocol.DeviceID]*deviceDownloadState
+	remotePausedFolders  map[protocol.DeviceID][]string               // deviceID -> folders
+	clockSkews           map[protocol.DeviceID]time.Duration          // deviceID -> remote clock offset, if significant
+	remoteClusterConfigs map[protocol.DeviceID]protocol.ClusterConfig // deviceID -> last received, with deltas applied
+	clusterConfigSent    map[protocol.DeviceID]bool                   // deviceID -> our full cluster config has been sent
+	connRecords          map[protocol.DeviceID]stats.ConnectionRecord // deviceID -> the current connection, for the history
+	pmut                 sync.RWMutex                                 // protects the above
 }
 
 type folderFactory func(*Model, config.FolderConfiguration, versioner.Versioner, *fs.MtimeFS) service
@@ -131,34 +157,52 @@ func NewModel(cfg *config.Wrapper, id protocol.DeviceID, deviceName, clientName,
 				l.Debugln(line)
 			},
 		}),
-		cfg:                 cfg,
-		db:                  ldb,
-		finder:              db.NewBlockFinder(ldb),
-		progressEmitter:     NewProgressEmitter(cfg),
-		id:                  id,
-		shortID:             id.Short(),
-		cacheIgnoredFiles:   cfg.Options().CacheIgnoredFiles,
-		protectedFiles:      protectedFiles,
-		deviceName:          deviceName,
-		clientName:          clientName,
-		clientVersion:       clientVersion,
-		folderCfgs:          make(map[string]config.FolderConfiguration),
-		folderFiles:         make(map[string]*db.FileSet),
-		folderDevices:       make(folderDeviceSet),
-		deviceFolders:       make(map[protocol.DeviceID][]string),
-		deviceStatRefs:      make(map[protocol.DeviceID]*stats.DeviceStatisticsReference),
-		folderIgnores:       make(map[string]*ignore.Matcher),
-		folderRunners:       make(map[string]service),
-		folderRunnerTokens:  make(map[string][]suture.ServiceToken),
-		folderStatRefs:      make(map[string]*stats.FolderStatisticsReference),
-		conn:                make(map[protocol.DeviceID]connections.Connection),
-		closed:              make(map[protocol.DeviceID]chan struct{}),
-		helloMessages:       make(map[protocol.DeviceID]protocol.HelloResult),
-		deviceDownloads:     make(map[protocol.DeviceID]*deviceDownloadState),
-		remotePausedFolders: make(map[protocol.DeviceID][]string),
-		fmut:                sync.NewRWMutex(),
-		pmut:                sync.NewRWMutex(),
-	}
+		cfg:                  cfg,
+		db:                   ldb,
+		finder:               db.NewBlockFinder(ldb),
+		progressEmitter:      NewProgressEmitter(cfg),
+		pullScheduler:        newPullScheduler(),
+		blockCache:           newBlockCache(),
+		virtualActivity:      newDeviceActivity(),
+		id:                   id,
+		shortID:              id.Short(),
+		cacheIgnoredFiles:    cfg.Options().CacheIgnoredFiles,
+		protectedFiles:       protectedFiles,
+		mappedFiles:          osutil.NewMappedFileCache(16, time.Minute),
+		deviceName:           deviceName,
+		clientName:           clientName,
+		clientVersion:        clientVersion,
+		folderCfgs:           make(map[string]config.FolderConfiguration),
+		folderFiles:          make(map[string]*db.FileSet),
+		folderDevices:        make(folderDeviceSet),
+		deviceFolders:        make(map[protocol.DeviceID][]string),
+		deviceStatRefs:       make(map[protocol.DeviceID]*stats.DeviceStatisticsReference),
+		folderIgnores:        make(map[string]*ignore.Matcher),
+		folderRunners:        make(map[string]service),
+		folderRunnerTokens:   make(map[string][]suture.ServiceToken),
+		folderStatRefs:       make(map[string]*stats.FolderStatisticsReference),
+		folderCaps:           make(map[string]fs.Capabilities),
+		folderVersioners:     make(map[string]versioner.Versioner),
+		indexSenders:         make(map[string]map[protocol.DeviceID]chan struct{}),
+		folderLimiters:       make(map[string]*folderLimiter),
+		folderQuotas:         make(map[string]*folderQuota),
+		folderWriteOnces:     make(map[string]*folderWriteOnce),
+		folderContents:       make(map[string]*folderContent),
+		conn:                 make(map[protocol.DeviceID]connections.Connection),
+		closed:               make(map[protocol.DeviceID]chan struct{}),
+		helloMessages:        make(map[protocol.DeviceID]protocol.HelloResult),
+		clockSkews:           make(map[protocol.DeviceID]time.Duration),
+		deviceDownloads:      make(map[protocol.DeviceID]*deviceDownloadState),
+		remotePausedFolders:  make(map[protocol.DeviceID][]string),
+		remoteClusterConfigs: make(map[protocol.DeviceID]protocol.ClusterConfig),
+		clusterConfigSent:    make(map[protocol.DeviceID]bool),
+		connRecords:          make(map[protocol.DeviceID]stats.ConnectionRecord),
+		fmut:                 sync.NewRWMutex(),
+		pmut:                 sync.NewRWMutex(),
+	}
+	m.externalChanges = newChangeQueue(externalChangesDelay, m.scanExternalChanges)
+	m.transferCaps = newTransferCaps(m)
+	m.Add(m.transferCaps)
 	if cfg.Options().ProgressUpdateIntervalS > -1 {
 		go m.progressEmitter.Serve()
 	}
@@ -183,9 +227,12 @@ func (m *Model) StartFolder(folder string) {
 	m.pmut.Lock()
 	folderType := m.startFolderLocked(folder)
 	folderCfg := m.folderCfgs[folder]
+	updates := m.clusterConfigUpdatesLocked(folder, nil)
 	m.pmut.Unlock()
 	m.fmut.Unlock()
 
+	m.sendClusterConfigUpdates(updates)
+
 	l.Infof("Ready to synchronize %s (%s)", folderCfg.Description(), folderType)
 }
 
@@ -204,6 +251,10 @@ func (m *Model) startFolderLocked(folder string) config.FolderType {
 	if !ok {
 		panic(fmt.Sprintf("unknown folder type 0x%x", cfg.Type))
 	}
+	if cfg.Virtual {
+		// Virtual folders never pull; their files are read on demand
+		folderFactory = folderFactories[config.FolderTypeSendOnly]
+	}
 
 	fs := m.folderFiles[folder]
 
@@ -217,9 +268,12 @@ func (m *Model) startFolderLocked(folder string) config.FolderType {
 		}
 	}
 
-	// Close connections to affected devices
+	// Close connections to affected devices, unless we can tell them about
+	// the folder with a cluster config delta.
 	for _, id := range cfg.DeviceIDs() {
-		m.closeLocked(id)
+		if !m.acceptsDeltasLocked(id) {
+			m.closeLocked(id)
+		}
 	}
 
 	v, ok := fs.Sequence(protocol.LocalDeviceID), true
@@ -256,6 +310,7 @@ func (m *Model) startFolderLocked(folder string) config.FolderType {
 			token := m.Add(service)
 			m.folderRunnerTokens[folder] = append(m.folderRunnerTokens[folder], token)
 		}
+		m.folderVersioners[folder] = ver
 	}
 
 	p := folderFactory(m, cfg, ver, fs.MtimeFS())
@@ -269,6 +324,57 @@ func (m *Model) startFolderLocked(folder string) config.FolderType {
 	return cfg.Type
 }
 
+// probeFolder finds out what the filesystem of the folder supports, and
+// returns the configuration to run the folder with, with the features the
+// filesystem doesn't support disabled. It creates files in the folder, so
+// it's up to folders that write to theirs to call it, without holding fmut.
+func (m *Model) probeFolder(cfg config.FolderConfiguration) config.FolderConfiguration {
+	caps, err := fs.ProbeCapabilities(cfg.Path(), ignore.TempName("probe-"+rand.String(8)))
+	m.fmut.Lock()
+	if err == nil {
+		m.folderCaps[cfg.ID] = caps
+	} else {
+		delete(m.folderCaps, cfg.ID)
+	}
+	m.fmut.Unlock()
+	if err != nil {
+		// Most likely the folder is missing or read only, which will be
+		// handled elsewhere.
+		l.Debugf("Probing filesystem of folder %s: %v", cfg.Description(), err)
+		return cfg
+	}
+	l.Debugf("Filesystem of folder %s: %+v", cfg.Description(), caps)
+
+	if !caps.SparseFiles && !cfg.DisableSparseFiles {
+		l.Infof("The filesystem of folder %s does not support sparse files; disabling them.", cfg.Description())
+		cfg.DisableSparseFiles = true
+	}
+	if !caps.Symlinks && fs.DefaultFilesystem.SymlinksSupported() {
+		l.Warnf("The filesystem of folder %s does not support symlinks; they will not be synced.", cfg.Description())
+	}
+
+	return cfg
+}
+
+// FolderCapabilities returns what the filesystem of each running folder
+// supports, as probed when the folder was started.
+func (m *Model) FolderCapabilities() map[string]fs.Capabilities {
+	m.fmut.RLock()
+	defer m.fmut.RUnlock()
+	res := make(map[string]fs.Capabilities, len(m.folderCaps))
+	for folder, caps := range m.folderCaps {
+		res[folder] = caps
+	}
+	return res
+}
+
+func (m *Model) symlinksSupported(folder string) bool {
+	m.fmut.RLock()
+	caps, ok := m.folderCaps[folder]
+	m.fmut.RUnlock()
+	return !ok || caps.Symlinks
+}
+
 func (m *Model) warnAboutOverwritingProtectedFiles(folder string) {
 	if m.folderCfgs[folder].Type == config.FolderTypeSendOnly {
 		return
@@ -310,6 +416,10 @@ func (m *Model) AddFolder(cfg config.FolderConfiguration) {
 func (m *Model) addFolderLocked(cfg config.FolderConfiguration) {
 	m.folderCfgs[cfg.ID] = cfg
 	m.folderFiles[cfg.ID] = db.NewFileSet(cfg.ID, m.db)
+	m.folderLimiters[cfg.ID] = newFolderLimiter(cfg)
+	m.folderQuotas[cfg.ID] = newFolderQuota(cfg)
+	m.folderWriteOnces[cfg.ID] = newFolderWriteOnce(cfg)
+	m.folderContents[cfg.ID] = newFolderContent(time.Now())
 
 	for _, device := range cfg.Devices {
 		m.folderDevices.set(device.DeviceID, cfg.ID)
@@ -317,6 +427,7 @@ func (m *Model) addFolderLocked(cfg config.FolderConfiguration) {
 	}
 
 	ignores := ignore.New(m.cacheIgnoredFiles)
+	ignores.SetUnselected(m.folderFiles[cfg.ID].Unselected())
 	if err := ignores.Load(filepath.Join(cfg.Path(), ".stignore")); err != nil && !os.IsNotExist(err) {
 		l.Warnln("Loading ignores:", err)
 	}
@@ -332,12 +443,16 @@ func (m *Model) RemoveFolder(folder string) {
 	folderPath := folderCfg.Path()
 	os.Remove(filepath.Join(folderPath, ".stfolder"))
 
+	before := m.folderDevices.sortedDevices(folder)
 	m.tearDownFolderLocked(folder)
+	updates := m.clusterConfigUpdatesLocked(folder, before)
 	// Remove it from the database
 	db.DropFolder(m.db, folder)
 
 	m.pmut.Unlock()
 	m.fmut.Unlock()
+
+	m.sendClusterConfigUpdates(updates)
 }
 
 func (m *Model) tearDownFolderLocked(folder string) {
@@ -346,9 +461,13 @@ func (m *Model) tearDownFolderLocked(folder string) {
 		m.Remove(id)
 	}
 
-	// Close connections to affected devices
+	// Close connections to affected devices, unless they accept cluster
+	// config deltas. Then we just stop sending index data for the folder
+	// and will tell them what changed.
 	for dev := range m.folderDevices[folder] {
-		if conn, ok := m.conn[dev]; ok {
+		if m.acceptsDeltasLocked(dev) {
+			m.stopIndexSenderLocked(dev, folder)
+		} else if conn, ok := m.conn[dev]; ok {
 			closeRawConn(conn)
 		}
 	}
@@ -361,6 +480,13 @@ func (m *Model) tearDownFolderLocked(folder string) {
 	delete(m.folderRunners, folder)
 	delete(m.folderRunnerTokens, folder)
 	delete(m.folderStatRefs, folder)
+	delete(m.folderCaps, folder)
+	delete(m.folderVersioners, folder)
+	delete(m.folderLimiters, folder)
+	delete(m.folderQuotas, folder)
+	delete(m.folderWriteOnces, folder)
+	delete(m.folderContents, folder)
+	delete(m.indexSenders, folder)
 	for dev, folders := range m.deviceFolders {
 		m.deviceFolders[dev] = stringSliceWithout(folders, folder)
 	}
@@ -374,6 +500,7 @@ func (m *Model) RestartFolder(cfg config.FolderConfiguration) {
 	m.fmut.Lock()
 	m.pmut.Lock()
 
+	before := m.folderDevices.sortedDevices(cfg.ID)
 	m.tearDownFolderLocked(cfg.ID)
 	if !cfg.Paused {
 		m.addFolderLocked(cfg)
@@ -382,18 +509,25 @@ func (m *Model) RestartFolder(cfg config.FolderConfiguration) {
 	} else {
 		l.Infoln("Paused folder", cfg.Description())
 	}
+	updates := m.clusterConfigUpdatesLocked(cfg.ID, before)
 
 	m.pmut.Unlock()
 	m.fmut.Unlock()
+
+	m.sendClusterConfigUpdates(updates)
 }
 
 type ConnectionInfo struct {
 	protocol.Statistics
 	Connected     bool
 	Paused        bool
+	PausedBy      string
+	PauseReason   string
 	Address       string
 	ClientVersion string
 	Type          string
+	ClockSkew     time.Duration
+	ColdStorage   bool
 }
 
 func (info ConnectionInfo) MarshalJSON() ([]byte, error) {
@@ -403,9 +537,13 @@ func (info ConnectionInfo) MarshalJSON() ([]byte, error) {
 		"outBytesTotal": info.OutBytesTotal,
 		"connected":     info.Connected,
 		"paused":        info.Paused,
+		"pausedBy":      info.PausedBy,
+		"pauseReason":   info.PauseReason,
 		"address":       info.Address,
 		"clientVersion": info.ClientVersion,
 		"type":          info.Type,
+		"clockSkewS":    info.ClockSkew.Seconds(),
+		"coldStorage":   info.ColdStorage,
 	})
 }
 
@@ -426,6 +564,10 @@ func (m *Model) ConnectionStats() map[string]interface{} {
 		ci := ConnectionInfo{
 			ClientVersion: strings.TrimSpace(versionString),
 			Paused:        deviceCfg.Paused,
+			PausedBy:      deviceCfg.PausedBy,
+			PauseReason:   deviceCfg.PauseReason,
+			ClockSkew:     m.clockSkews[device],
+			ColdStorage:   hello.ColdStorage,
 		}
 		if conn, ok := m.conn[device]; ok {
 			ci.Type = conn.Type()
@@ -459,8 +601,14 @@ func (m *Model) ConnectionStats() map[string]interface{} {
 // DeviceStatistics returns statistics about each device
 func (m *Model) DeviceStatistics() map[string]stats.DeviceStatistics {
 	res := make(map[string]stats.DeviceStatistics)
-	for id := range m.cfg.Devices() {
-		res[id.String()] = m.deviceStatRef(id).GetStatistics()
+	now := time.Now()
+	for id, cfg := range m.cfg.Devices() {
+		ref := m.deviceStatRef(id)
+		stats := ref.GetStatistics()
+		start, _ := cfg.CapPeriod(now)
+		stats.TransferredIn, stats.TransferredOut = ref.GetTransferred(start)
+		stats.TransferCapped = m.overTransferCap(cfg, now)
+		res[id.String()] = stats
 	}
 	return res
 }
@@ -519,7 +667,7 @@ func (m *Model) Completion(device protocol.DeviceID, folder string) FolderComple
 		}
 
 		// This might might be more than it really is, because some blocks can be of a smaller size.
-		downloaded = int64(counts[ft.Name] * protocol.BlockSize)
+		downloaded = int64(counts[ft.Name] * ft.BlockSize())
 
 		fileNeed = ft.FileSize() - downloaded
 		if fileNeed < 0 {
@@ -587,6 +735,24 @@ func (m *Model) LocalSize(folder string) db.Counts {
 	return db.Counts{}
 }
 
+// QuotaExceeded returns whether the folder is at its maximum size, with
+// files left out of the last pull.
+func (m *Model) QuotaExceeded(folder string) bool {
+	m.fmut.RLock()
+	quota := m.folderQuotas[folder]
+	m.fmut.RUnlock()
+	return quota.Exceeded()
+}
+
+// WriteOnceViolations returns the number of files in the write once folder
+// whose changes from other devices were refused during the last pull.
+func (m *Model) WriteOnceViolations(folder string) int {
+	m.fmut.RLock()
+	writeOnce := m.folderWriteOnces[folder]
+	m.fmut.RUnlock()
+	return writeOnce.Violations()
+}
+
 // NeedSize returns the number and total size of currently needed files.
 func (m *Model) NeedSize(folder string) db.Counts {
 	m.fmut.RLock()
@@ -779,29 +945,62 @@ func (m *Model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 	// Also, collect a list of folders we do share, and if he's interested in
 	// temporary indexes, subscribe the connection.
 
-	tempIndexFolders := make([]string, 0, len(cm.Folders))
-
 	m.pmut.RLock()
 	conn, ok := m.conn[deviceID]
 	hello := m.helloMessages[deviceID]
+	// A delta only describes the folders that changed. The full picture is
+	// the previous cluster config with the delta applied.
+	full := cm
+	if cm.Delta {
+		full = m.remoteClusterConfigs[deviceID].Merge(cm)
+	}
 	m.pmut.RUnlock()
 	if !ok {
 		panic("bug: ClusterConfig called on closed or nonexistent connection")
 	}
 
+	changedFolders := make(map[string]bool, len(cm.Folders))
+	for _, folder := range cm.Folders {
+		changedFolders[folder.ID] = true
+	}
+
+	tempIndexFolders := make([]string, 0, len(full.Folders))
+
 	dbLocation := filepath.Dir(m.db.Location())
 
-	// See issue #3802 - in short, we can't send modern symlink entries to older
-	// clients.
-	dropSymlinks := false
-	if hello.ClientName == m.clientName && upgrade.CompareVersions(hello.ClientVersion, "v0.14.14") < 0 {
+	dropSymlinks := m.dropSymlinks(hello)
+	if dropSymlinks && !cm.Delta {
 		l.Warnln("Not sending symlinks to old client", deviceID, "- please upgrade to v0.14.14 or newer")
-		dropSymlinks = true
 	}
 
+	deviceCfg := m.cfg.Devices()[deviceID]
+	isPrimary := deviceID == m.cfg.Options().StandbyFor
+
 	m.fmut.Lock()
+	for _, folder := range cm.RemovedFolders {
+		m.stopIndexSenderLocked(deviceID, folder)
+	}
+
 	var paused []string
-	for _, folder := range cm.Folders {
+	for _, folder := range full.Folders {
+		// Folders not mentioned in a delta are unchanged and already
+		// handled; only our bookkeeping needs to be recreated for them.
+		changed := changedFolders[folder.ID]
+		if changed {
+			// Any index data we're sending for the folder is based on
+			// outdated information.
+			m.stopIndexSenderLocked(deviceID, folder.ID)
+		}
+
+		if !deviceCfg.FolderAllowed(folder.ID) {
+			// Not even offered to the user, so that the remote side can't
+			// get us to add folders that we have ruled out for it.
+			if changed {
+				l.Warnf("Device %v offered folder %s, which is not allowed by its folder policy; ignoring.", deviceID, folder.Description())
+			}
+			continue
+		}
+
 		if folder.Paused {
 			paused = append(paused, folder.ID)
 			continue
@@ -812,120 +1011,71 @@ func (m *Model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 		}
 
 		if !m.folderSharedWithLocked(folder.ID, deviceID) {
-			events.Default.Log(events.FolderRejected, map[string]string{
-				"folder":      folder.ID,
-				"folderLabel": folder.Label,
-				"device":      deviceID.String(),
-			})
-			l.Infof("Unexpected folder %s sent from device %q; ensure that the folder exists and that this device is selected under \"Share With\" in the folder configuration.", folder.Description(), deviceID)
+			// A standby mirrors the folders of its primary below rather
+			// than reject them.
+			if changed && !isPrimary {
+				events.Default.Log(events.FolderRejected, map[string]string{
+					"folder":      folder.ID,
+					"folderLabel": folder.Label,
+					"device":      deviceID.String(),
+				})
+				l.Infof("Unexpected folder %s sent from device %q; ensure that the folder exists and that this device is selected under \"Share With\" in the folder configuration.", folder.Description(), deviceID)
+			}
 			continue
 		}
 		if !folder.DisableTempIndexes {
 			tempIndexFolders = append(tempIndexFolders, folder.ID)
 		}
 
-		fs := m.folderFiles[folder.ID]
-		myIndexID := fs.IndexID(protocol.LocalDeviceID)
-		mySequence := fs.Sequence(protocol.LocalDeviceID)
-		var startSequence int64
-
-		for _, dev := range folder.Devices {
-			if dev.ID == m.id {
-				// This is the other side's description of what it knows
-				// about us. Lets check to see if we can start sending index
-				// updates directly or need to send the index from start...
-
-				if dev.IndexID == myIndexID {
-					// They say they've seen our index ID before, so we can
-					// send a delta update only.
-
-					if dev.MaxSequence > mySequence {
-						// Safety check. They claim to have more or newer
-						// index data than we have - either we have lost
-						// index data, or reset the index without resetting
-						// the IndexID, or something else weird has
-						// happened. We send a full index to reset the
-						// situation.
-						l.Infof("Device %v folder %s is delta index compatible, but seems out of sync with reality", deviceID, folder.Description())
-						startSequence = 0
-						continue
-					}
-
-					l.Debugf("Device %v folder %s is delta index compatible (mlv=%d)", deviceID, folder.Description(), dev.MaxSequence)
-					startSequence = dev.MaxSequence
-				} else if dev.IndexID != 0 {
-					// They say they've seen an index ID from us, but it's
-					// not the right one. Either they are confused or we
-					// must have reset our database since last talking to
-					// them. We'll start with a full index transfer.
-					l.Infof("Device %v folder %s has mismatching index ID for us (%v != %v)", deviceID, folder.Description(), dev.IndexID, myIndexID)
-					startSequence = 0
-				}
-			} else if dev.ID == deviceID && dev.IndexID != 0 {
-				// This is the other side's description of themselves. We
-				// check to see that it matches the IndexID we have on file,
-				// otherwise we drop our old index data and expect to get a
-				// completely new set.
-
-				theirIndexID := fs.IndexID(deviceID)
-				if dev.IndexID == 0 {
-					// They're not announcing an index ID. This means they
-					// do not support delta indexes and we should clear any
-					// information we have from them before accepting their
-					// index, which will presumably be a full index.
-					fs.Replace(deviceID, nil)
-				} else if dev.IndexID != theirIndexID {
-					// The index ID we have on file is not what they're
-					// announcing. They must have reset their database and
-					// will probably send us a full index. We drop any
-					// information we have and remember this new index ID
-					// instead.
-					l.Infof("Device %v folder %s has a new index ID (%v)", deviceID, folder.Description(), dev.IndexID)
-					fs.Replace(deviceID, nil)
-					fs.SetIndexID(deviceID, dev.IndexID)
-				} else {
-					// They're sending a recognized index ID and will most
-					// likely use delta indexes. We might already have files
-					// that we need to pull so let the folder runner know
-					// that it should recheck the index data.
-					if runner := m.folderRunners[folder.ID]; runner != nil {
-						defer runner.IndexUpdated()
-					}
-				}
+		if changed {
+			m.startIndexSenderLocked(conn, folder, dbLocation, dropSymlinks, !hello.Placeholders)
+			if folder.Settings != nil {
+				// Applying the settings may restart the folder, which
+				// needs the locks we hold.
+				go m.applyFolderSettings(deviceID, folder.ID, folder.Settings)
+			}
+			if folder.SharedIgnores != nil {
+				go m.applySharedIgnores(deviceID, folder.ID, folder.SharedIgnores)
 			}
 		}
-
-		go sendIndexes(conn, folder.ID, fs, m.folderIgnores[folder.ID], startSequence, dbLocation, dropSymlinks)
 	}
 
 	m.pmut.Lock()
 	m.remotePausedFolders[deviceID] = paused
+	m.remoteClusterConfigs[deviceID] = full
 	m.pmut.Unlock()
 
-	// This breaks if we send multiple CM messages during the same connection.
-	if len(tempIndexFolders) > 0 {
-		m.pmut.RLock()
-		conn, ok := m.conn[deviceID]
-		m.pmut.RUnlock()
-		// In case we've got ClusterConfig, and the connection disappeared
-		// from infront of our nose.
-		if ok {
+	m.pmut.RLock()
+	conn, ok = m.conn[deviceID]
+	m.pmut.RUnlock()
+	// In case we've got ClusterConfig, and the connection disappeared
+	// from infront of our nose.
+	if ok {
+		// A delta may have changed which folders are interested, so start
+		// over with the subscriptions.
+		if cm.Delta {
+			m.progressEmitter.temporaryIndexUnsubscribe(conn)
+		}
+		if len(tempIndexFolders) > 0 {
 			m.progressEmitter.temporaryIndexSubscribe(conn, tempIndexFolders)
 		}
 	}
 
 	var changed = false
-	if deviceCfg := m.cfg.Devices()[deviceID]; deviceCfg.Introducer {
-		foldersDevices, introduced := m.handleIntroductions(deviceCfg, cm)
+	if deviceCfg.Introducer {
+		foldersDevices, introduced := m.handleIntroductions(deviceCfg, full)
 		if introduced {
 			changed = true
 		}
 		// If permitted, check if the introducer has unshare devices/folders with
 		// some of the devices/folders that we know were introduced to us by him.
-		if !deviceCfg.SkipIntroductionRemovals && m.handleDeintroductions(deviceCfg, cm, foldersDevices) {
+		if !deviceCfg.SkipIntroductionRemovals && m.handleDeintroductions(deviceCfg, full, foldersDevices) {
 			changed = true
 		}
 	}
+	if isPrimary && m.mirrorPrimaryLocked(deviceCfg, full) {
+		changed = true
+	}
 	m.fmut.Unlock()
 
 	if changed {
@@ -950,6 +1100,12 @@ func (m *Model) handleIntroductions(introducerCfg config.DeviceConfiguration, cm
 			continue
 		}
 
+		// The introducer may not speak for folders it isn't allowed to
+		// share.
+		if !introducerCfg.FolderAllowed(folder.ID) {
+			continue
+		}
+
 		// Adds devices which we do not have, but the introducer has
 		// for the folders that we have in common. Also, shares folders
 		// with devices that we have in common, yet are currently not sharing
@@ -958,9 +1114,10 @@ func (m *Model) handleIntroductions(introducerCfg config.DeviceConfiguration, cm
 		for _, device := range folder.Devices {
 			foldersDevices.set(device.ID, folder.ID)
 
-			if _, ok := m.cfg.Devices()[device.ID]; !ok {
+			deviceCfg, ok := m.cfg.Devices()[device.ID]
+			if !ok {
 				// The device is currently unknown. Add it to the config.
-				m.introduceDevice(device, introducerCfg)
+				deviceCfg = m.introduceDevice(device, introducerCfg)
 				changed = true
 			}
 
@@ -972,6 +1129,11 @@ func (m *Model) handleIntroductions(introducerCfg config.DeviceConfiguration, cm
 				}
 			}
 
+			if !deviceCfg.FolderAllowed(folder.ID) {
+				l.Infof("Not sharing folder %s with %v (vouched for by introducer %v); not allowed by its folder policy", folder.Description(), device.ID, introducerCfg.DeviceID)
+				continue
+			}
+
 			// We don't yet share this folder with this device. Add the device
 			// to sharing list of the folder.
 			m.introduceDeviceToFolder(device, folder, introducerCfg)
@@ -1035,7 +1197,7 @@ func (m *Model) handleDeintroductions(introducerCfg config.DeviceConfiguration,
 	return changed
 }
 
-func (m *Model) introduceDevice(device protocol.Device, introducerCfg config.DeviceConfiguration) {
+func (m *Model) introduceDevice(device protocol.Device, introducerCfg config.DeviceConfiguration) config.DeviceConfiguration {
 	addresses := []string{"dynamic"}
 	for _, addr := range device.Addresses {
 		if addr != "dynamic" {
@@ -1051,6 +1213,9 @@ func (m *Model) introduceDevice(device protocol.Device, introducerCfg config.Dev
 		Addresses:    addresses,
 		CertName:     device.CertName,
 		IntroducedBy: introducerCfg.DeviceID,
+		// Devices vouched for by the introducer are held to the same
+		// folder policy as the introducer itself.
+		AllowedFolders: append([]string(nil), introducerCfg.AllowedFolders...),
 	}
 
 	// The introducers' introducers are also our introducers.
@@ -1061,6 +1226,7 @@ func (m *Model) introduceDevice(device protocol.Device, introducerCfg config.Dev
 	}
 
 	m.cfg.SetDevice(newDeviceCfg)
+	return newDeviceCfg
 }
 
 func (m *Model) introduceDeviceToFolder(device protocol.Device, folder protocol.Folder, introducerCfg config.DeviceConfiguration) {
@@ -1080,6 +1246,14 @@ func (m *Model) introduceDeviceToFolder(device protocol.Device, folder protocol.
 // Closed is called when a connection has been closed
 func (m *Model) Closed(conn protocol.Connection, err error) {
 	device := conn.ID()
+	connStats := conn.Statistics()
+	m.countClosedTransfers(device, connStats)
+
+	m.fmut.Lock()
+	for folder := range m.indexSenders {
+		m.stopIndexSenderLocked(device, folder)
+	}
+	m.fmut.Unlock()
 
 	m.pmut.Lock()
 	conn, ok := m.conn[device]
@@ -1090,10 +1264,25 @@ func (m *Model) Closed(conn protocol.Connection, err error) {
 	delete(m.helloMessages, device)
 	delete(m.deviceDownloads, device)
 	delete(m.remotePausedFolders, device)
+	delete(m.clockSkews, device)
+	delete(m.remoteClusterConfigs, device)
+	delete(m.clusterConfigSent, device)
+	record, recorded := m.connRecords[device]
+	delete(m.connRecords, device)
 	closed := m.closed[device]
 	delete(m.closed, device)
 	m.pmut.Unlock()
 
+	m.deviceWasSeen(device)
+	if recorded {
+		record.Disconnected = time.Now()
+		record.InBytes = connStats.InBytesTotal
+		record.OutBytes = connStats.OutBytesTotal
+		record.Error = err.Error()
+		keep := time.Duration(m.cfg.Options().ConnectionHistoryDays) * 24 * time.Hour
+		m.deviceStatRef(device).AddConnection(record, keep)
+	}
+
 	l.Infof("Connection to %s closed: %v", device, err)
 	events.Default.Log(events.DeviceDisconnected, map[string]string{
 		"id":    device.String(),
@@ -1122,7 +1311,7 @@ func (m *Model) closeLocked(device protocol.DeviceID) {
 
 // Request returns the specified data segment by reading it from local disk.
 // Implements the protocol.Model interface.
-func (m *Model) Request(deviceID protocol.DeviceID, folder, name string, offset int64, hash []byte, fromTemporary bool, buf []byte) error {
+func (m *Model) Request(deviceID protocol.DeviceID, folder, name string, offset int64, hash []byte, fromTemporary, routed bool, buf []byte) error {
 	if offset < 0 {
 		return protocol.ErrInvalid
 	}
@@ -1138,6 +1327,7 @@ func (m *Model) Request(deviceID protocol.DeviceID, folder, name string, offset
 	folderCfg := m.folderCfgs[folder]
 	folderPath := folderCfg.Path()
 	folderIgnores := m.folderIgnores[folder]
+	limiter := m.folderLimiters[folder]
 	m.fmut.RUnlock()
 
 	fn, err := rootedJoinedPath(folderPath, name)
@@ -1166,6 +1356,21 @@ func (m *Model) Request(deviceID protocol.DeviceID, folder, name string, offset
 		return protocol.ErrNoSuchFile
 	}
 
+	if limiter != nil && deviceID != protocol.LocalDeviceID {
+		limiter.waitSend(len(buf))
+	}
+
+	if deviceID != protocol.LocalDeviceID && !fromTemporary && m.shouldRoute(folder, name, offset, hash) {
+		if routed {
+			// Routed requests are never routed again
+			l.Debugf("%v REQ(in) routed again: %s: %q / %q o=%d s=%d", m, deviceID, folder, name, offset, len(buf))
+			return protocol.ErrNoSuchFile
+		}
+		return m.routeRequest(deviceID, folder, name, offset, hash, buf)
+	}
+
+	limiter.diskThrottle().WaitRead(len(buf))
+
 	// Only check temp files if the flag is set, and if we are set to advertise
 	// the temp indexes.
 	if fromTemporary && !folderCfg.DisableTempIndexes {
@@ -1184,13 +1389,19 @@ func (m *Model) Request(deviceID protocol.DeviceID, folder, name string, offset
 		// file has finished downloading.
 	}
 
-	if info, err := osutil.Lstat(fn); err != nil || !info.Mode().IsRegular() {
+	info, err := osutil.Lstat(fn)
+	if err != nil || !info.Mode().IsRegular() {
 		// Reject reads for anything that doesn't exist or is something
 		// other than a regular file.
 		return protocol.ErrNoSuchFile
 	}
 
-	err = readOffsetIntoBuf(fn, offset, buf)
+	// Large files are read through a memory mapping, kept around for the
+	// requests for their other blocks.
+	ok, err := m.mappedFiles.ReadAt(fn, info, buf, offset)
+	if !ok {
+		err = readOffsetIntoBuf(fn, offset, buf)
+	}
 	if os.IsNotExist(err) {
 		return protocol.ErrNoSuchFile
 	} else if err != nil {
@@ -1254,20 +1465,11 @@ func (m *Model) GetIgnores(folder string) ([]string, []string, error) {
 		return lines, nil, fmt.Errorf("Folder %s stopped", folder)
 	}
 
-	fd, err := os.Open(filepath.Join(cfg.Path(), ".stignore"))
+	lines, err := readIgnoreLines(cfg)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return lines, nil, nil
-		}
 		l.Warnln("Loading .stignore:", err)
 		return lines, nil, err
 	}
-	defer fd.Close()
-
-	scanner := bufio.NewScanner(fd)
-	for scanner.Scan() {
-		lines = append(lines, strings.TrimSpace(scanner.Text()))
-	}
 
 	m.fmut.RLock()
 	patterns := m.folderIgnores[folder].Patterns()
@@ -1277,6 +1479,12 @@ func (m *Model) GetIgnores(folder string) ([]string, []string, error) {
 }
 
 func (m *Model) SetIgnores(folder string, content []string) error {
+	return m.setIgnores(folder, content, time.Time{})
+}
+
+// setIgnores writes the ignore patterns, with the modification time if
+// it's not zero, as when taking shared ignores from another device.
+func (m *Model) setIgnores(folder string, content []string, modTime time.Time) error {
 	cfg, ok := m.folderCfgs[folder]
 	if !ok {
 		return fmt.Errorf("Folder %s does not exist", folder)
@@ -1298,8 +1506,18 @@ func (m *Model) SetIgnores(folder string, content []string) error {
 		l.Warnln("Saving .stignore:", err)
 		return err
 	}
+	if !modTime.IsZero() {
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			l.Warnln("Saving .stignore:", err)
+			return err
+		}
+	}
 	osutil.HideFile(path)
 
+	if cfg.ShareSettings || cfg.SharedIgnores {
+		m.sendFolderSettings(folder)
+	}
+
 	return m.ScanFolder(folder)
 }
 
@@ -1316,6 +1534,9 @@ func (m *Model) OnHello(remoteID protocol.DeviceID, addr net.Addr, hello protoco
 		if cfg.Paused {
 			return errDevicePaused
 		}
+		if m.TransferCapped(remoteID) {
+			return errDeviceCapped
+		}
 		return nil
 	}
 
@@ -1334,6 +1555,12 @@ func (m *Model) GetHello(protocol.DeviceID) protocol.HelloIntf {
 		DeviceName:    m.deviceName,
 		ClientName:    m.clientName,
 		ClientVersion: m.clientVersion,
+		Timestamp:     time.Now().UnixNano(),
+		ColdStorage:   m.cfg.Options().ColdStorage,
+		Zstd:          protocol.ZstdSupported,
+		Management:    true,
+		SmallBlocks:   true,
+		Placeholders:  true,
 	}
 }
 
@@ -1364,6 +1591,11 @@ func (m *Model) AddConnection(conn connections.Connection, hello protocol.HelloR
 
 	m.helloMessages[deviceID] = hello
 
+	skew := m.clockSkew(hello)
+	if skew != 0 {
+		m.clockSkews[deviceID] = skew
+	}
+
 	event := map[string]string{
 		"id":            deviceID.String(),
 		"deviceName":    hello.DeviceName,
@@ -1379,8 +1611,22 @@ func (m *Model) AddConnection(conn connections.Connection, hello protocol.HelloR
 
 	events.Default.Log(events.DeviceConnected, event)
 
+	m.connRecords[deviceID] = stats.ConnectionRecord{
+		Connected: time.Now(),
+		Type:      event["type"],
+		Address:   event["addr"],
+	}
+
 	l.Infof(`Device %s client is "%s %s" named "%s"`, deviceID, hello.ClientName, hello.ClientVersion, hello.DeviceName)
 
+	if skew != 0 {
+		l.Warnf("The clock on device %s differs from ours by %v. Conflicts are resolved by modification time, so please check the time settings on both devices.", deviceID, skew)
+		events.Default.Log(events.DeviceClockSkew, map[string]interface{}{
+			"id":         deviceID.String(),
+			"clockSkewS": skew.Seconds(),
+		})
+	}
+
 	conn.Start()
 	m.pmut.Unlock()
 
@@ -1388,6 +1634,13 @@ func (m *Model) AddConnection(conn connections.Connection, hello protocol.HelloR
 	cm := m.generateClusterConfig(deviceID)
 	conn.ClusterConfig(cm)
 
+	// Changes from now on can be sent as deltas, if they accept them.
+	m.pmut.Lock()
+	if m.conn[deviceID] == conn {
+		m.clusterConfigSent[deviceID] = true
+	}
+	m.pmut.Unlock()
+
 	device, ok := m.cfg.Devices()[deviceID]
 	if ok && (device.Name == "" || m.cfg.Options().OverwriteRemoteDevNames) {
 		device.Name = hello.DeviceName
@@ -1398,6 +1651,47 @@ func (m *Model) AddConnection(conn connections.Connection, hello protocol.HelloR
 	m.deviceWasSeen(deviceID)
 }
 
+// clockSkew returns how far the remote clock is ahead of ours, based on the
+// timestamp in the hello message, rounded to whole seconds. Skews within the
+// configured tolerance, or from devices that don't announce their time,
+// are reported as zero.
+func (m *Model) clockSkew(hello protocol.HelloResult) time.Duration {
+	maxSkew := time.Duration(m.cfg.Options().MaxClockSkewS) * time.Second
+	if maxSkew <= 0 || hello.Timestamp.IsZero() {
+		return 0
+	}
+	skew := hello.Timestamp.Sub(time.Now())
+	if skew < maxSkew && skew > -maxSkew {
+		return 0
+	}
+	return (skew / time.Second) * time.Second
+}
+
+// localClockSkew returns how far our clock is ahead of those of the
+// connected devices: the median of the offsets of all of our clocks, with
+// skews within the tolerance counting as none.
+func (m *Model) localClockSkew() time.Duration {
+	offsets := []time.Duration{0}
+	m.pmut.RLock()
+	for deviceID := range m.conn {
+		offsets = append(offsets, -m.clockSkews[deviceID])
+	}
+	m.pmut.RUnlock()
+
+	sort.Sort(durationList(offsets))
+	mid := len(offsets) / 2
+	if len(offsets)%2 == 0 {
+		return (offsets[mid-1] + offsets[mid]) / 2
+	}
+	return offsets[mid]
+}
+
+type durationList []time.Duration
+
+func (l durationList) Len() int           { return len(l) }
+func (l durationList) Swap(a, b int)      { l[a], l[b] = l[b], l[a] }
+func (l durationList) Less(a, b int) bool { return l[a] < l[b] }
+
 func (m *Model) DownloadProgress(device protocol.DeviceID, folder string, updates []protocol.FileDownloadProgressUpdate) {
 	if !m.folderSharedWith(folder, device) {
 		return
@@ -1436,6 +1730,27 @@ func (m *Model) deviceStatRef(deviceID protocol.DeviceID) *stats.DeviceStatistic
 	return sr
 }
 
+// DeviceConnections returns the history of connections to the device, the
+// current one, if any, first.
+func (m *Model) DeviceConnections(deviceID protocol.DeviceID) ([]stats.ConnectionRecord, error) {
+	if _, ok := m.cfg.Devices()[deviceID]; !ok {
+		return nil, errDeviceUnknown
+	}
+
+	var records []stats.ConnectionRecord
+	m.pmut.RLock()
+	if record, ok := m.connRecords[deviceID]; ok {
+		conn := m.conn[deviceID]
+		connStats := conn.Statistics()
+		record.InBytes = connStats.InBytesTotal
+		record.OutBytes = connStats.OutBytesTotal
+		records = append(records, record)
+	}
+	m.pmut.RUnlock()
+
+	return append(records, m.deviceStatRef(deviceID).GetConnections()...), nil
+}
+
 func (m *Model) deviceWasSeen(deviceID protocol.DeviceID) {
 	m.deviceStatRef(deviceID).WasSeen()
 }
@@ -1456,7 +1771,7 @@ func (m *Model) receivedFile(folder string, file protocol.FileInfo) {
 	m.folderStatRef(folder).ReceivedFile(file.Name, file.IsDeleted())
 }
 
-func sendIndexes(conn protocol.Connection, folder string, fs *db.FileSet, ignores *ignore.Matcher, startSequence int64, dbLocation string, dropSymlinks bool) {
+func sendIndexes(conn protocol.Connection, folder string, fs *db.FileSet, ignores *ignore.Matcher, startSequence int64, dbLocation string, dropSymlinks, invalidPlaceholders bool, stop <-chan struct{}) {
 	deviceID := conn.ID()
 	name := conn.Name()
 	var err error
@@ -1464,7 +1779,7 @@ func sendIndexes(conn protocol.Connection, folder string, fs *db.FileSet, ignore
 	l.Debugf("sendIndexes for %s-%s/%q starting (slv=%d)", deviceID, name, folder, startSequence)
 	defer l.Debugf("sendIndexes for %s-%s/%q exiting: %v", deviceID, name, folder, err)
 
-	minSequence, err := sendIndexTo(startSequence, conn, folder, fs, ignores, dbLocation, dropSymlinks)
+	minSequence, err := sendIndexTo(startSequence, conn, folder, fs, ignores, dbLocation, dropSymlinks, invalidPlaceholders)
 
 	// Subscribe to LocalIndexUpdated (we have new information to send) and
 	// DeviceDisconnected (it might be us who disconnected, so we should
@@ -1473,6 +1788,13 @@ func sendIndexes(conn protocol.Connection, folder string, fs *db.FileSet, ignore
 	defer events.Default.Unsubscribe(sub)
 
 	for err == nil {
+		select {
+		case <-stop:
+			// The folder changed; someone else takes over.
+			return
+		default:
+		}
+
 		if conn.Closed() {
 			// Our work is done.
 			return
@@ -1487,7 +1809,7 @@ func sendIndexes(conn protocol.Connection, folder string, fs *db.FileSet, ignore
 			continue
 		}
 
-		minSequence, err = sendIndexTo(minSequence, conn, folder, fs, ignores, dbLocation, dropSymlinks)
+		minSequence, err = sendIndexTo(minSequence, conn, folder, fs, ignores, dbLocation, dropSymlinks, invalidPlaceholders)
 
 		// Wait a short amount of time before entering the next loop. If there
 		// are continuous changes happening to the local index, this gives us
@@ -1496,7 +1818,7 @@ func sendIndexes(conn protocol.Connection, folder string, fs *db.FileSet, ignore
 	}
 }
 
-func sendIndexTo(minSequence int64, conn protocol.Connection, folder string, fs *db.FileSet, ignores *ignore.Matcher, dbLocation string, dropSymlinks bool) (int64, error) {
+func sendIndexTo(minSequence int64, conn protocol.Connection, folder string, fs *db.FileSet, ignores *ignore.Matcher, dbLocation string, dropSymlinks, invalidPlaceholders bool) (int64, error) {
 	deviceID := conn.ID()
 	name := conn.Name()
 	batch := make([]protocol.FileInfo, 0, indexBatchSize)
@@ -1526,6 +1848,12 @@ func sendIndexTo(minSequence int64, conn protocol.Connection, folder string, fs
 			return true
 		}
 
+		if invalidPlaceholders && f.IsPlaceholder() {
+			// Devices that don't know about placeholders would request
+			// their data from us, so to them they're invalid.
+			f.Invalid = true
+		}
+
 		sorter.Append(f)
 		return true
 	})
@@ -1592,12 +1920,15 @@ func (m *Model) updateLocalsFromPulling(folder string, fs []protocol.FileInfo) {
 func (m *Model) updateLocals(folder string, fs []protocol.FileInfo) {
 	m.fmut.RLock()
 	files := m.folderFiles[folder]
+	content := m.folderContents[folder]
 	m.fmut.RUnlock()
 	if files == nil {
 		// The folder doesn't exist.
 		return
 	}
-	files.Update(protocol.LocalDeviceID, fs)
+	content.update(files, fs, time.Now(), func() {
+		files.Update(protocol.LocalDeviceID, fs)
+	})
 
 	filenames := make([]string, len(fs))
 	for i, file := range fs {
@@ -1653,7 +1984,7 @@ func (m *Model) diskChangeDetected(folderCfg config.FolderConfiguration, files [
 	}
 }
 
-func (m *Model) requestGlobal(deviceID protocol.DeviceID, folder, name string, offset int64, size int, hash []byte, fromTemporary bool) ([]byte, error) {
+func (m *Model) requestGlobal(deviceID protocol.DeviceID, folder, name string, offset int64, size int, hash []byte, fromTemporary, routed bool) ([]byte, error) {
 	m.pmut.RLock()
 	nc, ok := m.conn[deviceID]
 	m.pmut.RUnlock()
@@ -1662,9 +1993,9 @@ func (m *Model) requestGlobal(deviceID protocol.DeviceID, folder, name string, o
 		return nil, fmt.Errorf("requestGlobal: no such device: %s", deviceID)
 	}
 
-	l.Debugf("%v REQ(out): %s: %q / %q o=%d s=%d h=%x ft=%t", m, deviceID, folder, name, offset, size, hash, fromTemporary)
+	l.Debugf("%v REQ(out): %s: %q / %q o=%d s=%d h=%x ft=%t r=%t", m, deviceID, folder, name, offset, size, hash, fromTemporary, routed)
 
-	return nc.Request(folder, name, offset, size, hash, fromTemporary)
+	return nc.Request(folder, name, offset, size, hash, fromTemporary, routed)
 }
 
 func (m *Model) ScanFolders() map[string]error {
@@ -1726,6 +2057,17 @@ func (m *Model) ScanFolderSubdirs(folder string, subs []string) error {
 }
 
 func (m *Model) internalScanFolderSubdirs(folder string, subDirs []string) error {
+	return m.internalScanFolder(folder, subDirs, false)
+}
+
+// internalScanFolderChanges scans just the given paths, as listed by the
+// change journal, rather than walking the folder. Directories are only
+// walked when new.
+func (m *Model) internalScanFolderChanges(folder string, changes []string) error {
+	return m.internalScanFolder(folder, changes, true)
+}
+
+func (m *Model) internalScanFolder(folder string, subDirs []string, changesOnly bool) error {
 	for i := 0; i < len(subDirs); i++ {
 		sub := osutil.NativeFilename(subDirs[i])
 
@@ -1752,6 +2094,7 @@ func (m *Model) internalScanFolderSubdirs(folder string, subDirs []string) error
 	folderCfg := m.folderCfgs[folder]
 	ignores := m.folderIgnores[folder]
 	runner, ok := m.folderRunners[folder]
+	limiter := m.folderLimiters[folder]
 	m.fmut.Unlock()
 	mtimefs := fs.MtimeFS()
 
@@ -1774,18 +2117,36 @@ func (m *Model) internalScanFolderSubdirs(folder string, subDirs []string) error
 		return errFolderMissing
 	}
 
+	if folderCfg.SnapshotOf != "" {
+		// The index of a snapshot was frozen when it was taken.
+		return nil
+	}
+
 	if err := m.CheckFolderHealth(folder); err != nil {
 		runner.setError(err)
 		l.Infof("Stopping folder %s due to error: %s", folderCfg.Description(), err)
 		return err
 	}
 
+	oldIgnores := ignores.Hash()
 	if err := ignores.Load(filepath.Join(folderCfg.Path(), ".stignore")); err != nil && !os.IsNotExist(err) {
 		err = fmt.Errorf("loading ignores: %v", err)
 		runner.setError(err)
 		l.Infof("Stopping folder %s due to error: %s", folderCfg.Description(), err)
 		return err
 	}
+	if folderCfg.SharedIgnores && oldIgnores != "" && ignores.Hash() != oldIgnores {
+		// Edited by hand, so the others haven't been told yet.
+		m.sendFolderSettings(folder)
+	}
+
+	// The scanner gets the changed paths as they are, while the check for
+	// deleted files below covers them and everything under them.
+	var changes []string
+	if changesOnly {
+		changes = append([]string{}, subDirs...)
+	}
+	nothingChanged := changesOnly && len(changes) == 0
 
 	// Clean the list of subitems to ensure that we start at a known
 	// directory, and don't scan subdirectories of things we've already
@@ -1800,14 +2161,28 @@ func (m *Model) internalScanFolderSubdirs(folder string, subDirs []string) error
 	cancel := make(chan struct{})
 	defer close(cancel)
 
+	// A full scan resumes from where an interrupted one got, unless the
+	// ignore patterns have changed since.
+	var resume string
+	var checkpoints *scanner.Checkpoints
+	if folderCfg.ScanCheckpointS > 0 && !changesOnly && len(subDirs) == 0 {
+		if path, hash, ok := fs.ScanCheckpoint(); ok && hash == ignores.Hash() {
+			l.Infof("Resuming the scan of folder %s after %q", folderCfg.Description(), path)
+			resume = path
+		}
+		checkpoints = scanner.NewCheckpoints()
+	}
+	lastCheckpoint := time.Now()
+
 	runner.setState(FolderScanning)
 
 	fchan, err := scanner.Walk(scanner.Config{
 		Folder:                folderCfg.ID,
 		Dir:                   folderCfg.Path(),
 		Subs:                  subDirs,
+		Changes:               changes,
 		Matcher:               ignores,
-		BlockSize:             protocol.BlockSize,
+		BlockSize:             m.scanBlockSize(folderCfg),
 		TempLifetime:          time.Duration(m.cfg.Options().KeepTemporariesH) * time.Hour,
 		CurrentFiler:          cFiler{m, folder},
 		Lstater:               mtimefs,
@@ -1815,9 +2190,15 @@ func (m *Model) internalScanFolderSubdirs(folder string, subDirs []string) error
 		AutoNormalize:         folderCfg.AutoNormalize,
 		Hashers:               m.numHashers(folder),
 		ShortID:               m.shortID,
+		ClockSkew:             m.localClockSkew(),
 		ProgressTickIntervalS: folderCfg.ScanProgressIntervalS,
 		Cancel:                cancel,
 		UseWeakHashes:         weakhash.Enabled,
+		DetectAppends:         folderCfg.DetectAppends,
+		Throttle:              limiter.diskThrottle(),
+		Resume:                resume,
+		Checkpoints:           checkpoints,
+		HashWhileWalking:      folderCfg.ScanStreamBatch > 0,
 	})
 
 	if err != nil {
@@ -1833,6 +2214,10 @@ func (m *Model) internalScanFolderSubdirs(folder string, subDirs []string) error
 
 	batchSizeFiles := 100
 	batchSizeBlocks := 2048 // about 256 MB
+	if folderCfg.ScanStreamBatch > 0 {
+		// Smaller batches get to the other devices sooner.
+		batchSizeFiles = folderCfg.ScanStreamBatch
+	}
 
 	batch := make([]protocol.FileInfo, 0, batchSizeFiles)
 	blocksHandled := 0
@@ -1846,6 +2231,13 @@ func (m *Model) internalScanFolderSubdirs(folder string, subDirs []string) error
 			m.updateLocalsFromScanning(folder, batch)
 			batch = batch[:0]
 			blocksHandled = 0
+
+			// All we've been given so far has been committed, so the
+			// scan can resume from the walker's position.
+			if checkpoints != nil && time.Since(lastCheckpoint) > time.Duration(folderCfg.ScanCheckpointS)*time.Second {
+				fs.SetScanCheckpoint(checkpoints.Position(), ignores.Hash())
+				lastCheckpoint = time.Now()
+			}
 		}
 		batch = append(batch, f)
 		blocksHandled += len(f.Blocks)
@@ -1857,8 +2249,11 @@ func (m *Model) internalScanFolderSubdirs(folder string, subDirs []string) error
 	} else if len(batch) > 0 {
 		m.updateLocalsFromScanning(folder, batch)
 	}
+	if checkpoints != nil {
+		fs.SetScanCheckpoint("", "")
+	}
 
-	if len(subDirs) == 0 {
+	if len(subDirs) == 0 && !nothingChanged {
 		// If we have no specific subdirectories to traverse, set it to one
 		// empty prefix so we traverse the entire folder contents once.
 		subDirs = []string{""}
@@ -1885,25 +2280,20 @@ func (m *Model) internalScanFolderSubdirs(folder string, subDirs []string) error
 			case !f.IsInvalid() && ignores.Match(f.Name).IsIgnored():
 				// File was valid at last pass but has been ignored. Set invalid bit.
 				l.Debugln("setting invalid bit on ignored", f)
-				nf := protocol.FileInfo{
-					Name:          f.Name,
-					Type:          f.Type,
-					Size:          f.Size,
-					ModifiedS:     f.ModifiedS,
-					ModifiedNs:    f.ModifiedNs,
-					ModifiedBy:    m.id.Short(),
-					Permissions:   f.Permissions,
-					NoPermissions: f.NoPermissions,
-					Invalid:       true,
-					Version:       f.Version, // The file is still the same, so don't bump version
-				}
-				batch = append(batch, nf)
+				batch = append(batch, m.ignoredFileInfo(f))
 
 			case !f.IsInvalid() && !f.IsDeleted():
 				// The file is valid and not deleted. Lets check if it's
 				// still here.
 
-				if _, err := mtimefs.Lstat(filepath.Join(folderCfg.Path(), f.Name)); err != nil {
+				if info, err := mtimefs.Lstat(filepath.Join(folderCfg.Path(), f.Name)); err == nil {
+					// It is, but may now be ignored by a pattern with
+					// predicates on its size or times.
+					if ignores.MatchInfo(f.Name, info).IsIgnored() {
+						l.Debugln("setting invalid bit on ignored", f)
+						batch = append(batch, m.ignoredFileInfo(f))
+					}
+				} else {
 					// We don't specifically verify that the error is
 					// os.IsNotExist because there is a corner case when a
 					// directory is suddenly transformed into a file. When that
@@ -1912,14 +2302,15 @@ func (m *Model) internalScanFolderSubdirs(folder string, subDirs []string) error
 					// directory") when we try to Lstat() them.
 
 					nf := protocol.FileInfo{
-						Name:       f.Name,
-						Type:       f.Type,
-						Size:       0,
-						ModifiedS:  f.ModifiedS,
-						ModifiedNs: f.ModifiedNs,
-						ModifiedBy: m.id.Short(),
-						Deleted:    true,
-						Version:    f.Version.Update(m.shortID),
+						Name:        f.Name,
+						Type:        f.Type,
+						Size:        0,
+						ModifiedS:   f.ModifiedS,
+						ModifiedNs:  f.ModifiedNs,
+						ModifiedBy:  m.id.Short(),
+						ClockSkewMs: f.ClockSkewMs,
+						Deleted:     true,
+						Version:     f.Version.Update(m.shortID),
 					}
 
 					batch = append(batch, nf)
@@ -1943,9 +2334,53 @@ func (m *Model) internalScanFolderSubdirs(folder string, subDirs []string) error
 
 	m.folderStatRef(folder).ScanCompleted()
 	runner.setState(FolderIdle)
+
+	if folderCfg.CaseCollisions != config.CaseCollisionReport {
+		m.repairCaseCollisionsAfterScan(folder, folderCfg.CaseCollisions)
+	}
 	return nil
 }
 
+// scanBlockSize returns the smallest block size to hash the files of the
+// folder in. Blocks smaller than the standard size are only used when every
+// device sharing the folder has told us in its hello that it understands
+// them, as older devices would take such files for broken.
+func (m *Model) scanBlockSize(folderCfg config.FolderConfiguration) int {
+	blockSize := folderCfg.MinBlockSize()
+	if blockSize == protocol.BlockSize {
+		return blockSize
+	}
+
+	m.pmut.RLock()
+	defer m.pmut.RUnlock()
+	for _, device := range folderCfg.DeviceIDs() {
+		if device == m.id {
+			continue
+		}
+		if !m.helloMessages[device].SmallBlocks {
+			return protocol.BlockSize
+		}
+	}
+	return blockSize
+}
+
+// ignoredFileInfo returns the file, valid at the last scan, as now ignored.
+func (m *Model) ignoredFileInfo(f db.FileInfoTruncated) protocol.FileInfo {
+	return protocol.FileInfo{
+		Name:          f.Name,
+		Type:          f.Type,
+		Size:          f.Size,
+		ModifiedS:     f.ModifiedS,
+		ModifiedNs:    f.ModifiedNs,
+		ModifiedBy:    m.id.Short(),
+		ClockSkewMs:   f.ClockSkewMs,
+		Permissions:   f.Permissions,
+		NoPermissions: f.NoPermissions,
+		Invalid:       true,
+		Version:       f.Version, // The file is still the same, so don't bump version
+	}
+}
+
 func (m *Model) DelayScan(folder string, next time.Duration) {
 	m.fmut.Lock()
 	runner, ok := m.folderRunners[folder]
@@ -1964,12 +2399,17 @@ func (m *Model) numHashers(folder string) int {
 	numFolders := len(m.folderCfgs)
 	m.fmut.Unlock()
 
+	profile := m.cfg.Options().ActivePowerProfile.Settings()
+	if profile.MaxHashers > 0 && (folderCfg.Hashers <= 0 || folderCfg.Hashers > profile.MaxHashers) {
+		return profile.MaxHashers
+	}
+
 	if folderCfg.Hashers > 0 {
 		// Specific value set in the config, use that.
 		return folderCfg.Hashers
 	}
 
-	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+	if !profile.AllCoresHashing && (runtime.GOOS == "windows" || runtime.GOOS == "darwin") {
 		// Interactive operating systems; don't load the system too heavily by
 		// default.
 		return 1
@@ -1985,10 +2425,18 @@ func (m *Model) numHashers(folder string) int {
 	return 1
 }
 
+// rescanIntervalFactor returns what the folder rescan intervals are to be
+// multiplied by, as the power profile says.
+func (m *Model) rescanIntervalFactor() int {
+	return m.cfg.Options().ActivePowerProfile.Settings().RescanIntervalFactor
+}
+
 // generateClusterConfig returns a ClusterConfigMessage that is correct for
 // the given peer device
 func (m *Model) generateClusterConfig(device protocol.DeviceID) protocol.ClusterConfig {
-	var message protocol.ClusterConfig
+	message := protocol.ClusterConfig{
+		AcceptsDeltas: true,
+	}
 
 	m.fmut.RLock()
 	// The list of folders in the message is sorted, so we always get the
@@ -1997,51 +2445,60 @@ func (m *Model) generateClusterConfig(device protocol.DeviceID) protocol.Cluster
 	sort.Strings(folders)
 
 	for _, folder := range folders {
-		folderCfg := m.cfg.Folders()[folder]
-		fs := m.folderFiles[folder]
-
-		protocolFolder := protocol.Folder{
-			ID:                 folder,
-			Label:              folderCfg.Label,
-			ReadOnly:           folderCfg.Type == config.FolderTypeSendOnly,
-			IgnorePermissions:  folderCfg.IgnorePerms,
-			IgnoreDelete:       folderCfg.IgnoreDelete,
-			DisableTempIndexes: folderCfg.DisableTempIndexes,
-			Paused:             folderCfg.Paused,
-		}
-
-		// Devices are sorted, so we always get the same order.
-		for _, device := range m.folderDevices.sortedDevices(folder) {
-			deviceCfg := m.cfg.Devices()[device]
-
-			var indexID protocol.IndexID
-			var maxSequence int64
-			if device == m.id {
-				indexID = fs.IndexID(protocol.LocalDeviceID)
-				maxSequence = fs.Sequence(protocol.LocalDeviceID)
-			} else {
-				indexID = fs.IndexID(device)
-				maxSequence = fs.Sequence(device)
-			}
+		message.Folders = append(message.Folders, m.protocolFolderLocked(folder))
+	}
+	m.fmut.RUnlock()
 
-			protocolDevice := protocol.Device{
-				ID:          device,
-				Name:        deviceCfg.Name,
-				Addresses:   deviceCfg.Addresses,
-				Compression: deviceCfg.Compression,
-				CertName:    deviceCfg.CertName,
-				Introducer:  deviceCfg.Introducer,
-				IndexID:     indexID,
-				MaxSequence: maxSequence,
-			}
+	return message
+}
 
-			protocolFolder.Devices = append(protocolFolder.Devices, protocolDevice)
+// protocolFolderLocked returns the cluster config description of the
+// folder.
+func (m *Model) protocolFolderLocked(folder string) protocol.Folder {
+	folderCfg := m.cfg.Folders()[folder]
+	fs := m.folderFiles[folder]
+
+	protocolFolder := protocol.Folder{
+		ID:                 folder,
+		Label:              folderCfg.Label,
+		ReadOnly:           folderCfg.Type == config.FolderTypeSendOnly,
+		IgnorePermissions:  folderCfg.IgnorePerms,
+		IgnoreDelete:       folderCfg.IgnoreDelete,
+		DisableTempIndexes: folderCfg.DisableTempIndexes,
+		Paused:             folderCfg.Paused,
+		Settings:           folderSettings(folderCfg),
+		SharedIgnores:      sharedIgnores(folderCfg),
+	}
+
+	// Devices are sorted, so we always get the same order.
+	for _, device := range m.folderDevices.sortedDevices(folder) {
+		deviceCfg := m.cfg.Devices()[device]
+
+		var indexID protocol.IndexID
+		var maxSequence int64
+		if device == m.id {
+			indexID = fs.IndexID(protocol.LocalDeviceID)
+			maxSequence = fs.Sequence(protocol.LocalDeviceID)
+		} else {
+			indexID = fs.IndexID(device)
+			maxSequence = fs.Sequence(device)
 		}
-		message.Folders = append(message.Folders, protocolFolder)
+
+		protocolDevice := protocol.Device{
+			ID:          device,
+			Name:        deviceCfg.Name,
+			Addresses:   deviceCfg.Addresses,
+			Compression: deviceCfg.Compression,
+			CertName:    deviceCfg.CertName,
+			Introducer:  deviceCfg.Introducer,
+			IndexID:     indexID,
+			MaxSequence: maxSequence,
+		}
+
+		protocolFolder.Devices = append(protocolFolder.Devices, protocolDevice)
 	}
-	m.fmut.RUnlock()
 
-	return message
+	return protocolFolder
 }
 
 func (m *Model) State(folder string) (string, time.Time, error) {
@@ -2199,7 +2656,68 @@ func (m *Model) GlobalDirectoryTree(folder, prefix string, levels int, dirsonly
 	return output
 }
 
-func (m *Model) Availability(folder, file string, version protocol.Vector, block protocol.BlockInfo) []Availability {
+// GlobalNameCollisions returns groups of files in the global index of the
+// folder whose names are distinct, but become the same when compared case
+// insensitively and after Unicode normalization. Such files can't coexist
+// on case insensitive or normalizing filesystems, as on Windows and macOS.
+// Files that collide only because a parent directory does are left out, as
+// the parent directories are reported themselves.
+func (m *Model) GlobalNameCollisions(folder string) ([][]string, error) {
+	m.fmut.RLock()
+	files, ok := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil, errFolderMissing
+	}
+
+	names := make(map[string][]string)
+	files.WithGlobalTruncated(func(fi db.FileIntf) bool {
+		f := fi.(db.FileInfoTruncated)
+		if f.IsInvalid() || f.IsDeleted() {
+			return true
+		}
+		key := collisionKey(f.Name)
+		names[key] = append(names[key], f.Name)
+		return true
+	})
+
+	var collisions [][]string
+	for _, group := range names {
+		if len(group) < 2 || sameBaseNames(group) {
+			continue
+		}
+		sort.Strings(group)
+		collisions = append(collisions, group)
+	}
+	sort.Sort(nameGroupList(collisions))
+
+	return collisions, nil
+}
+
+// collisionKey returns the name as case insensitive, normalizing
+// filesystems would see it. Names in the database are already NFC
+// normalized, but we don't rely on that here.
+func collisionKey(name string) string {
+	return strings.ToLower(norm.NFC.String(name))
+}
+
+func sameBaseNames(names []string) bool {
+	base := filepath.Base(names[0])
+	for _, name := range names[1:] {
+		if filepath.Base(name) != base {
+			return false
+		}
+	}
+	return true
+}
+
+type nameGroupList [][]string
+
+func (l nameGroupList) Len() int           { return len(l) }
+func (l nameGroupList) Less(a, b int) bool { return l[a][0] < l[b][0] }
+func (l nameGroupList) Swap(a, b int)      { l[a], l[b] = l[b], l[a] }
+
+func (m *Model) Availability(folder string, file protocol.FileInfo, block protocol.BlockInfo) []Availability {
 	// The slightly unusual locking sequence here is because we need to hold
 	// pmut for the duration (as the value returned from foldersFiles can
 	// get heavily modified on Close()), but also must acquire fmut before
@@ -2217,8 +2735,9 @@ func (m *Model) Availability(folder, file string, version protocol.Vector, block
 	}
 
 	var availabilities []Availability
+	sources := fs.Availability(file.Name)
 next:
-	for _, device := range fs.Availability(file) {
+	for _, device := range sources {
 		for _, pausedFolder := range m.remotePausedFolders[device] {
 			if pausedFolder == folder {
 				continue next
@@ -2226,16 +2745,22 @@ next:
 		}
 		_, ok := m.conn[device]
 		if ok {
-			availabilities = append(availabilities, Availability{ID: device, FromTemporary: false})
+			availabilities = append(availabilities, Availability{ID: device, FromTemporary: false, ColdStorage: m.helloMessages[device].ColdStorage})
 		}
 	}
 
 	for device := range devices {
-		if m.deviceDownloads[device].Has(folder, file, version, int32(block.Offset/protocol.BlockSize)) {
-			availabilities = append(availabilities, Availability{ID: device, FromTemporary: true})
+		if m.deviceDownloads[device].Has(folder, file.Name, file.Version, int32(block.Offset/int64(file.BlockSize()))) {
+			availabilities = append(availabilities, Availability{ID: device, FromTemporary: true, ColdStorage: m.helloMessages[device].ColdStorage})
 		}
 	}
 
+	if len(availabilities) == 0 && len(sources) > 0 && m.cfg.Options().RouteBlockRequests {
+		// We're not connected to any device that has the file, but may be
+		// able to get it through one that is.
+		availabilities = m.routersPLocked(folder, devices)
+	}
+
 	return availabilities
 }
 
@@ -2250,6 +2775,127 @@ func (m *Model) BringToFront(folder, file string) {
 	}
 }
 
+// Prioritize moves the named files, and those matching any of the glob
+// patterns, to the front of the folder's job queue. Patterns are matched as
+// the folder's priority patterns are.
+func (m *Model) Prioritize(folder string, files, patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%q: %v", pattern, err)
+		}
+	}
+
+	m.fmut.RLock()
+	runner, ok := m.folderRunners[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return errFolderMissing
+	}
+
+	names := make(map[string]bool, len(files))
+	for _, file := range files {
+		names[osutil.NativeFilename(file)] = true
+	}
+	runner.BringMatchingToFront(func(name string) bool {
+		if names[name] {
+			return true
+		}
+		for _, pattern := range patterns {
+			if matchPriorityPattern(pattern, name) {
+				return true
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+// FolderErrors returns the errors syncing files in the folder, in the last
+// pull as well as those ignored until the files change.
+func (m *Model) FolderErrors(folder string) ([]FileError, error) {
+	m.fmut.RLock()
+	runner, ok := m.folderRunners[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil, errFolderMissing
+	}
+	return runner.Errors(), nil
+}
+
+// RetryFolderErrors pulls the files with the errors with the given IDs
+// again right away, including those whose errors were ignored.
+func (m *Model) RetryFolderErrors(folder string, ids []string) error {
+	m.fmut.RLock()
+	runner, ok := m.folderRunners[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return errFolderMissing
+	}
+	runner.RetryErrors(ids)
+	return nil
+}
+
+// IgnoreFolderErrors stops pulling the files with the errors with the
+// given IDs until there's a new version of them.
+func (m *Model) IgnoreFolderErrors(folder string, ids []string) error {
+	m.fmut.RLock()
+	runner, ok := m.folderRunners[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return errFolderMissing
+	}
+	runner.IgnoreErrors(ids)
+	return nil
+}
+
+// PendingDependencies returns the folders that the given folder pulls
+// after and that aren't up to date; those that are paused, busy or stopped,
+// or need something. Folders that don't exist are not waited for.
+func (m *Model) PendingDependencies(folder string) []string {
+	folders := m.cfg.Folders()
+
+	m.fmut.RLock()
+	defer m.fmut.RUnlock()
+
+	var pending []string
+	for _, dep := range folders[folder].PullAfter {
+		cfg, ok := folders[dep]
+		if !ok {
+			continue
+		}
+		if !m.folderUpToDateRLocked(cfg) {
+			pending = append(pending, dep)
+		}
+	}
+	return pending
+}
+
+func (m *Model) folderUpToDateRLocked(cfg config.FolderConfiguration) bool {
+	runner, ok := m.folderRunners[cfg.ID]
+	if !ok {
+		// Paused
+		return false
+	}
+	if state, _, _ := runner.getState(); state != FolderIdle {
+		return false
+	}
+	if cfg.Type == config.FolderTypeSendOnly {
+		// Never needs anything, as far as we're concerned
+		return true
+	}
+
+	needs := false
+	ignores := m.folderIgnores[cfg.ID]
+	m.folderFiles[cfg.ID].WithNeedTruncated(protocol.LocalDeviceID, func(f db.FileIntf) bool {
+		if shouldIgnore(f, ignores, cfg.IgnoreDelete) {
+			return true
+		}
+		needs = true
+		return false
+	})
+	return !needs
+}
+
 // CheckFolderHealth checks the folder for common errors and returns the
 // current folder error, or nil if the folder is healthy.
 func (m *Model) CheckFolderHealth(id string) error {
@@ -2360,6 +3006,12 @@ func (m *Model) ResetFolder(folder string) {
 	db.DropFolder(m.db, folder)
 }
 
+// NewDatabaseBackup prepares a backup of the database, incremental to the
+// backup with the since ID if given.
+func (m *Model) NewDatabaseBackup(since string) (*db.Backup, error) {
+	return m.db.NewBackup(since)
+}
+
 func (m *Model) String() string {
 	return fmt.Sprintf("model@%p", m)
 }
@@ -2403,13 +3055,21 @@ func (m *Model) CommitConfiguration(from, to config.Configuration) bool {
 			m.RestartFolder(toCfg)
 		}
 
-		// Emit the folder pause/resume event
+		// Emit the folder pause/resume event, with who paused it and why:
+		// for a resume, who paused it until then
 		if fromCfg.Paused != toCfg.Paused {
 			eventType := events.FolderResumed
+			pausedBy, reason := fromCfg.PausedBy, fromCfg.PauseReason
 			if toCfg.Paused {
 				eventType = events.FolderPaused
+				pausedBy, reason = toCfg.PausedBy, toCfg.PauseReason
 			}
-			events.Default.Log(eventType, map[string]string{"id": toCfg.ID, "label": toCfg.Label})
+			events.Default.Log(eventType, map[string]string{
+				"id":       toCfg.ID,
+				"label":    toCfg.Label,
+				"pausedBy": pausedBy,
+				"reason":   reason,
+			})
 		}
 	}
 
@@ -2432,9 +3092,17 @@ func (m *Model) CommitConfiguration(from, to config.Configuration) bool {
 		if toCfg.Paused {
 			l.Infoln("Pausing", deviceID)
 			m.close(deviceID)
-			events.Default.Log(events.DevicePaused, map[string]string{"device": deviceID.String()})
+			events.Default.Log(events.DevicePaused, map[string]string{
+				"device":   deviceID.String(),
+				"pausedBy": toCfg.PausedBy,
+				"reason":   toCfg.PauseReason,
+			})
 		} else {
-			events.Default.Log(events.DeviceResumed, map[string]string{"device": deviceID.String()})
+			events.Default.Log(events.DeviceResumed, map[string]string{
+				"device":   deviceID.String(),
+				"pausedBy": fromCfg.PausedBy,
+				"reason":   fromCfg.PauseReason,
+			})
 		}
 	}
 
@@ -2448,6 +3116,8 @@ func (m *Model) CommitConfiguration(from, to config.Configuration) bool {
 	from.Options.MaxRecvKbps = to.Options.MaxRecvKbps
 	from.Options.MaxSendKbps = to.Options.MaxSendKbps
 	from.Options.LimitBandwidthInLan = to.Options.LimitBandwidthInLan
+	from.Options.StandbyFor = to.Options.StandbyFor
+	from.Options.StandbyFolderPath = to.Options.StandbyFolderPath
 	// All of the other generic options require restart. Or at least they may;
 	// removing this check requires going through those options carefully and
 	// making sure there are individual services that handle them correctly.
@@ -8,6 +8,7 @@ package model
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -15,8 +16,11 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -29,6 +33,8 @@ import (
 	"github.com/syncthing/syncthing/lib/protocol"
 	srand "github.com/syncthing/syncthing/lib/rand"
 	"github.com/syncthing/syncthing/lib/scanner"
+	"github.com/syncthing/syncthing/lib/stats"
+	"github.com/syncthing/syncthing/lib/versioner"
 )
 
 var device1, device2 protocol.DeviceID
@@ -100,7 +106,7 @@ func TestRequest(t *testing.T) {
 
 	// Existing, shared file
 	bs = bs[:6]
-	err := m.Request(device1, "default", "foo", 0, nil, false, bs)
+	err := m.Request(device1, "default", "foo", 0, nil, false, false, bs)
 	if err != nil {
 		t.Error(err)
 	}
@@ -109,32 +115,32 @@ func TestRequest(t *testing.T) {
 	}
 
 	// Existing, nonshared file
-	err = m.Request(device2, "default", "foo", 0, nil, false, bs)
+	err = m.Request(device2, "default", "foo", 0, nil, false, false, bs)
 	if err == nil {
 		t.Error("Unexpected nil error on insecure file read")
 	}
 
 	// Nonexistent file
-	err = m.Request(device1, "default", "nonexistent", 0, nil, false, bs)
+	err = m.Request(device1, "default", "nonexistent", 0, nil, false, false, bs)
 	if err == nil {
 		t.Error("Unexpected nil error on insecure file read")
 	}
 
 	// Shared folder, but disallowed file name
-	err = m.Request(device1, "default", "../walk.go", 0, nil, false, bs)
+	err = m.Request(device1, "default", "../walk.go", 0, nil, false, false, bs)
 	if err == nil {
 		t.Error("Unexpected nil error on insecure file read")
 	}
 
 	// Negative offset
-	err = m.Request(device1, "default", "foo", -4, nil, false, bs[:0])
+	err = m.Request(device1, "default", "foo", -4, nil, false, false, bs[:0])
 	if err == nil {
 		t.Error("Unexpected nil error on insecure file read")
 	}
 
 	// Larger block than available
 	bs = bs[:42]
-	err = m.Request(device1, "default", "foo", 0, nil, false, bs)
+	err = m.Request(device1, "default", "foo", 0, nil, false, false, bs)
 	if err == nil {
 		t.Error("Unexpected nil error on insecure file read")
 	}
@@ -227,7 +233,10 @@ type fakeConnection struct {
 	folder                   string
 	model                    *Model
 	indexFn                  func(string, []protocol.FileInfo)
-	requestFn                func(folder, name string, offset int64, size int, hash []byte, fromTemporary bool) ([]byte, error)
+	requestFn                func(folder, name string, offset int64, size int, hash []byte, fromTemporary, routed bool) ([]byte, error)
+	clusterConfigs           []protocol.ClusterConfig
+	manageFn                 func(protocol.ManagementRequest) ([]byte, error)
+	stats                    protocol.Statistics
 	mut                      sync.Mutex
 }
 
@@ -271,16 +280,27 @@ func (f *fakeConnection) IndexUpdate(folder string, fs []protocol.FileInfo) erro
 	return nil
 }
 
-func (f *fakeConnection) Request(folder, name string, offset int64, size int, hash []byte, fromTemporary bool) ([]byte, error) {
+func (f *fakeConnection) Request(folder, name string, offset int64, size int, hash []byte, fromTemporary, routed bool) ([]byte, error) {
 	f.mut.Lock()
 	defer f.mut.Unlock()
 	if f.requestFn != nil {
-		return f.requestFn(folder, name, offset, size, hash, fromTemporary)
+		return f.requestFn(folder, name, offset, size, hash, fromTemporary, routed)
 	}
 	return f.fileData[name], nil
 }
 
-func (f *fakeConnection) ClusterConfig(protocol.ClusterConfig) {}
+func (f *fakeConnection) ClusterConfig(cm protocol.ClusterConfig) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.clusterConfigs = append(f.clusterConfigs, cm)
+}
+
+// clusterConfigsSince returns the cluster configs sent after the first n.
+func (f *fakeConnection) clusterConfigsSince(n int) []protocol.ClusterConfig {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return append([]protocol.ClusterConfig(nil), f.clusterConfigs[n:]...)
+}
 
 func (f *fakeConnection) Ping() bool {
 	f.mut.Lock()
@@ -295,7 +315,9 @@ func (f *fakeConnection) Closed() bool {
 }
 
 func (f *fakeConnection) Statistics() protocol.Statistics {
-	return protocol.Statistics{}
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return f.stats
 }
 
 func (f *fakeConnection) RemoteAddr() net.Addr {
@@ -306,6 +328,13 @@ func (f *fakeConnection) Type() string {
 	return "fake"
 }
 
+func (f *fakeConnection) Manage(req protocol.ManagementRequest) ([]byte, error) {
+	if f.manageFn != nil {
+		return f.manageFn(req)
+	}
+	return nil, nil
+}
+
 func (f *fakeConnection) DownloadProgress(folder string, updates []protocol.FileDownloadProgressUpdate) {
 	f.downloadProgressMessages = append(f.downloadProgressMessages, downloadProgressMessage{
 		folder:  folder,
@@ -373,7 +402,7 @@ func BenchmarkRequestOut(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		data, err := m.requestGlobal(device1, "default", files[i%n].Name, 0, 32, nil, false)
+		data, err := m.requestGlobal(device1, "default", files[i%n].Name, 0, 32, nil, false, false)
 		if err != nil {
 			b.Error(err)
 		}
@@ -401,7 +430,7 @@ func BenchmarkRequestInSingleFile(b *testing.B) {
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		if err := m.Request(device1, "default", "request/for/a/file/in/a/couple/of/dirs/128k", 0, nil, false, buf); err != nil {
+		if err := m.Request(device1, "default", "request/for/a/file/in/a/couple/of/dirs/128k", 0, nil, false, false, buf); err != nil {
 			b.Error(err)
 		}
 	}
@@ -481,6 +510,49 @@ func TestDeviceRename(t *testing.T) {
 	}
 }
 
+func TestDeviceConnectionHistory(t *testing.T) {
+	cfg := config.Wrap("/tmp/test", config.Configuration{
+		Devices: []config.DeviceConfiguration{{DeviceID: device1}},
+		Options: config.OptionsConfiguration{ConnectionHistoryDays: 30},
+	})
+	m := NewModel(cfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+
+	conn := &fakeConnection{id: device1}
+	m.AddConnection(conn, protocol.HelloResult{})
+	conn.stats = protocol.Statistics{InBytesTotal: 100, OutBytesTotal: 200}
+	m.Closed(conn, protocol.ErrTimeout)
+	m.AddConnection(conn, protocol.HelloResult{})
+
+	records, err := m.DeviceConnections(device1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected the current and a closed connection, got %+v", records)
+	}
+	if !records[0].Disconnected.IsZero() || records[0].Type != "fake" {
+		t.Errorf("Unexpected current connection %+v", records[0])
+	}
+	closed := records[1]
+	if closed.Disconnected.IsZero() || closed.Error != protocol.ErrTimeout.Error() || closed.InBytes != 100 || closed.OutBytes != 200 {
+		t.Errorf("Unexpected closed connection %+v", closed)
+	}
+	if summary := stats.SummarizeConnections(records, time.Now()); summary.Connections != 2 || summary.Types["fake"] != 2 || summary.Errors[protocol.ErrTimeout.Error()] != 1 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+
+	if _, err := m.DeviceConnections(device2); err != errDeviceUnknown {
+		t.Errorf("Expected an unknown device, got %v", err)
+	}
+
+	// With no history kept, what there is goes away
+	cfg.SetOptions(config.OptionsConfiguration{})
+	m.Closed(conn, protocol.ErrTimeout)
+	if records, _ := m.DeviceConnections(device1); len(records) != 0 {
+		t.Errorf("Expected no history, got %+v", records)
+	}
+}
+
 func TestClusterConfig(t *testing.T) {
 	cfg := config.New(device1)
 	cfg.Devices = []config.DeviceConfiguration{
@@ -927,6 +999,81 @@ func TestIntroducer(t *testing.T) {
 	}
 }
 
+func TestStandby(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wcfg := config.Wrap("/tmp/test", config.Configuration{
+		Devices: []config.DeviceConfiguration{{DeviceID: protocol.LocalDeviceID}, {DeviceID: device1}},
+		Options: config.OptionsConfiguration{
+			StandbyFor:        device1,
+			StandbyFolderPath: dir,
+		},
+	})
+	m := NewModel(wcfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.ServeBackground()
+	m.AddConnection(&fakeConnection{id: device1}, protocol.HelloResult{})
+
+	// The standby takes the folders of the primary, shared with the
+	// primary only, and the devices they're shared with.
+
+	m.ClusterConfig(device1, protocol.ClusterConfig{
+		Folders: []protocol.Folder{
+			{
+				ID:     "folder1",
+				Paused: true,
+				Devices: []protocol.Device{
+					{ID: device1},
+					{ID: protocol.LocalDeviceID},
+					{ID: device2},
+				},
+			},
+		},
+	})
+
+	if _, ok := wcfg.Device(device2); !ok {
+		t.Error("Expected device2 to be added")
+	}
+	fcfg, ok := wcfg.Folders()["folder1"]
+	if !ok {
+		t.Fatal("Expected folder1 to be mirrored")
+	}
+	if filepath.Clean(fcfg.Path()) != filepath.Join(dir, "folder1") {
+		t.Errorf("Mirrored folder has path %q", fcfg.Path())
+	}
+	if !reflect.DeepEqual(fcfg.DeviceIDs(), []protocol.DeviceID{device1, protocol.LocalDeviceID}) {
+		t.Errorf("Mirrored folder should be shared with the primary only, is shared with %v", fcfg.DeviceIDs())
+	}
+	if fcfg.SettingsFrom != device1 {
+		t.Error("Mirrored folder should take its settings from the primary")
+	}
+	if !reflect.DeepEqual(fcfg.StandbyDevices, []protocol.DeviceID{device2}) || !fcfg.StandbyPaused {
+		t.Errorf("Unexpected standby devices %v, paused %v", fcfg.StandbyDevices, fcfg.StandbyPaused)
+	}
+
+	// Once promoted it shares the folder as the primary did.
+
+	if err := m.PromoteStandby(true); err != nil {
+		t.Fatal(err)
+	}
+	fcfg = wcfg.Folders()["folder1"]
+	if !reflect.DeepEqual(fcfg.DeviceIDs(), []protocol.DeviceID{device1, device2, protocol.LocalDeviceID}) {
+		t.Errorf("Promoted folder should be shared with device2, is shared with %v", fcfg.DeviceIDs())
+	}
+	if !fcfg.Paused || fcfg.SettingsFrom != protocol.EmptyDeviceID || fcfg.StandbyDevices != nil {
+		t.Errorf("Unexpected promoted folder config %+v", fcfg)
+	}
+	if wcfg.Options().StandbyFor != protocol.EmptyDeviceID {
+		t.Error("Promoted device should no longer be a standby")
+	}
+	if err := m.PromoteStandby(true); err != errNotStandby {
+		t.Errorf("Expected errNotStandby, got %v", err)
+	}
+}
+
 func TestIgnores(t *testing.T) {
 	arrEqual := func(a, b []string) bool {
 		if len(a) != len(b) {
@@ -1444,6 +1591,47 @@ func TestGlobalDirectoryTree(t *testing.T) {
 	}
 }
 
+func TestGlobalNameCollisions(t *testing.T) {
+	db := db.OpenMemory()
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db, nil)
+	m.AddFolder(defaultFolderConfig)
+
+	dir := func(name string) protocol.FileInfo {
+		return protocol.FileInfo{Name: name, Type: protocol.FileInfoTypeDirectory, Version: protocol.Vector{}.Update(device1.Short())}
+	}
+
+	m.updateLocalsFromScanning("default", []protocol.FileInfo{
+		dir("Docs"),
+		dir("Docs/readme"),
+		dir("\u00c4pfel"),
+		dir("unique"),
+	})
+	m.folderFiles["default"].Update(device1, []protocol.FileInfo{
+		dir("docs"),
+		dir("docs/readme"),
+		dir("docs/README"),
+		dir("a\u0308pfel"),
+		{Name: "UNIQUE", Deleted: true, Version: protocol.Vector{}.Update(device1.Short())},
+	})
+
+	collisions, err := m.GlobalNameCollisions("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"Docs", "docs"},
+		{"Docs/readme", "docs/README", "docs/readme"},
+		{"\u00c4pfel", "\u00e4pfel"},
+	}
+	if !reflect.DeepEqual(collisions, expected) {
+		t.Errorf("Incorrect collisions\n%q !=\n%q", collisions, expected)
+	}
+
+	if _, err := m.GlobalNameCollisions("nonexistent"); err != errFolderMissing {
+		t.Error("Expected errFolderMissing, got", err)
+	}
+}
+
 func TestGlobalDirectorySelfFixing(t *testing.T) {
 	db := db.OpenMemory()
 	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db, nil)
@@ -1938,6 +2126,49 @@ func TestIssue2782(t *testing.T) {
 	}
 }
 
+func TestPendingDependencies(t *testing.T) {
+	dependent := config.NewFolderConfiguration("dependent", "testdata")
+	dependent.PullAfter = []string{"default", "missing", "paused"}
+	paused := config.NewFolderConfiguration("paused", "testdata")
+	paused.Paused = true
+	cfg := config.Wrap("/tmp/test", config.Configuration{
+		Folders: []config.FolderConfiguration{defaultFolderConfig, dependent, paused},
+		Devices: []config.DeviceConfiguration{config.NewDeviceConfiguration(device1, "device1")},
+	})
+
+	db := db.OpenMemory()
+	m := NewModel(cfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db, nil)
+	m.AddFolder(defaultFolderConfig)
+	m.StartFolder("default")
+
+	// Folders that don't exist aren't waited for, paused ones are
+
+	if deps := m.PendingDependencies("dependent"); !reflect.DeepEqual(deps, []string{"paused"}) {
+		t.Errorf("Unexpected pending dependencies %v", deps)
+	}
+
+	// Busy folders aren't up to date
+
+	m.folderRunners["default"].setState(FolderScanning)
+	if deps := m.PendingDependencies("dependent"); !reflect.DeepEqual(deps, []string{"default", "paused"}) {
+		t.Errorf("Unexpected pending dependencies %v", deps)
+	}
+	m.folderRunners["default"].setState(FolderIdle)
+
+	// Nor are folders that need something
+
+	m.folderFiles["default"].Update(device1, []protocol.FileInfo{
+		{Name: "needed", Type: protocol.FileInfoTypeDirectory, Version: protocol.Vector{}.Update(device1.Short())},
+	})
+	if deps := m.PendingDependencies("dependent"); !reflect.DeepEqual(deps, []string{"default", "paused"}) {
+		t.Errorf("Unexpected pending dependencies %v", deps)
+	}
+
+	if deps := m.PendingDependencies("default"); len(deps) != 0 {
+		t.Errorf("Unexpected pending dependencies %v", deps)
+	}
+}
+
 func TestIndexesForUnknownDevicesDropped(t *testing.T) {
 	dbi := db.OpenMemory()
 
@@ -2089,6 +2320,124 @@ func TestSharedWithClearedOnDisconnect(t *testing.T) {
 	}
 }
 
+func TestClusterConfigDelta(t *testing.T) {
+	otherDir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(otherDir)
+
+	fcfg := config.NewFolderConfiguration("default", "testdata")
+	fcfg.Devices = []config.FolderDeviceConfiguration{{DeviceID: device1}}
+	cfg := config.Configuration{
+		Folders: []config.FolderConfiguration{fcfg},
+		Devices: []config.DeviceConfiguration{
+			config.NewDeviceConfiguration(device1, "device1"),
+		},
+		Options: config.OptionsConfiguration{
+			// Don't remove temporaries directly on startup
+			KeepTemporariesH: 1,
+		},
+	}
+	wcfg := config.Wrap("/tmp/test", cfg)
+
+	m := NewModel(wcfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.StartFolder(fcfg.ID)
+	m.ServeBackground()
+	defer m.Stop()
+
+	conn := &fakeConnection{id: device1}
+	m.AddConnection(conn, protocol.HelloResult{})
+	sent := conn.clusterConfigsSince(0)
+	if len(sent) != 1 || !sent[0].AcceptsDeltas || sent[0].Delta {
+		t.Fatalf("expected a full cluster config accepting deltas, got %+v", sent)
+	}
+	m.ClusterConfig(device1, protocol.ClusterConfig{
+		Folders:       []protocol.Folder{{ID: "default", Devices: []protocol.Device{{ID: device1}}}},
+		AcceptsDeltas: true,
+	})
+
+	// Sharing a new folder sends a delta, and keeps the connection.
+
+	ocfg := config.NewFolderConfiguration("other", otherDir)
+	ocfg.Devices = []config.FolderDeviceConfiguration{{DeviceID: device1}}
+	cfg = cfg.Copy()
+	cfg.Folders = append(cfg.Folders, ocfg)
+	if err := wcfg.Replace(cfg); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // Committer notification happens in a separate routine
+
+	if conn.Closed() {
+		t.Fatal("connection should not be closed")
+	}
+	// Other folders may be restarted and sent as well, as the config was
+	// cleaned when replaced.
+	added := false
+	for _, cm := range conn.clusterConfigsSince(len(sent)) {
+		if !cm.Delta {
+			t.Fatalf("expected only deltas, got %+v", cm)
+		}
+		for _, folder := range cm.Folders {
+			added = added || folder.ID == "other"
+		}
+	}
+	if !added {
+		t.Fatal("no delta adding the new folder")
+	}
+	sent = conn.clusterConfigsSince(0)
+
+	// A delta from the other side is merged into what we have, and index
+	// sending starts for the folder.
+
+	m.ClusterConfig(device1, protocol.ClusterConfig{
+		Folders: []protocol.Folder{{ID: "other", Devices: []protocol.Device{{ID: device1}}}},
+		Delta:   true,
+	})
+	remote, ok := m.RemoteClusterConfig(device1)
+	if !ok || len(remote.Folders) != 2 || !remote.AcceptsDeltas {
+		t.Fatalf("unexpected merged cluster config %+v", remote)
+	}
+	m.fmut.RLock()
+	_, sending := m.indexSenders["other"][device1]
+	m.fmut.RUnlock()
+	if !sending {
+		t.Error("should be sending index data for the new folder")
+	}
+
+	// Unsharing the folder sends a delta removing it.
+
+	cfg = cfg.Copy()
+	cfg.Folders = cfg.Folders[:1]
+	if err := wcfg.Replace(cfg); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if conn.Closed() {
+		t.Fatal("connection should not be closed")
+	}
+	removed := false
+	for _, cm := range conn.clusterConfigsSince(len(sent)) {
+		if !cm.Delta {
+			t.Fatalf("expected only deltas, got %+v", cm)
+		}
+		for _, folder := range cm.RemovedFolders {
+			removed = removed || folder == "other"
+		}
+	}
+	if !removed {
+		t.Fatal("no delta removing the folder")
+	}
+	m.fmut.RLock()
+	_, sending = m.indexSenders["other"][device1]
+	m.fmut.RUnlock()
+	if sending {
+		t.Error("should have stopped sending index data for the removed folder")
+	}
+}
+
 func TestIssue3496(t *testing.T) {
 	t.Skip("This test deletes files that the other test depend on. Needs fixing.")
 
@@ -2232,7 +2581,7 @@ func TestNoRequestsFromPausedDevices(t *testing.T) {
 	files.Update(device1, []protocol.FileInfo{file})
 	files.Update(device2, []protocol.FileInfo{file})
 
-	avail := m.Availability("default", file.Name, file.Version, file.Blocks[0])
+	avail := m.Availability("default", file, file.Blocks[0])
 	if len(avail) != 0 {
 		t.Errorf("should not be available, no connections")
 	}
@@ -2242,7 +2591,7 @@ func TestNoRequestsFromPausedDevices(t *testing.T) {
 
 	// !!! This is not what I'd expect to happen, as we don't even know if the peer has the original index !!!
 
-	avail = m.Availability("default", file.Name, file.Version, file.Blocks[0])
+	avail = m.Availability("default", file, file.Blocks[0])
 	if len(avail) != 2 {
 		t.Errorf("should have two available")
 	}
@@ -2262,7 +2611,7 @@ func TestNoRequestsFromPausedDevices(t *testing.T) {
 	m.ClusterConfig(device1, cc)
 	m.ClusterConfig(device2, cc)
 
-	avail = m.Availability("default", file.Name, file.Version, file.Blocks[0])
+	avail = m.Availability("default", file, file.Blocks[0])
 	if len(avail) != 2 {
 		t.Errorf("should have two available")
 	}
@@ -2270,7 +2619,7 @@ func TestNoRequestsFromPausedDevices(t *testing.T) {
 	m.Closed(&fakeConnection{id: device1}, errDeviceUnknown)
 	m.Closed(&fakeConnection{id: device2}, errDeviceUnknown)
 
-	avail = m.Availability("default", file.Name, file.Version, file.Blocks[0])
+	avail = m.Availability("default", file, file.Blocks[0])
 	if len(avail) != 0 {
 		t.Errorf("should have no available")
 	}
@@ -2285,7 +2634,7 @@ func TestNoRequestsFromPausedDevices(t *testing.T) {
 	ccp.Folders[0].Paused = true
 	m.ClusterConfig(device1, ccp)
 
-	avail = m.Availability("default", file.Name, file.Version, file.Blocks[0])
+	avail = m.Availability("default", file, file.Blocks[0])
 	if len(avail) != 1 {
 		t.Errorf("should have one available")
 	}
@@ -2464,3 +2813,1269 @@ func (fakeAddr) Network() string {
 func (fakeAddr) String() string {
 	return "address"
 }
+
+func TestClockSkew(t *testing.T) {
+	cfg := config.Wrap("/tmp/test", config.Configuration{
+		Options: config.OptionsConfiguration{MaxClockSkewS: 60},
+	})
+	m := NewModel(cfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+
+	if skew := m.clockSkew(protocol.HelloResult{}); skew != 0 {
+		t.Errorf("skew without timestamp should be zero, not %v", skew)
+	}
+	if skew := m.clockSkew(protocol.HelloResult{Timestamp: time.Now().Add(30 * time.Second)}); skew != 0 {
+		t.Errorf("skew within tolerance should be zero, not %v", skew)
+	}
+	if skew := m.clockSkew(protocol.HelloResult{Timestamp: time.Now().Add(-time.Hour)}); skew > -59*time.Minute || skew%time.Second != 0 {
+		t.Errorf("unexpected skew %v for a clock one hour behind", skew)
+	}
+	if skew := m.clockSkew(protocol.HelloResult{Timestamp: time.Now().Add(time.Hour)}); skew < 59*time.Minute || skew%time.Second != 0 {
+		t.Errorf("unexpected skew %v for a clock one hour ahead", skew)
+	}
+
+	// Our own skew is the median of all of ours, so with one other device
+	// whose clock is an hour ahead, ours is half an hour behind. With
+	// another device agreeing with us, it's none.
+	if skew := m.localClockSkew(); skew != 0 {
+		t.Errorf("unexpected local skew %v without connections", skew)
+	}
+	m.conn[device1] = nil
+	m.clockSkews[device1] = time.Hour
+	if skew := m.localClockSkew(); skew != -30*time.Minute {
+		t.Errorf("unexpected local skew %v, expected -30m", skew)
+	}
+	m.conn[device2] = nil
+	if skew := m.localClockSkew(); skew != 0 {
+		t.Errorf("unexpected local skew %v, expected none", skew)
+	}
+}
+
+func TestIntroducerFolderPolicy(t *testing.T) {
+	device3, _ := protocol.DeviceIDFromString("LGFPDIT-7SKNNJL-VJZA4FC-7QNCRKA-CE753K7-2BW5QDK-2FOZ7FR-FEP57QJ")
+
+	cfg := config.Configuration{
+		Devices: []config.DeviceConfiguration{
+			{
+				DeviceID:       device1,
+				Introducer:     true,
+				AllowedFolders: []string{"folder1"},
+			},
+		},
+		Folders: []config.FolderConfiguration{
+			{
+				ID: "folder1",
+				Devices: []config.FolderDeviceConfiguration{
+					{DeviceID: device1},
+				},
+			},
+			{
+				ID: "folder2",
+			},
+		},
+	}
+	wcfg := config.Wrap("/tmp/test", cfg)
+	m := NewModel(wcfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	for _, folder := range cfg.Folders {
+		m.AddFolder(folder)
+	}
+	m.ServeBackground()
+	defer m.Stop()
+	m.AddConnection(&fakeConnection{id: device1}, protocol.HelloResult{})
+
+	m.ClusterConfig(device1, protocol.ClusterConfig{
+		Folders: []protocol.Folder{
+			{
+				ID:      "folder1",
+				Devices: []protocol.Device{{ID: device2}},
+			},
+			{
+				ID:      "folder2",
+				Devices: []protocol.Device{{ID: device3}},
+			},
+		},
+	})
+
+	if dev, ok := wcfg.Device(device2); !ok {
+		t.Error("device 2 should have been introduced")
+	} else if len(dev.AllowedFolders) != 1 || dev.AllowedFolders[0] != "folder1" {
+		t.Error("device 2 should inherit the introducer's folder policy, not", dev.AllowedFolders)
+	}
+	if _, ok := wcfg.Device(device3); ok {
+		t.Error("device 3 should not have been introduced via a disallowed folder")
+	}
+	if len(wcfg.Folders()["folder2"].Devices) != 0 {
+		t.Error("folder 2 should not be shared with anyone")
+	}
+
+	// The introduced device gets its own copy of the policy
+	introducer, _ := wcfg.Device(device1)
+	introduced := m.introduceDevice(protocol.Device{ID: device3}, introducer)
+	introduced.AllowedFolders[0] = "folder2"
+	if introducer.AllowedFolders[0] != "folder1" {
+		t.Error("introducer's folder policy changed along with the introduced device's")
+	}
+}
+
+func TestProbeFolder(t *testing.T) {
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+
+	cfg := m.probeFolder(defaultFolderConfig)
+	caps, ok := m.FolderCapabilities()["default"]
+	if !ok {
+		t.Fatal("expected capabilities for the default folder")
+	}
+	if cfg.DisableSparseFiles == caps.SparseFiles {
+		t.Errorf("sparse files should be disabled only when unsupported, got %+v", caps)
+	}
+	if m.symlinksSupported("default") != caps.Symlinks {
+		t.Error("symlink support should follow the probed capabilities")
+	}
+
+	// Folders that can't be probed keep their configuration and get the
+	// benefit of the doubt.
+	missing := config.NewFolderConfiguration("missing", "testdata/missing")
+	if cfg := m.probeFolder(missing); !reflect.DeepEqual(cfg, missing) {
+		t.Error("configuration of unprobed folder should be unchanged")
+	}
+	if _, ok := m.FolderCapabilities()["missing"]; ok {
+		t.Error("unexpected capabilities for missing folder")
+	}
+	if !m.symlinksSupported("missing") {
+		t.Error("symlinks should be assumed supported when unknown")
+	}
+}
+
+func TestSnapshotFolder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfgDir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfgDir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{".stfolder": "", "a": "hello", "dir/b": "world"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fcfg := config.NewFolderConfiguration("src", dir)
+	fcfg.Devices = []config.FolderDeviceConfiguration{{DeviceID: protocol.LocalDeviceID}, {DeviceID: device1}}
+	wcfg := config.Wrap(filepath.Join(cfgDir, "config.xml"), config.Configuration{
+		Version: config.CurrentVersion,
+		Folders: []config.FolderConfiguration{fcfg},
+		Devices: []config.DeviceConfiguration{config.NewDeviceConfiguration(device1, "device1")},
+	})
+
+	m := NewModel(wcfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.StartFolder(fcfg.ID)
+	m.ServeBackground()
+	defer m.Stop()
+	if err := m.ScanFolder("src"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.SnapshotFolder("src", "a/b", nil); err != errSnapshotName {
+		t.Error("Expected errSnapshotName, got", err)
+	}
+
+	snapCfg, err := m.SnapshotFolder("src", "v1", []protocol.DeviceID{device1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapCfg.ID != "src@v1" || snapCfg.SnapshotOf != "src" || snapCfg.Type != config.FolderTypeSendOnly {
+		t.Fatalf("Unexpected snapshot config %+v", snapCfg)
+	}
+	if _, err := m.SnapshotFolder("src", "v1", nil); err != errSnapshotExists {
+		t.Error("Expected errSnapshotExists, got", err)
+	}
+
+	// The snapshot folder is started when the config is committed, which
+	// happens in a separate routine.
+	time.Sleep(100 * time.Millisecond)
+	m.fmut.RLock()
+	_, ok := m.folderRunners[snapCfg.ID].(*sendOnlyFolder)
+	m.fmut.RUnlock()
+	if !ok {
+		t.Fatal("Snapshot not started as a send only folder")
+	}
+	snapA, ok := m.CurrentFolderFile(snapCfg.ID, "a")
+	if !ok {
+		t.Fatal("File missing from snapshot index")
+	}
+	if _, ok := m.CurrentFolderFile(snapCfg.ID, filepath.FromSlash("dir/b")); !ok {
+		t.Fatal("File in subdirectory missing from snapshot index")
+	}
+
+	// Changes to the folder don't make it into the snapshot, nor is the
+	// snapshot itself part of the folder.
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "a"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(snapCfg.Path(), "a"), future, future)
+	if err := m.ScanFolder("src"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ScanFolder(snapCfg.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if bs, err := ioutil.ReadFile(filepath.Join(snapCfg.Path(), "a")); err != nil || string(bs) != "hello" {
+		t.Errorf("Snapshot file changed: %q, %v", bs, err)
+	}
+	if cur, _ := m.CurrentFolderFile(snapCfg.ID, "a"); !cur.IsEquivalent(snapA) || !cur.Version.Equal(snapA.Version) {
+		t.Error("Snapshot index changed by scan")
+	}
+	if _, ok := m.CurrentFolderFile("src", filepath.Join(snapshotDir, "v1", "a")); ok {
+		t.Error("Snapshot included in the folder it was taken of")
+	}
+}
+
+func TestFolderManifest(t *testing.T) {
+	db := db.OpenMemory()
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db, nil)
+	m.AddFolder(defaultFolderConfig)
+
+	v1 := protocol.Vector{}.Update(protocol.LocalDeviceID.Short())
+	m.updateLocalsFromScanning("default", []protocol.FileInfo{
+		{Name: "dir", Type: protocol.FileInfoTypeDirectory, Permissions: 0755, Version: v1},
+		{Name: filepath.Join("dir", "file"), Size: 10, ModifiedS: 1234, Permissions: 0644, Version: v1, Blocks: []protocol.BlockInfo{{Size: 10, Hash: []byte{0xde, 0xad, 0xbe, 0xef}}}},
+		{Name: "gone", Version: v1},
+	})
+	m.folderFiles["default"].Update(device1, []protocol.FileInfo{
+		{Name: "gone", Deleted: true, Version: v1.Update(device1.Short())},
+	})
+
+	manifest := func(local bool) []ManifestEntry {
+		var entries []ManifestEntry
+		if err := m.FolderManifest("default", local, func(e ManifestEntry) bool {
+			entries = append(entries, e)
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return entries
+	}
+
+	expected := []ManifestEntry{
+		{Name: "dir", Type: "directory", Permissions: 0755},
+		{Name: "dir/file", Type: "file", Size: 10, ModifiedS: 1234, Permissions: 0644, Blocks: []ManifestBlock{{Size: 10, Hash: "deadbeef"}}},
+	}
+	if global := manifest(false); !reflect.DeepEqual(global, expected) {
+		t.Errorf("Incorrect global manifest\n%+v !=\n%+v", global, expected)
+	}
+	expected = append(expected, ManifestEntry{Name: "gone", Type: "file", Blocks: []ManifestBlock{}})
+	if local := manifest(true); !reflect.DeepEqual(local, expected) {
+		t.Errorf("Incorrect local manifest\n%+v !=\n%+v", local, expected)
+	}
+
+	if err := m.FolderManifest("nonexistent", false, nil); err != errFolderMissing {
+		t.Error("Expected errFolderMissing, got", err)
+	}
+}
+
+func TestVerifyManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, data string) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".stfolder", "")
+	write(".stignore", "ignored\n")
+	write("same", "unchanged")
+	write("modified", "original")
+	write("missing", "gone soon")
+	write("dir/file", "in a dir")
+
+	fcfg := config.NewFolderConfiguration("default", dir)
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.StartFolder("default")
+	m.ServeBackground()
+	defer m.Stop()
+	if err := m.ScanFolder("default"); err != nil {
+		t.Fatal(err)
+	}
+
+	var manifest []ManifestEntry
+	m.FolderManifest("default", true, func(e ManifestEntry) bool {
+		manifest = append(manifest, e)
+		return true
+	})
+
+	report, err := m.VerifyManifest("default", manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Missing)+len(report.Modified)+len(report.Extra) != 0 {
+		t.Fatalf("Unexpected differences before changes: %+v", report)
+	}
+
+	write("modified", "changed!")
+	os.Remove(filepath.Join(dir, "missing"))
+	write("extra/deeper/file", "new")
+	write("ignored", "not reported")
+
+	report, err = m.VerifyManifest("default", manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ManifestReport{
+		Missing:  []string{"missing"},
+		Modified: []string{"modified"},
+		Extra:    []string{"extra"},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("Incorrect report\n%+v !=\n%+v", report, expected)
+	}
+
+	manifest = append(manifest, ManifestEntry{Name: "../escape", Type: "file"})
+	if _, err := m.VerifyManifest("default", manifest); err == nil {
+		t.Error("Expected an error for a path outside the folder")
+	}
+}
+
+func TestSetSelected(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{".stfolder", "sub/file", "other/file"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ldb := db.OpenMemory()
+	fcfg := config.NewFolderConfiguration("default", dir)
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", ldb, nil)
+	m.AddFolder(fcfg)
+	m.StartFolder("default")
+	m.ServeBackground()
+	defer m.Stop()
+	if err := m.ScanFolder("default"); err != nil {
+		t.Fatal(err)
+	}
+
+	subFile := filepath.Join("sub", "file")
+	valid := func(name string) bool {
+		f, ok := m.CurrentFolderFile("default", name)
+		return ok && !f.IsInvalid()
+	}
+
+	if err := m.SetSelected("default", "../escape", false); err == nil {
+		t.Error("Expected an error unselecting a path outside the folder")
+	}
+
+	if err := m.SetSelected("default", "sub", false); err != nil {
+		t.Fatal(err)
+	}
+	if unselected, _ := m.Unselected("default"); !reflect.DeepEqual(unselected, []string{"sub"}) {
+		t.Errorf("Unexpected unselected directories %v", unselected)
+	}
+	if valid(subFile) {
+		t.Error("File in unselected directory should be invalid")
+	}
+	if !valid(filepath.Join("other", "file")) {
+		t.Error("File in other directory should be valid")
+	}
+	if _, err := os.Stat(filepath.Join(dir, subFile)); err != nil {
+		t.Error("File in unselected directory should be left alone:", err)
+	}
+
+	if err := m.SetSelected("default", "sub/deeper", true); err != errParentUnselected {
+		t.Error("Expected errParentUnselected, got", err)
+	}
+
+	// The selection is persisted in the database.
+	m2 := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", ldb, nil)
+	m2.AddFolder(fcfg)
+	if unselected, _ := m2.Unselected("default"); !reflect.DeepEqual(unselected, []string{"sub"}) {
+		t.Errorf("Unselected directories not persisted, got %v", unselected)
+	}
+
+	if err := m.SetSelected("default", "sub", true); err != nil {
+		t.Fatal(err)
+	}
+	if unselected, _ := m.Unselected("default"); len(unselected) != 0 {
+		t.Errorf("Unexpected unselected directories %v", unselected)
+	}
+	if !valid(subFile) {
+		t.Error("File in selected directory should be valid again")
+	}
+}
+
+func TestFolderConflicts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{
+		".stfolder",
+		"a.txt",
+		"a.sync-conflict-20170102-150405.txt",
+		"a.sync-conflict-20170103-150405.txt",
+		"sub/b",
+		"sub/b.sync-conflict-20170102-150405",
+		"gone.sync-conflict-20170102-150405",
+		"not.sync-conflict-copy",
+		".stversions/a.sync-conflict-20170102-150405.txt",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fcfg := config.NewFolderConfiguration("default", dir)
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.StartFolder("default")
+	m.ServeBackground()
+	defer m.Stop()
+	if err := m.ScanFolder("default"); err != nil {
+		t.Fatal(err)
+	}
+
+	conflicts, err := m.FolderConflicts("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subB := filepath.Join("sub", "b")
+	if len(conflicts) != 3 || conflicts[0].File != "a.txt" || conflicts[1].File != "gone" || conflicts[2].File != subB {
+		t.Fatalf("Unexpected conflicts %+v", conflicts)
+	}
+	if len(conflicts[0].Copies) != 2 || conflicts[0].Copies[0].Name != "a.sync-conflict-20170102-150405.txt" || conflicts[0].Deleted {
+		t.Errorf("Unexpected conflict %+v", conflicts[0])
+	}
+	if !conflicts[1].Deleted {
+		t.Errorf("Conflict for missing file not marked deleted, %+v", conflicts[1])
+	}
+
+	if err := m.ResolveConflict("default", "not.sync-conflict-copy", true); err != errNotConflictCopy {
+		t.Error("Expected errNotConflictCopy, got", err)
+	}
+	if err := m.ResolveConflict("default", "../a.sync-conflict-20170102-150405.txt", true); err == nil {
+		t.Error("Expected an error resolving outside the folder")
+	}
+
+	// The copy wins and replaces the file
+
+	if err := m.ResolveConflict("default", "a.sync-conflict-20170103-150405.txt", true); err != nil {
+		t.Fatal(err)
+	}
+	if bs, _ := ioutil.ReadFile(filepath.Join(dir, "a.txt")); string(bs) != "a.sync-conflict-20170103-150405.txt" {
+		t.Errorf("File not replaced by the conflict copy, has %q", bs)
+	}
+	if f, ok := m.CurrentFolderFile("default", "a.sync-conflict-20170103-150405.txt"); !ok || !f.IsDeleted() {
+		t.Error("Conflict copy not rescanned as deleted")
+	}
+
+	// The file wins and the copy is removed
+
+	if err := m.ResolveConflict("default", filepath.Join("sub", "b.sync-conflict-20170102-150405"), false); err != nil {
+		t.Fatal(err)
+	}
+	if bs, _ := ioutil.ReadFile(filepath.Join(dir, subB)); string(bs) != "sub/b" {
+		t.Errorf("File changed, has %q", bs)
+	}
+
+	conflicts, _ = m.FolderConflicts("default")
+	if len(conflicts) != 2 || len(conflicts[0].Copies) != 1 || conflicts[1].File != "gone" {
+		t.Errorf("Unexpected conflicts after resolving %+v", conflicts)
+	}
+}
+
+func TestRepairCaseCollisions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name string, age time.Duration) {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, ".stfolder"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write("Notes.txt", time.Hour)
+	write("notes.txt", time.Minute)
+	write("unique", time.Hour)
+
+	fcfg := config.NewFolderConfiguration("default", dir)
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.StartFolder("default")
+	m.ServeBackground()
+	defer m.Stop()
+	if err := m.ScanFolder("default"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.RepairCaseCollisions("default", config.CaseCollisionReport); err != errNoRepairPolicy {
+		t.Error("Expected errNoRepairPolicy, got", err)
+	}
+
+	// The older file is renamed, and the rename is scanned.
+
+	repairs, err := m.RepairCaseCollisions("default", config.CaseCollisionRename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []CaseRepair{{File: "Notes.txt", NewName: "Notes.sync-case-1.txt"}}
+	if !reflect.DeepEqual(repairs, expected) {
+		t.Errorf("Unexpected repairs %+v", repairs)
+	}
+	if bs, _ := ioutil.ReadFile(filepath.Join(dir, "Notes.sync-case-1.txt")); string(bs) != "Notes.txt" {
+		t.Errorf("File not renamed, has %q", bs)
+	}
+	if f, ok := m.CurrentFolderFile("default", "Notes.txt"); !ok || !f.IsDeleted() {
+		t.Error("Renamed file not rescanned as deleted")
+	}
+	if collisions, _ := m.GlobalNameCollisions("default"); len(collisions) != 0 {
+		t.Errorf("Collisions remain after repair: %q", collisions)
+	}
+
+	// With the folder set to quarantine, a new collision is repaired after
+	// the scan that finds it.
+
+	fcfg.CaseCollisions = config.CaseCollisionQuarantine
+	m.RestartFolder(fcfg)
+	write("UNIQUE", time.Minute)
+	if err := m.ScanFolder("default"); err != nil {
+		t.Fatal(err)
+	}
+	if bs, _ := ioutil.ReadFile(filepath.Join(dir, quarantineDir, "unique")); string(bs) != "unique" {
+		t.Errorf("File not quarantined, has %q", bs)
+	}
+	if f, ok := m.CurrentFolderFile("default", "unique"); !ok || !f.IsDeleted() {
+		t.Error("Quarantined file not rescanned as deleted")
+	}
+	if _, ok := m.CurrentFolderFile("default", filepath.Join(quarantineDir, "unique")); ok {
+		t.Error("Quarantined file should not be in the index")
+	}
+}
+
+func TestRouteBlockRequests(t *testing.T) {
+	fcfg := config.NewFolderConfiguration("default", "testdata")
+	fcfg.Devices = []config.FolderDeviceConfiguration{{DeviceID: device1}, {DeviceID: device2}}
+	cfg := config.Wrap("/tmp/test", config.Configuration{
+		Folders: []config.FolderConfiguration{fcfg},
+		Devices: []config.DeviceConfiguration{
+			config.NewDeviceConfiguration(device1, "device1"),
+			config.NewDeviceConfiguration(device2, "device2"),
+		},
+		Options: config.OptionsConfiguration{
+			RouteBlockRequests: true,
+		},
+	})
+
+	m := NewModel(cfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.updateLocalsFromScanning("default", []protocol.FileInfo{testDataExpected["foo"]})
+
+	data := []byte("hello")
+	hash := sha256.Sum256(data)
+	block := protocol.BlockInfo{Offset: 0, Size: int32(len(data)), Hash: hash[:]}
+	file := protocol.FileInfo{
+		Name:    "routed",
+		Type:    protocol.FileInfoTypeFile,
+		Size:    int64(len(data)),
+		Version: protocol.Vector{}.Update(device2.Short()),
+		Blocks:  []protocol.BlockInfo{block},
+	}
+	m.Index(device2, "default", []protocol.FileInfo{file})
+
+	// Only device2 has the file, but we're only connected to device1,
+	// which may fetch it for us.
+
+	addFakeConn(m, device1)
+	av := m.Availability("default", file, block)
+	if len(av) != 1 || av[0].ID != device1 || !av[0].Routed {
+		t.Fatalf("Unexpected availability %+v", av)
+	}
+	if !m.canPullFrom("default", []protocol.DeviceID{device2}) {
+		t.Error("Should be able to pull through device1")
+	}
+
+	// Acting as device1 would, we fetch the block from device2 for the
+	// device asking for it, unless that's device2 itself.
+
+	fc := addFakeConn(m, device2)
+	fc.fileData = map[string][]byte{"routed": data}
+	buf := make([]byte, len(data))
+	if err := m.Request(device1, "default", "routed", 0, block.Hash, false, false, buf); err != nil || !bytes.Equal(buf, data) {
+		t.Errorf("Routed request returned %q, %v", buf, err)
+	}
+	if err := m.Request(device2, "default", "routed", 0, block.Hash, false, false, buf); err != protocol.ErrNoSuchFile {
+		t.Error("Expected ErrNoSuchFile routing back to the source, got", err)
+	}
+
+	// The request is marked as routed, so that device2 doesn't route it
+	// again, and what comes back must match the hash.
+
+	fc.requestFn = func(folder, name string, offset int64, size int, hash []byte, fromTemporary, routed bool) ([]byte, error) {
+		if !routed {
+			t.Error("Request not marked as routed")
+		}
+		return []byte("olleh"), nil
+	}
+	if err := m.Request(device1, "default", "routed", 0, block.Hash, false, false, buf); err != protocol.ErrNoSuchFile {
+		t.Error("Expected ErrNoSuchFile for routed data not matching the hash, got", err)
+	}
+	fc.requestFn = nil
+	if err := m.Request(device1, "default", "routed", 0, block.Hash, false, true, buf); err != protocol.ErrNoSuchFile {
+		t.Error("Expected ErrNoSuchFile routing a routed request, got", err)
+	}
+
+	// What we have is served from disk.
+
+	foo := testDataExpected["foo"]
+	buf = make([]byte, foo.Blocks[0].Size)
+	fc.fileData = nil
+	if err := m.Request(device1, "default", "foo", 0, foo.Blocks[0].Hash, false, false, buf); err != nil || string(buf) != "foobar\n" {
+		t.Errorf("Local request returned %q, %v", buf, err)
+	}
+
+	// Nothing is routed when disabled.
+
+	cfg.SetOptions(config.OptionsConfiguration{})
+	if err := m.Request(device1, "default", "routed", 0, block.Hash, false, false, buf); err != protocol.ErrNoSuchFile {
+		t.Error("Expected ErrNoSuchFile with routing disabled, got", err)
+	}
+	if m.canPullFrom("default", []protocol.DeviceID{protocol.DeviceID([32]byte{1, 2, 3})}) {
+		t.Error("Shouldn't be able to pull from an unconnected device with routing disabled")
+	}
+}
+
+func TestPlaceholdersInvalidToOldDevices(t *testing.T) {
+	fs := db.NewFileSet("default", db.OpenMemory())
+	fs.Update(protocol.LocalDeviceID, []protocol.FileInfo{
+		{Name: "placeholder", Version: protocol.Vector{}.Update(device1.Short()), Placeholder: true},
+		{Name: "file", Version: protocol.Vector{}.Update(device1.Short())},
+	})
+
+	for _, invalidPlaceholders := range []bool{false, true} {
+		var sent []protocol.FileInfo
+		fc := &fakeConnection{id: device2, indexFn: func(folder string, fs []protocol.FileInfo) {
+			sent = append(sent, fs...)
+		}}
+		if _, err := sendIndexTo(0, fc, "default", fs, nil, "", false, invalidPlaceholders); err != nil {
+			t.Fatal(err)
+		}
+		if len(sent) != 2 {
+			t.Fatalf("Sent %d files, expected 2", len(sent))
+		}
+		for _, f := range sent {
+			if invalid := f.Name == "placeholder" && invalidPlaceholders; f.IsInvalid() != invalid {
+				t.Errorf("%s sent with invalid %v, expected %v", f.Name, f.IsInvalid(), invalid)
+			}
+		}
+	}
+}
+
+func TestScanBlockSize(t *testing.T) {
+	fcfg := config.NewFolderConfiguration("default", "testdata")
+	fcfg.Devices = []config.FolderDeviceConfiguration{{DeviceID: device1}, {DeviceID: device2}}
+	fcfg.MinBlockSizeKiB = 16
+	cfg := config.Wrap("/tmp/test", config.Configuration{
+		Folders: []config.FolderConfiguration{fcfg},
+		Devices: []config.DeviceConfiguration{
+			config.NewDeviceConfiguration(device1, "device1"),
+			config.NewDeviceConfiguration(device2, "device2"),
+		},
+	})
+
+	m := NewModel(cfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+
+	// Small blocks are only used once every device sharing the folder
+	// understands them.
+
+	m.pmut.Lock()
+	m.helloMessages[device1] = protocol.HelloResult{SmallBlocks: true}
+	m.pmut.Unlock()
+	if size := m.scanBlockSize(fcfg); size != protocol.BlockSize {
+		t.Errorf("Block size %d with device2 unknown, expected %d", size, protocol.BlockSize)
+	}
+
+	m.pmut.Lock()
+	m.helloMessages[device2] = protocol.HelloResult{}
+	m.pmut.Unlock()
+	if size := m.scanBlockSize(fcfg); size != protocol.BlockSize {
+		t.Errorf("Block size %d with device2 not understanding small blocks, expected %d", size, protocol.BlockSize)
+	}
+
+	m.pmut.Lock()
+	m.helloMessages[device2] = protocol.HelloResult{SmallBlocks: true}
+	m.pmut.Unlock()
+	if size := m.scanBlockSize(fcfg); size != 16<<10 {
+		t.Errorf("Block size %d with all devices understanding small blocks, expected %d", size, 16<<10)
+	}
+}
+
+func TestApplyFolderSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "folder", ".stfolder"), 0755)
+
+	fcfg := config.NewFolderConfiguration("default", filepath.Join(dir, "folder"))
+	fcfg.Devices = []config.FolderDeviceConfiguration{{DeviceID: device1}, {DeviceID: device2}}
+	fcfg.SettingsFrom = device1
+	fcfg.LocalSettings = []string{config.FolderSettingMinBlockSize}
+	w := config.Wrap(filepath.Join(dir, "config.xml"), config.Configuration{
+		Folders: []config.FolderConfiguration{fcfg},
+		Devices: []config.DeviceConfiguration{{DeviceID: device1}, {DeviceID: device2}},
+		Options: config.OptionsConfiguration{KeepTemporariesH: 24},
+	})
+	m := NewModel(w, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.StartFolder("default")
+	m.ServeBackground()
+	defer m.Stop()
+
+	settings := &protocol.FolderSettings{
+		Ignores:          []string{"*.tmp", "/build"},
+		VersioningType:   "simple",
+		VersioningParams: []protocol.VersioningParam{{Key: "keep", Value: "5"}},
+		MinBlockSizeKiB:  16,
+	}
+
+	// Settings from other devices are ignored
+
+	m.applyFolderSettings(device2, "default", settings)
+	if lines, _, _ := m.GetIgnores("default"); len(lines) != 0 {
+		t.Errorf("Took ignores from the wrong device: %v", lines)
+	}
+
+	// Settings from the authority are taken, except those kept local
+
+	m.applyFolderSettings(device1, "default", settings)
+	if lines, _, _ := m.GetIgnores("default"); !reflect.DeepEqual(lines, settings.Ignores) {
+		t.Errorf("Unexpected ignores %v", lines)
+	}
+	cfg := w.Folders()["default"]
+	if cfg.Versioning.Type != "simple" || cfg.Versioning.Params["keep"] != "5" {
+		t.Errorf("Versioning not taken: %+v", cfg.Versioning)
+	}
+	if cfg.MinBlockSizeKiB != 0 {
+		t.Errorf("Minimum block size not kept local: %d", cfg.MinBlockSizeKiB)
+	}
+
+	// What a folder sharing its settings sends
+
+	if folderSettings(cfg) != nil {
+		t.Error("Sent settings without sharing them")
+	}
+	cfg.ShareSettings = true
+	sent := folderSettings(cfg)
+	if sent == nil || !reflect.DeepEqual(sent.Ignores, settings.Ignores) || sent.VersioningType != "simple" || !reflect.DeepEqual(sent.VersioningParams, settings.VersioningParams) {
+		t.Errorf("Unexpected settings sent: %+v", sent)
+	}
+}
+
+func TestSharedIgnores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "folder", ".stfolder"), 0755)
+
+	fcfg := config.NewFolderConfiguration("default", filepath.Join(dir, "folder"))
+	fcfg.Devices = []config.FolderDeviceConfiguration{{DeviceID: device1}, {DeviceID: device2}}
+	fcfg.SharedIgnores = true
+	w := config.Wrap(filepath.Join(dir, "config.xml"), config.Configuration{
+		Folders: []config.FolderConfiguration{fcfg},
+		Devices: []config.DeviceConfiguration{{DeviceID: device1}, {DeviceID: device2}},
+		Options: config.OptionsConfiguration{KeepTemporariesH: 24},
+	})
+	m := NewModel(w, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.StartFolder("default")
+	m.ServeBackground()
+	defer m.Stop()
+
+	ours := []string{"*.tmp"}
+	if err := m.SetIgnores("default", ours); err != nil {
+		t.Fatal(err)
+	}
+	changed := time.Now().Truncate(time.Second)
+	os.Chtimes(filepath.Join(fcfg.Path(), ".stignore"), changed, changed)
+
+	// Patterns changed before ours aren't taken
+
+	theirs := []string{"*.tmp", "/build"}
+	m.applySharedIgnores(device1, "default", &protocol.SharedIgnores{Lines: theirs, ModifiedNs: changed.Add(-time.Hour).UnixNano()})
+	if lines, _, _ := m.GetIgnores("default"); !reflect.DeepEqual(lines, ours) {
+		t.Errorf("Took older ignores: %v", lines)
+	}
+
+	// Patterns changed since are, along with when they changed
+
+	later := changed.Add(time.Hour)
+	m.applySharedIgnores(device1, "default", &protocol.SharedIgnores{Lines: theirs, ModifiedNs: later.UnixNano()})
+	if lines, _, _ := m.GetIgnores("default"); !reflect.DeepEqual(lines, theirs) {
+		t.Errorf("Didn't take newer ignores: %v", lines)
+	}
+	sent := sharedIgnores(w.Folders()["default"])
+	if sent == nil || !reflect.DeepEqual(sent.Lines, theirs) || sent.ModifiedNs != later.UnixNano() {
+		t.Errorf("Unexpected shared ignores sent: %+v", sent)
+	}
+
+	// Not when the folder doesn't share them
+
+	cfg := w.Folders()["default"]
+	cfg.SharedIgnores = false
+	if sharedIgnores(cfg) != nil {
+		t.Error("Sent ignores without sharing them")
+	}
+}
+
+func TestVirtualFolder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fcfg := config.NewFolderConfiguration("default", filepath.Join(dir, "folder"))
+	fcfg.Devices = []config.FolderDeviceConfiguration{{DeviceID: device1}}
+	fcfg.Virtual = true
+	w := config.Wrap(filepath.Join(dir, "config.xml"), config.Configuration{
+		Folders: []config.FolderConfiguration{fcfg},
+		Devices: []config.DeviceConfiguration{{DeviceID: device1}},
+		Options: config.OptionsConfiguration{KeepTemporariesH: 24, VirtualCacheMiB: 1},
+	})
+	m := NewModel(w, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.StartFolder("default")
+	m.ServeBackground()
+	defer m.Stop()
+
+	data := make([]byte, 3*protocol.BlockSize-10)
+	rand.Read(data)
+	fc := addFakeConn(m, device1)
+	fc.folder = "default"
+	fc.addFile("dir", 0755, protocol.FileInfoTypeDirectory, nil)
+	fc.addFile("dir/file", 0644, protocol.FileInfoTypeFile, data)
+	requests := 0
+	fc.requestFn = func(folder, name string, offset int64, size int, hash []byte, fromTemporary, routed bool) ([]byte, error) {
+		requests++
+		return data[offset : offset+int64(size)], nil
+	}
+	fc.sendIndexUpdate()
+
+	vfs := m.VirtualFilesystem("default")
+	if names, err := vfs.DirNames(""); err != nil || !reflect.DeepEqual(names, []string{"dir"}) {
+		t.Errorf("Unexpected root listing %v, %v", names, err)
+	}
+	if info, err := vfs.Lstat("dir"); err != nil || !info.IsDir() {
+		t.Errorf("Unexpected directory info %v, %v", info, err)
+	}
+	if _, err := vfs.Lstat("missing"); !os.IsNotExist(err) {
+		t.Errorf("Unexpected error for missing file: %v", err)
+	}
+
+	// File data is requested as it's read, and read again from the cache
+
+	for i := 0; i < 2; i++ {
+		fd, err := vfs.Open(filepath.Join("dir", "file"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, err := ioutil.ReadAll(fd)
+		fd.Close()
+		if err != nil || !bytes.Equal(bs, data) {
+			t.Fatalf("Read back %d bytes, %v", len(bs), err)
+		}
+	}
+	if requests != 3 {
+		t.Errorf("Made %d requests, expected one per block", requests)
+	}
+
+	if err := vfs.Remove(filepath.Join("dir", "file")); !os.IsPermission(err) {
+		t.Errorf("Unexpected error removing a file: %v", err)
+	}
+}
+
+func TestScanFolderChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, data string) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".stfolder", "")
+	write("unlisted", "original")
+	write("removed/file", "gone soon")
+
+	fcfg := config.NewFolderConfiguration("default", dir)
+	fcfg.RescanIntervalS = 86400
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.StartFolder("default")
+	m.ServeBackground()
+	defer m.Stop()
+
+	// Wait for the initial scan, so that it doesn't happen during our
+	// modifications.
+	m.fmut.RLock()
+	folder := m.folderRunners["default"].(*sendReceiveFolder)
+	m.fmut.RUnlock()
+	<-folder.initialScanCompleted
+
+	// Changes that aren't listed go unnoticed, and so do deletions when
+	// nothing is listed.
+
+	write("unlisted", "changed, but not listed")
+	os.RemoveAll(filepath.Join(dir, "removed"))
+	write("added/deeper/file", "new")
+	if err := m.internalScanFolderChanges("default", nil); err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := m.CurrentFolderFile("default", "removed"); !ok || f.IsDeleted() {
+		t.Error("Unlisted deletion should go unnoticed")
+	}
+
+	if err := m.internalScanFolderChanges("default", []string{"added", "removed"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"removed", filepath.Join("removed", "file")} {
+		if f, ok := m.CurrentFolderFile("default", name); !ok || !f.IsDeleted() {
+			t.Errorf("%s should be deleted", name)
+		}
+	}
+	if _, ok := m.CurrentFolderFile("default", filepath.Join("added", "deeper", "file")); !ok {
+		t.Error("The contents of the new directory should be scanned")
+	}
+	if f, _ := m.CurrentFolderFile("default", "unlisted"); f.Size != int64(len("original")) {
+		t.Error("Unlisted change should go unnoticed")
+	}
+}
+
+func TestRestoreFolderVersions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Versions of a and b from an hour and two hours ago, with b changed
+	// since and a deleted.
+
+	now := time.Now().Truncate(time.Second)
+	write := func(name, data string, mtime time.Time) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, mtime, mtime)
+	}
+	write(".stfolder", "", now)
+	write(".stversions/sub/a~"+now.Add(-2*time.Hour).Format(versioner.TimeFormat)+".txt", "a one\nsame\n", now.Add(-2*time.Hour))
+	write(".stversions/sub/b~"+now.Add(-2*time.Hour).Format(versioner.TimeFormat)+".txt", "b one\n", now.Add(-2*time.Hour))
+	write(".stversions/sub/b~"+now.Add(-time.Hour).Format(versioner.TimeFormat)+".txt", "b two\n", now.Add(-time.Hour))
+	write("sub/b.txt", "b three\n", now)
+
+	fcfg := config.NewFolderConfiguration("default", dir)
+	fcfg.RescanIntervalS = 86400
+	fcfg.Versioning = config.VersioningConfiguration{Type: "simple", Params: map[string]string{"keep": "5"}}
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.StartFolder("default")
+	m.ServeBackground()
+	defer m.Stop()
+
+	a, b := filepath.Join("sub", "a.txt"), filepath.Join("sub", "b.txt")
+	versions, err := m.FolderVersions("default", "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions[a]) != 1 || len(versions[b]) != 2 {
+		t.Fatalf("Unexpected versions %v", versions)
+	}
+
+	diff, err := m.FolderVersionDiff("default", b, versions[b][1].VersionTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Binary || diff.Similarity != 0 || !strings.Contains(diff.Diff, "-b one\n+b three\n") {
+		t.Errorf("Unexpected diff %+v", diff)
+	}
+
+	// Ninety minutes ago, both were the first version.
+
+	at, err := m.FolderVersionsAt("default", "", now.Add(-90*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(at) != 2 || !at[a].Equal(versions[a][0].VersionTime) || !at[b].Equal(versions[b][1].VersionTime) {
+		t.Fatalf("Unexpected versions to restore %v", at)
+	}
+
+	errs, err := m.RestoreFolderVersions("default", at)
+	if err != nil || len(errs) != 0 {
+		t.Fatal(err, errs)
+	}
+	for name, data := range map[string]string{a: "a one\nsame\n", b: "b one\n"} {
+		if bs, _ := ioutil.ReadFile(filepath.Join(dir, name)); string(bs) != data {
+			t.Errorf("Restored %q as %q, expected %q", name, bs, data)
+		}
+		if f, ok := m.CurrentFolderFile("default", name); !ok || f.Size != int64(len(data)) {
+			t.Errorf("Restored %q not scanned", name)
+		}
+	}
+
+	if errs, _ := m.RestoreFolderVersions("default", map[string]time.Time{"../x": now}); errs["../x"] == "" {
+		t.Error("Restoring outside the folder should fail")
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := splitLines([]byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14"))
+	b := splitLines([]byte("1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"))
+
+	expected := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -11,4 +11,5 @@
+ 11
+ 12
+ 13
+-14
+\ No newline at end of file
++14
++15
+`
+	if diff := unifiedDiff(a, b, "a", "b"); diff != expected {
+		t.Errorf("Unexpected diff\n%s", diff)
+	}
+	if diff := unifiedDiff(a, a, "a", "a"); diff != "" {
+		t.Errorf("Unexpected diff of the same lines\n%s", diff)
+	}
+}
+
+func TestScanCheckpointResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{".stfolder", "a", "b", "c"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// As left by a scan that got as far as b, with no ignore patterns.
+	noIgnores := ignore.New(false)
+	noIgnores.Parse(bytes.NewBuffer(nil), ".stignore")
+	ldb := db.OpenMemory()
+	db.NewFileSet("default", ldb).SetScanCheckpoint("b", noIgnores.Hash())
+
+	fcfg := config.NewFolderConfiguration("default", dir)
+	fcfg.ScanCheckpointS = 60
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", ldb, nil)
+	m.AddFolder(fcfg)
+	m.StartFolder("default")
+
+	scanned := func(name string) bool {
+		_, ok := m.CurrentFolderFile("default", name)
+		return ok
+	}
+
+	// The scan resumes after b, and clears the checkpoint as it completes.
+	// The model isn't started, so that the folder doesn't scan by itself.
+	if err := m.internalScanFolderSubdirs("default", nil); err != nil {
+		t.Fatal(err)
+	}
+	if scanned("a") || scanned("b") || !scanned("c") {
+		t.Errorf("Expected just c scanned, got a %v, b %v, c %v", scanned("a"), scanned("b"), scanned("c"))
+	}
+	if _, _, ok := db.NewFileSet("default", ldb).ScanCheckpoint(); ok {
+		t.Error("Checkpoint not cleared after the scan")
+	}
+
+	// So the next scan covers everything.
+	if err := m.internalScanFolderSubdirs("default", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !scanned("a") || !scanned("b") {
+		t.Error("Expected a and b scanned by the next scan")
+	}
+}
+
+func TestPreviewIgnores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, ".stfolder"), 0755)
+	os.MkdirAll(filepath.Join(dir, "dir"), 0755)
+	for _, name := range []string{"a.txt", "b.log", filepath.Join("dir", "c.log")} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fcfg := config.NewFolderConfiguration("default", dir)
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(fcfg)
+	m.StartFolder("default")
+
+	// The log files are scanned, then ignored.
+	if err := m.internalScanFolderSubdirs("default", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ".stignore"), []byte("*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.internalScanFolderSubdirs("default", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	preview, err := m.PreviewIgnores("default", []string{"*.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := IgnoresPreview{
+		Ignored:       []string{"a.txt"},
+		IgnoredCount:  1,
+		Included:      []string{"b.log", filepath.Join("dir", "c.log")},
+		IncludedCount: 2,
+	}
+	if !reflect.DeepEqual(preview, expected) {
+		t.Errorf("Preview %+v != expected %+v", preview, expected)
+	}
+
+	// Nothing is saved.
+	if lines, _, _ := m.GetIgnores("default"); !reflect.DeepEqual(lines, []string{"*.log"}) {
+		t.Errorf("Ignores changed by the preview: %v", lines)
+	}
+
+	if _, err := m.PreviewIgnores("default", []string{"#include nonexistent"}); err == nil {
+		t.Error("Expected an error for invalid patterns")
+	}
+	if _, err := m.PreviewIgnores("nonexistent", nil); err != errFolderMissing {
+		t.Error("Expected errFolderMissing, got", err)
+	}
+}
+
+func TestQueueChanges(t *testing.T) {
+	m := NewModel(defaultConfig, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+	m.AddFolder(defaultFolderConfig)
+	m.StartFolder("default")
+
+	scanned := make(chan []string, 1)
+	m.externalChanges = newChangeQueue(time.Millisecond, func(folder string, changes []string) {
+		if folder != "default" {
+			t.Errorf("changes scanned for %q", folder)
+		}
+		scanned <- changes
+	})
+
+	if err := m.QueueChanges("default", []string{"../outside"}); err != errInvalidChange {
+		t.Errorf("expected errInvalidChange, got %v", err)
+	}
+	if err := m.QueueChanges("nonexistent", []string{"foo"}); err != errFolderMissing {
+		t.Errorf("expected errFolderMissing, got %v", err)
+	}
+
+	// The changes queued before the delay is up are scanned together.
+	if err := m.QueueChanges("default", []string{"foo", "dir/bar/"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.QueueChanges("default", []string{"foo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case changes := <-scanned:
+		sort.Strings(changes)
+		expected := []string{filepath.Join("dir", "bar"), "foo"}
+		if !reflect.DeepEqual(changes, expected) {
+			t.Errorf("scanned %v, expected %v", changes, expected)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("changes weren't scanned")
+	}
+}
+
+func TestChangesNeedWalk(t *testing.T) {
+	cases := []struct {
+		changes []string
+		walk    bool
+	}{
+		{[]string{"foo", filepath.Join("dir", "bar")}, false},
+		{[]string{"foo", ""}, true},
+		{[]string{"foo", filepath.Join("dir", ".stignore")}, true},
+	}
+	for _, tc := range cases {
+		if walk := changesNeedWalk("default", tc.changes); walk != tc.walk {
+			t.Errorf("changesNeedWalk(%v) = %v, expected %v", tc.changes, walk, tc.walk)
+		}
+	}
+}
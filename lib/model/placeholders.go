@@ -0,0 +1,105 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// Placeholder files
+//
+// A folder with placeholders syncs only the metadata of the files it
+// doesn't have yet. Each is kept on disk as an empty file with the right
+// permissions and modification time, marked with an extended attribute
+// where the filesystem supports them, and announced as a placeholder so
+// that other devices don't request its blocks from us. Devices that don't
+// know about placeholders, as told by their hello, get it announced as
+// invalid instead, which they skip just the same. The scanner leaves
+// a placeholder alone for as long as it's empty and untouched.
+//
+// The data of a placeholder is fetched on demand by invalidating it, which
+// makes the file needed again; the puller then pulls its data instead of
+// putting another placeholder in its place. Files we have the data of are
+// kept up to date as usual.
+
+// FetchPlaceholders asks for the data of the placeholders at the given
+// paths, relative to the folder root, or anywhere below them if they are
+// directories. A blank path means the whole folder. It returns the number
+// of files to be fetched.
+func (m *Model) FetchPlaceholders(folder string, paths []string) (int, error) {
+	m.fmut.RLock()
+	files, ok := m.folderFiles[folder]
+	runner, okRunner := m.folderRunners[folder]
+	cfg := m.folderCfgs[folder]
+	m.fmut.RUnlock()
+
+	if !ok {
+		return 0, errFolderMissing
+	}
+	if !okRunner && cfg.Paused {
+		return 0, errFolderPaused
+	}
+
+	for i, path := range paths {
+		path = strings.TrimRight(filepath.Clean(filepath.FromSlash(path)), string(filepath.Separator))
+		if path == "." || path == "" {
+			paths = nil
+			break
+		}
+		if _, err := rootedJoinedPath("root", path); err != nil {
+			return 0, errInvalidFilename
+		}
+		paths[i] = path
+	}
+
+	var fetch []protocol.FileInfo
+	files.WithHaveTruncated(protocol.LocalDeviceID, func(fi db.FileIntf) bool {
+		f := fi.(db.FileInfoTruncated)
+		if !f.IsPlaceholder() || f.IsInvalid() || f.IsDeleted() || !placeholderWanted(f.Name, paths) {
+			return true
+		}
+		fetch = append(fetch, m.fetchedPlaceholderInfo(f))
+		return true
+	})
+	if len(fetch) == 0 {
+		return 0, nil
+	}
+
+	l.Debugln("fetching", len(fetch), "placeholders in", folder)
+	m.updateLocals(folder, fetch)
+	if okRunner {
+		runner.IndexUpdated()
+	}
+	return len(fetch), nil
+}
+
+// placeholderWanted returns true if the file is one of the paths or in a
+// directory among them, or if there are no paths.
+func placeholderWanted(name string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, path := range paths {
+		if name == path || strings.HasPrefix(name, path+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchedPlaceholderInfo returns the file as a placeholder whose data we
+// want. Being invalid, it is needed again; the version stays the same, as
+// the file hasn't changed.
+func (m *Model) fetchedPlaceholderInfo(f db.FileInfoTruncated) protocol.FileInfo {
+	info := m.ignoredFileInfo(f)
+	info.Placeholder = true
+	return info
+}
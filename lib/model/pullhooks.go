@@ -0,0 +1,134 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// Pull hooks
+//
+// A folder can have commands run before and after each file, directory or
+// symlink is synced, to validate or process things as they arrive. They
+// are run in the folder, with the item described by environment variables:
+//
+//     STFOLDER      the folder ID
+//     STFOLDERPATH  the path of the folder
+//     STPATH        the path of the item, relative to the folder
+//     STTYPE        file, dir or symlink
+//     STACTION      update, metadata (for changes to just that) or delete
+//     STRESULT      for the post pull hook, success or failure
+//     STERROR       for the post pull hook, why syncing the item failed
+//
+// A hook that exits with an error, or doesn't exit in time and is killed,
+// has failed. The folder's PullHookFailure decides whether that just gets
+// logged or counts as the item failing to sync, in which case a failing
+// pre pull hook keeps the item from being synced until the next pull.
+
+const defaultPullHookTimeout = time.Minute
+
+var errCommandTimeout = errors.New("timed out")
+
+// prePullHook runs the pre pull hook for the item, if there is one. It
+// returns an error if the hook failed and the item shouldn't be synced.
+func (f *sendReceiveFolder) prePullHook(name, typ, action string) error {
+	if f.PrePullCommand == "" {
+		return nil
+	}
+	err := f.runPullHook(f.PrePullCommand, name, typ, action, nil)
+	return f.pullHookFailed("pre pull", name, err)
+}
+
+// postPullHook runs the post pull hook for the item, if there is one,
+// given the result of syncing it. The hook failing is recorded as an error
+// for the item, if it counts as one and the item didn't fail already.
+func (f *sendReceiveFolder) postPullHook(name, typ, action string, result error) {
+	if f.PostPullCommand == "" {
+		return
+	}
+	env := []string{"STRESULT=success"}
+	if result != nil {
+		env = []string{"STRESULT=failure", "STERROR=" + result.Error()}
+	}
+	err := f.runPullHook(f.PostPullCommand, name, typ, action, env)
+	if err = f.pullHookFailed("post pull", name, err); err != nil && result == nil {
+		f.newError(name, err)
+	}
+}
+
+// pullHookFailed returns the hook's error, if any, if it counts as the item
+// failing to sync. Otherwise it's only logged.
+func (f *sendReceiveFolder) pullHookFailed(hook, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("%s hook: %v", hook, err)
+	if f.PullHookFailure == config.PullHookFailureError {
+		return err
+	}
+	l.Infof("Folder %s, item %q: %v", f.Description(), name, err)
+	return nil
+}
+
+// runPullHook runs the command for the item.
+func (f *sendReceiveFolder) runPullHook(command, name, typ, action string, env []string) error {
+	timeout := time.Duration(f.PullHookTimeoutS) * time.Second
+	if timeout <= 0 {
+		timeout = defaultPullHookTimeout
+	}
+	env = append([]string{"STFOLDER=" + f.folderID, "STFOLDERPATH=" + f.dir, "STPATH=" + name, "STTYPE=" + typ, "STACTION=" + action}, env...)
+	return f.runCommand(command, env, timeout)
+}
+
+// runCommand runs the command in the folder, with the variables added to
+// our environment, and waits for it to exit, killing it if it takes longer
+// than the timeout.
+func (f *sendReceiveFolder) runCommand(command string, env []string, timeout time.Duration) error {
+	cmd := exec.Command(command)
+	cmd.Dir = f.dir
+	// Don't hand our own credentials to the command
+	for _, v := range os.Environ() {
+		if !strings.HasPrefix(v, "STGUIAUTH=") && !strings.HasPrefix(v, "STGUIAPIKEY=") {
+			cmd.Env = append(cmd.Env, v)
+		}
+	}
+	cmd.Env = append(cmd.Env, env...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	timedOut := false
+	timedOutMut := sync.NewMutex()
+	timer := time.AfterFunc(timeout, func() {
+		timedOutMut.Lock()
+		timedOut = true
+		timedOutMut.Unlock()
+		cmd.Process.Kill()
+	})
+	err := cmd.Wait()
+	timer.Stop()
+
+	l.Debugf("%v ran %s %v: %v: %s", f, command, env, err, output.Bytes())
+	timedOutMut.Lock()
+	defer timedOutMut.Unlock()
+	if timedOut {
+		return errCommandTimeout
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	stdsync "sync"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// While a folder of a higher priority is pulling, folders of lower priority
+// get this many block requests in flight, together. They're slowed down
+// rather than stopped, so that they still get somewhere eventually.
+const lowPriorityRequests = 1
+
+// The pullScheduler shares the block requests between the folders pulling
+// at the same time, according to their priority classes. As block data is
+// what uses the bandwidth, this also shares the bandwidth.
+type pullScheduler struct {
+	pulling  map[config.FolderPriority]int // folders pulling, per priority
+	inFlight map[config.FolderPriority]int // block requests in flight, per priority
+	mut      sync.Mutex
+	cond     *stdsync.Cond
+}
+
+func newPullScheduler() *pullScheduler {
+	s := &pullScheduler{
+		pulling:  make(map[config.FolderPriority]int),
+		inFlight: make(map[config.FolderPriority]int),
+		mut:      sync.NewMutex(),
+	}
+	s.cond = stdsync.NewCond(s.mut)
+	return s
+}
+
+// startPulling notes that a folder of the given priority is pulling, until
+// the matching call to donePulling.
+func (s *pullScheduler) startPulling(prio config.FolderPriority) {
+	s.mut.Lock()
+	s.pulling[prio]++
+	s.mut.Unlock()
+}
+
+func (s *pullScheduler) donePulling(prio config.FolderPriority) {
+	s.mut.Lock()
+	s.pulling[prio]--
+	s.cond.Broadcast()
+	s.mut.Unlock()
+}
+
+// startRequest blocks until a block request may be made for a folder of
+// the given priority, until the matching call to doneRequest.
+func (s *pullScheduler) startRequest(prio config.FolderPriority) {
+	s.mut.Lock()
+	for s.inFlight[prio] >= lowPriorityRequests && s.higherPulling(prio) {
+		s.cond.Wait()
+	}
+	s.inFlight[prio]++
+	s.mut.Unlock()
+}
+
+func (s *pullScheduler) doneRequest(prio config.FolderPriority) {
+	s.mut.Lock()
+	s.inFlight[prio]--
+	s.cond.Broadcast()
+	s.mut.Unlock()
+}
+
+func (s *pullScheduler) higherPulling(prio config.FolderPriority) bool {
+	for p, n := range s.pulling {
+		if p > prio && n > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+func TestPullScheduler(t *testing.T) {
+	s := newPullScheduler()
+
+	// With nothing else pulling, there is no limit.
+	for i := 0; i < 10; i++ {
+		s.startRequest(config.FolderPriorityLow)
+	}
+	for i := 0; i < 10; i++ {
+		s.doneRequest(config.FolderPriorityLow)
+	}
+
+	// While a high priority folder is pulling, lower priority folders get
+	// one request at a time, and high priority ones aren't held up.
+	s.startPulling(config.FolderPriorityHigh)
+	s.startRequest(config.FolderPriorityNormal)
+	for i := 0; i < 10; i++ {
+		s.startRequest(config.FolderPriorityHigh)
+	}
+
+	started := make(chan struct{})
+	go func() {
+		s.startRequest(config.FolderPriorityNormal)
+		close(started)
+	}()
+	select {
+	case <-started:
+		t.Fatal("Second normal priority request wasn't held up")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The held up request goes ahead once the first is done.
+	s.doneRequest(config.FolderPriorityNormal)
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("Normal priority request still held up")
+	}
+
+	// Or once the high priority folder is done pulling.
+	started = make(chan struct{})
+	go func() {
+		s.startRequest(config.FolderPriorityNormal)
+		close(started)
+	}()
+	s.donePulling(config.FolderPriorityHigh)
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("Normal priority request still held up")
+	}
+}
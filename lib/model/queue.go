@@ -71,6 +71,24 @@ func (q *jobQueue) BringToFront(filename string) {
 	}
 }
 
+// BringMatchingToFront moves the queued files for which match returns true
+// to the front of the queue, keeping their order.
+func (q *jobQueue) BringMatchingToFront(match func(name string) bool) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	front := make([]jobQueueEntry, 0, len(q.queued))
+	var back []jobQueueEntry
+	for _, cur := range q.queued {
+		if match(cur.name) {
+			front = append(front, cur)
+		} else {
+			back = append(back, cur)
+		}
+	}
+	q.queued = append(front, back...)
+}
+
 func (q *jobQueue) Done(file string) {
 	q.mut.Lock()
 	defer q.mut.Unlock()
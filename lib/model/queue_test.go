@@ -8,6 +8,7 @@ package model
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -161,6 +162,23 @@ func TestBringToFront(t *testing.T) {
 	}
 }
 
+func TestBringMatchingToFront(t *testing.T) {
+	q := newJobQueue()
+	q.Push("a.txt", 0, time.Time{})
+	q.Push("b.pdf", 0, time.Time{})
+	q.Push("c.txt", 0, time.Time{})
+	q.Push("d.pdf", 0, time.Time{})
+
+	q.BringMatchingToFront(func(name string) bool {
+		return strings.HasSuffix(name, ".pdf")
+	})
+
+	_, queued := q.Jobs()
+	if diff, equal := messagediff.PrettyDiff([]string{"b.pdf", "d.pdf", "a.txt", "c.txt"}, queued); !equal {
+		t.Errorf("Order does not match. Diff:\n%s", diff)
+	}
+}
+
 func TestShuffle(t *testing.T) {
 	q := newJobQueue()
 	q.Push("f1", 0, time.Time{})
@@ -8,6 +8,7 @@ package model
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/fs"
@@ -27,7 +28,9 @@ func newSendOnlyFolder(model *Model, cfg config.FolderConfiguration, _ versioner
 	return &sendOnlyFolder{
 		folder: folder{
 			stateTracker: newStateTracker(cfg.ID),
-			scan:         newFolderScanner(cfg),
+			scan:         newFolderScanner(cfg, model.rescanIntervalFactor),
+			schedule:     newFolderSchedule(cfg),
+			journal:      newFolderJournal(cfg),
 			stop:         make(chan struct{}),
 			model:        model,
 		},
@@ -41,8 +44,12 @@ func (f *sendOnlyFolder) Serve() {
 
 	defer func() {
 		f.scan.timer.Stop()
+		f.schedule.timer.Stop()
+		f.journal.Close()
 	}()
 
+	f.schedule.Update(time.Now())
+
 	initialScanCompleted := false
 	for {
 		select {
@@ -50,6 +57,12 @@ func (f *sendOnlyFolder) Serve() {
 			return
 
 		case <-f.scan.timer.C:
+			if f.schedule.Paused() {
+				// We scan again once resumed.
+				l.Debugln(f, "skipping rescan, paused by schedule")
+				continue
+			}
+
 			if err := f.model.CheckFolderHealth(f.folderID); err != nil {
 				l.Infoln("Skipping scan of", f.Description(), "due to folder error:", err)
 				f.scan.Reschedule()
@@ -58,7 +71,7 @@ func (f *sendOnlyFolder) Serve() {
 
 			l.Debugln(f, "rescan")
 
-			if err := f.model.internalScanFolderSubdirs(f.folderID, nil); err != nil {
+			if err := f.scanSubdirs(nil); err != nil {
 				// Potentially sets the error twice, once in the scanner just
 				// by doing a check, and once here, if the error returned is
 				// the same one as returned by CheckFolderHealth, though
@@ -80,10 +93,15 @@ func (f *sendOnlyFolder) Serve() {
 			f.scan.Reschedule()
 
 		case req := <-f.scan.now:
-			req.err <- f.scanSubdirsIfHealthy(req.subdirs)
+			req.err <- f.scanSubdirsIfHealthy(req.subdirs, req.changes)
 
 		case next := <-f.scan.delay:
 			f.scan.timer.Reset(next)
+
+		case <-f.schedule.timer.C:
+			if f.schedule.Update(time.Now()) {
+				f.scan.timer.Reset(0)
+			}
 		}
 	}
 }
@@ -0,0 +1,116 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+)
+
+// Block request routing lets a device fetch blocks of a file that only
+// devices it's not connected to have, by requesting them from a device it
+// is connected to that shares the folder. That device, when it doesn't
+// have the block itself, requests it in turn from a device that does and
+// passes the data on. Both devices must have routing enabled.
+//
+// A request is only routed onwards when our index doesn't have the block,
+// and only to devices whose index says they have the file. Routed requests
+// are marked as such and never routed again, so that they can't go round
+// in circles when indexes are out of date. The data passed on is checked
+// against the block hash, as the router can't trust it any more than the
+// requester would.
+
+// routersPLocked returns the connected devices sharing the folder that we
+// may route requests for the file through, given that none of them has it
+// themselves. The caller must hold pmut.
+func (m *Model) routersPLocked(folder string, devices map[protocol.DeviceID]struct{}) []Availability {
+	var routers []Availability
+next:
+	for device := range devices {
+		if _, ok := m.conn[device]; !ok {
+			continue
+		}
+		for _, pausedFolder := range m.remotePausedFolders[device] {
+			if pausedFolder == folder {
+				continue next
+			}
+		}
+		routers = append(routers, Availability{ID: device, Routed: true, ColdStorage: m.helloMessages[device].ColdStorage})
+	}
+	return routers
+}
+
+// canPullFrom returns whether the file, which the given devices have, can
+// be pulled: directly when we're connected to any of them, or otherwise
+// through another device when routing is enabled.
+func (m *Model) canPullFrom(folder string, devices []protocol.DeviceID) bool {
+	for _, dev := range devices {
+		if m.ConnectedTo(dev) {
+			return true
+		}
+	}
+	if len(devices) == 0 || !m.cfg.Options().RouteBlockRequests {
+		return false
+	}
+
+	m.fmut.RLock()
+	m.pmut.RLock()
+	defer m.pmut.RUnlock()
+	folderDevices := m.folderDevices[folder]
+	m.fmut.RUnlock()
+
+	return len(m.routersPLocked(folder, folderDevices)) > 0
+}
+
+// shouldRoute returns whether a request from another device for the given
+// block should be routed onwards rather than served from disk.
+func (m *Model) shouldRoute(folder, name string, offset int64, hash []byte) bool {
+	if len(hash) == 0 || !m.cfg.Options().RouteBlockRequests {
+		return false
+	}
+
+	cur, ok := m.CurrentFolderFile(folder, name)
+	if !ok || cur.IsDeleted() || cur.IsInvalid() {
+		return true
+	}
+	for _, block := range cur.Blocks {
+		if block.Offset == offset && bytes.Equal(block.Hash, hash) {
+			return false
+		}
+	}
+	return true
+}
+
+// routeRequest requests the block from the devices that have the file,
+// other than the one asking for it, and puts the data in buf.
+func (m *Model) routeRequest(requester protocol.DeviceID, folder, name string, offset int64, hash []byte, buf []byte) error {
+	m.fmut.RLock()
+	files, ok := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return protocol.ErrNoSuchFile
+	}
+
+	for _, device := range files.Availability(name) {
+		if device == requester || !m.ConnectedTo(device) {
+			continue
+		}
+		data, err := m.requestGlobal(device, folder, name, offset, len(buf), hash, false, true)
+		if err == nil {
+			_, err = scanner.VerifyBuffer(data, protocol.BlockInfo{Offset: offset, Size: int32(len(buf)), Hash: hash})
+		}
+		if err != nil {
+			l.Debugf("%v routed REQ for %s: %q / %q o=%d via %s: %v", m, requester, folder, name, offset, device, err)
+			continue
+		}
+		copy(buf, data)
+		return nil
+	}
+	return protocol.ErrNoSuchFile
+}
@@ -45,8 +45,9 @@ type pullBlockState struct {
 // copied.
 type copyBlocksState struct {
 	*sharedPullerState
-	blocks []protocol.BlockInfo
-	have   int
+	blocks       []protocol.BlockInfo
+	have         int
+	appendPrefix int // number of leading blocks unchanged since the current file, if appended to
 }
 
 // Which filemode bits to preserve
@@ -55,6 +56,11 @@ const retainBits = os.ModeSetgid | os.ModeSetuid | os.ModeSticky
 var (
 	activity    = newDeviceActivity()
 	errNoDevice = errors.New("peers who had this file went away, or the file has changed while syncing. will retry later")
+
+	errSymlinksUnsupported = errors.New("symlinks not supported by the filesystem")
+	errInsufficientSpace   = errors.New("insufficient space")
+	errQuotaExceeded       = errors.New("folder would exceed its maximum size")
+	errWriteOnce           = errors.New("write once folder: not modifying or deleting an existing file")
 )
 
 const (
@@ -71,6 +77,7 @@ const (
 	defaultPullers     = 64
 	defaultPullerSleep = 10 * time.Second
 	defaultPullerPause = 60 * time.Second
+	flashWriteBuffer   = 4 << 20 // bytes of temp file writes to coalesce with the flash storage profile
 )
 
 type dbUpdateJob struct {
@@ -93,8 +100,16 @@ type sendReceiveFolder struct {
 	pullTimer   *time.Timer
 	remoteIndex chan struct{} // An index update was received, we should re-evaluate needs
 
-	errors    map[string]string // path -> error string
-	errorsMut sync.Mutex
+	flashStorage bool           // batch writes harder, per the flash storage profile
+	limiter      *folderLimiter // bandwidth limits for the folder
+	quota        *folderQuota   // size limit for the folder
+	writeOnce    *folderWriteOnce
+
+	errors        map[string]FileError        // path -> error
+	errorCount    int                         // number of errors reported, including repeats
+	ignoredErrors map[string]ignoredFileError // path -> error ignored until the file changes
+	retryFirst    map[string]bool             // files to pull first in the next iteration, as retried
+	errorsMut     sync.Mutex
 
 	initialScanCompleted chan (struct{}) // exposed for testing
 }
@@ -103,7 +118,9 @@ func newSendReceiveFolder(model *Model, cfg config.FolderConfiguration, ver vers
 	f := &sendReceiveFolder{
 		folder: folder{
 			stateTracker: newStateTracker(cfg.ID),
-			scan:         newFolderScanner(cfg),
+			scan:         newFolderScanner(cfg, model.rescanIntervalFactor),
+			schedule:     newFolderSchedule(cfg),
+			journal:      newFolderJournal(cfg),
 			stop:         make(chan struct{}),
 			model:        model,
 		},
@@ -117,12 +134,18 @@ func newSendReceiveFolder(model *Model, cfg config.FolderConfiguration, ver vers
 		pullTimer:   time.NewTimer(time.Second),
 		remoteIndex: make(chan struct{}, 1), // This needs to be 1-buffered so that we queue a notification if we're busy doing a pull when it comes.
 
-		errorsMut: sync.NewMutex(),
+		ignoredErrors: make(map[string]ignoredFileError),
+		retryFirst:    make(map[string]bool),
+		errorsMut:     sync.NewMutex(),
 
 		initialScanCompleted: make(chan struct{}),
 	}
 
 	f.configureCopiersAndPullers()
+	f.flashStorage = model.cfg.Options().StorageProfile == config.StorageProfileFlash
+	f.limiter = model.folderLimiters[cfg.ID] // we're started with fmut held
+	f.quota = model.folderQuotas[cfg.ID]
+	f.writeOnce = model.folderWriteOnces[cfg.ID]
 
 	return f
 }
@@ -164,10 +187,17 @@ func (f *sendReceiveFolder) Serve() {
 	defer func() {
 		f.pullTimer.Stop()
 		f.scan.timer.Stop()
+		f.schedule.timer.Stop()
+		f.journal.Close()
 		// TODO: Should there be an actual FolderStopped state?
 		f.setState(FolderIdle)
 	}()
 
+	f.schedule.Update(time.Now())
+
+	// Only folders that write to theirs probe it, with no locks held.
+	f.DisableSparseFiles = f.model.probeFolder(f.FolderConfiguration).DisableSparseFiles
+
 	var prevSec int64
 	var prevIgnoreHash string
 
@@ -182,6 +212,12 @@ func (f *sendReceiveFolder) Serve() {
 			l.Debugln(f, "remote index updated, rescheduling pull")
 
 		case <-f.pullTimer.C:
+			if f.schedule.Paused() {
+				// We pull again once resumed.
+				l.Debugln(f, "skip (paused by schedule)")
+				continue
+			}
+
 			select {
 			case <-f.initialScanCompleted:
 			default:
@@ -217,6 +253,13 @@ func (f *sendReceiveFolder) Serve() {
 				continue
 			}
 
+			if deps := f.model.PendingDependencies(f.folderID); len(deps) > 0 {
+				l.Debugln(f, "waiting for", deps)
+				f.setState(FolderSyncWaiting)
+				f.pullTimer.Reset(f.sleep)
+				continue
+			}
+
 			l.Debugln(f, "pulling", prevSec, curSeq)
 
 			f.setState(FolderSyncing)
@@ -277,7 +320,12 @@ func (f *sendReceiveFolder) Serve() {
 		// this is the easiest way to make sure we are not doing both at the
 		// same time.
 		case <-f.scan.timer.C:
-			err := f.scanSubdirsIfHealthy(nil)
+			if f.schedule.Paused() {
+				// We scan again once resumed.
+				l.Debugln(f, "skipping rescan, paused by schedule")
+				continue
+			}
+			err := f.scanSubdirsIfHealthy(nil, false)
 			f.scan.Reschedule()
 			if err != nil {
 				continue
@@ -290,10 +338,16 @@ func (f *sendReceiveFolder) Serve() {
 			}
 
 		case req := <-f.scan.now:
-			req.err <- f.scanSubdirsIfHealthy(req.subdirs)
+			req.err <- f.scanSubdirsIfHealthy(req.subdirs, req.changes)
 
 		case next := <-f.scan.delay:
 			f.scan.timer.Reset(next)
+
+		case <-f.schedule.timer.C:
+			if f.schedule.Update(time.Now()) {
+				f.scan.timer.Reset(0)
+				f.pullTimer.Reset(0)
+			}
 		}
 	}
 }
@@ -329,6 +383,9 @@ func (f *sendReceiveFolder) pullerIteration(ignores *ignore.Matcher) int {
 
 	l.Debugln(f, "c", f.Copiers, "p", f.Pullers)
 
+	f.model.pullScheduler.startPulling(f.Priority)
+	defer f.model.pullScheduler.donePulling(f.Priority)
+
 	f.dbUpdates = make(chan dbUpdateJob)
 	updateWg.Add(1)
 	go func() {
@@ -366,6 +423,9 @@ func (f *sendReceiveFolder) pullerIteration(ignores *ignore.Matcher) int {
 	folderFiles := f.model.folderFiles[f.folderID]
 	f.model.fmut.RUnlock()
 
+	f.quota.start(folderFiles.LocalSize().Bytes)
+	f.writeOnce.start()
+	errorsBefore := f.errorsReported()
 	changed := 0
 	var processDirectly []protocol.FileInfo
 
@@ -379,6 +439,12 @@ func (f *sendReceiveFolder) pullerIteration(ignores *ignore.Matcher) int {
 			return true
 		}
 
+		if file, ok := intf.(protocol.FileInfo); ok && f.errorIgnored(file) {
+			// The error was ignored until the file changes, which it
+			// hasn't.
+			return true
+		}
+
 		if err := fileValid(intf); err != nil {
 			// The file isn't valid so we can't process it. Pretend that we
 			// tried and set the error for the file.
@@ -389,6 +455,19 @@ func (f *sendReceiveFolder) pullerIteration(ignores *ignore.Matcher) int {
 
 		file := intf.(protocol.FileInfo)
 
+		if f.writeOnce.active() {
+			cur, ok := folderFiles.Get(protocol.LocalDeviceID, file.Name)
+			if !ok || cur.IsDeleted() {
+				cur, ok = f.unscannedFile(file.Name)
+			}
+			if !f.writeOnce.allow(cur, ok, file) {
+				l.Debugf("%v not pulling %s: would modify or delete an existing file", f, file.Name)
+				f.newError(file.Name, errWriteOnce)
+				changed++
+				return true
+			}
+		}
+
 		switch {
 		case file.IsDeleted():
 			processDirectly = append(processDirectly, file)
@@ -398,13 +477,9 @@ func (f *sendReceiveFolder) pullerIteration(ignores *ignore.Matcher) int {
 			// Queue files for processing after directories and symlinks, if
 			// it has availability.
 
-			devices := folderFiles.Availability(file.Name)
-			for _, dev := range devices {
-				if f.model.ConnectedTo(dev) {
-					f.queue.Push(file.Name, file.Size, file.ModTime())
-					changed++
-					break
-				}
+			if f.model.canPullFrom(f.folderID, folderFiles.Availability(file.Name)) {
+				f.queue.Push(file.Name, file.Size, file.ModTime())
+				changed++
 			}
 
 		default:
@@ -448,8 +523,9 @@ func (f *sendReceiveFolder) pullerIteration(ignores *ignore.Matcher) int {
 				// Local file can be already deleted, but with a lower version
 				// number, hence the deletion coming in again as part of
 				// WithNeed, furthermore, the file can simply be of the wrong
-				// type if we haven't yet managed to pull it.
-				if ok && !df.IsDeleted() && !df.IsSymlink() && !df.IsDirectory() {
+				// type if we haven't yet managed to pull it. A placeholder
+				// has no data to be renamed.
+				if ok && !df.IsDeleted() && !df.IsSymlink() && !df.IsDirectory() && !df.IsPlaceholder() {
 					// Put files into buckets per first hash
 					key := string(df.Blocks[0].Hash)
 					buckets[key] = append(buckets[key], df)
@@ -487,6 +563,22 @@ func (f *sendReceiveFolder) pullerIteration(ignores *ignore.Matcher) int {
 		f.queue.SortNewestFirst()
 	}
 
+	// Files matching the priority patterns go first, those matching the
+	// first pattern before those matching the second, and so on.
+	for i := len(f.PriorityPatterns) - 1; i >= 0; i-- {
+		pattern := f.PriorityPatterns[i]
+		f.queue.BringMatchingToFront(func(name string) bool {
+			return matchPriorityPattern(pattern, name)
+		})
+	}
+
+	// Files whose errors were retried go before all else.
+	if retry := f.takeRetries(); len(retry) > 0 {
+		f.queue.BringMatchingToFront(func(name string) bool {
+			return retry[name]
+		})
+	}
+
 	// Process the file queue.
 
 nextFile:
@@ -565,6 +657,18 @@ nextFile:
 	// Wait for the finisherChan to finish.
 	doneWg.Wait()
 
+	// Deletes are always applied last, once all additions and updates in
+	// this iteration are on disk. This keeps things like hard links being
+	// replaced and files moved between directories (maildir, build trees)
+	// from ever leaving the data missing. In strict mode, we don't delete
+	// anything unless all of those succeeded; the deletes stay needed and
+	// are retried with the failed items on the next iteration.
+	if f.StrictDeleteOrdering && f.errorsReported() > errorsBefore && len(fileDeletions)+len(dirDeletions) > 0 {
+		l.Infof("Folder %s: postponing %d deletes as not all other changes could be applied", f.Description(), len(fileDeletions)+len(dirDeletions))
+		fileDeletions = nil
+		dirDeletions = nil
+	}
+
 	for _, file := range fileDeletions {
 		l.Debugln("Deleting file", file.Name)
 		f.deleteFile(file)
@@ -580,11 +684,34 @@ nextFile:
 	close(f.dbUpdates)
 	updateWg.Wait()
 
+	if denied := f.quota.finish(); denied > 0 {
+		l.Infof("Folder %s is at its maximum size of %d bytes; not pulling %d files", f.Description(), f.MaxFolderSize, denied)
+		events.Default.Log(events.QuotaExceeded, map[string]interface{}{
+			"folder":        f.folderID,
+			"maxFolderSize": f.MaxFolderSize,
+			"localBytes":    folderFiles.LocalSize().Bytes,
+			"denied":        denied,
+		})
+	}
+
+	if refused := f.writeOnce.finish(); len(refused) > 0 {
+		l.Warnf("Folder %s is write once; refused changes from other devices to %d existing files", f.Description(), len(refused))
+		events.Default.Log(events.WriteOnceViolation, map[string]interface{}{
+			"folder": f.folderID,
+			"files":  refused,
+		})
+	}
+
 	return changed
 }
 
 // handleDir creates or updates the given directory
 func (f *sendReceiveFolder) handleDir(file protocol.FileInfo) {
+	if err := f.prePullHook(file.Name, "dir", "update"); err != nil {
+		f.newError(file.Name, err)
+		return
+	}
+
 	// Used in the defer closure below, updated by the function body. Take
 	// care not declare another err.
 	var err error
@@ -604,6 +731,7 @@ func (f *sendReceiveFolder) handleDir(file protocol.FileInfo) {
 			"type":   "dir",
 			"action": "update",
 		})
+		f.postPullHook(file.Name, "dir", "update", err)
 	}()
 
 	realName, err := rootedJoinedPath(f.dir, file.Name)
@@ -687,6 +815,11 @@ func (f *sendReceiveFolder) handleDir(file protocol.FileInfo) {
 
 // handleSymlink creates or updates the given symlink
 func (f *sendReceiveFolder) handleSymlink(file protocol.FileInfo) {
+	if err := f.prePullHook(file.Name, "symlink", "update"); err != nil {
+		f.newError(file.Name, err)
+		return
+	}
+
 	// Used in the defer closure below, updated by the function body. Take
 	// care not declare another err.
 	var err error
@@ -706,6 +839,7 @@ func (f *sendReceiveFolder) handleSymlink(file protocol.FileInfo) {
 			"type":   "symlink",
 			"action": "update",
 		})
+		f.postPullHook(file.Name, "symlink", "update", err)
 	}()
 
 	realName, err := rootedJoinedPath(f.dir, file.Name)
@@ -728,6 +862,15 @@ func (f *sendReceiveFolder) handleSymlink(file protocol.FileInfo) {
 		return
 	}
 
+	if !f.model.symlinksSupported(f.folderID) {
+		// Don't remove whatever is there now, as we can't put the symlink
+		// in its place anyway.
+		err = errSymlinksUnsupported
+		l.Debugf("Puller (folder %q, dir %q): %v", f.folderID, file.Name, err)
+		f.newError(file.Name, err)
+		return
+	}
+
 	if _, err = f.mtimeFS.Lstat(realName); err == nil {
 		// There is already something under that name. Remove it to replace
 		// with the symlink. This also handles the "change symlink type"
@@ -756,6 +899,11 @@ func (f *sendReceiveFolder) handleSymlink(file protocol.FileInfo) {
 
 // deleteDir attempts to delete the given directory
 func (f *sendReceiveFolder) deleteDir(file protocol.FileInfo, matcher *ignore.Matcher) {
+	if err := f.prePullHook(file.Name, "dir", "delete"); err != nil {
+		f.newError(file.Name, err)
+		return
+	}
+
 	// Used in the defer closure below, updated by the function body. Take
 	// care not declare another err.
 	var err error
@@ -775,6 +923,7 @@ func (f *sendReceiveFolder) deleteDir(file protocol.FileInfo, matcher *ignore.Ma
 			"type":   "dir",
 			"action": "delete",
 		})
+		f.postPullHook(file.Name, "dir", "delete", err)
 	}()
 
 	realName, err := rootedJoinedPath(f.dir, file.Name)
@@ -815,6 +964,11 @@ func (f *sendReceiveFolder) deleteDir(file protocol.FileInfo, matcher *ignore.Ma
 
 // deleteFile attempts to delete the given file
 func (f *sendReceiveFolder) deleteFile(file protocol.FileInfo) {
+	if err := f.prePullHook(file.Name, "file", "delete"); err != nil {
+		f.newError(file.Name, err)
+		return
+	}
+
 	// Used in the defer closure below, updated by the function body. Take
 	// care not declare another err.
 	var err error
@@ -834,6 +988,7 @@ func (f *sendReceiveFolder) deleteFile(file protocol.FileInfo) {
 			"type":   "file",
 			"action": "delete",
 		})
+		f.postPullHook(file.Name, "file", "delete", err)
 	}()
 
 	realName, err := rootedJoinedPath(f.dir, file.Name)
@@ -843,12 +998,24 @@ func (f *sendReceiveFolder) deleteFile(file protocol.FileInfo) {
 	}
 
 	cur, ok := f.model.CurrentFolderFile(f.folderID, file.Name)
-	if ok && f.inConflict(cur.Version, file.Version) {
-		// There is a conflict here. Move the file to a conflict copy instead
-		// of deleting. Also merge with the version vector we had, to indicate
-		// we have resolved the conflict.
+	conflict := ok && f.inConflict(cur.Version, file.Version)
+	if conflict && f.keepLocalInConflict(cur, file) {
+		f.keepLocal(cur, file)
+		return
+	}
+
+	var copied, winner ConflictVersion
+	if conflict {
+		// There is a conflict here. Merge with the version vector we had, to
+		// indicate we have resolved the conflict.
+		copied, winner = conflictVersionOf(cur), conflictVersionOf(file)
 		file.Version = file.Version.Merge(cur.Version)
-		err = osutil.InWritableDir(f.moveForConflict, realName)
+	}
+	if conflict && f.ConflictPolicy == config.ConflictPolicyCopy {
+		// Move the file to a conflict copy instead of deleting.
+		err = osutil.InWritableDir(func(name string) error {
+			return f.moveForConflict(name, copied, winner)
+		}, realName)
 	} else if f.versioner != nil {
 		err = osutil.InWritableDir(f.versioner.Archive, realName)
 	} else {
@@ -873,6 +1040,15 @@ func (f *sendReceiveFolder) deleteFile(file protocol.FileInfo) {
 // renameFile attempts to rename an existing file to a destination
 // and set the right attributes on it.
 func (f *sendReceiveFolder) renameFile(source, target protocol.FileInfo) {
+	if err := f.prePullHook(source.Name, "file", "delete"); err != nil {
+		f.newError(source.Name, err)
+		return
+	}
+	if err := f.prePullHook(target.Name, "file", "update"); err != nil {
+		f.newError(target.Name, err)
+		return
+	}
+
 	// Used in the defer closure below, updated by the function body. Take
 	// care not declare another err.
 	var err error
@@ -905,6 +1081,8 @@ func (f *sendReceiveFolder) renameFile(source, target protocol.FileInfo) {
 			"type":   "file",
 			"action": "update",
 		})
+		f.postPullHook(source.Name, "file", "delete", err)
+		f.postPullHook(target.Name, "file", "update", err)
 	}()
 
 	l.Debugln(f, "taking rename shortcut", source.Name, "->", target.Name)
@@ -999,14 +1177,42 @@ func (f *sendReceiveFolder) renameFile(source, target protocol.FileInfo) {
 func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- copyBlocksState, finisherChan chan<- *sharedPullerState) {
 	curFile, hasCurFile := f.model.CurrentFolderFile(f.folderID, file.Name)
 
+	// Whether the device we take the file from has its data or not, we will
+	// once it's pulled.
+	file.Placeholder = false
+
+	if f.Placeholders && f.wantsPlaceholder(file, curFile, hasCurFile) {
+		f.queue.Done(file.Name)
+		f.placeholderFile(file)
+		return
+	}
+	if hasCurFile && curFile.IsPlaceholder() {
+		// There's no data on disk to keep or reuse, and no local changes
+		// that could be in conflict.
+		curFile.Blocks = nil
+		hasCurFile = false
+	}
+
+	if hasCurFile && f.inConflict(curFile.Version, file.Version) && f.keepLocalInConflict(curFile, file) {
+		f.queue.Done(file.Name)
+		f.keepLocal(curFile, file)
+		return
+	}
+
 	have, need := scanner.BlockDiff(curFile.Blocks, file.Blocks)
 
-	if hasCurFile && len(need) == 0 {
+	if hasCurFile && len(need) == 0 && f.canShortcut(curFile, file) {
 		// We are supposed to copy the entire file, and then fetch nothing. We
 		// are only updating metadata, so we don't actually *need* to make the
 		// copy.
 		l.Debugln(f, "taking shortcut on", file.Name)
 
+		if err := f.prePullHook(file.Name, "file", "metadata"); err != nil {
+			f.queue.Done(file.Name)
+			f.newError(file.Name, err)
+			return
+		}
+
 		events.Default.Log(events.ItemStarted, map[string]string{
 			"folder": f.folderID,
 			"item":   file.Name,
@@ -1031,6 +1237,7 @@ func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- c
 		} else {
 			f.dbUpdates <- dbUpdateJob{file, dbUpdateShortcutFile}
 		}
+		f.postPullHook(file.Name, "file", "metadata", err)
 
 		return
 	}
@@ -1076,7 +1283,7 @@ func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- c
 
 	// Check for an old temporary file which might have some blocks we could
 	// reuse.
-	tempBlocks, err := scanner.HashFile(tempName, protocol.BlockSize, nil, false)
+	tempBlocks, err := scanner.HashFile(tempName, file.BlockSize(), nil, false)
 	if err == nil {
 		// Check for any reusable blocks in the temp file
 		tempCopyBlocks, _ := scanner.BlockDiff(tempBlocks, file.Blocks)
@@ -1112,10 +1319,20 @@ func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- c
 		blocksSize = file.Size
 	}
 
+	growth := file.Size
+	if hasCurFile && !curFile.IsDirectory() && !curFile.IsSymlink() && !curFile.IsDeleted() {
+		growth -= curFile.Size
+	}
+	if !f.quota.allow(growth) {
+		l.Debugf("%v not pulling %s: would exceed the maximum folder size", f, file.Name)
+		f.newError(file.Name, errQuotaExceeded)
+		return
+	}
+
 	if f.MinDiskFreePct > 0 {
 		if free, err := osutil.DiskFreeBytes(f.dir); err == nil && free < blocksSize {
 			l.Warnf(`Folder "%s": insufficient disk space in %s for %s: have %.2f MiB, need %.2f MiB`, f.folderID, f.dir, file.Name, float64(free)/1024/1024, float64(blocksSize)/1024/1024)
-			f.newError(file.Name, errors.New("insufficient space"))
+			f.newError(file.Name, errInsufficientSpace)
 			return
 		}
 	}
@@ -1126,6 +1343,12 @@ func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- c
 		blocks[i], blocks[j] = blocks[j], blocks[i]
 	}
 
+	if err := f.prePullHook(file.Name, "file", "update"); err != nil {
+		f.queue.Done(file.Name)
+		f.newError(file.Name, err)
+		return
+	}
+
 	events.Default.Log(events.ItemStarted, map[string]string{
 		"folder": f.folderID,
 		"item":   file.Name,
@@ -1146,21 +1369,144 @@ func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- c
 		availableUpdated: time.Now(),
 		ignorePerms:      f.ignorePermissions(file),
 		version:          curFile.Version,
+		modifiedBy:       curFile.ModifiedBy,
 		mut:              sync.NewRWMutex(),
 		sparse:           !f.DisableSparseFiles,
 		created:          time.Now(),
+		throttle:         f.limiter.diskThrottle(),
+	}
+	if f.flashStorage {
+		s.writeBuffer = flashWriteBuffer
 	}
 
-	l.Debugf("%v need file %s; copy %d, reused %v", f, file.Name, len(blocks), len(reused))
+	// If the file was appended to, the blocks it starts with can be
+	// copied straight from the current file.
+	var appendPrefix int
+	if hasCurFile && !curFile.IsDirectory() && !curFile.IsSymlink() && !curFile.IsDeleted() && file.Size > curFile.Size {
+		appendPrefix = scanner.AppendedPrefix(curFile.Blocks, file.Blocks, file.BlockSize())
+	}
+
+	l.Debugf("%v need file %s; copy %d, reused %v, appended to %d blocks", f, file.Name, len(blocks), len(reused), appendPrefix)
 
 	cs := copyBlocksState{
 		sharedPullerState: &s,
 		blocks:            blocks,
 		have:              len(have),
+		appendPrefix:      appendPrefix,
 	}
 	copyChan <- cs
 }
 
+// wantsPlaceholder returns true if the file should get a placeholder rather
+// than its data: when it has just a placeholder already, or nothing at all
+// on disk, and its data hasn't been asked for. Fetching the data is asked
+// for by invalidating the placeholder.
+func (f *sendReceiveFolder) wantsPlaceholder(file, curFile protocol.FileInfo, hasCurFile bool) bool {
+	if hasCurFile && curFile.IsPlaceholder() {
+		return !curFile.IsInvalid()
+	}
+	if hasCurFile && !curFile.IsDeleted() && !curFile.IsInvalid() {
+		// We have the data of the previous version, so keep it up to date.
+		return false
+	}
+	realName, err := rootedJoinedPath(f.dir, file.Name)
+	if err != nil {
+		return false
+	}
+	_, err = f.mtimeFS.Lstat(realName)
+	return os.IsNotExist(err)
+}
+
+// placeholderFile puts an empty placeholder in place of the file, with its
+// permissions and modification time, and records that we have just its
+// metadata.
+func (f *sendReceiveFolder) placeholderFile(file protocol.FileInfo) {
+	l.Debugln(f, "placeholder for", file.Name)
+
+	events.Default.Log(events.ItemStarted, map[string]string{
+		"folder": f.folderID,
+		"item":   file.Name,
+		"type":   "file",
+		"action": "metadata",
+	})
+
+	err := f.writePlaceholder(file)
+	events.Default.Log(events.ItemFinished, map[string]interface{}{
+		"folder": f.folderID,
+		"item":   file.Name,
+		"error":  events.Error(err),
+		"type":   "file",
+		"action": "metadata",
+	})
+
+	if err != nil {
+		l.Infof("Puller (folder %q, file %q): placeholder: %v", f.folderID, file.Name, err)
+		f.newError(file.Name, err)
+		return
+	}
+
+	file.Placeholder = true
+	f.dbUpdates <- dbUpdateJob{file, dbUpdateHandleFile}
+}
+
+func (f *sendReceiveFolder) writePlaceholder(file protocol.FileInfo) error {
+	tempName, err := rootedJoinedPath(f.dir, ignore.TempName(file.Name))
+	if err != nil {
+		return err
+	}
+	realName, err := rootedJoinedPath(f.dir, file.Name)
+	if err != nil {
+		return err
+	}
+
+	fd, err := os.Create(tempName)
+	if err != nil {
+		return err
+	}
+	fd.Close()
+
+	if err := fs.MarkPlaceholder(tempName); err != nil {
+		l.Debugln(f, "marking placeholder:", err)
+	}
+	if !f.ignorePermissions(file) {
+		if err := os.Chmod(tempName, os.FileMode(file.Permissions&0777)); err != nil {
+			return err
+		}
+	}
+	if err := osutil.TryRename(tempName, realName); err != nil {
+		return err
+	}
+
+	f.mtimeFS.Chtimes(realName, file.ModTime(), file.ModTime()) // never fails
+	return nil
+}
+
+// canShortcut returns true if the existing file can be kept as is, with
+// only the metadata updated. When the folder accepts mtime only changes, we
+// verify that the data we have on disk really is what the new file should
+// contain, rather than trusting that it still matches our index.
+func (f *sendReceiveFolder) canShortcut(curFile, file protocol.FileInfo) bool {
+	if !f.MtimeOnlyChanges || curFile.ModTime().Equal(file.ModTime()) {
+		return true
+	}
+
+	realName, err := rootedJoinedPath(f.dir, file.Name)
+	if err != nil {
+		return false
+	}
+	fd, err := os.Open(realName)
+	if err != nil {
+		return false
+	}
+	defer fd.Close()
+
+	if err := scanner.Verify(fd, file.BlockSize(), file.Blocks); err != nil {
+		l.Debugln(f, "not taking shortcut on", file.Name, "as existing data differs:", err)
+		return false
+	}
+	return true
+}
+
 // shortcutFile sets file mode and modification time, when that's the only
 // thing that has changed.
 func (f *sendReceiveFolder) shortcutFile(file protocol.FileInfo) error {
@@ -1215,8 +1561,17 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 		f.model.fmut.RUnlock()
 
 		var weakHashFinder *weakhash.Finder
+		var origFd *os.File
 
-		if weakhash.Enabled {
+		if state.appendPrefix > 0 {
+			// The new blocks were appended; there's no point in looking
+			// for them in the current file.
+			l.Debugf("not weak hashing %s. file was appended to", state.file.Name)
+			origFd, err = os.Open(state.realName)
+			if err != nil {
+				l.Debugln("open for appending", err)
+			}
+		} else if weakhash.Enabled {
 			blocksPercentChanged := 0
 			if tot := len(state.file.Blocks); tot > 0 {
 				blocksPercentChanged = (tot - state.have) * 100 / tot
@@ -1231,7 +1586,7 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 				}
 
 				if len(hashesToFind) > 0 {
-					weakHashFinder, err = weakhash.NewFinder(state.realName, protocol.BlockSize, hashesToFind)
+					weakHashFinder, err = weakhash.NewFinder(state.realName, state.file.BlockSize(), hashesToFind)
 					if err != nil {
 						l.Debugln("weak hasher", err)
 					}
@@ -1260,6 +1615,22 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 
 			buf = buf[:int(block.Size)]
 
+			if origFd != nil && block.Offset < int64(state.appendPrefix)*int64(state.file.BlockSize()) {
+				f.limiter.diskThrottle().WaitRead(len(buf))
+				if _, err := origFd.ReadAt(buf, block.Offset); err == nil {
+					if _, err := scanner.VerifyBuffer(buf, block); err == nil {
+						if _, err := dstFd.WriteAt(buf, block.Offset); err != nil {
+							state.fail("dst write", err)
+							break
+						}
+						state.copiedFromOrigin()
+						state.copyDone(block)
+						continue
+					}
+				}
+				// Not what we expected after all; find it the usual way.
+			}
+
 			found, err := weakHashFinder.Iterate(block.WeakHash, buf, func(offset int64) bool {
 				if _, err := scanner.VerifyBuffer(buf, block); err != nil {
 					return true
@@ -1293,7 +1664,15 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 						return false
 					}
 
-					_, err = fd.ReadAt(buf, protocol.BlockSize*int64(index))
+					// The file the block is in may be hashed in blocks of
+					// another size than the file we're pulling.
+					blockSize := int64(protocol.BlockSize)
+					if cf, ok := f.model.CurrentFolderFile(folder, file); ok {
+						blockSize = int64(cf.BlockSize())
+					}
+
+					f.limiter.diskThrottle().WaitRead(len(buf))
+					_, err = fd.ReadAt(buf, blockSize*int64(index))
 					fd.Close()
 					if err != nil {
 						return false
@@ -1340,6 +1719,9 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 			}
 		}
 		weakHashFinder.Close()
+		if origFd != nil {
+			origFd.Close()
+		}
 		out <- state.sharedPullerState
 	}
 }
@@ -1368,8 +1750,11 @@ func (f *sendReceiveFolder) pullerRoutine(in <-chan pullBlockState, out chan<- *
 			continue
 		}
 
+		f.model.pullScheduler.startRequest(f.Priority)
+		f.limiter.waitRecv(int(state.block.Size))
+
 		var lastError error
-		candidates := f.model.Availability(f.folderID, state.file.Name, state.file.Version, state.block)
+		candidates := f.model.Availability(f.folderID, state.file, state.block)
 		for {
 			// Select the least busy device to pull the block from. If we found no
 			// feasible device at all, fail the block (and in the long run, the
@@ -1389,7 +1774,7 @@ func (f *sendReceiveFolder) pullerRoutine(in <-chan pullBlockState, out chan<- *
 			// Fetch the block, while marking the selected device as in use so that
 			// leastBusy can select another device when someone else asks.
 			activity.using(selected)
-			buf, lastError := f.model.requestGlobal(selected.ID, f.folderID, state.file.Name, state.block.Offset, int(state.block.Size), state.block.Hash, selected.FromTemporary)
+			buf, lastError := f.model.requestGlobal(selected.ID, f.folderID, state.file.Name, state.block.Offset, int(state.block.Size), state.block.Hash, selected.FromTemporary, false)
 			activity.done(selected)
 			if lastError != nil {
 				l.Debugln("request:", f.folderID, state.file.Name, state.block.Offset, state.block.Size, "returned error:", lastError)
@@ -1413,11 +1798,15 @@ func (f *sendReceiveFolder) pullerRoutine(in <-chan pullBlockState, out chan<- *
 			}
 			break
 		}
+		f.model.pullScheduler.doneRequest(f.Priority)
 		out <- state.sharedPullerState
 	}
 }
 
 func (f *sendReceiveFolder) performFinish(state *sharedPullerState) error {
+	// Whether the file is in conflict with ours and the two were merged
+	var merged bool
+
 	// Set the correct permission bits on the new file
 	if !f.ignorePermissions(state.file) {
 		if err := os.Chmod(state.tempName, os.FileMode(state.file.Permissions&0777)); err != nil {
@@ -1443,17 +1832,41 @@ func (f *sendReceiveFolder) performFinish(state *sharedPullerState) error {
 				return err
 			}
 
-		case f.inConflict(state.version, state.file.Version):
+		case f.inConflict(state.version, state.file.Version) && f.ConflictPolicy == config.ConflictPolicyCopy:
 			// The new file has been changed in conflict with the existing one. We
 			// should file it away as a conflict instead of just removing or
 			// archiving. Also merge with the version vector we had, to indicate
 			// we have resolved the conflict.
 
+			copied := ConflictVersion{ModifiedBy: state.modifiedBy, Version: state.version}
+			winner := conflictVersionOf(state.file)
 			state.file.Version = state.file.Version.Merge(state.version)
-			if err = osutil.InWritableDir(f.moveForConflict, state.realName); err != nil {
+			if merged = f.mergeConflict(state); merged {
+				// The merged file replaces ours like any other change
+				// would, and is a change of our own once scanned.
+				if f.versioner != nil {
+					if err = f.versioner.Archive(state.realName); err != nil {
+						return err
+					}
+				}
+			} else if err = osutil.InWritableDir(func(name string) error {
+				return f.moveForConflict(name, copied, winner)
+			}, state.realName); err != nil {
 				return err
 			}
 
+		case f.inConflict(state.version, state.file.Version):
+			// The conflict policy has decided for the new file, which
+			// replaces the existing one like any other change would. The
+			// versions are merged as above.
+
+			state.file.Version = state.file.Version.Merge(state.version)
+			if f.versioner != nil {
+				if err = f.versioner.Archive(state.realName); err != nil {
+					return err
+				}
+			}
+
 		case f.versioner != nil:
 			// If we should use versioning, let the versioner archive the old
 			// file before we replace it. Archiving a non-existent file is not
@@ -1471,6 +1884,15 @@ func (f *sendReceiveFolder) performFinish(state *sharedPullerState) error {
 		return err
 	}
 
+	if merged {
+		// Record the other device's version, which the merge replaces,
+		// and scan the merged file once we're done pulling. Keeping the
+		// time of the merge makes sure the scan sees the change.
+		f.dbUpdates <- dbUpdateJob{state.file, dbUpdateHandleFile}
+		go f.scan.Scan([]string{state.file.Name})
+		return nil
+	}
+
 	// Set the correct timestamp on the new file
 	f.mtimeFS.Chtimes(state.realName, state.file.ModTime(), state.file.ModTime()) // never fails
 
@@ -1501,6 +1923,7 @@ func (f *sendReceiveFolder) finisherRoutine(in <-chan *sharedPullerState) {
 				"type":   "file",
 				"action": "update",
 			})
+			f.postPullHook(state.file.Name, "file", "update", err)
 
 			if f.model.progressEmitter != nil {
 				f.model.progressEmitter.Deregister(state)
@@ -1514,17 +1937,38 @@ func (f *sendReceiveFolder) BringToFront(filename string) {
 	f.queue.BringToFront(filename)
 }
 
+// Moves the queued files for which match returns true to the front of the
+// job queue
+func (f *sendReceiveFolder) BringMatchingToFront(match func(name string) bool) {
+	f.queue.BringMatchingToFront(match)
+}
+
+// matchPriorityPattern returns whether the file name matches the glob
+// pattern. Patterns without a slash match the last element of the name, so
+// that "*.pdf" matches in any directory.
+func matchPriorityPattern(pattern, name string) bool {
+	pattern = filepath.FromSlash(pattern)
+	if !strings.ContainsRune(pattern, filepath.Separator) {
+		name = filepath.Base(name)
+	}
+	ok, _ := filepath.Match(pattern, name)
+	return ok
+}
+
 func (f *sendReceiveFolder) Jobs() ([]string, []string) {
 	return f.queue.Jobs()
 }
 
 // dbUpdaterRoutine aggregates db updates and commits them in batches no
-// larger than 1000 items, and no more delayed than 2 seconds.
+// larger than 1000 items, and no more delayed than 2 seconds. With the flash
+// storage profile the batches are allowed to grow larger and older.
 func (f *sendReceiveFolder) dbUpdaterRoutine() {
-	const (
-		maxBatchSize = 1000
-		maxBatchTime = 2 * time.Second
-	)
+	maxBatchSize := 1000
+	maxBatchTime := 2 * time.Second
+	if f.flashStorage {
+		maxBatchSize = 10000
+		maxBatchTime = 10 * time.Second
+	}
 
 	batch := make([]dbUpdateJob, 0, maxBatchSize)
 	files := make([]protocol.FileInfo, 0, maxBatchSize)
@@ -1647,6 +2091,35 @@ func (f *sendReceiveFolder) inConflict(current, replacement protocol.Vector) boo
 	return false
 }
 
+// keepLocalInConflict returns whether the folder's conflict policy decides
+// for our current version of a file over the given replacement. Keeping the
+// newest version needs nothing from us, as the newest of versions in
+// conflict becomes the global version anyway.
+func (f *sendReceiveFolder) keepLocalInConflict(current, replacement protocol.FileInfo) bool {
+	switch f.ConflictPolicy {
+	case config.ConflictPolicyKeepLocal:
+		return true
+	case config.ConflictPolicyKeepLargest:
+		return current.FileSize() > replacement.FileSize()
+	default:
+		return false
+	}
+}
+
+// keepLocal resolves a conflict in favour of our current version of the
+// file by giving it a version that supersedes both, which makes it the
+// global version for other devices to pull.
+func (f *sendReceiveFolder) keepLocal(current, replacement protocol.FileInfo) {
+	l.Infof("Keeping local version of %q in folder %q, in conflict with a remote change.", current.Name, f.folderID)
+
+	current.Version = current.Version.Merge(replacement.Version).Update(f.model.shortID)
+	if current.IsDeleted() {
+		f.dbUpdates <- dbUpdateJob{current, dbUpdateDeleteFile}
+	} else {
+		f.dbUpdates <- dbUpdateJob{current, dbUpdateShortcutFile}
+	}
+}
+
 func removeAvailability(availabilities []Availability, availability Availability) []Availability {
 	for i := range availabilities {
 		if availabilities[i] == availability {
@@ -1657,7 +2130,9 @@ func removeAvailability(availabilities []Availability, availability Availability
 	return availabilities
 }
 
-func (f *sendReceiveFolder) moveForConflict(name string) error {
+// moveForConflict moves the file away to a conflict copy, keeping the
+// given version, which was replaced by the winner.
+func (f *sendReceiveFolder) moveForConflict(name string, copied, winner ConflictVersion) error {
 	if strings.Contains(filepath.Base(name), ".sync-conflict-") {
 		l.Infoln("Conflict for", name, "which is already a conflict copy; not copying again.")
 		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
@@ -1673,9 +2148,13 @@ func (f *sendReceiveFolder) moveForConflict(name string) error {
 		return nil
 	}
 
-	ext := filepath.Ext(name)
-	withoutExt := name[:len(name)-len(ext)]
-	newName := withoutExt + time.Now().Format(".sync-conflict-20060102-150405") + ext
+	if info, err := os.Lstat(name); err == nil {
+		// What's kept is what's on disk
+		copied.Modified = info.ModTime()
+		copied.Size = info.Size()
+	}
+	now := time.Now()
+	newName := conflictCopyName(name, f.ConflictNaming, copied, now)
 	err := os.Rename(name, newName)
 	if os.IsNotExist(err) {
 		// We were supposed to move a file away but it does not exist. Either
@@ -1683,16 +2162,39 @@ func (f *sendReceiveFolder) moveForConflict(name string) error {
 		// remote modification and a local delete. In either way it does not
 		// matter, go ahead as if the move succeeded.
 		err = nil
+	} else if err == nil {
+		if rel, rerr := filepath.Rel(f.dir, newName); rerr == nil {
+			events.Default.Log(events.ConflictCreated, map[string]string{
+				"folder": f.folderID,
+				"item":   rel,
+			})
+		}
+		if f.ConflictSidecars {
+			f.writeConflictSidecar(name, newName, copied, winner, now)
+		}
 	}
 	if f.MaxConflicts > -1 {
-		matches, gerr := osutil.Glob(withoutExt + ".sync-conflict-????????-??????" + ext)
-		if gerr == nil && len(matches) > f.MaxConflicts {
-			sort.Sort(sort.Reverse(sort.StringSlice(matches)))
-			for _, match := range matches[f.MaxConflicts:] {
+		ext := filepath.Ext(name)
+		withoutExt := name[:len(name)-len(ext)]
+		matches, gerr := osutil.Glob(withoutExt + ".sync-conflict-????????-??????*" + ext)
+		copies := matches[:0]
+		for _, match := range matches {
+			// The pattern matches sidecars, and conflict copies of other
+			// files whose names start with ours, as well
+			if orig, ok := conflictOriginal(match); ok && orig == name {
+				copies = append(copies, match)
+			}
+		}
+		if gerr == nil && len(copies) > f.MaxConflicts {
+			sort.Sort(sort.Reverse(sort.StringSlice(copies)))
+			for _, match := range copies[f.MaxConflicts:] {
 				gerr = os.Remove(match)
 				if gerr != nil {
 					l.Debugln(f, "removing extra conflict", gerr)
 				}
+				if gerr = os.Remove(match + conflictSidecarSuffix); gerr != nil && !os.IsNotExist(gerr) {
+					l.Debugln(f, "removing extra conflict sidecar", gerr)
+				}
 			}
 		} else if gerr != nil {
 			l.Debugln(f, "globbing for conflicts", gerr)
@@ -1701,10 +2203,35 @@ func (f *sendReceiveFolder) moveForConflict(name string) error {
 	return err
 }
 
+// writeConflictSidecar writes the sidecar describing the conflict copy just
+// made. Failing that is logged, as the copy has been made all the same.
+func (f *sendReceiveFolder) writeConflictSidecar(name, copyName string, copied, winner ConflictVersion, now time.Time) {
+	rel, err := filepath.Rel(f.dir, name)
+	if err != nil {
+		return
+	}
+	relCopy, err := filepath.Rel(f.dir, copyName)
+	if err != nil {
+		return
+	}
+	sidecar := ConflictSidecar{
+		File:    filepath.ToSlash(rel),
+		Copy:    filepath.ToSlash(relCopy),
+		Created: now,
+		Copied:  copied,
+		Winner:  winner,
+	}
+	if err := writeConflictSidecar(copyName, sidecar); err != nil {
+		l.Infof("Writing description of conflict copy %q in folder %q: %v", relCopy, f.folderID, err)
+	}
+}
+
 func (f *sendReceiveFolder) newError(path string, err error) {
 	f.errorsMut.Lock()
 	defer f.errorsMut.Unlock()
 
+	f.errorCount++
+
 	// We might get more than one error report for a file (i.e. error on
 	// Write() followed by Close()); we keep the first error as that is
 	// probably closer to the root cause.
@@ -1712,33 +2239,35 @@ func (f *sendReceiveFolder) newError(path string, err error) {
 		return
 	}
 
-	f.errors[path] = err.Error()
+	f.errors[path] = newFileError(path, err)
+}
+
+// errorsReported returns the number of errors reported so far, counting
+// repeated errors for the same file.
+func (f *sendReceiveFolder) errorsReported() int {
+	f.errorsMut.Lock()
+	defer f.errorsMut.Unlock()
+	return f.errorCount
 }
 
 func (f *sendReceiveFolder) clearErrors() {
 	f.errorsMut.Lock()
-	f.errors = make(map[string]string)
+	f.errors = make(map[string]FileError)
 	f.errorsMut.Unlock()
 }
 
-func (f *sendReceiveFolder) currentErrors() []fileError {
+func (f *sendReceiveFolder) currentErrors() []FileError {
 	f.errorsMut.Lock()
-	errors := make([]fileError, 0, len(f.errors))
-	for path, err := range f.errors {
-		errors = append(errors, fileError{path, err})
+	errors := make([]FileError, 0, len(f.errors))
+	for _, err := range f.errors {
+		errors = append(errors, err)
 	}
 	sort.Sort(fileErrorList(errors))
 	f.errorsMut.Unlock()
 	return errors
 }
 
-// A []fileError is sent as part of an event and will be JSON serialized.
-type fileError struct {
-	Path string `json:"path"`
-	Err  string `json:"error"`
-}
-
-type fileErrorList []fileError
+type fileErrorList []FileError
 
 func (l fileErrorList) Len() int {
 	return len(l)
@@ -7,19 +7,26 @@
 package model
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 
+	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/db"
 	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/ignore"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/scanner"
 	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syncthing/syncthing/lib/versioner"
 )
 
 func TestMain(m *testing.M) {
@@ -87,7 +94,8 @@ func setUpSendReceiveFolder(model *Model) sendReceiveFolder {
 		mtimeFS:   fs.NewMtimeFS(db.NewNamespacedKV(model.db, "mtime")),
 		dir:       "testdata",
 		queue:     newJobQueue(),
-		errors:    make(map[string]string),
+		limiter:   newFolderLimiter(defaultFolderConfig),
+		errors:    make(map[string]FileError),
 		errorsMut: sync.NewMutex(),
 	}
 }
@@ -380,6 +388,103 @@ func TestWeakHash(t *testing.T) {
 	}
 }
 
+func TestAppendedFile(t *testing.T) {
+	tempFile := filepath.Join("testdata", ignore.TempName("appended"))
+	var size int64 = 3*protocol.BlockSize + 1000
+	var appended int64 = 2 * protocol.BlockSize
+
+	cleanup := func() {
+		for _, path := range []string{tempFile, "testdata/appended"} {
+			os.Remove(path)
+		}
+	}
+
+	cleanup()
+	defer cleanup()
+
+	data := make([]byte, size+appended)
+	if _, err := io.ReadFull(rand.Reader, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile("testdata/appended", data[:size], 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat("testdata/appended")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existing, err := scanner.Blocks(bytes.NewReader(data[:size]), protocol.BlockSize, size, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desired, err := scanner.Blocks(bytes.NewReader(data), protocol.BlockSize, size+appended, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existingFile := protocol.FileInfo{
+		Name:       "appended",
+		Blocks:     existing,
+		Size:       size,
+		ModifiedS:  info.ModTime().Unix(),
+		ModifiedNs: int32(info.ModTime().Nanosecond()),
+	}
+	desiredFile := protocol.FileInfo{
+		Name:      "appended",
+		Size:      size + appended,
+		Blocks:    desired,
+		ModifiedS: info.ModTime().Unix() + 1,
+	}
+
+	m := setUpModel(existingFile)
+	// Neither the block finder nor the weak hasher may find the unchanged
+	// blocks; they must be copied as part of the append.
+	m.finder = db.NewBlockFinder(db.OpenMemory())
+	fo := setUpSendReceiveFolder(m)
+	fo.WeakHashThresholdPct = 101
+	copyChan := make(chan copyBlocksState, 1)
+	pullChan := make(chan pullBlockState, len(desired))
+	finisherChan := make(chan *sharedPullerState, 1)
+
+	// The three complete blocks are kept, the rest pulled
+
+	fo.handleFile(desiredFile, copyChan, finisherChan)
+	state := <-copyChan
+	if state.appendPrefix != 3 {
+		t.Fatalf("Expected an append to three blocks, got %d", state.appendPrefix)
+	}
+
+	go fo.copierRoutine(copyChan, pullChan, finisherChan)
+	copyChan <- state
+
+	var pulls []pullBlockState
+	for len(pulls) < 3 {
+		select {
+		case pull := <-pullChan:
+			if pull.block.Offset < 3*protocol.BlockSize {
+				t.Errorf("Pulling unchanged block at %d", pull.block.Offset)
+			}
+			pulls = append(pulls, pull)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out, got %d pulls expected 3", len(pulls))
+		}
+	}
+	finish := <-finisherChan
+	finish.fd.Close()
+	if finish.copyOrigin != 3 {
+		t.Errorf("Copied %d blocks from the original, expected 3", finish.copyOrigin)
+	}
+
+	written, err := ioutil.ReadFile(tempFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(written[:3*protocol.BlockSize], data[:3*protocol.BlockSize]) {
+		t.Error("Unchanged blocks not copied to the temp file")
+	}
+}
+
 // Test that updating a file removes it's old blocks from the blockmap
 func TestCopierCleanup(t *testing.T) {
 	iterFn := func(folder, file string, index int32) bool {
@@ -601,3 +706,571 @@ func TestDeregisterOnFailInPull(t *testing.T) {
 		t.Fatal("Didn't get anything to the finisher")
 	}
 }
+
+func TestStrictDeleteOrdering(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		dir, err := ioutil.TempDir("", "syncthing")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		// "todelete" is deleted remotely, and "blocker/dir" is a new
+		// directory that can't be created as "blocker" is a file.
+		for _, name := range []string{"todelete", "blocker"} {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("hello"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		local := []protocol.FileInfo{
+			{Name: "todelete", Version: protocol.Vector{}.Update(protocol.LocalDeviceID.Short()), Blocks: blocks[1:2]},
+			{Name: "blocker", Version: protocol.Vector{}.Update(protocol.LocalDeviceID.Short()), Blocks: blocks[2:3]},
+		}
+		remote := []protocol.FileInfo{
+			{Name: "todelete", Deleted: true, Version: local[0].Version.Update(device1.Short())},
+			{Name: "blocker/dir", Type: protocol.FileInfoTypeDirectory, Permissions: 0755, Version: protocol.Vector{}.Update(device1.Short())},
+		}
+
+		m := setUpModel(local[0])
+		m.updateLocalsFromScanning("default", local[1:])
+		m.folderFiles["default"].Update(device1, remote)
+
+		f := setUpSendReceiveFolder(m)
+		f.dir = dir
+		f.StrictDeleteOrdering = strict
+		f.pullerIteration(ignore.New(false))
+
+		if len(f.currentErrors()) == 0 {
+			t.Fatal("Expected an error creating blocker/dir")
+		}
+		_, err = os.Lstat(filepath.Join(dir, "todelete"))
+		if strict && err != nil {
+			t.Error("File deleted despite failed changes in strict mode:", err)
+		} else if !strict && !os.IsNotExist(err) {
+			t.Error("File not deleted:", err)
+		}
+	}
+}
+
+func TestWriteOnceFolder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// "existing" is deleted remotely, which is refused, as is creating
+	// "unscanned", which is on disk but not yet in the database. "newdir"
+	// is new and created.
+	for _, name := range []string{"existing", "unscanned"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("hello"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	local := protocol.FileInfo{Name: "existing", Version: protocol.Vector{}.Update(protocol.LocalDeviceID.Short()), Blocks: blocks[1:2]}
+	remote := []protocol.FileInfo{
+		{Name: "existing", Deleted: true, Version: local.Version.Update(device1.Short())},
+		{Name: "unscanned", Version: protocol.Vector{}.Update(device1.Short()), Blocks: blocks[2:3]},
+		{Name: "newdir", Type: protocol.FileInfoTypeDirectory, Permissions: 0755, Version: protocol.Vector{}.Update(device1.Short())},
+	}
+
+	m := setUpModel(local)
+	m.folderFiles["default"].Update(device1, remote)
+
+	f := setUpSendReceiveFolder(m)
+	f.dir = dir
+	f.writeOnce = newFolderWriteOnce(config.FolderConfiguration{WriteOnce: true})
+	f.pullerIteration(ignore.New(false))
+
+	if errs := f.currentErrors(); len(errs) != 2 || errs[0].Path != "existing" || errs[1].Path != "unscanned" || errs[0].Class != errorClassWriteOnce {
+		t.Errorf("Expected the changes to the existing files to be refused, got %+v", errs)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "existing")); err != nil {
+		t.Error("Existing file deleted in a write once folder:", err)
+	}
+	if bs, err := ioutil.ReadFile(filepath.Join(dir, "unscanned")); err != nil || string(bs) != "hello" {
+		t.Error("Unscanned file changed in a write once folder:", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "newdir")); err != nil {
+		t.Error("New directory not created:", err)
+	}
+	if f.writeOnce.Violations() != 2 {
+		t.Errorf("Expected two violations, got %d", f.writeOnce.Violations())
+	}
+}
+
+func TestConflictPolicyKeepLocal(t *testing.T) {
+	local := setUpFile("filex", []int{0, 2, 0, 0, 5, 0, 0, 8})
+	local.Size = 8 * protocol.BlockSize
+	local.Version = protocol.Vector{}.Update(protocol.LocalDeviceID.Short())
+	remote := setUpFile("filex", []int{1, 2, 3, 4, 5, 6, 7, 8})
+	remote.Size = 9 * protocol.BlockSize
+	remote.Version = protocol.Vector{}.Update(device1.Short())
+
+	m := setUpModel(local)
+	f := setUpSendReceiveFolder(m)
+	f.model.shortID = protocol.LocalDeviceID.Short()
+
+	for _, tc := range []struct {
+		policy    config.ConflictPolicy
+		keepLocal bool
+	}{
+		{config.ConflictPolicyCopy, false},
+		{config.ConflictPolicyKeepNewest, false},
+		{config.ConflictPolicyKeepLargest, false},
+		{config.ConflictPolicyKeepLocal, true},
+		{config.ConflictPolicyKeepRemote, false},
+	} {
+		f.ConflictPolicy = tc.policy
+		f.dbUpdates = make(chan dbUpdateJob, 1)
+		copyChan := make(chan copyBlocksState, 1)
+
+		f.handleFile(remote, copyChan, nil)
+
+		if !tc.keepLocal {
+			if len(copyChan) != 1 || len(f.dbUpdates) != 0 {
+				t.Errorf("%v: the remote file wasn't pulled", tc.policy)
+			}
+			continue
+		}
+
+		if len(copyChan) != 0 || len(f.dbUpdates) != 1 {
+			t.Fatalf("%v: the remote file was pulled", tc.policy)
+		}
+		job := <-f.dbUpdates
+		if job.file.Size != local.Size || !job.file.Version.GreaterEqual(local.Version) || !job.file.Version.GreaterEqual(remote.Version) || job.file.Version.Equal(local.Version.Merge(remote.Version)) {
+			t.Errorf("%v: the local file wasn't given a superseding version: %v", tc.policy, job.file)
+		}
+	}
+
+	// The largest file wins when keeping the largest
+
+	remote.Size = 7 * protocol.BlockSize
+	f.ConflictPolicy = config.ConflictPolicyKeepLargest
+	f.dbUpdates = make(chan dbUpdateJob, 1)
+	f.handleFile(remote, make(chan copyBlocksState, 1), nil)
+	if len(f.dbUpdates) != 1 {
+		t.Error("The larger local file wasn't kept")
+	}
+}
+
+func TestConflictPolicyKeepRemote(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := setUpModel(protocol.FileInfo{Name: "unrelated"})
+	f := setUpSendReceiveFolder(m)
+	f.model.shortID = protocol.LocalDeviceID.Short()
+	f.dir = dir
+	f.MaxConflicts = 10
+
+	for _, policy := range []config.ConflictPolicy{config.ConflictPolicyKeepRemote, config.ConflictPolicyCopy} {
+		realName := filepath.Join(dir, "file")
+		tempName := filepath.Join(dir, ignore.TempName("file"))
+		if err := ioutil.WriteFile(realName, []byte("local"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(tempName, []byte("remote"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		state := &sharedPullerState{
+			file:     protocol.FileInfo{Name: "file", Version: protocol.Vector{}.Update(device1.Short()), NoPermissions: true},
+			version:  protocol.Vector{}.Update(protocol.LocalDeviceID.Short()),
+			tempName: tempName,
+			realName: realName,
+			mut:      sync.NewRWMutex(),
+		}
+		f.ConflictPolicy = policy
+		f.IgnorePerms = true
+		f.dbUpdates = make(chan dbUpdateJob, 1)
+
+		if err := f.performFinish(state); err != nil {
+			t.Fatal(err)
+		}
+
+		if bs, err := ioutil.ReadFile(realName); err != nil || string(bs) != "remote" {
+			t.Errorf("%v: the remote file didn't replace the local one: %q, %v", policy, bs, err)
+		}
+		if !state.file.Version.GreaterEqual(state.version) {
+			t.Errorf("%v: the versions weren't merged: %v", policy, state.file.Version)
+		}
+		conflicts, _ := filepath.Glob(filepath.Join(dir, "file.sync-conflict-*"))
+		if (policy == config.ConflictPolicyCopy) != (len(conflicts) == 1) {
+			t.Errorf("%v: unexpected conflict copies %v", policy, conflicts)
+		}
+	}
+}
+
+func TestConflictSidecars(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := setUpModel(protocol.FileInfo{Name: "unrelated"})
+	f := setUpSendReceiveFolder(m)
+	f.dir = dir
+	f.MaxConflicts = 1
+	f.ConflictNaming = config.ConflictNamingVersion
+	f.ConflictSidecars = true
+
+	realName := filepath.Join(dir, "file.txt")
+	for i, mtime := range []time.Time{time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC), time.Date(2017, 2, 3, 4, 5, 6, 0, time.UTC)} {
+		if err := ioutil.WriteFile(realName, []byte("local"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(realName, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+
+		copied := ConflictVersion{ModifiedBy: device2.Short(), Version: protocol.Vector{}.Update(device2.Short())}
+		winner := ConflictVersion{ModifiedBy: device1.Short(), Size: 6, Version: protocol.Vector{}.Update(device1.Short())}
+		if err := f.moveForConflict(realName, copied, winner); err != nil {
+			t.Fatal(err)
+		}
+
+		// The copy is named by the version it keeps, and described in
+		// its sidecar
+
+		copyName := "file.sync-conflict-" + mtime.Format("20060102-150405") + "-" + device2.Short().String() + ".txt"
+		bs, err := ioutil.ReadFile(filepath.Join(dir, copyName+conflictSidecarSuffix))
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		var sidecar ConflictSidecar
+		if err := json.Unmarshal(bs, &sidecar); err != nil {
+			t.Fatal(err)
+		}
+		if sidecar.File != "file.txt" || sidecar.Copy != copyName || sidecar.Copied.Size != 5 || !sidecar.Copied.Modified.Equal(mtime) ||
+			sidecar.Copied.ModifiedBy != device2.Short() || sidecar.Winner.ModifiedBy != device1.Short() || !sidecar.Winner.Version.Equal(winner.Version) {
+			t.Errorf("%d: unexpected sidecar %+v", i, sidecar)
+		}
+		if orig, ok := conflictOriginal(copyName); !ok || orig != "file.txt" {
+			t.Errorf("%d: copy %q not recognized as a copy of file.txt: %q", i, copyName, orig)
+		}
+		if _, ok := conflictOriginal(copyName + conflictSidecarSuffix); ok {
+			t.Errorf("%d: sidecar recognized as a conflict copy", i)
+		}
+	}
+
+	// The older copy went, along with its sidecar
+
+	names, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(names) != 2 {
+		t.Errorf("Unexpected files after the second conflict: %v", names)
+	}
+}
+
+func TestMatchPriorityPattern(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"*.pdf", "a.pdf", true},
+		{"*.pdf", "dir/sub/a.pdf", true},
+		{"*.pdf", "a.pdf.txt", false},
+		{"dir/*.pdf", "dir/a.pdf", true},
+		{"dir/*.pdf", "dir/sub/a.pdf", false},
+		{"dir/*", "other/a.pdf", false},
+	}
+	for _, tc := range cases {
+		if match := matchPriorityPattern(tc.pattern, filepath.FromSlash(tc.name)); match != tc.match {
+			t.Errorf("%q matching %q: got %v, expected %v", tc.pattern, tc.name, match, tc.match)
+		}
+	}
+}
+
+func TestFileErrorTriage(t *testing.T) {
+	file := setUpFile("filex", []int{0, 1})
+	file.Version = protocol.Vector{}.Update(protocol.LocalDeviceID.Short())
+	m := setUpModel(file)
+	f := setUpSendReceiveFolder(m)
+	f.ignoredErrors = make(map[string]ignoredFileError)
+	f.retryFirst = make(map[string]bool)
+
+	f.newError("filex", os.ErrPermission)
+	f.newError("filey", errInsufficientSpace)
+	f.newError("filex", errNoDevice) // the first error is kept
+
+	errs := f.Errors()
+	if len(errs) != 2 {
+		t.Fatalf("Expected two errors, got %v", errs)
+	}
+	if errs[0].Path != "filex" || errs[0].Class != errorClassPermission || errs[1].Class != errorClassNoSpace {
+		t.Errorf("Unexpected errors %v", errs)
+	}
+	id := errs[0].ID
+	if id != fileErrorID("filex") || id == errs[1].ID {
+		t.Errorf("Unstable or duplicate error IDs in %v", errs)
+	}
+
+	// Ignoring the error skips the file until it changes.
+
+	f.IgnoreErrors([]string{id})
+	if errs := f.Errors(); len(errs) != 2 || !errs[0].Ignored || errs[1].Ignored {
+		t.Errorf("Error not ignored: %v", errs)
+	}
+	f.clearErrors()
+	if errs := f.Errors(); len(errs) != 1 || errs[0].ID != id {
+		t.Errorf("Ignored error not kept past the pull: %v", errs)
+	}
+	if !f.errorIgnored(file) {
+		t.Error("Unchanged file not ignored")
+	}
+	changed := file
+	changed.Version = changed.Version.Update(device1.Short())
+	if f.errorIgnored(changed) {
+		t.Error("Changed file ignored")
+	}
+	if errs := f.Errors(); len(errs) != 0 {
+		t.Errorf("Ignored error kept after the file changed: %v", errs)
+	}
+
+	// Retrying forgets the error and pulls the file first.
+
+	f.newError("filex", os.ErrPermission)
+	f.IgnoreErrors([]string{id})
+	f.RetryErrors([]string{id})
+	if errs := f.Errors(); len(errs) != 0 {
+		t.Errorf("Retried error kept: %v", errs)
+	}
+	if f.errorIgnored(file) {
+		t.Error("Retried file still ignored")
+	}
+	if retry := f.takeRetries(); !retry["filex"] || len(retry) != 1 {
+		t.Errorf("Unexpected retries %v", retry)
+	}
+	if retry := f.takeRetries(); len(retry) != 0 {
+		t.Errorf("Retries not taken: %v", retry)
+	}
+}
+
+func TestHandleFilePlaceholder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	remote := setUpFile("filex", []int{1, 2, 3, 4, 5, 6, 7, 8})
+	remote.Size = 8 * protocol.BlockSize
+	remote.Permissions = 0644
+	remote.ModifiedS = 1234567890
+	remote.Version = protocol.Vector{}.Update(device1.Short())
+
+	m := setUpModel(setUpFile("other", []int{0}))
+	f := setUpSendReceiveFolder(m)
+	f.dir = dir
+	f.Placeholders = true
+	f.dbUpdates = make(chan dbUpdateJob, 1)
+	copyChan := make(chan copyBlocksState, 1)
+
+	// A new file gets a placeholder.
+
+	f.handleFile(remote, copyChan, nil)
+	if len(copyChan) != 0 || len(f.dbUpdates) != 1 {
+		t.Fatal("Expected a placeholder, not the data")
+	}
+	job := <-f.dbUpdates
+	if !job.file.IsPlaceholder() || job.file.Size != remote.Size {
+		t.Errorf("Unexpected placeholder record %v", job.file)
+	}
+	info, err := os.Stat(filepath.Join(dir, "filex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 || !info.ModTime().Equal(remote.ModTime()) {
+		t.Errorf("Unexpected placeholder on disk: size %d, mtime %v", info.Size(), info.ModTime())
+	}
+	m.updateLocals("default", []protocol.FileInfo{job.file})
+
+	// Its data is pulled once asked for.
+
+	if n, err := m.FetchPlaceholders("default", []string{"filex"}); err != nil || n != 1 {
+		t.Fatalf("Expected one placeholder to fetch, got %d, %v", n, err)
+	}
+	cur, _ := m.CurrentFolderFile("default", "filex")
+	if !cur.IsPlaceholder() || !cur.IsInvalid() || !cur.Version.Equal(remote.Version) {
+		t.Errorf("Unexpected fetched placeholder record %v", cur)
+	}
+
+	f.handleFile(remote, copyChan, nil)
+	if len(copyChan) != 1 || len(f.dbUpdates) != 0 {
+		t.Fatal("Expected the data to be pulled")
+	}
+	toCopy := <-copyChan
+	if len(toCopy.blocks) != len(remote.Blocks) || toCopy.file.IsPlaceholder() {
+		t.Errorf("Unexpected pull of %d blocks for %v", len(toCopy.blocks), toCopy.file)
+	}
+}
+
+func TestPullHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks are shell scripts")
+	}
+
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	hooks, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(hooks)
+
+	log := filepath.Join(hooks, "log")
+	scripts := map[string]string{
+		"pre":     `echo "pre $STFOLDER $STACTION $STTYPE $STPATH" >> ` + log,
+		"post":    `echo "post $STRESULT $STACTION $STTYPE $STPATH" >> ` + log,
+		"fail":    `exit 1`,
+		"timeout": `exec sleep 10`,
+	}
+	for name, script := range scripts {
+		if err := ioutil.WriteFile(filepath.Join(hooks, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		pre     string
+		failure config.PullHookFailure
+		pulled  bool
+		err     string
+		log     string
+	}{
+		{"pre", config.PullHookFailureIgnore, true, "", "pre default update dir %[1]s\npost success update dir %[1]s\n"},
+		{"fail", config.PullHookFailureIgnore, true, "", "post success update dir %[1]s\n"},
+		{"fail", config.PullHookFailureError, false, "pre pull hook: exit status 1", ""},
+		{"timeout", config.PullHookFailureError, false, "pre pull hook: timed out", ""},
+	}
+
+	m := setUpModel(protocol.FileInfo{Name: "unrelated"})
+	for i, tc := range cases {
+		name := fmt.Sprintf("dir%d", i)
+		os.Remove(log)
+
+		f := setUpSendReceiveFolder(m)
+		f.folderID = "default"
+		f.dir = dir
+		f.dbUpdates = make(chan dbUpdateJob, 1)
+		f.PrePullCommand = filepath.Join(hooks, tc.pre)
+		f.PostPullCommand = filepath.Join(hooks, "post")
+		f.PullHookTimeoutS = 1
+		f.PullHookFailure = tc.failure
+
+		f.handleDir(protocol.FileInfo{Name: name, Type: protocol.FileInfoTypeDirectory, Permissions: 0755})
+
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != tc.pulled {
+			t.Errorf("%d: expected pulled %v, got %v", i, tc.pulled, err)
+		}
+		errs := f.currentErrors()
+		if tc.err == "" && len(errs) != 0 || tc.err != "" && (len(errs) != 1 || errs[0].Err != tc.err) {
+			t.Errorf("%d: expected error %q, got %v", i, tc.err, errs)
+		}
+		bs, _ := ioutil.ReadFile(log)
+		expected := tc.log
+		if expected != "" {
+			expected = fmt.Sprintf(expected, name)
+		}
+		if string(bs) != expected {
+			t.Errorf("%d: expected hooks to log %q, got %q", i, expected, bs)
+		}
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the merge command is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	scripts, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(scripts)
+
+	merge := filepath.Join(scripts, "merge")
+	if err := ioutil.WriteFile(merge, []byte("#!/bin/sh\ncat \"$STOURS\" \"$STTHEIRS\" \"$STBASE\" > \"$STMERGED\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	m := setUpModel(protocol.FileInfo{Name: "unrelated"})
+	f := setUpSendReceiveFolder(m)
+	f.model.shortID = protocol.LocalDeviceID.Short()
+	f.dir = dir
+	f.IgnorePerms = true
+	f.MaxConflicts = 10
+	f.MergeCommand = merge
+	f.versioner = versioner.NewSimple("default", dir, map[string]string{"keep": "10"})
+
+	// The base is the version archived before both were changed
+
+	realName := filepath.Join(dir, "file.txt")
+	baseTime := time.Now().Add(-time.Hour)
+	if err := ioutil.WriteFile(realName, []byte("base\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(realName, baseTime, baseTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.versioner.Archive(realName); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pattern := range []string{"*.txt", "*.md"} {
+		tempName := filepath.Join(dir, ignore.TempName("file.txt"))
+		if err := ioutil.WriteFile(realName, []byte("ours\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(tempName, []byte("theirs\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		state := &sharedPullerState{
+			file:     protocol.FileInfo{Name: "file.txt", Version: protocol.Vector{}.Update(device1.Short()), ModifiedS: time.Now().Unix(), NoPermissions: true},
+			version:  protocol.Vector{}.Update(protocol.LocalDeviceID.Short()),
+			tempName: tempName,
+			realName: realName,
+			mut:      sync.NewRWMutex(),
+		}
+		f.MergePatterns = []string{pattern}
+		f.dbUpdates = make(chan dbUpdateJob, 1)
+
+		if err := f.performFinish(state); err != nil {
+			t.Fatal(err)
+		}
+
+		merged := pattern == "*.txt"
+		expected := "theirs\n"
+		if merged {
+			expected = "ours\ntheirs\nbase\n"
+		}
+		if bs, err := ioutil.ReadFile(realName); err != nil || string(bs) != expected {
+			t.Errorf("%s: expected %q, got %q, %v", pattern, expected, bs, err)
+		}
+		if !state.file.Version.GreaterEqual(state.version) {
+			t.Errorf("%s: the versions weren't merged: %v", pattern, state.file.Version)
+		}
+		conflicts, _ := filepath.Glob(filepath.Join(dir, "file.sync-conflict-*"))
+		if merged != (len(conflicts) == 0) {
+			t.Errorf("%s: unexpected conflict copies %v", pattern, conflicts)
+		}
+		leftovers, _ := filepath.Glob(filepath.Join(dir, ".syncthing.*"))
+		if len(leftovers) != 0 {
+			t.Errorf("%s: temporary files left behind: %v", pattern, leftovers)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var errParentUnselected = errors.New("parent directory is not selected")
+
+// Unselected returns the directories of the folder that are not synced
+// locally, as slash separated paths relative to the folder root.
+func (m *Model) Unselected(folder string) ([]string, error) {
+	m.fmut.RLock()
+	ignores, ok := m.folderIgnores[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil, errFolderMissing
+	}
+	return ignores.Unselected(), nil
+}
+
+// SetSelected selects or unselects a directory of the folder for local
+// sync. An unselected directory is treated as ignored: what's in it is not
+// pulled, and any local copy is left alone and no longer announced, but not
+// deleted on the other devices. The rest of the folder syncs as usual and
+// the directory remains part of the global index, so it can be browsed and
+// selected again later. The selection is persisted in the database.
+func (m *Model) SetSelected(folder, dir string, selected bool) error {
+	dir = filepath.Clean(dir)
+	if _, err := rootedJoinedPath("root", dir); err != nil || dir == "." {
+		return errors.New("invalid path")
+	}
+	dir = filepath.ToSlash(dir)
+
+	m.fmut.Lock()
+	ignores, ok := m.folderIgnores[folder]
+	files := m.folderFiles[folder]
+	if !ok {
+		m.fmut.Unlock()
+		return errFolderMissing
+	}
+
+	var unselected []string
+	for _, cur := range ignores.Unselected() {
+		switch {
+		case selected && cur != dir && isParentDir(cur, dir):
+			m.fmut.Unlock()
+			return errParentUnselected
+		case cur == dir, !selected && isParentDir(dir, cur):
+			// Removed, or subsumed by the directory being unselected.
+		default:
+			unselected = append(unselected, cur)
+		}
+	}
+	if !selected {
+		unselected = append(unselected, dir)
+	}
+	sort.Strings(unselected)
+
+	files.SetUnselected(unselected)
+	ignores.SetUnselected(unselected)
+	m.fmut.Unlock()
+
+	// Rescan the directory to stop or resume announcing what's in it, and
+	// get the puller going on what we now need.
+	if err := m.ScanFolderSubdirs(folder, []string{filepath.FromSlash(dir)}); err != nil {
+		return err
+	}
+	m.fmut.RLock()
+	runner, ok := m.folderRunners[folder]
+	m.fmut.RUnlock()
+	if ok {
+		runner.IndexUpdated()
+	}
+	return nil
+}
+
+// isParentDir returns true if child is inside the directory parent, both
+// being slash separated paths.
+func isParentDir(parent, child string) bool {
+	return strings.HasPrefix(child, parent+"/")
+}
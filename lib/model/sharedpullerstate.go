@@ -10,8 +10,10 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/sync"
 )
@@ -26,24 +28,29 @@ type sharedPullerState struct {
 	realName    string
 	reused      int // Number of blocks reused from temporary file
 	ignorePerms bool
-	version     protocol.Vector // The current (old) version
+	version     protocol.Vector  // The current (old) version
+	modifiedBy  protocol.ShortID // Who made the current (old) version
 	sparse      bool
 	created     time.Time
+	writeBuffer int            // Bytes of temp file writes to coalesce; 0 to write directly
+	throttle    *fs.IOThrottle // Limits the writes to the temp file, if set
 
 	// Mutable, must be locked for access
-	err               error        // The first error we hit
-	fd                *os.File     // The fd of the temp file
-	copyTotal         int          // Total number of copy actions for the whole job
-	pullTotal         int          // Total number of pull actions for the whole job
-	copyOrigin        int          // Number of blocks copied from the original file
-	copyOriginShifted int          // Number of blocks copied from the original file but shifted
-	copyNeeded        int          // Number of copy actions still pending
-	pullNeeded        int          // Number of block pulls still pending
-	updated           time.Time    // Time when any of the counters above were last updated
-	closed            bool         // True if the file has been finalClosed.
-	available         []int32      // Indexes of the blocks that are available in the temporary file
-	availableUpdated  time.Time    // Time when list of available blocks was last updated
-	mut               sync.RWMutex // Protects the above
+	err               error           // The first error we hit
+	fd                *os.File        // The fd of the temp file
+	writer            *writeCoalescer // Buffers writes to fd, when writeBuffer is set
+	copyTotal         int             // Total number of copy actions for the whole job
+	pullTotal         int             // Total number of pull actions for the whole job
+	copyOrigin        int             // Number of blocks copied from the original file
+	copyOriginShifted int             // Number of blocks copied from the original file but shifted
+	copyNeeded        int             // Number of copy actions still pending
+	pullNeeded        int             // Number of block pulls still pending
+	updated           time.Time       // Time when any of the counters above were last updated
+	closed            bool            // True if the file has been finalClosed.
+	available         []int32         // Indexes of the blocks that are available in the temporary file
+	pendingAvailable  []int32         // Indexes of the blocks that are done but may still be buffered
+	availableUpdated  time.Time       // Time when list of available blocks was last updated
+	mut               sync.RWMutex    // Protects the above
 }
 
 // A momentary state representing the progress of the puller
@@ -72,6 +79,91 @@ func (w lockedWriterAt) WriteAt(p []byte, off int64) (n int, err error) {
 	return w.wr.WriteAt(p, off)
 }
 
+// A writeCoalescer buffers writes in memory and writes them out in offset
+// order once more than max bytes are pending, merging adjacent writes. This
+// trades memory for fewer and larger writes, which is kinder to flash
+// storage. It is not goroutine safe.
+type writeCoalescer struct {
+	wr      io.WriterAt
+	max     int
+	pending []pendingWrite
+	size    int
+	flushed func() // called after each successful flush
+}
+
+type pendingWrite struct {
+	off  int64
+	data []byte
+}
+
+type pendingWriteList []pendingWrite
+
+func (l pendingWriteList) Len() int           { return len(l) }
+func (l pendingWriteList) Swap(a, b int)      { l[a], l[b] = l[b], l[a] }
+func (l pendingWriteList) Less(a, b int) bool { return l[a].off < l[b].off }
+
+func newWriteCoalescer(wr io.WriterAt, max int, flushed func()) *writeCoalescer {
+	return &writeCoalescer{
+		wr:      wr,
+		max:     max,
+		flushed: flushed,
+	}
+}
+
+func (w *writeCoalescer) WriteAt(p []byte, off int64) (int, error) {
+	// The caller may reuse p, so we need our own copy.
+	data := make([]byte, len(p))
+	copy(data, p)
+	w.pending = append(w.pending, pendingWrite{off, data})
+	w.size += len(p)
+
+	if w.size >= w.max {
+		if err := w.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Buffered returns the number of bytes not yet written out.
+func (w *writeCoalescer) Buffered() int {
+	return w.size
+}
+
+func (w *writeCoalescer) Flush() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+
+	// The sort is stable so that overlapping writes still happen in the
+	// order they were made.
+	sort.Stable(pendingWriteList(w.pending))
+
+	// Merge runs of adjacent writes into one and write them out.
+	start := w.pending[0].off
+	run := w.pending[0].data
+	for _, pw := range w.pending[1:] {
+		if pw.off == start+int64(len(run)) {
+			run = append(run, pw.data...)
+			continue
+		}
+		if _, err := w.wr.WriteAt(run, start); err != nil {
+			return err
+		}
+		start, run = pw.off, pw.data
+	}
+	if _, err := w.wr.WriteAt(run, start); err != nil {
+		return err
+	}
+
+	w.pending = w.pending[:0]
+	w.size = 0
+	if w.flushed != nil {
+		w.flushed()
+	}
+	return nil
+}
+
 // tempFile returns the fd for the temporary file, reusing an open fd
 // or creating the file as necessary.
 func (s *sharedPullerState) tempFile() (io.WriterAt, error) {
@@ -85,7 +177,7 @@ func (s *sharedPullerState) tempFile() (io.WriterAt, error) {
 
 	// If the temp file is already open, return the file descriptor
 	if s.fd != nil {
-		return lockedWriterAt{&s.mut, s.fd}, nil
+		return s.writerLocked(), nil
 	}
 
 	// Ensure that the parent directory is writable. This is
@@ -175,8 +267,41 @@ func (s *sharedPullerState) tempFile() (io.WriterAt, error) {
 
 	// Same fd will be used by all writers
 	s.fd = fd
+	if s.writeBuffer > 0 {
+		s.writer = newWriteCoalescer(s.throttle.WriterAt(fd), s.writeBuffer, s.flushedLocked)
+	}
+
+	return s.writerLocked(), nil
+}
+
+func (s *sharedPullerState) writerLocked() io.WriterAt {
+	if s.writer != nil {
+		return lockedWriterAt{&s.mut, s.writer}
+	}
+	return lockedWriterAt{&s.mut, s.throttle.WriterAt(s.fd)}
+}
+
+// flushedLocked is called when the buffered writes have been written to the
+// temp file, making the pending blocks available to others.
+func (s *sharedPullerState) flushedLocked() {
+	if len(s.pendingAvailable) == 0 {
+		return
+	}
+	s.available = append(s.available, s.pendingAvailable...)
+	s.pendingAvailable = s.pendingAvailable[:0]
+	s.availableUpdated = time.Now()
+}
 
-	return lockedWriterAt{&s.mut, s.fd}, nil
+// blockDoneLocked marks the block as available in the temp file, or as
+// pending if it may still be sitting in the write buffer.
+func (s *sharedPullerState) blockDoneLocked(block protocol.BlockInfo) {
+	idx := int32(block.Offset / int64(s.file.BlockSize()))
+	if s.writer != nil && s.writer.Buffered() > 0 {
+		s.pendingAvailable = append(s.pendingAvailable, idx)
+		return
+	}
+	s.available = append(s.available, idx)
+	s.availableUpdated = time.Now()
 }
 
 // sourceFile opens the existing source file for reading
@@ -230,8 +355,7 @@ func (s *sharedPullerState) copyDone(block protocol.BlockInfo) {
 	s.mut.Lock()
 	s.copyNeeded--
 	s.updated = time.Now()
-	s.available = append(s.available, int32(block.Offset/protocol.BlockSize))
-	s.availableUpdated = time.Now()
+	s.blockDoneLocked(block)
 	l.Debugln("sharedPullerState", s.folder, s.file.Name, "copyNeeded ->", s.copyNeeded)
 	s.mut.Unlock()
 }
@@ -266,8 +390,7 @@ func (s *sharedPullerState) pullDone(block protocol.BlockInfo) {
 	s.mut.Lock()
 	s.pullNeeded--
 	s.updated = time.Now()
-	s.available = append(s.available, int32(block.Offset/protocol.BlockSize))
-	s.availableUpdated = time.Now()
+	s.blockDoneLocked(block)
 	l.Debugln("sharedPullerState", s.folder, s.file.Name, "pullNeeded done ->", s.pullNeeded)
 	s.mut.Unlock()
 }
@@ -291,6 +414,13 @@ func (s *sharedPullerState) finalClose() (bool, error) {
 		return false, nil
 	}
 
+	if s.writer != nil {
+		if flushErr := s.writer.Flush(); flushErr != nil && s.err == nil {
+			s.err = flushErr
+		}
+		s.writer = nil
+	}
+
 	if s.fd != nil {
 		if closeErr := s.fd.Close(); closeErr != nil && s.err == nil {
 			// This is our error if we weren't errored before. Otherwise we
@@ -318,8 +448,8 @@ func (s *sharedPullerState) Progress() *pullerProgress {
 		CopiedFromElsewhere: s.copyTotal - s.copyNeeded - s.copyOrigin,
 		Pulled:              s.pullTotal - s.pullNeeded,
 		Pulling:             s.pullNeeded,
-		BytesTotal:          blocksToSize(total),
-		BytesDone:           blocksToSize(done),
+		BytesTotal:          blocksToSize(total, s.file.BlockSize()),
+		BytesDone:           blocksToSize(done, s.file.BlockSize()),
 	}
 }
 
@@ -347,9 +477,9 @@ func (s *sharedPullerState) Available() []int32 {
 	return blocks
 }
 
-func blocksToSize(num int) int64 {
+func blocksToSize(num, blockSize int) int64 {
 	if num < 2 {
-		return protocol.BlockSize / 2
+		return int64(blockSize / 2)
 	}
-	return int64(num-1)*protocol.BlockSize + protocol.BlockSize/2
+	return int64(num-1)*int64(blockSize) + int64(blockSize/2)
 }
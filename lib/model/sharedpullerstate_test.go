@@ -7,9 +7,11 @@
 package model
 
 import (
+	"bytes"
 	"os"
 	"testing"
 
+	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/sync"
 )
 
@@ -88,3 +90,66 @@ func TestReadOnlyDir(t *testing.T) {
 	s.fail("Test done", nil)
 	s.finalClose()
 }
+
+type recordingWriterAt struct {
+	buf    []byte
+	writes int
+}
+
+func (w *recordingWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	w.writes++
+	copy(w.buf[off:], p)
+	return len(p), nil
+}
+
+func TestWriteCoalescer(t *testing.T) {
+	wr := &recordingWriterAt{buf: make([]byte, 8)}
+	flushes := 0
+	c := newWriteCoalescer(wr, 6, func() { flushes++ })
+
+	// Out of order, adjacent writes are buffered until max is reached.
+	buf := []byte("cd")
+	c.WriteAt(buf, 2)
+	buf[0], buf[1] = 'a', 'b' // the writer must not hold on to our buffer
+	c.WriteAt(buf, 0)
+	if wr.writes != 0 || c.Buffered() != 4 {
+		t.Fatalf("unexpected write before the buffer is full; %d writes, %d buffered", wr.writes, c.Buffered())
+	}
+
+	c.WriteAt([]byte("gh"), 6)
+	if wr.writes != 2 || flushes != 1 || c.Buffered() != 0 {
+		t.Fatalf("expected two coalesced writes and a flush, got %d writes, %d flushes, %d buffered", wr.writes, flushes, c.Buffered())
+	}
+
+	c.WriteAt([]byte("ef"), 4)
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(wr.buf, []byte("abcdefgh")) {
+		t.Errorf("unexpected contents %q", wr.buf)
+	}
+	if flushes != 2 {
+		t.Errorf("expected two flushes, got %d", flushes)
+	}
+}
+
+func TestBufferedBlocksPending(t *testing.T) {
+	wr := &recordingWriterAt{buf: make([]byte, 3*protocol.BlockSize)}
+	s := sharedPullerState{
+		mut: sync.NewRWMutex(),
+	}
+	s.writer = newWriteCoalescer(wr, 2*protocol.BlockSize, s.flushedLocked)
+
+	block := make([]byte, protocol.BlockSize)
+	s.writer.WriteAt(block, 0)
+	s.copyDone(protocol.BlockInfo{Offset: 0})
+	if len(s.Available()) != 0 {
+		t.Fatalf("buffered block should not be available: %v", s.Available())
+	}
+
+	s.writer.WriteAt(block, protocol.BlockSize)
+	s.pullDone(protocol.BlockInfo{Offset: protocol.BlockSize})
+	if av := s.Available(); len(av) != 2 {
+		t.Fatalf("flushed blocks should be available: %v", av)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// Snapshots are kept in this directory within the folder they were taken
+// of. It's an internal name, so the snapshots themselves aren't synced as
+// part of that folder.
+const snapshotDir = ".stsnapshots"
+
+var (
+	errSnapshotName     = errors.New("invalid snapshot name")
+	errSnapshotExists   = errors.New("snapshot already exists")
+	errSnapshotOfFolder = errors.New("folder is itself a snapshot")
+)
+
+// SnapshotFolder freezes the current index of the folder as a named
+// snapshot. The files in the index are copied aside and the snapshot is
+// added as a new send only folder, shared with the given devices. The
+// snapshot is never rescanned, so the devices sync exactly the state of the
+// folder at this point in time, regardless of later changes to it. The
+// configuration of the new folder is returned.
+func (m *Model) SnapshotFolder(folder, name string, devices []protocol.DeviceID) (config.FolderConfiguration, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return config.FolderConfiguration{}, errSnapshotName
+	}
+
+	m.fmut.RLock()
+	cfg, ok := m.folderCfgs[folder]
+	files := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return config.FolderConfiguration{}, errFolderMissing
+	}
+	if cfg.SnapshotOf != "" {
+		return config.FolderConfiguration{}, errSnapshotOfFolder
+	}
+
+	id := folder + "@" + name
+	if _, ok := m.cfg.Folder(id); ok {
+		return config.FolderConfiguration{}, errSnapshotExists
+	}
+
+	snapCfg := config.NewFolderConfiguration(id, filepath.Join(cfg.Path(), snapshotDir, name))
+	snapCfg.Label = fmt.Sprintf("%s @ %s", cfg.Label, name)
+	if cfg.Label == "" {
+		snapCfg.Label = id
+	}
+	snapCfg.Type = config.FolderTypeSendOnly
+	snapCfg.SnapshotOf = folder
+	snapCfg.IgnorePerms = cfg.IgnorePerms
+	snapCfg.Devices = []config.FolderDeviceConfiguration{{DeviceID: m.id}}
+	for _, dev := range devices {
+		if dev != m.id {
+			snapCfg.Devices = append(snapCfg.Devices, config.FolderDeviceConfiguration{DeviceID: dev})
+		}
+	}
+
+	if _, err := os.Lstat(snapCfg.Path()); err == nil {
+		return config.FolderConfiguration{}, errSnapshotExists
+	}
+
+	snapFiles, err := copySnapshot(cfg, files, snapCfg.Path())
+	if err == nil {
+		err = snapCfg.CreateMarker()
+	}
+	if err != nil {
+		os.RemoveAll(snapCfg.Path())
+		return config.FolderConfiguration{}, err
+	}
+
+	// Put the index in place before the folder is started, so that what we
+	// announce is exactly what we copied.
+	db.NewFileSet(id, m.db).Replace(protocol.LocalDeviceID, snapFiles)
+
+	if err := m.cfg.SetFolder(snapCfg); err != nil {
+		db.DropFolder(m.db, id)
+		os.RemoveAll(snapCfg.Path())
+		return config.FolderConfiguration{}, err
+	}
+	if err := m.cfg.Save(); err != nil {
+		l.Warnln("Failed to save config", err)
+	}
+
+	l.Infof("Created snapshot %s of folder %s with %d items", snapCfg.Description(), cfg.Description(), len(snapFiles))
+
+	return snapCfg, nil
+}
+
+// copySnapshot copies everything in the local index of the folder to dst,
+// and returns the index entries for what was copied. Files must be exactly
+// as the index describes them, or an error is returned and the folder
+// needs to be rescanned before the snapshot can be taken.
+func copySnapshot(cfg config.FolderConfiguration, files *db.FileSet, dst string) ([]protocol.FileInfo, error) {
+	if err := os.MkdirAll(dst, 0777); err != nil {
+		return nil, err
+	}
+
+	mtimefs := files.MtimeFS()
+
+	var snapFiles, dirs []protocol.FileInfo
+	var err error
+	files.WithHave(protocol.LocalDeviceID, func(fi db.FileIntf) bool {
+		f := fi.(protocol.FileInfo)
+		if f.IsDeleted() || f.IsInvalid() {
+			return true
+		}
+
+		from := filepath.Join(cfg.Path(), f.Name)
+		to := filepath.Join(dst, f.Name)
+
+		switch {
+		case f.IsSymlink():
+			if err = os.MkdirAll(filepath.Dir(to), 0777); err == nil {
+				err = os.Symlink(f.SymlinkTarget, to)
+			}
+
+		case f.IsDirectory():
+			err = os.MkdirAll(to, 0777)
+			dirs = append(dirs, f)
+
+		default:
+			err = copySnapshotFile(f, from, to, mtimefs, cfg.IgnorePerms)
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %v", f.Name, err)
+			return false
+		}
+
+		f.Sequence = 0
+		snapFiles = append(snapFiles, f)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Directory permissions are set last, as they may well prevent us from
+	// creating their contents.
+	if !cfg.IgnorePerms {
+		for i := len(dirs) - 1; i >= 0; i-- {
+			if err := os.Chmod(filepath.Join(dst, dirs[i].Name), os.FileMode(dirs[i].Permissions&0777)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return snapFiles, nil
+}
+
+func copySnapshotFile(f protocol.FileInfo, from, to string, mtimefs *fs.MtimeFS, ignorePerms bool) error {
+	unchanged := func() error {
+		info, err := mtimefs.Lstat(from)
+		if err != nil {
+			return err
+		}
+		if info.Size() != f.Size || !info.ModTime().Equal(f.ModTime()) {
+			return errors.New("file differs from index; rescan the folder and try again")
+		}
+		return nil
+	}
+
+	if err := unchanged(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return err
+	}
+	if err := osutil.Copy(from, to); err != nil {
+		return err
+	}
+	if err := unchanged(); err != nil {
+		return err
+	}
+
+	if !ignorePerms {
+		if err := os.Chmod(to, os.FileMode(f.Permissions&0777)); err != nil {
+			return err
+		}
+	}
+	return os.Chtimes(to, f.ModTime(), f.ModTime())
+}
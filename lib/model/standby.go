@@ -0,0 +1,143 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// Warm standby
+//
+// A standby mirrors a primary device: it adds the folders the primary
+// shares with it, shared with the primary only, and takes their settings
+// from it. The other devices of the folders are added to the config, and
+// which folders the primary shares with them, and whether it has them
+// paused, is kept with the folders. Until promoted the standby announces
+// the folders to the primary alone; once promoted it shares them with the
+// other devices as the primary did, taking over from it.
+
+var errNotStandby = errors.New("this device is not a standby")
+
+// mirrorPrimaryLocked takes over the folders and devices from the cluster
+// config of the primary. It returns whether the config changed.
+func (m *Model) mirrorPrimaryLocked(primaryCfg config.DeviceConfiguration, cm protocol.ClusterConfig) bool {
+	primary := primaryCfg.DeviceID
+	changed := false
+
+	for _, folder := range cm.Folders {
+		if !primaryCfg.FolderAllowed(folder.ID) {
+			continue
+		}
+
+		var devices []protocol.DeviceID
+		for _, device := range folder.Devices {
+			if device.ID == m.id || device.ID == primary {
+				continue
+			}
+			devices = append(devices, device.ID)
+			if _, ok := m.cfg.Devices()[device.ID]; !ok {
+				m.introduceDevice(device, primaryCfg)
+				changed = true
+			}
+		}
+
+		folderCfg, ok := m.cfg.Folders()[folder.ID]
+		if !ok {
+			l.Infof("Mirroring folder %s from primary device %v", folder.Description(), primary)
+			folderCfg = config.NewFolderConfiguration(folder.ID, filepath.Join(m.standbyFolderPath(), folder.ID))
+			folderCfg.Label = folder.Label
+			folderCfg.IgnorePerms = folder.IgnorePermissions
+			folderCfg.IgnoreDelete = folder.IgnoreDelete
+			folderCfg.Devices = []config.FolderDeviceConfiguration{{DeviceID: m.id}, {DeviceID: primary}}
+			folderCfg.SettingsFrom = primary
+		} else if !m.folderSharedWithLocked(folder.ID, primary) {
+			// A folder of our own by the same ID, not the primary's.
+			continue
+		} else if equalDeviceIDs(folderCfg.StandbyDevices, devices) && folderCfg.StandbyPaused == folder.Paused {
+			continue
+		}
+
+		folderCfg.StandbyDevices = devices
+		folderCfg.StandbyPaused = folder.Paused
+		m.cfg.SetFolder(folderCfg)
+		changed = true
+	}
+
+	return changed
+}
+
+func equalDeviceIDs(a, b []protocol.DeviceID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Model) standbyFolderPath() string {
+	if path := m.cfg.Options().StandbyFolderPath; path != "" {
+		return path
+	}
+	return "~"
+}
+
+// PromoteStandby makes this standby take over from its primary: the
+// folders mirrored from the primary are shared with the devices the
+// primary shares them with, and this device no longer takes their settings
+// from the primary but shares its own. With takePauseState, the folders
+// the primary has paused are paused here too.
+func (m *Model) PromoteStandby(takePauseState bool) error {
+	primary := m.cfg.Options().StandbyFor
+	if primary == protocol.EmptyDeviceID {
+		return errNotStandby
+	}
+
+	cfg := m.cfg.RawCopy()
+	for i, folderCfg := range cfg.Folders {
+		shared := make(map[protocol.DeviceID]bool)
+		for _, device := range folderCfg.DeviceIDs() {
+			shared[device] = true
+		}
+		if !shared[primary] {
+			continue
+		}
+
+		for _, device := range folderCfg.StandbyDevices {
+			if _, ok := m.cfg.Devices()[device]; !ok || shared[device] {
+				// Removed since it was mirrored, or shared already.
+				continue
+			}
+			folderCfg.Devices = append(folderCfg.Devices, config.FolderDeviceConfiguration{DeviceID: device})
+			shared[device] = true
+		}
+		if takePauseState {
+			folderCfg.Paused = folderCfg.StandbyPaused
+		}
+		if folderCfg.SettingsFrom == primary {
+			folderCfg.SettingsFrom = protocol.EmptyDeviceID
+			folderCfg.ShareSettings = true
+		}
+		folderCfg.StandbyDevices = nil
+		folderCfg.StandbyPaused = false
+		cfg.Folders[i] = folderCfg
+	}
+	cfg.Options.StandbyFor = protocol.EmptyDeviceID
+
+	l.Infof("Promoted from standby; taking over from device %v", primary)
+	if err := m.cfg.Replace(cfg); err != nil {
+		return err
+	}
+	return m.cfg.Save()
+}
@@ -0,0 +1,212 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/connections"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// Monthly transfer caps
+//
+// What's sent to and received from each device is counted from the
+// connection statistics, every so often and when the connection closes,
+// and kept with the device statistics so that it survives restarts. A
+// device with a monthly cap is disconnected once the count reaches it, and
+// isn't connected to again until its month starts over or the cap is
+// raised. The count includes protocol overhead, like the bandwidth limits
+// do, and can overshoot the cap by what's transferred between two counts.
+
+// How often to count what's been transferred.
+const transferCountInterval = 10 * time.Second
+
+var errDeviceCapped = errors.New("device is over its monthly transfer cap")
+
+// transferCaps counts what's transferred on each connection and keeps
+// track of the devices that are over their cap.
+type transferCaps struct {
+	model   *Model
+	counted map[protocol.DeviceID]protocol.Statistics // device -> connection totals counted so far
+	capped  map[protocol.DeviceID]time.Time           // device -> end of the month it's over its cap in
+	stop    chan struct{}
+	mut     sync.Mutex
+}
+
+func newTransferCaps(m *Model) *transferCaps {
+	return &transferCaps{
+		model:   m,
+		counted: make(map[protocol.DeviceID]protocol.Statistics),
+		capped:  make(map[protocol.DeviceID]time.Time),
+		stop:    make(chan struct{}),
+		mut:     sync.NewMutex(),
+	}
+}
+
+func (c *transferCaps) Serve() {
+	ticker := time.NewTicker(transferCountInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			c.model.countTransfers(now)
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *transferCaps) Stop() {
+	close(c.stop)
+}
+
+func (c *transferCaps) String() string {
+	return "transferCaps"
+}
+
+// countTransfers counts what's been transferred on all connections since
+// the last count, disconnecting the devices that reach their cap, and lets
+// those whose month has started over connect again.
+func (m *Model) countTransfers(now time.Time) {
+	m.pmut.RLock()
+	conns := make(map[protocol.DeviceID]connections.Connection, len(m.conn))
+	for device, conn := range m.conn {
+		conns[device] = conn
+	}
+	m.pmut.RUnlock()
+
+	for device, conn := range conns {
+		m.countTransferred(device, conn.Statistics(), now)
+	}
+
+	m.transferCaps.mut.Lock()
+	capped := make([]protocol.DeviceID, 0, len(m.transferCaps.capped))
+	for device := range m.transferCaps.capped {
+		capped = append(capped, device)
+	}
+	m.transferCaps.mut.Unlock()
+
+	for _, device := range capped {
+		cfg, ok := m.cfg.Device(device)
+		if !ok || !m.overTransferCap(cfg, now) {
+			m.uncapDevice(device, ok)
+		}
+	}
+}
+
+// countTransferred adds what's been transferred on the device's connection
+// since the last count to the device's total for the month, disconnecting
+// it if that reaches its cap.
+func (m *Model) countTransferred(device protocol.DeviceID, stats protocol.Statistics, now time.Time) {
+	cfg, ok := m.cfg.Device(device)
+	if !ok {
+		return
+	}
+
+	m.transferCaps.mut.Lock()
+	prev := m.transferCaps.counted[device]
+	m.transferCaps.counted[device] = stats
+	in := stats.InBytesTotal - prev.InBytesTotal
+	out := stats.OutBytesTotal - prev.OutBytesTotal
+	if in < 0 || out < 0 {
+		// Counted from another connection that we missed the close of
+		in, out = stats.InBytesTotal, stats.OutBytesTotal
+	}
+	var totalIn, totalOut int64
+	if in > 0 || out > 0 {
+		start, _ := cfg.CapPeriod(now)
+		totalIn, totalOut = m.deviceStatRef(device).AddTransferred(start, in, out)
+	}
+	m.transferCaps.mut.Unlock()
+
+	if limit := cfg.CapBytes(); limit > 0 && totalIn+totalOut >= limit {
+		m.capDevice(cfg, now)
+		m.close(device)
+	}
+}
+
+// countClosedTransfers counts what's been transferred on the device's
+// connection, which has been closed, since the last count.
+func (m *Model) countClosedTransfers(device protocol.DeviceID, stats protocol.Statistics) {
+	m.countTransferred(device, stats, time.Now())
+
+	m.transferCaps.mut.Lock()
+	delete(m.transferCaps.counted, device)
+	m.transferCaps.mut.Unlock()
+}
+
+// overTransferCap returns true if the device has a cap and has transferred
+// at least that much in the month the time falls in.
+func (m *Model) overTransferCap(cfg config.DeviceConfiguration, now time.Time) bool {
+	limit := cfg.CapBytes()
+	if limit == 0 {
+		return false
+	}
+	start, _ := cfg.CapPeriod(now)
+	in, out := m.deviceStatRef(cfg.DeviceID).GetTransferred(start)
+	return in+out >= limit
+}
+
+// capDevice marks the device as over its cap until the end of the month,
+// emitting an event if it wasn't already.
+func (m *Model) capDevice(cfg config.DeviceConfiguration, now time.Time) {
+	_, end := cfg.CapPeriod(now)
+
+	m.transferCaps.mut.Lock()
+	_, ok := m.transferCaps.capped[cfg.DeviceID]
+	m.transferCaps.capped[cfg.DeviceID] = end
+	m.transferCaps.mut.Unlock()
+
+	if ok {
+		return
+	}
+	l.Infof("Device %v has reached its monthly transfer cap of %d MiB; pausing until %s", cfg.DeviceID, cfg.MonthlyCapMiB, end.Format("2006-01-02"))
+	events.Default.Log(events.DeviceAutoPaused, map[string]interface{}{
+		"device":   cfg.DeviceID.String(),
+		"pausedBy": config.PauseOwnerPolicy,
+		"capMiB":   cfg.MonthlyCapMiB,
+		"until":    end,
+	})
+}
+
+// uncapDevice lets the device connect again, emitting an event if it's
+// still among our devices.
+func (m *Model) uncapDevice(device protocol.DeviceID, known bool) {
+	m.transferCaps.mut.Lock()
+	delete(m.transferCaps.capped, device)
+	m.transferCaps.mut.Unlock()
+
+	if !known {
+		return
+	}
+	l.Infof("Device %v is no longer over its monthly transfer cap; resuming", device)
+	events.Default.Log(events.DeviceAutoResumed, map[string]interface{}{
+		"device":   device.String(),
+		"pausedBy": config.PauseOwnerPolicy,
+	})
+}
+
+// TransferCapped returns true if the device is over its monthly transfer
+// cap and should not be connected to.
+func (m *Model) TransferCapped(device protocol.DeviceID) bool {
+	cfg, ok := m.cfg.Device(device)
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	if !m.overTransferCap(cfg, now) {
+		return false
+	}
+	m.capDevice(cfg, now)
+	return true
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestTransferCaps(t *testing.T) {
+	cfg := config.Wrap("/tmp/test", config.Configuration{
+		Devices: []config.DeviceConfiguration{
+			{DeviceID: device1, MonthlyCapMiB: 1},
+		},
+	})
+	m := NewModel(cfg, protocol.LocalDeviceID, "device", "syncthing", "dev", db.OpenMemory(), nil)
+
+	sub := events.Default.Subscribe(events.DeviceAutoPaused | events.DeviceAutoResumed)
+	defer events.Default.Unsubscribe(sub)
+
+	conn := &fakeConnection{id: device1}
+	m.AddConnection(conn, protocol.HelloResult{})
+	now := time.Now()
+
+	// Below the cap, what's transferred is counted but nothing else happens
+
+	conn.mut.Lock()
+	conn.stats = protocol.Statistics{InBytesTotal: 512 << 10, OutBytesTotal: 256 << 10}
+	conn.mut.Unlock()
+	m.countTransfers(now)
+
+	stats := m.DeviceStatistics()[device1.String()]
+	if stats.TransferredIn != 512<<10 || stats.TransferredOut != 256<<10 || stats.TransferCapped {
+		t.Errorf("Unexpected statistics below the cap: %+v", stats)
+	}
+	if conn.closed {
+		t.Error("Connection closed below the cap")
+	}
+
+	// Reaching it disconnects the device and keeps it from connecting
+
+	conn.mut.Lock()
+	conn.stats = protocol.Statistics{InBytesTotal: 768 << 10, OutBytesTotal: 512 << 10}
+	conn.mut.Unlock()
+	m.countTransfers(now)
+
+	if !conn.closed {
+		t.Error("Connection not closed at the cap")
+	}
+	if ev, err := sub.Poll(time.Second); err != nil || ev.Type != events.DeviceAutoPaused {
+		t.Errorf("Expected a DeviceAutoPaused event, got %v (%v)", ev.Type, err)
+	}
+	stats = m.DeviceStatistics()[device1.String()]
+	if stats.TransferredIn != 768<<10 || stats.TransferredOut != 512<<10 || !stats.TransferCapped {
+		t.Errorf("Unexpected statistics at the cap: %+v", stats)
+	}
+	if err := m.OnHello(device1, &fakeAddr{}, protocol.HelloResult{}); err != errDeviceCapped {
+		t.Errorf("Expected the device to be rejected as over its cap, got %v", err)
+	}
+
+	// What's transferred after the last count is counted when the
+	// connection closes
+
+	conn.mut.Lock()
+	conn.stats = protocol.Statistics{InBytesTotal: 1 << 20, OutBytesTotal: 512 << 10}
+	conn.mut.Unlock()
+	m.Closed(conn, errors.New("test"))
+	if in, _ := m.deviceStatRef(device1).GetTransferred(time.Time{}); in != 0 {
+		t.Errorf("Transfers counted in another period: %d", in)
+	}
+	start, end := cfg.Devices()[device1].CapPeriod(now)
+	if in, _ := m.deviceStatRef(device1).GetTransferred(start); in != 1<<20 {
+		t.Errorf("Transfers at close not counted: %d bytes in, expected %d", in, 1<<20)
+	}
+
+	// The device may connect again once the month has started over
+
+	m.countTransfers(end)
+	if ev, err := sub.Poll(time.Second); err != nil || ev.Type != events.DeviceAutoResumed {
+		t.Errorf("Expected a DeviceAutoResumed event, got %v (%v)", ev.Type, err)
+	}
+	if m.overTransferCap(cfg.Devices()[device1], end) {
+		t.Error("Device still over its cap in the next month")
+	}
+}
@@ -0,0 +1,388 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+	"github.com/syncthing/syncthing/lib/versioner"
+)
+
+const (
+	maxDiffSize   = 1 << 20 // files larger than this aren't diffed
+	maxDiffCells  = 1 << 22 // of the table comparing the lines that differ
+	diffContext   = 3       // lines around the changes
+	diffNoNewline = "\\ No newline at end of file\n"
+)
+
+var errVersionsNotKept = errors.New("the versioning of the folder does not keep restorable versions")
+
+// A VersionDiff compares an archived version of a file with the file as
+// it is now.
+type VersionDiff struct {
+	File       string                `json:"file"`
+	Version    versioner.FileVersion `json:"version"`
+	Deleted    bool                  `json:"deleted"`    // the file doesn't exist now
+	Similarity float64               `json:"similarity"` // percentage of the blocks of the version in the file
+	Binary     bool                  `json:"binary"`     // not both text, or too large to diff
+	Diff       string                `json:"diff"`       // unified diff from the version to the file
+}
+
+// folderRestorer returns the folder and its versioner, if that keeps
+// versions that can be restored.
+func (m *Model) folderRestorer(folder string) (config.FolderConfiguration, versioner.Restorer, error) {
+	m.fmut.RLock()
+	cfg, ok := m.folderCfgs[folder]
+	ver := m.folderVersioners[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return cfg, nil, errFolderMissing
+	}
+	restorer, ok := ver.(versioner.Restorer)
+	if !ok {
+		return cfg, nil, errVersionsNotKept
+	}
+	return cfg, restorer, nil
+}
+
+// FolderVersions returns the archived versions of the files in the
+// directory of the folder, or all of them for "", newest first.
+func (m *Model) FolderVersions(folder, dir string) (map[string][]versioner.FileVersion, error) {
+	_, restorer, err := m.folderRestorer(folder)
+	if err != nil {
+		return nil, err
+	}
+	return restorer.Versions(dir)
+}
+
+// FolderVersionDiff compares the version of the file with the file, to see
+// what restoring it would change. The similarity is by blocks, as used to
+// sync the file, so it tells how much of the version could be reused. Text
+// files are compared line by line too.
+func (m *Model) FolderVersionDiff(folder, file string, versionTime time.Time) (VersionDiff, error) {
+	cfg, restorer, err := m.folderRestorer(folder)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+	path, err := rootedJoinedPath(cfg.Path(), file)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+	versionPath, err := restorer.VersionPath(file, versionTime)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+	info, err := os.Stat(versionPath)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+
+	diff := VersionDiff{
+		File: file,
+		Version: versioner.FileVersion{
+			VersionTime: versionTime,
+			ModTime:     info.ModTime(),
+			Size:        info.Size(),
+		},
+		Binary: true,
+	}
+
+	cur, err := osutil.Lstat(path)
+	if os.IsNotExist(err) {
+		diff.Deleted = true
+		return diff, nil
+	} else if err != nil {
+		return VersionDiff{}, err
+	}
+
+	versionBlocks, err := scanner.HashFile(versionPath, protocol.BlockSize, nil, false)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+	curBlocks, err := scanner.HashFile(path, protocol.BlockSize, nil, false)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+	diff.Similarity = blockSimilarity(versionBlocks, curBlocks)
+
+	if info.Size() > maxDiffSize || cur.Size() > maxDiffSize {
+		return diff, nil
+	}
+	versionData, err := ioutil.ReadFile(versionPath)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+	curData, err := ioutil.ReadFile(path)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+	if isText(versionData) && isText(curData) {
+		diff.Binary = false
+		diff.Diff = unifiedDiff(splitLines(versionData), splitLines(curData), file+" "+versionTime.Format(time.RFC3339), file)
+	}
+	return diff, nil
+}
+
+// FolderVersionsAt returns the versions to restore to bring the files in
+// the directory of the folder back to how they were at the time. That's the
+// latest version of each file modified by then, unless the file as it is
+// now is as new. Files deleted before the time but after their last
+// version was modified can't be told apart from those deleted later, and
+// are restored too.
+func (m *Model) FolderVersionsAt(folder, dir string, at time.Time) (map[string]time.Time, error) {
+	cfg, restorer, err := m.folderRestorer(folder)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := restorer.Versions(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]time.Time)
+	for name, vs := range versions {
+		var best *versioner.FileVersion
+		for i := range vs {
+			if vs[i].ModTime.After(at) {
+				continue
+			}
+			if best == nil || vs[i].ModTime.After(best.ModTime) {
+				best = &vs[i]
+			}
+		}
+		if best == nil {
+			continue
+		}
+		if cur, err := osutil.Lstat(filepath.Join(cfg.Path(), name)); err == nil {
+			if !cur.ModTime().After(at) && !cur.ModTime().Before(best.ModTime) {
+				continue
+			}
+		}
+		res[name] = best.VersionTime
+	}
+	return res, nil
+}
+
+// RestoreFolderVersions restores the given version of each file, archiving
+// the file as it is, and rescans them. It returns the errors restoring
+// files by name.
+func (m *Model) RestoreFolderVersions(folder string, versions map[string]time.Time) (map[string]string, error) {
+	cfg, restorer, err := m.folderRestorer(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := make(map[string]string)
+	var restored []string
+	for name, versionTime := range versions {
+		if _, err := rootedJoinedPath(cfg.Path(), name); err != nil {
+			errs[name] = err.Error()
+			continue
+		}
+		if ignore.IsInternal(name) {
+			errs[name] = errInvalidFilename.Error()
+			continue
+		}
+		if err := versioner.Restore(restorer, cfg.Path(), name, versionTime); err != nil {
+			errs[name] = err.Error()
+			continue
+		}
+		restored = append(restored, name)
+	}
+
+	if len(restored) > 0 {
+		l.Infof("Restored %d archived versions in folder %s", len(restored), cfg.Description())
+		if err := m.ScanFolderSubdirs(folder, restored); err != nil {
+			return errs, err
+		}
+	}
+	return errs, nil
+}
+
+// blockSimilarity returns the percentage of the blocks of the version that
+// are in the file, wherever they are.
+func blockSimilarity(version, file []protocol.BlockInfo) float64 {
+	if len(version) == 0 {
+		return 100
+	}
+	have := make(map[string]struct{}, len(file))
+	for _, b := range file {
+		have[string(b.Hash)] = struct{}{}
+	}
+	same := 0
+	for _, b := range version {
+		if _, ok := have[string(b.Hash)]; ok {
+			same++
+		}
+	}
+	return 100 * float64(same) / float64(len(version))
+}
+
+func isText(bs []byte) bool {
+	return utf8.Valid(bs) && bytes.IndexByte(bs, 0) < 0
+}
+
+// splitLines splits the text after each newline; the last line may be
+// without one.
+func splitLines(bs []byte) []string {
+	lines := strings.SplitAfter(string(bs), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// A diffLine is a line that's the same (' '), removed ('-') or added ('+').
+type diffLine struct {
+	op   byte
+	line string
+}
+
+// diffLines returns the lines of a changed into b. The lines that differ
+// are compared by their longest common subsequence, unless there are so
+// many that they're all replaced instead.
+func diffLines(a, b []string) []diffLine {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	var res []diffLine
+	for _, line := range a[:pre] {
+		res = append(res, diffLine{' ', line})
+	}
+
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	if len(ma)*len(mb) > maxDiffCells {
+		for _, line := range ma {
+			res = append(res, diffLine{'-', line})
+		}
+		for _, line := range mb {
+			res = append(res, diffLine{'+', line})
+		}
+	} else {
+		// lcs[i*w+j] is the length of the longest common subsequence of
+		// ma[i:] and mb[j:].
+		w := len(mb) + 1
+		lcs := make([]int32, (len(ma)+1)*w)
+		for i := len(ma) - 1; i >= 0; i-- {
+			for j := len(mb) - 1; j >= 0; j-- {
+				if ma[i] == mb[j] {
+					lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+				} else if lcs[(i+1)*w+j] >= lcs[i*w+j+1] {
+					lcs[i*w+j] = lcs[(i+1)*w+j]
+				} else {
+					lcs[i*w+j] = lcs[i*w+j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(ma) || j < len(mb) {
+			switch {
+			case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+				res = append(res, diffLine{' ', ma[i]})
+				i++
+				j++
+			case j == len(mb) || i < len(ma) && lcs[(i+1)*w+j] >= lcs[i*w+j+1]:
+				res = append(res, diffLine{'-', ma[i]})
+				i++
+			default:
+				res = append(res, diffLine{'+', mb[j]})
+				j++
+			}
+		}
+	}
+
+	for _, line := range a[len(a)-suf:] {
+		res = append(res, diffLine{' ', line})
+	}
+	return res
+}
+
+// unifiedDiff returns the changes from a to b in the unified format, or ""
+// when there are none.
+func unifiedDiff(a, b []string, nameA, nameB string) string {
+	lines := diffLines(a, b)
+
+	// The line numbers in a and b before each line of the diff
+	aPos := make([]int, len(lines)+1)
+	bPos := make([]int, len(lines)+1)
+	for i, line := range lines {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if line.op != '+' {
+			aPos[i+1]++
+		}
+		if line.op != '-' {
+			bPos[i+1]++
+		}
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+
+		// A hunk runs from the context before this change to the context
+		// after the last change close enough to join it.
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		last := i
+		for j := i + 1; j < len(lines) && j <= last+2*diffContext; j++ {
+			if lines[j].op != ' ' {
+				last = j
+			}
+		}
+		end := last + 1 + diffContext
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		if buf.Len() == 0 {
+			fmt.Fprintf(&buf, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", hunkStart(aPos[start], aPos[end]), aPos[end]-aPos[start], hunkStart(bPos[start], bPos[end]), bPos[end]-bPos[start])
+		for _, line := range lines[start:end] {
+			buf.WriteByte(line.op)
+			buf.WriteString(line.line)
+			if !strings.HasSuffix(line.line, "\n") {
+				buf.WriteString("\n" + diffNoNewline)
+			}
+		}
+		i = end
+	}
+	return buf.String()
+}
+
+// hunkStart returns the number of the first line of a hunk, which for an
+// empty one is the line before it.
+func hunkStart(start, end int) int {
+	if start == end {
+		return start
+	}
+	return start + 1
+}
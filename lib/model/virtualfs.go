@@ -0,0 +1,313 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+)
+
+// Virtual folders
+//
+// A virtual folder keeps none of its files locally. It runs like a send
+// only folder with nothing in it, so it never pulls, and the global state
+// of the folder is presented as a read only filesystem instead. The data of
+// a file is requested from the devices that have it as it's read, and the
+// most recently read blocks are kept in memory.
+
+var errNoBlockSource = errors.New("no connected device has the block")
+
+// VirtualFilesystem returns the read only filesystem presenting the global
+// state of the folder, with names relative to the folder root.
+func (m *Model) VirtualFilesystem(folder string) fs.Filesystem {
+	return &virtualFilesystem{
+		model:  m,
+		folder: folder,
+	}
+}
+
+type virtualFilesystem struct {
+	model  *Model
+	folder string
+}
+
+func (f *virtualFilesystem) Lstat(name string) (fs.FileInfo, error) {
+	name = filepath.Clean(name)
+	if name == "." {
+		return virtualDirInfo{}, nil
+	}
+
+	file, ok := f.model.CurrentGlobalFile(f.folder, name)
+	if !ok || file.IsDeleted() || file.IsInvalid() {
+		return nil, &os.PathError{Op: "lstat", Path: name, Err: os.ErrNotExist}
+	}
+	return virtualFileInfo{file}, nil
+}
+
+func (f *virtualFilesystem) Stat(name string) (fs.FileInfo, error) {
+	return f.Lstat(name)
+}
+
+func (f *virtualFilesystem) DirNames(name string) ([]string, error) {
+	info, err := f.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	prefix := filepath.Clean(name)
+	if prefix == "." {
+		prefix = ""
+	}
+	tree := f.model.GlobalDirectoryTree(f.folder, prefix, 0, false)
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (f *virtualFilesystem) Open(name string) (fs.File, error) {
+	file, ok := f.model.CurrentGlobalFile(f.folder, filepath.Clean(name))
+	if !ok || file.IsDeleted() || file.IsInvalid() || file.IsDirectory() || file.IsSymlink() {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return &virtualFile{model: f.model, folder: f.folder, file: file}, nil
+}
+
+func (f *virtualFilesystem) ReadSymlink(name string) (string, error) {
+	file, ok := f.model.CurrentGlobalFile(f.folder, filepath.Clean(name))
+	if !ok || file.IsDeleted() || file.IsInvalid() || !file.IsSymlink() {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: os.ErrNotExist}
+	}
+	return file.SymlinkTarget, nil
+}
+
+func (f *virtualFilesystem) Walk(root string, walkFn fs.WalkFunc) error {
+	info, err := f.Lstat(root)
+	if err != nil {
+		return walkFn(root, nil, err)
+	}
+	return f.walk(root, info, walkFn)
+}
+
+func (f *virtualFilesystem) walk(name string, info fs.FileInfo, walkFn fs.WalkFunc) error {
+	err := walkFn(name, info, nil)
+	if err != nil {
+		if info.IsDir() && err == fs.SkipDir {
+			return nil
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+
+	names, err := f.DirNames(name)
+	if err != nil {
+		return walkFn(name, info, err)
+	}
+	for _, child := range names {
+		child = filepath.Join(name, child)
+		info, err := f.Lstat(child)
+		if err != nil {
+			if err := walkFn(child, nil, err); err != nil && err != fs.SkipDir {
+				return err
+			}
+			continue
+		}
+		if err := f.walk(child, info, walkFn); err != nil {
+			if !info.IsDir() && err == fs.SkipDir {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *virtualFilesystem) SymlinksSupported() bool {
+	return false
+}
+
+func (f *virtualFilesystem) Chmod(name string, mode fs.FileMode) error {
+	return virtualReadOnly("chmod", name)
+}
+
+func (f *virtualFilesystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return virtualReadOnly("chtimes", name)
+}
+
+func (f *virtualFilesystem) Create(name string) (fs.File, error) {
+	return nil, virtualReadOnly("create", name)
+}
+
+func (f *virtualFilesystem) CreateSymlink(name, target string) error {
+	return virtualReadOnly("symlink", name)
+}
+
+func (f *virtualFilesystem) Mkdir(name string, perm fs.FileMode) error {
+	return virtualReadOnly("mkdir", name)
+}
+
+func (f *virtualFilesystem) Remove(name string) error {
+	return virtualReadOnly("remove", name)
+}
+
+func (f *virtualFilesystem) Rename(oldname, newname string) error {
+	return virtualReadOnly("rename", oldname)
+}
+
+func virtualReadOnly(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+}
+
+// A virtualFile reads the data of a file in the global state from the
+// devices that have it.
+type virtualFile struct {
+	model  *Model
+	folder string
+	file   protocol.FileInfo
+	offset int64
+}
+
+func (f *virtualFile) Read(p []byte) (int, error) {
+	if f.offset >= f.file.Size {
+		return 0, io.EOF
+	}
+	n, err := f.model.readGlobal(f.folder, f.file, f.offset, p)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *virtualFile) WriteAt(p []byte, off int64) (int, error) {
+	return 0, virtualReadOnly("write", f.file.Name)
+}
+
+func (f *virtualFile) Truncate(size int64) error {
+	return virtualReadOnly("truncate", f.file.Name)
+}
+
+func (f *virtualFile) Close() error {
+	return nil
+}
+
+// readGlobal reads the data of the global version of the file at the
+// offset into p, as far as the block the offset is in goes.
+func (m *Model) readGlobal(folder string, file protocol.FileInfo, offset int64, p []byte) (int, error) {
+	for _, block := range file.Blocks {
+		if offset < block.Offset || offset >= block.Offset+int64(block.Size) {
+			continue
+		}
+		data, err := m.globalBlock(folder, file, block)
+		if err != nil {
+			return 0, err
+		}
+		return copy(p, data[offset-block.Offset:]), nil
+	}
+	return 0, io.ErrUnexpectedEOF
+}
+
+// globalBlock returns the data of the block, from the cache or from the
+// least busy device that has it.
+func (m *Model) globalBlock(folder string, file protocol.FileInfo, block protocol.BlockInfo) ([]byte, error) {
+	if data, ok := m.blockCache.get(block.Hash); ok {
+		return data, nil
+	}
+
+	availability := m.Availability(folder, file, block)
+	for {
+		selected, found := m.virtualActivity.leastBusy(availability)
+		if !found {
+			return nil, errNoBlockSource
+		}
+
+		m.virtualActivity.using(selected)
+		data, err := m.requestGlobal(selected.ID, folder, file.Name, block.Offset, int(block.Size), block.Hash, selected.FromTemporary, false)
+		m.virtualActivity.done(selected)
+		if err == nil {
+			_, err = scanner.VerifyBuffer(data, block)
+		}
+		if err != nil {
+			l.Debugf("%v virtual read of %q / %q from %v: %v", m, folder, file.Name, selected.ID, err)
+			availability = removeAvailability(availability, selected)
+			continue
+		}
+
+		m.blockCache.add(block.Hash, data, int64(m.cfg.Options().VirtualCacheMiB)<<20)
+		return data, nil
+	}
+}
+
+// virtualDirInfo is the file info of the root of a virtual folder.
+type virtualDirInfo struct{}
+
+func (virtualDirInfo) Name() string       { return "." }
+func (virtualDirInfo) Mode() fs.FileMode  { return fs.FileMode(os.ModeDir | 0755) }
+func (virtualDirInfo) Size() int64        { return 0 }
+func (virtualDirInfo) ModTime() time.Time { return time.Time{} }
+func (virtualDirInfo) IsDir() bool        { return true }
+func (virtualDirInfo) IsRegular() bool    { return false }
+func (virtualDirInfo) IsSymlink() bool    { return false }
+
+// virtualFileInfo is the file info of a file in the global state.
+type virtualFileInfo struct {
+	file protocol.FileInfo
+}
+
+func (i virtualFileInfo) Name() string {
+	return filepath.Base(i.file.Name)
+}
+
+func (i virtualFileInfo) Mode() fs.FileMode {
+	perm := os.FileMode(i.file.Permissions) & os.ModePerm
+	if !i.file.HasPermissionBits() {
+		perm = 0644
+	}
+	switch {
+	case i.file.IsSymlink():
+		return fs.FileMode(os.ModeSymlink | 0777)
+	case i.file.IsDirectory():
+		return fs.FileMode(os.ModeDir | perm | 0111)
+	default:
+		return fs.FileMode(perm)
+	}
+}
+
+func (i virtualFileInfo) Size() int64 {
+	if i.file.IsDirectory() || i.file.IsSymlink() {
+		return 0
+	}
+	return i.file.Size
+}
+
+func (i virtualFileInfo) ModTime() time.Time {
+	return i.file.ModTime()
+}
+
+func (i virtualFileInfo) IsDir() bool {
+	return i.file.IsDirectory() && !i.file.IsSymlink()
+}
+
+func (i virtualFileInfo) IsRegular() bool {
+	return !i.file.IsDirectory() && !i.file.IsSymlink()
+}
+
+func (i virtualFileInfo) IsSymlink() bool {
+	return i.file.IsSymlink()
+}
@@ -0,0 +1,114 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"sort"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// A folderWriteOnce keeps the files of a write once folder as they are, by
+// having the puller refuse the remote changes that would modify or delete
+// a file we have. New files, and files we have deleted, are pulled as
+// usual. Directories may have their metadata changed, but not be deleted.
+type folderWriteOnce struct {
+	enabled bool
+
+	mut     sync.Mutex
+	refused map[string]bool // files whose changes were refused during this pull
+	last    map[string]bool // as above, during the last pull
+}
+
+func newFolderWriteOnce(cfg config.FolderConfiguration) *folderWriteOnce {
+	return &folderWriteOnce{
+		enabled: cfg.WriteOnce,
+		mut:     sync.NewMutex(),
+	}
+}
+
+// start is called when a pull starts.
+func (w *folderWriteOnce) start() {
+	if w == nil || !w.enabled {
+		return
+	}
+	w.mut.Lock()
+	w.refused = make(map[string]bool)
+	w.mut.Unlock()
+}
+
+// active returns whether changes are checked at all.
+func (w *folderWriteOnce) active() bool {
+	return w != nil && w.enabled
+}
+
+// allow returns whether the change to file may be pulled, given what we
+// have of it, if anything. Refused changes are remembered until the pull
+// is done.
+func (w *folderWriteOnce) allow(cur protocol.FileInfo, have bool, file protocol.FileInfo) bool {
+	if w == nil || !w.enabled || !have || cur.IsDeleted() || cur.IsInvalid() {
+		return true
+	}
+	if cur.IsDirectory() && file.IsDirectory() && !file.IsDeleted() {
+		return true
+	}
+	w.mut.Lock()
+	w.refused[file.Name] = true
+	w.mut.Unlock()
+	return false
+}
+
+// finish is called when a pull is done. It returns the files whose changes
+// were refused that weren't also refused during the previous pull, sorted.
+func (w *folderWriteOnce) finish() (newlyRefused []string) {
+	if w == nil || !w.enabled {
+		return nil
+	}
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	for name := range w.refused {
+		if !w.last[name] {
+			newlyRefused = append(newlyRefused, name)
+		}
+	}
+	w.last, w.refused = w.refused, nil
+	sort.Strings(newlyRefused)
+	return newlyRefused
+}
+
+// Violations returns the number of files whose changes were refused during
+// the last pull.
+func (w *folderWriteOnce) Violations() int {
+	if w == nil {
+		return 0
+	}
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return len(w.last)
+}
+
+// unscannedFile returns what is on disk at the name, as far as its type
+// goes, for files we don't know of or know as deleted. A file created since
+// the last scan is kept as much as one we have scanned.
+func (f *sendReceiveFolder) unscannedFile(name string) (protocol.FileInfo, bool) {
+	realName, err := rootedJoinedPath(f.dir, name)
+	if err != nil {
+		return protocol.FileInfo{}, false
+	}
+	info, err := osutil.Lstat(realName)
+	if err != nil {
+		return protocol.FileInfo{}, false
+	}
+	cur := protocol.FileInfo{Name: name, Type: protocol.FileInfoTypeFile}
+	if info.IsDir() {
+		cur.Type = protocol.FileInfoTypeDirectory
+	}
+	return cur, true
+}
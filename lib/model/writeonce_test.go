@@ -0,0 +1,57 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestFolderWriteOnce(t *testing.T) {
+	w := newFolderWriteOnce(config.FolderConfiguration{WriteOnce: true})
+	file := protocol.FileInfo{Name: "file"}
+	dir := protocol.FileInfo{Name: "dir", Type: protocol.FileInfoTypeDirectory}
+	deleted := func(f protocol.FileInfo) protocol.FileInfo {
+		f.Deleted = true
+		return f
+	}
+
+	w.start()
+	if !w.allow(protocol.FileInfo{}, false, file) || !w.allow(deleted(file), true, file) {
+		t.Error("New files should be allowed")
+	}
+	if !w.allow(dir, true, dir) {
+		t.Error("Changes to existing directories should be allowed")
+	}
+	if w.allow(file, true, file) || w.allow(dir, true, deleted(dir)) {
+		t.Error("Modifying or deleting existing files and directories should be refused")
+	}
+	if refused := w.finish(); len(refused) != 2 || refused[0] != "dir" || refused[1] != "file" || w.Violations() != 2 {
+		t.Errorf("Expected dir and file refused, got %v", refused)
+	}
+
+	// Files refused again aren't reported again, but new ones are.
+	w.start()
+	w.allow(file, true, file)
+	w.allow(file, true, protocol.FileInfo{Name: "other"})
+	if refused := w.finish(); len(refused) != 1 || refused[0] != "other" || w.Violations() != 2 {
+		t.Errorf("Expected only other newly refused, got %v", refused)
+	}
+
+	var disabled *folderWriteOnce
+	disabled.start()
+	if !disabled.allow(file, true, file) || disabled.finish() != nil || disabled.Violations() != 0 {
+		t.Error("A nil write once should not refuse anything")
+	}
+	disabled = newFolderWriteOnce(config.FolderConfiguration{})
+	disabled.start()
+	if !disabled.allow(file, true, file) {
+		t.Error("A folder that isn't write once should not refuse anything")
+	}
+}
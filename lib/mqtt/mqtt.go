@@ -0,0 +1,335 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package mqtt implements as much of MQTT 3.1.1 as it takes to publish
+// messages to a broker, at QoS 0 or 1. A Client is not safe for concurrent
+// use.
+package mqtt
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/dialer"
+)
+
+const (
+	packetConnect    = 1
+	packetConnAck    = 2
+	packetPublish    = 3
+	packetPubAck     = 4
+	packetPingReq    = 12
+	packetPingResp   = 13
+	packetDisconnect = 14
+
+	protocolLevel = 4 // 3.1.1
+
+	flagCleanSession = 0x02
+	flagWill         = 0x04
+	flagWillRetain   = 0x20
+	flagPassword     = 0x40
+	flagUsername     = 0x80
+
+	maxRemainingLength = 268435455
+)
+
+var (
+	ErrUnsupportedQoS = errors.New("mqtt: only QoS 0 and 1 are supported")
+	errTooLarge       = errors.New("mqtt: packet too large")
+	errMalformed      = errors.New("mqtt: malformed packet")
+)
+
+// Options are those of the connection to the broker.
+type Options struct {
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration // how often the broker expects to hear from us; zero for never
+	Timeout   time.Duration // for connecting, and for each packet and its acknowledgement
+
+	// The will is published by the broker if we go away without
+	// disconnecting.
+	WillTopic   string
+	WillMessage []byte
+	WillQoS     byte
+	WillRetain  bool
+}
+
+// A Client is a connection to a broker, for publishing.
+type Client struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+	nextID  uint16
+}
+
+// Dial connects to the broker at the URL, which is of the form
+// "tcp://host:port" or, for TLS, "tls://host:port". The port defaults to
+// 1883, or 8883 for TLS.
+func Dial(broker string, opts Options) (*Client, error) {
+	uri, err := url.Parse(broker)
+	if err != nil {
+		return nil, err
+	}
+	host := uri.Host
+	useTLS := false
+	switch uri.Scheme {
+	case "tcp", "mqtt":
+		if uri.Port() == "" {
+			host = net.JoinHostPort(uri.Hostname(), "1883")
+		}
+	case "tls", "ssl", "mqtts":
+		useTLS = true
+		if uri.Port() == "" {
+			host = net.JoinHostPort(uri.Hostname(), "8883")
+		}
+	default:
+		return nil, fmt.Errorf("mqtt: unsupported broker scheme %q", uri.Scheme)
+	}
+
+	conn, err := dialer.DialTimeout("tcp", host, opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if useTLS {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: uri.Hostname()})
+		if opts.Timeout > 0 {
+			tlsConn.SetDeadline(time.Now().Add(opts.Timeout))
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	c, err := NewClient(conn, opts)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClient connects to the broker over the given connection.
+func NewClient(conn net.Conn, opts Options) (*Client, error) {
+	if opts.WillQoS > 1 {
+		return nil, ErrUnsupportedQoS
+	}
+	c := &Client{
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		timeout: opts.Timeout,
+	}
+
+	flags := byte(flagCleanSession)
+	var payload []byte
+	payload = appendString(payload, opts.ClientID)
+	if opts.WillTopic != "" {
+		flags |= flagWill | opts.WillQoS<<3
+		if opts.WillRetain {
+			flags |= flagWillRetain
+		}
+		payload = appendString(payload, opts.WillTopic)
+		payload = appendBytes(payload, opts.WillMessage)
+	}
+	if opts.Username != "" {
+		flags |= flagUsername
+		payload = appendString(payload, opts.Username)
+	}
+	if opts.Password != "" {
+		flags |= flagPassword
+		payload = appendString(payload, opts.Password)
+	}
+
+	var body []byte
+	body = appendString(body, "MQTT")
+	body = append(body, protocolLevel, flags)
+	body = appendUint16(body, uint16(opts.KeepAlive/time.Second))
+	body = append(body, payload...)
+
+	if err := c.send(packetConnect<<4, body); err != nil {
+		return nil, err
+	}
+	typ, resp, err := c.receive()
+	if err != nil {
+		return nil, err
+	}
+	if typ != packetConnAck || len(resp) != 2 {
+		return nil, errMalformed
+	}
+	if resp[1] != 0 {
+		return nil, connectError(resp[1])
+	}
+	return c, nil
+}
+
+// Publish sends the message to the topic. At QoS 1 it waits for the broker
+// to acknowledge it.
+func (c *Client) Publish(topic string, message []byte, qos byte, retain bool) error {
+	if qos > 1 {
+		return ErrUnsupportedQoS
+	}
+
+	header := byte(packetPublish<<4) | qos<<1
+	if retain {
+		header |= 1
+	}
+	var body []byte
+	body = appendString(body, topic)
+	var id uint16
+	if qos > 0 {
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID++
+		}
+		id = c.nextID
+		body = appendUint16(body, id)
+	}
+	body = append(body, message...)
+
+	if err := c.send(header, body); err != nil {
+		return err
+	}
+	if qos == 0 {
+		return nil
+	}
+	return c.await(packetPubAck, id)
+}
+
+// Ping lets the broker know we're still there, and checks that it is.
+func (c *Client) Ping() error {
+	if err := c.send(packetPingReq<<4, nil); err != nil {
+		return err
+	}
+	return c.await(packetPingResp, 0)
+}
+
+// Close disconnects from the broker, which then doesn't publish the will.
+func (c *Client) Close() error {
+	c.send(packetDisconnect<<4, nil)
+	return c.conn.Close()
+}
+
+// await reads packets until one of the given type, and for packets that
+// have one, ID.
+func (c *Client) await(typ byte, id uint16) error {
+	for {
+		t, body, err := c.receive()
+		if err != nil {
+			return err
+		}
+		if t != typ {
+			continue
+		}
+		if typ == packetPubAck && (len(body) != 2 || binary.BigEndian.Uint16(body) != id) {
+			continue
+		}
+		return nil
+	}
+}
+
+func (c *Client) send(header byte, body []byte) error {
+	if len(body) > maxRemainingLength {
+		return errTooLarge
+	}
+	if c.timeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	pkt := append([]byte{header}, appendRemainingLength(nil, len(body))...)
+	pkt = append(pkt, body...)
+	_, err := c.conn.Write(pkt)
+	return err
+}
+
+func (c *Client) receive() (byte, []byte, error) {
+	if c.timeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+	header, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, err := readRemainingLength(c.r)
+	if err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
+
+func appendString(bs []byte, s string) []byte {
+	return appendBytes(bs, []byte(s))
+}
+
+func appendBytes(bs, data []byte) []byte {
+	bs = appendUint16(bs, uint16(len(data)))
+	return append(bs, data...)
+}
+
+func appendUint16(bs []byte, v uint16) []byte {
+	return append(bs, byte(v>>8), byte(v))
+}
+
+// appendRemainingLength appends the length in the variable length encoding
+// of the fixed header: seven bits at a time, least significant first, with
+// the top bit set on all but the last byte.
+func appendRemainingLength(bs []byte, length int) []byte {
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		bs = append(bs, b)
+		if length == 0 {
+			return bs
+		}
+	}
+}
+
+func readRemainingLength(r io.ByteReader) (int, error) {
+	length, mult := 0, 1
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length += int(b&0x7f) * mult
+		if b&0x80 == 0 {
+			return length, nil
+		}
+		mult *= 128
+	}
+	return 0, errMalformed
+}
+
+func connectError(code byte) error {
+	switch code {
+	case 1:
+		return errors.New("mqtt: connection refused: unacceptable protocol version")
+	case 2:
+		return errors.New("mqtt: connection refused: client identifier rejected")
+	case 3:
+		return errors.New("mqtt: connection refused: server unavailable")
+	case 4:
+		return errors.New("mqtt: connection refused: bad user name or password")
+	case 5:
+		return errors.New("mqtt: connection refused: not authorized")
+	default:
+		return fmt.Errorf("mqtt: connection refused: code %d", code)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+type packet struct {
+	header byte
+	body   []byte
+}
+
+// fakeBroker reads the packets sent to it, acknowledging those that want
+// it, until the connection is closed.
+func fakeBroker(conn net.Conn, connAckCode byte, packets chan<- packet) {
+	defer close(packets)
+	r := bufio.NewReader(conn)
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		length, err := readRemainingLength(r)
+		if err != nil {
+			return
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+		packets <- packet{header, body}
+
+		switch header >> 4 {
+		case packetConnect:
+			conn.Write([]byte{packetConnAck << 4, 2, 0, connAckCode})
+		case packetPublish:
+			if header&0x06 != 0 {
+				// The packet ID follows the topic
+				idx := 2 + int(body[0])<<8 + int(body[1])
+				conn.Write([]byte{packetPubAck << 4, 2, body[idx], body[idx+1]})
+			}
+		case packetPingReq:
+			conn.Write([]byte{packetPingResp << 4, 0})
+		}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	client, server := net.Pipe()
+	packets := make(chan packet, 10)
+	go fakeBroker(server, 0, packets)
+
+	c, err := NewClient(client, Options{
+		ClientID:    "syncthing-test",
+		Username:    "user",
+		Password:    "pass",
+		KeepAlive:   time.Minute,
+		Timeout:     5 * time.Second,
+		WillTopic:   "syncthing/status",
+		WillMessage: []byte("offline"),
+		WillRetain:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect := <-packets
+	expected := []byte{
+		0, 4, 'M', 'Q', 'T', 'T', protocolLevel,
+		flagCleanSession | flagWill | flagWillRetain | flagUsername | flagPassword,
+		0, 60,
+		0, 14, 's', 'y', 'n', 'c', 't', 'h', 'i', 'n', 'g', '-', 't', 'e', 's', 't',
+		0, 16, 's', 'y', 'n', 'c', 't', 'h', 'i', 'n', 'g', '/', 's', 't', 'a', 't', 'u', 's',
+		0, 7, 'o', 'f', 'f', 'l', 'i', 'n', 'e',
+		0, 4, 'u', 's', 'e', 'r',
+		0, 4, 'p', 'a', 's', 's',
+	}
+	if connect.header != packetConnect<<4 || !bytes.Equal(connect.body, expected) {
+		t.Errorf("Unexpected CONNECT %x: %x", connect.header, connect.body)
+	}
+
+	if err := c.Publish("a/b", []byte("hello"), 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if pub := <-packets; pub.header != packetPublish<<4|1 || !bytes.Equal(pub.body, []byte{0, 3, 'a', '/', 'b', 'h', 'e', 'l', 'l', 'o'}) {
+		t.Errorf("Unexpected QoS 0 PUBLISH %x: %x", pub.header, pub.body)
+	}
+
+	if err := c.Publish("a/b", []byte("hi"), 1, false); err != nil {
+		t.Fatal(err)
+	}
+	if pub := <-packets; pub.header != packetPublish<<4|2 || !bytes.Equal(pub.body, []byte{0, 3, 'a', '/', 'b', 0, 1, 'h', 'i'}) {
+		t.Errorf("Unexpected QoS 1 PUBLISH %x: %x", pub.header, pub.body)
+	}
+
+	if err := c.Publish("a/b", nil, 2, false); err != ErrUnsupportedQoS {
+		t.Errorf("Expected QoS 2 to be unsupported, got %v", err)
+	}
+
+	if err := c.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	<-packets
+
+	c.Close()
+	if disconnect := <-packets; disconnect.header != packetDisconnect<<4 {
+		t.Errorf("Unexpected packet %x on closing", disconnect.header)
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	client, server := net.Pipe()
+	packets := make(chan packet, 10)
+	go fakeBroker(server, 4, packets)
+
+	if _, err := NewClient(client, Options{ClientID: "test", Timeout: 5 * time.Second}); err == nil {
+		t.Error("Expected the connection to be refused")
+	}
+	client.Close()
+}
+
+func TestRemainingLength(t *testing.T) {
+	for _, length := range []int{0, 127, 128, 16383, 16384, 2097151, 2097152, maxRemainingLength} {
+		bs := appendRemainingLength(nil, length)
+		res, err := readRemainingLength(bufio.NewReader(bytes.NewReader(bs)))
+		if err != nil || res != length {
+			t.Errorf("Length %d encoded as %x decoded as %d, %v", length, bs, res, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package osutil
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const powerSupplyDir = "/sys/class/power_supply"
+
+// OnBattery returns whether the computer is running on battery power. It
+// is if there is a discharging battery and no mains power.
+func OnBattery() (bool, error) {
+	return onBattery(powerSupplyDir)
+}
+
+func onBattery(dir string) (bool, error) {
+	supplies, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+
+	discharging := false
+	for _, supply := range supplies {
+		path := filepath.Join(dir, supply.Name())
+		switch readSysValue(filepath.Join(path, "type")) {
+		case "Mains":
+			if readSysValue(filepath.Join(path, "online")) == "1" {
+				return false, nil
+			}
+		case "Battery":
+			if readSysValue(filepath.Join(path, "status")) == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return discharging, nil
+}
+
+func readSysValue(path string) string {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(bs))
+}
@@ -0,0 +1,48 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package osutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOnBattery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(supply, name, value string) {
+		if err := os.MkdirAll(filepath.Join(dir, supply), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, supply, name), []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("BAT0", "type", "Battery")
+	write("BAT0", "status", "Discharging")
+	write("AC", "type", "Mains")
+	write("AC", "online", "0")
+	if on, err := onBattery(dir); err != nil || !on {
+		t.Error("Should be on battery, got", on, err)
+	}
+
+	write("AC", "online", "1")
+	if on, err := onBattery(dir); err != nil || on {
+		t.Error("Should be on mains power, got", on, err)
+	}
+
+	if _, err := onBattery(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
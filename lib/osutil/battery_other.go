@@ -0,0 +1,17 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build !linux
+
+package osutil
+
+import "errors"
+
+// OnBattery returns whether the computer is running on battery power. It's
+// not known on this platform.
+func OnBattery() (bool, error) {
+	return false, errors.New("power state not supported on this platform")
+}
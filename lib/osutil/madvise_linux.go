@@ -0,0 +1,20 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package osutil
+
+import "golang.org/x/sys/unix"
+
+func madvise(data []byte, advice MmapAdvice) error {
+	switch advice {
+	case MmapSequential:
+		return unix.Madvise(data, unix.MADV_SEQUENTIAL)
+	case MmapRandom:
+		return unix.Madvise(data, unix.MADV_RANDOM)
+	default:
+		return nil
+	}
+}
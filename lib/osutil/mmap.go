@@ -0,0 +1,110 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package osutil
+
+import (
+	"errors"
+	"io"
+	"os"
+	"runtime/debug"
+)
+
+// MmapMinSize is the smallest file size for which memory mapping is worth
+// the setup cost, compared to plain reads.
+const MmapMinSize = 16 << 20
+
+// MmapAdvice describes how a mapping will be accessed, so the kernel can
+// read ahead (or not) accordingly.
+type MmapAdvice int
+
+const (
+	MmapNormal MmapAdvice = iota
+	MmapSequential
+	MmapRandom
+)
+
+var (
+	errMmapUnsupported = errors.New("memory mapping is not supported on this platform")
+	errMmapFault       = errors.New("fault reading memory mapped file (truncated?)")
+)
+
+// A MappedFile is a read only memory mapping of (the start of) a file.
+// Reads from the mapping that fault, as happens when the file is truncated
+// underneath it, return an error instead of crashing the process.
+type MappedFile struct {
+	data []byte
+}
+
+// MapFile maps the first size bytes of the open file for reading. The
+// mapping stays valid after the file is closed, until Close is called on
+// it. An error is returned when the platform or filesystem doesn't support
+// mapping, in which case the caller should fall back to regular reads.
+func MapFile(fd *os.File, size int64, advice MmapAdvice) (*MappedFile, error) {
+	if size <= 0 || int64(int(size)) != size {
+		return nil, errors.New("unmappable size")
+	}
+	data, err := mmap(fd, int(size))
+	if err != nil {
+		return nil, err
+	}
+	// The advice is just that; failure to take it is not an error.
+	_ = madvise(data, advice)
+	return &MappedFile{data}, nil
+}
+
+// MapFileOrNil is like MapFile, but returns nil instead of an error for
+// files smaller than MmapMinSize or that can't be mapped.
+func MapFileOrNil(fd *os.File, size int64, advice MmapAdvice) *MappedFile {
+	if size < MmapMinSize {
+		return nil
+	}
+	m, err := MapFile(fd, size, advice)
+	if err != nil {
+		return nil
+	}
+	return m
+}
+
+func (m *MappedFile) Size() int64 {
+	return int64(len(m.data))
+}
+
+func (m *MappedFile) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+
+	defer func() {
+		if recover() != nil {
+			n, err = 0, errMmapFault
+		}
+	}()
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+
+	n = copy(p, m.data[off:])
+	if n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Reader returns a reader for the whole mapping.
+func (m *MappedFile) Reader() io.Reader {
+	return io.NewSectionReader(m, 0, m.Size())
+}
+
+func (m *MappedFile) Close() error {
+	if m.data == nil {
+		return nil
+	}
+	err := munmap(m.data)
+	m.data = nil
+	return err
+}
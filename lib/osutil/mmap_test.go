@@ -0,0 +1,128 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package osutil_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/osutil"
+)
+
+func TestMapFile(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("memory mapping is not supported")
+	}
+
+	fd, err := ioutil.TempFile("", "syncthing-mmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fd.Name())
+	defer fd.Close()
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<12)
+	if _, err := fd.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := osutil.MapFileOrNil(fd, int64(len(data)), osutil.MmapSequential); m != nil {
+		t.Error("small files should not be mapped")
+		m.Close()
+	}
+
+	m, err := osutil.MapFile(fd, int64(len(data)), osutil.MmapSequential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	bs, err := ioutil.ReadAll(m.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bs, data) {
+		t.Error("mapped contents differ from written data")
+	}
+
+	// Reading beyond the end of a truncated file faults, which must be
+	// reported as an error.
+	if err := fd.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	if _, err := m.ReadAt(buf, int64(len(data)-len(buf))); err == nil {
+		t.Error("unexpected nil error reading truncated mapping")
+	}
+}
+
+func TestMappedFileCache(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("memory mapping is not supported")
+	}
+
+	dir, err := ioutil.TempDir("", "syncthing-mmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	small := filepath.Join(dir, "small")
+	if err := ioutil.WriteFile(small, []byte("small"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	large := filepath.Join(dir, "large")
+	writeLarge := func(data []byte, mtime time.Time) os.FileInfo {
+		if err := ioutil.WriteFile(large, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Truncate(large, osutil.MmapMinSize); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(large, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Lstat(large)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+
+	c := osutil.NewMappedFileCache(1, time.Minute)
+	buf := make([]byte, 5)
+
+	info, err := os.Lstat(small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := c.ReadAt(small, info, buf, 0); ok {
+		t.Error("small files should not be mapped")
+	}
+
+	info = writeLarge([]byte("first"), time.Unix(1000, 0))
+	if ok, err := c.ReadAt(large, info, buf, 0); !ok || err != nil {
+		t.Fatal("unexpected failure reading mapped file:", ok, err)
+	}
+	if string(buf) != "first" {
+		t.Errorf("read %q, expected %q", buf, "first")
+	}
+
+	// A changed file gets mapped anew.
+	info = writeLarge([]byte("again"), time.Unix(2000, 0))
+	if ok, err := c.ReadAt(large, info, buf, 0); !ok || err != nil {
+		t.Fatal("unexpected failure reading mapped file:", ok, err)
+	}
+	if string(buf) != "again" {
+		t.Errorf("read %q, expected %q", buf, "again")
+	}
+}
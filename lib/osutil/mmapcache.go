@@ -0,0 +1,129 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package osutil
+
+import (
+	"os"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// A MappedFileCache keeps the memory mappings of the large files read from
+// most recently, so that reading blocks from them over and over, as when
+// serving requests, needs neither opening the file nor a syscall per read.
+// Mappings unused for longer than the idle time are dropped, so that they
+// don't keep deleted files around for long.
+type MappedFileCache struct {
+	max     int
+	idle    time.Duration
+	mut     sync.Mutex
+	entries map[string]*cachedMapping
+}
+
+type cachedMapping struct {
+	*MappedFile
+	size    int64
+	modTime time.Time
+	used    time.Time
+	refs    int  // reads in progress
+	dropped bool // to be closed once refs drops to zero
+}
+
+func NewMappedFileCache(max int, idle time.Duration) *MappedFileCache {
+	return &MappedFileCache{
+		max:     max,
+		idle:    idle,
+		mut:     sync.NewMutex(),
+		entries: make(map[string]*cachedMapping),
+	}
+}
+
+// ReadAt reads len(p) bytes at the offset of the named file, described by
+// info, through a cached mapping. It returns false without reading if the
+// file is too small to be worth mapping or can't be mapped, in which case
+// the caller should read it as usual.
+func (c *MappedFileCache) ReadAt(name string, info os.FileInfo, p []byte, off int64) (bool, error) {
+	e := c.get(name, info)
+	if e == nil {
+		return false, nil
+	}
+	defer c.release(e)
+	_, err := e.ReadAt(p, off)
+	return true, err
+}
+
+func (c *MappedFileCache) get(name string, info os.FileInfo) *cachedMapping {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	now := time.Now()
+	for n, e := range c.entries {
+		if now.Sub(e.used) > c.idle {
+			c.dropLocked(n, e)
+		}
+	}
+
+	if e, ok := c.entries[name]; ok {
+		if e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+			e.used = now
+			e.refs++
+			return e
+		}
+		c.dropLocked(name, e)
+	}
+	if info.Size() < MmapMinSize {
+		return nil
+	}
+
+	fd, err := os.Open(name)
+	if err != nil {
+		return nil
+	}
+	m, err := MapFile(fd, info.Size(), MmapRandom)
+	fd.Close()
+	if err != nil {
+		return nil
+	}
+
+	if len(c.entries) >= c.max {
+		var oldestName string
+		var oldest *cachedMapping
+		for n, e := range c.entries {
+			if oldest == nil || e.used.Before(oldest.used) {
+				oldestName, oldest = n, e
+			}
+		}
+		c.dropLocked(oldestName, oldest)
+	}
+	e := &cachedMapping{
+		MappedFile: m,
+		size:       info.Size(),
+		modTime:    info.ModTime(),
+		used:       now,
+		refs:       1,
+	}
+	c.entries[name] = e
+	return e
+}
+
+func (c *MappedFileCache) release(e *cachedMapping) {
+	c.mut.Lock()
+	e.refs--
+	if e.dropped && e.refs == 0 {
+		e.Close()
+	}
+	c.mut.Unlock()
+}
+
+func (c *MappedFileCache) dropLocked(name string, e *cachedMapping) {
+	delete(c.entries, name)
+	e.dropped = true
+	if e.refs == 0 {
+		e.Close()
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/syncthing/syncthing/lib/rand"
@@ -53,6 +54,12 @@ func (f FileInfo) IsInvalid() bool {
 	return f.Invalid
 }
 
+// IsPlaceholder returns true if the device has only the metadata of the
+// file, with an empty placeholder in place of its data.
+func (f FileInfo) IsPlaceholder() bool {
+	return f.Placeholder
+}
+
 func (f FileInfo) IsDirectory() bool {
 	return f.Type == FileInfoTypeDirectory
 }
@@ -88,6 +95,36 @@ func (f FileInfo) ModTime() time.Time {
 	return time.Unix(f.ModifiedS, int64(f.ModifiedNs))
 }
 
+// CorrectedModTime returns the modification time less the skew of the clock
+// of the device that made the change, as it recorded it at the time.
+func (f FileInfo) CorrectedModTime() time.Time {
+	return f.ModTime().Add(-time.Duration(f.ClockSkewMs) * time.Millisecond)
+}
+
+// BlockSize returns the size of the blocks the file was hashed in, all but
+// the last of its blocks being that size.
+func (f FileInfo) BlockSize() int {
+	if f.RawBlockSize == 0 {
+		return BlockSize
+	}
+	return int(f.RawBlockSize)
+}
+
+// BlockSizeFor returns the size of the blocks to hash a file of the given
+// size in, given the smallest block size to use. That's the smallest power
+// of two multiple of it that keeps the number of blocks reasonable, or the
+// standard block size, whichever is smaller.
+func BlockSizeFor(fileSize int64, minBlockSize int) int {
+	if minBlockSize <= 0 {
+		return BlockSize
+	}
+	blockSize := minBlockSize
+	for blockSize < BlockSize && fileSize > int64(blockSize)*desiredPerFileBlocks {
+		blockSize *= 2
+	}
+	return blockSize
+}
+
 // WinsConflict returns true if "f" is the one to choose when it is in
 // conflict with "other".
 func (f FileInfo) WinsConflict(other FileInfo) bool {
@@ -100,11 +137,14 @@ func (f FileInfo) WinsConflict(other FileInfo) bool {
 		return false
 	}
 
-	// The one with the newer modification time wins.
-	if f.ModTime().After(other.ModTime()) {
+	// The one with the newer modification time wins. The times are
+	// corrected for the clock skews recorded with the changes, so that a
+	// device with a wrong clock doesn't always win or lose, and all devices
+	// pick the same one.
+	if f.CorrectedModTime().After(other.CorrectedModTime()) {
 		return true
 	}
-	if f.ModTime().Before(other.ModTime()) {
+	if f.CorrectedModTime().Before(other.CorrectedModTime()) {
 		return false
 	}
 
@@ -113,6 +153,34 @@ func (f FileInfo) WinsConflict(other FileInfo) bool {
 	return f.Version.Compare(other.Version) == ConcurrentGreater
 }
 
+// IsEquivalent returns true if "f" and "other" describe the same file
+// contents and attributes as far as other devices are concerned. The
+// version, sequence number, last modifier and weak hashes are not
+// considered, nor are the permission bits if both sides lack them.
+func (f FileInfo) IsEquivalent(other FileInfo) bool {
+	if f.Name != other.Name || f.Type != other.Type || f.Deleted != other.Deleted || f.Invalid != other.Invalid {
+		return false
+	}
+	if f.Size != other.Size || f.ModifiedS != other.ModifiedS || f.ModifiedNs != other.ModifiedNs {
+		return false
+	}
+	if f.NoPermissions != other.NoPermissions {
+		return false
+	}
+	if f.HasPermissionBits() && f.Permissions&0777 != other.Permissions&0777 {
+		return false
+	}
+	if f.SymlinkTarget != other.SymlinkTarget || len(f.Blocks) != len(other.Blocks) {
+		return false
+	}
+	for i := range f.Blocks {
+		if !bytes.Equal(f.Blocks[i].Hash, other.Blocks[i].Hash) {
+			return false
+		}
+	}
+	return true
+}
+
 func (b BlockInfo) String() string {
 	return fmt.Sprintf("Block{%d/%d/%d/%x}", b.Offset, b.Size, b.WeakHash, b.Hash)
 }
@@ -153,3 +221,35 @@ func (f Folder) Description() string {
 	}
 	return fmt.Sprintf("%q (%s)", f.Label, f.ID)
 }
+
+// Merge returns the cluster config resulting from applying the delta to
+// "c". Folders in the delta replace those of the same ID, removed folders
+// are dropped, and the folder list is kept sorted by ID.
+func (c ClusterConfig) Merge(delta ClusterConfig) ClusterConfig {
+	folders := make(map[string]Folder, len(c.Folders)+len(delta.Folders))
+	for _, folder := range c.Folders {
+		folders[folder.ID] = folder
+	}
+	for _, id := range delta.RemovedFolders {
+		delete(folders, id)
+	}
+	for _, folder := range delta.Folders {
+		folders[folder.ID] = folder
+	}
+
+	merged := ClusterConfig{
+		Folders:       make([]Folder, 0, len(folders)),
+		AcceptsDeltas: c.AcceptsDeltas,
+	}
+	for _, folder := range folders {
+		merged.Folders = append(merged.Folders, folder)
+	}
+	sort.Sort(folderList(merged.Folders))
+	return merged
+}
+
+type folderList []Folder
+
+func (l folderList) Len() int           { return len(l) }
+func (l folderList) Swap(a, b int)      { l[a], l[b] = l[b], l[a] }
+func (l folderList) Less(a, b int) bool { return l[a].ID < l[b].ID }
@@ -0,0 +1,113 @@
+// Copyright (C) 2017 The Protocol Authors.
+
+package protocol
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsEquivalent(t *testing.T) {
+	base := FileInfo{
+		Name:        "foo",
+		Size:        42,
+		Permissions: 0644,
+		ModifiedS:   1000,
+		Version:     Vector{[]Counter{{42, 1}}},
+		Sequence:    1,
+		ModifiedBy:  42,
+		Blocks:      []BlockInfo{{Size: 42, Hash: []byte{1, 2, 3}, WeakHash: 1}},
+	}
+
+	equivalent := []func(f *FileInfo){
+		func(f *FileInfo) { f.Version = f.Version.Update(43) },
+		func(f *FileInfo) { f.Sequence = 2 },
+		func(f *FileInfo) { f.ModifiedBy = 43 },
+		func(f *FileInfo) { f.Blocks = []BlockInfo{{Size: 42, Hash: []byte{1, 2, 3}, WeakHash: 2}} },
+	}
+	different := []func(f *FileInfo){
+		func(f *FileInfo) { f.Name = "bar" },
+		func(f *FileInfo) { f.Size = 43 },
+		func(f *FileInfo) { f.Permissions = 0755 },
+		func(f *FileInfo) { f.NoPermissions = true },
+		func(f *FileInfo) { f.ModifiedNs = 1 },
+		func(f *FileInfo) { f.Deleted = true },
+		func(f *FileInfo) { f.Invalid = true },
+		func(f *FileInfo) { f.Type = FileInfoTypeDirectory },
+		func(f *FileInfo) { f.Blocks = []BlockInfo{{Size: 42, Hash: []byte{3, 2, 1}}} },
+		func(f *FileInfo) { f.Blocks = nil },
+	}
+
+	for i, mod := range equivalent {
+		f := base
+		mod(&f)
+		if !base.IsEquivalent(f) || !f.IsEquivalent(base) {
+			t.Errorf("case %d: %v should be equivalent to %v", i, f, base)
+		}
+	}
+	for i, mod := range different {
+		f := base
+		mod(&f)
+		if base.IsEquivalent(f) || f.IsEquivalent(base) {
+			t.Errorf("case %d: %v should not be equivalent to %v", i, f, base)
+		}
+	}
+}
+
+func TestClusterConfigMerge(t *testing.T) {
+	base := ClusterConfig{
+		Folders: []Folder{
+			{ID: "a", Label: "A"},
+			{ID: "b", Label: "B"},
+			{ID: "c", Label: "C"},
+		},
+		AcceptsDeltas: true,
+	}
+	delta := ClusterConfig{
+		Folders: []Folder{
+			{ID: "d", Label: "D"},
+			{ID: "b", Label: "B2"},
+		},
+		Delta:          true,
+		RemovedFolders: []string{"c"},
+	}
+
+	expected := ClusterConfig{
+		Folders: []Folder{
+			{ID: "a", Label: "A"},
+			{ID: "b", Label: "B2"},
+			{ID: "d", Label: "D"},
+		},
+		AcceptsDeltas: true,
+	}
+	if merged := base.Merge(delta); !reflect.DeepEqual(merged, expected) {
+		t.Errorf("unexpected merge result\n%+v\n!=\n%+v", merged, expected)
+	}
+}
+
+func TestBlockSizeFor(t *testing.T) {
+	cases := []struct {
+		fileSize     int64
+		minBlockSize int
+		blockSize    int
+	}{
+		{0, MinBlockSize, MinBlockSize},
+		{1000 * MinBlockSize, MinBlockSize, MinBlockSize},
+		{2000 * MinBlockSize, MinBlockSize, MinBlockSize},
+		{2000*MinBlockSize + 1, MinBlockSize, 2 * MinBlockSize},
+		{10000 * MinBlockSize, MinBlockSize, 8 * MinBlockSize},
+		{1 << 40, MinBlockSize, BlockSize},
+		{1 << 40, BlockSize, BlockSize},
+		{100, 0, BlockSize},
+	}
+
+	for _, tc := range cases {
+		if bs := BlockSizeFor(tc.fileSize, tc.minBlockSize); bs != tc.blockSize {
+			t.Errorf("BlockSizeFor(%d, %d) = %d, expected %d", tc.fileSize, tc.minBlockSize, bs, tc.blockSize)
+		}
+	}
+
+	if bs := (FileInfo{}).BlockSize(); bs != BlockSize {
+		t.Errorf("BlockSize() of a file without a block size = %d, expected %d", bs, BlockSize)
+	}
+}
@@ -2,7 +2,10 @@
 
 package protocol
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 type TestModel struct {
 	data          []byte
@@ -14,6 +17,7 @@ type TestModel struct {
 	fromTemporary bool
 	closedCh      chan struct{}
 	closedErr     error
+	managed       []ManagementRequest
 }
 
 func newTestModel() *TestModel {
@@ -28,7 +32,7 @@ func (t *TestModel) Index(deviceID DeviceID, folder string, files []FileInfo) {
 func (t *TestModel) IndexUpdate(deviceID DeviceID, folder string, files []FileInfo) {
 }
 
-func (t *TestModel) Request(deviceID DeviceID, folder, name string, offset int64, hash []byte, fromTemporary bool, buf []byte) error {
+func (t *TestModel) Request(deviceID DeviceID, folder, name string, offset int64, hash []byte, fromTemporary, routed bool, buf []byte) error {
 	t.folder = folder
 	t.name = name
 	t.offset = offset
@@ -50,6 +54,14 @@ func (t *TestModel) ClusterConfig(deviceID DeviceID, config ClusterConfig) {
 func (t *TestModel) DownloadProgress(DeviceID, string, []FileDownloadProgressUpdate) {
 }
 
+func (t *TestModel) Manage(deviceID DeviceID, req ManagementRequest) ([]byte, error) {
+	t.managed = append(t.managed, req)
+	if req.Command != ManagementGetConfig {
+		return nil, errors.New("not allowed")
+	}
+	return t.data, nil
+}
+
 func (t *TestModel) closedError() error {
 	select {
 	case <-t.closedCh:
@@ -10,6 +10,9 @@ func TestWinsConflict(t *testing.T) {
 		{{ModifiedS: 42}, {ModifiedS: 41}},
 		{{ModifiedS: 41}, {ModifiedS: 42, Deleted: true}},
 		{{ModifiedS: 41, Version: Vector{[]Counter{{42, 2}, {43, 1}}}}, {ModifiedS: 41, Version: Vector{[]Counter{{42, 1}, {43, 2}}}}},
+		// Modification times are corrected for the recorded clock skew
+		{{ModifiedS: 41}, {ModifiedS: 42, ClockSkewMs: 2000}},
+		{{ModifiedS: 40, ClockSkewMs: -2000}, {ModifiedS: 41}},
 	}
 
 	for _, tc := range testcases {
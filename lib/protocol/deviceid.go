@@ -83,7 +83,10 @@ func (n DeviceID) Short() ShortID {
 	return ShortID(binary.BigEndian.Uint64(n[:]))
 }
 
-func (n *DeviceID) MarshalText() ([]byte, error) {
+// MarshalText has a value receiver, so that device IDs in structs that
+// aren't addressable, such as one marshalled by value, are marshalled as
+// text too.
+func (n DeviceID) MarshalText() ([]byte, error) {
 	return []byte(n.String()), nil
 }
 
@@ -5,7 +5,10 @@
 
 package protocol
 
-import "testing"
+import (
+	"encoding/json"
+	"testing"
+)
 
 var formatted = "P56IOI7-MZJNU2Y-IQGDREY-DM2MGTI-MGL3BXN-PQ6W5BM-TBBZ4TJ-XZWICQ2"
 var formatCases = []string{
@@ -79,6 +82,23 @@ func TestMarshallingDeviceID(t *testing.T) {
 	}
 }
 
+func TestMarshallingDeviceIDJSON(t *testing.T) {
+	id, _ := DeviceIDFromString(formatted)
+	type holder struct {
+		ID DeviceID
+	}
+
+	// Marshalled by value, the field isn't addressable
+	bs, err := json.Marshal(holder{ID: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res holder
+	if err := json.Unmarshal(bs, &res); err != nil || res.ID != id {
+		t.Errorf("JSON marshalling error; %s (%v)", bs, err)
+	}
+}
+
 func TestShortIDString(t *testing.T) {
 	id, _ := DeviceIDFromString(formatted)
 
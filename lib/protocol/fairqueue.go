@@ -0,0 +1,41 @@
+// Copyright (C) 2017 The Protocol Authors.
+
+package protocol
+
+import "sync"
+
+// A fairQueue is a lock that is handed out in the order it was asked for.
+// It is used to serialize index messages, so that a folder with a lot of
+// index data to send takes turns with the other folders instead of hogging
+// the connection until it is done. A sync.Mutex makes no such promise; the
+// goroutine that just unlocked it is likely to get it again.
+type fairQueue struct {
+	mut     sync.Mutex
+	busy    bool
+	waiting []chan struct{}
+}
+
+func (q *fairQueue) acquire() {
+	q.mut.Lock()
+	if !q.busy {
+		q.busy = true
+		q.mut.Unlock()
+		return
+	}
+	turn := make(chan struct{})
+	q.waiting = append(q.waiting, turn)
+	q.mut.Unlock()
+	<-turn
+}
+
+func (q *fairQueue) release() {
+	q.mut.Lock()
+	if len(q.waiting) > 0 {
+		// Hand over directly to the next in line, staying busy.
+		close(q.waiting[0])
+		q.waiting = q.waiting[1:]
+	} else {
+		q.busy = false
+	}
+	q.mut.Unlock()
+}
@@ -0,0 +1,44 @@
+// Copyright (C) 2017 The Protocol Authors.
+
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFairQueueOrder(t *testing.T) {
+	var q fairQueue
+	q.acquire()
+
+	// Queue up waiters one by one, so that their order is known.
+	order := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		i := i
+		go func() {
+			q.acquire()
+			order <- i
+			q.release()
+		}()
+		for waiting(&q) != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	q.release()
+	for i := 0; i < 3; i++ {
+		if got := <-order; got != i {
+			t.Errorf("turn %d went to waiter %d", i, got)
+		}
+	}
+
+	// Everyone is done, so the queue is free again.
+	q.acquire()
+	q.release()
+}
+
+func waiting(q *fairQueue) int {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	return len(q.waiting)
+}
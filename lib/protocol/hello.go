@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 )
 
 // The HelloIntf interface is implemented by the version specific hello
@@ -23,6 +24,12 @@ type HelloResult struct {
 	DeviceName    string
 	ClientName    string
 	ClientVersion string
+	Timestamp     time.Time // the remote clock at the time of sending; zero if not announced
+	ColdStorage   bool      // requests for data should be a last resort, as they may take long to answer
+	Zstd          bool      // messages may be compressed with zstd
+	Management    bool      // management messages are understood
+	SmallBlocks   bool      // files hashed in blocks smaller than the standard size are understood
+	Placeholders  bool      // placeholder files, whose data the device doesn't have, are understood
 }
 
 var (
@@ -84,6 +91,14 @@ func readHello(c io.Reader) (HelloResult, error) {
 			DeviceName:    hello.DeviceName,
 			ClientName:    hello.ClientName,
 			ClientVersion: hello.ClientVersion,
+			ColdStorage:   hello.ColdStorage,
+			Zstd:          hello.Zstd,
+			Management:    hello.Management,
+			SmallBlocks:   hello.SmallBlocks,
+			Placeholders:  hello.Placeholders,
+		}
+		if hello.Timestamp != 0 {
+			res.Timestamp = time.Unix(0, hello.Timestamp)
 		}
 		return res, nil
 
@@ -105,7 +120,11 @@ func readHello(c io.Reader) (HelloResult, error) {
 		if err := hello.UnmarshalXDR(buf); err != nil {
 			return HelloResult{}, err
 		}
-		res := HelloResult(hello)
+		res := HelloResult{
+			DeviceName:    hello.DeviceName,
+			ClientName:    hello.ClientName,
+			ClientVersion: hello.ClientVersion,
+		}
 		return res, ErrTooOldVersion13
 
 	case 0x00010001, 0x00010000:
@@ -20,6 +20,7 @@ func TestVersion14Hello(t *testing.T) {
 		DeviceName:    "test device",
 		ClientName:    "syncthing",
 		ClientVersion: "v0.14.5",
+		Timestamp:     1234567890123456789,
 	}
 	msgBuf, err := expected.Marshal()
 	if err != nil {
@@ -58,6 +59,9 @@ func TestVersion14Hello(t *testing.T) {
 	if res.DeviceName != expected.DeviceName {
 		t.Errorf("incorrect DeviceName %q != expected %q", res.DeviceName, expected.DeviceName)
 	}
+	if res.Timestamp.UnixNano() != expected.Timestamp {
+		t.Errorf("incorrect Timestamp %d != expected %d", res.Timestamp.UnixNano(), expected.Timestamp)
+	}
 }
 
 func TestVersion13Hello(t *testing.T) {
@@ -19,8 +19,19 @@ const (
 	// BlockSize is the standard ata block size (128 KiB)
 	BlockSize = 128 << 10
 
+	// MinBlockSize is the smallest block size a folder may use (16 KiB)
+	MinBlockSize = 16 << 10
+
+	// Files are hashed in blocks large enough to make them at most this
+	// many blocks long, unless that would exceed the standard block size.
+	desiredPerFileBlocks = 2000
+
 	// MaxMessageLen is the largest message size allowed on the wire. (500 MB)
 	MaxMessageLen = 500 * 1000 * 1000
+
+	// ManagementTimeout is the longest we'll wait for the answer to a
+	// management command. Devices that don't support them never answer.
+	ManagementTimeout = time.Minute
 )
 
 const (
@@ -65,13 +76,16 @@ type Model interface {
 	// An index update was received from the peer device
 	IndexUpdate(deviceID DeviceID, folder string, files []FileInfo)
 	// A request was made by the peer device
-	Request(deviceID DeviceID, folder string, name string, offset int64, hash []byte, fromTemporary bool, buf []byte) error
+	Request(deviceID DeviceID, folder string, name string, offset int64, hash []byte, fromTemporary, routed bool, buf []byte) error
 	// A cluster configuration message was received
 	ClusterConfig(deviceID DeviceID, config ClusterConfig)
 	// The peer device closed the connection
 	Closed(conn Connection, err error)
 	// The peer device sent progress updates for the files it is currently downloading
 	DownloadProgress(deviceID DeviceID, folder string, updates []FileDownloadProgressUpdate)
+	// The peer device sent a management command, to be carried out if it's
+	// our controller
+	Manage(deviceID DeviceID, req ManagementRequest) ([]byte, error)
 }
 
 type Connection interface {
@@ -80,9 +94,10 @@ type Connection interface {
 	Name() string
 	Index(folder string, files []FileInfo) error
 	IndexUpdate(folder string, files []FileInfo) error
-	Request(folder string, name string, offset int64, size int, hash []byte, fromTemporary bool) ([]byte, error)
+	Request(folder string, name string, offset int64, size int, hash []byte, fromTemporary, routed bool) ([]byte, error)
 	ClusterConfig(config ClusterConfig)
 	DownloadProgress(folder string, updates []FileDownloadProgressUpdate)
+	Manage(req ManagementRequest) ([]byte, error)
 	Statistics() Statistics
 	Closed() bool
 }
@@ -98,7 +113,13 @@ type rawConnection struct {
 	awaiting    map[int32]chan asyncResult
 	awaitingMut sync.Mutex
 
-	idxMut sync.Mutex // ensures serialization of Index calls
+	// Management requests are numbered and awaited apart from the
+	// requests for blocks.
+	managing     map[int32]chan asyncResult
+	nextManageID int32
+	managingMut  sync.Mutex
+
+	idxQueue fairQueue // serializes Index calls, taking turns between callers
 
 	nextID    int32
 	nextIDMut sync.Mutex
@@ -108,6 +129,7 @@ type rawConnection struct {
 	once        sync.Once
 	pool        bufferPool
 	compression Compression
+	zstdLevel   int
 }
 
 type asyncResult struct {
@@ -142,7 +164,10 @@ var buffers = bufferPool{
 	minSize: 64 << 10,
 }
 
-func NewConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, receiver Model, name string, compress Compression) Connection {
+// NewConnection returns a connection to the device. Messages are compressed
+// as the compress setting says, with zstd at the given level or, if that's
+// zero, because the other side doesn't support it, with LZ4.
+func NewConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, receiver Model, name string, compress Compression, zstdLevel int) Connection {
 	cr := &countingReader{Reader: reader}
 	cw := &countingWriter{Writer: writer}
 
@@ -153,10 +178,12 @@ func NewConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, receiv
 		cr:          cr,
 		cw:          cw,
 		awaiting:    make(map[int32]chan asyncResult),
+		managing:    make(map[int32]chan asyncResult),
 		outbox:      make(chan asyncMessage),
 		closed:      make(chan struct{}),
 		pool:        bufferPool{minSize: BlockSize},
 		compression: compress,
+		zstdLevel:   zstdLevel,
 	}
 
 	return wireFormatConnection{&c}
@@ -186,12 +213,12 @@ func (c *rawConnection) Index(folder string, idx []FileInfo) error {
 		return ErrClosed
 	default:
 	}
-	c.idxMut.Lock()
+	c.idxQueue.acquire()
 	c.send(&Index{
 		Folder: folder,
 		Files:  idx,
 	}, nil)
-	c.idxMut.Unlock()
+	c.idxQueue.release()
 	return nil
 }
 
@@ -202,17 +229,17 @@ func (c *rawConnection) IndexUpdate(folder string, idx []FileInfo) error {
 		return ErrClosed
 	default:
 	}
-	c.idxMut.Lock()
+	c.idxQueue.acquire()
 	c.send(&IndexUpdate{
 		Folder: folder,
 		Files:  idx,
 	}, nil)
-	c.idxMut.Unlock()
+	c.idxQueue.release()
 	return nil
 }
 
 // Request returns the bytes for the specified block after fetching them from the connected peer.
-func (c *rawConnection) Request(folder string, name string, offset int64, size int, hash []byte, fromTemporary bool) ([]byte, error) {
+func (c *rawConnection) Request(folder string, name string, offset int64, size int, hash []byte, fromTemporary, routed bool) ([]byte, error) {
 	c.nextIDMut.Lock()
 	id := c.nextID
 	c.nextID++
@@ -234,6 +261,7 @@ func (c *rawConnection) Request(folder string, name string, offset int64, size i
 		Size:          int32(size),
 		Hash:          hash,
 		FromTemporary: fromTemporary,
+		Routed:        routed,
 	}, nil)
 	if !ok {
 		return nil, ErrClosed
@@ -268,6 +296,40 @@ func (c *rawConnection) DownloadProgress(folder string, updates []FileDownloadPr
 	}, nil)
 }
 
+// Manage sends the management command to the peer, returning the config it
+// answers with, if any, or the error it gives. It must only be called if
+// the peer announced in its Hello that it understands management messages,
+// as older versions close the connection on messages they don't know.
+func (c *rawConnection) Manage(req ManagementRequest) ([]byte, error) {
+	c.managingMut.Lock()
+	id := c.nextManageID
+	c.nextManageID++
+	if _, ok := c.managing[id]; ok {
+		panic("id taken")
+	}
+	rc := make(chan asyncResult, 1)
+	c.managing[id] = rc
+	c.managingMut.Unlock()
+
+	req.ID = id
+	if ok := c.send(&req, nil); !ok {
+		return nil, ErrClosed
+	}
+
+	select {
+	case res, ok := <-rc:
+		if !ok {
+			return nil, ErrClosed
+		}
+		return res.val, res.err
+	case <-time.After(ManagementTimeout):
+		c.managingMut.Lock()
+		delete(c.managing, id)
+		c.managingMut.Unlock()
+		return nil, ErrTimeout
+	}
+}
+
 func (c *rawConnection) ping() bool {
 	return c.send(&Ping{}, nil)
 }
@@ -297,8 +359,10 @@ func (c *rawConnection) readerLoop() (err error) {
 		switch msg := msg.(type) {
 		case *ClusterConfig:
 			l.Debugln("read ClusterConfig message")
-			if state != stateInitial {
-				return fmt.Errorf("protocol error: cluster config message in state %d", state)
+			// The first cluster config must be a complete one, later ones
+			// may only be deltas to it.
+			if state != stateInitial && !msg.Delta || state == stateInitial && msg.Delta {
+				return fmt.Errorf("protocol error: cluster config message (delta %v) in state %d", msg.Delta, state)
 			}
 			c.receiver.ClusterConfig(c.id, *msg)
 			state = stateReady
@@ -343,6 +407,22 @@ func (c *rawConnection) readerLoop() (err error) {
 			}
 			c.handleResponse(*msg)
 
+		case *ManagementRequest:
+			l.Debugln("read ManagementRequest message")
+			if state != stateReady {
+				return fmt.Errorf("protocol error: management request message in state %d", state)
+			}
+			// Management commands are handled asynchronously, as they may
+			// take a while, e.g. to save the config
+			go c.handleManagementRequest(*msg)
+
+		case *ManagementResponse:
+			l.Debugln("read ManagementResponse message")
+			if state != stateReady {
+				return fmt.Errorf("protocol error: management response message in state %d", state)
+			}
+			c.handleManagementResponse(*msg)
+
 		case *DownloadProgress:
 			l.Debugln("read DownloadProgress message")
 			if state != stateReady {
@@ -410,6 +490,14 @@ func (c *rawConnection) readMessageAfterHeader(hdr Header) (message, error) {
 		}
 		buf = decomp
 
+	case MessageCompressionZstd:
+		decomp, err := zstdDecompress(buf)
+		buffers.put(buf)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing message: %v", err)
+		}
+		buf = decomp
+
 	default:
 		return nil, fmt.Errorf("unknown message compression %d", hdr.Compression)
 	}
@@ -518,7 +606,7 @@ func (c *rawConnection) handleRequest(req Request) {
 		buf = make([]byte, size)
 	}
 
-	err := c.receiver.Request(c.id, req.Folder, req.Name, req.Offset, req.Hash, req.FromTemporary, buf)
+	err := c.receiver.Request(c.id, req.Folder, req.Name, req.Offset, req.Hash, req.FromTemporary, req.Routed, buf)
 	if err != nil {
 		c.send(&Response{
 			ID:   req.ID,
@@ -549,6 +637,31 @@ func (c *rawConnection) handleResponse(resp Response) {
 	c.awaitingMut.Unlock()
 }
 
+func (c *rawConnection) handleManagementRequest(req ManagementRequest) {
+	resp := ManagementResponse{ID: req.ID}
+	config, err := c.receiver.Manage(c.id, req)
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Config = config
+	}
+	c.send(&resp, nil)
+}
+
+func (c *rawConnection) handleManagementResponse(resp ManagementResponse) {
+	var err error
+	if resp.Error != "" {
+		err = errors.New(resp.Error)
+	}
+	c.managingMut.Lock()
+	if rc := c.managing[resp.ID]; rc != nil {
+		delete(c.managing, resp.ID)
+		rc <- asyncResult{resp.Config, err}
+		close(rc)
+	}
+	c.managingMut.Unlock()
+}
+
 func (c *rawConnection) send(msg message, done chan struct{}) bool {
 	select {
 	case c.outbox <- asyncMessage{msg, done}:
@@ -590,15 +703,21 @@ func (c *rawConnection) writeCompressedMessage(hm asyncMessage) error {
 		close(hm.done)
 	}
 
-	compressed, err := c.lz4Compress(buf)
+	hdr := Header{
+		Type: c.typeOf(hm.msg),
+	}
+	var compressed []byte
+	var err error
+	if c.zstdLevel > 0 {
+		hdr.Compression = MessageCompressionZstd
+		compressed, err = zstdCompress(buf, c.zstdLevel)
+	} else {
+		hdr.Compression = MessageCompressionLZ4
+		compressed, err = c.lz4Compress(buf)
+	}
 	if err != nil {
 		return fmt.Errorf("compressing message: %v", err)
 	}
-
-	hdr := Header{
-		Type:        c.typeOf(hm.msg),
-		Compression: MessageCompressionLZ4,
-	}
 	hdrSize := hdr.ProtoSize()
 	if hdrSize > 1<<16-1 {
 		panic("impossibly large header")
@@ -683,6 +802,10 @@ func (c *rawConnection) typeOf(msg message) MessageType {
 		return messageTypeResponse
 	case *DownloadProgress:
 		return messageTypeDownloadProgress
+	case *ManagementRequest:
+		return messageTypeManagementRequest
+	case *ManagementResponse:
+		return messageTypeManagementResponse
 	case *Ping:
 		return messageTypePing
 	case *Close:
@@ -706,6 +829,10 @@ func (c *rawConnection) newMessage(t MessageType) (message, error) {
 		return new(Response), nil
 	case messageTypeDownloadProgress:
 		return new(DownloadProgress), nil
+	case messageTypeManagementRequest:
+		return new(ManagementRequest), nil
+	case messageTypeManagementResponse:
+		return new(ManagementResponse), nil
 	case messageTypePing:
 		return new(Ping), nil
 	case messageTypeClose:
@@ -748,6 +875,13 @@ func (c *rawConnection) close(err error) {
 		}
 		c.awaitingMut.Unlock()
 
+		c.managingMut.Lock()
+		for i, ch := range c.managing {
+			close(ch)
+			delete(c.managing, i)
+		}
+		c.managingMut.Unlock()
+
 		c.receiver.Closed(c, err)
 	})
 }
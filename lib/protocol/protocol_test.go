@@ -28,9 +28,9 @@ func TestPing(t *testing.T) {
 	ar, aw := io.Pipe()
 	br, bw := io.Pipe()
 
-	c0 := NewConnection(c0ID, ar, bw, newTestModel(), "name", CompressAlways).(wireFormatConnection).Connection.(*rawConnection)
+	c0 := NewConnection(c0ID, ar, bw, newTestModel(), "name", CompressAlways, 0).(wireFormatConnection).Connection.(*rawConnection)
 	c0.Start()
-	c1 := NewConnection(c1ID, br, aw, newTestModel(), "name", CompressAlways).(wireFormatConnection).Connection.(*rawConnection)
+	c1 := NewConnection(c1ID, br, aw, newTestModel(), "name", CompressAlways, 0).(wireFormatConnection).Connection.(*rawConnection)
 	c1.Start()
 	c0.ClusterConfig(ClusterConfig{})
 	c1.ClusterConfig(ClusterConfig{})
@@ -50,9 +50,9 @@ func TestClose(t *testing.T) {
 	ar, aw := io.Pipe()
 	br, bw := io.Pipe()
 
-	c0 := NewConnection(c0ID, ar, bw, m0, "name", CompressAlways).(wireFormatConnection).Connection.(*rawConnection)
+	c0 := NewConnection(c0ID, ar, bw, m0, "name", CompressAlways, 0).(wireFormatConnection).Connection.(*rawConnection)
 	c0.Start()
-	c1 := NewConnection(c1ID, br, aw, m1, "name", CompressAlways)
+	c1 := NewConnection(c1ID, br, aw, m1, "name", CompressAlways, 0)
 	c1.Start()
 	c0.ClusterConfig(ClusterConfig{})
 	c1.ClusterConfig(ClusterConfig{})
@@ -73,7 +73,7 @@ func TestClose(t *testing.T) {
 	c0.Index("default", nil)
 	c0.Index("default", nil)
 
-	if _, err := c0.Request("default", "foo", 0, 0, nil, false); err == nil {
+	if _, err := c0.Request("default", "foo", 0, 0, nil, false, false); err == nil {
 		t.Error("Request should return an error")
 	}
 }
@@ -307,6 +307,37 @@ func TestLZ4Compression(t *testing.T) {
 	}
 }
 
+func TestManagement(t *testing.T) {
+	m1 := newTestModel()
+	m1.data = []byte(`{"version": 20}`)
+
+	ar, aw := io.Pipe()
+	br, bw := io.Pipe()
+
+	c0 := NewConnection(c0ID, ar, bw, newTestModel(), "name", CompressAlways, 0)
+	c0.Start()
+	c1 := NewConnection(c1ID, br, aw, m1, "name", CompressAlways, 0)
+	c1.Start()
+	c0.ClusterConfig(ClusterConfig{})
+	c1.ClusterConfig(ClusterConfig{})
+
+	config, err := c0.Manage(ManagementRequest{Command: ManagementGetConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(config, m1.data) {
+		t.Errorf("Incorrect config %q", config)
+	}
+
+	_, err = c0.Manage(ManagementRequest{Command: ManagementPause, Device: c0ID, Reason: "maintenance"})
+	if err == nil || err.Error() != "not allowed" {
+		t.Errorf("Expected the error of the other side, got %v", err)
+	}
+	if len(m1.managed) != 2 || m1.managed[1].Device != c0ID || m1.managed[1].Reason != "maintenance" {
+		t.Errorf("Incorrect commands received %+v", m1.managed)
+	}
+}
+
 func TestCheckFilename(t *testing.T) {
 	cases := []struct {
 		name string
@@ -0,0 +1,10 @@
+// Copyright (C) 2017 The Protocol Authors.
+
+package protocol
+
+// The zstd compression levels are those of the reference implementation,
+// from fastest to best compression. Zero means to use LZ4 instead.
+const (
+	MaxZstdLevel     = 22
+	DefaultZstdLevel = 3
+)
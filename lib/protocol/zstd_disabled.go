@@ -0,0 +1,21 @@
+// Copyright (C) 2017 The Protocol Authors.
+
+// +build !zstd
+
+package protocol
+
+import "errors"
+
+// ZstdSupported is true when built with zstd compression. That takes the
+// zstd build tag, and Go 1.20 for the vendored zstd package.
+const ZstdSupported = false
+
+var errNoZstd = errors.New("zstd compression not supported by this build")
+
+func zstdCompress(src []byte, level int) ([]byte, error) {
+	return nil, errNoZstd
+}
+
+func zstdDecompress(src []byte) ([]byte, error) {
+	return nil, errNoZstd
+}
@@ -0,0 +1,53 @@
+// Copyright (C) 2017 The Protocol Authors.
+
+// +build zstd
+
+package protocol
+
+import (
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ZstdSupported is true when built with zstd compression.
+const ZstdSupported = true
+
+// The encoders and the decoder are safe for concurrent use, so all
+// connections share them.
+var (
+	zstdEncoders    = make(map[int]*zstd.Encoder)
+	zstdEncodersMut sync.Mutex
+	zstdDecoder     *zstd.Decoder
+	zstdDecoderOnce sync.Once
+)
+
+func zstdEncoder(level int) *zstd.Encoder {
+	zstdEncodersMut.Lock()
+	defer zstdEncodersMut.Unlock()
+
+	if enc, ok := zstdEncoders[level]; ok {
+		return enc
+	}
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	if err != nil {
+		panic("creating zstd encoder: " + err.Error())
+	}
+	zstdEncoders[level] = enc
+	return enc
+}
+
+func zstdCompress(src []byte, level int) ([]byte, error) {
+	return zstdEncoder(level).EncodeAll(src, buffers.get(len(src))[:0]), nil
+}
+
+func zstdDecompress(src []byte) ([]byte, error) {
+	zstdDecoderOnce.Do(func() {
+		var err error
+		zstdDecoder, err = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(MaxMessageLen))
+		if err != nil {
+			panic("creating zstd decoder: " + err.Error())
+		}
+	})
+	return zstdDecoder.DecodeAll(src, buffers.get(len(src))[:0])
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2017 The Protocol Authors.
+
+// +build zstd
+
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/rand"
+)
+
+func TestZstdCompression(t *testing.T) {
+	for _, level := range []int{1, DefaultZstdLevel, MaxZstdLevel} {
+		data := bytes.Repeat([]byte("syncthing "), 100)
+		if _, err := io.ReadFull(rand.Reader, data[500:]); err != nil {
+			t.Fatal(err)
+		}
+		comp, err := zstdCompress(data, level)
+		if err != nil {
+			t.Fatalf("compressing at level %d: %v", level, err)
+		}
+		res, err := zstdDecompress(comp)
+		if err != nil {
+			t.Fatalf("decompressing at level %d: %v", level, err)
+		}
+		if !bytes.Equal(data, res) {
+			t.Errorf("Incorrect decompressed data at level %d", level)
+		}
+	}
+}
+
+func TestZstdConnection(t *testing.T) {
+	m1 := newTestModel()
+	m1.data = bytes.Repeat([]byte("syncthing "), 400)
+
+	ar, aw := io.Pipe()
+	br, bw := io.Pipe()
+
+	// c1 compresses its responses with zstd, c0 its requests with LZ4, as
+	// if it were an older version.
+
+	c0 := NewConnection(c0ID, ar, bw, newTestModel(), "name", CompressAlways, 0)
+	c0.Start()
+	c1 := NewConnection(c1ID, br, aw, m1, "name", CompressAlways, DefaultZstdLevel)
+	c1.Start()
+	c0.ClusterConfig(ClusterConfig{})
+	c1.ClusterConfig(ClusterConfig{})
+
+	data, err := c0.Request("default", strings.Repeat("name", 100), 0, len(m1.data), nil, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, m1.data) {
+		t.Error("Incorrect response data")
+	}
+	if m1.name != strings.Repeat("name", 100) {
+		t.Errorf("Incorrect request name %q", m1.name)
+	}
+}
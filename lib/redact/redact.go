@@ -0,0 +1,415 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package redact pseudonymizes paths, names, device IDs and addresses in
+// diagnostics, so that they can be shared without revealing them. The same
+// value is always replaced by the same pseudonym for a given key, so the
+// diagnostics remain useful for following what happens to something.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kinds is a set of kinds of values to redact.
+type Kinds int
+
+const (
+	Paths Kinds = 1 << iota
+	Names
+	DeviceIDs
+	Addresses
+
+	None Kinds = 0
+	All        = Paths | Names | DeviceIDs | Addresses
+)
+
+var kindNames = map[string]Kinds{
+	"paths":     Paths,
+	"names":     Names,
+	"devices":   DeviceIDs,
+	"addresses": Addresses,
+}
+
+// ParseKinds parses a comma separated list of the kinds "paths", "names",
+// "devices" and "addresses". The values "true" and "all" mean all of them,
+// while "false" and the empty string mean none.
+func ParseKinds(s string) (Kinds, error) {
+	switch s {
+	case "", "false":
+		return None, nil
+	case "true", "all":
+		return All, nil
+	}
+	var kinds Kinds
+	for _, name := range strings.Split(s, ",") {
+		kind, ok := kindNames[strings.TrimSpace(name)]
+		if !ok {
+			return None, fmt.Errorf("unknown kind of value to redact %q", name)
+		}
+		kinds |= kind
+	}
+	return kinds, nil
+}
+
+const (
+	// Redacted replaces secrets, which are removed whatever is redacted.
+	Redacted = "[redacted]"
+
+	pseudonymLen = 8 // hex characters
+)
+
+var (
+	deviceIDExp = regexp.MustCompile(`^[A-Z2-7]{7}(-[A-Z2-7]{7}){7}$`)
+	extExp      = regexp.MustCompile(`^\.[A-Za-z0-9]{1,5}$`)
+
+	// The patterns for values found in free text, matched at the same
+	// position in this order after the known paths and names.
+	textPatterns = []string{
+		`"(?:[^"\\]|\\.)*"`,                          // quoted string
+		`\b[A-Z2-7]{7}(?:-[A-Z2-7]{7}){7}\b`,         // device ID
+		`\b\d{1,3}(?:\.\d{1,3}){3}\b`,                // IPv4
+		`[0-9a-fA-F]{0,4}(?::[0-9a-fA-F]{0,4}){2,7}`, // IPv6
+	}
+)
+
+// A Redactor replaces values with pseudonyms derived from its key.
+type Redactor struct {
+	key   []byte
+	kinds Kinds
+	known map[string]Kinds // the known paths and names
+	text  *regexp.Regexp
+}
+
+// New returns a Redactor for the kinds of values, with pseudonyms derived
+// from the key. The paths and names are recognized wherever they appear in
+// text, in addition to what looks like a quoted path, a device ID or an IP
+// address; paths extend to the next space, quote or colon.
+func New(key []byte, kinds Kinds, paths, names []string) *Redactor {
+	r := &Redactor{
+		key:   key,
+		kinds: kinds,
+		known: make(map[string]Kinds),
+	}
+	if kinds&Paths != 0 {
+		for _, path := range paths {
+			if path != "" && path != string(filepath.Separator) {
+				r.known[path] = Paths
+			}
+		}
+	}
+	if kinds&Names != 0 {
+		for _, name := range names {
+			if _, ok := r.known[name]; !ok && name != "" {
+				r.known[name] = Names
+			}
+		}
+	}
+
+	// Longer values first, so that a path is matched in full rather than
+	// as the folder path it's in.
+	known := make([]string, 0, len(r.known))
+	for value := range r.known {
+		known = append(known, value)
+	}
+	sort.Sort(byLengthDesc(known))
+	var alts []string
+	for _, value := range known {
+		alt := regexp.QuoteMeta(value)
+		if r.known[value] == Paths {
+			alt += `[^\s"':]*`
+		}
+		alts = append(alts, alt)
+	}
+	alts = append(alts, textPatterns...)
+	r.text = regexp.MustCompile("(" + strings.Join(alts, ")|(") + ")")
+
+	return r
+}
+
+// Kinds returns the kinds of values redacted.
+func (r *Redactor) Kinds() Kinds {
+	return r.kinds
+}
+
+// Text returns the text with the known paths and names, quoted strings,
+// device IDs and IP addresses in it redacted.
+func (r *Redactor) Text(s string) string {
+	if r.kinds == None {
+		return s
+	}
+
+	var res []byte
+	last := 0
+	for _, m := range r.text.FindAllStringIndex(s, -1) {
+		match := s[m[0]:m[1]]
+		var repl string
+		switch {
+		case strings.HasPrefix(match, `"`) && len(match) > 1:
+			repl = `"` + r.String(match[1:len(match)-1]) + `"`
+		default:
+			repl = r.match(s, m[0], m[1])
+		}
+		res = append(res, s[last:m[0]]...)
+		res = append(res, repl...)
+		last = m[1]
+	}
+	res = append(res, s[last:]...)
+	return string(res)
+}
+
+// match returns the replacement for the match of a known value, a device
+// ID or an IP address at s[start:end].
+func (r *Redactor) match(s string, start, end int) string {
+	match := s[start:end]
+	if kind, ok := r.known[match]; ok && kind == Names {
+		// Names only count as whole words, or they'd mangle the text
+		// around them.
+		if start > 0 && isWordByte(s[start-1]) || end < len(s) && isWordByte(s[end]) {
+			return match
+		}
+		return r.Name(match)
+	}
+	for value, kind := range r.known {
+		if kind == Paths && strings.HasPrefix(match, value) {
+			return r.Path(match)
+		}
+	}
+	if IsDeviceID(match) {
+		return r.DeviceID(match)
+	}
+	if ip := net.ParseIP(match); ip != nil {
+		return r.ip(ip, match)
+	}
+	return match
+}
+
+// String returns the redacted value, whichever kind it appears to be:
+// a device ID, an IP address, a known name or otherwise a path.
+func (r *Redactor) String(s string) string {
+	if IsDeviceID(s) {
+		return r.DeviceID(s)
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return r.ip(ip, s)
+	}
+	if kind, ok := r.known[s]; ok && kind == Names {
+		return r.Name(s)
+	}
+	return r.Path(s)
+}
+
+// Path returns the path with each of its elements pseudonymized, except for
+// the separators, the volume name, "." and "..", the files internal to
+// Syncthing and the extensions of files.
+func (r *Redactor) Path(p string) string {
+	if r.kinds&Paths == 0 || p == "" {
+		return p
+	}
+	vol := filepath.VolumeName(p)
+	elems := strings.FieldsFunc(p[len(vol):], isSeparator)
+	res := []byte(vol)
+	rest := p[len(vol):]
+	for _, elem := range elems {
+		i := strings.Index(rest, elem)
+		res = append(res, rest[:i]...)
+		res = append(res, r.component(elem)...)
+		rest = rest[i+len(elem):]
+	}
+	res = append(res, rest...)
+	return string(res)
+}
+
+// Name returns the pseudonym of a name, such as the label or ID of a folder
+// or the name of a device. Names are pseudonymized like the elements of a
+// path, so a folder labeled after its directory gets the same pseudonym in
+// both places.
+func (r *Redactor) Name(s string) string {
+	if r.kinds&Names == 0 || s == "" {
+		return s
+	}
+	if IsDeviceID(s) {
+		return r.DeviceID(s)
+	}
+	return r.component(s)
+}
+
+// DeviceID returns the pseudonym of a device ID.
+func (r *Redactor) DeviceID(id string) string {
+	if r.kinds&DeviceIDs == 0 || id == "" {
+		return id
+	}
+	return "device-" + r.pseudonym("device", id)
+}
+
+// Address returns the address, a URL or a host and port, with the host
+// pseudonymized. Device IDs in the query of a URL are pseudonymized too.
+func (r *Redactor) Address(a string) string {
+	if r.kinds&Addresses == 0 || a == "" || a == "dynamic" || a == "default" {
+		return a
+	}
+	if !strings.Contains(a, "://") {
+		return r.hostPort(a)
+	}
+	u, err := url.Parse(a)
+	if err != nil {
+		return r.Text(a)
+	}
+	u.Host = r.hostPort(u.Host)
+	u.RawQuery = r.Text(u.RawQuery)
+	u.User = nil
+	return u.String()
+}
+
+func (r *Redactor) hostPort(hostPort string) string {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return r.host(hostPort)
+	}
+	return net.JoinHostPort(r.host(host), port)
+}
+
+func (r *Redactor) host(host string) string {
+	if host == "" {
+		return host
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return r.ip(ip, host)
+	}
+	return "host-" + r.pseudonym("host", strings.ToLower(host))
+}
+
+func (r *Redactor) ip(ip net.IP, s string) string {
+	if r.kinds&Addresses == 0 || ip.IsLoopback() || ip.IsUnspecified() {
+		return s
+	}
+	return "ip-" + r.pseudonym("ip", ip.String())
+}
+
+// component returns the pseudonym of a path element or a name, keeping its
+// extension.
+func (r *Redactor) component(s string) string {
+	if s == "." || s == ".." || strings.HasPrefix(s, ".st") || strings.HasPrefix(s, "~syncthing~") {
+		return s
+	}
+	ext := filepath.Ext(s)
+	if ext == s || !extExp.MatchString(ext) {
+		ext = ""
+	}
+	return r.pseudonym("name", s[:len(s)-len(ext)]) + ext
+}
+
+func (r *Redactor) pseudonym(kind, value string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:pseudonymLen]
+}
+
+// Value returns the value, as would be marshalled to JSON, with the strings
+// in it redacted according to what they're keyed by. Secrets such as
+// passwords and API keys are always replaced by Redacted.
+func (r *Redactor) Value(v interface{}) (interface{}, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(bs)))
+	dec.UseNumber()
+	var res interface{}
+	if err := dec.Decode(&res); err != nil {
+		return nil, err
+	}
+	return r.value("", res), nil
+}
+
+func (r *Redactor) value(key string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			res[r.mapKey(k)] = r.value(k, val)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, val := range v {
+			res[i] = r.value(key, val)
+		}
+		return res
+	case string:
+		return r.keyed(key, v)
+	default:
+		return v
+	}
+}
+
+// mapKey returns the redacted map key; device IDs and known names are
+// replaced, everything else is kept as field names and the like.
+func (r *Redactor) mapKey(k string) string {
+	if IsDeviceID(k) {
+		return r.DeviceID(k)
+	}
+	if r.known[k] == Names {
+		return r.Name(k)
+	}
+	if r.known[k] == Paths {
+		return r.Path(k)
+	}
+	return k
+}
+
+func (r *Redactor) keyed(key, s string) string {
+	lower := strings.ToLower(key)
+	switch {
+	case s == "":
+		return s
+	case strings.Contains(lower, "password") || strings.Contains(lower, "secret") || lower == "apikey" || lower == "user" || lower == "token":
+		return Redacted
+	case IsDeviceID(s):
+		return r.DeviceID(s)
+	}
+	switch lower {
+	case "path", "item", "file", "filename", "directory", "dir", "home":
+		return r.Path(s)
+	case "name", "label", "devicename", "folderlabel", "folder", "id":
+		return r.Name(s)
+	case "address", "addresses", "addr", "listenaddress", "listenaddresses", "remoteaddress", "localaddress":
+		return r.Address(s)
+	}
+	return r.Text(s)
+}
+
+// IsDeviceID returns whether the string is a device ID in its canonical
+// form.
+func IsDeviceID(s string) bool {
+	return deviceIDExp.MatchString(s)
+}
+
+func isSeparator(c rune) bool {
+	return c == '/' || c == '\\'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+type byLengthDesc []string
+
+func (s byLengthDesc) Len() int           { return len(s) }
+func (s byLengthDesc) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byLengthDesc) Less(i, j int) bool { return len(s[i]) > len(s[j]) }
@@ -0,0 +1,186 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDeviceID = "AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR"
+
+func TestParseKinds(t *testing.T) {
+	cases := []struct {
+		in    string
+		kinds Kinds
+		ok    bool
+	}{
+		{"", None, true},
+		{"false", None, true},
+		{"true", All, true},
+		{"all", All, true},
+		{"paths", Paths, true},
+		{"devices, addresses", DeviceIDs | Addresses, true},
+		{"paths,colors", None, false},
+	}
+	for _, tc := range cases {
+		kinds, err := ParseKinds(tc.in)
+		if (err == nil) != tc.ok || kinds != tc.kinds {
+			t.Errorf("ParseKinds(%q) = %v, %v; want %v, ok %v", tc.in, kinds, err, tc.kinds, tc.ok)
+		}
+	}
+}
+
+func TestPseudonymsAreConsistent(t *testing.T) {
+	r := New([]byte("key"), All, nil, nil)
+
+	if r.Path("/home/user/Photos") != r.Path("/home/user/Photos") {
+		t.Error("path pseudonyms differ between calls")
+	}
+	if p := r.Path("/home/user/Photos"); !strings.HasSuffix(p, "/"+r.Name("Photos")) {
+		t.Errorf("path %q should end in the pseudonym of the name %q", p, r.Name("Photos"))
+	}
+	if r.Path("/home/user/a.jpg") == r.Path("/home/user/b.jpg") {
+		t.Error("different paths should get different pseudonyms")
+	}
+
+	other := New([]byte("other key"), All, nil, nil)
+	if r.DeviceID(testDeviceID) == other.DeviceID(testDeviceID) {
+		t.Error("pseudonyms should depend on the key")
+	}
+}
+
+func TestPath(t *testing.T) {
+	r := New([]byte("key"), All, nil, nil)
+
+	p := r.Path("/home/user/../Sync/.stfolder/photo.jpg")
+	parts := strings.Split(p, "/")
+	if len(parts) != 7 || parts[0] != "" || parts[3] != ".." || parts[5] != ".stfolder" {
+		t.Fatalf("unexpected redacted path %q", p)
+	}
+	for _, i := range []int{1, 2, 4} {
+		if len(parts[i]) != pseudonymLen {
+			t.Errorf("element %d of %q is not a pseudonym", i, p)
+		}
+	}
+	if !strings.HasSuffix(parts[6], ".jpg") || strings.Contains(parts[6], "photo") {
+		t.Errorf("file name %q should keep only its extension", parts[6])
+	}
+
+	if p := New([]byte("key"), Names, nil, nil).Path("/home/user"); p != "/home/user" {
+		t.Errorf("paths should be kept unless redacted, got %q", p)
+	}
+}
+
+func TestText(t *testing.T) {
+	r := New([]byte("key"), All, []string{"/home/user/Sync"}, []string{"default", "laptop"})
+
+	in := `Puller (folder "default", file "docs/secret plan.txt"): pull: connecting to ` + testDeviceID +
+		` at 192.168.1.12:22000 and [fe80::1]:22000 for /home/user/Sync/docs/a.txt: no route; 127.0.0.1 default laptops 15:04:05`
+	out := r.Text(in)
+
+	for _, leak := range []string{`"default"`, "secret", "docs", testDeviceID, "192.168.1.12", "fe80::1", "/home/user/Sync"} {
+		if strings.Contains(out, leak) {
+			t.Errorf("%q leaks through in %q", leak, out)
+		}
+	}
+	for _, kept := range []string{"Puller (folder ", ".txt", r.DeviceID(testDeviceID), ":22000", "127.0.0.1", "laptops", "15:04:05", ": no route"} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("%q missing from %q", kept, out)
+		}
+	}
+	if out != r.Text(in) {
+		t.Error("redacting the same text twice should give the same result")
+	}
+
+	if out := New(nil, None, nil, nil).Text(in); out != in {
+		t.Errorf("nothing should be redacted, got %q", out)
+	}
+}
+
+func TestAddress(t *testing.T) {
+	r := New([]byte("key"), All, nil, nil)
+
+	cases := []struct {
+		in       string
+		hidden   string
+		contains string
+	}{
+		{"tcp://192.168.1.12:22000", "192.168.1.12", "tcp://ip-"},
+		{"relay://relay.example.com:443/?id=" + testDeviceID + "&pingInterval=1m0s", "example", "pingInterval=1m0s"},
+		{"relay://relay.example.com:443/?id=" + testDeviceID, testDeviceID, "?id=device-"},
+		{"nas.local:22000", "nas", ":22000"},
+		{"dynamic", "", "dynamic"},
+	}
+	for _, tc := range cases {
+		out := r.Address(tc.in)
+		if tc.hidden != "" && strings.Contains(out, tc.hidden) {
+			t.Errorf("Address(%q) = %q, leaks %q", tc.in, out, tc.hidden)
+		}
+		if !strings.Contains(out, tc.contains) {
+			t.Errorf("Address(%q) = %q, missing %q", tc.in, out, tc.contains)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	r := New([]byte("key"), All, nil, []string{"photos"})
+
+	in := map[string]interface{}{
+		"folder":   "photos",
+		"path":     "/home/user/Photos",
+		"password": "hunter2",
+		"apiKey":   "abc123",
+		"secret":   "s3cr3t",
+		"device":   testDeviceID,
+		"address":  "tcp://10.0.0.2:22000",
+		"type":     "StateChanged",
+		"size":     1234,
+		"devices": map[string]interface{}{
+			testDeviceID: map[string]interface{}{"name": "laptop"},
+		},
+	}
+	v, err := r.Value(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := v.(map[string]interface{})
+
+	if out["folder"] != r.Name("photos") {
+		t.Errorf("folder %v not redacted as a name", out["folder"])
+	}
+	if out["path"] != r.Path("/home/user/Photos") {
+		t.Errorf("path %v not redacted as a path", out["path"])
+	}
+	if out["password"] != Redacted || out["apiKey"] != Redacted || out["secret"] != Redacted {
+		t.Error("secrets should be removed")
+	}
+	if out["device"] != r.DeviceID(testDeviceID) {
+		t.Errorf("device %v not redacted", out["device"])
+	}
+	if out["address"] != r.Address("tcp://10.0.0.2:22000") {
+		t.Errorf("address %v not redacted", out["address"])
+	}
+	if out["type"] != "StateChanged" || out["size"].(interface{ String() string }).String() != "1234" {
+		t.Error("other values should be kept")
+	}
+	devices := out["devices"].(map[string]interface{})
+	dev, ok := devices[r.DeviceID(testDeviceID)].(map[string]interface{})
+	if !ok || dev["name"] != r.Name("laptop") {
+		t.Errorf("device map not redacted: %v", devices)
+	}
+
+	// Secrets go even when nothing else is redacted.
+	v, err = New(nil, None, nil, nil).Value(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = v.(map[string]interface{})
+	if out["password"] != Redacted || out["folder"] != "photos" {
+		t.Errorf("unexpected value %v", out)
+	}
+}
@@ -7,10 +7,15 @@
 package scanner
 
 import (
+	"bufio"
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
 
+	"github.com/chmduquesne/rollinghash/adler32"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/sync"
 )
@@ -20,13 +25,13 @@ import (
 // workers are used in parallel. The outbox will become closed when the inbox
 // is closed and all items handled.
 
-func newParallelHasher(dir string, blockSize, workers int, outbox, inbox chan protocol.FileInfo, counter Counter, done, cancel chan struct{}, useWeakHashes bool) {
+func newParallelHasher(dir string, minBlockSize, workers int, outbox, inbox chan protocol.FileInfo, counter Counter, done, cancel chan struct{}, useWeakHashes bool, throttle *fs.IOThrottle) {
 	wg := sync.NewWaitGroup()
 	wg.Add(workers)
 
 	for i := 0; i < workers; i++ {
 		go func() {
-			hashFiles(dir, blockSize, outbox, inbox, counter, cancel, useWeakHashes)
+			hashFiles(dir, minBlockSize, outbox, inbox, counter, cancel, useWeakHashes, throttle)
 			wg.Done()
 		}()
 	}
@@ -42,6 +47,14 @@ func newParallelHasher(dir string, blockSize, workers int, outbox, inbox chan pr
 
 // HashFile hashes the files and returns a list of blocks representing the file.
 func HashFile(path string, blockSize int, counter Counter, useWeakHashes bool) ([]protocol.BlockInfo, error) {
+	return hashFile(path, blockSize, nil, counter, useWeakHashes, nil)
+}
+
+// hashFile is like HashFile, but if the file consists of the complete blocks
+// of prev followed by more data, as when it has been appended to, those
+// blocks are kept after checking their weak hashes, and only the rest of the
+// file is hashed. Reading the file is limited by the throttle, if any.
+func hashFile(path string, blockSize int, prev []protocol.BlockInfo, counter Counter, useWeakHashes bool, throttle *fs.IOThrottle) ([]protocol.BlockInfo, error) {
 	fd, err := os.Open(path)
 	if err != nil {
 		l.Debugln("open:", err)
@@ -59,13 +72,45 @@ func HashFile(path string, blockSize int, counter Counter, useWeakHashes bool) (
 	size := fi.Size()
 	modTime := fi.ModTime()
 
-	// Hash the file. This may take a while for large files.
+	// Hash the file. This may take a while for large files. Large files are
+	// read through a memory mapping when possible, saving a copy and a lot
+	// of syscalls.
 
-	blocks, err := Blocks(fd, blockSize, size, counter, useWeakHashes)
+	var ra io.ReaderAt = fd
+	if m := osutil.MapFileOrNil(fd, size, osutil.MmapSequential); m != nil {
+		defer m.Close()
+		ra = m
+	}
+	ra = throttle.ReaderAt(ra)
+
+	var blocks []protocol.BlockInfo
+	var offset int64
+	if n := appendedBlocks(ra, blockSize, size, prev); n > 0 {
+		l.Debugf("appended to: %s, keeping %d blocks", path, n)
+		blocks = make([]protocol.BlockInfo, n)
+		copy(blocks, prev)
+		PopulateOffsets(blocks)
+		offset = int64(n) * int64(blockSize)
+		if counter != nil {
+			counter.Update(offset)
+		}
+	}
+
+	var r io.Reader = io.NewSectionReader(ra, offset, size-offset)
+	if throttle != nil {
+		// A read per block, rather than per copy buffer, is what counts
+		// as an operation.
+		r = bufio.NewReaderSize(r, blockSize)
+	}
+	rest, err := Blocks(r, blockSize, size-offset, counter, useWeakHashes)
 	if err != nil {
 		l.Debugln("blocks:", err)
 		return nil, err
 	}
+	for i := range rest {
+		rest[i].Offset += offset
+	}
+	blocks = append(blocks, rest...)
 
 	// Recheck the size and modtime again. If they differ, the file changed
 	// while we were reading it and our hash results are invalid.
@@ -82,7 +127,7 @@ func HashFile(path string, blockSize int, counter Counter, useWeakHashes bool) (
 	return blocks, nil
 }
 
-func hashFiles(dir string, blockSize int, outbox, inbox chan protocol.FileInfo, counter Counter, cancel chan struct{}, useWeakHashes bool) {
+func hashFiles(dir string, minBlockSize int, outbox, inbox chan protocol.FileInfo, counter Counter, cancel chan struct{}, useWeakHashes bool, throttle *fs.IOThrottle) {
 	for {
 		select {
 		case f, ok := <-inbox:
@@ -94,13 +139,20 @@ func hashFiles(dir string, blockSize int, outbox, inbox chan protocol.FileInfo,
 				panic("Bug. Asked to hash a directory or a deleted file.")
 			}
 
-			blocks, err := HashFile(filepath.Join(dir, f.Name), blockSize, counter, useWeakHashes)
+			// Any blocks are those the file had before it grew; see
+			// Config.DetectAppends.
+			blockSize := protocol.BlockSizeFor(f.Size, minBlockSize)
+			blocks, err := hashFile(filepath.Join(dir, f.Name), blockSize, f.Blocks, counter, useWeakHashes, throttle)
 			if err != nil {
 				l.Debugln("hash error:", f.Name, err)
 				continue
 			}
 
 			f.Blocks = blocks
+			f.RawBlockSize = 0
+			if blockSize != protocol.BlockSize {
+				f.RawBlockSize = int32(blockSize)
+			}
 
 			// The size we saw when initially deciding to hash the file
 			// might not have been the size it actually had when we hashed
@@ -122,3 +174,31 @@ func hashFiles(dir string, blockSize int, outbox, inbox chan protocol.FileInfo,
 		}
 	}
 }
+
+// appendedBlocks returns the number of complete blocks at the start of prev
+// that the file of the given size starts with, as far as their weak hashes
+// tell, provided the file has more data after them. Otherwise it returns
+// zero.
+func appendedBlocks(ra io.ReaderAt, blockSize int, size int64, prev []protocol.BlockInfo) int {
+	n := 0
+	for n < len(prev) && prev[n].Size == int32(blockSize) && prev[n].WeakHash != 0 {
+		n++
+	}
+	if n == 0 || int64(n)*int64(blockSize) >= size {
+		return 0
+	}
+
+	buf := make([]byte, blockSize)
+	whf := adler32.New()
+	for i := 0; i < n; i++ {
+		if _, err := ra.ReadAt(buf, int64(i)*int64(blockSize)); err != nil {
+			return 0
+		}
+		whf.Reset()
+		whf.Write(buf)
+		if whf.Sum32() != prev[i].WeakHash {
+			return 0
+		}
+	}
+	return n
+}
@@ -111,6 +111,26 @@ func PopulateOffsets(blocks []protocol.BlockInfo) {
 	}
 }
 
+// AppendedPrefix returns the number of complete blocks of src that tgt
+// starts with, provided tgt has more blocks after them, as when the file src
+// describes has been appended to. Otherwise it returns zero. Both block
+// lists must have been created with the given block size.
+func AppendedPrefix(src, tgt []protocol.BlockInfo, blockSize int) int {
+	n := 0
+	for n < len(src) && src[n].Size == int32(blockSize) {
+		n++
+	}
+	if n == 0 || n >= len(tgt) {
+		return 0
+	}
+	for i := 0; i < n; i++ {
+		if !bytes.Equal(src[i].Hash, tgt[i].Hash) {
+			return 0
+		}
+	}
+	return n
+}
+
 // BlockDiff returns lists of common and missing (to transform src into tgt)
 // blocks. Both block lists must have been created with the same block size.
 func BlockDiff(src, tgt []protocol.BlockInfo) (have, need []protocol.BlockInfo) {
@@ -165,6 +165,32 @@ func TestDiffEmpty(t *testing.T) {
 	}
 }
 
+func TestAppendedPrefix(t *testing.T) {
+	full := func(hash byte) protocol.BlockInfo {
+		return protocol.BlockInfo{Size: 4, Hash: []byte{hash}}
+	}
+	partial := func(hash byte) protocol.BlockInfo {
+		return protocol.BlockInfo{Size: 2, Hash: []byte{hash}}
+	}
+
+	cases := []struct {
+		src, tgt []protocol.BlockInfo
+		prefix   int
+	}{
+		{[]protocol.BlockInfo{full(1), full(2)}, []protocol.BlockInfo{full(1), full(2), partial(3)}, 2},
+		{[]protocol.BlockInfo{full(1), partial(2)}, []protocol.BlockInfo{full(1), full(3), full(4)}, 1},
+		{[]protocol.BlockInfo{full(1), full(2)}, []protocol.BlockInfo{full(1), full(5), partial(3)}, 0},
+		{[]protocol.BlockInfo{full(1), full(2)}, []protocol.BlockInfo{full(1), full(2)}, 0},
+		{[]protocol.BlockInfo{partial(1)}, []protocol.BlockInfo{full(1), partial(2)}, 0},
+	}
+
+	for i, tc := range cases {
+		if prefix := AppendedPrefix(tc.src, tc.tgt, 4); prefix != tc.prefix {
+			t.Errorf("%d: AppendedPrefix = %d, expected %d", i, prefix, tc.prefix)
+		}
+	}
+}
+
 func TestAdler32Variants(t *testing.T) {
 	// Verify that the two adler32 functions give matching results for a few
 	// different blocks of data.
@@ -0,0 +1,111 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package scanner
+
+import (
+	"os"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// Checkpoints keeps how far a walk has got, as the position in walk order
+// up to which everything found has been received from the walker. Files
+// being hashed are received out of order, so the position trails behind
+// the first of them still pending.
+type Checkpoints struct {
+	mut     sync.Mutex
+	passed  string         // the last path the walker is done with
+	pending []pendingEntry // sent on, in walk order, from the first not received
+	index   map[string]int // into pending, offset by dropped
+	dropped int
+}
+
+type pendingEntry struct {
+	name     string
+	before   string // the position before it
+	received bool
+}
+
+func NewCheckpoints() *Checkpoints {
+	return &Checkpoints{
+		mut:   sync.NewMutex(),
+		index: make(map[string]int),
+	}
+}
+
+// Position returns the path, relative to the walked directory, up to and
+// including which everything has been received, or "" if nothing has yet.
+func (c *Checkpoints) Position() string {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if len(c.pending) > 0 {
+		return c.pending[0].before
+	}
+	return c.passed
+}
+
+// pass records that the walker is done with the path, as it has been sent
+// on or needn't be.
+func (c *Checkpoints) pass(relPath string) {
+	if c == nil {
+		return
+	}
+	c.mut.Lock()
+	c.passed = relPath
+	c.mut.Unlock()
+}
+
+// sending records that the file is about to be sent on.
+func (c *Checkpoints) sending(name string) {
+	if c == nil {
+		return
+	}
+	c.mut.Lock()
+	c.index[name] = c.dropped + len(c.pending)
+	c.pending = append(c.pending, pendingEntry{name: name, before: c.passed})
+	c.mut.Unlock()
+}
+
+// received records that the file has been received from the walker.
+func (c *Checkpoints) received(name string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	i, ok := c.index[name]
+	if !ok {
+		return
+	}
+	c.pending[i-c.dropped].received = true
+	for len(c.pending) > 0 && c.pending[0].received {
+		delete(c.index, c.pending[0].name)
+		c.pending = c.pending[1:]
+		c.dropped++
+	}
+}
+
+// compareWalkOrder returns whether the relative path a comes before (-1),
+// is (0) or comes after (1) the path b in the order filepath.Walk visits
+// them: by name within each directory, a directory before its contents.
+func compareWalkOrder(a, b string) int {
+	as := strings.Split(a, string(os.PathSeparator))
+	bs := strings.Split(b, string(os.PathSeparator))
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		switch {
+		case as[i] < bs[i]:
+			return -1
+		case as[i] > bs[i]:
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
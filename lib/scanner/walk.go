@@ -11,12 +11,14 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
 	"github.com/rcrowley/go-metrics"
 	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/ignore"
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
@@ -45,7 +47,13 @@ type Config struct {
 	Dir string
 	// Limit walking to these paths within Dir, or no limit if Sub is empty
 	Subs []string
-	// BlockSize controls the size of the block used when hashing.
+	// If Changes is not nil, only these paths within Dir are scanned, as
+	// told by the filesystem's change journal, rather than walking Dir or
+	// Subs. A changed directory is scanned by itself, not its contents,
+	// unless it's new to us.
+	Changes []string
+	// BlockSize controls the size of the block used when hashing. Larger
+	// files use larger blocks, up to the standard block size.
 	BlockSize int
 	// If Matcher is not nil, it is used to identify files to ignore which were specified by the user.
 	Matcher *ignore.Matcher
@@ -66,6 +74,9 @@ type Config struct {
 	Hashers int
 	// Our vector clock id
 	ShortID protocol.ShortID
+	// How far our clock is ahead of those of the other devices, recorded
+	// with the changes found so that conflicts are resolved despite it.
+	ClockSkew time.Duration
 	// Optional progress tick interval which defines how often FolderScanProgress
 	// events are emitted. Negative number means disabled.
 	ProgressTickIntervalS int
@@ -73,6 +84,25 @@ type Config struct {
 	Cancel chan struct{}
 	// Wether or not we should also compute weak hashes
 	UseWeakHashes bool
+	// If DetectAppends is true, files that grew are checked for having
+	// been appended to, by comparing the weak hashes of the complete
+	// blocks they had with the data now in their place. If all match,
+	// those blocks are kept and only the rest of the file is hashed. This
+	// is much cheaper for large, growing files such as logs, at the cost
+	// of trusting the weak hash.
+	DetectAppends bool
+	// If Throttle is not nil, reading files to hash them is limited by it.
+	Throttle *fs.IOThrottle
+	// If Resume is set, a walk of the whole of Dir skips the paths up to
+	// and including it in walk order, as an earlier walk got that far.
+	Resume string
+	// If Checkpoints is not nil, it keeps how far a walk of the whole of
+	// Dir has got, to Resume from later.
+	Checkpoints *Checkpoints
+	// If HashWhileWalking is true, files are hashed as they are found
+	// rather than once the walk is done, so the first results come sooner.
+	// The total in the progress events then grows as the walk goes on.
+	HashWhileWalking bool
 }
 
 type CurrentFiler interface {
@@ -112,14 +142,26 @@ func (w *walker) walk() (chan protocol.FileInfo, error) {
 
 	toHashChan := make(chan protocol.FileInfo)
 	finishedChan := make(chan protocol.FileInfo)
+	resultChan := finishedChan
+	if w.Checkpoints != nil {
+		// Relay the results, to know when each has been received.
+		resultChan = make(chan protocol.FileInfo)
+		go w.relayResults(finishedChan, resultChan)
+		w.Checkpoints.pass(w.Resume)
+	}
 
 	// A routine which walks the filesystem tree, and sends files which have
 	// been modified to the counter routine.
 	go func() {
 		hashFiles := w.walkAndHashFiles(toHashChan, finishedChan)
-		if len(w.Subs) == 0 {
+		switch {
+		case w.Changes != nil:
+			for _, name := range w.Changes {
+				w.walkChange(name, hashFiles)
+			}
+		case len(w.Subs) == 0:
 			filepath.Walk(w.Dir, hashFiles)
-		} else {
+		default:
 			for _, sub := range w.Subs {
 				filepath.Walk(filepath.Join(w.Dir, sub), hashFiles)
 			}
@@ -130,8 +172,8 @@ func (w *walker) walk() (chan protocol.FileInfo, error) {
 	// We're not required to emit scan progress events, just kick off hashers,
 	// and feed inputs directly from the walker.
 	if w.ProgressTickIntervalS < 0 {
-		newParallelHasher(w.Dir, w.BlockSize, w.Hashers, finishedChan, toHashChan, nil, nil, w.Cancel, w.UseWeakHashes)
-		return finishedChan, nil
+		newParallelHasher(w.Dir, w.BlockSize, w.Hashers, finishedChan, toHashChan, nil, nil, w.Cancel, w.UseWeakHashes, w.Throttle)
+		return resultChan, nil
 	}
 
 	// Defaults to every 2 seconds.
@@ -148,48 +190,40 @@ func (w *walker) walk() (chan protocol.FileInfo, error) {
 	// until a stop signal is sent by the parallel hasher.
 	// Parallel hasher is stopped by this routine when we close the channel over
 	// which it receives the files we ask it to hash.
+	// When hashing while walking, the files are passed on as they come and
+	// the total grows meanwhile.
 	go func() {
 		var filesToHash []protocol.FileInfo
-		var total int64 = 1
-
-		for file := range toHashChan {
-			filesToHash = append(filesToHash, file)
-			total += file.Size
-		}
+		var total int64 = 1 // accessed atomically
 
 		realToHashChan := make(chan protocol.FileInfo)
 		done := make(chan struct{})
 		progress := newByteCounter()
 
-		newParallelHasher(w.Dir, w.BlockSize, w.Hashers, finishedChan, realToHashChan, progress, done, w.Cancel, w.UseWeakHashes)
-
-		// A routine which actually emits the FolderScanProgress events
-		// every w.ProgressTicker ticks, until the hasher routines terminate.
-		go func() {
-			defer progress.Close()
+		if w.HashWhileWalking {
+			newParallelHasher(w.Dir, w.BlockSize, w.Hashers, finishedChan, realToHashChan, progress, done, w.Cancel, w.UseWeakHashes, w.Throttle)
+			go w.emitProgress(progress, &total, done, ticker)
 
-			for {
+		stream:
+			for file := range toHashChan {
+				atomic.AddInt64(&total, file.Size)
 				select {
-				case <-done:
-					l.Debugln("Walk progress done", w.Dir, w.Subs, w.BlockSize, w.Matcher)
-					ticker.Stop()
-					return
-				case <-ticker.C:
-					current := progress.Total()
-					rate := progress.Rate()
-					l.Debugf("Walk %s %s current progress %d/%d at %.01f MiB/s (%d%%)", w.Dir, w.Subs, current, total, rate/1024/1024, current*100/total)
-					events.Default.Log(events.FolderScanProgress, map[string]interface{}{
-						"folder":  w.Folder,
-						"current": current,
-						"total":   total,
-						"rate":    rate, // bytes per second
-					})
+				case realToHashChan <- file:
 				case <-w.Cancel:
-					ticker.Stop()
-					return
+					break stream
 				}
 			}
-		}()
+			close(realToHashChan)
+			return
+		}
+
+		for file := range toHashChan {
+			filesToHash = append(filesToHash, file)
+			total += file.Size
+		}
+
+		newParallelHasher(w.Dir, w.BlockSize, w.Hashers, finishedChan, realToHashChan, progress, done, w.Cancel, w.UseWeakHashes, w.Throttle)
+		go w.emitProgress(progress, &total, done, ticker)
 
 	loop:
 		for _, file := range filesToHash {
@@ -203,7 +237,49 @@ func (w *walker) walk() (chan protocol.FileInfo, error) {
 		close(realToHashChan)
 	}()
 
-	return finishedChan, nil
+	return resultChan, nil
+}
+
+// emitProgress emits the FolderScanProgress events every tick of the
+// ticker, until the hasher routines terminate.
+func (w *walker) emitProgress(progress *byteCounter, total *int64, done chan struct{}, ticker *time.Ticker) {
+	defer progress.Close()
+
+	for {
+		select {
+		case <-done:
+			l.Debugln("Walk progress done", w.Dir, w.Subs, w.BlockSize, w.Matcher)
+			ticker.Stop()
+			return
+		case <-ticker.C:
+			current := progress.Total()
+			rate := progress.Rate()
+			total := atomic.LoadInt64(total)
+			l.Debugf("Walk %s %s current progress %d/%d at %.01f MiB/s (%d%%)", w.Dir, w.Subs, current, total, rate/1024/1024, current*100/total)
+			events.Default.Log(events.FolderScanProgress, map[string]interface{}{
+				"folder":  w.Folder,
+				"current": current,
+				"total":   total,
+				"rate":    rate, // bytes per second
+			})
+		case <-w.Cancel:
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+// relayResults passes the results on, recording each as received.
+func (w *walker) relayResults(in <-chan protocol.FileInfo, out chan<- protocol.FileInfo) {
+	defer close(out)
+	for f := range in {
+		select {
+		case out <- f:
+			w.Checkpoints.received(f.Name)
+		case <-w.Cancel:
+			return
+		}
+	}
 }
 
 func (w *walker) walkAndHashFiles(fchan, dchan chan protocol.FileInfo) filepath.WalkFunc {
@@ -232,6 +308,23 @@ func (w *walker) walkAndHashFiles(fchan, dchan chan protocol.FileInfo) filepath.
 			return nil
 		}
 
+		if w.Resume != "" {
+			switch compareWalkOrder(relPath, w.Resume) {
+			case -1:
+				if strings.HasPrefix(w.Resume, relPath+string(os.PathSeparator)) {
+					// Holds the rest of the earlier walk.
+					return nil
+				}
+				return skip
+			case 0:
+				// Done, but not the contents if it's a directory.
+				return nil
+			default:
+				w.Resume = ""
+			}
+		}
+		walkedPath := relPath
+
 		info, err = w.Lstater.Lstat(absPath)
 		// An error here would be weird as we've already gotten to this point, but act on it nonetheless
 		if err != nil {
@@ -252,7 +345,7 @@ func (w *walker) walkAndHashFiles(fchan, dchan chan protocol.FileInfo) filepath.
 			return skip
 		}
 
-		if w.Matcher.Match(relPath).IsIgnored() {
+		if w.Matcher.MatchInfo(relPath, info).IsIgnored() {
 			l.Debugln("ignored (patterns):", relPath)
 			return skip
 		}
@@ -272,6 +365,7 @@ func (w *walker) walkAndHashFiles(fchan, dchan chan protocol.FileInfo) filepath.
 			if err := w.walkSymlink(absPath, relPath, dchan); err != nil {
 				return err
 			}
+			w.Checkpoints.pass(walkedPath)
 			if info.IsDir() {
 				// under no circumstances shall we descend into a symlink
 				return filepath.SkipDir
@@ -282,14 +376,35 @@ func (w *walker) walkAndHashFiles(fchan, dchan chan protocol.FileInfo) filepath.
 			err = w.walkDir(relPath, info, dchan)
 
 		case info.Mode().IsRegular():
-			err = w.walkRegular(relPath, info, fchan)
+			err = w.walkRegular(relPath, info, fchan, dchan)
 		}
 
+		if err == nil {
+			w.Checkpoints.pass(walkedPath)
+		}
 		return err
 	}
 }
 
-func (w *walker) walkRegular(relPath string, info os.FileInfo, fchan chan protocol.FileInfo) error {
+// walkChange scans a changed path, and its contents if it's a directory we
+// haven't seen before, such as one just moved into place. A path that no
+// longer exists is skipped; finding deleted files is up to the caller.
+func (w *walker) walkChange(name string, walkFn filepath.WalkFunc) {
+	absPath := filepath.Join(w.Dir, name)
+	info, err := w.Lstater.Lstat(absPath)
+	if err != nil {
+		return
+	}
+	if info.IsDir() {
+		if cf, ok := w.CurrentFiler.CurrentFile(name); !ok || cf.IsDeleted() || !cf.IsDirectory() {
+			filepath.Walk(absPath, walkFn)
+			return
+		}
+	}
+	walkFn(absPath, info, nil)
+}
+
+func (w *walker) walkRegular(relPath string, info os.FileInfo, fchan, dchan chan protocol.FileInfo) error {
 	curMode := uint32(info.Mode())
 	if runtime.GOOS == "windows" && osutil.IsWindowsExecutable(relPath) {
 		curMode |= 0111
@@ -306,8 +421,15 @@ func (w *walker) walkRegular(relPath string, info os.FileInfo, fchan chan protoc
 	//  - has the same size as previously
 	cf, ok := w.CurrentFiler.CurrentFile(relPath)
 	permUnchanged := w.IgnorePerms || !cf.HasPermissionBits() || PermsEqual(cf.Permissions, curMode)
-	if ok && permUnchanged && !cf.IsDeleted() && cf.ModTime().Equal(info.ModTime()) && !cf.IsDirectory() &&
-		!cf.IsSymlink() && !cf.IsInvalid() && cf.Size == info.Size() {
+	mtimeUnchanged := cf.ModTime().Equal(info.ModTime())
+	wasFile := ok && !cf.IsDeleted() && !cf.IsDirectory() && !cf.IsSymlink() && !cf.IsInvalid()
+	sameFile := wasFile && cf.Size == info.Size()
+	if sameFile && permUnchanged && mtimeUnchanged {
+		return nil
+	}
+	if ok && cf.IsPlaceholder() && !cf.IsDeleted() && info.Size() == 0 && mtimeUnchanged {
+		// A placeholder stands for the data on other devices, for as long
+		// as it's left untouched.
 		return nil
 	}
 
@@ -322,10 +444,42 @@ func (w *walker) walkRegular(relPath string, info os.FileInfo, fchan chan protoc
 		ModifiedS:     info.ModTime().Unix(),
 		ModifiedNs:    int32(info.ModTime().Nanosecond()),
 		ModifiedBy:    w.ShortID,
+		ClockSkewMs:   w.clockSkewMs(),
 		Size:          info.Size(),
 	}
+
+	// When only the permissions changed, the contents are as unchanged as
+	// for any file we skip above. Keep the blocks we have instead of
+	// hashing the file again.
+	if sameFile && mtimeUnchanged {
+		f.Blocks = cf.Blocks
+		f.RawBlockSize = cf.RawBlockSize
+		if f.IsEquivalent(cf) {
+			// Only things we don't sync changed, such as the permission
+			// bits we ignore. Keeping the old version avoids a database
+			// write and sending the file to everyone again.
+			l.Debugln("unchanged after rescan:", relPath)
+			return nil
+		}
+		l.Debugln("metadata only change:", relPath, f)
+
+		w.Checkpoints.sending(f.Name)
+		select {
+		case dchan <- f:
+		case <-w.Cancel:
+			return errors.New("cancelled")
+		}
+		return nil
+	}
+
+	if w.DetectAppends && wasFile && info.Size() > cf.Size {
+		// Let the hasher check whether the blocks we had are still there.
+		f.Blocks = cf.Blocks
+	}
+
 	l.Debugln("to hash:", relPath, f)
 
+	w.Checkpoints.sending(f.Name)
 	select {
 	case fchan <- f:
 	case <-w.Cancel:
@@ -358,9 +512,11 @@ func (w *walker) walkDir(relPath string, info os.FileInfo, dchan chan protocol.F
 		ModifiedS:     info.ModTime().Unix(),
 		ModifiedNs:    int32(info.ModTime().Nanosecond()),
 		ModifiedBy:    w.ShortID,
+		ClockSkewMs:   w.clockSkewMs(),
 	}
 	l.Debugln("dir:", relPath, f)
 
+	w.Checkpoints.sending(f.Name)
 	select {
 	case dchan <- f:
 	case <-w.Cancel:
@@ -402,10 +558,12 @@ func (w *walker) walkSymlink(absPath, relPath string, dchan chan protocol.FileIn
 		Version:       cf.Version.Update(w.ShortID),
 		NoPermissions: true, // Symlinks don't have permissions of their own
 		SymlinkTarget: target,
+		ClockSkewMs:   w.clockSkewMs(),
 	}
 
 	l.Debugln("symlink changedb:", absPath, f)
 
+	w.Checkpoints.sending(f.Name)
 	select {
 	case dchan <- f:
 	case <-w.Cancel:
@@ -415,6 +573,10 @@ func (w *walker) walkSymlink(absPath, relPath string, dchan chan protocol.FileIn
 	return nil
 }
 
+func (w *walker) clockSkewMs() int64 {
+	return int64(w.ClockSkew / time.Millisecond)
+}
+
 // normalizePath returns the normalized relative path (possibly after fixing
 // it on disk), or skip is true.
 func (w *walker) normalizePath(absPath, relPath string) (normPath string, skip bool) {
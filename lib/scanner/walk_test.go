@@ -11,6 +11,7 @@ import (
 	"crypto/rand"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -18,6 +19,7 @@ import (
 	"sort"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/d4l3k/messagediff"
 	"github.com/syncthing/syncthing/lib/ignore"
@@ -87,6 +89,39 @@ func TestWalkSub(t *testing.T) {
 	}
 }
 
+func TestWalkChanges(t *testing.T) {
+	ignores := ignore.New(false)
+	err := ignores.Load("testdata/.stignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// dir1 is known, so only the directory itself is scanned; dir2 is new,
+	// so its contents are scanned as well. The missing file is skipped.
+
+	fchan, err := Walk(Config{
+		Dir:          "testdata",
+		Changes:      []string{"afile", "dir1", "dir2", "missing"},
+		BlockSize:    128 * 1024,
+		Matcher:      ignores,
+		Hashers:      2,
+		CurrentFiler: fakeCurrentFiler{"dir1": protocol.FileInfo{Name: "dir1", Type: protocol.FileInfoTypeDirectory}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for f := range fchan {
+		files = append(files, f.Name)
+	}
+	sort.Strings(files)
+
+	expected := []string{"afile", "dir1", "dir2", filepath.Join("dir2", "cfile")}
+	if fmt.Sprint(files) != fmt.Sprint(expected) {
+		t.Errorf("Incorrect files %v != %v", files, expected)
+	}
+}
+
 func TestWalk(t *testing.T) {
 	ignores := ignore.New(false)
 	err := ignores.Load("testdata/.stignore")
@@ -118,6 +153,101 @@ func TestWalk(t *testing.T) {
 	}
 }
 
+func TestWalkResume(t *testing.T) {
+	ignores := ignore.New(false)
+	if err := ignores.Load("testdata/.stignore"); err != nil {
+		t.Fatal(err)
+	}
+	walk := func(resume string, checkpoints *Checkpoints) []string {
+		fchan, err := Walk(Config{
+			Dir:         "testdata",
+			BlockSize:   128 * 1024,
+			Matcher:     ignores,
+			Hashers:     2,
+			Resume:      resume,
+			Checkpoints: checkpoints,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var files []string
+		for f := range fchan {
+			files = append(files, f.Name)
+		}
+		sort.Strings(files)
+		return files
+	}
+
+	checkpoints := NewCheckpoints()
+	all := walk("", checkpoints)
+	if pos := checkpoints.Position(); pos != all[len(all)-1] {
+		t.Errorf("Position after the walk is %q, should be %q", pos, all[len(all)-1])
+	}
+
+	// Resuming after dir2 leaves out it and everything before it, but not
+	// its contents.
+	var expected []string
+	for _, name := range all {
+		if compareWalkOrder(name, "dir2") > 0 {
+			expected = append(expected, name)
+		}
+	}
+	if files := walk("dir2", nil); fmt.Sprint(files) != fmt.Sprint(expected) {
+		t.Errorf("Resumed walk returned %v, should be %v", files, expected)
+	}
+}
+
+func TestCompareWalkOrder(t *testing.T) {
+	cases := []struct {
+		a, b string
+		res  int
+	}{
+		{"a", "a", 0},
+		{"a", "b", -1},
+		{"a", "a/b", -1},
+		{"a/b", "a", 1},
+		{"a/x", "a b", -1},
+		{"a b", "a/x", 1},
+		{"a/b/c", "a/c", -1},
+	}
+	for _, tc := range cases {
+		if res := compareWalkOrder(filepath.FromSlash(tc.a), filepath.FromSlash(tc.b)); res != tc.res {
+			t.Errorf("compareWalkOrder(%q, %q) = %d, should be %d", tc.a, tc.b, res, tc.res)
+		}
+	}
+}
+
+func TestWalkHashWhileWalking(t *testing.T) {
+	ignores := ignore.New(false)
+	if err := ignores.Load("testdata/.stignore"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The results are the same as for a regular walk, with progress
+	// events on.
+	fchan, err := Walk(Config{
+		Dir:              "testdata",
+		BlockSize:        128 * 1024,
+		Matcher:          ignores,
+		Hashers:          2,
+		HashWhileWalking: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tmp []protocol.FileInfo
+	for f := range fchan {
+		tmp = append(tmp, f)
+	}
+	sort.Sort(fileList(tmp))
+	files := fileList(tmp).testfiles()
+
+	if diff, equal := messagediff.PrettyDiff(testdata, files); !equal {
+		t.Errorf("Walk returned unexpected data. Diff:\n%s", diff)
+	}
+}
+
 func TestWalkError(t *testing.T) {
 	_, err := Walk(Config{
 		Dir:       "testdata-missing",
@@ -323,6 +453,209 @@ func TestWalkSymlink(t *testing.T) {
 	}
 }
 
+type fakeCurrentFiler map[string]protocol.FileInfo
+
+func (fcf fakeCurrentFiler) CurrentFile(name string) (protocol.FileInfo, bool) {
+	f, ok := fcf[name]
+	return f, ok
+}
+
+func TestWalkMetadataOnly(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not reliably detected on Windows")
+	}
+
+	info, err := os.Stat("testdata/afile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	perms := uint32(info.Mode() & os.ModePerm)
+
+	// The file is known with the same size, and blocks that don't match
+	// its contents. If we reuse the blocks, we didn't hash the file.
+	fakeBlocks := []protocol.BlockInfo{{Size: 4, Hash: []byte{1, 2, 3}}}
+	known := protocol.FileInfo{
+		Name:       "afile",
+		Size:       4,
+		ModifiedS:  info.ModTime().Unix(),
+		ModifiedNs: int32(info.ModTime().Nanosecond()),
+		Blocks:     fakeBlocks,
+	}
+
+	cases := []struct {
+		name        string
+		perms       uint32
+		modifiedS   int64
+		reuseBlocks bool
+	}{
+		{"permissions", perms ^ 0100, known.ModifiedS, true},
+		{"mtime", perms, 1, false},
+		{"permissions and mtime", perms ^ 0100, 1, false},
+	}
+
+	for _, tc := range cases {
+		cf := known
+		cf.Permissions = tc.perms
+		cf.ModifiedS = tc.modifiedS
+
+		fchan, err := Walk(Config{
+			Dir:          "testdata",
+			Subs:         []string{"afile"},
+			BlockSize:    128 * 1024,
+			CurrentFiler: fakeCurrentFiler{"afile": cf},
+			Hashers:      2,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var files []protocol.FileInfo
+		for f := range fchan {
+			files = append(files, f)
+		}
+		if len(files) != 1 {
+			t.Fatalf("%s: expected one changed file, got %v", tc.name, files)
+		}
+
+		if files[0].Permissions != perms || !files[0].ModTime().Equal(info.ModTime()) {
+			t.Errorf("%s: new metadata should be used, got %v", tc.name, files[0])
+		}
+		rehashed := fmt.Sprintf("%x", files[0].Blocks[0].Hash) == testdata[0].hash
+		if tc.reuseBlocks && (rehashed || !BlocksEqual(files[0].Blocks, fakeBlocks)) {
+			t.Errorf("%s: existing blocks should be kept, got %v", tc.name, files[0].Blocks)
+		}
+		if !tc.reuseBlocks && !rehashed {
+			t.Errorf("%s: changed file should be rehashed, got %v", tc.name, files[0].Blocks)
+		}
+	}
+}
+
+func TestWalkBlockSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := make([]byte, 2*protocol.MinBlockSize+100)
+	if _, err := io.ReadFull(rand.Reader, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "small"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, blockSize := range []int{protocol.MinBlockSize, protocol.BlockSize} {
+		fchan, err := Walk(Config{
+			Dir:       dir,
+			BlockSize: blockSize,
+			Hashers:   2,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var files []protocol.FileInfo
+		for f := range fchan {
+			files = append(files, f)
+		}
+		if len(files) != 1 || files[0].BlockSize() != blockSize {
+			t.Fatalf("Unexpected files %v for block size %d", files, blockSize)
+		}
+		expected, err := Blocks(bytes.NewReader(data), blockSize, -1, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !BlocksEqual(files[0].Blocks, expected) {
+			t.Errorf("Unexpected blocks %v for block size %d", files[0].Blocks, blockSize)
+		}
+	}
+}
+
+func TestWalkAppended(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const blockSize = 16
+	data := make([]byte, 3*blockSize+5)
+	if _, err := io.ReadFull(rand.Reader, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "log"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file was known before the last block and a half were appended.
+	// The strong hashes of the blocks don't match the contents, so if we
+	// reuse them, we didn't hash those blocks.
+	before, err := Blocks(bytes.NewReader(data[:2*blockSize+3]), blockSize, -1, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range before {
+		before[i].Hash = []byte{byte(i), 2, 3}
+	}
+	rehashed, err := Blocks(bytes.NewReader(data), blockSize, -1, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modified := make([]protocol.BlockInfo, len(before))
+	copy(modified, before)
+	modified[1].WeakHash++
+
+	cases := []struct {
+		name          string
+		blocks        []protocol.BlockInfo
+		detectAppends bool
+		keptBlocks    int
+	}{
+		{"appended", before, true, 2},
+		{"appended, not detecting", before, false, 0},
+		{"modified", modified, true, 0},
+	}
+
+	for _, tc := range cases {
+		fchan, err := Walk(Config{
+			Dir:       dir,
+			BlockSize: blockSize,
+			CurrentFiler: fakeCurrentFiler{"log": protocol.FileInfo{
+				Name:   "log",
+				Size:   2*blockSize + 3,
+				Blocks: tc.blocks,
+			}},
+			Hashers:       2,
+			UseWeakHashes: true,
+			DetectAppends: tc.detectAppends,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var files []protocol.FileInfo
+		for f := range fchan {
+			files = append(files, f)
+		}
+		if len(files) != 1 {
+			t.Fatalf("%s: expected one changed file, got %v", tc.name, files)
+		}
+
+		expected := make([]protocol.BlockInfo, len(rehashed))
+		copy(expected, rehashed)
+		copy(expected, before[:tc.keptBlocks])
+		if files[0].Size != int64(len(data)) || !BlocksEqual(files[0].Blocks, expected) {
+			t.Errorf("%s: unexpected blocks %v", tc.name, files[0].Blocks)
+		}
+		for i, b := range files[0].Blocks {
+			if b.Offset != int64(i*blockSize) {
+				t.Errorf("%s: block %d has offset %d", tc.name, i, b.Offset)
+			}
+		}
+	}
+}
+
 func walkDir(dir string) ([]protocol.FileInfo, error) {
 	fchan, err := Walk(Config{
 		Dir:           dir,
@@ -418,3 +751,61 @@ func initTestFile() {
 		panic(err)
 	}
 }
+
+func TestWalkPlaceholder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "placeholder")
+	if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Unix(1234567890, 0)
+	if err := os.Chtimes(name, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	cf := protocol.FileInfo{
+		Name:        "placeholder",
+		Type:        protocol.FileInfoTypeFile,
+		Size:        1000,
+		Permissions: 0644,
+		ModifiedS:   mtime.Unix(),
+		Placeholder: true,
+		Blocks:      []protocol.BlockInfo{{Size: 1000, Hash: []byte("fake")}},
+	}
+	walk := func() []protocol.FileInfo {
+		fchan, err := Walk(Config{
+			Dir:          dir,
+			BlockSize:    protocol.BlockSize,
+			CurrentFiler: fakeCurrentFiler{"placeholder": cf},
+			Hashers:      2,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var files []protocol.FileInfo
+		for f := range fchan {
+			files = append(files, f)
+		}
+		return files
+	}
+
+	// An untouched placeholder is left alone, even though it's empty.
+
+	if files := walk(); len(files) != 0 {
+		t.Errorf("Unexpected changes %v", files)
+	}
+
+	// Data written to it makes it a file like any other.
+
+	if err := ioutil.WriteFile(name, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if files := walk(); len(files) != 1 || files[0].Size != 4 || files[0].IsPlaceholder() {
+		t.Errorf("Unexpected changes %v", files)
+	}
+}
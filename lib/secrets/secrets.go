@@ -0,0 +1,112 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package secrets keeps passwords and the like in the operating system's
+// store for them, so that the config holds only references to them: the
+// keychain on macOS, the Secret Service through libsecret on Linux and the
+// BSDs, and files encrypted with DPAPI for the user on Windows.
+package secrets
+
+import (
+	"errors"
+	"strings"
+)
+
+// The service the secrets are stored under, where the store has them.
+const service = "syncthing"
+
+// refPrefix starts a config value that refers to a secret in the store.
+const refPrefix = "keychain:"
+
+var (
+	ErrNotFound    = errors.New("secret not found")
+	ErrUnsupported = errors.New("no secret store available")
+	errInvalidName = errors.New("invalid secret name")
+)
+
+// A Store keeps secrets by name. Names consist of letters, digits, dots,
+// dashes and underscores.
+type Store interface {
+	Get(name string) (string, error)
+	Set(name, value string) error
+	Delete(name string) error
+}
+
+// NewStore returns the operating system's store. Where secrets are kept in
+// files, they are put in the directory.
+func NewStore(dir string) Store {
+	return newStore(dir)
+}
+
+// IsRef returns true if the config value refers to a secret in the store.
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, refPrefix)
+}
+
+// Ref returns the config value referring to the named secret.
+func Ref(name string) string {
+	return refPrefix + name
+}
+
+// Resolve returns the config value, or the secret it refers to.
+func Resolve(s Store, value string) (string, error) {
+	if !IsRef(value) {
+		return value, nil
+	}
+	if s == nil {
+		return "", ErrUnsupported
+	}
+	return s.Get(strings.TrimPrefix(value, refPrefix))
+}
+
+// Move stores the config value as the named secret, returning the reference
+// to it to use in its place. References are returned as they are. An empty
+// value deletes the secret and stays empty.
+func Move(s Store, name, value string) (string, error) {
+	if IsRef(value) {
+		return value, nil
+	}
+	if value == "" {
+		if err := s.Delete(name); err != nil && err != ErrNotFound {
+			return "", err
+		}
+		return "", nil
+	}
+	if err := s.Set(name, value); err != nil {
+		return "", err
+	}
+	return Ref(name), nil
+}
+
+// The unsupportedStore is used where there's no store to be had.
+type unsupportedStore struct{}
+
+func (unsupportedStore) Get(string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (unsupportedStore) Set(string, string) error {
+	return ErrUnsupported
+}
+
+func (unsupportedStore) Delete(string) error {
+	return ErrUnsupported
+}
+
+func validName(name string) bool {
+	if name == "" || name == "." || name == ".." {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
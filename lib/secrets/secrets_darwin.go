@@ -0,0 +1,70 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package secrets
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// The keychainStore keeps secrets as generic passwords in the user's
+// login keychain, through the security command.
+type keychainStore struct{}
+
+func newStore(string) Store {
+	if _, err := exec.LookPath("security"); err != nil {
+		return unsupportedStore{}
+	}
+	return keychainStore{}
+}
+
+func (keychainStore) Get(name string) (string, error) {
+	if !validName(name) {
+		return "", errInvalidName
+	}
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", name, "-w").Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && strings.Contains(string(exitErr.Stderr), "could not be found") {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("keychain: %v", err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (keychainStore) Set(name, value string) error {
+	if !validName(name) {
+		return errInvalidName
+	}
+	// The password is given in hex on stdin, so that it's neither on the
+	// command line for all to see nor in need of quoting.
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n", service, name, hex.EncodeToString([]byte(value))))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil || stderr.Len() > 0 {
+		return fmt.Errorf("keychain: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (keychainStore) Delete(name string) error {
+	if !validName(name) {
+		return errInvalidName
+	}
+	out, err := exec.Command("security", "delete-generic-password", "-s", service, "-a", name).CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(out), "could not be found") {
+			return ErrNotFound
+		}
+		return fmt.Errorf("keychain: %v", err)
+	}
+	return nil
+}
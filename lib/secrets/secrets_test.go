@@ -0,0 +1,84 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package secrets
+
+import "testing"
+
+type memoryStore map[string]string
+
+func (s memoryStore) Get(name string) (string, error) {
+	value, ok := s[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (s memoryStore) Set(name, value string) error {
+	s[name] = value
+	return nil
+}
+
+func (s memoryStore) Delete(name string) error {
+	if _, ok := s[name]; !ok {
+		return ErrNotFound
+	}
+	delete(s, name)
+	return nil
+}
+
+func TestMoveResolve(t *testing.T) {
+	s := make(memoryStore)
+
+	ref, err := Move(s, "smtp-password", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref != "keychain:smtp-password" || s["smtp-password"] != "hunter2" {
+		t.Errorf("Unexpected reference %q, store %v", ref, s)
+	}
+	if value, err := Resolve(s, ref); err != nil || value != "hunter2" {
+		t.Errorf("Resolved %q, %v", value, err)
+	}
+
+	// References and plain values are left as they are.
+
+	if again, err := Move(s, "smtp-password", ref); err != nil || again != ref {
+		t.Errorf("Moved reference to %q, %v", again, err)
+	}
+	if value, err := Resolve(s, "plain"); err != nil || value != "plain" {
+		t.Errorf("Resolved plain value to %q, %v", value, err)
+	}
+
+	// An empty value removes the secret.
+
+	if empty, err := Move(s, "smtp-password", ""); err != nil || empty != "" {
+		t.Errorf("Moved empty value to %q, %v", empty, err)
+	}
+	if _, err := Resolve(s, ref); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := Resolve(nil, ref); err != ErrUnsupported {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestValidName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"gui-password-ABCDEFG": true,
+		"smtp_password.1":      true,
+		"":                     false,
+		"..":                   false,
+		"a/b":                  false,
+		"a b":                  false,
+		`a\b`:                  false,
+	} {
+		if validName(name) != valid {
+			t.Errorf("validName(%q) != %v", name, valid)
+		}
+	}
+}
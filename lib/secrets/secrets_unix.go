@@ -0,0 +1,71 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build linux freebsd openbsd netbsd dragonfly
+
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// The secretServiceStore keeps secrets in the Secret Service, as provided
+// by GNOME Keyring or KWallet, through libsecret's secret-tool.
+type secretServiceStore struct{}
+
+func newStore(string) Store {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return unsupportedStore{}
+	}
+	return secretServiceStore{}
+}
+
+func (secretServiceStore) Get(name string) (string, error) {
+	if !validName(name) {
+		return "", errInvalidName
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", service, "account", name)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if stdout.Len() == 0 && stderr.Len() == 0 {
+			// Nothing found is a failure without a message.
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("secret service: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func (secretServiceStore) Set(name, value string) error {
+	if !validName(name) {
+		return errInvalidName
+	}
+	// The secret is read from stdin, keeping it off the command line.
+	cmd := exec.Command("secret-tool", "store", "--label", "Syncthing "+name, "service", service, "account", name)
+	cmd.Stdin = strings.NewReader(value)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret service: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (secretServiceStore) Delete(name string) error {
+	if !validName(name) {
+		return errInvalidName
+	}
+	if _, err := (secretServiceStore{}).Get(name); err != nil {
+		return err
+	}
+	if out, err := exec.Command("secret-tool", "clear", "service", service, "account", name).CombinedOutput(); err != nil {
+		return fmt.Errorf("secret service: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
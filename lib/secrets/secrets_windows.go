@@ -0,0 +1,130 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"github.com/syncthing/syncthing/lib/osutil"
+)
+
+var (
+	crypt32                = syscall.NewLazyDLL("crypt32.dll")
+	procCryptProtectData   = crypt32.NewProc("CryptProtectData")
+	procCryptUnprotectData = crypt32.NewProc("CryptUnprotectData")
+	kernel32               = syscall.NewLazyDLL("kernel32.dll")
+	procLocalFree          = kernel32.NewProc("LocalFree")
+)
+
+const cryptProtectUIForbidden = 0x1
+
+// The dpapiStore keeps each secret in a file of its own, encrypted with
+// DPAPI so that only the same user can decrypt it.
+type dpapiStore struct {
+	dir string
+}
+
+func newStore(dir string) Store {
+	return dpapiStore{dir: filepath.Join(dir, "secrets")}
+}
+
+func (s dpapiStore) Get(name string) (string, error) {
+	if !validName(name) {
+		return "", errInvalidName
+	}
+	bs, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+	if os.IsNotExist(err) {
+		return "", ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
+	plain, err := unprotect(bs)
+	if err != nil {
+		return "", fmt.Errorf("DPAPI: %v", err)
+	}
+	return string(plain), nil
+}
+
+func (s dpapiStore) Set(name, value string) error {
+	if !validName(name) {
+		return errInvalidName
+	}
+	bs, err := protect([]byte(value))
+	if err != nil {
+		return fmt.Errorf("DPAPI: %v", err)
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	fd, err := osutil.CreateAtomic(filepath.Join(s.dir, name))
+	if err != nil {
+		return err
+	}
+	if _, err := fd.Write(bs); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
+
+func (s dpapiStore) Delete(name string) error {
+	if !validName(name) {
+		return errInvalidName
+	}
+	err := os.Remove(filepath.Join(s.dir, name))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// A dataBlob is the DATA_BLOB passed to and returned from DPAPI.
+type dataBlob struct {
+	size uint32
+	data *byte
+}
+
+func newDataBlob(bs []byte) *dataBlob {
+	if len(bs) == 0 {
+		return &dataBlob{}
+	}
+	return &dataBlob{size: uint32(len(bs)), data: &bs[0]}
+}
+
+// bytes returns a copy of the data, which it frees.
+func (b *dataBlob) bytes() []byte {
+	if b.data == nil {
+		return nil
+	}
+	defer procLocalFree.Call(uintptr(unsafe.Pointer(b.data)))
+	bs := make([]byte, b.size)
+	copy(bs, (*[1 << 30]byte)(unsafe.Pointer(b.data))[:b.size:b.size])
+	return bs
+}
+
+func protect(plain []byte) ([]byte, error) {
+	var out dataBlob
+	r, _, err := procCryptProtectData.Call(uintptr(unsafe.Pointer(newDataBlob(plain))), 0, 0, 0, 0, cryptProtectUIForbidden, uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return nil, err
+	}
+	return out.bytes(), nil
+}
+
+func unprotect(encrypted []byte) ([]byte, error) {
+	var out dataBlob
+	r, _, err := procCryptUnprotectData.Call(uintptr(unsafe.Pointer(newDataBlob(encrypted))), 0, 0, 0, 0, cryptProtectUIForbidden, uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return nil, err
+	}
+	return out.bytes(), nil
+}
@@ -7,13 +7,77 @@
 package stats
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/syncthing/syncthing/lib/db"
 )
 
+// The most connections kept in the history of a device, however recent.
+const maxConnectionRecords = 1000
+
 type DeviceStatistics struct {
 	LastSeen time.Time `json:"lastSeen"`
+
+	// Bytes transferred in the current month of the transfer cap, when set
+	TransferredIn  int64 `json:"transferredIn,omitempty"`
+	TransferredOut int64 `json:"transferredOut,omitempty"`
+	TransferCapped bool  `json:"transferCapped,omitempty"`
+}
+
+// A ConnectionRecord is a connection to the device, from when it was made
+// until it was closed, and why.
+type ConnectionRecord struct {
+	Connected    time.Time `json:"connected"`
+	Disconnected time.Time `json:"disconnected"` // zero while connected
+	Type         string    `json:"type"`         // the transport, such as tcp-client or relay-server
+	Address      string    `json:"address"`
+	InBytes      int64     `json:"inBytes"`
+	OutBytes     int64     `json:"outBytes"`
+	Error        string    `json:"error"` // why the connection was closed
+}
+
+// Duration returns how long the connection lasted, or has lasted so far.
+func (r ConnectionRecord) Duration(now time.Time) time.Duration {
+	if r.Disconnected.IsZero() {
+		return now.Sub(r.Connected)
+	}
+	return r.Disconnected.Sub(r.Connected)
+}
+
+// A ConnectionSummary sums up the connection history of a device.
+type ConnectionSummary struct {
+	Connections int            `json:"connections"`
+	ConnectedS  float64        `json:"connectedS"`
+	UptimePct   float64        `json:"uptimePct"` // of the time since the first connection in the history
+	Types       map[string]int `json:"types"`     // connections per transport
+	Errors      map[string]int `json:"errors"`    // connections per reason for being closed
+}
+
+// SummarizeConnections sums up the connection history, which is sorted the
+// latest first.
+func SummarizeConnections(records []ConnectionRecord, now time.Time) ConnectionSummary {
+	summary := ConnectionSummary{
+		Connections: len(records),
+		Types:       make(map[string]int),
+		Errors:      make(map[string]int),
+	}
+	if len(records) == 0 {
+		return summary
+	}
+	var connected time.Duration
+	for _, r := range records {
+		connected += r.Duration(now)
+		summary.Types[r.Type]++
+		if r.Error != "" {
+			summary.Errors[r.Error]++
+		}
+	}
+	summary.ConnectedS = connected.Seconds()
+	if period := now.Sub(records[len(records)-1].Connected); period > 0 {
+		summary.UptimePct = 100 * connected.Seconds() / period.Seconds()
+	}
+	return summary
 }
 
 type DeviceStatisticsReference struct {
@@ -45,6 +109,70 @@ func (s *DeviceStatisticsReference) WasSeen() {
 	s.ns.PutTime("lastSeen", time.Now())
 }
 
+// GetTransferred returns the bytes received from and sent to the device in
+// the transfer period starting at the given time.
+func (s *DeviceStatisticsReference) GetTransferred(period time.Time) (in, out int64) {
+	if start, ok := s.ns.Time("transferPeriod"); !ok || !start.Equal(period) {
+		return 0, 0
+	}
+	in, _ = s.ns.Int64("transferredIn")
+	out, _ = s.ns.Int64("transferredOut")
+	return in, out
+}
+
+// AddTransferred adds to the bytes received from and sent to the device in
+// the transfer period starting at the given time, starting over from zero
+// when it's a new period. It returns the new totals.
+func (s *DeviceStatisticsReference) AddTransferred(period time.Time, in, out int64) (int64, int64) {
+	prevIn, prevOut := s.GetTransferred(period)
+	in += prevIn
+	out += prevOut
+	l.Debugln("stats.DeviceStatisticsReference.AddTransferred:", s.device, period, in, out)
+	s.ns.PutTime("transferPeriod", period)
+	s.ns.PutInt64("transferredIn", in)
+	s.ns.PutInt64("transferredOut", out)
+	return in, out
+}
+
+// GetConnections returns the history of closed connections to the device,
+// the latest first.
+func (s *DeviceStatisticsReference) GetConnections() []ConnectionRecord {
+	bs, ok := s.ns.Bytes("connections")
+	if !ok {
+		return nil
+	}
+	var records []ConnectionRecord
+	if err := json.Unmarshal(bs, &records); err != nil {
+		l.Debugln("stats.DeviceStatisticsReference.GetConnections:", s.device, err)
+		return nil
+	}
+	return records
+}
+
+// AddConnection adds a closed connection to the history, dropping those
+// closed longer ago than it's kept for. With nothing kept the history is
+// cleared.
+func (s *DeviceStatisticsReference) AddConnection(record ConnectionRecord, keep time.Duration) {
+	l.Debugln("stats.DeviceStatisticsReference.AddConnection:", s.device, record)
+	if keep <= 0 {
+		s.ns.Delete("connections")
+		return
+	}
+	records := append([]ConnectionRecord{record}, s.GetConnections()...)
+	cutoff := record.Disconnected.Add(-keep)
+	for i, r := range records {
+		if i == maxConnectionRecords || r.Disconnected.Before(cutoff) {
+			records = records[:i]
+			break
+		}
+	}
+	bs, err := json.Marshal(records)
+	if err != nil {
+		return
+	}
+	s.ns.PutBytes("connections", bs)
+}
+
 func (s *DeviceStatisticsReference) GetStatistics() DeviceStatistics {
 	return DeviceStatistics{
 		LastSeen: s.GetLastSeen(),
@@ -25,6 +25,14 @@ var (
 	// nonstandard things (from a debug logging PoV).
 	debug       = strings.Contains(os.Getenv("STTRACE"), "sync") || os.Getenv("STTRACE") == "all"
 	useDeadlock = os.Getenv("STDEADLOCK") != ""
+
+	// Set by STDEADLOCKDUMP to the number of seconds a goroutine may wait
+	// for a lock before the stacks of all goroutines are dumped. Zero
+	// disables the dumps.
+	dumpThreshold time.Duration
+
+	// Set STLOCKMETRICS to collect per call site lock metrics.
+	useMetrics = os.Getenv("STLOCKMETRICS") != ""
 )
 
 func init() {
@@ -36,5 +44,8 @@ func init() {
 	if n, err := strconv.Atoi(os.Getenv("STDEADLOCK")); err == nil {
 		deadlock.Opts.DeadlockTimeout = time.Duration(n) * time.Second
 	}
+	if n, err := strconv.Atoi(os.Getenv("STDEADLOCKDUMP")); err == nil && n > 0 {
+		dumpThreshold = time.Duration(n) * time.Second
+	}
 	l.Debugf("Enabling lock logging at %v threshold", threshold)
 }
@@ -0,0 +1,77 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package sync
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// A LockMetric holds the statistics for the locks taken at one call site.
+// They are only collected when STLOCKMETRICS is set.
+type LockMetric struct {
+	Wait      metrics.Timer   // time spent waiting for the lock
+	Hold      metrics.Timer   // time the lock was held
+	Contended metrics.Counter // times the lock was held or waited on by someone else
+}
+
+var (
+	lockMetrics    = make(map[string]LockMetric)
+	lockMetricsMut sync.RWMutex
+)
+
+// LockMetrics returns a snapshot of the lock metrics, by call site.
+func LockMetrics() map[string]LockMetric {
+	lockMetricsMut.RLock()
+	defer lockMetricsMut.RUnlock()
+
+	res := make(map[string]LockMetric, len(lockMetrics))
+	for at, m := range lockMetrics {
+		res[at] = LockMetric{
+			Wait:      m.Wait.Snapshot(),
+			Hold:      m.Hold.Snapshot(),
+			Contended: m.Contended.Snapshot(),
+		}
+	}
+	return res
+}
+
+func lockMetric(at string) LockMetric {
+	lockMetricsMut.RLock()
+	m, ok := lockMetrics[at]
+	lockMetricsMut.RUnlock()
+	if ok {
+		return m
+	}
+
+	lockMetricsMut.Lock()
+	defer lockMetricsMut.Unlock()
+	if m, ok := lockMetrics[at]; ok {
+		return m
+	}
+	m = LockMetric{
+		Wait:      metrics.NewTimer(),
+		Hold:      metrics.NewTimer(),
+		Contended: metrics.NewCounter(),
+	}
+	lockMetrics[at] = m
+	return m
+}
+
+func recordWait(at string, d time.Duration, contended bool) {
+	m := lockMetric(at)
+	m.Wait.Update(d)
+	if contended {
+		m.Contended.Inc(1)
+	}
+}
+
+func recordHold(at string, d time.Duration) {
+	lockMetric(at).Hold.Update(d)
+}
@@ -40,7 +40,7 @@ func NewMutex() Mutex {
 	if useDeadlock {
 		return &deadlock.Mutex{}
 	}
-	if debug {
+	if debug || dumpThreshold > 0 || useMetrics {
 		mutex := &loggedMutex{}
 		mutex.holder.Store(holder{})
 		return mutex
@@ -52,7 +52,7 @@ func NewRWMutex() RWMutex {
 	if useDeadlock {
 		return &deadlock.RWMutex{}
 	}
-	if debug {
+	if debug || dumpThreshold > 0 || useMetrics {
 		mutex := &loggedRWMutex{
 			readHolders: make(map[int][]holder),
 			unlockers:   make(chan holder, 1024),
@@ -86,11 +86,21 @@ func (h holder) String() string {
 type loggedMutex struct {
 	sync.Mutex
 	holder atomic.Value
+	users  int32 // goroutines holding or waiting for the lock
 }
 
 func (m *loggedMutex) Lock() {
+	if dumpThreshold > 0 {
+		defer watchLock("Mutex", getHolder(), m.Holders).Stop()
+	}
+	start := time.Now()
+	contended := atomic.AddInt32(&m.users, 1) > 1
 	m.Mutex.Lock()
-	m.holder.Store(getHolder())
+	holder := getHolder()
+	m.holder.Store(holder)
+	if useMetrics {
+		recordWait(holder.at, holder.time.Sub(start), contended)
+	}
 }
 
 func (m *loggedMutex) Unlock() {
@@ -99,7 +109,11 @@ func (m *loggedMutex) Unlock() {
 	if duration >= threshold {
 		l.Debugf("Mutex held for %v. Locked at %s unlocked at %s", duration, currentHolder.at, getHolder().at)
 	}
+	if useMetrics {
+		recordHold(currentHolder.at, duration)
+	}
 	m.holder.Store(holder{})
+	atomic.AddInt32(&m.users, -1)
 	m.Mutex.Unlock()
 }
 
@@ -116,11 +130,18 @@ type loggedRWMutex struct {
 
 	logUnlockers int32
 	unlockers    chan holder
+
+	writers int32 // goroutines holding or waiting for the write lock
+	readers int32 // goroutines holding or waiting for a read lock
 }
 
 func (m *loggedRWMutex) Lock() {
 	start := time.Now()
 
+	if dumpThreshold > 0 {
+		defer watchLock("RWMutex", getHolder(), m.Holders).Stop()
+	}
+	contended := atomic.AddInt32(&m.writers, 1) > 1 || atomic.LoadInt32(&m.readers) > 0
 	atomic.StoreInt32(&m.logUnlockers, 1)
 	m.RWMutex.Lock()
 	atomic.StoreInt32(&m.logUnlockers, 0)
@@ -129,6 +150,9 @@ func (m *loggedRWMutex) Lock() {
 	m.holder.Store(holder)
 
 	duration := holder.time.Sub(start)
+	if useMetrics {
+		recordWait(holder.at, duration, contended)
+	}
 
 	if duration > threshold {
 		var unlockerStrings []string
@@ -151,13 +175,26 @@ func (m *loggedRWMutex) Unlock() {
 	if duration >= threshold {
 		l.Debugf("RWMutex held for %v. Locked at %s unlocked at %s", duration, currentHolder.at, getHolder().at)
 	}
+	if useMetrics {
+		recordHold(currentHolder.at, duration)
+	}
 	m.holder.Store(holder{})
+	atomic.AddInt32(&m.writers, -1)
 	m.RWMutex.Unlock()
 }
 
 func (m *loggedRWMutex) RLock() {
+	if dumpThreshold > 0 {
+		defer watchLock("RWMutex", getHolder(), m.Holders).Stop()
+	}
+	start := time.Now()
+	atomic.AddInt32(&m.readers, 1)
+	contended := atomic.LoadInt32(&m.writers) > 0
 	m.RWMutex.RLock()
 	holder := getHolder()
+	if useMetrics {
+		recordWait(holder.at, holder.time.Sub(start), contended)
+	}
 	m.readHoldersMut.Lock()
 	m.readHolders[holder.goid] = append(m.readHolders[holder.goid], holder)
 	m.readHoldersMut.Unlock()
@@ -169,6 +206,10 @@ func (m *loggedRWMutex) RUnlock() {
 	current := m.readHolders[id]
 	if len(current) > 0 {
 		m.readHolders[id] = current[:len(current)-1]
+		if useMetrics {
+			last := current[len(current)-1]
+			recordHold(last.at, time.Since(last.time))
+		}
 	}
 	m.readHoldersMut.Unlock()
 	if atomic.LoadInt32(&m.logUnlockers) == 1 {
@@ -179,6 +220,7 @@ func (m *loggedRWMutex) RUnlock() {
 			l.Debugf("Dropped holder %s as channel full", holder)
 		}
 	}
+	atomic.AddInt32(&m.readers, -1)
 	m.RWMutex.RUnlock()
 }
 
@@ -217,6 +259,36 @@ func getHolder() holder {
 	}
 }
 
+// lastDump is the time, in Unix nanoseconds, of the last goroutine dump.
+var lastDump int64
+
+// watchLock returns a timer that dumps the stacks of all goroutines if it
+// isn't stopped, by the waiter acquiring the lock, within the dump
+// threshold. At most one dump is made per threshold period, however many
+// goroutines are stuck.
+func watchLock(kind string, waiter holder, holders func() string) *time.Timer {
+	return time.AfterFunc(dumpThreshold, func() {
+		now := time.Now().UnixNano()
+		last := atomic.LoadInt64(&lastDump)
+		if now-last < int64(dumpThreshold) || !atomic.CompareAndSwapInt64(&lastDump, last, now) {
+			return
+		}
+		l.Warnf("%s waited on for %v, possible deadlock. Waiting %s. Held %s. Goroutines:\n%s", kind, dumpThreshold, waiter, holders(), goroutineDump())
+	})
+}
+
+// goroutineDump returns the stack traces of all goroutines, as in a panic.
+func goroutineDump() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
 func goid() int {
 	var buf [64]byte
 	n := runtime.Stack(buf[:], false)
@@ -180,6 +180,136 @@ func TestRWMutex(t *testing.T) {
 	l.SetDebug("sync", false)
 }
 
+func TestDeadlockDump(t *testing.T) {
+	if skipTimingTests {
+		t.Skip("insufficient timer accuracy")
+		return
+	}
+
+	dumpThreshold = logThreshold
+	defer func() {
+		dumpThreshold = 0
+	}()
+
+	msgmut := sync.Mutex{}
+	var messages []string
+
+	l.AddHandler(logger.LevelWarn, func(_ logger.LogLevel, message string) {
+		msgmut.Lock()
+		messages = append(messages, message)
+		msgmut.Unlock()
+	})
+
+	mut := NewMutex()
+	if _, ok := mut.(*loggedMutex); !ok {
+		t.Fatal("Wrong type")
+	}
+
+	mut.Lock()
+	time.Sleep(shortWait)
+	mut.Unlock()
+
+	// A waiter that gets the lock in time causes no dump.
+
+	mut.Lock()
+	go func() {
+		time.Sleep(shortWait)
+		mut.Unlock()
+	}()
+	mut.Lock()
+	mut.Unlock()
+	time.Sleep(longWait)
+
+	msgmut.Lock()
+	if len(messages) != 0 {
+		t.Errorf("Unexpected message count %d", len(messages))
+	}
+	msgmut.Unlock()
+
+	// A waiter that doesn't causes a dump of all goroutines, including
+	// the one holding the lock.
+
+	held := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		mut.Lock()
+		close(held)
+		<-release
+		mut.Unlock()
+	}()
+	<-held
+	go func() {
+		time.Sleep(2 * longWait)
+		close(release)
+	}()
+	mut.Lock()
+	mut.Unlock()
+
+	msgmut.Lock()
+	defer msgmut.Unlock()
+	if len(messages) != 1 {
+		t.Fatalf("Unexpected message count %d", len(messages))
+	}
+	if !strings.Contains(messages[0], "Held at sync/sync_test.go:") {
+		t.Error("Holder missing from message:", messages[0])
+	}
+	if !strings.Contains(messages[0], "goroutine ") || !strings.Contains(messages[0], "TestDeadlockDump") {
+		t.Error("Goroutine dump missing from message:", messages[0])
+	}
+}
+
+func TestLockMetrics(t *testing.T) {
+	useMetrics = true
+	defer func() {
+		useMetrics = false
+	}()
+
+	lockMetricsMut.Lock()
+	lockMetrics = make(map[string]LockMetric)
+	lockMetricsMut.Unlock()
+
+	mut := NewRWMutex()
+	if _, ok := mut.(*loggedRWMutex); !ok {
+		t.Fatal("Wrong type")
+	}
+
+	mut.Lock()
+	time.Sleep(shortWait)
+	mut.Unlock()
+
+	mut.RLock()
+	done := make(chan struct{})
+	go func() {
+		mut.Lock() // contended by the read lock
+		mut.Unlock()
+		close(done)
+	}()
+	time.Sleep(shortWait)
+	mut.RUnlock()
+	<-done
+
+	var count, contended int64
+	var hold int64
+	for at, m := range LockMetrics() {
+		if !strings.HasPrefix(at, "sync/sync_test.go:") {
+			continue
+		}
+		count += m.Wait.Count()
+		contended += m.Contended.Count()
+		hold += m.Hold.Sum()
+	}
+
+	if count != 3 {
+		t.Errorf("Unexpected lock count %d", count)
+	}
+	if contended != 1 {
+		t.Errorf("Unexpected contended count %d", contended)
+	}
+	if time.Duration(hold) < 2*shortWait {
+		t.Errorf("Unexpected hold time %v", time.Duration(hold))
+	}
+}
+
 func TestWaitGroup(t *testing.T) {
 	if skipTimingTests {
 		t.Skip("insufficient timer accuracy")
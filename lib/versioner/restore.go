@@ -0,0 +1,159 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package versioner
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/osutil"
+)
+
+var ErrVersionNotFound = errors.New("version not found")
+
+// FileVersion is an archived version of a file.
+type FileVersion struct {
+	VersionTime time.Time `json:"versionTime"` // identifies the version
+	ModTime     time.Time `json:"modTime"`
+	Size        int64     `json:"size"`
+}
+
+// A Restorer is a Versioner that can find the versions it has archived.
+type Restorer interface {
+	Versioner
+	// Versions returns the versions of the files in the directory of the
+	// folder, all of them for "", newest first, by file name relative to
+	// the folder.
+	Versions(dir string) (map[string][]FileVersion, error)
+	// VersionPath returns where the version of the file is kept.
+	VersionPath(name string, versionTime time.Time) (string, error)
+}
+
+// Restore puts the version of the file, named relative to the folder,
+// back. The current file is archived first, so it can be restored in turn.
+func Restore(r Restorer, folderPath, name string, versionTime time.Time) error {
+	src, err := r.VersionPath(name, versionTime)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	dst := filepath.Join(folderPath, name)
+	if err := osutil.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+		return err
+	}
+
+	// Copy rather than move the version back, as it may be in a read only
+	// snapshot, and a restore shouldn't cost a version.
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := osutil.CreateAtomic(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := r.Archive(dst); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	os.Chmod(dst, info.Mode()&os.ModePerm)
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// walkVersions calls fn with the name relative to the folder and the
+// info of each file in the directory of the archive.
+func walkVersions(versionsDir, dir string, fn func(name string, info os.FileInfo)) error {
+	root := filepath.Join(versionsDir, dir)
+	if _, err := osutil.Lstat(root); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		name, err := filepath.Rel(versionsDir, path)
+		if err != nil {
+			return err
+		}
+		fn(name, info)
+		return nil
+	})
+}
+
+// taggedVersions returns the versions in an archive of files tagged with
+// the time, as kept by the simple and staggered versioners.
+func taggedVersions(versionsDir, dir string) (map[string][]FileVersion, error) {
+	versions := make(map[string][]FileVersion)
+	err := walkVersions(versionsDir, dir, func(name string, info os.FileInfo) {
+		tag := filenameTag(name)
+		versionTime, err := time.ParseInLocation(TimeFormat, tag, time.Local)
+		if err != nil {
+			return
+		}
+		// The tag is either before the extension or at the end.
+		name = filepath.Join(filepath.Dir(name), strings.Replace(filepath.Base(name), "~"+tag, "", 1))
+		versions[name] = append(versions[name], FileVersion{
+			VersionTime: versionTime,
+			ModTime:     info.ModTime(),
+			Size:        info.Size(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, vs := range versions {
+		sortVersions(vs)
+	}
+	return versions, nil
+}
+
+// taggedVersionPath returns the path of the version in an archive of files
+// tagged with the time.
+func taggedVersionPath(versionsDir, name string, versionTime time.Time) (string, error) {
+	tag := versionTime.In(time.Local).Format(TimeFormat)
+	for _, path := range []string{
+		filepath.Join(versionsDir, taggedFilename(name, tag)),
+		filepath.Join(versionsDir, name+"~"+tag),
+	} {
+		if info, err := osutil.Lstat(path); err == nil && info.Mode().IsRegular() {
+			return path, nil
+		}
+	}
+	return "", ErrVersionNotFound
+}
+
+type versionsNewestFirst []FileVersion
+
+func (l versionsNewestFirst) Len() int           { return len(l) }
+func (l versionsNewestFirst) Swap(a, b int)      { l[a], l[b] = l[b], l[a] }
+func (l versionsNewestFirst) Less(a, b int) bool { return l[a].VersionTime.After(l[b].VersionTime) }
+
+func sortVersions(versions []FileVersion) {
+	sort.Sort(versionsNewestFirst(versions))
+}
@@ -0,0 +1,80 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package versioner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSimpleRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join("sub", "file.txt")
+	path := filepath.Join(dir, name)
+	os.MkdirAll(filepath.Dir(path), 0755)
+
+	v := NewSimple("default", dir, map[string]string{"keep": "5"}).(Restorer)
+
+	// Three contents of the file, the first two archived.
+
+	base := time.Date(2017, 1, 2, 3, 4, 5, 0, time.Local)
+	for i, content := range []string{"one", "two", "three"} {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := base.Add(time.Duration(i) * time.Hour)
+		os.Chtimes(path, mtime, mtime)
+		if i < 2 {
+			if err := v.Archive(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	versions, err := v.Versions("sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := versions[name]
+	if len(versions) != 1 || len(vs) != 2 {
+		t.Fatalf("Unexpected versions %v", versions)
+	}
+	if !vs[0].VersionTime.Equal(base.Add(time.Hour)) || vs[0].Size != 3 || !vs[1].ModTime.Equal(base) {
+		t.Errorf("Unexpected versions %v", vs)
+	}
+	if versions, _ := v.Versions("other"); len(versions) != 0 {
+		t.Errorf("Unexpected versions %v in another directory", versions)
+	}
+
+	// Restoring the first archives the current one.
+
+	if err := Restore(v, dir, name, vs[1].VersionTime); err != nil {
+		t.Fatal(err)
+	}
+	if bs, _ := ioutil.ReadFile(path); string(bs) != "one" {
+		t.Errorf("Restored %q, expected %q", bs, "one")
+	}
+	if info, err := os.Stat(path); err != nil || !info.ModTime().Equal(base) {
+		t.Error("The restored file should have the modification time of the version")
+	}
+	versions, _ = v.Versions("")
+	if len(versions[name]) != 3 {
+		t.Errorf("Unexpected versions %v after restoring", versions)
+	}
+
+	if err := Restore(v, dir, name, base.Add(time.Minute)); err != ErrVersionNotFound {
+		t.Errorf("Unexpected error %v restoring a missing version", err)
+	}
+}
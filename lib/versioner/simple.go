@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/util"
@@ -117,3 +118,11 @@ func (v Simple) Archive(filePath string) error {
 
 	return nil
 }
+
+func (v Simple) Versions(dir string) (map[string][]FileVersion, error) {
+	return taggedVersions(filepath.Join(v.folderPath, ".stversions"), dir)
+}
+
+func (v Simple) VersionPath(name string, versionTime time.Time) (string, error) {
+	return taggedVersionPath(filepath.Join(v.folderPath, ".stversions"), name, versionTime)
+}
@@ -0,0 +1,435 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package versioner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+func init() {
+	// Register the constructor for this type of versioner with the name "snapshot"
+	Factories["snapshot"] = NewSnapshot
+}
+
+// Snapshot versioning keeps the old versions of files in snapshots of the
+// filesystem rather than in copies, which costs next to nothing on btrfs
+// and ZFS. A snapshot is taken before a file is replaced or deleted,
+// unless the file hasn't changed since the last snapshot, so a pull
+// replacing many files takes just the one.
+type Snapshot struct {
+	prefix     string // of the names of our snapshots
+	folderPath string
+	snapshots  snapshotBackend
+	keep       int           // the number of snapshots to keep, or zero for no limit
+	maxAge     time.Duration // the age of the snapshots to remove, or zero for no limit
+
+	mut      sync.Mutex
+	last     time.Time // when the last snapshot was taken
+	lastName string
+	listed   bool // last has been set from the existing snapshots
+}
+
+// Files changed this close to when the last snapshot was taken get
+// another, as file times are not as precise as the clock.
+const snapshotTimeMargin = time.Second
+
+// A snapshotBackend takes, lists and deletes snapshots by name.
+type snapshotBackend interface {
+	Create(name string) error
+	List() ([]string, error)
+	Delete(name string) error
+	// Path returns where the folder is in the snapshot.
+	Path(name string) (string, error)
+}
+
+// commandRunner runs a command, returning its output.
+type commandRunner func(name string, args ...string) ([]byte, error)
+
+func runCommand(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+// NewSnapshot returns a snapshot versioner. The "filesystem" parameter is
+// "btrfs", the default, or "zfs". For btrfs, "source" is the subvolume to snapshot,
+// which defaults to the folder, and "snapshotsPath" where to put the
+// snapshots, which defaults to .stversions in the folder. For ZFS,
+// "source" is the dataset to snapshot, which defaults to the one holding
+// the folder. The snapshots beyond the newest "keep" and those older than
+// "maxAge" seconds are removed; zero for either means no limit.
+func NewSnapshot(folderID, folderPath string, params map[string]string) Versioner {
+	return newSnapshot(folderID, folderPath, params, runCommand)
+}
+
+func newSnapshot(folderID, folderPath string, params map[string]string, run commandRunner) *Snapshot {
+	keep, _ := strconv.Atoi(params["keep"])
+	maxAge, _ := strconv.ParseInt(params["maxAge"], 10, 0)
+
+	var snapshots snapshotBackend
+	switch params["filesystem"] {
+	case "zfs":
+		snapshots = &zfsSnapshots{
+			dataset:    params["source"],
+			folderPath: folderPath,
+			run:        run,
+		}
+	default:
+		snapshots = &btrfsSnapshots{
+			subvolume:  defaultPath(params["source"], folderPath),
+			dir:        defaultPath(params["snapshotsPath"], filepath.Join(folderPath, ".stversions")),
+			folderPath: folderPath,
+			run:        run,
+		}
+	}
+
+	s := &Snapshot{
+		prefix:     "syncthing-" + snapshotNamePart(folderID) + "-",
+		folderPath: folderPath,
+		snapshots:  snapshots,
+		keep:       keep,
+		maxAge:     time.Duration(maxAge) * time.Second,
+		mut:        sync.NewMutex(),
+	}
+
+	l.Debugf("instantiated %#v", s)
+	return s
+}
+
+// Archive makes sure the named file is in a snapshot and removes it. If
+// this function returns nil, the named file does not exist any more (has
+// been archived).
+func (v *Snapshot) Archive(filePath string) error {
+	info, err := osutil.Lstat(filePath)
+	if os.IsNotExist(err) {
+		l.Debugln("not archiving nonexistent file", filePath)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	v.mut.Lock()
+	defer v.mut.Unlock()
+
+	if !v.listed {
+		v.loadLast()
+	}
+	if !changeTime(info).Before(v.last.Add(-snapshotTimeMargin)) {
+		if err := v.snapshot(); err != nil {
+			return err
+		}
+	}
+
+	l.Debugln("archiving", filePath, "in snapshot", v.lastName)
+	return os.Remove(filePath)
+}
+
+// loadLast sets when the last snapshot was taken from the existing ones.
+// The time in their names is truncated to the second, which errs on the
+// side of taking another.
+func (v *Snapshot) loadLast() {
+	names, err := v.snapshots.List()
+	if err != nil {
+		l.Debugln("listing snapshots:", err)
+		return
+	}
+	for _, name := range names {
+		if t, ok := v.snapshotTime(name); ok && t.After(v.last) {
+			v.last = t
+			v.lastName = name
+		}
+	}
+	v.listed = true
+}
+
+func (v *Snapshot) snapshot() error {
+	now := time.Now()
+	name := v.prefix + now.Format(TimeFormat)
+	if name == v.lastName {
+		// Names are by the second.
+		time.Sleep(now.Truncate(time.Second).Add(time.Second).Sub(now))
+		now = time.Now()
+		name = v.prefix + now.Format(TimeFormat)
+	}
+
+	l.Debugln("taking snapshot", name)
+	if err := v.snapshots.Create(name); err != nil {
+		return err
+	}
+	v.last = now
+	v.lastName = name
+
+	v.prune(now)
+	return nil
+}
+
+// prune deletes the snapshots beyond the ones to keep and those too old,
+// but never the one just taken.
+func (v *Snapshot) prune(now time.Time) {
+	if v.keep <= 0 && v.maxAge <= 0 {
+		return
+	}
+
+	names, err := v.snapshots.List()
+	if err != nil {
+		l.Infoln("Listing snapshots:", err)
+		return
+	}
+	var ours []string
+	for _, name := range names {
+		if _, ok := v.snapshotTime(name); ok && name != v.lastName {
+			ours = append(ours, name)
+		}
+	}
+	// Newest first, as the names end with the time.
+	sort.Sort(sort.Reverse(sort.StringSlice(ours)))
+
+	for i, name := range ours {
+		t, _ := v.snapshotTime(name)
+		tooMany := v.keep > 0 && i+1 >= v.keep
+		tooOld := v.maxAge > 0 && now.Sub(t) > v.maxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		l.Debugln("deleting snapshot", name)
+		if err := v.snapshots.Delete(name); err != nil {
+			l.Infoln("Deleting snapshot:", err)
+		}
+	}
+}
+
+// Versions returns the versions of the files in our snapshots, which means
+// walking the directory in each of them. A file is usually the same in
+// many snapshots, so only the newest snapshot with each version of it is
+// listed, and not those with the file as it is now.
+func (v *Snapshot) Versions(dir string) (map[string][]FileVersion, error) {
+	names, err := v.snapshots.List()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string][]FileVersion)
+	for _, snapshot := range names {
+		t, ok := v.snapshotTime(snapshot)
+		if !ok {
+			continue
+		}
+		root, err := v.snapshots.Path(snapshot)
+		if err != nil {
+			return nil, err
+		}
+		err = walkVersions(root, dir, func(name string, info os.FileInfo) {
+			versions[name] = append(versions[name], FileVersion{
+				VersionTime: t,
+				ModTime:     info.ModTime(),
+				Size:        info.Size(),
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for name, vs := range versions {
+		sortVersions(vs)
+		cur, err := osutil.Lstat(filepath.Join(v.folderPath, name))
+		var distinct []FileVersion
+		for i, fv := range vs {
+			if err == nil && fv.ModTime.Equal(cur.ModTime()) && fv.Size == cur.Size() {
+				continue
+			}
+			if i > 0 && fv.ModTime.Equal(vs[i-1].ModTime) && fv.Size == vs[i-1].Size {
+				continue
+			}
+			distinct = append(distinct, fv)
+		}
+		if len(distinct) == 0 {
+			delete(versions, name)
+		} else {
+			versions[name] = distinct
+		}
+	}
+	return versions, nil
+}
+
+func (v *Snapshot) VersionPath(name string, versionTime time.Time) (string, error) {
+	root, err := v.snapshots.Path(v.prefix + versionTime.In(time.Local).Format(TimeFormat))
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(root, name)
+	if info, err := osutil.Lstat(path); err != nil || !info.Mode().IsRegular() {
+		return "", ErrVersionNotFound
+	}
+	return path, nil
+}
+
+// snapshotTime returns when the snapshot was taken, if it's one of ours.
+func (v *Snapshot) snapshotTime(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, v.prefix) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(TimeFormat, name[len(v.prefix):], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// btrfsSnapshots are read only snapshots of a subvolume, in a directory.
+type btrfsSnapshots struct {
+	subvolume  string
+	dir        string
+	folderPath string
+	run        commandRunner
+}
+
+func (s *btrfsSnapshots) Create(name string) error {
+	if err := osutil.MkdirAll(s.dir, 0777); err != nil {
+		return err
+	}
+	return runSnapshotCommand(s.run, "btrfs", "subvolume", "snapshot", "-r", s.subvolume, filepath.Join(s.dir, name))
+}
+
+func (s *btrfsSnapshots) List() ([]string, error) {
+	fd, err := os.Open(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	return fd.Readdirnames(-1)
+}
+
+func (s *btrfsSnapshots) Delete(name string) error {
+	return runSnapshotCommand(s.run, "btrfs", "subvolume", "delete", filepath.Join(s.dir, name))
+}
+
+func (s *btrfsSnapshots) Path(name string) (string, error) {
+	rel, err := filepath.Rel(s.subvolume, s.folderPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, name, rel), nil
+}
+
+// zfsSnapshots are snapshots of a dataset.
+type zfsSnapshots struct {
+	dataset    string // found from the folder path when empty
+	mountpoint string // found when needed
+	folderPath string
+	run        commandRunner
+}
+
+func (s *zfsSnapshots) Create(name string) error {
+	dataset, err := s.datasetName()
+	if err != nil {
+		return err
+	}
+	return runSnapshotCommand(s.run, "zfs", "snapshot", dataset+"@"+name)
+}
+
+func (s *zfsSnapshots) List() ([]string, error) {
+	dataset, err := s.datasetName()
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.run("zfs", "list", "-H", "-t", "snapshot", "-o", "name", "-d", "1", dataset)
+	if err != nil {
+		return nil, snapshotCommandError("zfs list", out, err)
+	}
+	var names []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if i := strings.IndexByte(line, '@'); i >= 0 && line[:i] == dataset {
+			names = append(names, strings.TrimSpace(line[i+1:]))
+		}
+	}
+	return names, nil
+}
+
+func (s *zfsSnapshots) Delete(name string) error {
+	dataset, err := s.datasetName()
+	if err != nil {
+		return err
+	}
+	return runSnapshotCommand(s.run, "zfs", "destroy", dataset+"@"+name)
+}
+
+// Path returns the path in the hidden .zfs directory of the dataset, which
+// gives access to its snapshots.
+func (s *zfsSnapshots) Path(name string) (string, error) {
+	if s.mountpoint == "" {
+		dataset, err := s.datasetName()
+		if err != nil {
+			return "", err
+		}
+		out, err := s.run("zfs", "get", "-H", "-o", "value", "mountpoint", dataset)
+		if err != nil {
+			return "", snapshotCommandError("zfs get", out, err)
+		}
+		s.mountpoint = strings.TrimSpace(string(out))
+	}
+	rel, err := filepath.Rel(s.mountpoint, s.folderPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.mountpoint, ".zfs", "snapshot", name, rel), nil
+}
+
+func (s *zfsSnapshots) datasetName() (string, error) {
+	if s.dataset != "" {
+		return s.dataset, nil
+	}
+	out, err := s.run("zfs", "list", "-H", "-o", "name", s.folderPath)
+	if err != nil {
+		return "", snapshotCommandError("zfs list", out, err)
+	}
+	s.dataset = strings.TrimSpace(string(out))
+	return s.dataset, nil
+}
+
+func runSnapshotCommand(run commandRunner, name string, args ...string) error {
+	if out, err := run(name, args...); err != nil {
+		return snapshotCommandError(name+" "+args[0], out, err)
+	}
+	return nil
+}
+
+func snapshotCommandError(cmd string, out []byte, err error) error {
+	if msg := strings.TrimSpace(string(out)); msg != "" {
+		return fmt.Errorf("%s: %v: %s", cmd, err, msg)
+	}
+	return fmt.Errorf("%s: %v", cmd, err)
+}
+
+// snapshotNamePart returns the string with the characters that can't be
+// part of a snapshot name replaced.
+func snapshotNamePart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+func defaultPath(path, def string) string {
+	if path == "" {
+		return def
+	}
+	return path
+}
@@ -0,0 +1,26 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build freebsd netbsd darwin
+
+package versioner
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// changeTime returns when the file last changed in any way, including
+// having its modification time set, as Syncthing does to the files it
+// pulls.
+func changeTime(info os.FileInfo) time.Time {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.ModTime()
+	}
+	return time.Unix(st.Ctimespec.Unix())
+}
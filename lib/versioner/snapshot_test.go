@@ -0,0 +1,138 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package versioner
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeBtrfs creates and deletes directories in place of snapshots.
+func fakeBtrfs(name string, args ...string) ([]byte, error) {
+	switch {
+	case name == "btrfs" && len(args) == 5 && args[1] == "snapshot":
+		return nil, os.Mkdir(args[4], 0755)
+	case name == "btrfs" && len(args) == 3 && args[1] == "delete":
+		return nil, os.Remove(args[2])
+	}
+	return []byte("unexpected command"), errors.New("exit status 1")
+}
+
+func TestSnapshotVersioning(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test takes some time, skipping.")
+	}
+	if runtime.GOOS != "linux" {
+		t.Skip("Test requires file change times")
+	}
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	snapshots := func() []string {
+		names, _ := ioutil.ReadDir(filepath.Join(dir, ".stversions"))
+		var snapshots []string
+		for _, info := range names {
+			snapshots = append(snapshots, info.Name())
+		}
+		return snapshots
+	}
+
+	v := newSnapshot("default", dir, map[string]string{"keep": "2"}, fakeBtrfs)
+
+	// Files unchanged since the last snapshot are in it already, so
+	// archiving two of them takes one snapshot.
+
+	a, b := write("a"), write("b")
+	time.Sleep(snapshotTimeMargin + 100*time.Millisecond)
+	for _, path := range []string{a, b} {
+		if err := v.Archive(path); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Error("Archived file should be removed")
+		}
+	}
+	if s := snapshots(); len(s) != 1 || !strings.HasPrefix(s[0], "syncthing-default-") {
+		t.Fatalf("Unexpected snapshots %v", s)
+	}
+
+	// Changed files need another, and only the newest two are kept.
+
+	for _, name := range []string{"c", "d"} {
+		if err := v.Archive(write(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := snapshots(); len(s) != 2 || s[1] != v.lastName {
+		t.Errorf("Unexpected snapshots %v", s)
+	}
+
+	// A versioner for the same folder carries on with the same snapshots.
+
+	v = newSnapshot("default", dir, map[string]string{"keep": "2"}, fakeBtrfs)
+	v.loadLast()
+	if s := snapshots(); v.lastName != s[1] {
+		t.Errorf("Last snapshot %q should be %q", v.lastName, s[1])
+	}
+}
+
+func TestZFSSnapshots(t *testing.T) {
+	var commands []string
+	run := func(name string, args ...string) ([]byte, error) {
+		cmd := name + " " + strings.Join(args, " ")
+		commands = append(commands, cmd)
+		switch {
+		case cmd == "zfs list -H -o name /tank/sync":
+			return []byte("tank/sync\n"), nil
+		case strings.HasPrefix(cmd, "zfs list -H -t snapshot"):
+			return []byte("tank/sync@syncthing-abc-20170101-120000\ntank/sync@manual\n"), nil
+		}
+		return nil, nil
+	}
+
+	s := &zfsSnapshots{folderPath: "/tank/sync", run: run}
+	if err := s.Create("syncthing-abc-20170102-120000"); err != nil {
+		t.Fatal(err)
+	}
+	names, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(names[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"zfs list -H -o name /tank/sync",
+		"zfs snapshot tank/sync@syncthing-abc-20170102-120000",
+		"zfs list -H -t snapshot -o name -d 1 tank/sync",
+		"zfs destroy tank/sync@syncthing-abc-20170101-120000",
+	}
+	if strings.Join(commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected commands\n%s", strings.Join(commands, "\n"))
+	}
+	if len(names) != 2 || names[1] != "manual" {
+		t.Errorf("Unexpected snapshots %v", names)
+	}
+}
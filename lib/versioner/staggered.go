@@ -303,3 +303,13 @@ func (v *Staggered) Archive(filePath string) error {
 
 	return nil
 }
+
+func (v *Staggered) Versions(dir string) (map[string][]FileVersion, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return taggedVersions(v.versionsPath, dir)
+}
+
+func (v *Staggered) VersionPath(name string, versionTime time.Time) (string, error) {
+	return taggedVersionPath(v.versionsPath, name, versionTime)
+}
@@ -173,3 +173,29 @@ func (t *Trashcan) cleanoutArchive() error {
 	}
 	return nil
 }
+
+// Versions returns the file in the trash can, if any, for each file. Its
+// time is when it was put there.
+func (t *Trashcan) Versions(dir string) (map[string][]FileVersion, error) {
+	versions := make(map[string][]FileVersion)
+	err := walkVersions(filepath.Join(t.folderPath, ".stversions"), dir, func(name string, info os.FileInfo) {
+		versions[name] = []FileVersion{{
+			VersionTime: info.ModTime(),
+			ModTime:     info.ModTime(),
+			Size:        info.Size(),
+		}}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (t *Trashcan) VersionPath(name string, versionTime time.Time) (string, error) {
+	path := filepath.Join(t.folderPath, ".stversions", name)
+	info, err := osutil.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() || info.ModTime().Unix() != versionTime.Unix() {
+		return "", ErrVersionNotFound
+	}
+	return path, nil
+}
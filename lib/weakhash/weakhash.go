@@ -12,6 +12,7 @@ import (
 	"os"
 
 	"github.com/chmduquesne/rollinghash/adler32"
+	"github.com/syncthing/syncthing/lib/osutil"
 )
 
 const (
@@ -78,7 +79,16 @@ func NewFinder(path string, size int, hashesToFind []uint32) (*Finder, error) {
 		return nil, err
 	}
 
-	offsets, err := Find(file, hashesToFind, size)
+	// Large files are scanned through a memory mapping when possible.
+	var r io.Reader = file
+	if info, err := file.Stat(); err == nil {
+		if m := osutil.MapFileOrNil(file, info.Size(), osutil.MmapSequential); m != nil {
+			defer m.Close()
+			r = m.Reader()
+		}
+	}
+
+	offsets, err := Find(r, hashesToFind, size)
 	if err != nil {
 		file.Close()
 		return nil, err
@@ -0,0 +1,91 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package webdav
+
+import (
+	"encoding/xml"
+	"net/http"
+
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+// The multistatus response to a PROPFIND. All the live properties we have
+// are always returned, regardless of which were asked for.
+type multistatus struct {
+	XMLName   xml.Name   `xml:"D:multistatus"`
+	Namespace string     `xml:"xmlns:D,attr"`
+	Responses []response `xml:"D:response"`
+}
+
+type response struct {
+	Href     string   `xml:"D:href"`
+	Propstat propstat `xml:"D:propstat"`
+}
+
+type propstat struct {
+	Prop   prop   `xml:"D:prop"`
+	Status string `xml:"D:status"`
+}
+
+type prop struct {
+	DisplayName   string        `xml:"D:displayname"`
+	ResourceType  resourceType  `xml:"D:resourcetype"`
+	ContentLength *int64        `xml:"D:getcontentlength,omitempty"`
+	LastModified  string        `xml:"D:getlastmodified,omitempty"`
+	ETag          string        `xml:"D:getetag,omitempty"`
+	SupportedLock supportedLock `xml:"D:supportedlock"`
+}
+
+type resourceType struct {
+	Collection *struct{} `xml:"D:collection,omitempty"`
+}
+
+type supportedLock struct {
+	LockEntry struct {
+		LockScope struct {
+			Exclusive struct{} `xml:"D:exclusive"`
+		} `xml:"D:lockscope"`
+		LockType struct {
+			Write struct{} `xml:"D:write"`
+		} `xml:"D:locktype"`
+	} `xml:"D:lockentry"`
+}
+
+func newMultistatus() *multistatus {
+	return &multistatus{Namespace: "DAV:"}
+}
+
+// add adds a response for the file or directory at href. The info is nil
+// for the top level, which doesn't exist on disk.
+func (m *multistatus) add(href, name string, info fs.FileInfo) {
+	p := prop{DisplayName: name}
+	if info == nil || info.IsDir() {
+		p.ResourceType.Collection = &struct{}{}
+	}
+	if info != nil {
+		if !info.IsDir() {
+			size := info.Size()
+			p.ContentLength = &size
+			p.ETag = etag(info)
+		}
+		p.LastModified = info.ModTime().UTC().Format(http.TimeFormat)
+	}
+	m.Responses = append(m.Responses, response{
+		Href: href,
+		Propstat: propstat{
+			Prop:   p,
+			Status: "HTTP/1.1 200 OK",
+		},
+	})
+}
+
+func (m *multistatus) write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+	w.WriteHeader(207) // Multi-Status
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(m)
+}
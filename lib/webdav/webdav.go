@@ -0,0 +1,556 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package webdav serves folders over WebDAV, so that devices that can't run
+// Syncthing can mount them.
+//
+// The server is WebDAV class 1. Locking is accepted, as some clients won't
+// write without it, but not enforced. Properties can't be changed.
+package webdav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/rand"
+)
+
+// A Folder is a folder served over WebDAV, as a collection named by the
+// folder ID at the top level. The folder is served from its own filesystem
+// if it has one, and from the handler's otherwise.
+type Folder struct {
+	ID    string
+	Label string
+	Path  string
+	FS    fs.Filesystem
+}
+
+// The Handler serves the folders it's given over WebDAV. Internal and
+// temporary files are hidden, and symlinks are neither listed nor
+// followed. The changed function is called with the folder ID and the
+// path within the folder of every file or directory that is written,
+// created or removed.
+type Handler struct {
+	fs      fs.Filesystem
+	folders func() map[string]Folder
+	changed func(folder, name string)
+}
+
+func NewHandler(filesystem fs.Filesystem, folders func() map[string]Folder, changed func(folder, name string)) *Handler {
+	return &Handler{
+		fs:      filesystem,
+		folders: folders,
+		changed: changed,
+	}
+}
+
+// A target is the file or directory a request is for.
+type target struct {
+	folder Folder
+	name   string // within the folder, empty for the folder itself
+}
+
+func (t target) fs(fallback fs.Filesystem) fs.Filesystem {
+	if t.folder.FS != nil {
+		return t.folder.FS
+	}
+	return fallback
+}
+
+func (t target) path() string {
+	return filepath.Join(t.folder.Path, t.name)
+}
+
+func (t target) href(dir bool) string {
+	p := "/" + t.folder.ID + "/" + filepath.ToSlash(t.name)
+	if dir {
+		p = strings.TrimSuffix(p, "/") + "/"
+	} else {
+		p = strings.TrimSuffix(p, "/")
+	}
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+// resolve returns the target for the URL path, and whether it is the top
+// level listing the folders instead. Targets within symlinked directories
+// don't resolve.
+func (h *Handler) resolve(urlPath string) (t target, top bool, ok bool) {
+	p := path.Clean("/" + urlPath)
+	if p == "/" {
+		return target{}, true, true
+	}
+
+	parts := strings.SplitN(p[1:], "/", 2)
+	folder, ok := h.folders()[parts[0]]
+	if !ok {
+		return target{}, false, false
+	}
+	t.folder = folder
+	if len(parts) == 2 {
+		t.name = filepath.FromSlash(parts[1])
+		if ignore.IsInternal(t.name) || ignore.IsTemporary(t.name) {
+			return target{}, false, false
+		}
+		if traversesSymlink(t.fs(h.fs), folder.Path, filepath.Dir(t.name)) {
+			return target{}, false, false
+		}
+	}
+	return t, false, true
+}
+
+// traversesSymlink returns whether any of the directories on the way to dir
+// within base, including dir itself, is a symlink or can't be checked.
+// Those that don't exist yet aren't symlinks.
+func traversesSymlink(filesystem fs.Filesystem, base, dir string) bool {
+	if dir == "." {
+		return false
+	}
+	cur := base
+	for _, part := range strings.Split(dir, string(filepath.Separator)) {
+		cur = filepath.Join(cur, part)
+		info, err := filesystem.Lstat(cur)
+		if os.IsNotExist(err) {
+			return false
+		}
+		if err != nil || info.IsSymlink() {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.Debugln("WebDAV", r.Method, r.URL.Path)
+
+	if r.Method == "OPTIONS" {
+		w.Header().Set("DAV", "1, 2")
+		w.Header().Set("MS-Author-Via", "DAV")
+		w.Header().Set("Allow", "OPTIONS, PROPFIND, GET, HEAD, PUT, DELETE, MKCOL, COPY, MOVE, LOCK, UNLOCK")
+		return
+	}
+
+	t, top, ok := h.resolve(r.URL.Path)
+	if !ok {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if top {
+		if r.Method == "PROPFIND" {
+			h.propfindTop(w, r)
+		} else {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	switch r.Method {
+	case "PROPFIND":
+		h.propfind(w, r, t)
+	case "GET", "HEAD":
+		h.get(w, r, t)
+	case "PUT":
+		h.put(w, r, t)
+	case "DELETE":
+		h.delete(w, r, t)
+	case "MKCOL":
+		h.mkcol(w, r, t)
+	case "COPY", "MOVE":
+		h.copyMove(w, r, t)
+	case "LOCK":
+		h.lock(w, r, t)
+	case "UNLOCK":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// stat returns the file info of the target, refusing symlinks. Those among
+// the directories leading to it were refused by resolve.
+func (h *Handler) stat(t target) (fs.FileInfo, error) {
+	info, err := t.fs(h.fs).Lstat(t.path())
+	if err != nil {
+		return nil, err
+	}
+	if info.IsSymlink() {
+		return nil, os.ErrNotExist
+	}
+	return info, nil
+}
+
+func (h *Handler) notifyChanged(t target) {
+	if h.changed != nil && t.name != "" {
+		h.changed(t.folder.ID, t.name)
+	}
+}
+
+func (h *Handler) propfindTop(w http.ResponseWriter, r *http.Request) {
+	ms := newMultistatus()
+	ms.add("/", "", nil)
+	if r.Header.Get("Depth") != "0" {
+		for _, folder := range h.folders() {
+			t := target{folder: folder}
+			info, err := h.stat(t)
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			name := folder.Label
+			if name == "" {
+				name = folder.ID
+			}
+			ms.add(t.href(true), name, info)
+		}
+	}
+	ms.write(w)
+}
+
+func (h *Handler) propfind(w http.ResponseWriter, r *http.Request, t target) {
+	info, err := h.stat(t)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ms := newMultistatus()
+	ms.add(t.href(info.IsDir()), info.Name(), info)
+	if info.IsDir() && r.Header.Get("Depth") != "0" {
+		names, err := t.fs(h.fs).DirNames(t.path())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		for _, name := range names {
+			child := target{folder: t.folder, name: filepath.Join(t.name, name)}
+			if ignore.IsInternal(child.name) || ignore.IsTemporary(child.name) {
+				continue
+			}
+			info, err := h.stat(child)
+			if err != nil {
+				continue
+			}
+			ms.add(child.href(info.IsDir()), name, info)
+		}
+	}
+	ms.write(w)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, t target) {
+	info, err := h.stat(t)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if info.IsDir() {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fd, err := t.fs(h.fs).Open(t.path())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer fd.Close()
+
+	w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+	w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	w.Header().Set("ETag", etag(info))
+	if ct := mime.TypeByExtension(filepath.Ext(t.name)); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	if r.Method == "HEAD" {
+		return
+	}
+	io.Copy(w, fd)
+}
+
+// put writes the file to a temporary file next to it first, so that it's
+// not scanned or synced half written.
+func (h *Handler) put(w http.ResponseWriter, r *http.Request, t target) {
+	if t.name == "" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	info, err := h.stat(t)
+	existed := err == nil
+	if existed && info.IsDir() {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := writeFile(t.fs(h.fs), t.path(), r.Body); err != nil {
+		writeError(w, err)
+		return
+	}
+	h.notifyChanged(t)
+
+	if existed {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func writeFile(filesystem fs.Filesystem, name string, r io.Reader) error {
+	tempName := ignore.TempName(name + ".webdav")
+	fd, err := filesystem.Create(tempName)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(&offsetWriter{w: fd}, r)
+	if closeErr := fd.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = filesystem.Rename(tempName, name)
+	}
+	if err != nil {
+		filesystem.Remove(tempName)
+	}
+	return err
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, t target) {
+	if t.name == "" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if _, err := h.stat(t); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := removeAll(t.fs(h.fs), t.path()); err != nil {
+		writeError(w, err)
+		return
+	}
+	h.notifyChanged(t)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) mkcol(w http.ResponseWriter, r *http.Request, t target) {
+	if r.ContentLength > 0 {
+		http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+		return
+	}
+	if _, err := h.stat(t); err == nil {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := t.fs(h.fs).Mkdir(t.path(), 0777); err != nil {
+		writeError(w, err)
+		return
+	}
+	h.notifyChanged(t)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// copyMove copies or moves the target to the one named by the Destination
+// header, which may be in another folder.
+func (h *Handler) copyMove(w http.ResponseWriter, r *http.Request, t target) {
+	if t.name == "" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	u, err := url.Parse(r.Header.Get("Destination"))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	dst, top, ok := h.resolve(u.Path)
+	if !ok || top || dst.name == "" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if dst.path() == t.path() {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	info, err := h.stat(t)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	_, err = h.stat(dst)
+	existed := err == nil
+	if existed {
+		if r.Header.Get("Overwrite") == "F" {
+			http.Error(w, "Precondition Failed", http.StatusPreconditionFailed)
+			return
+		}
+		if err := removeAll(dst.fs(h.fs), dst.path()); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	srcFS, dstFS := t.fs(h.fs), dst.fs(h.fs)
+	switch {
+	case r.Method == "MOVE" && srcFS == dstFS:
+		err = srcFS.Rename(t.path(), dst.path())
+	case r.Method == "MOVE":
+		// Between filesystems, a move is a copy followed by a delete
+		err = copyAll(srcFS, t.path(), dstFS, dst.path(), info)
+		if err == nil {
+			err = removeAll(srcFS, t.path())
+		}
+	default:
+		err = copyAll(srcFS, t.path(), dstFS, dst.path(), info)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if r.Method == "MOVE" {
+		h.notifyChanged(t)
+	}
+	h.notifyChanged(dst)
+
+	if existed {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// lock hands out a lock token without locking anything. Locking a name
+// that doesn't exist creates an empty file, as clients expect.
+func (h *Handler) lock(w http.ResponseWriter, r *http.Request, t target) {
+	status := http.StatusOK
+	if _, err := h.stat(t); err != nil {
+		if t.name == "" || !os.IsNotExist(err) {
+			writeError(w, err)
+			return
+		}
+		if err := writeFile(t.fs(h.fs), t.path(), strings.NewReader("")); err != nil {
+			writeError(w, err)
+			return
+		}
+		h.notifyChanged(t)
+		status = http.StatusCreated
+	}
+
+	token := "opaquelocktoken:" + rand.String(32)
+	w.Header().Set("Lock-Token", "<"+token+">")
+	w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+	w.WriteHeader(status)
+	fmt.Fprintf(w, lockResponse, token, xmlEscape(t.href(false)))
+}
+
+const lockResponse = `<?xml version="1.0" encoding="utf-8"?>
+<D:prop xmlns:D="DAV:"><D:lockdiscovery><D:activelock>
+<D:locktype><D:write/></D:locktype>
+<D:lockscope><D:exclusive/></D:lockscope>
+<D:depth>infinity</D:depth>
+<D:timeout>Second-3600</D:timeout>
+<D:locktoken><D:href>%s</D:href></D:locktoken>
+<D:lockroot><D:href>%s</D:href></D:lockroot>
+</D:activelock></D:lockdiscovery></D:prop>
+`
+
+func removeAll(filesystem fs.Filesystem, name string) error {
+	info, err := filesystem.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		children, err := filesystem.DirNames(name)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := removeAll(filesystem, filepath.Join(name, child)); err != nil {
+				return err
+			}
+		}
+	}
+	return filesystem.Remove(name)
+}
+
+func copyAll(srcFS fs.Filesystem, src string, dstFS fs.Filesystem, dst string, info fs.FileInfo) error {
+	if !info.IsDir() {
+		fd, err := srcFS.Open(src)
+		if err != nil {
+			return err
+		}
+		defer fd.Close()
+		return writeFile(dstFS, dst, fd)
+	}
+
+	if err := dstFS.Mkdir(dst, 0777); err != nil {
+		return err
+	}
+	children, err := srcFS.DirNames(src)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if ignore.IsTemporary(child) {
+			continue
+		}
+		info, err := srcFS.Lstat(filepath.Join(src, child))
+		if err != nil {
+			return err
+		}
+		if info.IsSymlink() {
+			continue
+		}
+		if err := copyAll(srcFS, filepath.Join(src, child), dstFS, filepath.Join(dst, child), info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// An offsetWriter turns an io.WriterAt into an io.Writer.
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+}
+
+func (w *offsetWriter) Write(bs []byte) (int, error) {
+	n, err := w.w.WriteAt(bs, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case os.IsNotExist(err):
+		// Also when the parent directory doesn't exist, which should
+		// strictly be a conflict, but we can't easily tell the two apart.
+		http.Error(w, "Not Found", http.StatusNotFound)
+	case os.IsPermission(err):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	default:
+		l.Debugln("WebDAV:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func etag(info fs.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,158 @@
+// Copyright (C) 2017 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package webdav
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+func TestHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webdav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, ".stfolder"), 0777)
+	ioutil.WriteFile(filepath.Join(dir, "existing"), []byte("hello"), 0644)
+
+	folders := func() map[string]Folder {
+		return map[string]Folder{"default": {ID: "default", Label: "Default Folder", Path: dir}}
+	}
+	var changed []string
+	h := NewHandler(fs.DefaultFilesystem, folders, func(folder, name string) {
+		changed = append(changed, folder+":"+filepath.ToSlash(name))
+	})
+
+	do := func(method, path, body string, hdr map[string]string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range hdr {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	expect := func(w *httptest.ResponseRecorder, code int) {
+		if w.Code != code {
+			t.Fatalf("Unexpected status %d, expected %d: %s", w.Code, code, w.Body.String())
+		}
+	}
+
+	// The folders are listed at the top level, and the internal files in
+	// the folders are hidden
+
+	w := do("PROPFIND", "/", "", map[string]string{"Depth": "1"})
+	expect(w, 207)
+	if !strings.Contains(w.Body.String(), "<D:href>/default/</D:href>") || !strings.Contains(w.Body.String(), "Default Folder") {
+		t.Errorf("Folder not listed: %s", w.Body.String())
+	}
+	w = do("PROPFIND", "/default/", "", map[string]string{"Depth": "1"})
+	expect(w, 207)
+	if !strings.Contains(w.Body.String(), "<D:href>/default/existing</D:href>") || strings.Contains(w.Body.String(), ".stfolder") {
+		t.Errorf("Unexpected listing: %s", w.Body.String())
+	}
+	expect(do("PROPFIND", "/default/.stfolder", "", nil), 404)
+	expect(do("GET", "/default/../../etc/passwd", "", nil), 404)
+	expect(do("GET", "/other/", "", nil), 404)
+
+	// Files and directories can be created, read, copied, moved and
+	// deleted, and each change is reported
+
+	expect(do("MKCOL", "/default/dir", "", nil), 201)
+	expect(do("PUT", "/default/dir/file", "some data", nil), 201)
+	w = do("GET", "/default/dir/file", "", nil)
+	expect(w, 200)
+	if w.Body.String() != "some data" {
+		t.Errorf("Read back %q", w.Body.String())
+	}
+	expect(do("PUT", "/default/dir/file", "other data", nil), 204)
+
+	expect(do("COPY", "/default/dir", "", map[string]string{"Destination": "http://localhost/default/copy"}), 201)
+	expect(do("MOVE", "/default/copy/file", "", map[string]string{"Destination": "/default/existing", "Overwrite": "F"}), 412)
+	expect(do("MOVE", "/default/copy/file", "", map[string]string{"Destination": "/default/existing"}), 204)
+	if bs, _ := ioutil.ReadFile(filepath.Join(dir, "existing")); string(bs) != "other data" {
+		t.Errorf("Moved file contains %q", bs)
+	}
+	expect(do("DELETE", "/default/dir", "", nil), 204)
+	expect(do("DELETE", "/default/", "", nil), 403)
+
+	names, _ := ioutil.ReadDir(dir)
+	var left []string
+	for _, info := range names {
+		left = append(left, info.Name())
+	}
+	sort.Strings(left)
+	if strings.Join(left, " ") != ".stfolder copy existing" {
+		t.Errorf("Unexpected files left: %v", left)
+	}
+
+	expected := "default:dir default:dir/file default:dir/file default:copy default:copy/file default:existing default:dir"
+	if strings.Join(changed, " ") != expected {
+		t.Errorf("Unexpected changes reported: %v", changed)
+	}
+}
+
+func TestHandlerSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+
+	dir, err := ioutil.TempDir("", "webdav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outside := filepath.Join(dir, "outside")
+	folder := filepath.Join(dir, "folder")
+	os.Mkdir(outside, 0777)
+	os.Mkdir(folder, 0777)
+	ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644)
+	if err := os.Symlink(outside, filepath.Join(folder, "link")); err != nil {
+		t.Fatal(err)
+	}
+	os.Mkdir(filepath.Join(folder, "dir"), 0777)
+	if err := os.Symlink(outside, filepath.Join(folder, "dir", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandler(fs.DefaultFilesystem, func() map[string]Folder {
+		return map[string]Folder{"default": {ID: "default", Path: folder}}
+	}, nil)
+	do := func(method, path string) int {
+		r, _ := http.NewRequest(method, path, strings.NewReader("data"))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// Neither the symlinks nor anything through them are reachable, however
+	// deep they are
+
+	for _, path := range []string{"/default/link", "/default/link/secret", "/default/dir/link/secret"} {
+		if code := do("GET", path); code != 404 {
+			t.Errorf("GET %s got %d", path, code)
+		}
+	}
+	for _, path := range []string{"/default/link/new", "/default/dir/link/new"} {
+		if code := do("PUT", path); code != 404 {
+			t.Errorf("PUT %s got %d", path, code)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new")); !os.IsNotExist(err) {
+		t.Error("File written outside the folder")
+	}
+}